@@ -104,6 +104,10 @@ Flags:
 		and diagnose imports that would cause a circular dependency.
 	-pack
 		Write a package (archive) file rather than an object file
+	-pgoprofile file
+		Use the CPU profile in file, in pprof format, for profile-guided
+		optimization: inline more aggressively at hot call sites and
+		devirtualize hot interface method calls.
 	-race
 		Compile with race detector enabled.
 	-s
@@ -32,6 +32,10 @@ type DebugFlags struct {
 	Nil                  int    `help:"print information about nil checks"`
 	NoOpenDefer          int    `help:"disable open-coded defers"`
 	PCTab                string `help:"print named pc-value table\nOne of: pctospadj, pctofile, pctoline, pctoinline, pctopcdata"`
+	PGOCDFThreshold      int    `help:"percentage of profile weight covered by the call edges considered hot"`
+	PGODevirtualize      int    `help:"enable profile-guided devirtualization"`
+	PGOInline            int    `help:"enable profile-guided inlining; >1 prints hot call sites"`
+	PGOInlineBudget      int    `help:"inline budget for hot call sites (0: default)"`
 	Panic                int    `help:"show all compiler panics"`
 	Slice                int    `help:"print information about slice compilation"`
 	SoftFloat            int    `help:"force compiler to emit soft-float code"`
@@ -0,0 +1,192 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package devirtualize
+
+import (
+	"strings"
+
+	"cmd/compile/internal/base"
+	"cmd/compile/internal/ir"
+	"cmd/compile/internal/pgo"
+	"cmd/compile/internal/typecheck"
+	"cmd/compile/internal/types"
+	"cmd/internal/src"
+)
+
+// ProfileGuided performs call-site specific devirtualization of the
+// interface method calls in the package being compiled that profile p
+// shows to be hot. A hot call
+//
+//	i.M(args)
+//
+// whose calls mostly go to the method M of a concrete type T is
+// rewritten to
+//
+//	if t, ok := i.(T); ok {
+//		t.M(args)
+//	} else {
+//		i.M(args)
+//	}
+//
+// The direct call to t.M is then a candidate for inlining, so
+// ProfileGuided must run before the inliner.
+//
+// Only methods declared in the package being compiled are considered
+// as targets.
+func ProfileGuided(p *pgo.Profile) {
+	hot := p.HotEdges(base.Debug.PGOCDFThreshold)
+	if len(hot) == 0 {
+		return
+	}
+
+	// Index the methods declared in this package by linker symbol
+	// name, as they appear in the profile.
+	methods := make(map[string]*ir.Func)
+	for _, n := range typecheck.Target.Decls {
+		if n.Op() != ir.ODCLFUNC {
+			continue
+		}
+		fn := n.(*ir.Func)
+		if fn.Type().Recv() == nil || fn.Type().HasTParam() || fn.Type().HasShape() {
+			continue
+		}
+		methods[pgo.FuncName(fn)] = fn
+	}
+
+	for _, n := range typecheck.Target.Decls {
+		if n.Op() != ir.ODCLFUNC {
+			continue
+		}
+		fn := n.(*ir.Func)
+		if fn.Type().HasTParam() || fn.Type().HasShape() {
+			continue
+		}
+		profileGuidedFunc(fn, p, hot, methods)
+	}
+	ir.CurFunc = nil
+}
+
+func profileGuidedFunc(fn *ir.Func, p *pgo.Profile, hot map[pgo.CallEdge]bool, methods map[string]*ir.Func) {
+	ir.CurFunc = fn
+	name := pgo.FuncName(fn)
+
+	var edit func(n ir.Node) ir.Node
+	edit = func(n ir.Node) ir.Node {
+		if n == nil {
+			return n
+		}
+		switch n.Op() {
+		case ir.OCLOSURE, ir.ODEFER, ir.OGO:
+			// Closure bodies are not visited, like the inliner.
+			// Deferred and go'd calls must stay calls.
+			return n
+		}
+
+		ir.EditChildren(n, edit)
+
+		if n.Op() != ir.OCALLINTER {
+			return n
+		}
+		call := n.(*ir.CallExpr)
+		callee, typ := hotCallee(fn, name, call, p, hot, methods)
+		if callee == nil {
+			return n
+		}
+		return rewriteCondCall(call, fn, callee, typ)
+	}
+	ir.EditChildren(fn, edit)
+}
+
+// hotCallee returns the method that the profile shows to be the hot
+// target of the interface call in fn, whose profile name is caller,
+// along with its receiver type, or nil if there is no such method that
+// the call can be devirtualized to.
+func hotCallee(fn *ir.Func, caller string, call *ir.CallExpr, p *pgo.Profile, hot map[pgo.CallEdge]bool, methods map[string]*ir.Func) (*ir.Func, *types.Type) {
+	e, ok := p.HottestCallee(caller, pgo.NodeLineOffset(call, fn))
+	if !ok || !hot[e] {
+		return nil, nil
+	}
+	callee := methods[e.Callee]
+	if callee == nil {
+		if base.Debug.PGODevirtualize > 1 {
+			base.WarnfAt(call.Pos(), "PGO devirtualize: hot callee %s is not a method of this package", e.Callee)
+		}
+		return nil, nil
+	}
+
+	sel := call.X.(*ir.SelectorExpr)
+	iface := sel.X.Type()
+	typ := callee.Type().Recv().Type
+	if iface.HasShape() || typ.IsInterface() {
+		return nil, nil
+	}
+	if !strings.HasSuffix(callee.Sym().Name, "."+sel.Sel.Name) || !typecheck.Implements(typ, iface) {
+		// The profile is stale, or two calls share a line.
+		if base.Debug.PGODevirtualize > 1 {
+			base.WarnfAt(call.Pos(), "PGO devirtualize: hot callee %s does not match %v", e.Callee, sel)
+		}
+		return nil, nil
+	}
+	return callee, typ
+}
+
+// rewriteCondCall rewrites the interface call to a conditional call
+// of callee, a method of the concrete type typ, as described in
+// ProfileGuided. The result is an OINLCALL node that replaces call.
+func rewriteCondCall(call *ir.CallExpr, curfn, callee *ir.Func, typ *types.Type) ir.Node {
+	if base.Flag.LowerM != 0 {
+		base.WarnfAt(call.Pos(), "PGO devirtualizing %v to %v", call.X, ir.PkgFuncName(callee))
+	}
+
+	pos := call.Pos()
+	sel := call.X.(*ir.SelectorExpr)
+	typecheck.FixVariadicCall(call)
+
+	// Evaluate the receiver and arguments once, up front,
+	// since each is used in both branches.
+	init := ir.TakeInit(call)
+	recv := typecheck.TempAt(pos, curfn, sel.X.Type())
+	init.Append(typecheck.Stmt(ir.NewAssignStmt(pos, recv, sel.X)))
+	args := make([]ir.Node, len(call.Args))
+	for i, arg := range call.Args {
+		tmp := typecheck.TempAt(pos, curfn, arg.Type())
+		init.Append(typecheck.Stmt(ir.NewAssignStmt(pos, tmp, arg)))
+		args[i] = tmp
+	}
+
+	var retvars []ir.Node
+	for _, f := range call.X.Type().Results().FieldSlice() {
+		retvars = append(retvars, typecheck.TempAt(pos, curfn, f.Type))
+	}
+
+	// t, ok := recv.(T)
+	t := typecheck.TempAt(pos, curfn, typ)
+	ok := typecheck.TempAt(pos, curfn, types.Types[types.TBOOL])
+	assert := ir.NewAssignListStmt(pos, ir.OAS2, []ir.Node{t, ok}, []ir.Node{ir.NewTypeAssertExpr(pos, recv, ir.TypeNode(typ))})
+
+	concreteCall := typecheck.Call(pos, ir.NewSelectorExpr(pos, ir.OXDOT, t, sel.Sel), append([]ir.Node(nil), args...), call.IsDDD)
+	ifaceCall := typecheck.Call(pos, ir.NewSelectorExpr(pos, ir.OXDOT, recv, sel.Sel), append([]ir.Node(nil), args...), call.IsDDD)
+
+	ifStmt := ir.NewIfStmt(pos, ok, []ir.Node{assignResults(pos, retvars, concreteCall)}, []ir.Node{assignResults(pos, retvars, ifaceCall)})
+	body := []ir.Node{typecheck.Stmt(assert), typecheck.Stmt(ifStmt)}
+
+	res := ir.NewInlinedCallExpr(pos, body, retvars)
+	res.SetInit(init)
+	res.SetType(call.Type())
+	res.SetTypecheck(1)
+	return res
+}
+
+// assignResults returns a statement that assigns the results of call
+// to retvars.
+func assignResults(pos src.XPos, retvars []ir.Node, call ir.Node) ir.Node {
+	switch len(retvars) {
+	case 0:
+		return call
+	case 1:
+		return ir.NewAssignStmt(pos, retvars[0], call)
+	}
+	return ir.NewAssignListStmt(pos, ir.OAS2, append([]ir.Node(nil), retvars...), []ir.Node{call})
+}
@@ -16,6 +16,7 @@ import (
 	"cmd/compile/internal/ir"
 	"cmd/compile/internal/logopt"
 	"cmd/compile/internal/noder"
+	"cmd/compile/internal/pgo"
 	"cmd/compile/internal/pkginit"
 	"cmd/compile/internal/reflectdata"
 	"cmd/compile/internal/ssa"
@@ -241,10 +242,27 @@ func Main(archInit func(*ssagen.ArchInfo)) {
 		typecheck.AllImportedBodies()
 	}
 
+	// Read profile file and build profile-graph.
+	var profile *pgo.Profile
+	if base.Flag.PgoProfile != "" {
+		var err error
+		profile, err = pgo.Open(base.Flag.PgoProfile)
+		if err != nil {
+			log.Fatalf("%s: PGO error: %v", base.Flag.PgoProfile, err)
+		}
+	}
+
+	// Profile-guided devirtualization, which makes the devirtualized
+	// calls candidates for inlining.
+	if profile != nil && base.Debug.PGODevirtualize > 0 {
+		base.Timer.Start("fe", "pgo-devirtualization")
+		devirtualize.ProfileGuided(profile)
+	}
+
 	// Inlining
 	base.Timer.Start("fe", "inlining")
 	if base.Flag.LowerL != 0 {
-		inline.InlinePackage()
+		inline.InlinePackage(profile)
 	}
 	noder.MakeWrappers(typecheck.Target) // must happen after inlining
 
@@ -23,6 +23,10 @@
 //
 // The Debug.m flag enables diagnostic output.  a single -m is useful for verifying
 // which calls get inlined or not, more is for debugging, and may go away at any point.
+//
+// When compiling with a profile (-pgoprofile), call sites that the profile
+// shows to be hot are given a much larger budget (inlineHotMaxBudget), as
+// are the functions called from them.
 
 package inline
 
@@ -34,6 +38,7 @@ import (
 	"cmd/compile/internal/base"
 	"cmd/compile/internal/ir"
 	"cmd/compile/internal/logopt"
+	"cmd/compile/internal/pgo"
 	"cmd/compile/internal/typecheck"
 	"cmd/compile/internal/types"
 	"cmd/internal/obj"
@@ -51,10 +56,54 @@ const (
 
 	inlineBigFunctionNodes   = 5000 // Functions with this many nodes are considered "big".
 	inlineBigFunctionMaxCost = 20   // Max cost of inlinee when inlining into a "big" function.
+
+	inlineHotMaxBudget = 2000 // Max cost of inlinee at a hot call site, when using a profile.
+)
+
+var (
+	// hotCallees is the set of functions called from hot call sites,
+	// named by their linker symbols. They are allowed to be inlined
+	// with a budget of inlineHotMaxBudget.
+	hotCallees map[string]bool
+
+	// hotEdges is the set of hot call edges. Calls along these edges are
+	// inlined if the callee costs at most inlineHotMaxBudget.
+	hotEdges map[pgo.CallEdge]bool
+
+	// inlinedFuncs maps the indexes of base.Ctxt.InlTree to the functions
+	// inlined there, so that calls in inlined bodies can be matched with
+	// call edges of the profile. It is only maintained with a profile.
+	inlinedFuncs map[int]*ir.Func
 )
 
+// pgoInlinePrologue records the hot call sites in profile p.
+func pgoInlinePrologue(p *pgo.Profile) {
+	hotEdges = p.HotEdges(base.Debug.PGOCDFThreshold)
+	hotCallees = make(map[string]bool)
+	inlinedFuncs = make(map[int]*ir.Func)
+	for e := range hotEdges {
+		hotCallees[e.Callee] = true
+		if base.Debug.PGOInline > 1 {
+			fmt.Printf("hot-edge %s -> %s at line offset %d, weight %d\n", e.Caller, e.Callee, e.CallSiteOffset, p.EdgeWeight[e])
+		}
+	}
+}
+
+// hotBudget returns the inlining budget for calls along hot edges.
+func hotBudget() int32 {
+	if base.Debug.PGOInlineBudget != 0 {
+		return int32(base.Debug.PGOInlineBudget)
+	}
+	return inlineHotMaxBudget
+}
+
 // InlinePackage finds functions that can be inlined and clones them before walk expands them.
-func InlinePackage() {
+// If profile is non-nil, it is used to inline more aggressively at hot call sites.
+func InlinePackage(profile *pgo.Profile) {
+	if profile != nil && base.Debug.PGOInline > 0 {
+		pgoInlinePrologue(profile)
+	}
+
 	ir.VisitFuncsBottomUp(typecheck.Target.Decls, func(list []*ir.Func, recursive bool) {
 		numfns := numNonClosures(list)
 		for _, n := range list {
@@ -166,8 +215,17 @@ func CanInline(fn *ir.Func) {
 	// locals, and we use this map to produce a pruned Inline.Dcl
 	// list. See issue 25249 for more context.
 
+	budget := int32(inlineMaxBudget)
+	if hotCallees != nil && hotCallees[pgo.FuncName(fn)] {
+		budget = hotBudget()
+		if base.Debug.PGOInline > 1 {
+			fmt.Printf("hot-node enabled increased budget=%v for func=%v\n", budget, ir.PkgFuncName(fn))
+		}
+	}
+
 	visitor := hairyVisitor{
-		budget:        inlineMaxBudget,
+		budget:        budget,
+		maxBudget:     budget,
 		extraCallCost: cc,
 	}
 	if visitor.tooHairy(fn) {
@@ -176,7 +234,7 @@ func CanInline(fn *ir.Func) {
 	}
 
 	n.Func.Inl = &ir.Inline{
-		Cost: inlineMaxBudget - visitor.budget,
+		Cost: budget - visitor.budget,
 		Dcl:  pruneUnusedAutos(n.Defn.(*ir.Func).Dcl, &visitor),
 		Body: inlcopylist(fn.Body),
 
@@ -184,12 +242,12 @@ func CanInline(fn *ir.Func) {
 	}
 
 	if base.Flag.LowerM > 1 {
-		fmt.Printf("%v: can inline %v with cost %d as: %v { %v }\n", ir.Line(fn), n, inlineMaxBudget-visitor.budget, fn.Type(), ir.Nodes(n.Func.Inl.Body))
+		fmt.Printf("%v: can inline %v with cost %d as: %v { %v }\n", ir.Line(fn), n, n.Func.Inl.Cost, fn.Type(), ir.Nodes(n.Func.Inl.Body))
 	} else if base.Flag.LowerM != 0 {
 		fmt.Printf("%v: can inline %v\n", ir.Line(fn), n)
 	}
 	if logopt.Enabled() {
-		logopt.LogOpt(fn.Pos(), "canInlineFunction", "inline", ir.FuncName(fn), fmt.Sprintf("cost: %d", inlineMaxBudget-visitor.budget))
+		logopt.LogOpt(fn.Pos(), "canInlineFunction", "inline", ir.FuncName(fn), fmt.Sprintf("cost: %d", n.Func.Inl.Cost))
 	}
 }
 
@@ -229,6 +287,7 @@ func canDelayResults(fn *ir.Func) bool {
 // hairiness and whether or not it can be inlined.
 type hairyVisitor struct {
 	budget        int32
+	maxBudget     int32
 	reason        string
 	extraCallCost int32
 	usedLocals    ir.NameSet
@@ -241,7 +300,7 @@ func (v *hairyVisitor) tooHairy(fn *ir.Func) bool {
 		return true
 	}
 	if v.budget < 0 {
-		v.reason = fmt.Sprintf("function too complex: cost %d exceeds budget %d", inlineMaxBudget-v.budget, inlineMaxBudget)
+		v.reason = fmt.Sprintf("function too complex: cost %d exceeds budget %d", v.maxBudget-v.budget, v.maxBudget)
 		return true
 	}
 	return false
@@ -668,13 +727,20 @@ func mkinlcall(n *ir.CallExpr, fn *ir.Func, maxCost int32, inlMap map[*ir.Func]b
 		return n
 	}
 	if fn.Inl.Cost > maxCost {
-		// The inlined function body is too big. Typically we use this check to restrict
-		// inlining into very big functions.  See issue 26546 and 17566.
-		if logopt.Enabled() {
-			logopt.LogOpt(n.Pos(), "cannotInlineCall", "inline", ir.FuncName(ir.CurFunc),
-				fmt.Sprintf("cost %d of %s exceeds max large caller cost %d", fn.Inl.Cost, ir.PkgFuncName(fn), maxCost))
+		// If the call site is hot, allow inlining up to the hot budget.
+		if hotEdges != nil && fn.Inl.Cost <= hotBudget() && hotEdges[callEdge(n, fn)] {
+			if base.Debug.PGOInline > 1 {
+				fmt.Printf("hot-budget check allows inlining for call %s (cost %d) at %v in function %s\n", ir.PkgFuncName(fn), fn.Inl.Cost, ir.Line(n), ir.PkgFuncName(ir.CurFunc))
+			}
+		} else {
+			// The inlined function body is too big. Typically we use this check to restrict
+			// inlining into very big functions.  See issue 26546 and 17566.
+			if logopt.Enabled() {
+				logopt.LogOpt(n.Pos(), "cannotInlineCall", "inline", ir.FuncName(ir.CurFunc),
+					fmt.Sprintf("cost %d of %s exceeds max large caller cost %d", fn.Inl.Cost, ir.PkgFuncName(fn), maxCost))
+			}
+			return n
 		}
-		return n
 	}
 
 	if fn == ir.CurFunc {
@@ -733,6 +799,9 @@ func mkinlcall(n *ir.CallExpr, fn *ir.Func, maxCost int32, inlMap map[*ir.Func]b
 
 	sym := fn.Linksym()
 	inlIndex := base.Ctxt.InlTree.Add(parent, n.Pos(), sym)
+	if inlinedFuncs != nil {
+		inlinedFuncs[inlIndex] = fn
+	}
 
 	if base.Flag.GenDwarfInl > 0 {
 		if !sym.WasInlined() {
@@ -768,6 +837,22 @@ func mkinlcall(n *ir.CallExpr, fn *ir.Func, maxCost int32, inlMap map[*ir.Func]b
 	return res
 }
 
+// callEdge returns the profile call edge for the call n to fn.
+// The caller is the function that contains the call in the source:
+// for a call that was itself inlined from another function, that is
+// the inlined function, not ir.CurFunc.
+func callEdge(n *ir.CallExpr, fn *ir.Func) pgo.CallEdge {
+	caller := ir.CurFunc
+	if inlIndex := base.Ctxt.PosTable.Pos(n.Pos()).Base().InliningIndex(); inlIndex >= 0 {
+		caller = inlinedFuncs[inlIndex]
+	}
+	return pgo.CallEdge{
+		Caller:         pgo.FuncName(caller),
+		Callee:         pgo.FuncName(fn),
+		CallSiteOffset: pgo.NodeLineOffset(n, caller),
+	}
+}
+
 // CalleeEffects appends any side effects from evaluating callee to init.
 func CalleeEffects(init *ir.Nodes, callee ir.Node) {
 	for {
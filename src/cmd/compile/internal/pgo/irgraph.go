@@ -0,0 +1,245 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package pgo contains the compiler's support for profile-guided
+// optimization (PGO).
+//
+// The compiler reads a CPU profile in pprof format (typically collected
+// from a production binary with runtime/pprof) and summarizes it as a
+// weighted call graph. Each edge of the graph records that one function
+// called another from a particular line, and how much of the profile
+// was spent in the callee, or in the functions it called, when it was
+// called that way. The inliner and the devirtualizer consult the graph
+// to find hot call sites.
+//
+// Call sites are identified by the offset of the line of the call from
+// the line of the calling function's declaration, as given by the start
+// line that the profile records for each function. A profile collected
+// from an older version of the source therefore still applies to
+// functions that have moved as a whole, and simply has no effect on call
+// sites that have moved within their function. Calls made by functions
+// without a recorded start line, such as functions that were inlined
+// when the profile was collected, are not part of the graph.
+package pgo
+
+import (
+	"fmt"
+	"internal/profile"
+	"os"
+	"sort"
+	"strings"
+
+	"cmd/compile/internal/base"
+	"cmd/compile/internal/ir"
+	"cmd/internal/obj"
+	"cmd/internal/objabi"
+)
+
+// A CallEdge is a call from one function to another at a particular
+// line of the caller. Functions are named by their linker symbol
+// names, as they appear in the profile (for example, "main.(*T).M").
+type CallEdge struct {
+	Caller string
+	Callee string
+
+	// CallSiteOffset is the line of the call relative to the line
+	// of the caller's declaration.
+	CallSiteOffset int
+}
+
+// LinkName returns the name of the symbol s as the linker, and so the
+// profile, sees it: symbols of the package being compiled have their
+// "" prefix replaced with the package path.
+func LinkName(s *obj.LSym) string {
+	if name := s.Name; strings.HasPrefix(name, `"".`) {
+		return objabi.PathToPrefix(base.Ctxt.Pkgpath) + name[len(`""`):]
+	}
+	return s.Name
+}
+
+// FuncName returns the name of fn as it appears in a profile.
+func FuncName(fn *ir.Func) string {
+	return LinkName(fn.Linksym())
+}
+
+// NodeLineOffset returns the offset of the line of n from the line of
+// the declaration of fn, the function whose source contains n, for use
+// as the CallSiteOffset of a call. For a node inlined into another
+// function, fn is the inlined function.
+func NodeLineOffset(n ir.Node, fn *ir.Func) int {
+	line := base.Ctxt.InnermostPos(n.Pos()).RelLine()
+	start := base.Ctxt.InnermostPos(fn.Pos()).RelLine()
+	return int(line) - int(start)
+}
+
+// A callSite is a call position in a function, without the callee.
+type callSite struct {
+	caller string
+	offset int
+}
+
+// A Profile is a weighted call graph derived from a CPU profile.
+type Profile struct {
+	// TotalWeight is the sum of the weights of all edges.
+	TotalWeight int64
+
+	// EdgeWeight maps each call edge to its weight: the sum of the
+	// sample values of all samples whose stack goes through the edge.
+	EdgeWeight map[CallEdge]int64
+
+	// sites indexes the edges in EdgeWeight by call site.
+	sites map[callSite][]CallEdge
+
+	// hot caches the result of the last call to HotEdges.
+	hot          map[CallEdge]bool
+	hotThreshold int
+}
+
+// Open reads the pprof profile in the named file and returns the
+// call graph it describes.
+func Open(name string) (*Profile, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := profile.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("parsing profile: %v", err)
+	}
+	return New(p)
+}
+
+// New returns the call graph described by the pprof profile p.
+func New(p *profile.Profile) (*Profile, error) {
+	g := &Profile{
+		EdgeWeight: make(map[CallEdge]int64),
+		sites:      make(map[callSite][]CallEdge),
+	}
+	if len(p.Sample) == 0 {
+		// An empty profile is valid; it just has nothing to say.
+		return g, nil
+	}
+	vi, err := sampleIndex(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var frames []profile.Line
+	seen := make(map[CallEdge]bool)
+	for _, s := range p.Sample {
+		w := s.Value[vi]
+		if w <= 0 {
+			continue
+		}
+
+		// Each location lists its inlined frames innermost first, so
+		// the lines of the locations, in order, are the frames of the
+		// stack from the leaf to the root.
+		frames = frames[:0]
+		for _, loc := range s.Location {
+			frames = append(frames, loc.Line...)
+		}
+
+		// The weight of a sample is attributed to every call edge of
+		// its stack, but only once to an edge that recursion repeats.
+		for e := range seen {
+			delete(seen, e)
+		}
+		for i := 0; i+1 < len(frames); i++ {
+			callee, caller := frames[i], frames[i+1]
+			if callee.Function == nil || caller.Function == nil || caller.Function.StartLine == 0 {
+				continue
+			}
+			e := CallEdge{
+				Caller:         caller.Function.Name,
+				Callee:         callee.Function.Name,
+				CallSiteOffset: int(caller.Line - caller.Function.StartLine),
+			}
+			if seen[e] {
+				continue
+			}
+			seen[e] = true
+			if _, ok := g.EdgeWeight[e]; !ok {
+				site := callSite{e.Caller, e.CallSiteOffset}
+				g.sites[site] = append(g.sites[site], e)
+			}
+			g.EdgeWeight[e] += w
+			g.TotalWeight += w
+		}
+	}
+	return g, nil
+}
+
+// sampleIndex returns the index of the sample value to use as the
+// weight of a sample: the sample count if the profile has one, or
+// else the CPU time.
+func sampleIndex(p *profile.Profile) (int, error) {
+	for i, st := range p.SampleType {
+		if st.Type == "samples" && st.Unit == "count" {
+			return i, nil
+		}
+	}
+	for i, st := range p.SampleType {
+		if st.Type == "cpu" && st.Unit == "nanoseconds" {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("profile does not contain a sample index with value/type samples/count or cpu/nanoseconds")
+}
+
+// HotEdges returns the set of hottest edges that together account for
+// at least threshold percent of the total edge weight.
+// The result must not be modified.
+func (p *Profile) HotEdges(threshold int) map[CallEdge]bool {
+	if p.hot != nil && p.hotThreshold == threshold {
+		return p.hot
+	}
+
+	edges := make([]CallEdge, 0, len(p.EdgeWeight))
+	for e := range p.EdgeWeight {
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		ei, ej := edges[i], edges[j]
+		if wi, wj := p.EdgeWeight[ei], p.EdgeWeight[ej]; wi != wj {
+			return wi > wj
+		}
+		// Break ties deterministically.
+		if ei.Caller != ej.Caller {
+			return ei.Caller < ej.Caller
+		}
+		if ei.Callee != ej.Callee {
+			return ei.Callee < ej.Callee
+		}
+		return ei.CallSiteOffset < ej.CallSiteOffset
+	})
+
+	hot := make(map[CallEdge]bool)
+	var cum int64
+	for _, e := range edges {
+		if p.TotalWeight == 0 || cum*100 >= int64(threshold)*p.TotalWeight {
+			break
+		}
+		hot[e] = true
+		cum += p.EdgeWeight[e]
+	}
+
+	p.hot, p.hotThreshold = hot, threshold
+	return hot
+}
+
+// HottestCallee returns the edge with the greatest weight among the
+// calls made by caller at the line at offset from its declaration,
+// and reports whether there is any.
+func (p *Profile) HottestCallee(caller string, offset int) (CallEdge, bool) {
+	var best CallEdge
+	found := false
+	for _, e := range p.sites[callSite{caller, offset}] {
+		if !found || p.EdgeWeight[e] > p.EdgeWeight[best] || p.EdgeWeight[e] == p.EdgeWeight[best] && e.Callee < best.Callee {
+			best, found = e, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,182 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pgo
+
+import (
+	"bytes"
+	"internal/profile"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// A testFrame is a frame of a test sample: a function name and the line
+// being executed in it.
+type testFrame struct {
+	fn   string
+	line int64
+}
+
+// makeProfile returns a CPU profile with one sample per element of
+// stacks, each of the given weight. Each stack is listed leaf first,
+// and each element of a stack is one location; a location with several
+// frames represents inlined calls, innermost first. The start lines of
+// the functions are given by starts; functions missing from it have no
+// recorded start line.
+func makeProfile(stacks [][][]testFrame, weights []int64, starts map[string]int64) *profile.Profile {
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		PeriodType: &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:     10000000,
+	}
+	funcs := make(map[string]*profile.Function)
+	for i, stack := range stacks {
+		s := &profile.Sample{Value: []int64{weights[i], weights[i] * p.Period}}
+		for _, frames := range stack {
+			loc := &profile.Location{ID: uint64(len(p.Location) + 1)}
+			for _, f := range frames {
+				fn := funcs[f.fn]
+				if fn == nil {
+					fn = &profile.Function{ID: uint64(len(p.Function) + 1), Name: f.fn, StartLine: starts[f.fn]}
+					funcs[f.fn] = fn
+					p.Function = append(p.Function, fn)
+				}
+				loc.Line = append(loc.Line, profile.Line{Function: fn, Line: f.line})
+			}
+			p.Location = append(p.Location, loc)
+			s.Location = append(s.Location, loc)
+		}
+		p.Sample = append(p.Sample, s)
+	}
+	return p
+}
+
+func TestNew(t *testing.T) {
+	p := makeProfile([][][]testFrame{
+		{{{"main.leaf", 3}}, {{"main.mid", 10}}, {{"main.main", 20}}},
+		{{{"main.leaf", 4}}, {{"main.mid", 10}}, {{"main.main", 20}}},
+		{{{"main.mid", 11}}, {{"main.main", 20}}},
+		// leaf was inlined into other, and other into main.
+		{{{"main.leaf", 3}, {"main.other", 30}, {"main.main", 21}}},
+		// Samples in a function with no caller have no edges.
+		{{{"main.main", 22}}},
+		// Recursion counts a sample only once for each edge.
+		{{{"main.rec", 5}}, {{"main.rec", 6}}, {{"main.rec", 6}}, {{"main.main", 23}}},
+		// Calls made by a function without a start line are ignored.
+		{{{"main.leaf", 3}}, {{"main.nostart", 40}}, {{"main.main", 24}}},
+	}, []int64{5, 2, 1, 4, 7, 3, 6}, map[string]int64{
+		"main.main":  15,
+		"main.mid":   8,
+		"main.other": 28,
+		"main.rec":   4,
+		"main.leaf":  1,
+	})
+
+	g, err := New(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[CallEdge]int64{
+		{Caller: "main.mid", Callee: "main.leaf", CallSiteOffset: 2}:     7,
+		{Caller: "main.main", Callee: "main.mid", CallSiteOffset: 5}:     8,
+		{Caller: "main.other", Callee: "main.leaf", CallSiteOffset: 2}:   4,
+		{Caller: "main.main", Callee: "main.other", CallSiteOffset: 6}:   4,
+		{Caller: "main.rec", Callee: "main.rec", CallSiteOffset: 2}:      3,
+		{Caller: "main.main", Callee: "main.rec", CallSiteOffset: 8}:     3,
+		{Caller: "main.main", Callee: "main.nostart", CallSiteOffset: 9}: 6,
+	}
+	if !reflect.DeepEqual(g.EdgeWeight, want) {
+		t.Errorf("EdgeWeight = %v, want %v", g.EdgeWeight, want)
+	}
+	if g.TotalWeight != 35 {
+		t.Errorf("TotalWeight = %d, want 35", g.TotalWeight)
+	}
+}
+
+func TestHotEdges(t *testing.T) {
+	p := makeProfile([][][]testFrame{
+		{{{"a", 1}}, {{"main", 1}}},
+		{{{"b", 1}}, {{"main", 2}}},
+		{{{"c", 1}}, {{"main", 3}}},
+		{{{"d", 1}}, {{"main", 4}}},
+	}, []int64{60, 30, 9, 1}, map[string]int64{"main": 1})
+	g, err := New(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		threshold int
+		want      []string
+	}{
+		{0, nil},
+		{50, []string{"a"}},
+		{60, []string{"a"}},
+		{61, []string{"a", "b"}},
+		{90, []string{"a", "b"}},
+		{99, []string{"a", "b", "c"}},
+		{100, []string{"a", "b", "c", "d"}},
+	} {
+		hot := g.HotEdges(test.threshold)
+		var got []string
+		for _, callee := range []string{"a", "b", "c", "d"} {
+			for e := range hot {
+				if e.Callee == callee {
+					got = append(got, callee)
+				}
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("HotEdges(%d) = %v, want %v", test.threshold, got, test.want)
+		}
+	}
+}
+
+func TestHottestCallee(t *testing.T) {
+	p := makeProfile([][][]testFrame{
+		{{{"main.(*T).M", 1}}, {{"main.f", 10}}},
+		{{{"main.(*U).M", 1}}, {{"main.f", 10}}},
+		{{{"main.g", 1}}, {{"main.f", 11}}},
+	}, []int64{2, 3, 10}, map[string]int64{"main.f": 5})
+	g, err := New(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, ok := g.HottestCallee("main.f", 5)
+	if want := (CallEdge{"main.f", "main.(*U).M", 5}); !ok || e != want {
+		t.Errorf("HottestCallee(main.f, 5) = %v, %v, want %v, true", e, ok, want)
+	}
+	if e, ok := g.HottestCallee("main.f", 7); ok {
+		t.Errorf("HottestCallee(main.f, 7) = %v, true, want false", e)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	var buf bytes.Buffer
+	p := makeProfile([][][]testFrame{{{{"main.g", 1}}, {{"main.f", 10}}}}, []int64{1}, map[string]int64{"main.f": 5})
+	if err := p.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "default.pgo")
+	if err := os.WriteFile(name, buf.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+	g, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := g.EdgeWeight[CallEdge{"main.f", "main.g", 5}]; w != 1 {
+		t.Errorf("edge weight = %d, want 1", w)
+	}
+
+	// A profile without CPU samples is rejected.
+	p.SampleType = []*profile.ValueType{{Type: "alloc_space", Unit: "bytes"}, {Type: "inuse_space", Unit: "bytes"}}
+	if _, err := New(p); err == nil {
+		t.Errorf("New succeeded with a heap profile")
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"fmt"
+	"internal/profile"
+	"internal/testenv"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const pgoSrc = `package pgo
+
+type Adder interface {
+	Add(x, y int) int
+}
+
+type Add struct{ n int }
+
+func (a *Add) Add(x, y int) int {
+	for i := 0; i < 3; i++ {
+		x += y ^ i
+		if x > 1000000 {
+			x -= a.n
+		}
+	}
+	return x
+}
+
+type Sub struct{}
+
+func (Sub) Add(x, y int) int { return x - y }
+
+func big(x int) int {
+	s := 0
+	for i := 0; i < 10; i++ {
+		s += x * i
+		s ^= s >> 3
+		s += x / (i + 1)
+		s -= x % (i + 2)
+		s ^= s << 2
+		s += i * i * x
+		s = s*7 + 3
+		s = s%1000003 + x
+		s = s*11 + 5
+		s = s%1000033 + i
+	}
+	return s
+}
+
+func Run(a Adder, n int) int {
+	s := 0
+	for i := 0; i < n; i++ {
+		s = a.Add(s, i) // CALL Add
+		s += big(i)     // CALL big
+	}
+	return s
+}
+
+func Cold(n int) int {
+	return big(n) // CALL cold big
+}
+`
+
+// pgoSrcLine returns the line of pgoSrc that ends in the comment marker.
+func pgoSrcLine(t *testing.T, marker string) int64 {
+	for i, l := range strings.Split(pgoSrc, "\n") {
+		if strings.HasSuffix(l, "// "+marker) {
+			return int64(i + 1)
+		}
+	}
+	t.Fatalf("missing marker %q", marker)
+	return 0
+}
+
+// pgoFuncLine returns the line of pgoSrc that declares the named function.
+func pgoFuncLine(t *testing.T, name string) int64 {
+	for i, l := range strings.Split(pgoSrc, "\n") {
+		if strings.HasPrefix(l, "func "+name+"(") {
+			return int64(i + 1)
+		}
+	}
+	t.Fatalf("missing function %s", name)
+	return 0
+}
+
+// pgoProfile returns a profile for pgoSrc in which calls from Run to
+// big and (*Add).Add are hot.
+func pgoProfile(t *testing.T) *profile.Profile {
+	line := func(marker string) int64 { return pgoSrcLine(t, marker) }
+
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		PeriodType: &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:     10000000,
+	}
+	// Call sites are identified by their line offset from the start of the
+	// calling function, so the callers need a start line.
+	starts := map[string]int64{
+		"example.com/pgo.Run":  pgoFuncLine(t, "Run"),
+		"example.com/pgo.Cold": pgoFuncLine(t, "Cold"),
+	}
+	addSample := func(weight int64, callee, caller string, callLine int64) {
+		fn := func(name string) *profile.Function {
+			for _, f := range p.Function {
+				if f.Name == name {
+					return f
+				}
+			}
+			f := &profile.Function{ID: uint64(len(p.Function) + 1), Name: name, StartLine: starts[name]}
+			p.Function = append(p.Function, f)
+			return f
+		}
+		leaf := &profile.Location{ID: uint64(len(p.Location) + 1), Line: []profile.Line{{Function: fn(callee), Line: 1}}}
+		p.Location = append(p.Location, leaf)
+		call := &profile.Location{ID: uint64(len(p.Location) + 1), Line: []profile.Line{{Function: fn(caller), Line: callLine}}}
+		p.Location = append(p.Location, call)
+		p.Sample = append(p.Sample, &profile.Sample{
+			Location: []*profile.Location{leaf, call},
+			Value:    []int64{weight, weight * p.Period},
+		})
+	}
+	addSample(900, "example.com/pgo.big", "example.com/pgo.Run", line("CALL big"))
+	addSample(99, "example.com/pgo.(*Add).Add", "example.com/pgo.Run", line("CALL Add"))
+	addSample(1, "example.com/pgo.big", "example.com/pgo.Cold", line("CALL cold big"))
+	return p
+}
+
+// TestPGOIntendedInliningAndDevirtualization checks that a profile
+// raises the inlining budget for hot call sites, and devirtualizes hot
+// interface calls, but leaves cold call sites alone.
+func TestPGOIntendedInliningAndDevirtualization(t *testing.T) {
+	testenv.MustHaveGoBuild(t)
+	t.Parallel()
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "pgo.go")
+	if err := os.WriteFile(src, []byte(pgoSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(dir, "default.pgo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pgoProfile(t).Write(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	compile := func(flags ...string) string {
+		args := append([]string{"tool", "compile", "-p", "example.com/pgo", "-o", filepath.Join(dir, "pgo.o"), "-m"}, flags...)
+		cmd := exec.Command(testenv.GoToolPath(t), append(args, src)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v failed: %v\n%s", cmd, err, out)
+		}
+		return string(out)
+	}
+
+	want := []string{
+		fmt.Sprintf("pgo.go:%d:12: PGO devirtualizing a.Add to example.com/pgo.(*Add).Add", pgoSrcLine(t, "CALL Add")),
+		fmt.Sprintf("pgo.go:%d:12: inlining call to (*Add).Add", pgoSrcLine(t, "CALL Add")),
+		fmt.Sprintf("pgo.go:%d:11: inlining call to big", pgoSrcLine(t, "CALL big")),
+	}
+	notWant := []string{
+		fmt.Sprintf("pgo.go:%d:12: inlining call to big", pgoSrcLine(t, "CALL cold big")),
+	}
+
+	out := compile("-pgoprofile=" + filepath.Join(dir, "default.pgo"))
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Errorf("with profile: missing %q", w)
+		}
+	}
+	for _, w := range notWant {
+		if strings.Contains(out, w) {
+			t.Errorf("with profile: unexpected %q", w)
+		}
+	}
+	if t.Failed() {
+		t.Logf("output:\n%s", out)
+	}
+
+	// Without a profile, none of that happens.
+	out = compile()
+	for _, w := range append(want, notWant...) {
+		if strings.Contains(out, w) {
+			t.Errorf("without profile: unexpected %q\noutput:\n%s", w, out)
+		}
+	}
+}
@@ -172,6 +172,16 @@ func expandiface(t *Type) {
 	t.SetAllMethods(methods)
 }
 
+// isAtomicStdPkg reports whether p is sync/atomic or
+// runtime/internal/atomic, either imported or being compiled.
+func isAtomicStdPkg(p *Pkg) bool {
+	path := p.Path
+	if p == LocalPkg {
+		path = base.Ctxt.Pkgpath
+	}
+	return path == "sync/atomic" || path == "runtime/internal/atomic"
+}
+
 func calcStructOffset(errtype *Type, t *Type, o int64, flag int) int64 {
 	// flag is 0 (receiver), 1 (actual struct), or RegSize (in/out parameters)
 	isStruct := flag == 1
@@ -180,6 +190,13 @@ func calcStructOffset(errtype *Type, t *Type, o int64, flag int) int64 {
 	if maxalign < 1 {
 		maxalign = 1
 	}
+	// Special case: sync/atomic.align64 is an empty struct we recognize
+	// as a signal that the struct it contains must be 64-bit-aligned.
+	//
+	// This logic is duplicated in go/types and cmd/compile/internal/types2.
+	if isStruct && t.NumFields() == 0 && t.Sym() != nil && t.Sym().Name == "align64" && isAtomicStdPkg(t.Sym().Pkg) {
+		maxalign = 8
+	}
 	lastzero := int64(0)
 	for _, f := range t.Fields().Slice() {
 		if f.Type == nil {
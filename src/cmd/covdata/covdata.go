@@ -0,0 +1,278 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"internal/coverage"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"cmd/internal/objabi"
+)
+
+const usageMessage = "" +
+	`usage: go tool covdata <mode> -i=<dir1,dir2,...> -o=<output>
+
+Modes:
+	textfmt    convert coverage data to a text profile (-o names a file)
+	merge      merge coverage data directories (-o names a directory)
+	subtract   coverage of the first input minus that of the others
+	intersect  coverage common to all inputs
+`
+
+func usage() {
+	fmt.Fprint(os.Stderr, usageMessage)
+	fmt.Fprintln(os.Stderr, "\nFlags:")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+var (
+	inputs = flag.String("i", "", "comma-separated list of input directories")
+	output = flag.String("o", "", "output file or directory")
+)
+
+func main() {
+	objabi.AddVersionFlag()
+	flag.Usage = usage
+
+	var mode string
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		mode, args = args[0], args[1:]
+	}
+	flag.CommandLine.Parse(args)
+	if mode == "" || *inputs == "" || *output == "" || flag.NArg() != 0 {
+		usage()
+	}
+	dirs := strings.Split(*inputs, ",")
+
+	var op func([]map[string]*pod) map[string]*pod
+	switch mode {
+	case "textfmt", "merge":
+		op = merge
+	case "subtract":
+		op = subtract
+	case "intersect":
+		op = intersect
+	default:
+		fmt.Fprintf(os.Stderr, "covdata: unknown mode %q\n", mode)
+		usage()
+	}
+
+	in := make([]map[string]*pod, len(dirs))
+	for i, dir := range dirs {
+		pods, err := readDir(dir)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		in[i] = pods
+	}
+	out := op(in)
+
+	var err error
+	if mode == "textfmt" {
+		err = writeText(*output, out)
+	} else {
+		err = writeDir(*output, out)
+	}
+	if err != nil {
+		fatalf("%v", err)
+	}
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "covdata: "+format+"\n", args...)
+	os.Exit(1)
+}
+
+// A pod holds the coverage data of one program layout: its meta-data
+// and the accumulated counts of the counter data files referring to it.
+type pod struct {
+	meta   *coverage.Meta
+	counts [][]uint32 // indexed like coverage.Counters.Counts
+}
+
+func newPod(meta *coverage.Meta) *pod {
+	p := &pod{meta: meta}
+	for _, pkg := range meta.Packages {
+		for _, f := range pkg.Files {
+			p.counts = append(p.counts, make([]uint32, len(f.Blocks)))
+		}
+	}
+	return p
+}
+
+// add adds counts to the counts of p.
+func (p *pod) add(counts [][]uint32) {
+	for i, c := range counts {
+		for j, n := range c {
+			p.counts[i][j] = combine(p.meta.Mode, p.counts[i][j], n)
+		}
+	}
+}
+
+// combine returns the combination of two counts in the given mode.
+func combine(mode string, a, b uint32) uint32 {
+	if mode == "set" {
+		if a|b != 0 {
+			return 1
+		}
+		return 0
+	}
+	if s := a + b; s >= a {
+		return s
+	}
+	return math.MaxUint32
+}
+
+// A blockKey identifies a block independently of the program it
+// appears in.
+type blockKey struct {
+	file  string
+	block coverage.Block
+}
+
+// forEachBlock calls f for each block of p with its count.
+func (p *pod) forEachBlock(f func(k blockKey, count *uint32)) {
+	i := 0
+	for _, pkg := range p.meta.Packages {
+		for _, file := range pkg.Files {
+			for j, b := range file.Blocks {
+				f(blockKey{file.Name, b}, &p.counts[i][j])
+			}
+			i++
+		}
+	}
+}
+
+// readDir reads the coverage data files in dir, returning the pods
+// keyed by meta-data hash.
+func readDir(dir string) (map[string]*pod, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	pods := make(map[string]*pod)
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, coverage.MetaFilePrefix) {
+			continue
+		}
+		file := filepath.Join(dir, name)
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		hash := name[len(coverage.MetaFilePrefix):]
+		if coverage.Hash(data) != hash {
+			return nil, fmt.Errorf("%s: meta-data does not match file name", file)
+		}
+		meta, err := coverage.ParseMeta(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		pods[hash] = newPod(meta)
+	}
+	for _, e := range entries {
+		hash, ok := coverage.CounterFileHash(e.Name())
+		if !ok {
+			continue
+		}
+		file := filepath.Join(dir, e.Name())
+		p := pods[hash]
+		if p == nil {
+			return nil, fmt.Errorf("%s: missing meta-data file %s", file, coverage.MetaFilePrefix+hash)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		c, err := coverage.ParseCounters(data)
+		if err == nil && c.MetaHash != hash {
+			err = fmt.Errorf("counter data does not match file name")
+		}
+		if err == nil {
+			err = c.Check(p.meta)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		p.add(c.Counts)
+	}
+	if len(pods) == 0 {
+		return nil, fmt.Errorf("no coverage data files in %s", dir)
+	}
+	return pods, nil
+}
+
+// sortedHashes returns the keys of pods in sorted order.
+func sortedHashes(pods map[string]*pod) []string {
+	var hashes []string
+	for h := range pods {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+	return hashes
+}
+
+// writeDir writes pods as coverage data files into dir.
+func writeDir(dir string, pods map[string]*pod) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	for _, hash := range sortedHashes(pods) {
+		p := pods[hash]
+		meta := p.meta.Encode()
+		hash = coverage.Hash(meta)
+		if err := os.WriteFile(filepath.Join(dir, coverage.MetaFilePrefix+hash), meta, 0666); err != nil {
+			return err
+		}
+		c := &coverage.Counters{MetaHash: hash, Counts: p.counts}
+		name := coverage.CounterFileName(hash, os.Getpid(), time.Now().UnixNano())
+		if err := os.WriteFile(filepath.Join(dir, name), c.Encode(), 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeText writes pods to file as a text coverage profile, the format
+// written by 'go test -coverprofile'. Blocks appearing in more than one
+// program are combined.
+func writeText(file string, pods map[string]*pod) error {
+	mode := ""
+	counts := make(map[blockKey]uint32)
+	var order []blockKey
+	for _, hash := range sortedHashes(pods) {
+		p := pods[hash]
+		if mode == "" {
+			mode = p.meta.Mode
+		} else if p.meta.Mode != mode {
+			return fmt.Errorf("cannot combine coverage data with modes %q and %q", mode, p.meta.Mode)
+		}
+		p.forEachBlock(func(k blockKey, count *uint32) {
+			if _, ok := counts[k]; !ok {
+				order = append(order, k)
+			}
+			counts[k] = combine(mode, counts[k], *count)
+		})
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "mode: %s\n", mode)
+	for _, k := range order {
+		b := k.block
+		fmt.Fprintf(&buf, "%s:%d.%d,%d.%d %d %d\n", k.file, b.StartLine, b.StartCol, b.EndLine, b.EndCol, b.NumStmt, counts[k])
+	}
+	return os.WriteFile(file, buf.Bytes(), 0666)
+}
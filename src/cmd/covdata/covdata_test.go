@@ -0,0 +1,114 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"internal/coverage"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testMeta(mode string) *coverage.Meta {
+	return &coverage.Meta{
+		Mode: mode,
+		Packages: []coverage.Package{{
+			Path: "p",
+			Files: []coverage.File{{
+				Name: "p/p.go",
+				Blocks: []coverage.Block{
+					{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 1, NumStmt: 1},
+					{StartLine: 2, StartCol: 1, EndLine: 3, EndCol: 1, NumStmt: 2},
+					{StartLine: 3, StartCol: 1, EndLine: 4, EndCol: 1, NumStmt: 3},
+				},
+			}},
+		}},
+	}
+}
+
+// testInput returns the pods of an input directory holding the given
+// counts for the file of testMeta.
+func testInput(mode string, counts ...uint32) map[string]*pod {
+	p := newPod(testMeta(mode))
+	p.add([][]uint32{counts})
+	return map[string]*pod{"h": p}
+}
+
+func TestOps(t *testing.T) {
+	tests := []struct {
+		name string
+		op   func([]map[string]*pod) map[string]*pod
+		mode string
+		in   [][]uint32
+		want []uint32
+	}{
+		{"merge", merge, "count", [][]uint32{{1, 0, 2}, {3, 0, 0}}, []uint32{4, 0, 2}},
+		{"merge", merge, "set", [][]uint32{{1, 0, 1}, {1, 0, 0}}, []uint32{1, 0, 1}},
+		{"merge", merge, "count", [][]uint32{{1 << 31, 0, 0}, {1 << 31, 0, 0}}, []uint32{1<<32 - 1, 0, 0}},
+		{"subtract", subtract, "count", [][]uint32{{1, 2, 3}, {0, 5, 0}, {0, 0, 1}}, []uint32{1, 0, 0}},
+		{"intersect", intersect, "count", [][]uint32{{1, 2, 0}, {4, 0, 0}}, []uint32{5, 0, 0}},
+	}
+	for _, tt := range tests {
+		var in []map[string]*pod
+		for _, counts := range tt.in {
+			in = append(in, testInput(tt.mode, counts...))
+		}
+		out := tt.op(in)
+		if got := out["h"].counts[0]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s(%v) = %v, want %v", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDirRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	in := testInput("count", 1, 0, 7)
+	if err := writeDir(dir, in); err != nil {
+		t.Fatal(err)
+	}
+	// A second counter data file for the same program accumulates.
+	hash := coverage.Hash(testMeta("count").Encode())
+	c := &coverage.Counters{MetaHash: hash, Counts: [][]uint32{{1, 1, 0}}}
+	if err := os.WriteFile(filepath.Join(dir, coverage.CounterFileName(hash, 1, 1)), c.Encode(), 0666); err != nil {
+		t.Fatal(err)
+	}
+	pods, err := readDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 1 {
+		t.Fatalf("readDir returned %d pods, want 1", len(pods))
+	}
+	for _, p := range pods {
+		if want := []uint32{2, 1, 7}; !reflect.DeepEqual(p.counts[0], want) {
+			t.Errorf("counts = %v, want %v", p.counts[0], want)
+		}
+	}
+
+	file := filepath.Join(t.TempDir(), "profile.txt")
+	if err := writeText(file, pods); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "mode: count\n" +
+		"p/p.go:1.1,2.1 1 2\n" +
+		"p/p.go:2.1,3.1 2 1\n" +
+		"p/p.go:3.1,4.1 3 7\n"
+	if string(data) != want {
+		t.Errorf("text profile:\n%s\nwant:\n%s", data, want)
+	}
+
+	// A counter file without its meta-data file is an error.
+	if err := os.Remove(filepath.Join(dir, coverage.MetaFilePrefix+hash)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readDir(dir); err == nil {
+		t.Errorf("readDir succeeded without meta-data file")
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Covdata is a program for manipulating the coverage data files written
+by programs built with 'go build -cover'. When such a program exits,
+it writes its coverage data into the directory named by the GOCOVERDIR
+environment variable.
+
+Usage:
+
+	go tool covdata <mode> -i=<dir1,dir2,...> [flags]
+
+The modes are:
+
+	textfmt    convert the data to the text profile format used by
+	           'go test -coverprofile', written to the file named by -o
+	merge      merge the data of the input directories into the
+	           directory named by -o
+	subtract   write to the directory named by -o the coverage of the
+	           first input directory minus the blocks covered by any of
+	           the others
+	intersect  write to the directory named by -o the coverage of the
+	           blocks covered by every input directory
+
+For example, to view the combined coverage of two sets of runs:
+
+	go tool covdata merge -i=dir1,dir2 -o=merged
+	go tool covdata textfmt -i=merged -o=profile.txt
+	go tool cover -html=profile.txt
+*/
+package main
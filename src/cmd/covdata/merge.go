@@ -0,0 +1,86 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// The operations on coverage data. Each takes the pods read from each
+// input directory and returns the resulting pods. Data written by the
+// same program is combined in a single pod; subtract and intersect
+// compare blocks by file name and position, so that they also apply to
+// data written by different programs sharing packages.
+
+// merge combines the coverage of all inputs.
+func merge(in []map[string]*pod) map[string]*pod {
+	out := make(map[string]*pod)
+	for _, pods := range in {
+		for hash, p := range pods {
+			if q := out[hash]; q != nil {
+				q.add(p.counts)
+			} else {
+				out[hash] = p
+			}
+		}
+	}
+	return out
+}
+
+// covered returns the set of blocks covered by pods.
+func covered(pods map[string]*pod) map[blockKey]bool {
+	m := make(map[blockKey]bool)
+	for _, p := range pods {
+		p.forEachBlock(func(k blockKey, count *uint32) {
+			if *count != 0 {
+				m[k] = true
+			}
+		})
+	}
+	return m
+}
+
+// subtract returns the coverage of the first input, with the blocks
+// covered by any of the other inputs marked as not covered.
+func subtract(in []map[string]*pod) map[string]*pod {
+	var others []map[blockKey]bool
+	for _, pods := range in[1:] {
+		others = append(others, covered(pods))
+	}
+	out := in[0]
+	for _, p := range out {
+		p.forEachBlock(func(k blockKey, count *uint32) {
+			for _, c := range others {
+				if c[k] {
+					*count = 0
+				}
+			}
+		})
+	}
+	return out
+}
+
+// intersect returns the combined coverage of the inputs, with the
+// blocks not covered by every input marked as not covered. Programs
+// appear in the result only if they appear in the first input.
+func intersect(in []map[string]*pod) map[string]*pod {
+	var all []map[blockKey]bool
+	for _, pods := range in {
+		all = append(all, covered(pods))
+	}
+	out := make(map[string]*pod)
+	for hash, p := range in[0] {
+		for _, pods := range in[1:] {
+			if q := pods[hash]; q != nil {
+				p.add(q.counts)
+			}
+		}
+		p.forEachBlock(func(k blockKey, count *uint32) {
+			for _, c := range all {
+				if !c[k] {
+					*count = 0
+				}
+			}
+		})
+		out[hash] = p
+	}
+	return out
+}
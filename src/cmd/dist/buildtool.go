@@ -63,6 +63,7 @@ var bootstrapDirs = []string{
 	"internal/buildcfg",
 	"internal/goexperiment",
 	"internal/goversion",
+	"internal/profile",
 	"internal/race",
 	"internal/unsafeheader",
 	"internal/xcoff",
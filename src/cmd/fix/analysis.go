@@ -0,0 +1,148 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// The analyzers run by 'go fix'. Unlike the rewrites registered with
+// register, which apply to syntax alone, they run on type-checked
+// packages, invoked by the go command through the vet protocol, and
+// report their rewrites as suggested fixes; see cmd/internal/analysisfix.
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"cmd/internal/analysisfix"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+var analyzers = []*analysis.Analyzer{
+	efaceanyAnalyzer,
+	sortsliceAnalyzer,
+}
+
+// unitMode reports whether the command line arguments invoke fix as
+// an analysis tool, as the go command does, rather than as a rewriter
+// of the named files.
+func unitMode(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	return args[0] == "-flags" || args[0] == "help" || strings.HasSuffix(args[len(args)-1], ".cfg")
+}
+
+// goVersionAtLeast reports whether the package being analyzed by pass
+// may use the features of Go 1.minor. It requires analysisfix.GoVersion.
+func goVersionAtLeast(pass *analysis.Pass, minor int) bool {
+	v := pass.ResultOf[analysisfix.GoVersion].(string)
+	if v == "" {
+		return true
+	}
+	m, err := strconv.Atoi(strings.TrimPrefix(v, "go1."))
+	return err != nil || m >= minor
+}
+
+// fileOf returns the file of pass containing pos.
+func fileOf(pass *analysis.Pass, pos token.Pos) *ast.File {
+	for _, f := range pass.Files {
+		if f.Pos() <= pos && pos < f.End() {
+			return f
+		}
+	}
+	return nil
+}
+
+// importSpecOf returns the import of path in f, or nil.
+func importSpecOf(f *ast.File, path string) *ast.ImportSpec {
+	for _, spec := range f.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err == nil && p == path {
+			return spec
+		}
+	}
+	return nil
+}
+
+// importDecl returns the import declaration of f containing spec.
+func importDecl(f *ast.File, spec *ast.ImportSpec) *ast.GenDecl {
+	for _, decl := range f.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			for _, s := range gen.Specs {
+				if s == spec {
+					return gen
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// addImportEdit returns an edit adding an import of path to f. The
+// import is added to the first import declaration of f, and is put in
+// its place by gofmt.
+func addImportEdit(f *ast.File, path string) analysis.TextEdit {
+	quoted := []byte(strconv.Quote(path))
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		if gen.Lparen.IsValid() {
+			return analysis.TextEdit{Pos: gen.Rparen, End: gen.Rparen, NewText: append(append([]byte("\t"), quoted...), '\n')}
+		}
+		return analysis.TextEdit{Pos: gen.End(), End: gen.End(), NewText: append([]byte("\nimport "), quoted...)}
+	}
+	return analysis.TextEdit{Pos: f.Name.End(), End: f.Name.End(), NewText: append([]byte("\n\nimport "), quoted...)}
+}
+
+// deleteImportEdit returns an edit deleting the import spec from f.
+func deleteImportEdit(fset *token.FileSet, f *ast.File, spec *ast.ImportSpec) analysis.TextEdit {
+	decl := importDecl(f, spec)
+	if len(decl.Specs) == 1 {
+		return analysis.TextEdit{Pos: decl.Pos(), End: decl.End()}
+	}
+	// Delete the lines of the spec, if it has them to itself.
+	start, end := spec.Pos(), spec.End()
+	if spec.Doc != nil {
+		start = spec.Doc.Pos()
+	}
+	if spec.Comment != nil {
+		end = spec.Comment.End()
+	}
+	tf := fset.File(start)
+	first, last := tf.Line(start), tf.Line(end)
+	for _, s := range decl.Specs {
+		if s != spec && (tf.Line(s.End()) == first || tf.Line(s.Pos()) == last) {
+			return analysis.TextEdit{Pos: spec.Pos(), End: spec.End()}
+		}
+	}
+	if first == tf.Line(decl.Lparen) || last == tf.Line(decl.Rparen) || last == tf.LineCount() {
+		return analysis.TextEdit{Pos: spec.Pos(), End: spec.End()}
+	}
+	return analysis.TextEdit{Pos: tf.LineStart(first), End: tf.LineStart(last + 1)}
+}
+
+// isUniverse reports whether name refers to the predeclared object
+// of that name at pos.
+func isUniverse(pkg *types.Package, pos token.Pos, name string) bool {
+	scope := pkg.Scope().Innermost(pos)
+	if scope == nil {
+		return false
+	}
+	_, obj := scope.LookupParent(name, pos)
+	return obj != nil && obj == types.Universe.Lookup(name)
+}
+
+// isFree reports whether name refers to nothing at pos.
+func isFree(pkg *types.Package, pos token.Pos, name string) bool {
+	scope := pkg.Scope().Innermost(pos)
+	if scope == nil {
+		return false
+	}
+	_, obj := scope.LookupParent(name, pos)
+	return obj == nil
+}
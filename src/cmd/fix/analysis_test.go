@@ -0,0 +1,103 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+	"testing"
+
+	"cmd/internal/analysisfix"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+type analysisTestCase struct {
+	Name    string
+	Version string // Go version of the package; "" means the latest
+	In      string
+	Out     string // "" means In, unchanged
+}
+
+// testAnalyzer checks that applying the fixes suggested by a to each
+// test input yields the output, and that the output has nothing left
+// to fix.
+func testAnalyzer(t *testing.T, a *analysis.Analyzer, tests []analysisTestCase) {
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "source", nil)
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			out, fixed := applyAnalyzer(t, fset, imp, a, tt.Version, tt.In)
+			want := tt.Out
+			if want == "" {
+				want = tt.In
+			}
+			if out != want {
+				t.Errorf("incorrect output.\n--- have\n%s\n--- want\n%s", out, want)
+				tdiff(t, out, want)
+				return
+			}
+			if fixed != (out != tt.In) {
+				t.Errorf("changed=%v != fixed=%v", out != tt.In, fixed)
+			}
+			if _, fixed := applyAnalyzer(t, fset, imp, a, tt.Version, out); fixed {
+				t.Errorf("suggested fixes during second round")
+			}
+		})
+	}
+}
+
+// applyAnalyzer type-checks src, applies the fixes suggested by a
+// and returns the formatted result.
+func applyAnalyzer(t *testing.T, fset *token.FileSet, imp types.Importer, a *analysis.Analyzer, version, src string) (string, bool) {
+	f, err := parser.ParseFile(fset, "test.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &types.Config{Importer: imp, GoVersion: version}
+	info := analysisfix.NewInfo()
+	pkg, err := conf.Check("test", fset, []*ast.File{f}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pass := &analysis.Pass{Fset: fset, Files: []*ast.File{f}, Pkg: pkg, TypesInfo: info}
+	fixes, err := analysisfix.Fixes(pass, version, []*analysis.Analyzer{a})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Apply the edits, each distinct edit once.
+	seen := make(map[analysisfix.Edit]bool)
+	var edits []analysisfix.Edit
+	for _, fix := range fixes {
+		for _, e := range fix.Edits {
+			if !seen[e] {
+				seen[e] = true
+				edits = append(edits, e)
+			}
+		}
+	}
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].Offset < edits[j].Offset })
+	out := ""
+	last := 0
+	for _, e := range edits {
+		if e.Offset < last {
+			t.Fatalf("overlapping edits: %+v", edits)
+		}
+		out += src[last:e.Offset] + e.New
+		last = e.End
+	}
+	out += src[last:]
+	b, err := format.Source([]byte(out))
+	if err != nil {
+		t.Fatalf("fixed source does not parse: %v\n%s", err, out)
+	}
+	return string(b), len(fixes) > 0
+}
@@ -32,5 +32,11 @@ to see them, run go tool fix -help.
 Fix does not make backup copies of the files that it edits.
 Instead, use a version control system's ``diff'' functionality to inspect
 the changes that fix makes before committing them.
+
+Fix also contains analyzers that rewrite code to use newer idioms,
+such as efaceany, which replaces interface{} by any. They are not run
+by go tool fix, but by 'go fix', which runs them on type-checked
+packages and applies the fixes they suggest. To list them, run
+go tool fix help.
 */
 package main
@@ -0,0 +1,62 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+
+	"cmd/internal/analysisfix"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+var efaceanyAnalyzer = &analysis.Analyzer{
+	Name: "efaceany",
+	Doc: `replace interface{} by any
+
+The efaceany analyzer replaces the empty interface type interface{}
+by the predeclared alias any, available since Go 1.18.`,
+	Requires: []*analysis.Analyzer{inspect.Analyzer, analysisfix.GoVersion},
+	Run:      efaceany,
+}
+
+func efaceany(pass *analysis.Pass) (any, error) {
+	if !goVersionAtLeast(pass, 18) {
+		return nil, nil
+	}
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	inspect.Preorder([]ast.Node{(*ast.InterfaceType)(nil)}, func(n ast.Node) {
+		it := n.(*ast.InterfaceType)
+		if len(it.Methods.List) > 0 || hasComments(pass, it) || !isUniverse(pass.Pkg, it.Pos(), "any") {
+			return
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:     it.Pos(),
+			End:     it.End(),
+			Message: "interface{} can be replaced by any",
+			SuggestedFixes: []analysis.SuggestedFix{{
+				Message:   "Replace interface{} by any",
+				TextEdits: []analysis.TextEdit{{Pos: it.Pos(), End: it.End(), NewText: []byte("any")}},
+			}},
+		})
+	})
+	return nil, nil
+}
+
+// hasComments reports whether there are comments within n.
+func hasComments(pass *analysis.Pass, n ast.Node) bool {
+	f := fileOf(pass, n.Pos())
+	if f == nil {
+		return true
+	}
+	for _, g := range f.Comments {
+		if n.Pos() <= g.Pos() && g.End() <= n.End() {
+			return true
+		}
+	}
+	return false
+}
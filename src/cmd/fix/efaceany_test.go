@@ -0,0 +1,83 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestEfaceany(t *testing.T) {
+	testAnalyzer(t, efaceanyAnalyzer, efaceanyTests)
+}
+
+var efaceanyTests = []analysisTestCase{
+	{
+		Name: "efaceany.0",
+		In: `package p
+
+type T[P interface{}] struct {
+	m map[string]interface{}
+}
+
+func f(x interface{}, args ...interface{}) interface{} {
+	var _ interface{ M() }
+	var _ interface {
+		// comment
+	}
+	return func() interface{} { return x }
+}
+`,
+		Out: `package p
+
+type T[P any] struct {
+	m map[string]any
+}
+
+func f(x any, args ...any) any {
+	var _ interface{ M() }
+	var _ interface {
+		// comment
+	}
+	return func() any { return x }
+}
+`,
+	},
+	{
+		Name: "efaceany.1",
+		In: `package p
+
+type any int
+
+var x interface{}
+`,
+	},
+	{
+		Name: "efaceany.2",
+		In: `package p
+
+func f(any int) {
+	var x interface{} = any
+	_ = x
+}
+
+var y interface{}
+`,
+		Out: `package p
+
+func f(any int) {
+	var x interface{} = any
+	_ = x
+}
+
+var y any
+`,
+	},
+	{
+		Name:    "efaceany.3",
+		Version: "go1.17",
+		In: `package p
+
+var x interface{}
+`,
+	},
+}
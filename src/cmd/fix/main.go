@@ -21,7 +21,9 @@ import (
 	"strconv"
 	"strings"
 
+	"cmd/internal/analysisfix"
 	"cmd/internal/diff"
+	"cmd/internal/objabi"
 )
 
 var (
@@ -66,6 +68,11 @@ func usage() {
 }
 
 func main() {
+	objabi.AddVersionFlag()
+	if unitMode(os.Args[1:]) {
+		analysisfix.Main(analyzers...)
+	}
+
 	flag.Usage = usage
 	flag.Parse()
 
@@ -0,0 +1,211 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+
+	"cmd/internal/analysisfix"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+var sortsliceAnalyzer = &analysis.Analyzer{
+	Name: "sortslice",
+	Doc: `replace sort.Slice by slices.Sort
+
+The sortslice analyzer replaces calls of the form
+
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+
+where s is a slice of integers or strings, by the equivalent call of
+the generic function
+
+	slices.Sort(s)
+
+Slices of floating-point numbers are left alone, as slices.Sort
+orders NaNs differently.`,
+	Requires: []*analysis.Analyzer{inspect.Analyzer, analysisfix.GoVersion},
+	Run:      sortslice,
+}
+
+func sortslice(pass *analysis.Pass) (any, error) {
+	if !goVersionAtLeast(pass, 18) {
+		return nil, nil
+	}
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	// Find the calls to rewrite, by file.
+	calls := make(map[*ast.File][]*ast.CallExpr)
+	inspect.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		if isSortSliceOrdered(pass.TypesInfo, call) {
+			if f := fileOf(pass, call.Pos()); f != nil {
+				calls[f] = append(calls[f], call)
+			}
+		}
+	})
+
+	for _, f := range pass.Files {
+		name := "slices"
+		slicesSpec := importSpecOf(f, "slices")
+		if slicesSpec != nil && slicesSpec.Name != nil {
+			name = slicesSpec.Name.Name
+		}
+		if name == "_" || name == "." {
+			continue
+		}
+		var fcalls []*ast.CallExpr
+		for _, call := range calls[f] {
+			// An added import of slices must not shadow anything.
+			if slicesSpec != nil || isFree(pass.Pkg, call.Pos(), name) {
+				fcalls = append(fcalls, call)
+			}
+		}
+		if len(fcalls) == 0 {
+			continue
+		}
+
+		// If the calls are the only uses of package sort,
+		// the import of sort goes away.
+		sortSpec := importSpecOf(f, "sort")
+		sortGone := sortSpec != nil && countUses(pass.TypesInfo, f, "sort") == len(fcalls)
+		var importEdits []analysis.TextEdit
+		switch {
+		case slicesSpec != nil:
+			if sortGone {
+				importEdits = append(importEdits, deleteImportEdit(pass.Fset, f, sortSpec))
+			}
+		case sortGone && sortSpec.Name == nil:
+			importEdits = append(importEdits, analysis.TextEdit{Pos: sortSpec.Path.Pos(), End: sortSpec.Path.End(), NewText: []byte(`"slices"`)})
+		default:
+			importEdits = append(importEdits, addImportEdit(f, "slices"))
+			if sortGone {
+				importEdits = append(importEdits, deleteImportEdit(pass.Fset, f, sortSpec))
+			}
+		}
+
+		for _, call := range fcalls {
+			var buf bytes.Buffer
+			buf.WriteString(name + ".Sort(")
+			if err := format.Node(&buf, pass.Fset, call.Args[0]); err != nil {
+				return nil, err
+			}
+			buf.WriteString(")")
+			edits := append([]analysis.TextEdit{{Pos: call.Pos(), End: call.End(), NewText: buf.Bytes()}}, importEdits...)
+			pass.Report(analysis.Diagnostic{
+				Pos:     call.Pos(),
+				End:     call.End(),
+				Message: "sort.Slice can be replaced by slices.Sort",
+				SuggestedFixes: []analysis.SuggestedFix{{
+					Message:   "Replace sort.Slice by slices.Sort",
+					TextEdits: edits,
+				}},
+			})
+		}
+	}
+	return nil, nil
+}
+
+// isSortSliceOrdered reports whether call has the form
+//
+//	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+//
+// with s a variable, or a field selected from one, whose type is a
+// slice of integers or strings.
+func isSortSliceOrdered(info *types.Info, call *ast.CallExpr) bool {
+	fn, ok := typeutil.Callee(info, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != "sort" || fn.Name() != "Slice" {
+		return false
+	}
+	if _, ok := call.Fun.(*ast.SelectorExpr); !ok || len(call.Args) != 2 || call.Ellipsis.IsValid() {
+		return false
+	}
+	s := call.Args[0]
+	slice, ok := info.TypeOf(s).Underlying().(*types.Slice)
+	if !ok {
+		return false
+	}
+	elem, ok := slice.Elem().Underlying().(*types.Basic)
+	if !ok || elem.Info()&(types.IsInteger|types.IsString) == 0 {
+		return false
+	}
+
+	lit, ok := call.Args[1].(*ast.FuncLit)
+	if !ok || len(lit.Body.List) != 1 {
+		return false
+	}
+	var params []*ast.Ident
+	for _, field := range lit.Type.Params.List {
+		params = append(params, field.Names...)
+	}
+	if len(params) != 2 {
+		return false
+	}
+	ret, ok := lit.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return false
+	}
+	cmp, ok := ret.Results[0].(*ast.BinaryExpr)
+	if !ok || cmp.Op != token.LSS {
+		return false
+	}
+	isIndex := func(e ast.Expr, param *ast.Ident) bool {
+		ix, ok := e.(*ast.IndexExpr)
+		if !ok || !sameVar(info, ix.X, s) {
+			return false
+		}
+		id, ok := ix.Index.(*ast.Ident)
+		return ok && info.Uses[id] != nil && info.Uses[id] == info.Defs[param]
+	}
+	return isIndex(cmp.X, params[0]) && isIndex(cmp.Y, params[1])
+}
+
+// sameVar reports whether x and y are the same variable, or the same
+// field selected from the same variable.
+func sameVar(info *types.Info, x, y ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.Ident:
+		y, ok := y.(*ast.Ident)
+		if !ok {
+			return false
+		}
+		v, ok := info.Uses[x].(*types.Var)
+		return ok && v == info.Uses[y]
+	case *ast.SelectorExpr:
+		y, ok := y.(*ast.SelectorExpr)
+		if !ok {
+			return false
+		}
+		sel := info.Selections[x]
+		return sel != nil && sel.Kind() == types.FieldVal &&
+			info.Uses[x.Sel] == info.Uses[y.Sel] && sameVar(info, x.X, y.X)
+	case *ast.ParenExpr:
+		return sameVar(info, x.X, y)
+	}
+	return false
+}
+
+// countUses returns the number of references in f to the package
+// imported from path.
+func countUses(info *types.Info, f *ast.File, path string) int {
+	n := 0
+	ast.Inspect(f, func(node ast.Node) bool {
+		if id, ok := node.(*ast.Ident); ok {
+			if pkg, ok := info.Uses[id].(*types.PkgName); ok && pkg.Imported().Path() == path {
+				n++
+			}
+		}
+		return true
+	})
+	return n
+}
@@ -0,0 +1,155 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestSortslice(t *testing.T) {
+	testAnalyzer(t, sortsliceAnalyzer, sortsliceTests)
+}
+
+var sortsliceTests = []analysisTestCase{
+	{
+		Name: "sortslice.0",
+		In: `package p
+
+import (
+	"fmt"
+	"sort"
+)
+
+type T struct{ names []string }
+
+func f(s []int, t *T) {
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	sort.Slice(t.names, func(a, b int) bool {
+		return t.names[a] < t.names[b]
+	})
+	fmt.Println(s)
+}
+`,
+		Out: `package p
+
+import (
+	"fmt"
+	"slices"
+)
+
+type T struct{ names []string }
+
+func f(s []int, t *T) {
+	slices.Sort(s)
+	slices.Sort(t.names)
+	fmt.Println(s)
+}
+`,
+	},
+	{
+		Name: "sortslice.1",
+		In: `package p
+
+import (
+	"fmt"
+	"sort"
+)
+
+func f(s, t []string, f []float64) {
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	sort.Slice(s, func(i, j int) bool { return s[j] < s[i] })
+	sort.Slice(s, func(i, j int) bool { return t[i] < t[j] })
+	sort.Slice(s, func(i, j int) bool { return s[i] > s[j] })
+	sort.Slice(f, func(i, j int) bool { return f[i] < f[j] })
+	sort.SliceStable(s, func(i, j int) bool { return s[i] < s[j] })
+	fmt.Println(s)
+}
+`,
+		Out: `package p
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+func f(s, t []string, f []float64) {
+	slices.Sort(s)
+	sort.Slice(s, func(i, j int) bool { return s[j] < s[i] })
+	sort.Slice(s, func(i, j int) bool { return t[i] < t[j] })
+	sort.Slice(s, func(i, j int) bool { return s[i] > s[j] })
+	sort.Slice(f, func(i, j int) bool { return f[i] < f[j] })
+	sort.SliceStable(s, func(i, j int) bool { return s[i] < s[j] })
+	fmt.Println(s)
+}
+`,
+	},
+	{
+		Name: "sortslice.2",
+		In: `package p
+
+import "sort"
+
+func f(s []int) {
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+}
+`,
+		Out: `package p
+
+import "slices"
+
+func f(s []int) {
+	slices.Sort(s)
+}
+`,
+	},
+	{
+		Name: "sortslice.3",
+		In: `package p
+
+import (
+	"slices"
+	"sort"
+)
+
+func f(s []int) {
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	_ = slices.Contains(s, 1)
+}
+`,
+		Out: `package p
+
+import (
+	"slices"
+)
+
+func f(s []int) {
+	slices.Sort(s)
+	_ = slices.Contains(s, 1)
+}
+`,
+	},
+	{
+		Name: "sortslice.4",
+		In: `package p
+
+import "sort"
+
+func f(s []int, slices int) {
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+}
+`,
+	},
+	{
+		Name:    "sortslice.5",
+		Version: "go1.17",
+		In: `package p
+
+import "sort"
+
+func f(s []int) {
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+}
+`,
+	},
+}
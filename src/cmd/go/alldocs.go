@@ -20,6 +20,7 @@
 // 	env         print Go environment information
 // 	fix         update packages to use new APIs
 // 	fmt         gofmt (reformat) package sources
+// 	fuzzcache   fuzzing cache maintenance
 // 	generate    generate Go files by processing source
 // 	get         add dependencies to current module and install them
 // 	install     compile and install packages and dependencies
@@ -54,6 +55,7 @@
 // 	private         configuration for downloading non-public code
 // 	testflag        testing flags
 // 	testfunc        testing functions
+// 	toolchain       toolchain selection and GOTOOLCHAIN
 // 	vcs             controlling version control with GOVCS
 //
 // Use "go help <topic>" for more information about that topic.
@@ -125,6 +127,30 @@
 // 	-asan
 // 		enable interoperation with address sanitizer.
 // 		Supported only on linux/arm64, linux/amd64.
+// 	-cover
+// 		enable code coverage instrumentation. When the resulting
+// 		program exits, it writes coverage data files into the
+// 		directory named by the GOCOVERDIR environment variable.
+// 		Use 'go tool covdata' to process those files.
+// 		Supported only by the build, install and run commands;
+// 		for coverage of tests, see 'go help testflag'.
+// 	-covermode set,count,atomic
+// 		set the mode for coverage analysis.
+// 		The default is "set" unless -race is enabled,
+// 		in which case it is "atomic".
+// 		The values:
+// 		set: bool: does this statement run?
+// 		count: int: how many times does this statement run?
+// 		atomic: int: count, but correct in multithreaded programs;
+// 			significantly more expensive.
+// 		Sets -cover.
+// 	-coverpkg pattern1,pattern2,pattern3
+// 		apply coverage analysis to each package matching the patterns.
+// 		The default is to apply coverage analysis to the packages named
+// 		on the command line and to the other packages in the main module.
+// 		Standard library packages are never instrumented.
+// 		See 'go help packages' for a description of package patterns.
+// 		Sets -cover.
 // 	-v
 // 		print the names of packages as they are compiled.
 // 	-work
@@ -188,6 +214,14 @@
 // 		include path must be in the same directory as the Go package they are
 // 		included from, and overlays will not appear when binaries and tests are
 // 		run through go run and go test respectively.
+// 	-pgo file
+// 		specify the file path of a profile for profile-guided optimization (PGO).
+// 		The profile is a CPU profile in pprof format, such as one written by
+// 		runtime/pprof. The main package and all its dependencies are compiled
+// 		using the profile. The special name "auto" (the default) selects the
+// 		file "default.pgo" in the main package's directory, if that exists and
+// 		the build has a single main package. The special name "off" turns
+// 		off PGO.
 // 	-pkgdir dir
 // 		install and load all packages from dir instead of the usual locations.
 // 		For example, when building with a non-standard configuration,
@@ -298,7 +332,8 @@
 // code coverage, so removing them may make fuzzing less effective until
 // new inputs are found that provide the same coverage. These files are
 // distinct from those stored in testdata directory; clean does not remove
-// those files.
+// those files. To keep the cached files of one machine for use
+// on another, see 'go help fuzzcache'.
 //
 // For more about build flags, see 'go help build'.
 //
@@ -453,18 +488,35 @@
 //
 // Usage:
 //
-// 	go fix [-fix list] [packages]
+// 	go fix [-fix list] [-diff] [build flags] [packages]
 //
-// Fix runs the Go fix command on the packages named by the import paths.
+// Fix runs the analyzers of the Go fix command on the packages named by
+// the import paths, and applies the fixes they suggest, updating the
+// packages to use newer APIs and idioms. For example, the efaceany
+// analyzer replaces interface{} by any. Fixes that depend on a newer
+// Go version than that of the module containing a package are not made.
 //
-// The -fix flag sets a comma-separated list of fixes to run.
-// The default is all known fixes.
-// (Its value is passed to 'go tool fix -r'.)
+// The -fix flag sets a comma-separated list of analyzers to run.
+// The default is all known analyzers.
+// For a list of analyzers, see 'go tool fix help'.
+//
+// The -diff flag causes fix to print the changes that the fixes would
+// make as a unified diff instead of applying them.
+//
+// Fixes are applied as 'go vet -fix' applies them: the fixes of all
+// the packages are applied together, and if any package cannot be
+// analyzed, no file is changed. Fixed files are reformatted with gofmt.
+// Only packages in the main module are fixed.
+//
+// The build flags supported by go fix are those that control package
+// resolution and execution, such as -n, -x, -v, -tags, and -toolexec.
+// For more about these flags, see 'go help build'.
 //
 // For more about fix, see 'go doc cmd/fix'.
 // For more about specifying packages, see 'go help packages'.
 //
-// To run fix with other options, run 'go tool fix'.
+// The older syntactic rewrites of the fix command are not run by go fix;
+// to run them, run 'go tool fix'.
 //
 // See also: go fmt, go vet.
 //
@@ -492,6 +544,70 @@
 // See also: go fix, go vet.
 //
 //
+// Fuzzing cache maintenance
+//
+// Go fuzzcache provides access to the values cached by
+// 'go test -fuzz', so that they can be shared between machines.
+//
+// When fuzzing, the go command caches the values that expanded code
+// coverage when passed to a fuzz function, and uses them as a starting
+// point the next time the fuzz test runs. The values are stored in the
+// fuzz subdirectory of the build cache, the directory that
+// 'go clean -fuzzcache' removes. See 'go help cache' for more.
+//
+// The fuzzing cache of one machine can be exported to an archive
+// with 'go fuzzcache export' and merged into the fuzzing cache of
+// another machine with 'go fuzzcache import'.
+//
+// Usage:
+//
+// 	go fuzzcache <command> [arguments]
+//
+// The commands are:
+//
+// 	export      write cached fuzzing values to an archive
+// 	import      merge cached fuzzing values from archives
+//
+// Use "go help fuzzcache <command>" for more information about a command.
+//
+// Write cached fuzzing values to an archive
+//
+// Usage:
+//
+// 	go fuzzcache export [-o file] [packages]
+//
+// Export writes the values cached by 'go test -fuzz' to a zip archive,
+// which 'go fuzzcache import' can merge into another fuzzing cache.
+//
+// The packages are import paths or import path patterns, such as
+// example.com/m/..., and select the packages whose cached values are
+// exported. With no arguments, export writes the whole fuzzing cache.
+// Relative paths such as ./... are not supported, as the fuzzing cache
+// records only the import paths of packages.
+//
+// The -o flag writes the archive to the named file instead of the
+// standard output.
+//
+// Each file in the archive holds one value, and is named
+// importpath/FuzzTest/hash, as in the fuzzing cache.
+//
+//
+// Merge cached fuzzing values from archives
+//
+// Usage:
+//
+// 	go fuzzcache import [file...]
+//
+// Import merges the values in the named archives, written by
+// 'go fuzzcache export', into the fuzzing cache.
+//
+// Values already in the fuzzing cache are left as they are. Before
+// changing the cache, import checks that each file in the archives is
+// a value encoded by 'go test -fuzz' and named by its hash, and that it
+// belongs to a fuzz test of a package with a valid import path.
+// If any file is not, import reports the error and changes nothing.
+//
+//
 // Generate Go files by processing source
 //
 // Usage:
@@ -1150,6 +1266,10 @@
 //
 // The -go=version flag sets the expected Go language version.
 //
+// The -toolchain=name flag sets the Go toolchain to use.
+// The -toolchain=none flag drops the toolchain line.
+// See 'go help toolchain'.
+//
 // The -print flag prints the final go.mod in its text format instead of
 // writing it back to go.mod.
 //
@@ -1162,12 +1282,13 @@
 // 	}
 //
 // 	type GoMod struct {
-// 		Module  ModPath
-// 		Go      string
-// 		Require []Require
-// 		Exclude []Module
-// 		Replace []Replace
-// 		Retract []Retract
+// 		Module    ModPath
+// 		Go        string
+// 		Toolchain string
+// 		Require   []Require
+// 		Exclude   []Module
+// 		Replace   []Replace
+// 		Retract   []Retract
 // 	}
 //
 // 	type ModPath struct {
@@ -1401,7 +1522,11 @@
 // The go directive specifies the version of Go the file was written at. It
 // is possible there may be future changes in the semantics of workspaces
 // that could be controlled by this version, but for now the version
-// specified has no effect.
+// specified has no effect, except that the go command may run a newer
+// Go toolchain if the version is newer than its own.
+//
+// The toolchain directive names the Go toolchain to use in the workspace.
+// See 'go help toolchain'.
 //
 // The replace directive has the same syntax as the replace directive in a
 // go.mod file and takes precedence over replaces in go.mod files.  It is
@@ -1464,6 +1589,10 @@
 //
 // The -go=version flag sets the expected Go language version.
 //
+// The -toolchain=name flag sets the Go toolchain to use.
+// The -toolchain=none flag drops the toolchain line.
+// See 'go help toolchain'.
+//
 // The -print flag prints the final go.work in its text format instead of
 // writing it back to go.mod.
 //
@@ -1471,9 +1600,10 @@
 // writing it back to go.mod. The JSON output corresponds to these Go types:
 //
 // 	type GoWork struct {
-// 		Go      string
-// 		Use     []Use
-// 		Replace []Replace
+// 		Go        string
+// 		Toolchain string
+// 		Use       []Use
+// 		Replace   []Replace
 // 	}
 //
 // 	type Use struct {
@@ -1717,6 +1847,8 @@
 //
 // 	-json
 // 	    Convert test output to JSON suitable for automated processing.
+// 	    When fuzzing, also report fuzzing progress, such as new inputs
+// 	    and crashers, as events with the "fuzz" action.
 // 	    See 'go doc test2json' for the encoding details.
 //
 // 	-o file
@@ -1778,7 +1910,7 @@
 //
 // Usage:
 //
-// 	go vet [-n] [-x] [-vettool prog] [build flags] [vet flags] [packages]
+// 	go vet [-n] [-x] [-vettool prog] [-fix] [-diff] [build flags] [vet flags] [packages]
 //
 // Vet runs the Go vet command on the packages named by the import paths.
 //
@@ -1797,6 +1929,18 @@
 //   go install golang.org/x/tools/go/analysis/passes/shadow/cmd/shadow
 //   go vet -vettool=$(which shadow)
 //
+// The -fix flag causes vet to apply the suggested fixes of its checkers
+// to the source files of the packages, instead of reporting problems.
+// Checkers depending on information about other packages, such as
+// 'printf', are not run with -fix. The fixes of all the packages are
+// applied together: if any package cannot be analyzed, no file is
+// changed. Fixes conflicting with other fixes are skipped; run the
+// command again to apply them. Fixed files are reformatted with gofmt.
+// Only packages in the main module are fixed.
+//
+// The -diff flag is like -fix, but prints the changes that the fixes
+// would make as a unified diff instead of applying them.
+//
 // The build flags supported by go vet are those that control package resolution
 // and execution, such as -n, -x, -v, -tags, and -toolexec.
 // For more about these flags, see 'go help build'.
@@ -1988,6 +2132,8 @@
 // testing, but they're stored in a subdirectory of the build cache.
 // Running 'go clean -fuzzcache' removes all cached fuzzing values.
 // This may make fuzzing less effective, temporarily.
+// The cached fuzzing values can be shared between machines with
+// 'go fuzzcache export' and 'go fuzzcache import'.
 //
 // The GODEBUG environment variable can enable printing of debugging
 // information about the state of the cache:
@@ -2078,6 +2224,9 @@
 // 	GOTMPDIR
 // 		The directory where the go command will write
 // 		temporary source files, packages, and binaries.
+// 	GOTOOLCHAIN
+// 		Controls which Go toolchain runs the go command: the local one,
+// 		or one required by the main module or workspace. See 'go help toolchain'.
 // 	GOVCS
 // 		Lists version control commands that may be used with matching servers.
 // 		See 'go help vcs'.
@@ -2160,6 +2309,9 @@
 // 	GCCGOTOOLDIR
 // 		If set, where to find gccgo tools, such as cgo.
 // 		The default is based on how gccgo was configured.
+// 	GOCOVERDIR
+// 		Directory into which to write code coverage data files
+// 		when running a program built with 'go build -cover'.
 // 	GOEXPERIMENT
 // 		Comma-separated list of toolchain experiments to enable or disable.
 // 		The list of available experiments may change arbitrarily over time.
@@ -2252,6 +2404,9 @@
 // To create a new go.mod file, use 'go mod init'. For details see
 // 'go help mod init' or https://golang.org/ref/mod#go-mod-init.
 //
+// The go and toolchain lines of the go.mod file of the main module
+// control which Go toolchain runs the go command; see 'go help toolchain'.
+//
 // To add missing module requirements or remove unneeded requirements,
 // use 'go mod tidy'. For details, see 'go help mod tidy' or
 // https://golang.org/ref/mod#go-mod-tidy.
@@ -3188,6 +3343,56 @@
 // See the documentation of the testing package for more information.
 //
 //
+// Toolchain selection and GOTOOLCHAIN
+//
+// The go line of a go.mod or go.work file declares the version of Go that
+// the module or workspace requires. The toolchain line, which is optional,
+// names the Go toolchain that should be used to work in the module or
+// workspace, such as
+//
+// 	toolchain go1.18.3
+//
+// When the main module or workspace requires a newer version of Go than
+// that of the go command being run, the go command can download a newer
+// toolchain and run it instead. The GOTOOLCHAIN environment variable
+// controls this behavior. It can be set to:
+//
+// 	local
+// 		Always run the local toolchain, the one that was invoked.
+// 	auto
+// 		Run the local toolchain, unless the main module or workspace
+// 		requires a newer one. This is the default.
+// 	<name>, such as go1.18.3
+// 		Always run the named toolchain, downloading it if it
+// 		is not the local toolchain.
+// 	<name>+auto
+// 		Run the named toolchain, unless the main module or
+// 		workspace requires a newer one.
+//
+// The toolchain required by the main module or workspace is the newest
+// of the one named by its toolchain line and the first release of the
+// Go version of its go line: for 'go 1.19', that is go1.19. When the
+// go.work file of a workspace is in use, its go and toolchain lines are
+// used, and those of the go.mod files of its modules are not. The name
+// 'default' in a toolchain line states no requirement beyond the go line.
+//
+// Toolchains are downloaded as the module golang.org/toolchain, at
+// versions such as v0.0.1-go1.18.3.linux-amd64, through the module proxy
+// and into the module cache, like other modules. As for other modules,
+// the go command verifies the checksum of each downloaded toolchain
+// against the checksum database configured by GOSUMDB; see
+// 'go help module-auth'. Unlike other modules, toolchains are never
+// downloaded without that verification: if GOSUMDB is off, or if
+// GONOSUMDB or GOPRIVATE matches golang.org/toolchain, the go command
+// refuses to run a toolchain other than the local one.
+//
+// The downloaded toolchain is run with GOTOOLCHAIN set to local,
+// so that it does not select another toolchain in turn.
+//
+// GOTOOLCHAIN can be set with 'go env -w'. The 'go env -w' and
+// 'go env -u' commands always run the local toolchain.
+//
+//
 // Controlling version control with GOVCS
 //
 // The 'go get' command can run version control commands like git
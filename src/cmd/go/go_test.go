@@ -41,6 +41,13 @@ func init() {
 	// Change to fully permissive.
 	// The tests of the GOVCS setting itself are in ../../testdata/script/govcs.txt.
 	os.Setenv("GOVCS", "*:all")
+
+	// GOTOOLCHAIN defaults to auto, which would make the go command
+	// try to download newer toolchains for test modules declaring
+	// future Go versions. Run the go command being tested instead.
+	// The tests of the GOTOOLCHAIN setting itself are in
+	// testdata/script/gotoolchain.txt.
+	os.Setenv("GOTOOLCHAIN", "local")
 }
 
 var (
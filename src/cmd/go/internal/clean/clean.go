@@ -80,7 +80,8 @@ cache for fuzz testing. The fuzzing engine caches files that expand
 code coverage, so removing them may make fuzzing less effective until
 new inputs are found that provide the same coverage. These files are
 distinct from those stored in testdata directory; clean does not remove
-those files.
+those files. To keep the cached files of one machine for use
+on another, see 'go help fuzzcache'.
 
 For more about build flags, see 'go help build'.
 
@@ -7,37 +7,53 @@ package fix
 
 import (
 	"cmd/go/internal/base"
-	"cmd/go/internal/cfg"
-	"cmd/go/internal/load"
 	"cmd/go/internal/modload"
-	"cmd/go/internal/str"
+	"cmd/go/internal/vet"
 	"cmd/go/internal/work"
 	"context"
-	"fmt"
-	"go/build"
-	"os"
+	"strings"
 )
 
 var CmdFix = &base.Command{
-	UsageLine: "go fix [-fix list] [packages]",
+	UsageLine: "go fix [-fix list] [-diff] [build flags] [packages]",
 	Short:     "update packages to use new APIs",
 	Long: `
-Fix runs the Go fix command on the packages named by the import paths.
+Fix runs the analyzers of the Go fix command on the packages named by
+the import paths, and applies the fixes they suggest, updating the
+packages to use newer APIs and idioms. For example, the efaceany
+analyzer replaces interface{} by any. Fixes that depend on a newer
+Go version than that of the module containing a package are not made.
 
-The -fix flag sets a comma-separated list of fixes to run.
-The default is all known fixes.
-(Its value is passed to 'go tool fix -r'.)
+The -fix flag sets a comma-separated list of analyzers to run.
+The default is all known analyzers.
+For a list of analyzers, see 'go tool fix help'.
+
+The -diff flag causes fix to print the changes that the fixes would
+make as a unified diff instead of applying them.
+
+Fixes are applied as 'go vet -fix' applies them: the fixes of all
+the packages are applied together, and if any package cannot be
+analyzed, no file is changed. Fixed files are reformatted with gofmt.
+Only packages in the main module are fixed.
+
+The build flags supported by go fix are those that control package
+resolution and execution, such as -n, -x, -v, -tags, and -toolexec.
+For more about these flags, see 'go help build'.
 
 For more about fix, see 'go doc cmd/fix'.
 For more about specifying packages, see 'go help packages'.
 
-To run fix with other options, run 'go tool fix'.
+The older syntactic rewrites of the fix command are not run by go fix;
+to run them, run 'go tool fix'.
 
 See also: go fmt, go vet.
 	`,
 }
 
-var fixes = CmdFix.Flag.String("fix", "", "comma-separated list of fixes to apply")
+var (
+	fixes   = CmdFix.Flag.String("fix", "", "comma-separated list of fixes to apply")
+	fixDiff = CmdFix.Flag.Bool("diff", false, "print diffs instead of applying fixes")
+)
 
 func init() {
 	work.AddBuildFlags(CmdFix, work.DefaultBuildFlags)
@@ -45,41 +61,16 @@ func init() {
 }
 
 func runFix(ctx context.Context, cmd *base.Command, args []string) {
-	pkgs := load.PackagesAndErrors(ctx, load.PackageOpts{}, args)
-	w := 0
-	for _, pkg := range pkgs {
-		if pkg.Error != nil {
-			base.Errorf("%v", pkg.Error)
-			continue
-		}
-		pkgs[w] = pkg
-		w++
-	}
-	pkgs = pkgs[:w]
-
-	printed := false
-	for _, pkg := range pkgs {
-		if modload.Enabled() && pkg.Module != nil && !pkg.Module.Main {
-			if !printed {
-				fmt.Fprintf(os.Stderr, "go: not fixing packages in dependency modules\n")
-				printed = true
-			}
-			continue
-		}
-		// Use pkg.gofiles instead of pkg.Dir so that
-		// the command only applies to this package,
-		// not to packages in subdirectories.
-		files := base.RelPaths(pkg.InternalAllGoFiles())
-		goVersion := ""
-		if pkg.Module != nil {
-			goVersion = "go" + pkg.Module.GoVersion
-		} else if pkg.Standard {
-			goVersion = build.Default.ReleaseTags[len(build.Default.ReleaseTags)-1]
-		}
-		var fixArg []string
-		if *fixes != "" {
-			fixArg = []string{"-r=" + *fixes}
+	modload.InitWorkfile()
+	work.BuildInit()
+	work.VetTool = base.Tool("fix")
+	work.VetFix = true
+	if *fixes != "" {
+		// Run only the named analyzers.
+		for _, name := range strings.Split(*fixes, ",") {
+			work.VetFlags = append(work.VetFlags, "-"+name)
 		}
-		base.Run(str.StringList(cfg.BuildToolexec, base.Tool("fix"), "-go="+goVersion, fixArg, files))
+		work.VetExplicit = true
 	}
+	vet.Run(ctx, args, *fixDiff)
 }
@@ -0,0 +1,141 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// go fuzzcache export
+
+package fuzzcmd
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cmd/go/internal/base"
+	"cmd/go/internal/cache"
+	"cmd/go/internal/search"
+)
+
+var cmdExport = &base.Command{
+	UsageLine: "go fuzzcache export [-o file] [packages]",
+	Short:     "write cached fuzzing values to an archive",
+	Long: `
+Export writes the values cached by 'go test -fuzz' to a zip archive,
+which 'go fuzzcache import' can merge into another fuzzing cache.
+
+The packages are import paths or import path patterns, such as
+example.com/m/..., and select the packages whose cached values are
+exported. With no arguments, export writes the whole fuzzing cache.
+Relative paths such as ./... are not supported, as the fuzzing cache
+records only the import paths of packages.
+
+The -o flag writes the archive to the named file instead of the
+standard output.
+
+Each file in the archive holds one value, and is named
+importpath/FuzzTest/hash, as in the fuzzing cache.
+	`,
+}
+
+var exportO = cmdExport.Flag.String("o", "", "")
+
+func init() {
+	cmdExport.Run = runExport // break init cycle
+}
+
+func runExport(ctx context.Context, cmd *base.Command, args []string) {
+	var matches []func(string) bool
+	for _, arg := range args {
+		if search.IsRelativePath(arg) || filepath.IsAbs(arg) {
+			base.Fatalf("go: fuzzcache export: %s: packages must be import paths or patterns", arg)
+		}
+		matches = append(matches, search.MatchPattern(arg))
+	}
+	match := func(pkg string) bool {
+		if len(matches) == 0 {
+			return true
+		}
+		for _, m := range matches {
+			if m(pkg) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var w io.Writer = os.Stdout
+	var f *os.File
+	if *exportO != "" {
+		var err error
+		f, err = os.Create(*exportO)
+		if err != nil {
+			base.Fatalf("go: %v", err)
+		}
+		w = f
+	}
+
+	zw := zip.NewWriter(w)
+	dir := cache.Default().FuzzDir()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && os.IsNotExist(err) {
+				return nil // nothing cached yet
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		pkg, _, ok := splitEntry(name)
+		if !ok || !match(pkg) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+		if err != nil {
+			return err
+		}
+		_, err = fw.Write(data)
+		return err
+	})
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil && f != nil {
+		err = f.Close()
+	}
+	if err != nil {
+		if f != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+		base.Fatalf("go: fuzzcache export: %v", err)
+	}
+}
+
+// splitEntry splits the slash-separated name of a file in the fuzzing
+// cache, of the form importpath/FuzzTest/hash, into the import path
+// and the rest. It reports whether name has that form.
+func splitEntry(name string) (pkg, rest string, ok bool) {
+	i := strings.LastIndex(name, "/")
+	if i < 0 {
+		return "", "", false
+	}
+	j := strings.LastIndex(name[:i], "/")
+	if j < 0 {
+		return "", "", false
+	}
+	return name[:j], name[j+1:], true
+}
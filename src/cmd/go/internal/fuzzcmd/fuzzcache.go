@@ -0,0 +1,33 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package fuzzcmd implements the ``go fuzzcache'' command.
+package fuzzcmd
+
+import (
+	"cmd/go/internal/base"
+)
+
+var CmdFuzzcache = &base.Command{
+	UsageLine: "go fuzzcache",
+	Short:     "fuzzing cache maintenance",
+	Long: `Go fuzzcache provides access to the values cached by
+'go test -fuzz', so that they can be shared between machines.
+
+When fuzzing, the go command caches the values that expanded code
+coverage when passed to a fuzz function, and uses them as a starting
+point the next time the fuzz test runs. The values are stored in the
+fuzz subdirectory of the build cache, the directory that
+'go clean -fuzzcache' removes. See 'go help cache' for more.
+
+The fuzzing cache of one machine can be exported to an archive
+with 'go fuzzcache export' and merged into the fuzzing cache of
+another machine with 'go fuzzcache import'.
+	`,
+
+	Commands: []*base.Command{
+		cmdExport,
+		cmdImport,
+	},
+}
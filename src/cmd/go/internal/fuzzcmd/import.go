@@ -0,0 +1,147 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// go fuzzcache import
+
+package fuzzcmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"cmd/go/internal/base"
+	"cmd/go/internal/cache"
+
+	"golang.org/x/mod/module"
+)
+
+var cmdImport = &base.Command{
+	UsageLine: "go fuzzcache import [file...]",
+	Short:     "merge cached fuzzing values from archives",
+	Long: `
+Import merges the values in the named archives, written by
+'go fuzzcache export', into the fuzzing cache.
+
+Values already in the fuzzing cache are left as they are. Before
+changing the cache, import checks that each file in the archives is
+a value encoded by 'go test -fuzz' and named by its hash, and that it
+belongs to a fuzz test of a package with a valid import path.
+If any file is not, import reports the error and changes nothing.
+	`,
+	Run: runImport,
+}
+
+// maxValueSize is the size limit of an imported value.
+// Values found by fuzzing are far smaller.
+const maxValueSize = 100 << 20
+
+// fuzzHeader is the first line of a value encoded by 'go test -fuzz'.
+const fuzzHeader = "go test fuzz v1\n"
+
+func runImport(ctx context.Context, cmd *base.Command, args []string) {
+	if len(args) == 0 {
+		base.Fatalf("go: fuzzcache import: no archives listed")
+	}
+
+	type value struct {
+		name string // slash-separated name in the fuzzing cache
+		data []byte
+	}
+	var values []value
+	for _, file := range args {
+		zr, err := zip.OpenReader(file)
+		if err != nil {
+			base.Fatalf("go: fuzzcache import: %v", err)
+		}
+		for _, zf := range zr.File {
+			if strings.HasSuffix(zf.Name, "/") {
+				continue // directory
+			}
+			if err := checkEntry(zf.Name); err != nil {
+				base.Fatalf("go: fuzzcache import: %s: %s: %v", file, zf.Name, err)
+			}
+			data, err := readValue(zf)
+			if err != nil {
+				base.Fatalf("go: fuzzcache import: %s: %s: %v", file, zf.Name, err)
+			}
+			values = append(values, value{zf.Name, data})
+		}
+		zr.Close()
+	}
+
+	dir := cache.Default().FuzzDir()
+	for _, v := range values {
+		target := filepath.Join(dir, filepath.FromSlash(v.name))
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+			base.Fatalf("go: fuzzcache import: %v", err)
+		}
+		if err := os.WriteFile(target, v.data, 0666); err != nil {
+			os.Remove(target) // remove partially written file
+			base.Fatalf("go: fuzzcache import: %v", err)
+		}
+	}
+}
+
+// checkEntry checks that name, the name of a file in an archive,
+// is a valid name for a value in the fuzzing cache.
+func checkEntry(name string) error {
+	if path.Clean(name) != name || path.IsAbs(name) {
+		return fmt.Errorf("invalid file name")
+	}
+	pkg, rest, ok := splitEntry(name)
+	if !ok {
+		return fmt.Errorf("file name is not of the form importpath/FuzzTest/hash")
+	}
+	if err := module.CheckImportPath(pkg); err != nil {
+		return err
+	}
+	i := strings.Index(rest, "/")
+	test, hash := rest[:i], rest[i+1:]
+	if !token.IsIdentifier(test) || !strings.HasPrefix(test, "Fuzz") {
+		return fmt.Errorf("invalid fuzz test name %q", test)
+	}
+	if len(hash) != 2*sha256.Size || strings.Trim(hash, "0123456789abcdef") != "" {
+		return fmt.Errorf("file name does not end in a hash")
+	}
+	return nil
+}
+
+// readValue returns the contents of zf, checking that they are an
+// encoded value whose hash is the last element of zf's name.
+func readValue(zf *zip.File) ([]byte, error) {
+	if zf.UncompressedSize64 > maxValueSize {
+		return nil, fmt.Errorf("value too large")
+	}
+	r, err := zf.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, maxValueSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxValueSize {
+		return nil, fmt.Errorf("value too large")
+	}
+	if !bytes.HasPrefix(data, []byte(fuzzHeader)) {
+		return nil, fmt.Errorf("not a value encoded by 'go test -fuzz'")
+	}
+	if fmt.Sprintf("%x", sha256.Sum256(data)) != path.Base(zf.Name) {
+		return nil, fmt.Errorf("hash does not match contents")
+	}
+	return data, nil
+}
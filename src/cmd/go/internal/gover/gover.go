@@ -0,0 +1,188 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package gover implements support for Go release versions,
+// such as 1.18, 1.18.3, and 1.19rc1, and the names of the
+// toolchains implementing them, such as go1.18.3.
+package gover
+
+import (
+	"internal/goversion"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// A version is a parsed Go version: major.minor[.patch][kind pre],
+// where kind is "beta" or "rc". A missing patch number is treated
+// as zero, and a missing kind as a release.
+type version struct {
+	major, minor, patch string
+	kind, pre           string
+}
+
+// Compare returns -1, 0, or +1 depending on whether x < y, x == y,
+// or x > y, interpreted as Go versions. An invalid version is
+// considered less than all valid versions.
+func Compare(x, y string) int {
+	vx, okx := parse(x)
+	vy, oky := parse(y)
+	switch {
+	case !okx && !oky:
+		return 0
+	case !okx:
+		return -1
+	case !oky:
+		return +1
+	}
+	if c := cmpInt(vx.major, vy.major); c != 0 {
+		return c
+	}
+	if c := cmpInt(vx.minor, vy.minor); c != 0 {
+		return c
+	}
+	if c := cmpInt(vx.patch, vy.patch); c != 0 {
+		return c
+	}
+	if c := strings.Compare(kindOrder(vx.kind), kindOrder(vy.kind)); c != 0 {
+		return c
+	}
+	return cmpInt(vx.pre, vy.pre)
+}
+
+// Max returns the maximum of x and y interpreted as Go versions.
+func Max(x, y string) string {
+	if Compare(x, y) < 0 {
+		return y
+	}
+	return x
+}
+
+// IsValid reports whether x is a valid Go version.
+func IsValid(x string) bool {
+	_, ok := parse(x)
+	return ok
+}
+
+// FromToolchain returns the Go version implemented by the toolchain
+// with the given name: for go1.18.3 or go1.18.3-custom, it returns
+// 1.18.3. If name does not name a Go toolchain, FromToolchain
+// returns "".
+func FromToolchain(name string) string {
+	if !strings.HasPrefix(name, "go") {
+		return ""
+	}
+	v := name[len("go"):]
+	if i := strings.IndexAny(v, "- "); i >= 0 {
+		v = v[:i]
+	}
+	if !IsValid(v) {
+		return ""
+	}
+	return v
+}
+
+// TestVersion, if set, is the Go version reported by Local.
+// It is set by the testgo build of the go command.
+var TestVersion string
+
+// Local returns the Go version of the running toolchain. In a
+// development build, it returns the version under development,
+// such as 1.18.
+func Local() string {
+	name := runtime.Version()
+	if TestVersion != "" {
+		name = TestVersion
+	}
+	if v := FromToolchain(name); v != "" {
+		return v
+	}
+	return "1." + strconv.Itoa(goversion.Version)
+}
+
+// LocalToolchain returns the name of the running toolchain,
+// such as go1.18.
+func LocalToolchain() string {
+	return "go" + Local()
+}
+
+// parse parses the Go version x.
+func parse(x string) (v version, ok bool) {
+	v.major, x, ok = cutInt(x)
+	if !ok || x == "" || x[0] != '.' {
+		return version{}, false
+	}
+	v.minor, x, ok = cutInt(x[1:])
+	if !ok {
+		return version{}, false
+	}
+	if x != "" && x[0] == '.' {
+		v.patch, x, ok = cutInt(x[1:])
+		if !ok {
+			return version{}, false
+		}
+	}
+	switch {
+	case x == "":
+		return v, true
+	case v.patch != "":
+		// Prereleases such as 1.19rc1 precede the first release
+		// of a Go version, so they have no patch number.
+		return version{}, false
+	case strings.HasPrefix(x, "beta"):
+		v.kind, x = "beta", x[len("beta"):]
+	case strings.HasPrefix(x, "rc"):
+		v.kind, x = "rc", x[len("rc"):]
+	default:
+		return version{}, false
+	}
+	v.pre, x, ok = cutInt(x)
+	if !ok || x != "" {
+		return version{}, false
+	}
+	return v, true
+}
+
+// cutInt scans the decimal integer at the start of x, returning it
+// and the rest of x.
+func cutInt(x string) (n, rest string, ok bool) {
+	i := 0
+	for i < len(x) && '0' <= x[i] && x[i] <= '9' {
+		i++
+	}
+	if i == 0 || x[0] == '0' && i > 1 {
+		return "", "", false
+	}
+	return x[:i], x[i:], true
+}
+
+// cmpInt compares the decimal integers x and y,
+// treating the empty string as zero.
+func cmpInt(x, y string) int {
+	if x == "" {
+		x = "0"
+	}
+	if y == "" {
+		y = "0"
+	}
+	if len(x) != len(y) {
+		if len(x) < len(y) {
+			return -1
+		}
+		return +1
+	}
+	return strings.Compare(x, y)
+}
+
+// kindOrder returns a key ordering the kinds of Go versions:
+// betas before release candidates before releases.
+func kindOrder(kind string) string {
+	switch kind {
+	case "beta":
+		return "0"
+	case "rc":
+		return "1"
+	}
+	return "2"
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gover
+
+import "testing"
+
+var compareTests = []struct {
+	x, y string
+	out  int
+}{
+	{"1.18", "1.18", 0},
+	{"1.18", "1.18.0", 0},
+	{"1.18", "1.18.1", -1},
+	{"1.18.10", "1.18.9", +1},
+	{"1.18", "1.19", -1},
+	{"1.9", "1.10", -1},
+	{"1.19beta1", "1.19", -1},
+	{"1.19beta2", "1.19rc1", -1},
+	{"1.19rc1", "1.19rc2", -1},
+	{"1.19rc1", "1.18.5", +1},
+	{"2.0", "1.99", +1},
+	{"bad", "1.18", -1},
+	{"1.18", "bad", +1},
+	{"bad", "bad", 0},
+}
+
+func TestCompare(t *testing.T) {
+	for _, tt := range compareTests {
+		if out := Compare(tt.x, tt.y); out != tt.out {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tt.x, tt.y, out, tt.out)
+		}
+	}
+}
+
+var isValidTests = []struct {
+	in  string
+	out bool
+}{
+	{"1.18", true},
+	{"1.18.3", true},
+	{"1.19rc1", true},
+	{"1.19beta10", true},
+	{"1", false},
+	{"1.", false},
+	{"1.18.", false},
+	{"1.08", false},
+	{"1.18.3rc1", false},
+	{"1.19rc", false},
+	{"1.19alpha1", false},
+	{"go1.18", false},
+}
+
+func TestIsValid(t *testing.T) {
+	for _, tt := range isValidTests {
+		if out := IsValid(tt.in); out != tt.out {
+			t.Errorf("IsValid(%q) = %v, want %v", tt.in, out, tt.out)
+		}
+	}
+}
+
+var fromToolchainTests = []struct {
+	in, out string
+}{
+	{"go1.18", "1.18"},
+	{"go1.18.3", "1.18.3"},
+	{"go1.19rc1", "1.19rc1"},
+	{"go1.18.3-custom", "1.18.3"},
+	{"go1.18 X:fieldtrack", "1.18"},
+	{"devel +abcdef", ""},
+	{"go1.testgo", ""},
+	{"1.18", ""},
+	{"default", ""},
+}
+
+func TestFromToolchain(t *testing.T) {
+	for _, tt := range fromToolchainTests {
+		if out := FromToolchain(tt.in); out != tt.out {
+			t.Errorf("FromToolchain(%q) = %q, want %q", tt.in, out, tt.out)
+		}
+	}
+}
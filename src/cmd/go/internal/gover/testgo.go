@@ -0,0 +1,15 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains extra hooks for testing the go command.
+
+//go:build testgo
+
+package gover
+
+import "os"
+
+func init() {
+	TestVersion = os.Getenv("TESTGO_VERSION")
+}
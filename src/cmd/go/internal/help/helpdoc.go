@@ -542,6 +542,9 @@ General-purpose environment variables:
 	GOTMPDIR
 		The directory where the go command will write
 		temporary source files, packages, and binaries.
+	GOTOOLCHAIN
+		Controls which Go toolchain runs the go command: the local one,
+		or one required by the main module or workspace. See 'go help toolchain'.
 	GOVCS
 		Lists version control commands that may be used with matching servers.
 		See 'go help vcs'.
@@ -624,6 +627,9 @@ Special-purpose environment variables:
 	GCCGOTOOLDIR
 		If set, where to find gccgo tools, such as cgo.
 		The default is based on how gccgo was configured.
+	GOCOVERDIR
+		Directory into which to write code coverage data files
+		when running a program built with 'go build -cover'.
 	GOEXPERIMENT
 		Comma-separated list of toolchain experiments to enable or disable.
 		The list of available experiments may change arbitrarily over time.
@@ -791,6 +797,8 @@ fuzz function. These values are not used for regular building and
 testing, but they're stored in a subdirectory of the build cache.
 Running 'go clean -fuzzcache' removes all cached fuzzing values.
 This may make fuzzing less effective, temporarily.
+The cached fuzzing values can be shared between machines with
+'go fuzzcache export' and 'go fuzzcache import'.
 
 The GODEBUG environment variable can enable printing of debugging
 information about the state of the cache:
@@ -8,6 +8,7 @@ package load
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -212,6 +213,7 @@ type PackageInternal struct {
 	OmitDebug         bool                 // tell linker not to write debug information
 	GobinSubdir       bool                 // install target would be subdir of GOBIN
 	BuildInfo         string               // add this info to package main
+	PGOProfile        string               // path to PGO profile
 	TestmainGo        *[]byte              // content for _testmain.go
 	Embed             map[string][]string  // //go:embed comment mapping
 	OrigImportPath    string               // original import path before adding '_test' suffix
@@ -1404,6 +1406,13 @@ func disallowInternal(ctx context.Context, srcDir string, importer *Package, imp
 		return p
 	}
 
+	// Packages instrumented by 'go build -cover' are allowed to access
+	// internal/coverage/rtcov, which registers their coverage counters.
+	// The import is inserted by the go command, not written by the user.
+	if p.ImportPath == "internal/coverage/rtcov" && cfg.BuildCover && importer != nil && importer.Internal.CoverMode != "" {
+		return p
+	}
+
 	// We can't check standard packages with gccgo.
 	if cfg.BuildContext.Compiler == "gccgo" && p.Standard {
 		return p
@@ -2625,6 +2634,97 @@ func LoadImportWithFlags(path, srcDir string, parent *Package, stk *ImportStack,
 	return p
 }
 
+// EnsureImport ensures that package p imports the named package.
+func EnsureImport(p *Package, pkg string) {
+	for _, d := range p.Internal.Imports {
+		if d.ImportPath == pkg {
+			return
+		}
+	}
+
+	p1 := LoadImportWithFlags(pkg, p.Dir, p, &ImportStack{}, nil, 0)
+	if p1.Error != nil {
+		base.Fatalf("load %s: %v", pkg, p1.Error)
+	}
+
+	p.Internal.Imports = append(p.Internal.Imports, p1)
+}
+
+// DeclareCoverVars attaches the required cover variables names
+// to the files, to be used when annotating the files.
+func DeclareCoverVars(p *Package, files ...string) map[string]*CoverVar {
+	coverVars := make(map[string]*CoverVar)
+	coverIndex := 0
+	// We create the cover counters as new top-level variables in the package.
+	// We need to avoid collisions with user variables (GoCover_0 is unlikely but still)
+	// and more importantly with dot imports of other covered packages,
+	// so we append 12 hex digits from the SHA-256 of the import path.
+	// The point is only to avoid accidents, not to defeat users determined to
+	// break things.
+	sum := sha256.Sum256([]byte(p.ImportPath))
+	h := fmt.Sprintf("%x", sum[:6])
+	for _, file := range files {
+		if base.IsTestFile(file) {
+			continue
+		}
+		// For a package that is "local" (imported via ./ import or command line, outside GOPATH),
+		// we record the full path to the file name.
+		// Otherwise we record the import path, then a forward slash, then the file name.
+		// This makes profiles within GOPATH file system-independent.
+		// These names appear in the cmd/cover HTML interface.
+		var longFile string
+		if p.Internal.Local {
+			longFile = filepath.Join(p.Dir, file)
+		} else {
+			longFile = pathpkg.Join(p.ImportPath, file)
+		}
+		coverVars[file] = &CoverVar{
+			File: longFile,
+			Var:  fmt.Sprintf("GoCover_%d_%x", coverIndex, h),
+		}
+		coverIndex++
+	}
+	return coverVars
+}
+
+// PrepareForCoverageBuild marks the packages to be instrumented by
+// 'go build -cover' among pkgs and their dependencies: those matching
+// the -coverpkg patterns or, by default, those named on the command
+// line and those in the main module. Standard library packages are
+// never instrumented.
+func PrepareForCoverageBuild(pkgs []*Package) {
+	var match []func(*Package) bool
+	for _, pattern := range cfg.BuildCoverPkg {
+		match = append(match, MatchPackage(pattern, base.Cwd()))
+	}
+	selected := func(p *Package) bool {
+		if match == nil {
+			return p.Internal.CmdlinePkg || p.Module != nil && p.Module.Main
+		}
+		for _, m := range match {
+			if m(p) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, p := range PackageList(pkgs) {
+		if p.Standard || p.Error != nil || len(p.GoFiles)+len(p.CgoFiles) == 0 || !selected(p) {
+			continue
+		}
+		p.Internal.CoverMode = cfg.BuildCoverMode
+		var coverFiles []string
+		coverFiles = append(coverFiles, p.GoFiles...)
+		coverFiles = append(coverFiles, p.CgoFiles...)
+		p.Internal.CoverVars = DeclareCoverVars(p, coverFiles...)
+		EnsureImport(p, "internal/coverage/rtcov")
+		if cfg.BuildCoverMode == "atomic" {
+			EnsureImport(p, "sync/atomic")
+		}
+	}
+}
+
 // PackageOpts control the behavior of PackagesAndErrors and other package
 // loading functions.
 type PackageOpts struct {
@@ -2749,9 +2849,84 @@ func PackagesAndErrors(ctx context.Context, opts PackageOpts, patterns []string)
 	// their dependencies).
 	setToolFlags(pkgs...)
 
+	setPGOProfilePath(pkgs)
+
 	return pkgs
 }
 
+// setPGOProfilePath sets p.Internal.PGOProfile for the packages in pkgs
+// and all their dependencies, according to the -pgo flag.
+//
+// With -pgo=auto, the profile is the file default.pgo in the directory
+// of the main package, if there is one. Since all the packages in a build
+// are compiled with the same profile, -pgo=auto uses no profile if pkgs
+// contains more than one main package.
+func setPGOProfilePath(pkgs []*Package) {
+	var file string
+	switch cfg.BuildPGO {
+	case "", "off":
+		return
+
+	case "auto":
+		var main *Package
+		for _, p := range pkgs {
+			if p.Name == "main" {
+				if main != nil {
+					return
+				}
+				main = p
+			}
+		}
+		if main == nil || main.Dir == "" {
+			return
+		}
+		file = filepath.Join(main.Dir, "default.pgo")
+		if fi, err := fsys.Stat(file); err != nil || fi.IsDir() {
+			return
+		}
+
+	default:
+		var err error
+		file, err = filepath.Abs(cfg.BuildPGO)
+		if err != nil {
+			base.Fatalf("go: invalid -pgo profile path: %v", err)
+		}
+		if _, err := fsys.Stat(file); err != nil {
+			base.Fatalf("go: %v", err)
+		}
+	}
+
+	setting := file
+	if cfg.BuildTrimpath {
+		setting = filepath.Base(file)
+	}
+	for _, p := range PackageList(pkgs) {
+		p.Internal.PGOProfile = file
+		if p.Internal.BuildInfo != "" {
+			p.Internal.BuildInfo = appendBuildSetting(p.Internal.BuildInfo, "-pgo", setting)
+		}
+	}
+}
+
+// appendBuildSetting returns the build information info, as formatted
+// by setBuildInfo, with the setting key=value added to its settings.
+// Settings for command-line flags are kept in sorted order.
+func appendBuildSetting(info, key, value string) string {
+	bi, err := debug.ParseBuildInfo(info)
+	if err != nil {
+		// setBuildInfo wrote info, so this should not happen.
+		base.Fatalf("go: internal error: parsing build info: %v", err)
+	}
+	i := 0
+	for i < len(bi.Settings) && strings.HasPrefix(bi.Settings[i].Key, "-") && bi.Settings[i].Key < key {
+		i++
+	}
+	bi.Settings = append(bi.Settings, debug.BuildSetting{})
+	copy(bi.Settings[i+1:], bi.Settings[i:])
+	bi.Settings[i] = debug.BuildSetting{Key: key, Value: value}
+	return bi.String()
+}
+
 // CheckPackageErrors prints errors encountered loading pkgs and their
 // dependencies, then exits with a non-zero status if any errors were found.
 func CheckPackageErrors(pkgs []*Package) {
@@ -2960,6 +3135,7 @@ func GoFilesPackage(ctx context.Context, opts PackageOpts, gofiles []string) *Pa
 		pkg.Error = &PackageError{Err: &mainPackageError{importPath: pkg.ImportPath}}
 	}
 	setToolFlags(pkg)
+	setPGOProfilePath([]*Package{pkg})
 
 	return pkg
 }
@@ -78,6 +78,10 @@ and the changes are applied in the order given.
 
 The -go=version flag sets the expected Go language version.
 
+The -toolchain=name flag sets the Go toolchain to use.
+The -toolchain=none flag drops the toolchain line.
+See 'go help toolchain'.
+
 The -print flag prints the final go.mod in its text format instead of
 writing it back to go.mod.
 
@@ -90,12 +94,13 @@ writing it back to go.mod. The JSON output corresponds to these Go types:
 	}
 
 	type GoMod struct {
-		Module  ModPath
-		Go      string
-		Require []Require
-		Exclude []Module
-		Replace []Replace
-		Retract []Retract
+		Module    ModPath
+		Go        string
+		Toolchain string
+		Require   []Require
+		Exclude   []Module
+		Replace   []Replace
+		Retract   []Retract
 	}
 
 	type ModPath struct {
@@ -132,12 +137,13 @@ See https://golang.org/ref/mod#go-mod-edit for more about 'go mod edit'.
 }
 
 var (
-	editFmt    = cmdEdit.Flag.Bool("fmt", false, "")
-	editGo     = cmdEdit.Flag.String("go", "", "")
-	editJSON   = cmdEdit.Flag.Bool("json", false, "")
-	editPrint  = cmdEdit.Flag.Bool("print", false, "")
-	editModule = cmdEdit.Flag.String("module", "", "")
-	edits      []func(*modfile.File) // edits specified in flags
+	editFmt       = cmdEdit.Flag.Bool("fmt", false, "")
+	editGo        = cmdEdit.Flag.String("go", "", "")
+	editToolchain = cmdEdit.Flag.String("toolchain", "", "")
+	editJSON      = cmdEdit.Flag.Bool("json", false, "")
+	editPrint     = cmdEdit.Flag.Bool("print", false, "")
+	editModule    = cmdEdit.Flag.String("module", "", "")
+	edits         []func(*modfile.File) // edits specified in flags
 )
 
 type flagFunc func(string)
@@ -165,6 +171,7 @@ func runEdit(ctx context.Context, cmd *base.Command, args []string) {
 	anyFlags :=
 		*editModule != "" ||
 			*editGo != "" ||
+			*editToolchain != "" ||
 			*editJSON ||
 			*editPrint ||
 			*editFmt ||
@@ -200,6 +207,12 @@ func runEdit(ctx context.Context, cmd *base.Command, args []string) {
 		}
 	}
 
+	if *editToolchain != "" && *editToolchain != "none" {
+		if !modfile.ToolchainRE.MatchString(*editToolchain) {
+			base.Fatalf(`go: invalid -toolchain option; expecting something like "-toolchain go%s"`, modload.LatestGoVersion())
+		}
+	}
+
 	data, err := lockedfile.Read(gomod)
 	if err != nil {
 		base.Fatalf("go: %v", err)
@@ -220,6 +233,14 @@ func runEdit(ctx context.Context, cmd *base.Command, args []string) {
 		}
 	}
 
+	if *editToolchain == "none" {
+		modFile.DropToolchainStmt()
+	} else if *editToolchain != "" {
+		if err := modFile.AddToolchainStmt(*editToolchain); err != nil {
+			base.Fatalf("go: internal error: %v", err)
+		}
+	}
+
 	if len(edits) > 0 {
 		for _, edit := range edits {
 			edit(modFile)
@@ -455,12 +476,13 @@ func flagDropRetract(arg string) {
 
 // fileJSON is the -json output data structure.
 type fileJSON struct {
-	Module  editModuleJSON
-	Go      string `json:",omitempty"`
-	Require []requireJSON
-	Exclude []module.Version
-	Replace []replaceJSON
-	Retract []retractJSON
+	Module    editModuleJSON
+	Go        string `json:",omitempty"`
+	Toolchain string `json:",omitempty"`
+	Require   []requireJSON
+	Exclude   []module.Version
+	Replace   []replaceJSON
+	Retract   []retractJSON
 }
 
 type editModuleJSON struct {
@@ -497,6 +519,9 @@ func editPrintJSON(modFile *modfile.File) {
 	if modFile.Go != nil {
 		f.Go = modFile.Go.Version
 	}
+	if modFile.Toolchain != nil {
+		f.Toolchain = modFile.Toolchain.Name
+	}
 	for _, r := range modFile.Require {
 		f.Require = append(f.Require, requireJSON{Path: r.Mod.Path, Version: r.Mod.Version, Indirect: r.Indirect})
 	}
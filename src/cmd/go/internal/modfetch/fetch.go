@@ -59,6 +59,12 @@ func Download(ctx context.Context, mod module.Version) (dir string, err error) {
 	return c.dir, c.err
 }
 
+// UseSumDB reports whether the checksum of mod is verified against
+// the checksum database when it is downloaded.
+func UseSumDB(mod module.Version) bool {
+	return useSumDB(mod)
+}
+
 func download(ctx context.Context, mod module.Version) (dir string, err error) {
 	ctx, span := trace.StartSpan(ctx, "modfetch.download "+mod.String())
 	defer span.Done()
@@ -49,6 +49,9 @@ https://golang.org/ref/mod#go-mod-file.
 To create a new go.mod file, use 'go mod init'. For details see
 'go help mod init' or https://golang.org/ref/mod#go-mod-init.
 
+The go and toolchain lines of the go.mod file of the main module
+control which Go toolchain runs the go command; see 'go help toolchain'.
+
 To add missing module requirements or remove unneeded requirements,
 use 'go mod tidy'. For details, see 'go help mod tidy' or
 https://golang.org/ref/mod#go-mod-tidy.
@@ -1288,6 +1288,22 @@ func findModuleRoot(dir string) (roots string) {
 	return ""
 }
 
+// FindGoMod returns the name of the go.mod file of the module
+// containing dir, or "" if there is none.
+func FindGoMod(dir string) string {
+	root := findModuleRoot(dir)
+	if root == "" {
+		return ""
+	}
+	return filepath.Join(root, "go.mod")
+}
+
+// FindGoWork returns the name of the go.work file enclosing dir,
+// or "" if there is none.
+func FindGoWork(dir string) string {
+	return findWorkspaceFile(dir)
+}
+
 func findWorkspaceFile(dir string) (root string) {
 	if dir == "" {
 		panic("dir not set")
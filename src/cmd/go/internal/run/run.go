@@ -65,6 +65,7 @@ func init() {
 	CmdRun.Run = runRun // break init loop
 
 	work.AddBuildFlags(CmdRun, work.DefaultBuildFlags)
+	work.AddCoverFlags(CmdRun)
 	CmdRun.Flag.Var((*base.StringsFlag)(&work.ExecCmd), "exec", "")
 }
 
@@ -137,6 +138,9 @@ func runRun(ctx context.Context, cmd *base.Command, args []string) {
 	}
 	cmdArgs := args[i:]
 	load.CheckPackageErrors([]*load.Package{p})
+	if cfg.BuildCover {
+		load.PrepareForCoverageBuild([]*load.Package{p})
+	}
 
 	p.Internal.OmitDebug = true
 	p.Target = "" // must build - not up to date
@@ -0,0 +1,34 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+
+package toolchain
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+
+	"cmd/go/internal/base"
+)
+
+// execGo runs the go command exe with the arguments args in place of
+// the running go command, exiting with its exit status. It does not
+// return.
+func execGo(exe string, args []string) {
+	cmd := exec.Command(exe, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && ee.Exited() {
+			os.Exit(ee.ExitCode())
+		}
+		base.Fatalf("go: exec %s: %v", exe, err)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,21 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package toolchain
+
+import (
+	"os"
+	"syscall"
+
+	"cmd/go/internal/base"
+)
+
+// execGo replaces the running go command by the go command exe,
+// invoked with the arguments args. It does not return.
+func execGo(exe string, args []string) {
+	err := syscall.Exec(exe, append([]string{exe}, args...), os.Environ())
+	base.Fatalf("go: exec %s: %v", exe, err)
+}
@@ -0,0 +1,241 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package toolchain implements the selection of the Go toolchain
+// running the go command, as controlled by the GOTOOLCHAIN setting
+// and by the go and toolchain lines of go.mod and go.work files.
+package toolchain
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"cmd/go/internal/base"
+	"cmd/go/internal/cfg"
+	"cmd/go/internal/gover"
+	"cmd/go/internal/modfetch"
+	"cmd/go/internal/modload"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+var HelpToolchain = &base.Command{
+	UsageLine: "toolchain",
+	Short:     "toolchain selection and GOTOOLCHAIN",
+	Long: `
+The go line of a go.mod or go.work file declares the version of Go that
+the module or workspace requires. The toolchain line, which is optional,
+names the Go toolchain that should be used to work in the module or
+workspace, such as
+
+	toolchain go1.18.3
+
+When the main module or workspace requires a newer version of Go than
+that of the go command being run, the go command can download a newer
+toolchain and run it instead. The GOTOOLCHAIN environment variable
+controls this behavior. It can be set to:
+
+	local
+		Always run the local toolchain, the one that was invoked.
+	auto
+		Run the local toolchain, unless the main module or workspace
+		requires a newer one. This is the default.
+	<name>, such as go1.18.3
+		Always run the named toolchain, downloading it if it
+		is not the local toolchain.
+	<name>+auto
+		Run the named toolchain, unless the main module or
+		workspace requires a newer one.
+
+The toolchain required by the main module or workspace is the newest
+of the one named by its toolchain line and the first release of the
+Go version of its go line: for 'go 1.19', that is go1.19. When the
+go.work file of a workspace is in use, its go and toolchain lines are
+used, and those of the go.mod files of its modules are not. The name
+'default' in a toolchain line states no requirement beyond the go line.
+
+Toolchains are downloaded as the module golang.org/toolchain, at
+versions such as v0.0.1-go1.18.3.linux-amd64, through the module proxy
+and into the module cache, like other modules. As for other modules,
+the go command verifies the checksum of each downloaded toolchain
+against the checksum database configured by GOSUMDB; see
+'go help module-auth'. Unlike other modules, toolchains are never
+downloaded without that verification: if GOSUMDB is off, or if
+GONOSUMDB or GOPRIVATE matches golang.org/toolchain, the go command
+refuses to run a toolchain other than the local one.
+
+The downloaded toolchain is run with GOTOOLCHAIN set to local,
+so that it does not select another toolchain in turn.
+
+GOTOOLCHAIN can be set with 'go env -w'. The 'go env -w' and
+'go env -u' commands always run the local toolchain.
+	`,
+}
+
+// toolchainModule is the path of the modules holding Go toolchains.
+const toolchainModule = "golang.org/toolchain"
+
+// Select runs a different Go toolchain in place of the local one,
+// if the GOTOOLCHAIN setting and the requirements of the main module
+// or workspace call for it. If the local toolchain is to be used,
+// Select returns; otherwise it does not. args are the command line
+// arguments of the go command, after its flags.
+func Select(args []string) {
+	if len(args) > 0 && args[0] == "env" && changesEnv(args[1:]) {
+		// Let the local toolchain change a broken GOTOOLCHAIN setting.
+		return
+	}
+
+	gotoolchain := cfg.GOTOOLCHAIN
+	min, auto := gotoolchain, false
+	if gotoolchain == "auto" {
+		min, auto = "local", true
+	} else if strings.HasSuffix(gotoolchain, "+auto") {
+		min, auto = strings.TrimSuffix(gotoolchain, "+auto"), true
+	}
+	var minVers string
+	if min == "local" {
+		minVers = gover.Local()
+	} else if minVers = gover.FromToolchain(min); minVers == "" {
+		base.Fatalf("go: invalid GOTOOLCHAIN %q", gotoolchain)
+	}
+
+	name := min
+	if auto && modload.WillBeEnabled() {
+		if need := required(); need != "" && gover.Compare(gover.FromToolchain(need), minVers) > 0 {
+			name = need
+		}
+	}
+	if name == "local" || name == gover.LocalToolchain() {
+		return
+	}
+	run(name)
+}
+
+// changesEnv reports whether the arguments of 'go env' make it change
+// the go environment configuration file.
+func changesEnv(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			break
+		}
+		switch strings.TrimPrefix(arg, "-") {
+		case "w", "-w", "u", "-u":
+			return true
+		}
+	}
+	return false
+}
+
+// required returns the name of the toolchain required by the go.work
+// file in use or else by the go.mod file of the main module, or "" if
+// there is no such file or requirement. Errors in the file are
+// ignored, to be reported by the command being run.
+func required() string {
+	var file string
+	switch gowork := cfg.Getenv("GOWORK"); gowork {
+	case "off":
+	case "", "auto":
+		file = modload.FindGoWork(base.Cwd())
+	default:
+		file = gowork
+	}
+	isWork := file != ""
+	if !isWork {
+		file = modload.FindGoMod(base.Cwd())
+		if file == "" {
+			return ""
+		}
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+
+	// Accept non-canonical versions in other lines, as the go command
+	// fixes them when loading the main module.
+	anyVersion := func(_, vers string) (string, error) { return vers, nil }
+	var goLine *modfile.Go
+	var toolchainLine *modfile.Toolchain
+	if isWork {
+		wf, err := modfile.ParseWork(file, data, anyVersion)
+		if err != nil {
+			return ""
+		}
+		goLine, toolchainLine = wf.Go, wf.Toolchain
+	} else {
+		mf, err := modfile.Parse(file, data, anyVersion)
+		if err != nil {
+			return ""
+		}
+		goLine, toolchainLine = mf.Go, mf.Toolchain
+	}
+
+	need := ""
+	if goLine != nil {
+		need = "go" + goLine.Version
+	}
+	if toolchainLine != nil && toolchainLine.Name != "default" {
+		if need == "" || gover.Compare(gover.FromToolchain(toolchainLine.Name), gover.FromToolchain(need)) >= 0 {
+			need = toolchainLine.Name
+		}
+	}
+	return need
+}
+
+// run downloads the named toolchain for the host system and runs it
+// in place of the local one. It does not return.
+func run(name string) {
+	m := module.Version{
+		Path:    toolchainModule,
+		Version: "v0.0.1-" + name + "." + runtime.GOOS + "-" + runtime.GOARCH,
+	}
+	if !modfetch.UseSumDB(m) {
+		// Never run a toolchain that has not been verified.
+		base.Fatalf("go: download %s for %s/%s: toolchains must be verified by the checksum database, but GOSUMDB or GONOSUMDB disables it for %s", name, runtime.GOOS, runtime.GOARCH, toolchainModule)
+	}
+	dir, err := modfetch.Download(context.Background(), m)
+	if err != nil {
+		base.Fatalf("go: download %s for %s/%s: %v", name, runtime.GOOS, runtime.GOARCH, err)
+	}
+
+	// The module cache keeps files read-only and not executable,
+	// so make the toolchain's binaries executable.
+	if runtime.GOOS != "windows" && runtime.GOOS != "plan9" {
+		for _, sub := range []string{"bin", "pkg/tool"} {
+			err := filepath.WalkDir(filepath.Join(dir, sub), func(path string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				if info.Mode()&0111 == 0 {
+					return os.Chmod(path, info.Mode()|0111)
+				}
+				return nil
+			})
+			if err != nil && !os.IsNotExist(err) {
+				base.Fatalf("go: preparing %s: %v", name, err)
+			}
+		}
+	}
+
+	exe := filepath.Join(dir, "bin", "go")
+	if runtime.GOOS == "windows" {
+		exe += ".exe"
+	}
+	if _, err := os.Stat(exe); err != nil {
+		base.Fatalf("go: toolchain %s has no go command: %v", name, err)
+	}
+	os.Setenv("GOROOT", dir)
+	os.Setenv("GOTOOLCHAIN", "local")
+	execGo(exe, os.Args[1:])
+}
@@ -0,0 +1,195 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"cmd/go/internal/base"
+	"cmd/go/internal/work"
+	"cmd/internal/diff"
+)
+
+// A fix is a suggested fix computed by the vet tool in fix mode.
+// See cmd/internal/analysisfix.Fix.
+type fix struct {
+	Analyzer string
+	Posn     string
+	Message  string
+	Edits    []edit
+}
+
+// An edit replaces the bytes [Offset, End) of a file with New.
+type edit struct {
+	Filename    string
+	Offset, End int
+	New         string
+}
+
+// overlaps reports whether e and e1 cannot both be applied:
+// whether they change overlapping bytes or insert different text
+// at the same offset.
+func (e edit) overlaps(e1 edit) bool {
+	if e.Offset == e1.Offset && e.End == e1.End {
+		return e.New != e1.New
+	}
+	return e.Offset < e1.End && e1.Offset < e.End
+}
+
+// applyFixes applies the suggested fixes computed by the vet actions,
+// or, if printDiff is set, prints the changes they would make as diffs.
+//
+// The fixes of all packages are applied together: if any package
+// failed, or any file would not be valid Go source after fixing it,
+// no file is changed. A fix is applied as a whole or not at all;
+// fixes conflicting with fixes applied before them are skipped, and
+// are found again by running the command again. Identical fixes
+// computed for more than one package, such as for a package and
+// for its test variant, are applied once. Files are reformatted
+// with gofmt after fixing them.
+func applyFixes(actions []*work.Action, printDiff bool) {
+	var fixes []fix
+	for _, a := range actions {
+		if a.Failed || a.FixOutput == nil {
+			continue
+		}
+		var list []fix
+		if err := json.Unmarshal(a.FixOutput, &list); err != nil {
+			base.Fatalf("go: cannot decode suggested fixes for %s: %v", a.Package.ImportPath, err)
+		}
+	Fixes:
+		for _, f := range list {
+			// Only fix the package's own source files,
+			// not the files generated while building it.
+			for _, e := range f.Edits {
+				if filepath.Dir(e.Filename) != a.Package.Dir {
+					continue Fixes
+				}
+			}
+			fixes = append(fixes, f)
+		}
+	}
+	base.ExitIfErrors()
+
+	edits := make(map[string][]edit) // accepted edits by file
+	seen := make(map[string]bool)    // accepted fixes, by their edits
+	skipped := 0
+Accept:
+	for _, f := range fixes {
+		key := fmt.Sprint(f.Edits)
+		if seen[key] {
+			continue
+		}
+		for _, e := range f.Edits {
+			for _, e1 := range edits[e.Filename] {
+				if e.overlaps(e1) {
+					skipped++
+					continue Accept
+				}
+			}
+		}
+		seen[key] = true
+	Edits:
+		for _, e := range f.Edits {
+			for _, e1 := range edits[e.Filename] {
+				if e == e1 {
+					continue Edits
+				}
+			}
+			edits[e.Filename] = append(edits[e.Filename], e)
+		}
+	}
+
+	var files []string
+	for file := range edits {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	olds := make([][]byte, len(files))
+	news := make([][]byte, len(files))
+	for i, file := range files {
+		old, err := os.ReadFile(file)
+		if err != nil {
+			base.Errorf("go: %v", err)
+			continue
+		}
+		new, err := applyEdits(old, edits[file])
+		if err == nil {
+			new, err = format.Source(new)
+		}
+		if err != nil {
+			base.Errorf("go: cannot fix %s: %v", base.ShortPath(file), err)
+			continue
+		}
+		olds[i], news[i] = old, new
+	}
+	base.ExitIfErrors()
+
+	for i, file := range files {
+		if bytes.Equal(olds[i], news[i]) {
+			continue
+		}
+		if printDiff {
+			data, err := diff.Diff("go-fix", olds[i], news[i])
+			if err != nil {
+				base.Fatalf("go: computing diff: %v", err)
+			}
+			os.Stdout.Write(replaceTempFilename(data, base.ShortPath(file)))
+			continue
+		}
+		fi, err := os.Stat(file)
+		if err == nil {
+			err = os.WriteFile(file, news[i], fi.Mode().Perm())
+		}
+		if err != nil {
+			base.Errorf("go: %v", err)
+		}
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "go: skipped %d conflicting fixes; run the command again to apply them\n", skipped)
+	}
+}
+
+// applyEdits returns src with the edits applied.
+func applyEdits(src []byte, edits []edit) ([]byte, error) {
+	edits = append([]edit(nil), edits...)
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].Offset != edits[j].Offset {
+			return edits[i].Offset < edits[j].Offset
+		}
+		return edits[i].End < edits[j].End
+	})
+	var buf bytes.Buffer
+	last := 0
+	for _, e := range edits {
+		if e.Offset < last || e.End < e.Offset || e.End > len(src) {
+			return nil, fmt.Errorf("invalid edit of bytes %d-%d", e.Offset, e.End)
+		}
+		buf.Write(src[last:e.Offset])
+		buf.WriteString(e.New)
+		last = e.End
+	}
+	buf.Write(src[last:])
+	return buf.Bytes(), nil
+}
+
+// replaceTempFilename replaces the names of the temporary files in
+// the header of a diff produced by diff.Diff with filename.
+func replaceTempFilename(diff []byte, filename string) []byte {
+	bs := bytes.SplitN(diff, []byte{'\n'}, 3)
+	if len(bs) < 3 {
+		return diff
+	}
+	f := filepath.ToSlash(filename)
+	bs[0] = []byte("--- " + f + ".orig")
+	bs[1] = []byte("+++ " + f)
+	return bytes.Join(bs, []byte{'\n'})
+}
@@ -8,6 +8,7 @@ package vet
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"cmd/go/internal/base"
@@ -25,7 +26,7 @@ func init() {
 
 var CmdVet = &base.Command{
 	CustomFlags: true,
-	UsageLine:   "go vet [-n] [-x] [-vettool prog] [build flags] [vet flags] [packages]",
+	UsageLine:   "go vet [-n] [-x] [-vettool prog] [-fix] [-diff] [build flags] [vet flags] [packages]",
 	Short:       "report likely mistakes in packages",
 	Long: `
 Vet runs the Go vet command on the packages named by the import paths.
@@ -45,6 +46,18 @@ For example, the 'shadow' analyzer can be built and run using these commands:
   go install golang.org/x/tools/go/analysis/passes/shadow/cmd/shadow
   go vet -vettool=$(which shadow)
 
+The -fix flag causes vet to apply the suggested fixes of its checkers
+to the source files of the packages, instead of reporting problems.
+Checkers depending on information about other packages, such as
+'printf', are not run with -fix. The fixes of all the packages are
+applied together: if any package cannot be analyzed, no file is
+changed. Fixes conflicting with other fixes are skipped; run the
+command again to apply them. Fixed files are reformatted with gofmt.
+Only packages in the main module are fixed.
+
+The -diff flag is like -fix, but prints the changes that the fixes
+would make as a unified diff instead of applying them.
+
 The build flags supported by go vet are those that control package resolution
 and execution, such as -n, -x, -v, -tags, and -toolexec.
 For more about these flags, see 'go help build'.
@@ -86,7 +99,17 @@ func runVet(ctx context.Context, cmd *base.Command, args []string) {
 			base.Fatalf("%v", err)
 		}
 	}
+	work.VetFix = vetFix || vetDiff
 
+	Run(ctx, pkgArgs, vetDiff)
+}
+
+// Run runs the vet tool on the packages named by pkgArgs. If
+// work.VetFix is set, it then applies the suggested fixes computed by
+// the tool, or, if printDiff is set, prints the changes they would make.
+//
+// The caller is expected to set the vet configuration in package work.
+func Run(ctx context.Context, pkgArgs []string, printDiff bool) {
 	pkgOpts := load.PackageOpts{ModResolveTests: true}
 	pkgs := load.PackagesAndErrors(ctx, pkgOpts, pkgArgs)
 	load.CheckPackageErrors(pkgs)
@@ -98,7 +121,15 @@ func runVet(ctx context.Context, cmd *base.Command, args []string) {
 	b.Init()
 
 	root := &work.Action{Mode: "go vet"}
+	printed := false
 	for _, p := range pkgs {
+		if work.VetFix && modload.Enabled() && p.Module != nil && !p.Module.Main {
+			if !printed {
+				fmt.Fprintf(os.Stderr, "go: not fixing packages in dependency modules\n")
+				printed = true
+			}
+			continue
+		}
 		_, ptest, pxtest, err := load.TestPackagesFor(ctx, pkgOpts, p, nil)
 		if err != nil {
 			base.Errorf("%v", err)
@@ -116,4 +147,8 @@ func runVet(ctx context.Context, cmd *base.Command, args []string) {
 		}
 	}
 	b.Do(ctx, root)
+
+	if work.VetFix {
+		applyFixes(root.Deps, printDiff)
+	}
 }
@@ -38,9 +38,16 @@ import (
 //
 var vetTool string // -vettool
 
+var (
+	vetFix  bool // -fix
+	vetDiff bool // -diff
+)
+
 func init() {
 	work.AddBuildFlags(CmdVet, work.DefaultBuildFlags)
 	CmdVet.Flag.StringVar(&vetTool, "vettool", "", "")
+	CmdVet.Flag.BoolVar(&vetFix, "fix", false, "")
+	CmdVet.Flag.BoolVar(&vetDiff, "diff", false, "")
 }
 
 func parseVettoolFlag(args []string) {
@@ -86,6 +86,7 @@ type Action struct {
 	buildID  string         // build ID of action output
 
 	VetxOnly  bool       // Mode=="vet": only being called to supply info about dependencies
+	FixOutput []byte     // Mode=="vet": suggested fixes, if VetFix is set
 	needVet   bool       // Mode=="build": need to fill in vet config
 	needBuild bool       // Mode=="build": need to do actual build (can be false if needVet is true)
 	vetCfg    *vetConfig // vet config
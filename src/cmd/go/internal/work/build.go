@@ -7,6 +7,7 @@ package work
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"go/build"
 	exec "internal/execabs"
@@ -78,6 +79,30 @@ and test commands:
 	-asan
 		enable interoperation with address sanitizer.
 		Supported only on linux/arm64, linux/amd64.
+	-cover
+		enable code coverage instrumentation. When the resulting
+		program exits, it writes coverage data files into the
+		directory named by the GOCOVERDIR environment variable.
+		Use 'go tool covdata' to process those files.
+		Supported only by the build, install and run commands;
+		for coverage of tests, see 'go help testflag'.
+	-covermode set,count,atomic
+		set the mode for coverage analysis.
+		The default is "set" unless -race is enabled,
+		in which case it is "atomic".
+		The values:
+		set: bool: does this statement run?
+		count: int: how many times does this statement run?
+		atomic: int: count, but correct in multithreaded programs;
+			significantly more expensive.
+		Sets -cover.
+	-coverpkg pattern1,pattern2,pattern3
+		apply coverage analysis to each package matching the patterns.
+		The default is to apply coverage analysis to the packages named
+		on the command line and to the other packages in the main module.
+		Standard library packages are never instrumented.
+		See 'go help packages' for a description of package patterns.
+		Sets -cover.
 	-v
 		print the names of packages as they are compiled.
 	-work
@@ -141,6 +166,14 @@ and test commands:
 		include path must be in the same directory as the Go package they are
 		included from, and overlays will not appear when binaries and tests are
 		run through go run and go test respectively.
+	-pgo file
+		specify the file path of a profile for profile-guided optimization (PGO).
+		The profile is a CPU profile in pprof format, such as one written by
+		runtime/pprof. The main package and all its dependencies are compiled
+		using the profile. The special name "auto" (the default) selects the
+		file "default.pgo" in the main package's directory, if that exists and
+		the build has a single main package. The special name "off" turns
+		off PGO.
 	-pkgdir dir
 		install and load all packages from dir instead of the usual locations.
 		For example, when building with a non-standard configuration,
@@ -209,6 +242,8 @@ func init() {
 
 	AddBuildFlags(CmdBuild, DefaultBuildFlags)
 	AddBuildFlags(CmdInstall, DefaultBuildFlags)
+	AddCoverFlags(CmdBuild)
+	AddCoverFlags(CmdInstall)
 }
 
 // Note that flags consulted by other parts of the code
@@ -294,6 +329,7 @@ func AddBuildFlags(cmd *base.Command, mask BuildFlagMask) {
 	cmd.Flag.StringVar(&cfg.BuildContext.InstallSuffix, "installsuffix", "", "")
 	cmd.Flag.Var(&load.BuildLdflags, "ldflags", "")
 	cmd.Flag.BoolVar(&cfg.BuildLinkshared, "linkshared", false, "")
+	cmd.Flag.StringVar(&cfg.BuildPGO, "pgo", "auto", "")
 	cmd.Flag.StringVar(&cfg.BuildPkgdir, "pkgdir", "", "")
 	cmd.Flag.BoolVar(&cfg.BuildRace, "race", false, "")
 	cmd.Flag.BoolVar(&cfg.BuildMSan, "msan", false, "")
@@ -309,6 +345,54 @@ func AddBuildFlags(cmd *base.Command, mask BuildFlagMask) {
 	cmd.Flag.StringVar(&cfg.DebugTrace, "debug-trace", "", "")
 }
 
+// AddCoverFlags adds the coverage flags to the build, install and run
+// commands. The test command has coverage flags of its own.
+func AddCoverFlags(cmd *base.Command) {
+	cmd.Flag.BoolVar(&cfg.BuildCover, "cover", false, "")
+	cmd.Flag.Var(coverFlag{(*coverModeFlag)(&cfg.BuildCoverMode)}, "covermode", "")
+	cmd.Flag.Var(coverFlag{(*commaListFlag)(&cfg.BuildCoverPkg)}, "coverpkg", "")
+}
+
+// A coverFlag is a flag.Value that also implies -cover.
+type coverFlag struct{ v flag.Value }
+
+func (f coverFlag) String() string { return f.v.String() }
+
+func (f coverFlag) Set(value string) error {
+	if err := f.v.Set(value); err != nil {
+		return err
+	}
+	cfg.BuildCover = true
+	return nil
+}
+
+type coverModeFlag string
+
+func (f *coverModeFlag) String() string { return string(*f) }
+func (f *coverModeFlag) Set(value string) error {
+	switch value {
+	case "", "set", "count", "atomic":
+		*f = coverModeFlag(value)
+		return nil
+	default:
+		return errors.New(`valid modes are "set", "count", or "atomic"`)
+	}
+}
+
+// commaListFlag is a flag.Value representing a comma-separated list.
+type commaListFlag []string
+
+func (f *commaListFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *commaListFlag) Set(value string) error {
+	if value == "" {
+		*f = nil
+	} else {
+		*f = strings.Split(value, ",")
+	}
+	return nil
+}
+
 // tagsFlag is the implementation of the -tags flag.
 type tagsFlag []string
 
@@ -381,6 +465,9 @@ func runBuild(ctx context.Context, cmd *base.Command, args []string) {
 
 	pkgs := load.PackagesAndErrors(ctx, load.PackageOpts{}, args)
 	load.CheckPackageErrors(pkgs)
+	if cfg.BuildCover {
+		load.PrepareForCoverageBuild(pkgs)
+	}
 
 	explicitO := len(cfg.BuildO) > 0
 
@@ -669,6 +756,9 @@ func InstallPackages(ctx context.Context, patterns []string, pkgs []*load.Packag
 	}
 
 	pkgs = omitTestOnly(pkgsFilter(pkgs))
+	if cfg.BuildCover {
+		load.PrepareForCoverageBuild(pkgs)
+	}
 	for _, p := range pkgs {
 		if p.Target == "" {
 			switch {
@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -298,12 +299,18 @@ func (b *Builder) buildActionID(a *Action) cache.ActionID {
 	}
 	if p.Internal.CoverMode != "" {
 		fmt.Fprintf(h, "cover %q %q\n", p.Internal.CoverMode, b.toolID("cover"))
+		if cfg.BuildCover {
+			fmt.Fprintf(h, "coverregister\n")
+		}
 	}
 	if p.Internal.FuzzInstrument {
 		if fuzzFlags := fuzzInstrumentFlags(); fuzzFlags != nil {
 			fmt.Fprintf(h, "fuzz %q\n", fuzzFlags)
 		}
 	}
+	if p.Internal.PGOProfile != "" {
+		fmt.Fprintf(h, "pgofile %s\n", b.fileHash(p.Internal.PGOProfile))
+	}
 	fmt.Fprintf(h, "modinfo %q\n", p.Internal.BuildInfo)
 
 	// Configuration specific to compiler toolchain.
@@ -444,6 +451,34 @@ func allowedVersion(v string) bool {
 	return false
 }
 
+// goVersion returns the Go language version of p, such as "go1.18",
+// or "" if p is not in a module or its version is unknown.
+func goVersion(p *load.Package) string {
+	if p.Module == nil {
+		return ""
+	}
+	v := p.Module.GoVersion
+	if v == "" {
+		// We started adding a 'go' directive to the go.mod file unconditionally
+		// as of Go 1.12, so any module that still lacks such a directive must
+		// either have been authored before then, or have a hand-edited go.mod
+		// file that hasn't been updated by cmd/go since that edit.
+		//
+		// Unfortunately, through at least Go 1.16 we didn't add versions to
+		// vendor/modules.txt. So this could also be a vendored 1.16 dependency.
+		//
+		// Fortunately, there were no breaking changes to the language between Go
+		// 1.11 and 1.16, so if we assume Go 1.16 semantics we will not introduce
+		// any spurious errors — we will only mask errors, and not particularly
+		// important ones at that.
+		v = "1.16"
+	}
+	if !allowedVersion(v) {
+		return ""
+	}
+	return "go" + v
+}
+
 const (
 	needBuild uint32 = 1 << iota
 	needCgoHdr
@@ -658,6 +693,16 @@ OverlayLoop:
 				cgofiles[i-len(gofiles)] = coverFile
 			}
 		}
+
+		// For 'go build -cover', add a file registering the coverage
+		// variables, so that the program writes them out when it exits.
+		if cfg.BuildCover && len(a.Package.Internal.CoverVars) > 0 {
+			regFile := objdir + "_coverreg_.go"
+			if err := b.writeFile(regFile, coverRegisterFile(a.Package)); err != nil {
+				return err
+			}
+			gofiles = append(gofiles, regFile)
+		}
 	}
 
 	// Run cgo.
@@ -1032,6 +1077,7 @@ type vetConfig struct {
 	Compiler     string   // compiler name (gc, gccgo)
 	Dir          string   // directory containing package
 	ImportPath   string   // canonical import path ("package path")
+	GoVersion    string   // Go language version of package (example: "go1.18")
 	GoFiles      []string // absolute paths to package source files
 	NonGoFiles   []string // absolute paths to package non-Go files
 	IgnoredFiles []string // absolute paths to ignored source files
@@ -1042,6 +1088,7 @@ type vetConfig struct {
 	PackageVetx map[string]string // map package path to vetx data from earlier vet run
 	VetxOnly    bool              // only compute vetx data; don't report detected problems
 	VetxOutput  string            // write vetx data to this output file
+	FixOutput   string            // write suggested fixes to this output file instead of reporting problems
 
 	SucceedOnTypecheckFailure bool // awful hack; see #18395 and below
 }
@@ -1072,6 +1119,7 @@ func buildVetConfig(a *Action, srcfiles []string) {
 		NonGoFiles:   mkAbsFiles(a.Package.Dir, nongofiles),
 		IgnoredFiles: mkAbsFiles(a.Package.Dir, ignored),
 		ImportPath:   a.Package.ImportPath,
+		GoVersion:    goVersion(a.Package),
 		ImportMap:    make(map[string]string),
 		PackageFile:  make(map[string]string),
 		Standard:     make(map[string]bool),
@@ -1119,6 +1167,13 @@ var VetFlags []string
 // VetExplicit records whether the vet flags were set explicitly on the command line.
 var VetExplicit bool
 
+// VetFix records whether vet actions compute the suggested fixes of
+// the packages being vetted instead of reporting problems. The fixes
+// of each action not run only for its VetxOnly data are left in its
+// FixOutput, as a JSON-encoded list in the format defined by package
+// cmd/internal/analysisfix.
+var VetFix bool
+
 func (b *Builder) vet(ctx context.Context, a *Action) error {
 	// a.Deps[0] is the build of the package being vetted.
 	// a.Deps[1] is the build of the "fmt" package.
@@ -1141,9 +1196,16 @@ func (b *Builder) vet(ctx context.Context, a *Action) error {
 	vcfg.VetxOnly = a.VetxOnly
 	vcfg.VetxOutput = a.Objdir + "vet.out"
 	vcfg.PackageVetx = make(map[string]string)
+	if VetFix && !a.VetxOnly {
+		vcfg.FixOutput = a.Objdir + "vet.fix"
+	}
 
 	h := cache.NewHash("vet " + a.Package.ImportPath)
 	fmt.Fprintf(h, "vet %q\n", b.toolID("vet"))
+	if VetTool != "" {
+		// Different tools compute different vetx data.
+		fmt.Fprintf(h, "vettool %s\n", b.fileHash(VetTool))
+	}
 
 	vetFlags := VetFlags
 
@@ -1239,6 +1301,13 @@ func (b *Builder) vet(ctx context.Context, a *Action) error {
 		f.Close()
 	}
 
+	if vcfg.FixOutput != "" && runErr == nil && !cfg.BuildN {
+		a.FixOutput, err = os.ReadFile(vcfg.FixOutput)
+		if err != nil {
+			return fmt.Errorf("%s does not support suggested fixes: %v", tool, err)
+		}
+	}
+
 	return runErr
 }
 
@@ -1894,6 +1963,29 @@ func (b *Builder) cover(a *Action, dst, src string, varName string) error {
 		src)
 }
 
+// coverRegisterFile returns the source of a file that registers the
+// coverage variables of package p with internal/coverage/rtcov.
+func coverRegisterFile(p *load.Package) []byte {
+	var files []string
+	for file := range p.Internal.CoverVars {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by 'go build -cover'. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", p.Name)
+	fmt.Fprintf(&buf, "import _cover_rtcov_ \"internal/coverage/rtcov\"\n\n")
+	fmt.Fprintf(&buf, "func init() {\n")
+	fmt.Fprintf(&buf, "\t_cover_rtcov_.Register(%q, %q, []_cover_rtcov_.File{\n", p.ImportPath, p.Internal.CoverMode)
+	for _, file := range files {
+		v := p.Internal.CoverVars[file]
+		fmt.Fprintf(&buf, "\t\t{Name: %q, Counts: %s.Count[:], Pos: %s.Pos[:], NumStmt: %s.NumStmt[:]},\n", v.File, v.Var, v.Var, v.Var)
+	}
+	fmt.Fprintf(&buf, "\t})\n}\n")
+	return buf.Bytes()
+}
+
 var objectMagic = [][]byte{
 	{'!', '<', 'a', 'r', 'c', 'h', '>', '\n'}, // Package archive
 	{'<', 'b', 'i', 'g', 'a', 'f', '>', '\n'}, // Package AIX big archive
@@ -23,6 +23,7 @@ func BuildInit() {
 	modload.Init()
 	instrumentInit()
 	buildModeInit()
+	coverInit()
 	if err := fsys.Init(base.Cwd()); err != nil {
 		base.Fatalf("go: %v", err)
 	}
@@ -60,6 +61,23 @@ func BuildInit() {
 	}
 }
 
+// coverInit checks the -cover flags and sets the default coverage mode.
+func coverInit() {
+	if !cfg.BuildCover {
+		return
+	}
+	if cfg.BuildCoverMode == "" {
+		cfg.BuildCoverMode = "set"
+		if cfg.BuildRace {
+			// Default coverage mode is atomic when -race is set.
+			cfg.BuildCoverMode = "atomic"
+		}
+	}
+	if cfg.BuildRace && cfg.BuildCoverMode != "atomic" {
+		base.Fatalf(`-covermode must be "atomic", not %q, when -race is enabled`, cfg.BuildCoverMode)
+	}
+}
+
 // fuzzInstrumentFlags returns compiler flags that enable fuzzing instrumation
 // on supported platforms.
 //
@@ -57,6 +57,10 @@ editing flags may be repeated, and the changes are applied in the order given.
 
 The -go=version flag sets the expected Go language version.
 
+The -toolchain=name flag sets the Go toolchain to use.
+The -toolchain=none flag drops the toolchain line.
+See 'go help toolchain'.
+
 The -print flag prints the final go.work in its text format instead of
 writing it back to go.mod.
 
@@ -64,9 +68,10 @@ The -json flag prints the final go.work file in JSON format instead of
 writing it back to go.mod. The JSON output corresponds to these Go types:
 
 	type GoWork struct {
-		Go      string
-		Use     []Use
-		Replace []Replace
+		Go        string
+		Toolchain string
+		Use       []Use
+		Replace   []Replace
 	}
 
 	type Use struct {
@@ -90,11 +95,12 @@ for more information.
 }
 
 var (
-	editFmt   = cmdEdit.Flag.Bool("fmt", false, "")
-	editGo    = cmdEdit.Flag.String("go", "", "")
-	editJSON  = cmdEdit.Flag.Bool("json", false, "")
-	editPrint = cmdEdit.Flag.Bool("print", false, "")
-	workedits []func(file *modfile.WorkFile) // edits specified in flags
+	editFmt       = cmdEdit.Flag.Bool("fmt", false, "")
+	editGo        = cmdEdit.Flag.String("go", "", "")
+	editToolchain = cmdEdit.Flag.String("toolchain", "", "")
+	editJSON      = cmdEdit.Flag.Bool("json", false, "")
+	editPrint     = cmdEdit.Flag.Bool("print", false, "")
+	workedits     []func(file *modfile.WorkFile) // edits specified in flags
 )
 
 type flagFunc func(string)
@@ -133,12 +139,19 @@ func runEditwork(ctx context.Context, cmd *base.Command, args []string) {
 		}
 	}
 
+	if *editToolchain != "" && *editToolchain != "none" {
+		if !modfile.ToolchainRE.MatchString(*editToolchain) {
+			base.Fatalf(`go: invalid -toolchain option; expecting something like "-toolchain go%s"`, modload.LatestGoVersion())
+		}
+	}
+
 	if gowork == "" {
 		base.Fatalf("go: no go.work file found\n\t(run 'go work init' first or specify path using GOWORK environment variable)")
 	}
 
 	anyFlags :=
 		*editGo != "" ||
+			*editToolchain != "" ||
 			*editJSON ||
 			*editPrint ||
 			*editFmt ||
@@ -159,6 +172,14 @@ func runEditwork(ctx context.Context, cmd *base.Command, args []string) {
 		}
 	}
 
+	if *editToolchain == "none" {
+		workFile.DropToolchainStmt()
+	} else if *editToolchain != "" {
+		if err := workFile.AddToolchainStmt(*editToolchain); err != nil {
+			base.Fatalf("go: internal error: %v", err)
+		}
+	}
+
 	if len(workedits) > 0 {
 		for _, edit := range workedits {
 			edit(workFile)
@@ -288,6 +309,9 @@ func editPrintJSON(workFile *modfile.WorkFile) {
 	if workFile.Go != nil {
 		f.Go = workFile.Go.Version
 	}
+	if workFile.Toolchain != nil {
+		f.Toolchain = workFile.Toolchain.Name
+	}
 	for _, d := range workFile.Use {
 		f.Use = append(f.Use, useJSON{DiskPath: d.Path, ModPath: d.ModulePath})
 	}
@@ -305,9 +329,10 @@ func editPrintJSON(workFile *modfile.WorkFile) {
 
 // workfileJSON is the -json output data structure.
 type workfileJSON struct {
-	Go      string `json:",omitempty"`
-	Use     []useJSON
-	Replace []replaceJSON
+	Go        string `json:",omitempty"`
+	Toolchain string `json:",omitempty"`
+	Use       []useJSON
+	Replace   []replaceJSON
 }
 
 type useJSON struct {
@@ -57,7 +57,11 @@ containing the module's go.mod file.
 The go directive specifies the version of Go the file was written at. It
 is possible there may be future changes in the semantics of workspaces
 that could be controlled by this version, but for now the version
-specified has no effect.
+specified has no effect, except that the go command may run a newer
+Go toolchain if the version is newer than its own.
+
+The toolchain directive names the Go toolchain to use in the workspace.
+See 'go help toolchain'.
 
 The replace directive has the same syntax as the replace directive in a
 go.mod file and takes precedence over replaces in go.mod files.  It is
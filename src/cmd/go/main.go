@@ -26,6 +26,7 @@ import (
 	"cmd/go/internal/envcmd"
 	"cmd/go/internal/fix"
 	"cmd/go/internal/fmtcmd"
+	"cmd/go/internal/fuzzcmd"
 	"cmd/go/internal/generate"
 	"cmd/go/internal/get"
 	"cmd/go/internal/help"
@@ -37,6 +38,7 @@ import (
 	"cmd/go/internal/run"
 	"cmd/go/internal/test"
 	"cmd/go/internal/tool"
+	"cmd/go/internal/toolchain"
 	"cmd/go/internal/trace"
 	"cmd/go/internal/version"
 	"cmd/go/internal/vet"
@@ -52,6 +54,7 @@ func init() {
 		envcmd.CmdEnv,
 		fix.CmdFix,
 		fmtcmd.CmdFmt,
+		fuzzcmd.CmdFuzzcache,
 		generate.CmdGenerate,
 		modget.CmdGet,
 		work.CmdInstall,
@@ -82,6 +85,7 @@ func init() {
 		modfetch.HelpPrivate,
 		test.HelpTestflag,
 		test.HelpTestfunc,
+		toolchain.HelpToolchain,
 		modget.HelpVCS,
 	}
 }
@@ -147,6 +151,9 @@ func main() {
 		os.Exit(2)
 	}
 
+	// Run the toolchain required by the main module, if not this one.
+	toolchain.Select(args)
+
 BigCmdLoop:
 	for bigCmd := base.Go; ; {
 		for _, cmd := range bigCmd.Commands {
@@ -0,0 +1,365 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package analysisfix implements the driver of a vet-like analysis
+// tool that can report the suggested fixes of its analyzers.
+//
+// The tool is invoked by the go command, one package at a time, with
+// the vet configuration file of the package as its only argument.
+// If the configuration names a FixOutput file, the tool runs those of
+// its analyzers that do not depend on analysis facts and writes the
+// suggested fixes of their diagnostics, as a JSON-encoded []Fix, to
+// that file. Otherwise it behaves exactly like unitchecker.Main.
+package analysisfix
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/unitchecker"
+)
+
+// A Fix is a suggested fix of a diagnostic.
+type Fix struct {
+	Analyzer string // name of the analyzer reporting the diagnostic
+	Posn     string // position of the diagnostic, as file:line:col
+	Message  string // message of the fix
+	Edits    []Edit
+}
+
+// An Edit replaces the bytes [Offset, End) of a file with New.
+type Edit struct {
+	Filename    string
+	Offset, End int
+	New         string
+}
+
+// GoVersion is an analyzer whose result is the Go language version of
+// the package being analyzed, such as "go1.18", or "" if it is unknown.
+// Analyzers that rewrite code to newer idioms require it to find out
+// whether the idioms are available.
+var GoVersion = &analysis.Analyzer{
+	Name:       "goversion",
+	Doc:        "report the Go language version of the package",
+	Run:        func(*analysis.Pass) (interface{}, error) { return "", nil },
+	ResultType: reflect.TypeOf(""),
+}
+
+// config is the subset of the vet configuration used in fix mode.
+// See unitchecker.Config and cmd/go/internal/work.vetConfig.
+type config struct {
+	ID                        string
+	Compiler                  string
+	ImportPath                string
+	GoVersion                 string
+	GoFiles                   []string
+	NonGoFiles                []string
+	IgnoredFiles              []string
+	ImportMap                 map[string]string
+	PackageFile               map[string]string
+	Standard                  map[string]bool
+	VetxOnly                  bool
+	FixOutput                 string
+	SucceedOnTypecheckFailure bool
+}
+
+// Main is the main function of an analysis tool that is invoked by
+// the go command to analyze, or to compute the fixes of, a single
+// package. It does not return.
+func Main(analyzers ...*analysis.Analyzer) {
+	args := os.Args[1:]
+	if len(args) == 0 || !strings.HasSuffix(args[len(args)-1], ".cfg") {
+		unitchecker.Main(analyzers...)
+		os.Exit(0) // unreachable
+	}
+	cfg, err := readConfig(args[len(args)-1])
+	if err != nil {
+		log.SetFlags(0)
+		log.Fatalf("%s: %v", filepath.Base(os.Args[0]), err)
+	}
+	if cfg.FixOutput == "" || cfg.VetxOnly {
+		unitchecker.Main(analyzers...)
+		os.Exit(0) // unreachable
+	}
+
+	log.SetFlags(0)
+	log.SetPrefix(filepath.Base(os.Args[0]) + ": ")
+	if err := analysis.Validate(analyzers); err != nil {
+		log.Fatal(err)
+	}
+	analyzers = parseFlags(analyzers)
+
+	fset := token.NewFileSet()
+	fixes, err := run(fset, cfg, analyzers)
+	if err != nil {
+		if cfg.SucceedOnTypecheckFailure {
+			// Let the compiler report the errors.
+			fixes, err = nil, nil
+		} else {
+			log.Fatal(err)
+		}
+	}
+	data, err := json.MarshalIndent(fixes, "", "\t")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(cfg.FixOutput, data, 0666); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(0)
+}
+
+// parseFlags parses the command line flags understood in fix mode:
+// the -NAME flags enabling or disabling each analyzer and the
+// -NAME.FLAG flags of the analyzers, as in unitchecker.Main.
+// It returns the analyzers to run.
+func parseFlags(analyzers []*analysis.Analyzer) []*analysis.Analyzer {
+	enable := make(map[*analysis.Analyzer]*bool)
+	for _, a := range analyzers {
+		enable[a] = flag.Bool(a.Name, false, "enable "+a.Name+" analysis")
+		prefix := a.Name + "."
+		a.Flags.VisitAll(func(f *flag.Flag) {
+			flag.Var(f.Value, prefix+f.Name, f.Usage)
+		})
+	}
+	flag.Parse()
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	// If any -NAME flag is true, run only those analyzers. Otherwise,
+	// if any -NAME flag is false, run all but those analyzers.
+	var hasTrue, hasFalse bool
+	for _, a := range analyzers {
+		if set[a.Name] {
+			if *enable[a] {
+				hasTrue = true
+			} else {
+				hasFalse = true
+			}
+		}
+	}
+	if !hasTrue && !hasFalse {
+		return analyzers
+	}
+	var keep []*analysis.Analyzer
+	for _, a := range analyzers {
+		if hasTrue && *enable[a] || !hasTrue && !set[a.Name] {
+			keep = append(keep, a)
+		}
+	}
+	return keep
+}
+
+func readConfig(filename string) (*config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	cfg := new(config)
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("cannot decode JSON config file %s: %v", filename, err)
+	}
+	if len(cfg.GoFiles) == 0 {
+		return nil, fmt.Errorf("package has no files: %s", cfg.ImportPath)
+	}
+	return cfg, nil
+}
+
+// run loads and type-checks the package described by cfg and returns
+// the fixes suggested by analyzers.
+func run(fset *token.FileSet, cfg *config, analyzers []*analysis.Analyzer) ([]Fix, error) {
+	var files []*ast.File
+	for _, name := range cfg.GoFiles {
+		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	compilerImporter := importer.ForCompiler(fset, cfg.Compiler, func(path string) (io.ReadCloser, error) {
+		// path is a resolved package path, not an import path.
+		file, ok := cfg.PackageFile[path]
+		if !ok {
+			if cfg.Compiler == "gccgo" && cfg.Standard[path] {
+				return nil, nil // fall back to default gccgo lookup
+			}
+			return nil, fmt.Errorf("no package file for %q", path)
+		}
+		return os.Open(file)
+	})
+	tc := &types.Config{
+		Importer: importerFunc(func(importPath string) (*types.Package, error) {
+			path, ok := cfg.ImportMap[importPath] // resolve vendoring, etc
+			if !ok {
+				return nil, fmt.Errorf("can't resolve import %q", importPath)
+			}
+			return compilerImporter.Import(path)
+		}),
+		Sizes:     types.SizesFor("gc", build.Default.GOARCH),
+		GoVersion: cfg.GoVersion,
+	}
+	info := NewInfo()
+	pkg, err := tc.Check(cfg.ImportPath, fset, files, info)
+	if err != nil {
+		return nil, err
+	}
+	pass := &analysis.Pass{
+		Fset:         fset,
+		Files:        files,
+		OtherFiles:   cfg.NonGoFiles,
+		IgnoredFiles: cfg.IgnoredFiles,
+		Pkg:          pkg,
+		TypesInfo:    info,
+		TypesSizes:   tc.Sizes,
+	}
+	return Fixes(pass, cfg.GoVersion, analyzers)
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// NewInfo returns a types.Info recording all the information that
+// analyzers may use.
+func NewInfo() *types.Info {
+	return &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Instances:  make(map[*ast.Ident]types.Instance),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Scopes:     make(map[ast.Node]*types.Scope),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+}
+
+// Fixes runs analyzers, and the analyzers they require, on the
+// type-checked package described by pkg, a partially filled in pass
+// of which only the fields describing the package are used. goVersion
+// is the result of the GoVersion analyzer.
+//
+// Analyzers depending on analysis facts are not run. Fixes returns
+// the first suggested fix of each diagnostic reported by the other
+// analyzers, in the order of the diagnostics' positions.
+func Fixes(pkg *analysis.Pass, goVersion string, analyzers []*analysis.Analyzer) ([]Fix, error) {
+	type action struct {
+		result interface{}
+		err    error
+		diags  []analysis.Diagnostic
+	}
+	actions := make(map[*analysis.Analyzer]*action)
+	var exec func(a *analysis.Analyzer) *action
+	exec = func(a *analysis.Analyzer) *action {
+		if act := actions[a]; act != nil {
+			return act
+		}
+		act := new(action)
+		actions[a] = act
+		if a == GoVersion {
+			act.result = goVersion
+			return act
+		}
+		inputs := make(map[*analysis.Analyzer]interface{})
+		for _, req := range a.Requires {
+			reqact := exec(req)
+			if reqact.err != nil {
+				act.err = fmt.Errorf("failed prerequisite %s: %v", req, reqact.err)
+				return act
+			}
+			inputs[req] = reqact.result
+		}
+		pass := *pkg
+		pass.Analyzer = a
+		pass.ResultOf = inputs
+		pass.Report = func(d analysis.Diagnostic) { act.diags = append(act.diags, d) }
+		pass.ImportObjectFact = func(types.Object, analysis.Fact) bool { return false }
+		pass.ExportObjectFact = func(types.Object, analysis.Fact) {}
+		pass.AllObjectFacts = func() []analysis.ObjectFact { return nil }
+		pass.ImportPackageFact = func(*types.Package, analysis.Fact) bool { return false }
+		pass.ExportPackageFact = func(analysis.Fact) {}
+		pass.AllPackageFacts = func() []analysis.PackageFact { return nil }
+		act.result, act.err = a.Run(&pass)
+		return act
+	}
+
+	var usesFacts func(a *analysis.Analyzer) bool
+	usesFacts = func(a *analysis.Analyzer) bool {
+		if len(a.FactTypes) > 0 {
+			return true
+		}
+		for _, req := range a.Requires {
+			if usesFacts(req) {
+				return true
+			}
+		}
+		return false
+	}
+
+	type diag struct {
+		a *analysis.Analyzer
+		d analysis.Diagnostic
+	}
+	var diags []diag
+	for _, a := range analyzers {
+		if usesFacts(a) {
+			continue
+		}
+		act := exec(a)
+		if act.err != nil {
+			return nil, fmt.Errorf("%s: %v", a, act.err)
+		}
+		for _, d := range act.diags {
+			diags = append(diags, diag{a, d})
+		}
+	}
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		return diags[i].d.Pos < diags[j].d.Pos
+	})
+	var fixes []Fix
+	for _, d := range diags {
+		if len(d.d.SuggestedFixes) == 0 {
+			continue
+		}
+		sf := d.d.SuggestedFixes[0]
+		fix := Fix{
+			Analyzer: d.a.Name,
+			Posn:     pkg.Fset.Position(d.d.Pos).String(),
+			Message:  sf.Message,
+		}
+		for _, e := range sf.TextEdits {
+			end := e.End
+			if !end.IsValid() {
+				end = e.Pos
+			}
+			file := pkg.Fset.File(e.Pos)
+			if file == nil || pkg.Fset.File(end) != file || end < e.Pos {
+				return nil, fmt.Errorf("%s: invalid edit in fix %q", fix.Posn, fix.Message)
+			}
+			fix.Edits = append(fix.Edits, Edit{
+				Filename: file.Name(),
+				Offset:   file.Offset(e.Pos),
+				End:      file.Offset(end),
+				New:      string(e.NewText),
+			})
+		}
+		fixes = append(fixes, fix)
+	}
+	return fixes, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysisfix
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+type fact struct{}
+
+func (*fact) AFact() {}
+
+// intAnalyzer reports each integer literal, suggesting to replace it
+// by the Go version of the package if it is 0.
+var intAnalyzer = &analysis.Analyzer{
+	Name:     "int",
+	Doc:      "test analyzer",
+	Requires: []*analysis.Analyzer{GoVersion},
+	Run: func(pass *analysis.Pass) (interface{}, error) {
+		version := pass.ResultOf[GoVersion].(string)
+		ast.Inspect(pass.Files[0], func(n ast.Node) bool {
+			if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.INT {
+				d := analysis.Diagnostic{Pos: lit.Pos(), Message: "int"}
+				if lit.Value == "0" {
+					d.SuggestedFixes = []analysis.SuggestedFix{{
+						Message:   "replace",
+						TextEdits: []analysis.TextEdit{{Pos: lit.Pos(), End: lit.End(), NewText: []byte(`"` + version + `"`)}},
+					}}
+				}
+				pass.Report(d)
+			}
+			return true
+		})
+		return nil, nil
+	},
+}
+
+var factAnalyzer = &analysis.Analyzer{
+	Name:      "fact",
+	Doc:       "test analyzer using facts",
+	FactTypes: []analysis.Fact{new(fact)},
+	Run: func(pass *analysis.Pass) (interface{}, error) {
+		panic("analyzer using facts run in fix mode")
+	},
+}
+
+func TestFixes(t *testing.T) {
+	const src = `package p
+
+var x, y, z = 0, 1, 0
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf types.Config
+	info := NewInfo()
+	pkg, err := conf.Check("p", fset, []*ast.File{f}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pass := &analysis.Pass{Fset: fset, Files: []*ast.File{f}, Pkg: pkg, TypesInfo: info}
+	fixes, err := Fixes(pass, "go1.18", []*analysis.Analyzer{factAnalyzer, intAnalyzer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Fix{
+		{Analyzer: "int", Posn: "p.go:3:15", Message: "replace", Edits: []Edit{{Filename: "p.go", Offset: 25, End: 26, New: `"go1.18"`}}},
+		{Analyzer: "int", Posn: "p.go:3:21", Message: "replace", Edits: []Edit{{Filename: "p.go", Offset: 31, End: 32, New: `"go1.18"`}}},
+	}
+	if !reflect.DeepEqual(fixes, want) {
+		t.Errorf("Fixes() = %+v, want %+v", fixes, want)
+	}
+}
@@ -31,10 +31,11 @@ const (
 type event struct {
 	Time    *time.Time `json:",omitempty"`
 	Action  string
-	Package string     `json:",omitempty"`
-	Test    string     `json:",omitempty"`
-	Elapsed *float64   `json:",omitempty"`
-	Output  *textBytes `json:",omitempty"`
+	Package string          `json:",omitempty"`
+	Test    string          `json:",omitempty"`
+	Elapsed *float64        `json:",omitempty"`
+	Output  *textBytes      `json:",omitempty"`
+	Fuzz    json.RawMessage `json:",omitempty"`
 }
 
 // textBytes is a hack to get JSON to emit a []byte as a string
@@ -160,6 +161,11 @@ var (
 		[]byte("--- BENCH: "),
 	}
 
+	// printed by a fuzz test, followed by its name, when fuzzing starts;
+	// when run with -test.fuzzjson, also printed followed by its name
+	// and a JSON object describing each fuzzing event.
+	fuzzEvent = []byte("=== FUZZ  ")
+
 	fourSpace = []byte("    ")
 
 	skipLinePrefix = []byte("?   \t")
@@ -188,6 +194,24 @@ func (c *Converter) handleInputLine(line []byte) {
 		c.result = "skip"
 	}
 
+	// "=== FUZZ  FuzzName {...}"
+	// "=== FUZZ  FuzzName"
+	if bytes.HasPrefix(line, fuzzEvent) {
+		rest := line[len(fuzzEvent):]
+		if name, data, ok := parseFuzzEvent(rest); ok {
+			c.writeEvent(&event{Action: "fuzz", Test: name, Fuzz: data})
+			return
+		}
+		if name := strings.TrimSuffix(string(rest), "\n"); name != "" && !strings.Contains(name, " ") {
+			// Fuzzing has started; the fuzz test
+			// produces the output that follows.
+			c.flushReport(0)
+			c.testName = name
+		}
+		c.output.write(line)
+		return
+	}
+
 	// "=== RUN   "
 	// "=== PAUSE "
 	// "=== CONT  "
@@ -294,6 +318,23 @@ func (c *Converter) handleInputLine(line []byte) {
 	return
 }
 
+// parseFuzzEvent parses the "FuzzName {...}\n" remainder of a fuzz event
+// line, returning the test name and the JSON object describing the event.
+// Other "=== FUZZ  " lines, such as the one printed when fuzzing starts,
+// are not events.
+func parseFuzzEvent(line []byte) (name string, data json.RawMessage, ok bool) {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	i := bytes.IndexByte(line, ' ')
+	if i < 0 {
+		return "", nil, false
+	}
+	obj := line[i+1:]
+	if len(obj) == 0 || obj[0] != '{' || !json.Valid(obj) {
+		return "", nil, false
+	}
+	return string(line[:i]), json.RawMessage(obj), true
+}
+
 // flushReport flushes all pending PASS/FAIL reports at levels >= depth.
 func (c *Converter) flushReport(depth int) {
 	c.testName = ""
@@ -21,6 +21,8 @@
 // The test must be invoked with -test.v. Additionally passing
 // -test.paniconexit0 will cause test2json to exit with a non-zero
 // status if one of the tests being run calls os.Exit(0).
+// When fuzzing with -test.fuzz, additionally passing -test.fuzzjson
+// causes the fuzz test to report fuzzing events, as described below.
 //
 // Note that test2json is only intended for converting a single test
 // binary's output. To convert the output of a "go test" command,
@@ -38,6 +40,7 @@
 //		Test    string
 //		Elapsed float64 // seconds
 //		Output  string
+//		Fuzz    *FuzzEvent
 //	}
 //
 // The Time field holds the time the event happened.
@@ -53,6 +56,7 @@
 //	fail   - the test or benchmark failed
 //	output - the test printed output
 //	skip   - the test was skipped or the package contained no tests
+//	fuzz   - the fuzz test reported a fuzzing event
 //
 // The Package field, if present, specifies the package being tested.
 // When the go command runs parallel tests in -json mode, events from
@@ -81,6 +85,40 @@
 // by a final event with Action == "bench" or "fail".
 // Benchmarks have no events with Action == "run", "pause", or "cont".
 //
+// When a fuzz test fuzzes, as with 'go test -fuzz -json', it reports
+// its progress in events with Action == "fuzz" and Test set to the fuzz
+// test, along with the output describing the same progress for people.
+// The Fuzz field of such an event describes it, and corresponds to
+// the Go struct:
+//
+//	type FuzzEvent struct {
+//		Kind    string
+//		Elapsed float64 // seconds since fuzzing started
+//		Execs   int64   // calls of the fuzz function so far
+//		New     int     // new interesting inputs found so far
+//		Corpus  int     // inputs in the corpus, including new ones
+//		Workers int     // number of fuzzing processes
+//		Input   string  // file holding the input
+//		Size    int     // size of the input, in bytes
+//	}
+//
+// The Kind field is one of:
+//
+//	baseline - the seed corpus and the cached inputs have been run,
+//	           gathering baseline coverage, and fuzzing starts with
+//	           Workers processes
+//	new      - the fuzzer found an interesting input, which expands
+//	           coverage; Input is the file in the fuzzing cache holding it
+//	minimize - the fuzzer found a failing input of Size bytes, and
+//	           starts minimizing it
+//	crash    - the fuzzer found a failing input, written to Input;
+//	           or Input is a seed corpus entry, named FuzzTest/name,
+//	           which failed
+//	stats    - a periodic report of the counts, also made when fuzzing
+//	           stops
+//
+// Fields not meaningful for the Kind of an event are omitted.
+//
 package main
 
 import (
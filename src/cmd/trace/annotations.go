@@ -274,21 +274,13 @@ type regionTypeID struct {
 // analyzeAnnotations analyzes user annotation events and
 // returns the task descriptors keyed by internal task id.
 func analyzeAnnotations() (annotationAnalysisResult, error) {
-	res, err := parseTrace()
-	if err != nil {
-		return annotationAnalysisResult{}, fmt.Errorf("failed to parse trace: %v", err)
-	}
-
-	events := res.Events
-	if len(events) == 0 {
-		return annotationAnalysisResult{}, fmt.Errorf("empty trace")
-	}
-
 	tasks := allTasks{}
 	regions := map[regionTypeID][]regionDesc{}
 	var gcEvents []*trace.Event
 
-	for _, ev := range events {
+	n := 0
+	err := readTrace(func(ev *trace.Event) {
+		n++
 		switch typ := ev.Type; typ {
 		case trace.EvUserTaskCreate, trace.EvUserTaskEnd, trace.EvUserLog:
 			taskid := ev.Args[0]
@@ -309,9 +301,17 @@ func analyzeAnnotations() (annotationAnalysisResult, error) {
 		case trace.EvGCStart:
 			gcEvents = append(gcEvents, ev)
 		}
+	})
+	if err != nil {
+		return annotationAnalysisResult{}, err
+	}
+	if n == 0 {
+		return annotationAnalysisResult{}, fmt.Errorf("empty trace")
 	}
 	// combine region info.
-	analyzeGoroutines(events)
+	if err := analyzeGoroutines(); err != nil {
+		return annotationAnalysisResult{}, err
+	}
 	for goid, stats := range gs {
 		// gs is a global var defined in goroutines.go as a result
 		// of analyzeGoroutines. TODO(hyangah): fix this not to depend
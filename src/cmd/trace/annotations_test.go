@@ -13,6 +13,7 @@ import (
 	"fmt"
 	traceparser "internal/trace"
 	"os"
+	"path/filepath"
 	"reflect"
 	"runtime/debug"
 	"runtime/trace"
@@ -324,8 +325,8 @@ func TestAnalyzeAnnotationGC(t *testing.T) {
 }
 
 // traceProgram runs the provided function while tracing is enabled,
-// parses the captured trace, and sets the global trace loader to
-// point to the parsed trace.
+// checks that the captured trace parses, and points the tool at a
+// file holding it for the rest of the test.
 //
 // If savetraces flag is set, the captured trace will be saved in the named file.
 func traceProgram(t *testing.T, f func(), name string) error {
@@ -338,14 +339,18 @@ func traceProgram(t *testing.T, f func(), name string) error {
 	trace.Stop()
 
 	saveTrace(buf, name)
-	res, err := traceparser.Parse(buf, name+".faketrace")
+	file := filepath.Join(t.TempDir(), name+".trace")
+	if err := os.WriteFile(file, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	_, err := traceparser.Parse(buf, name+".faketrace")
 	if err == traceparser.ErrTimeOrder {
 		t.Skipf("skipping due to golang.org/issue/16755: %v", err)
 	} else if err != nil {
 		return err
 	}
 
-	swapLoaderData(res, err)
+	setTraceFile(t, file)
 	return nil
 }
 
@@ -370,16 +375,24 @@ func childrenNames(task *taskDesc) (ret []string) {
 	return ret
 }
 
-func swapLoaderData(res traceparser.ParseResult, err error) {
-	// swap loader's data.
-	parseTrace() // fool loader.once.
+// setTraceFile points the tool at the trace file until the end of the test,
+// dropping the results of the analyses of the previous trace file.
+func setTraceFile(t *testing.T, file string) {
+	old := traceFile
+	t.Cleanup(func() { traceFile = old })
+	traceFile = file
 
-	loader.res = res
-	loader.err = err
+	loader.once = sync.Once{}
+	loader.res = traceparser.ParseResult{}
+	loader.err = nil
 
-	analyzeGoroutines(nil) // fool gsInit once.
-	gs = traceparser.GoroutineStats(res.Events)
+	gsInit = sync.Once{}
+	gs = nil
+	gsErr = nil
 
+	mmuCache.lock.Lock()
+	mmuCache.m = make(map[traceparser.UtilFlags]*mmuCacheEntry)
+	mmuCache.lock.Unlock()
 }
 
 func saveTrace(buf *bytes.Buffer, name string) {
@@ -27,6 +27,9 @@ Supported profile types are:
 Then, you can use the pprof tool to analyze the profile:
 	go tool pprof TYPE.pprof
 
+Traces produced by Go 1.19 and later are read incrementally, one
+generation at a time, so they need not fit in memory.
+
 Note that while the various profiles available when launching
 'go tool trace' work on every browser, the trace viewer itself
 (the 'view trace' page) comes from the Chrome/Chromium project
@@ -35,23 +35,63 @@ type gtype struct {
 var (
 	gsInit sync.Once
 	gs     map[uint64]*trace.GDesc
+	gsErr  error
+
+	// The timestamps of the first and last events of the trace.
+	traceStart, traceEnd int64
 )
 
 // analyzeGoroutines generates statistics about execution of all goroutines and stores them in gs.
-func analyzeGoroutines(events []*trace.Event) {
+// It also records the timestamps of the first and last events of the trace.
+func analyzeGoroutines() error {
 	gsInit.Do(func() {
-		gs = trace.GoroutineStats(events)
+		b := trace.NewGoroutineStatsBuilder()
+		n := 0
+		gsErr = readTrace(func(ev *trace.Event) {
+			if n == 0 {
+				traceStart = ev.Ts
+			}
+			traceEnd = ev.Ts
+			n++
+			b.Event(ev)
+		})
+		gs = b.Finalize()
 	})
+	return gsErr
+}
+
+// relatedGoroutines finds a set of goroutines related to goroutine goid,
+// like trace.RelatedGoroutines, reading the trace once for each level of
+// the search rather than holding its events in memory.
+func relatedGoroutines(goid uint64) (map[uint64]bool, error) {
+	// BFS of depth 2 over "unblock" edges
+	// (what goroutines unblock goroutine goid?).
+	gmap := map[uint64]bool{goid: true}
+	for i := 0; i < 2; i++ {
+		gmap1 := make(map[uint64]bool)
+		for g := range gmap {
+			gmap1[g] = true
+		}
+		err := readTrace(func(ev *trace.Event) {
+			if ev.Type == trace.EvGoUnblock && gmap[ev.Args[0]] {
+				gmap1[ev.G] = true
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		gmap = gmap1
+	}
+	gmap[0] = true // for GC events
+	return gmap, nil
 }
 
 // httpGoroutines serves list of goroutine groups.
 func httpGoroutines(w http.ResponseWriter, r *http.Request) {
-	events, err := parseEvents()
-	if err != nil {
+	if err := analyzeGoroutines(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	analyzeGoroutines(events)
 	gss := make(map[uint64]gtype)
 	for _, g := range gs {
 		gs1 := gss[g.PC]
@@ -89,8 +129,7 @@ Goroutines: <br>
 func httpGoroutine(w http.ResponseWriter, r *http.Request) {
 	// TODO(hyangah): support format=csv (raw data)
 
-	events, err := parseEvents()
-	if err != nil {
+	if err := analyzeGoroutines(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -100,7 +139,6 @@ func httpGoroutine(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("failed to parse id parameter '%v': %v", r.FormValue("id"), err), http.StatusInternalServerError)
 		return
 	}
-	analyzeGoroutines(events)
 	var (
 		glist                   []*trace.GDesc
 		name                    string
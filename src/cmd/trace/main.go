@@ -48,6 +48,9 @@ Flags:
 	-pprof=type: print a pprof-like profile instead
 	-d: print debug info such as parsed events
 
+Traces produced by Go 1.19 and later are read incrementally, one
+generation at a time, so they need not fit in memory.
+
 Note that while the various profiles available when launching
 'go tool trace' work on every browser, the trace viewer itself
 (the 'view trace' page) comes from the Chrome/Chromium project
@@ -109,22 +112,35 @@ func main() {
 		dief("failed to create server socket: %v\n", err)
 	}
 
-	log.Print("Parsing trace...")
-	res, err := parseTrace()
-	if err != nil {
-		dief("%v\n", err)
-	}
-
 	if *debugFlag {
-		trace.Print(res.Events)
+		if err := readTrace(trace.PrintEvent); err != nil {
+			dief("%v\n", err)
+		}
 		os.Exit(0)
 	}
-	reportMemoryUsage("after parsing trace")
-	debug.FreeOSMemory()
 
-	log.Print("Splitting trace...")
-	ranges = splitTrace(res)
-	reportMemoryUsage("after spliting trace")
+	if streamable() {
+		// Traces partitioned into generations are never loaded at once
+		// to view them, so that they may be larger than the memory.
+		log.Print("Indexing trace...")
+		ranges, err = indexTrace()
+		if err != nil {
+			dief("%v\n", err)
+		}
+		reportMemoryUsage("after indexing trace")
+	} else {
+		log.Print("Parsing trace...")
+		res, err := parseTrace()
+		if err != nil {
+			dief("%v\n", err)
+		}
+		reportMemoryUsage("after parsing trace")
+		debug.FreeOSMemory()
+
+		log.Print("Splitting trace...")
+		ranges = splitTrace(res)
+		reportMemoryUsage("after spliting trace")
+	}
 	debug.FreeOSMemory()
 
 	addr := "http://" + ln.Addr().String()
@@ -145,16 +161,6 @@ var loader struct {
 	err  error
 }
 
-// parseEvents is a compatibility wrapper that returns only
-// the Events part of trace.ParseResult returned by parseTrace.
-func parseEvents() ([]*trace.Event, error) {
-	res, err := parseTrace()
-	if err != nil {
-		return nil, err
-	}
-	return res.Events, err
-}
-
 func parseTrace() (trace.ParseResult, error) {
 	loader.once.Do(func() {
 		tracef, err := os.Open(traceFile)
@@ -175,6 +181,52 @@ func parseTrace() (trace.ParseResult, error) {
 	return loader.res, loader.err
 }
 
+// streamable reports whether the trace file can be read incrementally.
+func streamable() bool {
+	tracef, err := os.Open(traceFile)
+	if err != nil {
+		return false
+	}
+	defer tracef.Close()
+	return trace.IsStreamable(bufio.NewReader(tracef))
+}
+
+// openTrace returns a reader for the events of the trace file.
+// The returned file must be closed by the caller.
+func openTrace() (*os.File, *trace.Reader, error) {
+	tracef, err := os.Open(traceFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open trace file: %v", err)
+	}
+	r, err := trace.NewReader(bufio.NewReader(tracef))
+	if err != nil {
+		tracef.Close()
+		return nil, nil, fmt.Errorf("failed to parse trace: %v", err)
+	}
+	return tracef, r, nil
+}
+
+// readTrace calls f for each event of the trace file, in order.
+// Traces produced by Go 1.19 and later are decoded one generation
+// at a time, so that they are not held in memory at once.
+func readTrace(f func(ev *trace.Event)) error {
+	tracef, r, err := openTrace()
+	if err != nil {
+		return err
+	}
+	defer tracef.Close()
+	for {
+		ev, err := r.ReadEvent()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse trace: %v", err)
+		}
+		f(ev)
+	}
+}
+
 // httpMain serves the starting page.
 func httpMain(w http.ResponseWriter, r *http.Request) {
 	if err := templMain.Execute(w, ranges); err != nil {
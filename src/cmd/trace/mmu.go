@@ -83,11 +83,11 @@ func getMMUCurve(r *http.Request) ([][]trace.MutatorUtil, *trace.MMUCurve, error
 	mmuCache.lock.Unlock()
 
 	c.init.Do(func() {
-		events, err := parseEvents()
-		if err != nil {
+		b := trace.NewMutatorUtilizationBuilder(flags)
+		if err := readTrace(b.Event); err != nil {
 			c.err = err
 		} else {
-			c.util = trace.MutatorUtilization(events, flags)
+			c.util = b.Finalize()
 			c.mmuCurve = trace.NewMMUCurve(c.util)
 		}
 	})
@@ -59,22 +59,18 @@ type interval struct {
 	begin, end int64 // nanoseconds.
 }
 
-func pprofByGoroutine(compute func(io.Writer, map[uint64][]interval, []*trace.Event) error) func(w io.Writer, r *http.Request) error {
+func pprofByGoroutine(compute func(io.Writer, map[uint64][]interval) error) func(w io.Writer, r *http.Request) error {
 	return func(w io.Writer, r *http.Request) error {
 		id := r.FormValue("id")
-		events, err := parseEvents()
+		gToIntervals, err := pprofMatchingGoroutines(id)
 		if err != nil {
 			return err
 		}
-		gToIntervals, err := pprofMatchingGoroutines(id, events)
-		if err != nil {
-			return err
-		}
-		return compute(w, gToIntervals, events)
+		return compute(w, gToIntervals)
 	}
 }
 
-func pprofByRegion(compute func(io.Writer, map[uint64][]interval, []*trace.Event) error) func(w io.Writer, r *http.Request) error {
+func pprofByRegion(compute func(io.Writer, map[uint64][]interval) error) func(w io.Writer, r *http.Request) error {
 	return func(w io.Writer, r *http.Request) error {
 		filter, err := newRegionFilter(r)
 		if err != nil {
@@ -84,16 +80,14 @@ func pprofByRegion(compute func(io.Writer, map[uint64][]interval, []*trace.Event
 		if err != nil {
 			return err
 		}
-		events, _ := parseEvents()
-
-		return compute(w, gToIntervals, events)
+		return compute(w, gToIntervals)
 	}
 }
 
 // pprofMatchingGoroutines parses the goroutine type id string (i.e. pc)
 // and returns the ids of goroutines of the matching type and its interval.
 // If the id string is empty, returns nil without an error.
-func pprofMatchingGoroutines(id string, events []*trace.Event) (map[uint64][]interval, error) {
+func pprofMatchingGoroutines(id string) (map[uint64][]interval, error) {
 	if id == "" {
 		return nil, nil
 	}
@@ -101,7 +95,9 @@ func pprofMatchingGoroutines(id string, events []*trace.Event) (map[uint64][]int
 	if err != nil {
 		return nil, fmt.Errorf("invalid goroutine type: %v", id)
 	}
-	analyzeGoroutines(events)
+	if err := analyzeGoroutines(); err != nil {
+		return nil, err
+	}
 	var res map[uint64][]interval
 	for _, g := range gs {
 		if g.PC != pc {
@@ -171,79 +167,53 @@ func pprofMatchingRegions(filter *regionFilter) (map[uint64][]interval, error) {
 }
 
 // computePprofIO generates IO pprof-like profile (time spent in IO wait, currently only network blocking event).
-func computePprofIO(w io.Writer, gToIntervals map[uint64][]interval, events []*trace.Event) error {
-	prof := make(map[uint64]Record)
-	for _, ev := range events {
-		if ev.Type != trace.EvGoBlockNet || ev.Link == nil || ev.StkID == 0 || len(ev.Stk) == 0 {
-			continue
-		}
-		overlapping := pprofOverlappingDuration(gToIntervals, ev)
-		if overlapping > 0 {
-			rec := prof[ev.StkID]
-			rec.stk = ev.Stk
-			rec.n++
-			rec.time += overlapping.Nanoseconds()
-			prof[ev.StkID] = rec
-		}
-	}
-	return buildProfile(prof).Write(w)
+func computePprofIO(w io.Writer, gToIntervals map[uint64][]interval) error {
+	return computePprof(w, gToIntervals, func(ev *trace.Event) (uint64, bool) {
+		return ev.G, ev.Type == trace.EvGoBlockNet
+	})
 }
 
 // computePprofBlock generates blocking pprof-like profile (time spent blocked on synchronization primitives).
-func computePprofBlock(w io.Writer, gToIntervals map[uint64][]interval, events []*trace.Event) error {
-	prof := make(map[uint64]Record)
-	for _, ev := range events {
+func computePprofBlock(w io.Writer, gToIntervals map[uint64][]interval) error {
+	return computePprof(w, gToIntervals, func(ev *trace.Event) (uint64, bool) {
 		switch ev.Type {
 		case trace.EvGoBlockSend, trace.EvGoBlockRecv, trace.EvGoBlockSelect,
 			trace.EvGoBlockSync, trace.EvGoBlockCond, trace.EvGoBlockGC:
 			// TODO(hyangah): figure out why EvGoBlockGC should be here.
 			// EvGoBlockGC indicates the goroutine blocks on GC assist, not
 			// on synchronization primitives.
-		default:
-			continue
-		}
-		if ev.Link == nil || ev.StkID == 0 || len(ev.Stk) == 0 {
-			continue
-		}
-		overlapping := pprofOverlappingDuration(gToIntervals, ev)
-		if overlapping > 0 {
-			rec := prof[ev.StkID]
-			rec.stk = ev.Stk
-			rec.n++
-			rec.time += overlapping.Nanoseconds()
-			prof[ev.StkID] = rec
+			return ev.G, true
 		}
-	}
-	return buildProfile(prof).Write(w)
+		return 0, false
+	})
 }
 
 // computePprofSyscall generates syscall pprof-like profile (time spent blocked in syscalls).
-func computePprofSyscall(w io.Writer, gToIntervals map[uint64][]interval, events []*trace.Event) error {
-	prof := make(map[uint64]Record)
-	for _, ev := range events {
-		if ev.Type != trace.EvGoSysCall || ev.Link == nil || ev.StkID == 0 || len(ev.Stk) == 0 {
-			continue
-		}
-		overlapping := pprofOverlappingDuration(gToIntervals, ev)
-		if overlapping > 0 {
-			rec := prof[ev.StkID]
-			rec.stk = ev.Stk
-			rec.n++
-			rec.time += overlapping.Nanoseconds()
-			prof[ev.StkID] = rec
-		}
-	}
-	return buildProfile(prof).Write(w)
+func computePprofSyscall(w io.Writer, gToIntervals map[uint64][]interval) error {
+	return computePprof(w, gToIntervals, func(ev *trace.Event) (uint64, bool) {
+		return ev.G, ev.Type == trace.EvGoSysCall
+	})
 }
 
 // computePprofSched generates scheduler latency pprof-like profile
 // (time between a goroutine become runnable and actually scheduled for execution).
-func computePprofSched(w io.Writer, gToIntervals map[uint64][]interval, events []*trace.Event) error {
+func computePprofSched(w io.Writer, gToIntervals map[uint64][]interval) error {
+	return computePprof(w, gToIntervals, func(ev *trace.Event) (uint64, bool) {
+		return ev.Args[0], ev.Type == trace.EvGoUnblock || ev.Type == trace.EvGoCreate
+	})
+}
+
+// computePprof generates a pprof-like profile of the time between the
+// events selected by match and the events they are linked to. match also
+// returns the goroutine whose next event is the linked one, which is either
+// the goroutine of that event or, for an unblock, its first argument.
+// The trace is read one event at a time, and a selected event is only
+// held until the event it is linked to is read.
+func computePprof(w io.Writer, gToIntervals map[uint64][]interval, match func(ev *trace.Event) (g uint64, ok bool)) error {
 	prof := make(map[uint64]Record)
-	for _, ev := range events {
-		if (ev.Type != trace.EvGoUnblock && ev.Type != trace.EvGoCreate) ||
-			ev.Link == nil || ev.StkID == 0 || len(ev.Stk) == 0 {
-			continue
+	add := func(ev *trace.Event) {
+		if ev.Link == nil || ev.StkID == 0 || len(ev.Stk) == 0 {
+			return
 		}
 		overlapping := pprofOverlappingDuration(gToIntervals, ev)
 		if overlapping > 0 {
@@ -254,6 +224,25 @@ func computePprofSched(w io.Writer, gToIntervals map[uint64][]interval, events [
 			prof[ev.StkID] = rec
 		}
 	}
+	pending := make(map[uint64]*trace.Event) // selected events waiting for their link, by goroutine
+	err := readTrace(func(ev *trace.Event) {
+		for _, g := range [...]uint64{ev.G, ev.Args[0]} {
+			if p := pending[g]; p != nil && p.Link != nil {
+				add(p)
+				delete(pending, g)
+			}
+		}
+		if g, ok := match(ev); ok {
+			if ev.Link != nil {
+				add(ev)
+			} else {
+				pending[g] = ev
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
 	return buildProfile(prof).Write(w)
 }
 
@@ -31,15 +31,17 @@ func init() {
 
 // httpTrace serves either whole trace (goid==0) or trace for goid goroutine.
 func httpTrace(w http.ResponseWriter, r *http.Request) {
-	_, err := parseTrace()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if r.Form.Get("startTime") == "" && !streamable() {
+		// Streamed traces are read on demand.
+		if _, err := parseTrace(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
 	html := strings.ReplaceAll(templTrace, "{{PARAMS}}", r.Form.Encode())
 	w.Write([]byte(html))
 
@@ -188,16 +190,29 @@ func httpJsonTrace(w http.ResponseWriter, r *http.Request) {
 	defer debug.FreeOSMemory()
 	defer reportMemoryUsage("after httpJsonTrace")
 	// This is an AJAX handler, so instead of http.Error we use log.Printf to log errors.
-	res, err := parseTrace()
-	if err != nil {
-		log.Printf("failed to parse trace: %v", err)
+	if r.FormValue("startTime") != "" {
+		httpJsonTraceStream(w, r)
 		return
 	}
-
 	params := &traceParams{
-		parsed:  res,
 		endTime: math.MaxInt64,
 	}
+	if streamable() {
+		tracef, tr, err := openTrace()
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		defer tracef.Close()
+		params.stream = &eventStream{r: tr}
+	} else {
+		res, err := parseTrace()
+		if err != nil {
+			log.Printf("failed to parse trace: %v", err)
+			return
+		}
+		params.parsed = res
+	}
 
 	if goids := r.FormValue("goid"); goids != "" {
 		// If goid argument is present, we are rendering a trace for this particular goroutine.
@@ -206,7 +221,10 @@ func httpJsonTrace(w http.ResponseWriter, r *http.Request) {
 			log.Printf("failed to parse goid parameter %q: %v", goids, err)
 			return
 		}
-		analyzeGoroutines(res.Events)
+		if err := analyzeGoroutines(); err != nil {
+			log.Print(err)
+			return
+		}
 		g, ok := gs[goid]
 		if !ok {
 			log.Printf("failed to find goroutine %d", goid)
@@ -220,7 +238,11 @@ func httpJsonTrace(w http.ResponseWriter, r *http.Request) {
 			params.endTime = lastTimestamp()
 		}
 		params.maing = goid
-		params.gs = trace.RelatedGoroutines(res.Events, goid)
+		params.gs, err = relatedGoroutines(goid)
+		if err != nil {
+			log.Print(err)
+			return
+		}
 	} else if taskids := r.FormValue("taskid"); taskids != "" {
 		taskid, err := strconv.ParseUint(taskids, 10, 64)
 		if err != nil {
@@ -242,7 +264,7 @@ func httpJsonTrace(w http.ResponseWriter, r *http.Request) {
 		gs := map[uint64]bool{}
 		for _, t := range params.tasks {
 			// find only directly involved goroutines
-			for k, v := range t.RelatedGoroutines(res.Events, 0) {
+			for k, v := range t.RelatedGoroutines(nil, 0) {
 				gs[k] = v
 			}
 		}
@@ -265,6 +287,7 @@ func httpJsonTrace(w http.ResponseWriter, r *http.Request) {
 		params.tasks = task.descendants()
 	}
 
+	var err error
 	start := int64(0)
 	end := int64(math.MaxInt64)
 	if startStr, endStr := r.FormValue("start"), r.FormValue("end"); startStr != "" && endStr != "" {
@@ -288,18 +311,147 @@ func httpJsonTrace(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// httpJsonTraceStream serves the json trace for the time range of a
+// streamed trace, reading the events up to the end of the range.
+func httpJsonTraceStream(w http.ResponseWriter, r *http.Request) {
+	startTime, err := strconv.ParseInt(r.FormValue("startTime"), 10, 64)
+	if err != nil {
+		log.Printf("failed to parse startTime parameter %q: %v", r.FormValue("startTime"), err)
+		return
+	}
+	endTime, err := strconv.ParseInt(r.FormValue("endTime"), 10, 64)
+	if err != nil {
+		log.Printf("failed to parse endTime parameter %q: %v", r.FormValue("endTime"), err)
+		return
+	}
+	tracef, tr, err := openTrace()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	defer tracef.Close()
+
+	params := &traceParams{
+		stream:    &eventStream{r: tr},
+		startTime: startTime,
+		endTime:   endTime,
+	}
+	c := viewerDataTraceConsumer(w, 0, math.MaxInt64)
+	if err := generateTrace(params, c); err != nil {
+		log.Printf("failed to generate trace: %v", err)
+		return
+	}
+}
+
 type Range struct {
 	Name      string
 	Start     int
 	End       int
 	StartTime int64
 	EndTime   int64
+
+	streamed bool // whether the range is read on demand by time
 }
 
 func (r Range) URL() string {
+	if r.streamed {
+		return fmt.Sprintf("/trace?startTime=%d&endTime=%d", r.StartTime, r.EndTime)
+	}
 	return fmt.Sprintf("/trace?start=%d&end=%d", r.Start, r.End)
 }
 
+// rangeEvents is the approximate number of events in each range
+// of a streamed trace.
+const rangeEvents = 1 << 20
+
+// indexTrace splits a streamed trace into time ranges
+// of approximately rangeEvents events each.
+func indexTrace() ([]Range, error) {
+	tracef, tr, err := openTrace()
+	if err != nil {
+		return nil, err
+	}
+	defer tracef.Close()
+
+	var ranges []Range
+	var n int
+	var start, last int64
+	for {
+		ev, err := tr.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse trace: %v", err)
+		}
+		if n == 0 {
+			start = ev.Ts
+		}
+		last = ev.Ts
+		if n++; n == rangeEvents {
+			ranges = append(ranges, makeRange(start, last))
+			n = 0
+		}
+	}
+	if n > 0 || len(ranges) == 0 {
+		ranges = append(ranges, makeRange(start, last))
+	}
+	return ranges, nil
+}
+
+func makeRange(start, end int64) Range {
+	return Range{
+		Name:      fmt.Sprintf("%v-%v", time.Duration(start), time.Duration(end)),
+		StartTime: start,
+		EndTime:   end,
+		streamed:  true,
+	}
+}
+
+// eventStream reads the events of a streamed trace. An event that starts
+// a slice is held back until the event ending it is read, so that its Link
+// is set, and the events following it are held back with it.
+type eventStream struct {
+	r       *trace.Reader
+	pending []*trace.Event
+	eof     bool
+}
+
+// maxPending bounds the number of events held back by an eventStream.
+// A slice that does not end within that many events is cut short.
+const maxPending = 1 << 18
+
+// next returns the next event of the stream, or nil at the end of it.
+func (s *eventStream) next() (*trace.Event, error) {
+	for !s.eof && (len(s.pending) == 0 || needsLink(s.pending[0]) && len(s.pending) < maxPending) {
+		ev, err := s.r.ReadEvent()
+		if err == io.EOF {
+			s.eof = true
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.pending = append(s.pending, ev)
+	}
+	if len(s.pending) == 0 {
+		return nil, nil
+	}
+	ev := s.pending[0]
+	s.pending[0] = nil
+	s.pending = s.pending[1:]
+	return ev, nil
+}
+
+// needsLink reports whether ev is a slice whose end has not been read yet.
+func needsLink(ev *trace.Event) bool {
+	switch ev.Type {
+	case trace.EvGCStart, trace.EvGCSTWStart, trace.EvGCSweepStart, trace.EvGoStart, trace.EvGoStartLabel:
+		return ev.Link == nil
+	}
+	return false
+}
+
 // splitTrace splits the trace into a number of ranges,
 // each resulting in approx 100MB of json output
 // (trace viewer can hardly handle more).
@@ -413,6 +565,7 @@ func (cw *countingWriter) Write(data []byte) (int, error) {
 
 type traceParams struct {
 	parsed    trace.ParseResult
+	stream    *eventStream // if non-nil, the events are read from stream rather than parsed
 	mode      traceviewMode
 	startTime int64
 	endTime   int64
@@ -527,7 +680,23 @@ func generateTrace(params *traceParams, consumer traceConsumer) error {
 	ctx.consumer.consumeTimeUnit("ns")
 	maxProc := 0
 	ginfos := make(map[uint64]*gInfo)
-	stacks := params.parsed.Stacks
+	stack := func(id uint64) []*trace.Frame {
+		return params.parsed.Stacks[id]
+	}
+	if params.stream != nil {
+		stack = params.stream.r.Stack
+	}
+	i := 0
+	nextEvent := func() (*trace.Event, error) {
+		if params.stream != nil {
+			return params.stream.next()
+		}
+		if i == len(params.parsed.Events) {
+			return nil, nil
+		}
+		i++
+		return params.parsed.Events[i-1], nil
+	}
 
 	getGInfo := func(g uint64) *gInfo {
 		info, ok := ginfos[g]
@@ -555,7 +724,19 @@ func generateTrace(params *traceParams, consumer traceConsumer) error {
 		info.state = newState
 	}
 
-	for _, ev := range ctx.parsed.Events {
+	for {
+		ev, err := nextEvent()
+		if err != nil {
+			return err
+		}
+		if ev == nil {
+			break
+		}
+		if params.stream != nil && ev.Ts > ctx.endTime {
+			// Slices starting earlier were emitted already.
+			break
+		}
+
 		// Handle state transitions before we filter out events.
 		switch ev.Type {
 		case trace.EvGoStart, trace.EvGoStartLabel:
@@ -573,8 +754,8 @@ func generateTrace(params *traceParams, consumer traceConsumer) error {
 				return fmt.Errorf("duplicate go create event for go id=%d detected at offset %d", newG, ev.Off)
 			}
 
-			stk, ok := stacks[ev.Args[1]]
-			if !ok || len(stk) == 0 {
+			stk := stack(ev.Args[1])
+			if len(stk) == 0 {
 				return fmt.Errorf("invalid go create event: missing stack information for go id=%d at offset %d", newG, ev.Off)
 			}
 
@@ -676,10 +857,13 @@ func generateTrace(params *traceParams, consumer traceConsumer) error {
 			// whichever comes first. We'll synthesize another slice if
 			// necessary in EvGoStart.
 			markFinish := ev.Link
-			goFinish := getGInfo(ev.G).start.Link
+			var goFinish *trace.Event
+			if start := getGInfo(ev.G).start; start != nil {
+				goFinish = start.Link
+			}
 			fakeMarkStart := *ev
 			text := "MARK ASSIST"
-			if markFinish == nil || markFinish.Ts > goFinish.Ts {
+			if markFinish == nil || goFinish != nil && markFinish.Ts > goFinish.Ts {
 				fakeMarkStart.Link = goFinish
 				text = "MARK ASSIST (unfinished)"
 			}
@@ -707,7 +891,7 @@ func generateTrace(params *traceParams, consumer traceConsumer) error {
 				goFinish := ev.Link
 				fakeMarkStart := *ev
 				text := "MARK ASSIST (resumed, unfinished)"
-				if markFinish != nil && markFinish.Ts < goFinish.Ts {
+				if markFinish != nil && (goFinish == nil || markFinish.Ts < goFinish.Ts) {
 					fakeMarkStart.Link = markFinish
 					text = "MARK ASSIST (resumed)"
 				}
@@ -855,8 +1039,13 @@ func (ctx *traceContext) makeSlice(ev *trace.Event, name string) *traceviewer.Ev
 	// If ViewerEvent.Dur is not a positive value,
 	// trace viewer handles it as a non-terminating time interval.
 	// Avoid it by setting the field with a small value.
-	durationUsec := ctx.time(ev.Link) - ctx.time(ev)
-	if ev.Link.Ts-ev.Ts <= 0 {
+	end := ev.Link
+	if end == nil {
+		// The end of a streamed slice was not read. Cut it short.
+		end = &trace.Event{Ts: ctx.endTime}
+	}
+	durationUsec := ctx.time(end) - ctx.time(ev)
+	if end.Ts-ev.Ts <= 0 {
 		durationUsec = 0.0001 // 0.1 nanoseconds
 	}
 	sl := &traceviewer.Event{
@@ -866,7 +1055,7 @@ func (ctx *traceContext) makeSlice(ev *trace.Event, name string) *traceviewer.Ev
 		Dur:      durationUsec,
 		TID:      ctx.proc(ev),
 		Stack:    ctx.stack(ev.Stk),
-		EndStack: ctx.stack(ev.Link.Stk),
+		EndStack: ctx.stack(end.Stk),
 	}
 
 	// grey out non-overlapping events if the event is not a global event (ev.G == 0)
@@ -1141,20 +1330,14 @@ func isSystemGoroutine(entryFn string) bool {
 
 // firstTimestamp returns the timestamp of the first event record.
 func firstTimestamp() int64 {
-	res, _ := parseTrace()
-	if len(res.Events) > 0 {
-		return res.Events[0].Ts
-	}
-	return 0
+	analyzeGoroutines()
+	return traceStart
 }
 
 // lastTimestamp returns the timestamp of the last event record.
 func lastTimestamp() int64 {
-	res, _ := parseTrace()
-	if n := len(res.Events); n > 1 {
-		return res.Events[n-1].Ts
-	}
-	return 0
+	analyzeGoroutines()
+	return traceEnd
 }
 
 type jsonWriter struct {
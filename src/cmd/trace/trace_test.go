@@ -7,15 +7,22 @@
 package main
 
 import (
+	"bytes"
 	"cmd/internal/traceviewer"
 	"context"
+	"encoding/json"
 	"internal/trace"
 	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	rtrace "runtime/trace"
 	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/google/pprof/profile"
 )
 
 // stacks is a fake stack map populated for test.
@@ -267,3 +274,94 @@ func TestDirectSemaphoreHandoff(t *testing.T) {
 		t.Fatalf("failed to parse the trace: %v", err)
 	}
 }
+
+func TestStreamedTrace(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "trace.out")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rtrace.Start(f); err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rtrace.WithRegion(context.Background(), "streamed", func() {
+				time.Sleep(time.Millisecond)
+			})
+		}()
+	}
+	wg.Wait()
+	rtrace.Stop()
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	setTraceFile(t, file)
+	if !streamable() {
+		t.Fatal("trace is not streamable")
+	}
+	ranges, err := indexTrace()
+	if err != nil {
+		t.Fatalf("failed to index trace: %v", err)
+	}
+	if len(ranges) != 1 || !ranges[0].streamed {
+		t.Fatalf("got ranges %+v, want a single streamed range", ranges)
+	}
+
+	w := httptest.NewRecorder()
+	httpJsonTrace(w, httptest.NewRequest("GET", ranges[0].URL(), nil))
+	var data traceviewer.Data
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+		t.Fatalf("failed to decode json trace: %v\n%s", err, w.Body.Bytes())
+	}
+	var slices int
+	for _, ev := range data.Events {
+		if ev.Phase == "X" && strings.Contains(ev.Name, "TestStreamedTrace") {
+			slices++
+		}
+	}
+	if slices < 10 {
+		t.Errorf("got %d slices of goroutines started by TestStreamedTrace, want at least 10", slices)
+	}
+
+	if err := analyzeGoroutines(); err != nil {
+		t.Fatalf("failed to analyze goroutines: %v", err)
+	}
+	var regions int
+	for _, g := range gs {
+		for _, r := range g.Regions {
+			if r.Name == "streamed" && r.End != nil {
+				regions++
+			}
+		}
+	}
+	if regions != 10 {
+		t.Errorf("got %d ended regions named streamed, want 10", regions)
+	}
+
+	// The goroutines were created by TestStreamedTrace, so their
+	// scheduling latency is attributed to it.
+	var buf bytes.Buffer
+	if err := computePprofSched(&buf, nil); err != nil {
+		t.Fatalf("failed to compute scheduler latency profile: %v", err)
+	}
+	prof, err := profile.Parse(&buf)
+	if err != nil {
+		t.Fatalf("failed to parse scheduler latency profile: %v", err)
+	}
+	var found bool
+	for _, s := range prof.Sample {
+		for _, loc := range s.Location {
+			for _, l := range loc.Line {
+				found = found || strings.Contains(l.Function.Name, "TestStreamedTrace")
+			}
+		}
+	}
+	if !found {
+		t.Error("scheduler latency profile has no samples in TestStreamedTrace")
+	}
+}
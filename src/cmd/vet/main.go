@@ -5,10 +5,9 @@
 package main
 
 import (
+	"cmd/internal/analysisfix"
 	"cmd/internal/objabi"
 
-	"golang.org/x/tools/go/analysis/unitchecker"
-
 	"golang.org/x/tools/go/analysis/passes/asmdecl"
 	"golang.org/x/tools/go/analysis/passes/assign"
 	"golang.org/x/tools/go/analysis/passes/atomic"
@@ -41,7 +40,7 @@ import (
 func main() {
 	objabi.AddVersionFlag()
 
-	unitchecker.Main(
+	analysisfix.Main(
 		asmdecl.Analyzer,
 		assign.Analyzer,
 		atomic.Analyzer,
@@ -12,3 +12,15 @@ type MethodTest int
 
 func (t *MethodTest) Scan(x fmt.ScanState, c byte) { // ERROR "should have signature Scan\(fmt\.ScanState, rune\) error"
 }
+
+type MultiErrorTest int
+
+func (MultiErrorTest) Error() string { return "" }
+
+func (MultiErrorTest) Unwrap() []error { return nil } // OK
+
+type UnwrapTest int
+
+func (UnwrapTest) Error() string { return "" }
+
+func (UnwrapTest) Unwrap() int { return 0 } // ERROR "should have signature Unwrap\(\) error or Unwrap\(\) \[\]error"
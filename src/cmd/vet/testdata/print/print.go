@@ -245,6 +245,9 @@ func PrintfTests() {
 
 	Errorf(1, "%d", 3)    // OK
 	Errorf(1, "%d", "hi") // ERROR "Errorf format %d has arg \x22hi\x22 of wrong type string"
+	wrapped := fmt.Errorf("wrapped")
+	_ = fmt.Errorf("%w: %w", wrapped, wrapped) // OK
+	_ = fmt.Errorf("%w", "hi")                 // ERROR "Errorf format %w has arg \x22hi\x22 of wrong type string"
 
 	// Multiple string arguments before variadic args
 	errorf("WARNING", "foobar")            // OK
@@ -0,0 +1,179 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import "math/bits"
+
+// A forwardBitReader reads a little-endian bit stream from the start of
+// a byte slice, least significant bit first. Reads past the end of the
+// data return zero bits; the caller checks for that with overrun.
+// It is used for FSE table descriptions.
+type forwardBitReader struct {
+	data []byte
+	pos  int // in bits
+}
+
+// peek returns the next n bits without consuming them. n must be at most 32.
+func (br *forwardBitReader) peek(n int) uint32 {
+	var v uint64
+	off := br.pos >> 3
+	for i := 0; i < 5 && off+i < len(br.data); i++ {
+		v |= uint64(br.data[off+i]) << (8 * i)
+	}
+	return uint32(v>>(br.pos&7)) & (1<<n - 1)
+}
+
+// read returns the next n bits. n must be at most 32.
+func (br *forwardBitReader) read(n int) uint32 {
+	v := br.peek(n)
+	br.pos += n
+	return v
+}
+
+// overrun reports whether more bits have been read than the data holds.
+func (br *forwardBitReader) overrun() bool {
+	return br.pos > len(br.data)*8
+}
+
+// A reverseBitReader reads a bit stream backward from the end of a byte
+// slice, most significant bit first. The last byte of the stream holds
+// a 1 bit marking where the stream starts; bits above it are padding.
+// Huffman and FSE streams are read this way.
+type reverseBitReader struct {
+	data []byte
+	off  int    // data[:off] has not been loaded into bits
+	bits uint64 // the low cnt bits are unread
+	cnt  uint
+
+	// overflow is set when a padded read runs past the start of
+	// the stream.
+	overflow bool
+}
+
+// init prepares br to read the bit stream in data.
+func (br *reverseBitReader) init(data []byte) error {
+	if len(data) == 0 {
+		return corruptError("empty bit stream")
+	}
+	last := data[len(data)-1]
+	if last == 0 {
+		return corruptError("missing bit stream marker")
+	}
+	*br = reverseBitReader{
+		data: data,
+		off:  len(data) - 1,
+		bits: uint64(last),
+		cnt:  uint(bits.Len8(last)) - 1,
+	}
+	return nil
+}
+
+// fill loads as many bytes into the bit buffer as fit.
+func (br *reverseBitReader) fill() {
+	if br.cnt <= 32 && br.off >= 4 {
+		br.off -= 4
+		br.bits = br.bits<<32 | uint64(le.Uint32(br.data[br.off:]))
+		br.cnt += 32
+		return
+	}
+	for br.cnt <= 56 && br.off > 0 {
+		br.off--
+		br.bits = br.bits<<8 | uint64(br.data[br.off])
+		br.cnt += 8
+	}
+}
+
+// getBits returns the next n bits, n <= 32, and reports an error if
+// the stream does not hold that many.
+func (br *reverseBitReader) getBits(n uint) (uint32, error) {
+	if n > br.cnt {
+		br.fill()
+		if n > br.cnt {
+			return 0, corruptError("bit stream overrun")
+		}
+	}
+	br.cnt -= n
+	return uint32(br.bits>>br.cnt) & (1<<n - 1), nil
+}
+
+// peek returns the next n bits, n <= 32, without consuming them.
+// If the stream holds fewer than n bits, the missing low bits are zero.
+func (br *reverseBitReader) peek(n uint) uint32 {
+	if n > br.cnt {
+		br.fill()
+		if n > br.cnt {
+			return uint32(br.bits<<(n-br.cnt)) & (1<<n - 1)
+		}
+	}
+	return uint32(br.bits>>(br.cnt-n)) & (1<<n - 1)
+}
+
+// skip consumes n bits that have been peeked, and reports an error if
+// the stream does not hold that many.
+func (br *reverseBitReader) skip(n uint) error {
+	if n > br.cnt {
+		return corruptError("bit stream overrun")
+	}
+	br.cnt -= n
+	return nil
+}
+
+// getBitsPad is like getBits, but reading past the start of the stream
+// returns zero bits and sets br.overflow.
+func (br *reverseBitReader) getBitsPad(n uint) uint32 {
+	v := br.peek(n)
+	if n > br.cnt {
+		br.overflow = true
+		br.cnt = 0
+		return v
+	}
+	br.cnt -= n
+	return v
+}
+
+// done reports whether the whole stream has been read.
+func (br *reverseBitReader) done() bool {
+	return br.off == 0 && br.cnt == 0
+}
+
+// A bitWriter writes a little-endian bit stream, least significant bit
+// first. Streams meant to be read by a reverseBitReader are finished
+// with close, which appends the marker bit.
+type bitWriter struct {
+	out   []byte
+	bits  uint64
+	nbits uint
+}
+
+// addBits appends the low n bits of v, n <= 32.
+func (w *bitWriter) addBits(v uint32, n uint) {
+	w.bits |= uint64(v) & (1<<n - 1) << w.nbits
+	w.nbits += n
+	if w.nbits >= 32 {
+		w.out = append(w.out, byte(w.bits), byte(w.bits>>8), byte(w.bits>>16), byte(w.bits>>24))
+		w.bits >>= 32
+		w.nbits -= 32
+	}
+}
+
+// flush writes out any buffered bits, padding the last byte with zeros.
+func (w *bitWriter) flush() {
+	for w.nbits > 0 {
+		w.out = append(w.out, byte(w.bits))
+		w.bits >>= 8
+		if w.nbits < 8 {
+			w.nbits = 0
+		} else {
+			w.nbits -= 8
+		}
+	}
+	w.bits = 0
+}
+
+// close appends the stream marker bit and flushes the stream.
+func (w *bitWriter) close() {
+	w.addBits(1, 1)
+	w.flush()
+}
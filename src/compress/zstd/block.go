@@ -0,0 +1,363 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+// A seqTable is the FSE decoding table in use for one kind of
+// sequence code.
+type seqTable struct {
+	table    []fseEntry
+	tableLog int
+}
+
+// decompressBlock decompresses the compressed block in data (section
+// 3.1.1.3), appending the result to z.hist.
+func (z *Reader) decompressBlock(data []byte) error {
+	lits, n, err := z.readLiterals(data)
+	if err != nil {
+		return err
+	}
+	data = data[n:]
+
+	// Sequences section header (section 3.1.1.3.2.1).
+	if len(data) == 0 {
+		return corruptError("missing sequences section")
+	}
+	nseq := int(data[0])
+	switch {
+	case nseq == 0:
+		if len(data) != 1 {
+			return corruptError("extra data after literals")
+		}
+		z.hist = append(z.hist, lits...)
+		return nil
+	case nseq < 128:
+		data = data[1:]
+	case nseq < 255:
+		if len(data) < 2 {
+			return corruptError("truncated sequences section")
+		}
+		nseq = (nseq-128)<<8 + int(data[1])
+		data = data[2:]
+	default:
+		if len(data) < 3 {
+			return corruptError("truncated sequences section")
+		}
+		nseq = int(le.Uint16(data[1:])) + 0x7F00
+		data = data[3:]
+	}
+	if len(data) == 0 {
+		return corruptError("truncated sequences section")
+	}
+	modes := data[0]
+	if modes&3 != 0 {
+		return corruptError("reserved bits set in symbol compression modes")
+	}
+	data = data[1:]
+	for kind := 0; kind < seqKinds; kind++ {
+		mode := int(modes>>(6-2*kind)) & 3
+		n, err := z.readSeqTable(kind, mode, data)
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return z.execSequences(data, nseq, lits)
+}
+
+// readLiterals reads the literals section at the start of data (section
+// 3.1.1.3.1) and returns the literals and the size of the section.
+func (z *Reader) readLiterals(data []byte) ([]byte, int, error) {
+	if len(data) == 0 {
+		return nil, 0, corruptError("missing literals section")
+	}
+	typ := data[0] & 3
+	sizeFormat := (data[0] >> 2) & 3
+
+	if typ == literalsRaw || typ == literalsRLE {
+		var size, hdr int
+		switch sizeFormat {
+		case 0, 2:
+			size, hdr = int(data[0]>>3), 1
+		case 1:
+			if len(data) < 2 {
+				return nil, 0, corruptError("truncated literals header")
+			}
+			size, hdr = int(data[0]>>4)|int(data[1])<<4, 2
+		case 3:
+			if len(data) < 3 {
+				return nil, 0, corruptError("truncated literals header")
+			}
+			size, hdr = int(data[0]>>4)|int(data[1])<<4|int(data[2])<<12, 3
+		}
+		if size > z.blockMax {
+			return nil, 0, corruptError("literals section too large")
+		}
+		if typ == literalsRaw {
+			if hdr+size > len(data) {
+				return nil, 0, corruptError("truncated literals")
+			}
+			return data[hdr : hdr+size], hdr + size, nil
+		}
+		if hdr >= len(data) {
+			return nil, 0, corruptError("truncated literals")
+		}
+		z.literals = z.literals[:0]
+		for i := 0; i < size; i++ {
+			z.literals = append(z.literals, data[hdr])
+		}
+		return z.literals, hdr + 1, nil
+	}
+
+	// Huffman-coded literals. The header holds the regenerated and
+	// compressed sizes, each of 10, 14 or 18 bits.
+	hdr := 3 + int(sizeFormat) - 1
+	if sizeFormat == 0 {
+		hdr = 3
+	}
+	if len(data) < hdr {
+		return nil, 0, corruptError("truncated literals header")
+	}
+	v := int(data[0] >> 4)
+	for i := 1; i < hdr; i++ {
+		v |= int(data[i]) << (8*i - 4)
+	}
+	sizeBits := 4*hdr - 2
+	regen, comp := v&(1<<sizeBits-1), v>>sizeBits
+	streams := 4
+	if sizeFormat == 0 {
+		streams = 1
+	}
+	if regen > z.blockMax {
+		return nil, 0, corruptError("literals section too large")
+	}
+	if hdr+comp > len(data) {
+		return nil, 0, corruptError("truncated literals")
+	}
+	src := data[hdr : hdr+comp]
+
+	if typ == literalsCompressed {
+		if z.huffTable == nil {
+			z.huffTable = make([]huffEntry, 1<<maxHuffBits)
+		}
+		bits, n, err := readHuff(src, z.huffTable)
+		if err != nil {
+			return nil, 0, err
+		}
+		z.huffBits = bits
+		src = src[n:]
+	} else if z.huffBits == 0 {
+		return nil, 0, corruptError("treeless literals without a previous Huffman table")
+	}
+
+	if cap(z.literals) < regen {
+		z.literals = make([]byte, regen, maxBlockSize)
+	}
+	out := z.literals[:regen]
+	if streams == 1 {
+		if err := decodeHuff(src, z.huffTable, z.huffBits, out); err != nil {
+			return nil, 0, err
+		}
+		return out, hdr + comp, nil
+	}
+
+	// Four streams, preceded by a jump table of the sizes of the
+	// first three.
+	if len(src) < 10 || regen < 6 {
+		return nil, 0, corruptError("invalid four-stream literals")
+	}
+	s1, s2, s3 := int(le.Uint16(src)), int(le.Uint16(src[2:])), int(le.Uint16(src[4:]))
+	src = src[6:]
+	if s1+s2+s3 > len(src) {
+		return nil, 0, corruptError("invalid literals jump table")
+	}
+	per := (regen + 3) / 4
+	sizes := [4]int{s1, s2, s3, len(src) - s1 - s2 - s3}
+	for i, size := range sizes {
+		o := out[i*per:]
+		if i < 3 {
+			o = o[:per]
+		}
+		if err := decodeHuff(src[:size], z.huffTable, z.huffBits, o); err != nil {
+			return nil, 0, err
+		}
+		src = src[size:]
+	}
+	return out, hdr + comp, nil
+}
+
+// readSeqTable sets up the decoding table for the kind of sequence code
+// according to mode, reading any table description from the start of
+// data. It returns the number of bytes of data consumed.
+func (z *Reader) readSeqTable(kind, mode int, data []byte) (int, error) {
+	info := &seqCodeInfo[kind]
+	t := &z.seqTables[kind]
+	switch mode {
+	case modePredefined:
+		t.table, t.tableLog = predefDecoders[kind], info.predefLog
+		return 0, nil
+	case modeRLE:
+		if len(data) == 0 {
+			return 0, corruptError("truncated sequences section")
+		}
+		if int(data[0]) > info.maxSym {
+			return 0, corruptError("invalid RLE sequence code")
+		}
+		t.table, t.tableLog = z.rleTables[kind][:], 0
+		t.table[0] = fseEntry{sym: data[0]}
+		return 1, nil
+	case modeCompressed:
+		var norm [53]int16
+		tableLog, nsym, n, err := readFSE(data, info.maxSym, info.maxLog, norm[:])
+		if err != nil {
+			return 0, err
+		}
+		if z.fseTables[kind] == nil {
+			z.fseTables[kind] = make([]fseEntry, 1<<info.maxLog)
+		}
+		if err := buildFSE(norm[:nsym], tableLog, z.fseTables[kind]); err != nil {
+			return 0, err
+		}
+		t.table, t.tableLog = z.fseTables[kind], tableLog
+		return n, nil
+	default: // modeRepeat
+		if t.table == nil {
+			return 0, corruptError("repeated sequence table without a previous table")
+		}
+		return 0, nil
+	}
+}
+
+// execSequences decodes nseq sequences from the bit stream in data and
+// executes them (section 3.1.1.4), appending the result to z.hist.
+func (z *Reader) execSequences(data []byte, nseq int, lits []byte) error {
+	var br reverseBitReader
+	if err := br.init(data); err != nil {
+		return err
+	}
+	llt, oft, mlt := &z.seqTables[seqLiteralLength], &z.seqTables[seqOffset], &z.seqTables[seqMatchLength]
+	llState, err := br.getBits(uint(llt.tableLog))
+	if err != nil {
+		return err
+	}
+	ofState, err := br.getBits(uint(oft.tableLog))
+	if err != nil {
+		return err
+	}
+	mlState, err := br.getBits(uint(mlt.tableLog))
+	if err != nil {
+		return err
+	}
+
+	start := len(z.hist)
+	for i := 0; i < nseq; i++ {
+		ll, of, ml := llt.table[llState], oft.table[ofState], mlt.table[mlState]
+		if ll.sym > 35 || ml.sym > 52 || of.sym > 31 {
+			return corruptError("invalid sequence code")
+		}
+
+		// Offset, then match length, then literal length.
+		v, err := br.getBits(uint(of.sym))
+		if err != nil {
+			return err
+		}
+		offValue := 1<<of.sym + v
+		mb := matchLengthBase[ml.sym]
+		v, err = br.getBits(uint(mb.bits))
+		if err != nil {
+			return err
+		}
+		matchLen := int(mb.base + v)
+		lb := literalLengthBase[ll.sym]
+		v, err = br.getBits(uint(lb.bits))
+		if err != nil {
+			return err
+		}
+		litLen := int(lb.base + v)
+
+		// Repeat offsets (section 3.1.1.5).
+		var offset uint32
+		if offValue > 3 {
+			offset = offValue - 3
+			z.reps[2], z.reps[1], z.reps[0] = z.reps[1], z.reps[0], offset
+		} else {
+			idx := offValue - 1
+			if litLen == 0 {
+				idx++
+			}
+			switch idx {
+			case 0:
+				offset = z.reps[0]
+			case 1:
+				offset = z.reps[1]
+				z.reps[1], z.reps[0] = z.reps[0], offset
+			case 2:
+				offset = z.reps[2]
+				z.reps[2], z.reps[1], z.reps[0] = z.reps[1], z.reps[0], offset
+			case 3:
+				offset = z.reps[0] - 1
+				z.reps[2], z.reps[1], z.reps[0] = z.reps[1], z.reps[0], offset
+			}
+		}
+
+		if litLen > len(lits) {
+			return corruptError("literal length exceeds literals")
+		}
+		z.hist = append(z.hist, lits[:litLen]...)
+		lits = lits[litLen:]
+		if len(z.hist)-start+matchLen > z.blockMax {
+			return corruptError("block too large")
+		}
+		if err := z.copyMatch(int(offset), matchLen); err != nil {
+			return err
+		}
+
+		if i == nseq-1 {
+			break
+		}
+		// Update states: literal length, then match length, then offset.
+		v, err = br.getBits(uint(ll.bits))
+		if err != nil {
+			return err
+		}
+		llState = uint32(ll.base) + v
+		v, err = br.getBits(uint(ml.bits))
+		if err != nil {
+			return err
+		}
+		mlState = uint32(ml.base) + v
+		v, err = br.getBits(uint(of.bits))
+		if err != nil {
+			return err
+		}
+		ofState = uint32(of.base) + v
+	}
+	if !br.done() {
+		return corruptError("extra bits in sequences section")
+	}
+	if len(z.hist)-start+len(lits) > z.blockMax {
+		return corruptError("block too large")
+	}
+	z.hist = append(z.hist, lits...)
+	return nil
+}
+
+// copyMatch appends length bytes copied from offset bytes back in
+// z.hist. The source and destination may overlap.
+func (z *Reader) copyMatch(offset, length int) error {
+	if offset <= 0 || offset > len(z.hist) {
+		return corruptError("match offset out of range")
+	}
+	from := len(z.hist) - offset
+	for length > 0 {
+		n := length
+		if n > offset {
+			n = offset
+		}
+		z.hist = append(z.hist, z.hist[from:from+n]...)
+		from += n
+		length -= n
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+// A dict is a parsed dictionary (section 5).
+type dict struct {
+	id      uint32
+	content []byte
+
+	// Entropy tables and repeat offsets that frames using the
+	// dictionary start with. A raw content dictionary has no tables
+	// and the default repeat offsets.
+	huffTable []huffEntry
+	huffBits  int
+	seqTables [seqKinds]seqTable
+	reps      [3]uint32
+}
+
+// parseDict parses a dictionary. Data that does not start with the
+// dictionary magic number is a raw content dictionary.
+func parseDict(data []byte) (*dict, error) {
+	d := &dict{reps: [3]uint32{1, 4, 8}}
+	if len(data) < 8 || le.Uint32(data) != dictMagic {
+		d.content = append([]byte(nil), data...)
+		return d, nil
+	}
+	d.id = le.Uint32(data[4:])
+	if d.id == 0 {
+		return nil, ErrDictionary
+	}
+	data = data[8:]
+
+	d.huffTable = make([]huffEntry, 1<<maxHuffBits)
+	bits, n, err := readHuff(data, d.huffTable)
+	if err != nil {
+		return nil, ErrDictionary
+	}
+	d.huffBits = bits
+	data = data[n:]
+
+	// The FSE tables appear in the order offset, match length,
+	// literal length.
+	for _, kind := range []int{seqOffset, seqMatchLength, seqLiteralLength} {
+		info := &seqCodeInfo[kind]
+		var norm [53]int16
+		tableLog, nsym, n, err := readFSE(data, info.maxSym, info.maxLog, norm[:])
+		if err != nil {
+			return nil, ErrDictionary
+		}
+		table := make([]fseEntry, 1<<tableLog)
+		if err := buildFSE(norm[:nsym], tableLog, table); err != nil {
+			return nil, ErrDictionary
+		}
+		d.seqTables[kind] = seqTable{table: table, tableLog: tableLog}
+		data = data[n:]
+	}
+
+	if len(data) < 12 {
+		return nil, ErrDictionary
+	}
+	for i := range d.reps {
+		d.reps[i] = le.Uint32(data[4*i:])
+		if d.reps[i] == 0 || int(d.reps[i]) > len(data)-12 {
+			return nil, ErrDictionary
+		}
+	}
+	d.content = append([]byte(nil), data[12:]...)
+	return d, nil
+}
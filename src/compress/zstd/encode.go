@@ -0,0 +1,560 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"math"
+	"math/bits"
+)
+
+// minMatch is the shortest match the encoder looks for.
+const minMatch = 4
+
+// levelParams gives the match finder parameters of each compression
+// level. Levels without a chain use a single hash table and take the
+// first match found; the others follow hash chains up to depth
+// candidates deep, and may defer a match by a byte if that finds a
+// longer one.
+var levelParams = [BestCompression + 1]struct {
+	windowLog, hashLog, chainLog uint
+	depth                        int
+	lazy                         bool
+}{
+	1: {windowLog: 19, hashLog: 15},
+	2: {windowLog: 20, hashLog: 16, chainLog: 16, depth: 2},
+	3: {windowLog: 21, hashLog: 17, chainLog: 17, depth: 6, lazy: true},
+	4: {windowLog: 21, hashLog: 17, chainLog: 17, depth: 12, lazy: true},
+	5: {windowLog: 21, hashLog: 17, chainLog: 17, depth: 24, lazy: true},
+	6: {windowLog: 22, hashLog: 18, chainLog: 18, depth: 48, lazy: true},
+	7: {windowLog: 22, hashLog: 18, chainLog: 18, depth: 96, lazy: true},
+	8: {windowLog: 22, hashLog: 18, chainLog: 19, depth: 192, lazy: true},
+	9: {windowLog: 23, hashLog: 18, chainLog: 20, depth: 384, lazy: true},
+}
+
+// A sequence is a run of literals followed by a match.
+type sequence struct {
+	litLen   uint32
+	matchLen uint32
+	offset   uint32
+}
+
+// An encoder finds matches in the data written to a Writer and encodes
+// blocks of sequences.
+type encoder struct {
+	windowLog, hashLog, chainLog uint
+	depth                        int
+	lazy                         bool
+
+	// table maps the hash of 4 bytes to the most recent position in
+	// the history at which they occur, or -1. If chainLog is nonzero,
+	// chain[p&(1<<chainLog-1)] holds the previous position with the
+	// same hash as position p.
+	table    []int32
+	chain    []int32
+	inserted int // positions before inserted are in the tables
+
+	seqs []sequence
+	lits []byte
+	huff huffEncoder
+	fse  [seqKinds]fseEncoder
+
+	codes [seqKinds][]uint8
+}
+
+func (e *encoder) init(level int) {
+	p := levelParams[level]
+	e.windowLog, e.hashLog, e.chainLog = p.windowLog, p.hashLog, p.chainLog
+	e.depth, e.lazy = p.depth, p.lazy
+	e.table = make([]int32, 1<<e.hashLog)
+	if e.chainLog > 0 {
+		e.chain = make([]int32, 1<<e.chainLog)
+	}
+}
+
+// reset forgets all history.
+func (e *encoder) reset() {
+	for i := range e.table {
+		e.table[i] = -1
+	}
+	for i := range e.chain {
+		e.chain[i] = -1
+	}
+	e.inserted = 0
+}
+
+// slideDistance returns the distance by which to slide the history to
+// drop up to d bytes: a multiple of the chain size, so that positions
+// keep their chain entries.
+func (e *encoder) slideDistance(d int) int {
+	return d &^ (1<<e.chainLog - 1)
+}
+
+// shift adjusts the tables for the removal of the first d bytes of the
+// history.
+func (e *encoder) shift(d int) {
+	for _, t := range [][]int32{e.table, e.chain} {
+		for i, p := range t {
+			if int(p) < d {
+				t[i] = -1
+			} else {
+				t[i] = p - int32(d)
+			}
+		}
+	}
+	e.inserted -= d
+}
+
+func load32(b []byte, i int) uint32 {
+	return le.Uint32(b[i:])
+}
+
+func (e *encoder) hash(u uint32) uint32 {
+	return (u * 2654435761) >> (32 - e.hashLog)
+}
+
+// insert adds the positions before end that are not yet in the tables.
+// Positions within minMatch-1 bytes of the end of hist are left for
+// later, once more data follows them.
+func (e *encoder) insert(hist []byte, end int) {
+	if max := len(hist) - minMatch + 1; end > max {
+		end = max
+	}
+	for i := e.inserted; i < end; i++ {
+		h := e.hash(load32(hist, i))
+		if e.chain != nil {
+			e.chain[i&(1<<e.chainLog-1)] = e.table[h]
+		}
+		e.table[h] = int32(i)
+	}
+	if end > e.inserted {
+		e.inserted = end
+	}
+}
+
+// matchLen returns the length of the common prefix of a and b, where
+// len(a) >= len(b).
+func matchLen(a, b []byte) int {
+	n := 0
+	for len(b)-n >= 8 {
+		if x := le.Uint64(a[n:]) ^ le.Uint64(b[n:]); x != 0 {
+			return n + bits.TrailingZeros64(x)>>3
+		}
+		n += 8
+	}
+	for n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// compressBlock compresses hist[start:] as a compressed block, whose
+// content it appends to dst. Matches may refer to data before start.
+// It reports false if the block cannot be encoded.
+func (e *encoder) compressBlock(dst, hist []byte, start int) ([]byte, bool) {
+	e.seqs = e.seqs[:0]
+	e.lits = e.lits[:0]
+	var litStart int
+	if e.chain == nil {
+		litStart = e.matchFast(hist, start)
+	} else {
+		litStart = e.matchChain(hist, start)
+	}
+	e.lits = append(e.lits, hist[litStart:]...)
+	return e.encodeBlock(dst)
+}
+
+// addSeq records a sequence of the literals hist[litStart:s] followed
+// by a match of length n at offset off.
+func (e *encoder) addSeq(hist []byte, litStart, s, off, n int) {
+	e.seqs = append(e.seqs, sequence{
+		litLen:   uint32(s - litStart),
+		matchLen: uint32(n),
+		offset:   uint32(off),
+	})
+	e.lits = append(e.lits, hist[litStart:s]...)
+}
+
+// matchFast finds matches greedily using only the hash table, skipping
+// ahead faster the longer it goes without finding one. It returns the
+// start of the trailing literals.
+func (e *encoder) matchFast(hist []byte, start int) int {
+	end := len(hist)
+	limit := end - 8
+	window := 1 << e.windowLog
+	litStart := start
+	for s := start; s < limit; {
+		cur := load32(hist, s)
+		h := e.hash(cur)
+		cand := int(e.table[h])
+		e.table[h] = int32(s)
+		if cand < 0 || cand >= s || s-cand > window || load32(hist, cand) != cur {
+			s += 1 + (s-litStart)>>6
+			continue
+		}
+		n := minMatch + matchLen(hist[cand+minMatch:], hist[s+minMatch:])
+		for s > litStart && cand > 0 && hist[s-1] == hist[cand-1] {
+			s--
+			cand--
+			n++
+		}
+		e.addSeq(hist, litStart, s, s-cand, n)
+		s += n
+		litStart = s
+		if s-2 < limit {
+			e.table[e.hash(load32(hist, s-2))] = int32(s - 2)
+		}
+	}
+	if end-minMatch+1 > e.inserted {
+		e.inserted = end - minMatch + 1
+	}
+	return litStart
+}
+
+// matchChain finds matches by searching the hash chains, deferring a
+// match by one byte at a time while that finds a longer one, if
+// e.lazy is set. It returns the start of the trailing literals.
+func (e *encoder) matchChain(hist []byte, start int) int {
+	end := len(hist)
+	limit := end - 8
+	litStart := start
+	for s := start; s < limit; {
+		e.insert(hist, s)
+		n, off := e.findMatch(hist, s)
+		if n < minMatch {
+			s++
+			continue
+		}
+		for e.lazy && s+1 < limit {
+			e.insert(hist, s+1)
+			n1, off1 := e.findMatch(hist, s+1)
+			if n1 <= n {
+				break
+			}
+			s, n, off = s+1, n1, off1
+		}
+		cand := s - off
+		for s > litStart && cand > 0 && hist[s-1] == hist[cand-1] {
+			s--
+			cand--
+			n++
+		}
+		e.addSeq(hist, litStart, s, off, n)
+		s += n
+		litStart = s
+	}
+	e.insert(hist, end)
+	return litStart
+}
+
+// findMatch returns the length and offset of the longest match for the
+// data at hist[s:] among the candidates in the hash chain, or a length
+// of 0 if there is none.
+func (e *encoder) findMatch(hist []byte, s int) (n, off int) {
+	cur := load32(hist, s)
+	cand := int(e.table[e.hash(cur)])
+	minPos := s - 1<<e.windowLog
+	chainMin := s - 1<<e.chainLog
+	mask := 1<<e.chainLog - 1
+	maxLen := len(hist) - s
+	for depth := e.depth; depth > 0 && cand >= 0 && cand >= minPos && cand < s; depth-- {
+		if hist[cand+n] == hist[s+n] && load32(hist, cand) == cur {
+			if l := minMatch + matchLen(hist[cand+minMatch:], hist[s+minMatch:]); l > n {
+				n, off = l, s-cand
+				if n == maxLen {
+					break
+				}
+			}
+		}
+		if cand <= chainMin {
+			break
+		}
+		next := int(e.chain[cand&mask])
+		if next >= cand {
+			break
+		}
+		cand = next
+	}
+	return n, off
+}
+
+// encodeBlock appends the content of a compressed block holding e.lits
+// and e.seqs to dst.
+func (e *encoder) encodeBlock(dst []byte) ([]byte, bool) {
+	dst = e.encodeLiterals(dst)
+	return e.encodeSequences(dst)
+}
+
+// encodeLiterals appends the literals section for e.lits to dst
+// (section 3.1.1.3.1).
+func (e *encoder) encodeLiterals(dst []byte) []byte {
+	lits := e.lits
+	n := len(lits)
+	if n >= 64 {
+		if out, ok := e.huffLiterals(dst, lits); ok {
+			return out
+		}
+	}
+	typ := byte(literalsRaw)
+	if n > 1 && allSame(lits) {
+		typ = literalsRLE
+	}
+	switch {
+	case n < 32:
+		dst = append(dst, typ|byte(n)<<3)
+	case n < 4096:
+		dst = append(dst, typ|1<<2|byte(n&0xf)<<4, byte(n>>4))
+	default:
+		dst = append(dst, typ|3<<2|byte(n&0xf)<<4, byte(n>>4), byte(n>>12))
+	}
+	if typ == literalsRLE {
+		return append(dst, lits[0])
+	}
+	return append(dst, lits...)
+}
+
+// huffLiterals appends a Huffman-compressed literals section for lits
+// to dst, and reports whether doing so saved space.
+func (e *encoder) huffLiterals(dst, lits []byte) ([]byte, bool) {
+	var count [256]uint32
+	for _, c := range lits {
+		count[c]++
+	}
+	n := len(lits)
+	start := len(dst)
+	hdr := 5
+	switch {
+	case n <= 1023:
+		hdr = 3
+	case n <= 16383:
+		hdr = 4
+	}
+	dst = append(dst, make([]byte, hdr)...)
+	dst, ok := e.huff.build(dst, &count)
+	if !ok {
+		return dst[:start], false
+	}
+
+	var sizeFormat int
+	if n <= 1023 {
+		dst = e.huff.encode(dst, lits)
+	} else {
+		// Four streams, after a jump table of the sizes of the first
+		// three.
+		sizeFormat = hdr - 2
+		jump := len(dst)
+		dst = append(dst, make([]byte, 6)...)
+		per := (n + 3) / 4
+		for i := 0; i < 4; i++ {
+			streamStart := len(dst)
+			src := lits[i*per:]
+			if i < 3 {
+				src = src[:per]
+			}
+			dst = e.huff.encode(dst, src)
+			if i < 3 {
+				le.PutUint16(dst[jump+2*i:], uint16(len(dst)-streamStart))
+			}
+		}
+	}
+
+	comp := len(dst) - start - hdr
+	if comp >= n-n/32 {
+		return dst[:start], false
+	}
+	sizeBits := 4*hdr - 2
+	v := uint64(n) | uint64(comp)<<sizeBits
+	dst[start] = literalsCompressed | byte(sizeFormat)<<2 | byte(v&0xf)<<4
+	v >>= 4
+	for i := 1; i < hdr; i++ {
+		dst[start+i] = byte(v)
+		v >>= 8
+	}
+	return dst, true
+}
+
+// Tables mapping literal lengths below 64 and match lengths below 131
+// to their codes.
+var (
+	llCodeTable [64]uint8
+	mlCodeTable [128]uint8
+)
+
+func init() {
+	for code, b := range literalLengthBase {
+		for v := b.base; v < b.base+1<<b.bits && v < 64; v++ {
+			llCodeTable[v] = uint8(code)
+		}
+	}
+	for code, b := range matchLengthBase {
+		for v := b.base - 3; v < b.base-3+1<<b.bits && v < 128; v++ {
+			mlCodeTable[v] = uint8(code)
+		}
+	}
+}
+
+func literalLengthCode(ll uint32) uint8 {
+	if ll < 64 {
+		return llCodeTable[ll]
+	}
+	return uint8(bits.Len32(ll)) - 1 + 19
+}
+
+func matchLengthCode(ml uint32) uint8 {
+	if ml-3 < 128 {
+		return mlCodeTable[ml-3]
+	}
+	return uint8(bits.Len32(ml-3)) - 1 + 36
+}
+
+// encodeSequences appends the sequences section for e.seqs to dst
+// (section 3.1.1.3.2). Offsets are always written in full, never as
+// repeat offsets.
+func (e *encoder) encodeSequences(dst []byte) ([]byte, bool) {
+	nseq := len(e.seqs)
+	switch {
+	case nseq < 128:
+		dst = append(dst, byte(nseq))
+	case nseq < 0x7F00:
+		dst = append(dst, byte(nseq>>8+128), byte(nseq))
+	default:
+		dst = append(dst, 255, byte(nseq-0x7F00), byte((nseq-0x7F00)>>8))
+	}
+	if nseq == 0 {
+		return dst, true
+	}
+
+	for k := range e.codes {
+		e.codes[k] = e.codes[k][:0]
+	}
+	for _, s := range e.seqs {
+		e.codes[seqLiteralLength] = append(e.codes[seqLiteralLength], literalLengthCode(s.litLen))
+		e.codes[seqMatchLength] = append(e.codes[seqMatchLength], matchLengthCode(s.matchLen))
+		e.codes[seqOffset] = append(e.codes[seqOffset], uint8(bits.Len32(s.offset+3)-1))
+	}
+
+	modesAt := len(dst)
+	dst = append(dst, 0)
+	var encs [seqKinds]*fseEncoder
+	var modes byte
+	for kind := 0; kind < seqKinds; kind++ {
+		var mode int
+		dst, mode, encs[kind] = e.chooseTable(dst, kind)
+		modes |= byte(mode) << (6 - 2*kind)
+	}
+	dst[modesAt] = modes
+
+	// The bit stream is written backward from the last sequence, so
+	// that the decoder reads it forward.
+	w := bitWriter{out: dst}
+	var state [seqKinds]uint32
+	last := nseq - 1
+	for kind, enc := range encs {
+		if enc != nil {
+			state[kind] = enc.start(e.codes[kind][last])
+		}
+	}
+	e.addExtraBits(&w, last)
+	for i := last - 1; i >= 0; i-- {
+		for _, kind := range [...]int{seqOffset, seqMatchLength, seqLiteralLength} {
+			if enc := encs[kind]; enc != nil {
+				enc.encode(&w, &state[kind], e.codes[kind][i])
+			}
+		}
+		e.addExtraBits(&w, i)
+	}
+	for _, kind := range [...]int{seqMatchLength, seqOffset, seqLiteralLength} {
+		if enc := encs[kind]; enc != nil {
+			enc.flush(&w, state[kind])
+		}
+	}
+	w.close()
+	return w.out, true
+}
+
+// addExtraBits writes the extra bits of sequence i: those of the
+// literal length, the match length and the offset, so that the decoder
+// reads them in the opposite order.
+func (e *encoder) addExtraBits(w *bitWriter, i int) {
+	s := e.seqs[i]
+	llb := literalLengthBase[e.codes[seqLiteralLength][i]]
+	w.addBits(s.litLen-llb.base, uint(llb.bits))
+	mlb := matchLengthBase[e.codes[seqMatchLength][i]]
+	w.addBits(s.matchLen-mlb.base, uint(mlb.bits))
+	ofCode := e.codes[seqOffset][i]
+	w.addBits(s.offset+3-1<<ofCode, uint(ofCode))
+}
+
+// chooseTable picks the cheapest way to encode the codes of the given
+// kind: a single repeated symbol, the predefined distribution, or a
+// distribution of their own, which it appends to dst. It returns the
+// mode and the encoder to use, which is nil for a repeated symbol.
+func (e *encoder) chooseTable(dst []byte, kind int) ([]byte, int, *fseEncoder) {
+	codes := e.codes[kind]
+	info := &seqCodeInfo[kind]
+	var count [53]uint32
+	maxSym := 0
+	distinct := 0
+	for _, c := range codes {
+		if count[c] == 0 {
+			distinct++
+		}
+		count[c]++
+		if int(c) > maxSym {
+			maxSym = int(c)
+		}
+	}
+	if distinct == 1 {
+		return append(dst, codes[0]), modeRLE, nil
+	}
+
+	cost := func(norm []int16, tableLog int) float64 {
+		bits := 0.0
+		for sym, c := range count[:maxSym+1] {
+			if c == 0 {
+				continue
+			}
+			p := float64(norm[sym])
+			if p < 1 {
+				p = 1
+			}
+			bits += float64(c) * (float64(tableLog) - math.Log2(p))
+		}
+		return bits
+	}
+	predefCost := cost(info.predef, info.predefLog)
+	if len(codes) < 32 {
+		return dst, modePredefined, &predefEncoders[kind]
+	}
+
+	// Pick an accuracy log suited to the number of codes and symbols.
+	n := len(codes)
+	tableLog := info.maxLog
+	if l := bits.Len(uint(n-1)) - 1 - 2; l < tableLog {
+		tableLog = l
+	}
+	minLog := bits.Len(uint(n-1)) - 1 + 1
+	if l := bits.Len(uint(maxSym)) - 1 + 2; l < minLog {
+		minLog = l
+	}
+	if minLog > tableLog {
+		tableLog = minLog
+	}
+	if tableLog < minTableLog {
+		tableLog = minTableLog
+	}
+	if tableLog > info.maxLog {
+		tableLog = info.maxLog
+	}
+
+	var norm [53]int16
+	normalizeCounts(count[:maxSym+1], uint32(n), tableLog, norm[:])
+	w := bitWriter{out: dst}
+	writeFSE(&w, norm[:maxSym+1], tableLog)
+	if 8*float64(len(w.out)-len(dst))+cost(norm[:], tableLog) >= predefCost {
+		return dst, modePredefined, &predefEncoders[kind]
+	}
+	e.fse[kind].init(norm[:maxSym+1], tableLog)
+	return w.out, modeCompressed, &e.fse[kind]
+}
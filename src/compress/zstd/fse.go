@@ -0,0 +1,344 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import "math/bits"
+
+// minTableLog is the smallest accuracy log of an FSE table description.
+const minTableLog = 5
+
+// An fseEntry is one state of an FSE decoding table. Decoding the state
+// produces sym; the next state is base plus the next bits bits of the
+// stream.
+type fseEntry struct {
+	sym  uint8
+	bits uint8
+	base uint16
+}
+
+// readFSE reads an FSE table description (section 4.1.1) from the start
+// of data into norm, which must have room for maxSym+1 entries. It
+// returns the accuracy log, the number of symbols described, and the
+// number of bytes of data consumed.
+func readFSE(data []byte, maxSym, maxLog int, norm []int16) (tableLog, nsym, n int, err error) {
+	br := forwardBitReader{data: data}
+	tableLog = int(br.read(4)) + minTableLog
+	if tableLog > maxLog {
+		return 0, 0, 0, corruptError("FSE accuracy log too large")
+	}
+
+	remaining := 1<<tableLog + 1
+	threshold := 1 << tableLog
+	nbBits := tableLog + 1
+	prev0 := false
+	sym := 0
+	for remaining > 1 && sym <= maxSym {
+		if prev0 {
+			// A zero probability is followed by a count of
+			// further zero probabilities, in 2-bit increments.
+			n0 := sym
+			for {
+				r := int(br.read(2))
+				n0 += r
+				if r != 3 {
+					break
+				}
+				if br.overrun() {
+					return 0, 0, 0, corruptError("truncated FSE table")
+				}
+			}
+			if n0 > maxSym {
+				return 0, 0, 0, corruptError("too many symbols in FSE table")
+			}
+			for ; sym < n0; sym++ {
+				norm[sym] = 0
+			}
+		}
+
+		max := 2*threshold - 1 - remaining
+		var count int
+		if low := int(br.peek(nbBits - 1)); low < max {
+			count = low
+			br.pos += nbBits - 1
+		} else {
+			count = int(br.read(nbBits))
+			if count >= threshold {
+				count -= max
+			}
+		}
+		count-- // -1 means "less than 1"
+		if count < 0 {
+			remaining--
+		} else {
+			remaining -= count
+		}
+		if remaining < 1 {
+			return 0, 0, 0, corruptError("invalid FSE table probabilities")
+		}
+		norm[sym] = int16(count)
+		sym++
+		prev0 = count == 0
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+	if remaining != 1 || br.overrun() {
+		return 0, 0, 0, corruptError("invalid FSE table description")
+	}
+	return tableLog, sym, (br.pos + 7) / 8, nil
+}
+
+// spreadFSE distributes the symbols of the normalized distribution
+// norm over a table of 1<<tableLog states as described in section
+// 4.1.1, calling set for each state and its symbol. It reports whether
+// the distribution filled the table exactly.
+func spreadFSE(norm []int16, tableLog int, set func(state, sym int)) bool {
+	size := 1 << tableLog
+	high := size - 1
+	for sym, c := range norm {
+		if c == -1 {
+			set(high, sym)
+			high--
+		}
+	}
+	step := size>>1 + size>>3 + 3
+	mask := size - 1
+	pos := 0
+	for sym, c := range norm {
+		for i := 0; i < int(c); i++ {
+			set(pos, sym)
+			pos = (pos + step) & mask
+			for pos > high {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+	return pos == 0
+}
+
+// buildFSE builds the decoding table for the normalized distribution
+// norm into table, which must have room for 1<<tableLog entries.
+func buildFSE(norm []int16, tableLog int, table []fseEntry) error {
+	var next [256]uint16
+	for sym, c := range norm {
+		if c == -1 {
+			next[sym] = 1
+		} else if c > 0 {
+			next[sym] = uint16(c)
+		}
+	}
+	seen := 0
+	ok := spreadFSE(norm, tableLog, func(state, sym int) {
+		if state < len(table) {
+			table[state].sym = uint8(sym)
+			seen++
+		}
+	})
+	if !ok || seen != 1<<tableLog {
+		return corruptError("invalid FSE distribution")
+	}
+	size := uint16(1 << tableLog)
+	for i := range table[:size] {
+		sym := table[i].sym
+		ns := next[sym]
+		next[sym]++
+		nb := uint8(tableLog - (bits.Len16(ns) - 1))
+		table[i].bits = nb
+		table[i].base = ns<<nb - size
+	}
+	return nil
+}
+
+// An fseEncoder holds the encoding tables for a normalized distribution.
+type fseEncoder struct {
+	tableLog   uint
+	stateTable []uint16
+	symbols    []fseSymbolTransform
+}
+
+// An fseSymbolTransform gives the parameters for encoding one symbol.
+type fseSymbolTransform struct {
+	deltaNbBits    uint32
+	deltaFindState int32
+}
+
+// init builds the encoding tables for the normalized distribution norm,
+// which must be valid.
+func (e *fseEncoder) init(norm []int16, tableLog int) {
+	size := 1 << tableLog
+	e.tableLog = uint(tableLog)
+	if cap(e.stateTable) < size {
+		e.stateTable = make([]uint16, size)
+	}
+	e.stateTable = e.stateTable[:size]
+	if cap(e.symbols) < len(norm) {
+		e.symbols = make([]fseSymbolTransform, len(norm))
+	}
+	e.symbols = e.symbols[:len(norm)]
+
+	var tableSymbol [1 << 9]uint8
+	spreadFSE(norm, tableLog, func(state, sym int) {
+		tableSymbol[state] = uint8(sym)
+	})
+
+	var cumul [256]int
+	for sym := 1; sym < len(norm); sym++ {
+		c := int(norm[sym-1])
+		if c == -1 {
+			c = 1
+		}
+		cumul[sym] = cumul[sym-1] + c
+	}
+	for u := 0; u < size; u++ {
+		sym := tableSymbol[u]
+		e.stateTable[cumul[sym]] = uint16(size + u)
+		cumul[sym]++
+	}
+
+	total := int32(0)
+	for sym, c := range norm {
+		t := &e.symbols[sym]
+		switch c {
+		case 0:
+			t.deltaNbBits = uint32(tableLog+1)<<16 - uint32(size)
+		case -1, 1:
+			t.deltaNbBits = uint32(tableLog)<<16 - uint32(size)
+			t.deltaFindState = total - 1
+			total++
+		default:
+			maxBitsOut := uint32(tableLog - (bits.Len16(uint16(c-1)) - 1))
+			minStatePlus := uint32(c) << maxBitsOut
+			t.deltaNbBits = maxBitsOut<<16 - minStatePlus
+			t.deltaFindState = total - int32(c)
+			total += int32(c)
+		}
+	}
+}
+
+// start returns the initial encoder state for encoding sym last.
+func (e *fseEncoder) start(sym uint8) uint32 {
+	t := e.symbols[sym]
+	nbBitsOut := (t.deltaNbBits + 1<<15) >> 16
+	v := nbBitsOut<<16 - t.deltaNbBits
+	return uint32(e.stateTable[int32(v>>nbBitsOut)+t.deltaFindState])
+}
+
+// encode writes the bits that take the decoder from the state for sym
+// back to the current state, and updates state to that for sym.
+func (e *fseEncoder) encode(w *bitWriter, state *uint32, sym uint8) {
+	t := e.symbols[sym]
+	nb := (*state + t.deltaNbBits) >> 16
+	w.addBits(*state, uint(nb))
+	*state = uint32(e.stateTable[int32(*state>>nb)+t.deltaFindState])
+}
+
+// flush writes the final state, which is the decoder's initial state.
+func (e *fseEncoder) flush(w *bitWriter, state uint32) {
+	w.addBits(state, e.tableLog)
+}
+
+// normalizeCounts scales the symbol frequencies in count, which sum to
+// total, to a distribution over 1<<tableLog states in norm. Every symbol
+// that occurs gets a probability of at least 1.
+func normalizeCounts(count []uint32, total uint32, tableLog int, norm []int16) {
+	size := 1 << tableLog
+	sum := 0
+	largest := -1
+	for sym, c := range count {
+		if c == 0 {
+			norm[sym] = 0
+			continue
+		}
+		n := int((uint64(c)*uint64(size) + uint64(total)/2) / uint64(total))
+		if n < 1 {
+			n = 1
+		}
+		norm[sym] = int16(n)
+		sum += n
+		if largest < 0 || c > count[largest] {
+			largest = sym
+		}
+	}
+	for sum < size {
+		norm[largest]++
+		sum++
+	}
+	for sum > size {
+		// Take from the symbol that can best afford it.
+		best := -1
+		for sym, n := range norm[:len(count)] {
+			if n > 1 && (best < 0 || n > norm[best]) {
+				best = sym
+			}
+		}
+		norm[best]--
+		sum--
+	}
+}
+
+// writeFSE appends the FSE table description (section 4.1.1) of the
+// normalized distribution norm to w.
+func writeFSE(w *bitWriter, norm []int16, tableLog int) {
+	w.addBits(uint32(tableLog-minTableLog), 4)
+	remaining := 1<<tableLog + 1
+	threshold := 1 << tableLog
+	nbBits := tableLog + 1
+	prev0 := false
+	sym := 0
+	for sym < len(norm) && remaining > 1 {
+		if prev0 {
+			start := sym
+			for sym < len(norm) && norm[sym] == 0 {
+				sym++
+			}
+			for sym >= start+3 {
+				start += 3
+				w.addBits(3, 2)
+			}
+			w.addBits(uint32(sym-start), 2)
+		}
+		count := int(norm[sym])
+		sym++
+		max := 2*threshold - 1 - remaining
+		if count < 0 {
+			remaining += count
+		} else {
+			remaining -= count
+		}
+		count++
+		if count >= threshold {
+			count += max
+		}
+		if count < max {
+			w.addBits(uint32(count), uint(nbBits-1))
+		} else {
+			w.addBits(uint32(count), uint(nbBits))
+		}
+		prev0 = count == 1
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+	w.flush()
+}
+
+// Predefined decoding and encoding tables for each kind of sequence code.
+var (
+	predefDecoders [seqKinds][]fseEntry
+	predefEncoders [seqKinds]fseEncoder
+)
+
+func init() {
+	for kind, info := range seqCodeInfo {
+		t := make([]fseEntry, 1<<info.predefLog)
+		if err := buildFSE(info.predef, info.predefLog, t); err != nil {
+			panic(err)
+		}
+		predefDecoders[kind] = t
+		predefEncoders[kind].init(info.predef, info.predefLog)
+	}
+}
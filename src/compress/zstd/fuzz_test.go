@@ -0,0 +1,59 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func FuzzReader(f *testing.F) {
+	inp := []byte("Hello, gophers! Hello, gophers! Hello, gophers!")
+	for _, level := range []int{BestSpeed, DefaultCompression, BestCompression} {
+		var b bytes.Buffer
+		w, err := NewWriterLevel(&b, level)
+		if err != nil {
+			f.Fatal(err)
+		}
+		if _, err := w.Write(inp); err != nil {
+			f.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b.Bytes())
+	}
+
+	files, err := os.ReadDir("testdata")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".zst") {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join("testdata", file.Name()))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for _, multistream := range []bool{true, false} {
+			r, err := NewReader(bytes.NewReader(b))
+			if err != nil {
+				continue
+			}
+			r.Multistream(multistream)
+			io.Copy(io.Discard, r)
+			r.Close()
+		}
+	})
+}
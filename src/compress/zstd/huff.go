@@ -0,0 +1,415 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"math/bits"
+	"sort"
+)
+
+const (
+	// maxHuffBits is the longest Huffman code allowed (section 4.2.1).
+	maxHuffBits = 11
+
+	// maxWeightLog is the largest accuracy log of the FSE table used
+	// to compress Huffman weights.
+	maxWeightLog = 6
+)
+
+// A huffEntry is one entry of a Huffman decoding table: the symbol in
+// the high byte and the code length in the low byte.
+type huffEntry uint16
+
+// readHuff reads a Huffman tree description (section 4.2.1) from the
+// start of data into table, which must have room for 1<<maxHuffBits
+// entries. It returns the number of bits indexing the table and the
+// number of bytes of data consumed.
+func readHuff(data []byte, table []huffEntry) (tableBits, n int, err error) {
+	if len(data) == 0 {
+		return 0, 0, corruptError("missing Huffman tree description")
+	}
+	var weights [256]uint8
+	nw := 0
+	hdr := int(data[0])
+	if hdr < 128 {
+		// FSE-compressed weights.
+		if 1+hdr > len(data) {
+			return 0, 0, corruptError("truncated Huffman tree description")
+		}
+		nw, err = readHuffWeights(data[1:1+hdr], weights[:])
+		if err != nil {
+			return 0, 0, err
+		}
+		n = 1 + hdr
+	} else {
+		// Weights stored directly, 4 bits each.
+		nw = hdr - 127
+		n = 1 + (nw+1)/2
+		if n > len(data) {
+			return 0, 0, corruptError("truncated Huffman tree description")
+		}
+		for i := 0; i < nw; i++ {
+			b := data[1+i/2]
+			if i%2 == 0 {
+				b >>= 4
+			}
+			weights[i] = b & 0xf
+		}
+	}
+
+	// The weight of the last symbol is implied by the others.
+	var rankCount [maxHuffBits + 2]int
+	total := 0
+	for _, w := range weights[:nw] {
+		if w > maxHuffBits {
+			return 0, 0, corruptError("invalid Huffman weight")
+		}
+		rankCount[w]++
+		if w > 0 {
+			total += 1 << (w - 1)
+		}
+	}
+	if total == 0 || nw >= 256 {
+		return 0, 0, corruptError("invalid Huffman weights")
+	}
+	tableBits = bits.Len(uint(total))
+	if tableBits > maxHuffBits {
+		return 0, 0, corruptError("Huffman table too large")
+	}
+	left := 1<<tableBits - total
+	if left&(left-1) != 0 {
+		return 0, 0, corruptError("invalid Huffman weights")
+	}
+	last := bits.Len(uint(left))
+	weights[nw] = uint8(last)
+	rankCount[last]++
+	nw++
+	if rankCount[1] < 2 || rankCount[1]&1 != 0 {
+		return 0, 0, corruptError("invalid Huffman weights")
+	}
+
+	// Symbols are assigned codes in order of increasing weight, then
+	// increasing symbol value. A symbol of weight w fills 1<<(w-1)
+	// consecutive table entries.
+	var rankStart [maxHuffBits + 2]int
+	start := 0
+	for w := 1; w <= tableBits; w++ {
+		rankStart[w] = start
+		start += rankCount[w] << (w - 1)
+	}
+	for sym, w := range weights[:nw] {
+		if w == 0 {
+			continue
+		}
+		e := huffEntry(sym<<8 | (tableBits + 1 - int(w)))
+		length := 1 << (w - 1)
+		pos := rankStart[w]
+		for i := pos; i < pos+length; i++ {
+			table[i] = e
+		}
+		rankStart[w] += length
+	}
+	return tableBits, n, nil
+}
+
+// readHuffWeights decodes FSE-compressed Huffman weights from data into
+// weights and returns the number of weights.
+func readHuffWeights(data []byte, weights []uint8) (int, error) {
+	var norm [256]int16
+	tableLog, nsym, n, err := readFSE(data, 255, maxWeightLog, norm[:])
+	if err != nil {
+		return 0, err
+	}
+	var table [1 << maxWeightLog]fseEntry
+	if err := buildFSE(norm[:nsym], tableLog, table[:]); err != nil {
+		return 0, err
+	}
+
+	// Two interleaved states share the bit stream, which ends when a
+	// state update runs past its start.
+	var br reverseBitReader
+	if err := br.init(data[n:]); err != nil {
+		return 0, err
+	}
+	s1 := br.getBitsPad(uint(tableLog))
+	s2 := br.getBitsPad(uint(tableLog))
+	if br.overflow {
+		return 0, corruptError("truncated Huffman weights")
+	}
+	nw := 0
+	for {
+		if nw+2 > len(weights)-1 {
+			return 0, corruptError("too many Huffman weights")
+		}
+		e := table[s1]
+		weights[nw] = e.sym
+		nw++
+		s1 = uint32(e.base) + br.getBitsPad(uint(e.bits))
+		if br.overflow {
+			weights[nw] = table[s2].sym
+			nw++
+			break
+		}
+		e = table[s2]
+		weights[nw] = e.sym
+		nw++
+		s2 = uint32(e.base) + br.getBitsPad(uint(e.bits))
+		if br.overflow {
+			weights[nw] = table[s1].sym
+			nw++
+			break
+		}
+	}
+	return nw, nil
+}
+
+// decodeHuff decodes len(out) symbols from the Huffman-coded stream in
+// data into out.
+func decodeHuff(data []byte, table []huffEntry, tableBits int, out []byte) error {
+	var br reverseBitReader
+	if err := br.init(data); err != nil {
+		return err
+	}
+	tb := uint(tableBits)
+	for i := range out {
+		e := table[br.peek(tb)]
+		if err := br.skip(uint(e & 0xff)); err != nil {
+			return err
+		}
+		out[i] = byte(e >> 8)
+	}
+	if !br.done() {
+		return corruptError("extra bits in Huffman stream")
+	}
+	return nil
+}
+
+// A huffEncoder holds the Huffman code for a block of literals.
+type huffEncoder struct {
+	codes   [256]uint16
+	lengths [256]uint8
+	maxBits int
+}
+
+// build constructs a length-limited Huffman code for the symbol
+// frequencies in count and appends its tree description to dst. It
+// reports false if no useful code could be built.
+func (h *huffEncoder) build(dst []byte, count *[256]uint32) ([]byte, bool) {
+	maxSym := -1
+	nsym := 0
+	for sym, c := range count {
+		if c > 0 {
+			maxSym = sym
+			nsym++
+		}
+	}
+	if nsym < 2 {
+		return dst, false
+	}
+
+	// Build a Huffman code, halving the frequencies until it fits in
+	// maxHuffBits.
+	var freq [256]uint32
+	copy(freq[:], count[:])
+	for {
+		h.maxBits = huffLengths(&freq, &h.lengths)
+		if h.maxBits <= maxHuffBits {
+			break
+		}
+		for i, f := range freq {
+			if f > 0 {
+				freq[i] = f/2 | 1
+			}
+		}
+	}
+
+	// Convert to weights and assign the codes the decoder derives
+	// from them.
+	var weights [256]uint8
+	var rankCount [maxHuffBits + 2]int
+	for sym := 0; sym <= maxSym; sym++ {
+		if l := h.lengths[sym]; l > 0 {
+			w := uint8(h.maxBits + 1 - int(l))
+			weights[sym] = w
+			rankCount[w]++
+		}
+	}
+	var rankStart [maxHuffBits + 2]int
+	start := 0
+	for w := 1; w <= h.maxBits; w++ {
+		rankStart[w] = start
+		start += rankCount[w] << (w - 1)
+	}
+	for sym := 0; sym <= maxSym; sym++ {
+		if w := weights[sym]; w > 0 {
+			h.codes[sym] = uint16(rankStart[w] >> (w - 1))
+			rankStart[w] += 1 << (w - 1)
+		}
+	}
+
+	// The last weight is implied, so maxSym weights are written.
+	if desc, ok := compressHuffWeights(weights[:maxSym]); ok && (len(desc) < (maxSym+1)/2 || maxSym > 128) {
+		return append(append(dst, byte(len(desc))), desc...), true
+	}
+	if maxSym > 128 {
+		return dst, false
+	}
+	dst = append(dst, byte(127+maxSym))
+	for i := 0; i < maxSym; i += 2 {
+		b := weights[i] << 4
+		if i+1 < maxSym {
+			b |= weights[i+1]
+		}
+		dst = append(dst, b)
+	}
+	return dst, true
+}
+
+// compressHuffWeights returns the FSE-compressed form of weights, and
+// reports whether it could be produced and decodes correctly.
+func compressHuffWeights(weights []uint8) ([]byte, bool) {
+	if len(weights) < 2 {
+		return nil, false
+	}
+	var count [maxHuffBits + 1]uint32
+	distinct := 0
+	for _, w := range weights {
+		if count[w] == 0 {
+			distinct++
+		}
+		count[w]++
+	}
+	if distinct < 2 {
+		return nil, false
+	}
+	maxW := maxHuffBits
+	for count[maxW] == 0 {
+		maxW--
+	}
+	tableLog := maxWeightLog
+	if len(weights) < 1<<minTableLog {
+		tableLog = minTableLog
+	}
+	var norm [maxHuffBits + 1]int16
+	normalizeCounts(count[:maxW+1], uint32(len(weights)), tableLog, norm[:])
+
+	var w bitWriter
+	writeFSE(&w, norm[:maxW+1], tableLog)
+	var e fseEncoder
+	e.init(norm[:maxW+1], tableLog)
+
+	// Encode backward with two interleaved states, as readHuffWeights
+	// decodes them.
+	ws := bitWriter{out: w.out}
+	i := len(weights)
+	var s1, s2 uint32
+	if i%2 != 0 {
+		i--
+		s1 = e.start(weights[i])
+		i--
+		s2 = e.start(weights[i])
+		i--
+		e.encode(&ws, &s1, weights[i])
+	} else {
+		i--
+		s2 = e.start(weights[i])
+		i--
+		s1 = e.start(weights[i])
+	}
+	for i > 0 {
+		i--
+		e.encode(&ws, &s2, weights[i])
+		i--
+		e.encode(&ws, &s1, weights[i])
+	}
+	e.flush(&ws, s2)
+	e.flush(&ws, s1)
+	ws.close()
+	if len(ws.out) >= 128 {
+		return nil, false
+	}
+
+	// Some distributions do not terminate where the decoder expects;
+	// check the round trip.
+	var got [256]uint8
+	n, err := readHuffWeights(ws.out, got[:])
+	if err != nil || n != len(weights) {
+		return nil, false
+	}
+	for i, w := range weights {
+		if got[i] != w {
+			return nil, false
+		}
+	}
+	return ws.out, true
+}
+
+// huffLengths computes Huffman code lengths for the symbols with
+// nonzero frequency in freq, stores them in lengths, and returns the
+// longest.
+func huffLengths(freq *[256]uint32, lengths *[256]uint8) int {
+	type node struct {
+		freq   uint64
+		parent int
+	}
+	var leaves []int
+	for sym, f := range freq {
+		lengths[sym] = 0
+		if f > 0 {
+			leaves = append(leaves, sym)
+		}
+	}
+	sort.SliceStable(leaves, func(i, j int) bool { return freq[leaves[i]] < freq[leaves[j]] })
+
+	// The two-queue method: nodes[:len(leaves)] are the leaves in
+	// order of frequency, and internal nodes are appended in order of
+	// frequency as they are created.
+	nodes := make([]node, len(leaves), 2*len(leaves))
+	for i, sym := range leaves {
+		nodes[i] = node{freq: uint64(freq[sym]), parent: -1}
+	}
+	nextLeaf, nextInternal := 0, len(leaves)
+	pick := func() int {
+		if nextLeaf < len(leaves) && (nextInternal >= len(nodes) || nodes[nextLeaf].freq <= nodes[nextInternal].freq) {
+			nextLeaf++
+			return nextLeaf - 1
+		}
+		nextInternal++
+		return nextInternal - 1
+	}
+	for i := 1; i < len(leaves); i++ {
+		a, b := pick(), pick()
+		nodes = append(nodes, node{freq: nodes[a].freq + nodes[b].freq, parent: -1})
+		nodes[a].parent = len(nodes) - 1
+		nodes[b].parent = len(nodes) - 1
+	}
+
+	// Internal nodes have higher indexes than their children, so depths
+	// can be computed from the root down.
+	depth := make([]int, len(nodes))
+	for i := len(nodes) - 2; i >= 0; i-- {
+		depth[i] = depth[nodes[i].parent] + 1
+	}
+	max := 0
+	for i, sym := range leaves {
+		lengths[sym] = uint8(depth[i])
+		if depth[i] > max {
+			max = depth[i]
+		}
+	}
+	return max
+}
+
+// encode appends the Huffman coding of src to dst as a single
+// stream.
+func (h *huffEncoder) encode(dst, src []byte) []byte {
+	w := bitWriter{out: dst}
+	for i := len(src) - 1; i >= 0; i-- {
+		c := src[i]
+		w.addBits(uint32(h.codes[c]), uint(h.lengths[c]))
+	}
+	w.close()
+	return w.out
+}
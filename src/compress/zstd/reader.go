@@ -0,0 +1,382 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"io"
+)
+
+// A Reader is an io.Reader that can be read to retrieve uncompressed
+// data from a Zstandard stream.
+//
+// A Zstandard stream can be a concatenation of frames. Reads from the
+// Reader return the concatenation of the uncompressed data of each,
+// and skippable frames are ignored.
+//
+// Frames may carry a checksum of their uncompressed data. The Reader
+// returns ErrChecksum when Read reaches the end of a frame whose data
+// does not match its checksum. Clients should treat data returned by
+// Read as tentative until they receive the io.EOF marking the end of
+// the data.
+type Reader struct {
+	r           io.Reader
+	dict        *dict
+	multistream bool
+	err         error
+	buf         [18]byte
+
+	// Frame state.
+	inFrame     bool
+	hasChecksum bool
+	windowSize  int
+	blockMax    int
+	contentSize int64 // -1 if unknown
+	decoded     int64
+	digest      xxhash64
+
+	// hist holds the window: previously decompressed data that later
+	// blocks may refer to. The data in hist[off:] has not yet been
+	// returned by Read.
+	hist []byte
+	off  int
+
+	// Block decoding state.
+	compressed []byte
+	literals   []byte
+	huffTable  []huffEntry
+	huffBits   int // 0 if there is no Huffman table
+	seqTables  [seqKinds]seqTable
+	fseTables  [seqKinds][]fseEntry
+	rleTables  [seqKinds][1]fseEntry
+	reps       [3]uint32
+}
+
+// NewReader creates a new Reader reading the given reader. It reads the
+// header of the first frame, and returns an error if it is invalid.
+//
+// It is the caller's responsibility to call Close on the Reader when done.
+func NewReader(r io.Reader) (*Reader, error) {
+	return NewReaderDict(r, nil)
+}
+
+// NewReaderDict is like NewReader but decompresses using the given
+// dictionary, which may be either in the format produced by
+// "zstd --train" or raw content. Frames that name a dictionary ID must
+// name the ID of dict.
+func NewReaderDict(r io.Reader, dict []byte) (*Reader, error) {
+	z := new(Reader)
+	if dict != nil {
+		d, err := parseDict(dict)
+		if err != nil {
+			return nil, err
+		}
+		z.dict = d
+	}
+	if err := z.Reset(r); err != nil {
+		return nil, err
+	}
+	return z, nil
+}
+
+// Reset discards the Reader z's state and makes it equivalent to the
+// result of its original state from NewReader or NewReaderDict, but
+// reading from r instead. This permits reusing a Reader rather than
+// allocating a new one.
+func (z *Reader) Reset(r io.Reader) error {
+	*z = Reader{
+		r:           r,
+		dict:        z.dict,
+		multistream: true,
+		hist:        z.hist[:0],
+		compressed:  z.compressed[:0],
+		literals:    z.literals[:0],
+		huffTable:   z.huffTable,
+		fseTables:   z.fseTables,
+	}
+	z.err = z.readFrameHeader()
+	return z.err
+}
+
+// Multistream controls whether the reader reads all frames of the
+// stream.
+//
+// If enabled (the default), the Reader reads frames until the end of
+// the stream, returning the concatenation of their data.
+//
+// Calling Multistream(false) disables this behavior; when the Reader
+// reaches the end of a frame, Read returns io.EOF, and the underlying
+// reader is left positioned just after the frame. To start the next
+// frame, call z.Reset(r) followed by z.Multistream(false). If there is
+// no next frame, z.Reset(r) will return io.EOF.
+func (z *Reader) Multistream(ok bool) {
+	z.multistream = ok
+}
+
+// readFrameHeader skips any skippable frames and reads the header of
+// the next frame (section 3.1.1.1). It returns io.EOF if the stream ends
+// cleanly before a frame starts.
+func (z *Reader) readFrameHeader() error {
+	for {
+		if _, err := io.ReadFull(z.r, z.buf[:4]); err != nil {
+			return err
+		}
+		magic := le.Uint32(z.buf[:4])
+		if magic == frameMagic {
+			break
+		}
+		if magic&skippableMagicMask != skippableMagic {
+			return ErrHeader
+		}
+		if _, err := io.ReadFull(z.r, z.buf[:4]); err != nil {
+			return noEOF(err)
+		}
+		if _, err := io.CopyN(io.Discard, z.r, int64(le.Uint32(z.buf[:4]))); err != nil {
+			return noEOF(err)
+		}
+	}
+
+	if _, err := io.ReadFull(z.r, z.buf[:1]); err != nil {
+		return noEOF(err)
+	}
+	desc := z.buf[0]
+	fcsFlag := desc >> 6
+	singleSegment := desc&(1<<5) != 0
+	if desc&(1<<3) != 0 {
+		return ErrHeader // reserved bit
+	}
+	z.hasChecksum = desc&(1<<2) != 0
+	dictIDSize := [4]int{0, 1, 2, 4}[desc&3]
+	fcsSize := [4]int{0, 2, 4, 8}[fcsFlag]
+	if fcsFlag == 0 && singleSegment {
+		fcsSize = 1
+	}
+	n := dictIDSize + fcsSize
+	if !singleSegment {
+		n++
+	}
+	if _, err := io.ReadFull(z.r, z.buf[:n]); err != nil {
+		return noEOF(err)
+	}
+	b := z.buf[:n]
+
+	windowSize := 0
+	if !singleSegment {
+		exp, mantissa := b[0]>>3, b[0]&7
+		windowLog := minWindowLog + int(exp)
+		if windowLog > 30 {
+			return ErrHeader
+		}
+		base := 1 << windowLog
+		windowSize = base + base/8*int(mantissa)
+		b = b[1:]
+	}
+
+	var dictID uint32
+	switch dictIDSize {
+	case 1:
+		dictID = uint32(b[0])
+	case 2:
+		dictID = uint32(le.Uint16(b))
+	case 4:
+		dictID = le.Uint32(b)
+	}
+	b = b[dictIDSize:]
+
+	z.contentSize = -1
+	switch fcsSize {
+	case 1:
+		z.contentSize = int64(b[0])
+	case 2:
+		z.contentSize = int64(le.Uint16(b)) + 256
+	case 4:
+		z.contentSize = int64(le.Uint32(b))
+	case 8:
+		z.contentSize = int64(le.Uint64(b))
+		if z.contentSize < 0 {
+			return ErrHeader
+		}
+	}
+	if singleSegment {
+		if z.contentSize > maxWindowSize {
+			return corruptError("window size too large")
+		}
+		windowSize = int(z.contentSize)
+	}
+	if windowSize > maxWindowSize {
+		return corruptError("window size too large")
+	}
+	z.windowSize = windowSize
+	z.blockMax = windowSize
+	if z.blockMax > maxBlockSize {
+		z.blockMax = maxBlockSize
+	}
+
+	// Each frame starts afresh, except for the state a dictionary
+	// provides.
+	z.hist = z.hist[:0]
+	z.off = 0
+	z.huffBits = 0
+	z.seqTables = [seqKinds]seqTable{}
+	z.reps = [3]uint32{1, 4, 8}
+	if dictID != 0 && (z.dict == nil || z.dict.id != dictID) {
+		return ErrDictionary
+	}
+	if d := z.dict; d != nil {
+		z.hist = append(z.hist, d.content...)
+		z.off = len(z.hist)
+		z.reps = d.reps
+		if d.huffBits != 0 {
+			if z.huffTable == nil {
+				z.huffTable = make([]huffEntry, 1<<maxHuffBits)
+			}
+			copy(z.huffTable, d.huffTable)
+			z.huffBits = d.huffBits
+		}
+		z.seqTables = d.seqTables
+	}
+
+	z.decoded = 0
+	z.digest.reset()
+	z.inFrame = true
+	return nil
+}
+
+// Read implements io.Reader, reading uncompressed bytes from its
+// underlying Reader.
+func (z *Reader) Read(p []byte) (n int, err error) {
+	for z.off == len(z.hist) {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.err = z.readBlock()
+	}
+	n = copy(p, z.hist[z.off:])
+	z.off += n
+	return n, nil
+}
+
+// readBlock reads and decompresses the next block of the stream,
+// starting the next frame if necessary.
+func (z *Reader) readBlock() error {
+	if !z.inFrame {
+		if !z.multistream {
+			return io.EOF
+		}
+		if err := z.readFrameHeader(); err != nil {
+			return err
+		}
+	}
+
+	// Drop data that is no longer in the window. Sliding only once
+	// twice the window is held keeps the cost of copying low. A
+	// dictionary stays available until the first slide.
+	if keep := z.windowSize; len(z.hist) >= 2*keep && len(z.hist) > len(z.dictContent()) {
+		n := copy(z.hist, z.hist[len(z.hist)-keep:])
+		z.hist = z.hist[:n]
+		z.off = n
+	}
+
+	if _, err := io.ReadFull(z.r, z.buf[:3]); err != nil {
+		return noEOF(err)
+	}
+	hdr := uint32(z.buf[0]) | uint32(z.buf[1])<<8 | uint32(z.buf[2])<<16
+	last := hdr&1 != 0
+	typ := (hdr >> 1) & 3
+	size := int(hdr >> 3)
+
+	start := len(z.hist)
+	switch typ {
+	case blockRaw:
+		if size > z.blockMax {
+			return corruptError("block too large")
+		}
+		z.reserve(size)
+		z.hist = z.hist[:start+size]
+		if _, err := io.ReadFull(z.r, z.hist[start:]); err != nil {
+			return noEOF(err)
+		}
+	case blockRLE:
+		if size > z.blockMax {
+			return corruptError("block too large")
+		}
+		if _, err := io.ReadFull(z.r, z.buf[:1]); err != nil {
+			return noEOF(err)
+		}
+		for i := 0; i < size; i++ {
+			z.hist = append(z.hist, z.buf[0])
+		}
+	case blockCompressed:
+		if size > z.blockMax {
+			return corruptError("block too large")
+		}
+		if cap(z.compressed) < size {
+			z.compressed = make([]byte, size, maxBlockSize)
+		}
+		z.compressed = z.compressed[:size]
+		if _, err := io.ReadFull(z.r, z.compressed); err != nil {
+			return noEOF(err)
+		}
+		z.reserve(z.blockMax)
+		if err := z.decompressBlock(z.compressed); err != nil {
+			return err
+		}
+	default:
+		return corruptError("reserved block type")
+	}
+
+	out := z.hist[start:]
+	z.decoded += int64(len(out))
+	if z.hasChecksum {
+		z.digest.write(out)
+	}
+	if z.contentSize >= 0 && z.decoded > z.contentSize {
+		return corruptError("frame larger than its content size")
+	}
+	if !last {
+		return nil
+	}
+
+	z.inFrame = false
+	if z.contentSize >= 0 && z.decoded != z.contentSize {
+		return corruptError("frame smaller than its content size")
+	}
+	if z.hasChecksum {
+		if _, err := io.ReadFull(z.r, z.buf[:4]); err != nil {
+			return noEOF(err)
+		}
+		if le.Uint32(z.buf[:4]) != uint32(z.digest.sum64()) {
+			return ErrChecksum
+		}
+	}
+	return nil
+}
+
+// reserve grows z.hist, if necessary, to have room for n more bytes.
+func (z *Reader) reserve(n int) {
+	if cap(z.hist)-len(z.hist) >= n {
+		return
+	}
+	h := make([]byte, len(z.hist), 2*cap(z.hist)+n)
+	copy(h, z.hist)
+	z.hist = h
+}
+
+// dictContent returns the content of the Reader's dictionary, if any.
+func (z *Reader) dictContent() []byte {
+	if z.dict == nil {
+		return nil
+	}
+	return z.dict.content
+}
+
+// Close closes the Reader. It does not close the underlying io.Reader.
+// In order for the checksums to be verified, the reader must be fully
+// consumed until the io.EOF.
+func (z *Reader) Close() error {
+	if z.err == io.EOF {
+		return nil
+	}
+	return z.err
+}
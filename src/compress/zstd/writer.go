@@ -0,0 +1,285 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Compression levels. Higher levels search harder for matches, trading
+// speed for a smaller result. The levels do not produce the same output
+// as the levels of the same number in the reference implementation.
+const (
+	BestSpeed          = 1
+	BestCompression    = 9
+	DefaultCompression = 3
+)
+
+var errWriterClosed = errors.New("zstd: write to closed Writer")
+
+// A Writer is an io.WriteCloser.
+// Writes to a Writer are compressed and written to w.
+//
+// The Writer produces a single frame with a content checksum. Data is
+// compressed in blocks of up to 128 KiB, so writes are buffered until a
+// block is full or Flush or Close is called.
+type Writer struct {
+	w           io.Writer
+	level       int
+	dict        *dict
+	enc         encoder
+	wroteHeader bool
+	closed      bool
+	err         error
+	digest      xxhash64
+
+	// hist holds the data that matches may refer to, followed by the
+	// data in hist[pos:] that has been written but not yet compressed.
+	hist []byte
+	pos  int
+
+	out []byte // scratch space for compressed output
+}
+
+// NewWriter returns a new Writer compressing data at the default level.
+// Writes to the returned writer are compressed and written to w.
+//
+// It is the caller's responsibility to call Close on the Writer when done.
+// Writes may be buffered and not flushed until Close.
+func NewWriter(w io.Writer) *Writer {
+	z, _ := NewWriterLevel(w, DefaultCompression)
+	return z
+}
+
+// NewWriterLevel is like NewWriter but specifies the compression level
+// instead of assuming DefaultCompression.
+//
+// The compression level can be any integer value between BestSpeed and
+// BestCompression inclusive. The error returned will be nil if the
+// level is valid.
+func NewWriterLevel(w io.Writer, level int) (*Writer, error) {
+	return NewWriterDict(w, level, nil)
+}
+
+// NewWriterDict is like NewWriterLevel but compresses using the given
+// dictionary, which may be either in the format produced by
+// "zstd --train" or raw content. The compressed data can only be read
+// by a Reader using the same dictionary.
+func NewWriterDict(w io.Writer, level int, dict []byte) (*Writer, error) {
+	if level < BestSpeed || level > BestCompression {
+		return nil, fmt.Errorf("zstd: invalid compression level: %d", level)
+	}
+	z := &Writer{level: level}
+	if dict != nil {
+		d, err := parseDict(dict)
+		if err != nil {
+			return nil, err
+		}
+		z.dict = d
+	}
+	z.enc.init(level)
+	z.Reset(w)
+	return z, nil
+}
+
+// Reset discards the Writer z's state and makes it equivalent to the
+// result of its original state from NewWriter, NewWriterLevel or
+// NewWriterDict, but writing to w instead. This permits reusing a Writer
+// rather than allocating a new one.
+func (z *Writer) Reset(w io.Writer) {
+	z.w = w
+	z.wroteHeader = false
+	z.closed = false
+	z.err = nil
+	z.digest.reset()
+	z.hist = z.hist[:0]
+	z.enc.reset()
+	if z.dict != nil {
+		z.hist = append(z.hist, z.dict.content...)
+		z.enc.insert(z.hist, len(z.hist))
+	}
+	z.pos = len(z.hist)
+}
+
+// Write writes a compressed form of p to the underlying io.Writer. The
+// compressed bytes are not necessarily flushed until the Writer is
+// closed.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.closed {
+		return 0, errWriterClosed
+	}
+	n := 0
+	for len(p) > 0 {
+		room := maxBlockSize - (len(z.hist) - z.pos)
+		if room > len(p) {
+			room = len(p)
+		}
+		z.hist = append(z.hist, p[:room]...)
+		z.digest.write(p[:room])
+		n += room
+		p = p[room:]
+		if len(z.hist)-z.pos == maxBlockSize {
+			if z.err = z.writeBlock(false); z.err != nil {
+				return n, z.err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Flush compresses any pending data and writes it to the underlying
+// writer, so that a reader can decompress everything written so far.
+// Flush does not return until the data has been written. If the
+// underlying writer returns an error, Flush returns that error.
+func (z *Writer) Flush() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	if !z.wroteHeader || len(z.hist) > z.pos {
+		z.err = z.writeBlock(false)
+	}
+	return z.err
+}
+
+// Close closes the Writer by flushing any unwritten data to the
+// underlying io.Writer and writing the end of the frame. It does not
+// close the underlying io.Writer.
+func (z *Writer) Close() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	z.closed = true
+	if z.err = z.writeBlock(true); z.err != nil {
+		return z.err
+	}
+	var b [4]byte
+	le.PutUint32(b[:], uint32(z.digest.sum64()))
+	_, z.err = z.w.Write(b[:])
+	return z.err
+}
+
+// writeHeader appends the frame header to z.out. If the frame is
+// finished, its size is known and is recorded in the header.
+func (z *Writer) writeHeader(last bool) {
+	var desc byte = 1 << 2 // content checksum
+	var dictID []byte
+	if z.dict != nil && z.dict.id != 0 {
+		id := z.dict.id
+		switch {
+		case id < 1<<8:
+			desc |= 1
+			dictID = []byte{byte(id)}
+		case id < 1<<16:
+			desc |= 2
+			dictID = []byte{byte(id), byte(id >> 8)}
+		default:
+			desc |= 3
+			dictID = []byte{byte(id), byte(id >> 8), byte(id >> 16), byte(id >> 24)}
+		}
+	}
+
+	b := appendUint32(z.out, frameMagic)
+	if !last {
+		b = append(b, desc, byte(z.enc.windowLog-minWindowLog)<<3)
+		z.out = append(b, dictID...)
+		return
+	}
+
+	// The whole frame is a single block: record its size, and let the
+	// window be the frame.
+	size := len(z.hist) - z.pos
+	desc |= 1 << 5 // single segment
+	switch {
+	case size < 256:
+		b = append(append(b, desc), dictID...)
+		b = append(b, byte(size))
+	case size < 65536+256:
+		b = append(append(b, desc|1<<6), dictID...)
+		b = append(b, byte(size-256), byte((size-256)>>8))
+	default:
+		b = append(append(b, desc|2<<6), dictID...)
+		b = appendUint32(b, uint32(size))
+	}
+	z.out = b
+}
+
+// writeBlock compresses the pending data as one block and writes it to
+// the underlying writer, preceded by the frame header if it has not
+// been written yet.
+func (z *Writer) writeBlock(last bool) error {
+	z.out = z.out[:0]
+	if !z.wroteHeader {
+		z.writeHeader(last)
+		z.wroteHeader = true
+	}
+
+	src := z.hist[z.pos:]
+	var lastBit uint32
+	if last {
+		lastBit = 1
+	}
+	hdr := len(z.out)
+	z.out = append(z.out, 0, 0, 0)
+	typ := uint32(blockRaw)
+	size := len(src)
+	switch {
+	case len(src) > 1 && allSame(src):
+		typ = blockRLE
+		z.out = append(z.out, src[0])
+		z.enc.insert(z.hist, len(z.hist))
+	case len(src) > 0:
+		var ok bool
+		z.out, ok = z.enc.compressBlock(z.out, z.hist, z.pos)
+		if ok && len(z.out)-hdr-3 < len(src) {
+			typ = blockCompressed
+			size = len(z.out) - hdr - 3
+		} else {
+			z.out = append(z.out[:hdr+3], src...)
+		}
+	}
+	bh := lastBit | typ<<1 | uint32(size)<<3
+	z.out[hdr], z.out[hdr+1], z.out[hdr+2] = byte(bh), byte(bh>>8), byte(bh>>16)
+
+	if _, err := z.w.Write(z.out); err != nil {
+		return err
+	}
+
+	// Keep at least a window of history, sliding it to the front of
+	// the buffer once twice that much has accumulated.
+	z.pos = len(z.hist)
+	if window := 1 << z.enc.windowLog; z.pos >= 2*window {
+		d := z.enc.slideDistance(z.pos - window)
+		n := copy(z.hist, z.hist[d:])
+		z.hist = z.hist[:n]
+		z.pos = n
+		z.enc.shift(d)
+	}
+	return nil
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+// allSame reports whether all bytes of b are the same.
+func allSame(b []byte) bool {
+	for _, c := range b[1:] {
+		if c != b[0] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import "math/bits"
+
+// xxhash64 computes the 64-bit xxHash of a stream with seed 0, which
+// Zstandard uses for frame content checksums (section 3.1.1).
+type xxhash64 struct {
+	v     [4]uint64
+	total uint64
+	mem   [32]byte
+	n     int // bytes buffered in mem
+}
+
+const (
+	xxPrime1 = 11400714785074694791
+	xxPrime2 = 14029467366897019727
+	xxPrime3 = 1609587929392839161
+	xxPrime4 = 9650029242287828579
+	xxPrime5 = 2870177450012600261
+)
+
+func (h *xxhash64) reset() {
+	p1, p2 := uint64(xxPrime1), uint64(xxPrime2)
+	h.v = [4]uint64{p1 + p2, p2, 0, -p1}
+	h.total = 0
+	h.n = 0
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMergeRound(acc, val uint64) uint64 {
+	acc ^= xxRound(0, val)
+	return acc*xxPrime1 + xxPrime4
+}
+
+func (h *xxhash64) write(b []byte) {
+	h.total += uint64(len(b))
+	if h.n > 0 {
+		n := copy(h.mem[h.n:], b)
+		h.n += n
+		b = b[n:]
+		if h.n < len(h.mem) {
+			return
+		}
+		h.stripes(h.mem[:])
+		h.n = 0
+	}
+	if n := len(b) &^ 31; n > 0 {
+		h.stripes(b[:n])
+		b = b[n:]
+	}
+	h.n = copy(h.mem[:], b)
+}
+
+// stripes consumes b, whose length is a multiple of 32.
+func (h *xxhash64) stripes(b []byte) {
+	v0, v1, v2, v3 := h.v[0], h.v[1], h.v[2], h.v[3]
+	for ; len(b) >= 32; b = b[32:] {
+		v0 = xxRound(v0, le.Uint64(b))
+		v1 = xxRound(v1, le.Uint64(b[8:]))
+		v2 = xxRound(v2, le.Uint64(b[16:]))
+		v3 = xxRound(v3, le.Uint64(b[24:]))
+	}
+	h.v = [4]uint64{v0, v1, v2, v3}
+}
+
+func (h *xxhash64) sum64() uint64 {
+	var acc uint64
+	if h.total >= 32 {
+		v := &h.v
+		acc = bits.RotateLeft64(v[0], 1) + bits.RotateLeft64(v[1], 7) +
+			bits.RotateLeft64(v[2], 12) + bits.RotateLeft64(v[3], 18)
+		for _, x := range v {
+			acc = xxMergeRound(acc, x)
+		}
+	} else {
+		acc = xxPrime5
+	}
+	acc += h.total
+
+	b := h.mem[:h.n]
+	for ; len(b) >= 8; b = b[8:] {
+		acc ^= xxRound(0, le.Uint64(b))
+		acc = bits.RotateLeft64(acc, 27)*xxPrime1 + xxPrime4
+	}
+	if len(b) >= 4 {
+		acc ^= uint64(le.Uint32(b)) * xxPrime1
+		acc = bits.RotateLeft64(acc, 23)*xxPrime2 + xxPrime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		acc ^= uint64(c) * xxPrime5
+		acc = bits.RotateLeft64(acc, 11) * xxPrime1
+	}
+
+	acc ^= acc >> 33
+	acc *= xxPrime2
+	acc ^= acc >> 29
+	acc *= xxPrime3
+	acc ^= acc >> 32
+	return acc
+}
@@ -0,0 +1,168 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zstd implements reading and writing of Zstandard compressed data,
+// as specified in RFC 8878.
+//
+// A Zstandard stream is a sequence of frames. The Reader decompresses
+// every frame in the stream, skipping over skippable frames, and verifies
+// the content checksum of each frame that has one. The Writer produces a
+// single frame with a content checksum.
+//
+// Both the Reader and the Writer accept an optional dictionary, either in
+// the format produced by "zstd --train" or as raw content.
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	frameMagic         = 0xFD2FB528
+	skippableMagic     = 0x184D2A50 // low 4 bits are user-defined
+	skippableMagicMask = 0xFFFFFFF0
+	dictMagic          = 0xEC30A437
+
+	// maxBlockSize is the largest amount of data a block may decompress
+	// to (section 3.1.1.2.3).
+	maxBlockSize = 128 << 10
+
+	// maxWindowSize is the largest window the Reader will allocate.
+	// It matches the default limit of the reference implementation.
+	maxWindowSize = 1 << 27
+
+	// minWindowLog is the smallest window that a window descriptor
+	// can describe.
+	minWindowLog = 10
+)
+
+var (
+	// ErrChecksum is returned when reading Zstandard data whose
+	// content checksum does not match the decompressed data.
+	ErrChecksum = errors.New("zstd: invalid checksum")
+	// ErrHeader is returned when reading Zstandard data that has an
+	// invalid frame header.
+	ErrHeader = errors.New("zstd: invalid header")
+	// ErrDictionary is returned when reading a frame that requires a
+	// dictionary other than the one given to the Reader, or when a
+	// dictionary is malformed.
+	ErrDictionary = errors.New("zstd: invalid or missing dictionary")
+)
+
+var le = binary.LittleEndian
+
+// noEOF converts io.EOF to io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// A corruptError reports invalid compressed data.
+type corruptError string
+
+func (e corruptError) Error() string {
+	return "zstd: corrupt input: " + string(e)
+}
+
+// Block types (section 3.1.1.2.2).
+const (
+	blockRaw        = 0
+	blockRLE        = 1
+	blockCompressed = 2
+)
+
+// Literals block types (section 3.1.1.3.1.1).
+const (
+	literalsRaw        = 0
+	literalsRLE        = 1
+	literalsCompressed = 2
+	literalsTreeless   = 3
+)
+
+// Symbol compression modes of the sequences section (section 3.1.1.3.2.1).
+const (
+	modePredefined = 0
+	modeRLE        = 1
+	modeCompressed = 2
+	modeRepeat     = 3
+)
+
+// The kinds of sequence codes, in the order in which their table
+// descriptions appear in a sequences section.
+const (
+	seqLiteralLength = iota
+	seqOffset
+	seqMatchLength
+	seqKinds
+)
+
+// seqCodeInfo describes the code alphabet of each kind of sequence code.
+var seqCodeInfo = [seqKinds]struct {
+	maxSym    int
+	maxLog    int
+	predefLog int
+	predef    []int16
+}{
+	seqLiteralLength: {
+		maxSym:    35,
+		maxLog:    9,
+		predefLog: 6,
+		predef: []int16{
+			4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+			2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+			-1, -1, -1, -1,
+		},
+	},
+	seqOffset: {
+		maxSym:    31,
+		maxLog:    8,
+		predefLog: 5,
+		predef: []int16{
+			1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+		},
+	},
+	seqMatchLength: {
+		maxSym:    52,
+		maxLog:    9,
+		predefLog: 6,
+		predef: []int16{
+			1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+			-1, -1, -1, -1, -1,
+		},
+	},
+}
+
+// A codeBase gives the baseline value and number of extra bits of a
+// literal length or match length code.
+type codeBase struct {
+	base uint32
+	bits uint8
+}
+
+// literalLengthBase is indexed by literal length code (section 3.1.1.3.2.1.1).
+var literalLengthBase = [36]codeBase{
+	{0, 0}, {1, 0}, {2, 0}, {3, 0}, {4, 0}, {5, 0}, {6, 0}, {7, 0},
+	{8, 0}, {9, 0}, {10, 0}, {11, 0}, {12, 0}, {13, 0}, {14, 0}, {15, 0},
+	{16, 1}, {18, 1}, {20, 1}, {22, 1}, {24, 2}, {28, 2}, {32, 3}, {40, 3},
+	{48, 4}, {64, 6}, {128, 7}, {256, 8}, {512, 9}, {1024, 10}, {2048, 11}, {4096, 12},
+	{8192, 13}, {16384, 14}, {32768, 15}, {65536, 16},
+}
+
+// matchLengthBase is indexed by match length code (section 3.1.1.3.2.1.1).
+var matchLengthBase = [53]codeBase{
+	{3, 0}, {4, 0}, {5, 0}, {6, 0}, {7, 0}, {8, 0}, {9, 0}, {10, 0},
+	{11, 0}, {12, 0}, {13, 0}, {14, 0}, {15, 0}, {16, 0}, {17, 0}, {18, 0},
+	{19, 0}, {20, 0}, {21, 0}, {22, 0}, {23, 0}, {24, 0}, {25, 0}, {26, 0},
+	{27, 0}, {28, 0}, {29, 0}, {30, 0}, {31, 0}, {32, 0}, {33, 0}, {34, 0},
+	{35, 1}, {37, 1}, {39, 1}, {41, 1}, {43, 2}, {47, 2}, {51, 3}, {59, 3},
+	{67, 4}, {83, 4}, {99, 5}, {131, 7}, {259, 8}, {515, 9}, {1027, 10}, {2051, 11},
+	{4099, 12}, {8195, 13}, {16387, 14}, {32771, 15}, {65539, 16},
+}
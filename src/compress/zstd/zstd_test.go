@@ -0,0 +1,384 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testInputs returns a variety of inputs for round-trip tests.
+func testInputs(t testing.TB) map[string][]byte {
+	e, err := os.ReadFile("../testdata/e.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gettysburg, err := os.ReadFile("../testdata/gettysburg.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := make([]byte, 300<<10)
+	rand.New(rand.NewSource(1)).Read(rnd)
+
+	// Text longer than a block, with long-distance repeats.
+	var long bytes.Buffer
+	for i := 0; long.Len() < 1<<20; i++ {
+		long.Write(gettysburg[i%len(gettysburg):])
+		long.Write(e[:i%1000])
+	}
+
+	return map[string][]byte{
+		"empty":      nil,
+		"byte":       {'x'},
+		"short":      []byte("hello, hello, hello, world"),
+		"zeros":      make([]byte, 200<<10),
+		"gettysburg": gettysburg,
+		"e":          e,
+		"random":     rnd,
+		"long":       long.Bytes(),
+		"binary":     bytes.Repeat([]byte{0, 1, 2, 3, 0xfe, 0xff, 0x80, 7, 7, 7}, 20000),
+	}
+}
+
+func compress(t testing.TB, data []byte, level int, dict []byte) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriterDict(&buf, level, dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decompress(data, dict []byte) ([]byte, error) {
+	r, err := NewReaderDict(bytes.NewReader(data), dict)
+	if err != nil {
+		return nil, err
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return out, err
+	}
+	return out, r.Close()
+}
+
+func TestRoundTrip(t *testing.T) {
+	for name, data := range testInputs(t) {
+		for level := BestSpeed; level <= BestCompression; level++ {
+			if testing.Short() && level != BestSpeed && level != DefaultCompression {
+				continue
+			}
+			c := compress(t, data, level, nil)
+			got, err := decompress(c, nil)
+			if err != nil {
+				t.Errorf("%s, level %d: %v", name, level, err)
+				continue
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("%s, level %d: round trip mismatch", name, level)
+			}
+		}
+	}
+}
+
+func TestCompressionRatio(t *testing.T) {
+	e, err := os.ReadFile("../testdata/e.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The digits of e take about 3.32 bits each; Huffman coding of
+	// the literals should get within a few percent of that.
+	if c := compress(t, e, DefaultCompression, nil); len(c) > len(e)/2 {
+		t.Errorf("e.txt compressed to %d bytes, want at most %d", len(c), len(e)/2)
+	}
+	zeros := make([]byte, 1<<20)
+	if c := compress(t, zeros, BestSpeed, nil); len(c) > 100 {
+		t.Errorf("zeros compressed to %d bytes, want at most 100", len(c))
+	}
+}
+
+func TestWriterFlush(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	var want []byte
+	for i := 0; i < 10; i++ {
+		chunk := []byte(strings.Repeat("flush me ", i+1))
+		want = append(want, chunk...)
+		if _, err := w.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Everything written so far can be read back.
+		r, err := NewReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]byte, len(want))
+		if _, err := io.ReadFull(r, got); err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("after Flush %d: got %q, %v; want %q", i, got, err, want)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("x")); err == nil {
+		t.Errorf("Write after Close succeeded")
+	}
+	got, err := decompress(buf.Bytes(), nil)
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("got %q, %v; want %q", got, err, want)
+	}
+}
+
+func TestWriterReset(t *testing.T) {
+	data := []byte(strings.Repeat("reset and reuse ", 100))
+	var buf1, buf2 bytes.Buffer
+	w, err := NewWriterLevel(&buf1, BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data)
+	w.Close()
+	w.Reset(&buf2)
+	w.Write(data)
+	w.Close()
+	if !bytes.Equal(buf1.Bytes(), buf2.Bytes()) {
+		t.Errorf("output after Reset differs")
+	}
+}
+
+func TestInvalidLevel(t *testing.T) {
+	for _, level := range []int{-1, 0, BestCompression + 1} {
+		if _, err := NewWriterLevel(io.Discard, level); err == nil {
+			t.Errorf("NewWriterLevel(%d) succeeded", level)
+		}
+	}
+}
+
+func TestReference(t *testing.T) {
+	// The files in testdata were compressed by the reference
+	// implementation.
+	for _, test := range []struct {
+		file, want, dict string
+	}{
+		{"e.txt.zst", "../testdata/e.txt", ""},
+		{"gettysburg.txt.zst", "../testdata/gettysburg.txt", ""},
+		{"gettysburg.txt.dict.zst", "../testdata/gettysburg.txt", "testdata/dict"},
+	} {
+		c, err := os.ReadFile("testdata/" + test.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := os.ReadFile(test.want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dict []byte
+		if test.dict != "" {
+			if dict, err = os.ReadFile(test.dict); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := decompress(c, dict)
+		if err != nil {
+			t.Errorf("%s: %v", test.file, err)
+		} else if !bytes.Equal(got, want) {
+			t.Errorf("%s: wrong output", test.file)
+		}
+	}
+}
+
+func TestDict(t *testing.T) {
+	formatted, err := os.ReadFile("testdata/dict")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gettysburg, err := os.ReadFile("../testdata/gettysburg.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := gettysburg[:500]
+	for name, dict := range map[string][]byte{"formatted": formatted, "raw": raw} {
+		c := compress(t, gettysburg, DefaultCompression, dict)
+		got, err := decompress(c, dict)
+		if err != nil || !bytes.Equal(got, gettysburg) {
+			t.Errorf("%s dictionary: round trip failed: %v", name, err)
+		}
+		if name == "raw" && len(c) >= len(compress(t, gettysburg, DefaultCompression, nil)) {
+			t.Errorf("raw dictionary did not help")
+		}
+	}
+
+	// A frame that names a dictionary cannot be read without it.
+	c := compress(t, gettysburg, DefaultCompression, formatted)
+	if _, err := decompress(c, nil); err != ErrDictionary {
+		t.Errorf("decompress without dictionary: got %v, want ErrDictionary", err)
+	}
+	if _, err := decompress(c, raw); err != ErrDictionary {
+		t.Errorf("decompress with wrong dictionary: got %v, want ErrDictionary", err)
+	}
+}
+
+// skippableFrame returns a skippable frame holding data.
+func skippableFrame(data []byte) []byte {
+	b := make([]byte, 8, 8+len(data))
+	le.PutUint32(b, skippableMagic+3)
+	le.PutUint32(b[4:], uint32(len(data)))
+	return append(b, data...)
+}
+
+func TestMultipleFrames(t *testing.T) {
+	a := []byte(strings.Repeat("first frame ", 50))
+	b := []byte(strings.Repeat("second frame ", 50))
+	var stream []byte
+	stream = append(stream, skippableFrame([]byte("ignore me"))...)
+	stream = append(stream, compress(t, a, BestSpeed, nil)...)
+	stream = append(stream, skippableFrame(nil)...)
+	stream = append(stream, compress(t, b, BestCompression, nil)...)
+	stream = append(stream, skippableFrame([]byte("trailer"))...)
+
+	got, err := decompress(stream, nil)
+	if want := append(append([]byte(nil), a...), b...); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("multistream: got %q, %v; want %q", got, err, want)
+	}
+
+	// Read the frames one at a time.
+	br := bytes.NewReader(stream)
+	r, err := NewReader(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range [][]byte{a, b} {
+		if i > 0 {
+			if err := r.Reset(br); err != nil {
+				t.Fatal(err)
+			}
+		}
+		r.Multistream(false)
+		got, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("frame %d: got %q, %v; want %q", i, got, err, want)
+		}
+	}
+	if err := r.Reset(br); err != io.EOF {
+		t.Errorf("Reset after last frame: got %v, want io.EOF", err)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	data := []byte(strings.Repeat("check me ", 100))
+	c := compress(t, data, DefaultCompression, nil)
+	c[len(c)-1] ^= 1
+	if _, err := decompress(c, nil); err != ErrChecksum {
+		t.Errorf("got %v, want ErrChecksum", err)
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	data := testInputs(t)["long"]
+	c := compress(t, data, DefaultCompression, nil)
+
+	// Every truncation of the stream is an error.
+	for n := 0; n < len(c); n += 1 + n/8 {
+		got, err := decompress(c[:n], nil)
+		if err == nil {
+			t.Fatalf("truncated to %d bytes: no error", n)
+		}
+		if !bytes.HasPrefix(data, got) {
+			t.Fatalf("truncated to %d bytes: wrong data returned before error", n)
+		}
+	}
+
+	// Flipping bits must not cause a panic or return success with
+	// wrong data.
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		b := append([]byte(nil), c...)
+		b[rnd.Intn(len(b))] ^= 1 << rnd.Intn(8)
+		if got, err := decompress(b, nil); err == nil && !bytes.Equal(got, data) {
+			t.Fatalf("corrupt input decoded without error")
+		}
+	}
+
+	if _, err := decompress([]byte("not zstd data"), nil); err != ErrHeader {
+		t.Errorf("got %v, want ErrHeader", err)
+	}
+	if _, err := NewReader(bytes.NewReader(nil)); err != io.EOF {
+		t.Errorf("NewReader on empty input: got %v, want io.EOF", err)
+	}
+	var ce corruptError
+	if _, err := decompress(append(compress(t, data, 1, nil)[:20], make([]byte, 20)...), nil); !errors.As(err, &ce) && err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v, want corrupt input error", err)
+	}
+}
+
+func TestXXHash(t *testing.T) {
+	// Values from the reference implementation.
+	for _, test := range []struct {
+		in   string
+		want uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"abc", 0x44bc2cf5ad770999},
+		{"message digest", 0x066ed728fceeb3be},
+		{"abcdefghijklmnopqrstuvwxyz", 0xcfe1f278fa89835c},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789", 0xaaa46907d3047814},
+	} {
+		var h xxhash64
+		h.reset()
+		// Write in pieces to exercise buffering.
+		for i := 0; i < len(test.in); i += 7 {
+			end := i + 7
+			if end > len(test.in) {
+				end = len(test.in)
+			}
+			h.write([]byte(test.in[i:end]))
+		}
+		if got := h.sum64(); got != test.want {
+			t.Errorf("xxhash64(%q) = %#x, want %#x", test.in, got, test.want)
+		}
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	data := testInputs(b)["long"]
+	for _, level := range []int{BestSpeed, DefaultCompression, BestCompression} {
+		b.Run(fmt.Sprintf("level%d", level), func(b *testing.B) {
+			w, _ := NewWriterLevel(io.Discard, level)
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				w.Reset(io.Discard)
+				w.Write(data)
+				w.Close()
+			}
+		})
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	data := testInputs(b)["long"]
+	c := compress(b, data, DefaultCompression, nil)
+	r, _ := NewReader(bytes.NewReader(c))
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		r.Reset(bytes.NewReader(c))
+		io.Copy(io.Discard, r)
+	}
+}
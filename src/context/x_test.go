@@ -29,3 +29,16 @@ func TestWithValueChecksKey(t *testing.T)              { XTestWithValueChecksKey
 func TestInvalidDerivedFail(t *testing.T)              { XTestInvalidDerivedFail(t) }
 func TestDeadlineExceededSupportsTimeout(t *testing.T) { XTestDeadlineExceededSupportsTimeout(t) }
 func TestCustomContextGoroutines(t *testing.T)         { XTestCustomContextGoroutines(t) }
+func TestCause(t *testing.T)                           { XTestCause(t) }
+func TestCauseRace(t *testing.T)                       { XTestCauseRace(t) }
+func TestWithoutCancel(t *testing.T)                   { XTestWithoutCancel(t) }
+func TestCustomAfterFuncCancel(t *testing.T)           { XTestCustomAfterFuncCancel(t) }
+func TestCustomAfterFuncTimeout(t *testing.T)          { XTestCustomAfterFuncTimeout(t) }
+func TestCustomAfterFuncUnregister(t *testing.T)       { XTestCustomAfterFuncUnregister(t) }
+func TestCustomAfterFuncGoroutines(t *testing.T)       { XTestCustomAfterFuncGoroutines(t) }
+func TestAfterFuncCalledAfterCancel(t *testing.T)      { XTestAfterFuncCalledAfterCancel(t) }
+func TestAfterFuncCalledAfterTimeout(t *testing.T)     { XTestAfterFuncCalledAfterTimeout(t) }
+func TestAfterFuncCalledImmediately(t *testing.T)      { XTestAfterFuncCalledImmediately(t) }
+func TestAfterFuncNotCalledAfterStop(t *testing.T)     { XTestAfterFuncNotCalledAfterStop(t) }
+func TestAfterFuncCalledAsynchronously(t *testing.T)   { XTestAfterFuncCalledAsynchronously(t) }
+func TestAfterFuncRemovesChild(t *testing.T)           { XTestAfterFuncRemovesChild(t) }
@@ -0,0 +1,153 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdh
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"io"
+)
+
+// nistCurve is a Curve backed by the crypto/elliptic implementation of a NIST
+// curve. The P-256, P-384 and P-521 scalar multiplications of crypto/elliptic
+// are constant-time, and points and scalars are only converted to and from
+// big.Int at the API boundary.
+type nistCurve struct {
+	name  string
+	curve elliptic.Curve
+}
+
+func (c *nistCurve) String() string {
+	return c.name
+}
+
+// byteLen returns the length of scalars and field elements of the curve.
+func (c *nistCurve) byteLen() int {
+	return (c.curve.Params().BitSize + 7) / 8
+}
+
+func (c *nistCurve) GenerateKey(rand io.Reader) (*PrivateKey, error) {
+	key, x, y, err := elliptic.GenerateKey(c.curve, rand)
+	if err != nil {
+		return nil, err
+	}
+	k, err := c.NewPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	// The public key was computed as part of the generation.
+	k.publicKeyOnce.Do(func() {
+		k.publicKey = &PublicKey{
+			curve:     c,
+			publicKey: elliptic.Marshal(c.curve, x, y),
+		}
+	})
+	return k, nil
+}
+
+func (c *nistCurve) NewPrivateKey(key []byte) (*PrivateKey, error) {
+	if len(key) != c.byteLen() {
+		return nil, errors.New("crypto/ecdh: invalid private key size")
+	}
+	order := c.curve.Params().N.FillBytes(make([]byte, c.byteLen()))
+	if isZero(key) || !isLess(key, order) {
+		return nil, errors.New("crypto/ecdh: invalid private key")
+	}
+	return &PrivateKey{
+		curve:      c,
+		privateKey: append([]byte{}, key...),
+	}, nil
+}
+
+func (c *nistCurve) privateKeyToPublicKey(key *PrivateKey) *PublicKey {
+	if key.curve != c {
+		panic("crypto/ecdh: internal error: converting the wrong key type")
+	}
+	x, y := c.curve.ScalarBaseMult(key.privateKey)
+	return &PublicKey{
+		curve:     key.curve,
+		publicKey: elliptic.Marshal(c.curve, x, y),
+	}
+}
+
+// isZero returns whether a is all zeroes in constant time.
+func isZero(a []byte) bool {
+	var acc byte
+	for _, b := range a {
+		acc |= b
+	}
+	return acc == 0
+}
+
+// isLess returns whether a < b, where a and b are big-endian buffers of the
+// same length, in constant time.
+func isLess(a, b []byte) bool {
+	if len(a) != len(b) {
+		panic("crypto/ecdh: internal error: mismatched isLess inputs")
+	}
+	var borrow uint
+	for i := len(a) - 1; i >= 0; i-- {
+		diff := uint(a[i]) - uint(b[i]) - borrow
+		borrow = (diff >> 8) & 1
+	}
+	return borrow == 1
+}
+
+func (c *nistCurve) NewPublicKey(key []byte) (*PublicKey, error) {
+	// Reject the point at infinity and compressed encodings.
+	if len(key) == 0 || key[0] != 4 {
+		return nil, errors.New("crypto/ecdh: invalid public key")
+	}
+	// Unmarshal checks that the point is on the curve.
+	if x, _ := elliptic.Unmarshal(c.curve, key); x == nil {
+		return nil, errors.New("crypto/ecdh: invalid public key")
+	}
+	return &PublicKey{
+		curve:     c,
+		publicKey: append([]byte{}, key...),
+	}, nil
+}
+
+func (c *nistCurve) ecdh(local *PrivateKey, remote *PublicKey) ([]byte, error) {
+	// SEC 1, Version 2.0, Section 3.3.1 requires the shared point not to be
+	// the point at infinity. That's not possible on these prime order curves
+	// with a valid scalar and point, but it's cheap to check anyway.
+	x, y := elliptic.Unmarshal(c.curve, remote.publicKey)
+	if x == nil {
+		return nil, errors.New("crypto/ecdh: invalid public key")
+	}
+	x, y = c.curve.ScalarMult(x, y, local.privateKey)
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, errors.New("crypto/ecdh: invalid ECDH result")
+	}
+	return x.FillBytes(make([]byte, c.byteLen())), nil
+}
+
+// P256 returns a Curve which implements NIST P-256 (FIPS 186-3, section D.2.3),
+// also known as secp256r1 or prime256v1.
+//
+// Multiple invocations of this function will return the same value, which can
+// be used for equality checks and switch statements.
+func P256() Curve { return p256 }
+
+var p256 = &nistCurve{name: "P-256", curve: elliptic.P256()}
+
+// P384 returns a Curve which implements NIST P-384 (FIPS 186-3, section D.2.4),
+// also known as secp384r1.
+//
+// Multiple invocations of this function will return the same value, which can
+// be used for equality checks and switch statements.
+func P384() Curve { return p384 }
+
+var p384 = &nistCurve{name: "P-384", curve: elliptic.P384()}
+
+// P521 returns a Curve which implements NIST P-521 (FIPS 186-3, section D.2.5),
+// also known as secp521r1.
+//
+// Multiple invocations of this function will return the same value, which can
+// be used for equality checks and switch statements.
+func P521() Curve { return p521 }
+
+var p521 = &nistCurve{name: "P-521", curve: elliptic.P521()}
@@ -6,6 +6,7 @@ package ecdsa
 
 import (
 	"bufio"
+	"bytes"
 	"compress/bzip2"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -57,6 +58,65 @@ func testKeyGeneration(t *testing.T, c elliptic.Curve) {
 	}
 }
 
+func TestECDH(t *testing.T) {
+	testAllCurves(t, testECDH)
+}
+
+func testECDH(t *testing.T, c elliptic.Curve) {
+	priv, err := GenerateKey(c, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecdhPriv, err := priv.ECDH()
+	if c == elliptic.P224() {
+		if err == nil {
+			t.Error("expected error converting P-224 private key")
+		}
+		if _, err := priv.PublicKey.ECDH(); err == nil {
+			t.Error("expected error converting P-224 public key")
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdhPub, err := priv.PublicKey.ECDH()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ecdhPriv.PublicKey().Equal(ecdhPub) {
+		t.Error("converted public key doesn't match public key of converted private key")
+	}
+	if !bytes.Equal(ecdhPub.Bytes(), elliptic.Marshal(c, priv.X, priv.Y)) {
+		t.Error("converted public key has unexpected encoding")
+	}
+	if ecdhPriv.Curve() != curveToECDH(c) {
+		t.Error("converted private key has unexpected curve")
+	}
+
+	peer, err := ecdhPriv.Curve().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret1, err := ecdhPriv.ECDH(peer.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret2, err := peer.ECDH(ecdhPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(secret1, secret2) {
+		t.Error("ECDH computations with converted keys came out different")
+	}
+
+	invalid := &PublicKey{Curve: c, X: big.NewInt(1), Y: big.NewInt(1)}
+	if _, err := invalid.ECDH(); err == nil {
+		t.Error("expected error converting off-curve public key")
+	}
+}
+
 func TestSignAndVerify(t *testing.T) {
 	testAllCurves(t, testSignAndVerify)
 }
@@ -0,0 +1,336 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package hpke implements the base mode of Hybrid Public Key Encryption, as
+// specified in RFC 9180, with the DHKEM(X25519, HKDF-SHA256) KEM.
+//
+// It supports only what crypto/tls needs for Encrypted Client Hello.
+package hpke
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	_ "crypto/sha256"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// KEM, KDF, and AEAD identifiers from the IANA HPKE registry.
+const (
+	DHKEM_X25519_HKDF_SHA256 uint16 = 0x0020
+
+	KDF_HKDF_SHA256 uint16 = 0x0001
+
+	AEAD_AES_128_GCM      uint16 = 0x0001
+	AEAD_AES_256_GCM      uint16 = 0x0002
+	AEAD_ChaCha20Poly1305 uint16 = 0x0003
+)
+
+const modeBase = 0x00
+
+// x25519KeySize is Nsk, Npk, Nenc and Nsecret for DHKEM(X25519, HKDF-SHA256).
+const x25519KeySize = 32
+
+var (
+	errUnsupportedKEM   = errors.New("hpke: unsupported KEM")
+	errUnsupportedKDF   = errors.New("hpke: unsupported KDF")
+	errUnsupportedAEAD  = errors.New("hpke: unsupported AEAD")
+	errInvalidKey       = errors.New("hpke: invalid key")
+	errMessageLimit     = errors.New("hpke: message limit reached")
+	errOpen             = errors.New("hpke: message authentication failed")
+	errInvalidPublicKey = errors.New("hpke: invalid public key")
+)
+
+// SupportedKEM reports whether the KEM with identifier id is implemented.
+func SupportedKEM(id uint16) bool {
+	return id == DHKEM_X25519_HKDF_SHA256
+}
+
+// SupportedKDF reports whether the KDF with identifier id is implemented.
+func SupportedKDF(id uint16) bool {
+	return id == KDF_HKDF_SHA256
+}
+
+// SupportedAEAD reports whether the AEAD with identifier id is implemented.
+func SupportedAEAD(id uint16) bool {
+	_, ok := aeadKeySize(id)
+	return ok
+}
+
+func aeadKeySize(id uint16) (int, bool) {
+	switch id {
+	case AEAD_AES_128_GCM:
+		return 16, true
+	case AEAD_AES_256_GCM, AEAD_ChaCha20Poly1305:
+		return 32, true
+	}
+	return 0, false
+}
+
+func newAEAD(id uint16, key []byte) (cipher.AEAD, error) {
+	switch id {
+	case AEAD_AES_128_GCM, AEAD_AES_256_GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case AEAD_ChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	}
+	return nil, errUnsupportedAEAD
+}
+
+// labeledExtract implements LabeledExtract from RFC 9180, Section 4.
+func labeledExtract(suiteID []byte, salt []byte, label string, ikm []byte) []byte {
+	labeledIKM := make([]byte, 0, 7+len(suiteID)+len(label)+len(ikm))
+	labeledIKM = append(labeledIKM, "HPKE-v1"...)
+	labeledIKM = append(labeledIKM, suiteID...)
+	labeledIKM = append(labeledIKM, label...)
+	labeledIKM = append(labeledIKM, ikm...)
+	return hkdf.Extract(crypto.SHA256.New, labeledIKM, salt)
+}
+
+// labeledExpand implements LabeledExpand from RFC 9180, Section 4.
+func labeledExpand(suiteID []byte, prk []byte, label string, info []byte, length int) []byte {
+	labeledInfo := make([]byte, 2, 2+7+len(suiteID)+len(label)+len(info))
+	binary.BigEndian.PutUint16(labeledInfo, uint16(length))
+	labeledInfo = append(labeledInfo, "HPKE-v1"...)
+	labeledInfo = append(labeledInfo, suiteID...)
+	labeledInfo = append(labeledInfo, label...)
+	labeledInfo = append(labeledInfo, info...)
+	out := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.Expand(crypto.SHA256.New, prk, labeledInfo), out); err != nil {
+		panic("hpke: internal error: HKDF-Expand failed: " + err.Error())
+	}
+	return out
+}
+
+func kemSuiteID(kemID uint16) []byte {
+	return []byte{'K', 'E', 'M', byte(kemID >> 8), byte(kemID)}
+}
+
+// x25519 computes the X25519 function, rejecting an all-zero output as
+// required by RFC 9180, Section 7.1.4.
+func x25519(scalar, point []byte) ([]byte, error) {
+	if len(scalar) != x25519KeySize {
+		return nil, errInvalidKey
+	}
+	if len(point) != x25519KeySize {
+		return nil, errInvalidPublicKey
+	}
+	out, err := curve25519.X25519(scalar, point)
+	if err != nil {
+		return nil, errInvalidPublicKey
+	}
+	return out, nil
+}
+
+// extractAndExpand derives the KEM shared secret from a Diffie-Hellman output,
+// as specified in RFC 9180, Section 4.1.
+func extractAndExpand(kemID uint16, dh, kemContext []byte) []byte {
+	suiteID := kemSuiteID(kemID)
+	eaePRK := labeledExtract(suiteID, nil, "eae_prk", dh)
+	return labeledExpand(suiteID, eaePRK, "shared_secret", kemContext, x25519KeySize)
+}
+
+// GenerateKey returns a new key pair for the KEM with identifier kemID, reading
+// randomness from rand.
+func GenerateKey(kemID uint16, rand io.Reader) (privateKey, publicKey []byte, err error) {
+	if !SupportedKEM(kemID) {
+		return nil, nil, errUnsupportedKEM
+	}
+	privateKey = make([]byte, x25519KeySize)
+	if _, err := io.ReadFull(rand, privateKey); err != nil {
+		return nil, nil, err
+	}
+	publicKey, err = PublicKey(kemID, privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return privateKey, publicKey, nil
+}
+
+// PublicKey returns the encoded public key corresponding to the encoded
+// privateKey for the KEM with identifier kemID.
+func PublicKey(kemID uint16, privateKey []byte) ([]byte, error) {
+	if !SupportedKEM(kemID) {
+		return nil, errUnsupportedKEM
+	}
+	return x25519(privateKey, curve25519.Basepoint)
+}
+
+// context is the encryption context shared by Sender and Recipient.
+type context struct {
+	aead      cipher.AEAD
+	baseNonce []byte
+	seqNum    uint64
+	exporter  []byte
+	suiteID   []byte
+}
+
+func newContext(kemID, kdfID, aeadID uint16, sharedSecret, info []byte) (*context, error) {
+	if !SupportedKDF(kdfID) {
+		return nil, errUnsupportedKDF
+	}
+	keySize, ok := aeadKeySize(aeadID)
+	if !ok {
+		return nil, errUnsupportedAEAD
+	}
+
+	suiteID := make([]byte, 0, 10)
+	suiteID = append(suiteID, "HPKE"...)
+	suiteID = append(suiteID, byte(kemID>>8), byte(kemID))
+	suiteID = append(suiteID, byte(kdfID>>8), byte(kdfID))
+	suiteID = append(suiteID, byte(aeadID>>8), byte(aeadID))
+
+	// Only the base mode is implemented, so psk and psk_id are empty.
+	pskIDHash := labeledExtract(suiteID, nil, "psk_id_hash", nil)
+	infoHash := labeledExtract(suiteID, nil, "info_hash", info)
+	keyScheduleContext := make([]byte, 0, 1+len(pskIDHash)+len(infoHash))
+	keyScheduleContext = append(keyScheduleContext, modeBase)
+	keyScheduleContext = append(keyScheduleContext, pskIDHash...)
+	keyScheduleContext = append(keyScheduleContext, infoHash...)
+
+	secret := labeledExtract(suiteID, sharedSecret, "secret", nil)
+	key := labeledExpand(suiteID, secret, "key", keyScheduleContext, keySize)
+	aead, err := newAEAD(aeadID, key)
+	if err != nil {
+		return nil, err
+	}
+	return &context{
+		aead:      aead,
+		baseNonce: labeledExpand(suiteID, secret, "base_nonce", keyScheduleContext, aead.NonceSize()),
+		exporter:  labeledExpand(suiteID, secret, "exp", keyScheduleContext, crypto.SHA256.Size()),
+		suiteID:   suiteID,
+	}, nil
+}
+
+// nonce returns the nonce for the current sequence number, as specified in
+// RFC 9180, Section 5.2.
+func (ctx *context) nonce() ([]byte, error) {
+	if ctx.seqNum == 1<<64-1 {
+		return nil, errMessageLimit
+	}
+	nonce := make([]byte, len(ctx.baseNonce))
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], ctx.seqNum)
+	for i := range nonce {
+		nonce[i] ^= ctx.baseNonce[i]
+	}
+	return nonce, nil
+}
+
+// Export derives a secret of the given length from the context, as specified
+// in RFC 9180, Section 5.3.
+func (ctx *context) Export(exporterContext []byte, length int) []byte {
+	return labeledExpand(ctx.suiteID, ctx.exporter, "sec", exporterContext, length)
+}
+
+// A Sender encrypts messages to a Recipient. It is not safe for concurrent use.
+type Sender struct {
+	*context
+}
+
+// SetupSender establishes an encryption context to the holder of publicKey,
+// using the given KEM, KDF, and AEAD, and reading the ephemeral key from rand.
+// It returns the encapsulated key that the recipient needs to set up the
+// matching context.
+func SetupSender(rand io.Reader, kemID, kdfID, aeadID uint16, publicKey, info []byte) (enc []byte, s *Sender, err error) {
+	if !SupportedKEM(kemID) {
+		return nil, nil, errUnsupportedKEM
+	}
+	ephemeral := make([]byte, x25519KeySize)
+	if _, err := io.ReadFull(rand, ephemeral); err != nil {
+		return nil, nil, err
+	}
+	return setupSender(kemID, kdfID, aeadID, publicKey, info, ephemeral)
+}
+
+func setupSender(kemID, kdfID, aeadID uint16, publicKey, info, ephemeral []byte) ([]byte, *Sender, error) {
+	dh, err := x25519(ephemeral, publicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	enc, err := x25519(ephemeral, curve25519.Basepoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	kemContext := append(append([]byte{}, enc...), publicKey...)
+	sharedSecret := extractAndExpand(kemID, dh, kemContext)
+	ctx, err := newContext(kemID, kdfID, aeadID, sharedSecret, info)
+	if err != nil {
+		return nil, nil, err
+	}
+	return enc, &Sender{ctx}, nil
+}
+
+// Overhead returns the difference between the lengths of a ciphertext and its
+// plaintext.
+func (s *Sender) Overhead() int {
+	return s.aead.Overhead()
+}
+
+// Seal encrypts and authenticates plaintext and authenticates aad. Each call
+// uses the next nonce in sequence, so messages must be opened in the same
+// order they were sealed.
+func (s *Sender) Seal(aad, plaintext []byte) ([]byte, error) {
+	nonce, err := s.nonce()
+	if err != nil {
+		return nil, err
+	}
+	s.seqNum++
+	return s.aead.Seal(nil, nonce, plaintext, aad), nil
+}
+
+// A Recipient decrypts messages from a Sender. It is not safe for concurrent
+// use.
+type Recipient struct {
+	*context
+}
+
+// SetupRecipient establishes the decryption context matching the Sender that
+// produced the encapsulated key enc, using privateKey.
+func SetupRecipient(kemID, kdfID, aeadID uint16, privateKey, info, enc []byte) (*Recipient, error) {
+	if !SupportedKEM(kemID) {
+		return nil, errUnsupportedKEM
+	}
+	dh, err := x25519(privateKey, enc)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := PublicKey(kemID, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	kemContext := append(append([]byte{}, enc...), publicKey...)
+	sharedSecret := extractAndExpand(kemID, dh, kemContext)
+	ctx, err := newContext(kemID, kdfID, aeadID, sharedSecret, info)
+	if err != nil {
+		return nil, err
+	}
+	return &Recipient{ctx}, nil
+}
+
+// Open decrypts and authenticates ciphertext and authenticates aad. The
+// sequence number only advances on success, so a failed Open can be retried
+// with the correct message.
+func (r *Recipient) Open(aad, ciphertext []byte) ([]byte, error) {
+	nonce, err := r.nonce()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := r.aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, errOpen
+	}
+	r.seqNum++
+	return plaintext, nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpke
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+)
+
+// deriveKeyPair implements DeriveKeyPair from RFC 9180, Section 7.1.3 for
+// DHKEM(X25519, HKDF-SHA256).
+func deriveKeyPair(kemID uint16, ikm []byte) (privateKey []byte) {
+	suiteID := kemSuiteID(kemID)
+	dkpPRK := labeledExtract(suiteID, nil, "dkp_prk", ikm)
+	return labeledExpand(suiteID, dkpPRK, "sk", nil, x25519KeySize)
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Test vectors from RFC 9180, Appendix A.1 to A.3, base mode.
+var vectors = []struct {
+	name       string
+	aeadID     uint16
+	ikmE       string
+	skRm, pkRm string
+	enc        string
+	ct0, ct1   string
+	export     string // exporter_context "TestContext", L = 32
+}{
+	{
+		name:   "AES-128-GCM",
+		aeadID: AEAD_AES_128_GCM,
+		ikmE:   "7268600d403fce431561aef583ee1613527cff655c1343f29812e66706df3234",
+		skRm:   "4612c550263fc8ad58375df3f557aac531d26850903e55a9f23f21d8534e8ac8",
+		pkRm:   "3948cfe0ad1ddb695d780e59077195da6c56506b027329794ab02bca80815c4d",
+		enc:    "37fda3567bdbd628e88668c3c8d7e97d1d1253b6d4ea6d44c150f741f1bf4431",
+		ct0:    "f938558b5d72f1a23810b4be2ab4f84331acc02fc97babc53a52ae8218a355a96d8770ac83d07bea87e13c512a",
+		ct1:    "af2d7e9ac9ae7e270f46ba1f975be53c09f8d875bdc8535458c2494e8a6eab251c03d0c22a56b8ca42c2063b84",
+		export: "e9e43065102c3836401bed8c3c3c75ae46be1639869391d62c61f1ec7af54931",
+	},
+	{
+		name:   "AES-256-GCM",
+		aeadID: AEAD_AES_256_GCM,
+		ikmE:   "2cd7c601cefb3d42a62b04b7a9041494c06c7843818e0ce28a8f704ae7ab20f9",
+		skRm:   "497b4502664cfea5d5af0b39934dac72242a74f8480451e1aee7d6a53320333d",
+		pkRm:   "430f4b9859665145a6b1ba274024487bd66f03a2dd577d7753c68d7d7d00c00c",
+		enc:    "6c93e09869df3402d7bf231bf540fadd35cd56be14f97178f0954db94b7fc256",
+		ct0:    "e5d84cd531cfb583096e7cfa9641bd3079cf3a91cda813c52deb5f512be9931980a41de125a925cdad859d5b7a",
+		ct1:    "2c43aff25343fdbff864506f0818b9d87df84ea01b1a2144d23b4d40c26bf655fdf197fe40297a8aebeed5cc2d",
+		export: "7c5ded445732c14fe09727d29b4251c0fd38455fe8440571e687f0886aac94d2",
+	},
+	{
+		name:   "ChaCha20Poly1305",
+		aeadID: AEAD_ChaCha20Poly1305,
+		ikmE:   "909a9b35d3dc4713a5e72a4da274b55d3d3821a37e5d099e74a647db583a904b",
+		skRm:   "8057991eef8f1f1af18f4a9491d16a1ce333f695d4db8e38da75975c4478e0fb",
+		pkRm:   "4310ee97d88cc1f088a5576c77ab0cf5c3ac797f3d95139c6c84b5429c59662a",
+		enc:    "1afa08d3dec047a643885163f1180476fa7ddb54c6a8029ea33f95796bf2ac4a",
+		ct0:    "1c5250d8034ec2b784ba2cfd69dbdb8af406cfe3ff938e131f0def8c8b60b4db21993c62ce81883d2dd1b51a28",
+		ct1:    "6b53c051e4199c518de79594e1c4ab18b96f081549d45ce015be002090bb119e85285337cc95ba5f59992dc98c",
+		export: "5acb09211139c43b3090489a9da433e8a30ee7188ba8b0a9a1ccf0c229283e53",
+	},
+}
+
+var (
+	vectorInfo      = []byte("Ode on a Grecian Urn")
+	vectorPlaintext = []byte("Beauty is truth, truth beauty")
+)
+
+func TestVectors(t *testing.T) {
+	for _, v := range vectors {
+		t.Run(v.name, func(t *testing.T) {
+			skRm, pkRm := mustDecodeHex(t, v.skRm), mustDecodeHex(t, v.pkRm)
+			if pk, err := PublicKey(DHKEM_X25519_HKDF_SHA256, skRm); err != nil || !bytes.Equal(pk, pkRm) {
+				t.Fatalf("PublicKey = %x, %v; want %x", pk, err, pkRm)
+			}
+
+			ephemeral := deriveKeyPair(DHKEM_X25519_HKDF_SHA256, mustDecodeHex(t, v.ikmE))
+			enc, s, err := setupSender(DHKEM_X25519_HKDF_SHA256, KDF_HKDF_SHA256, v.aeadID, pkRm, vectorInfo, ephemeral)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(enc); got != v.enc {
+				t.Errorf("enc = %s; want %s", got, v.enc)
+			}
+			r, err := SetupRecipient(DHKEM_X25519_HKDF_SHA256, KDF_HKDF_SHA256, v.aeadID, skRm, vectorInfo, enc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, want := range []string{v.ct0, v.ct1} {
+				aad := []byte("Count-" + string(rune('0'+i)))
+				ct, err := s.Seal(aad, vectorPlaintext)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := hex.EncodeToString(ct); got != want {
+					t.Errorf("Seal #%d = %s; want %s", i, got, want)
+				}
+				pt, err := r.Open(aad, ct)
+				if err != nil {
+					t.Fatalf("Open #%d: %v", i, err)
+				}
+				if !bytes.Equal(pt, vectorPlaintext) {
+					t.Errorf("Open #%d = %q; want %q", i, pt, vectorPlaintext)
+				}
+			}
+
+			if got := hex.EncodeToString(s.Export([]byte("TestContext"), 32)); got != v.export {
+				t.Errorf("Export = %s; want %s", got, v.export)
+			}
+		})
+	}
+}
+
+func TestOpenFailureKeepsSequence(t *testing.T) {
+	sk, pk, err := GenerateKey(DHKEM_X25519_HKDF_SHA256, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, s, err := SetupSender(rand.Reader, DHKEM_X25519_HKDF_SHA256, KDF_HKDF_SHA256, AEAD_AES_128_GCM, pk, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := SetupRecipient(DHKEM_X25519_HKDF_SHA256, KDF_HKDF_SHA256, AEAD_AES_128_GCM, sk, nil, enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := s.Seal([]byte("aad"), []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Open([]byte("bad"), ct); err == nil {
+		t.Fatal("Open with wrong aad succeeded")
+	}
+	if pt, err := r.Open([]byte("aad"), ct); err != nil || string(pt) != "hello" {
+		t.Fatalf("Open = %q, %v; want hello", pt, err)
+	}
+}
+
+func TestUnsupportedAlgorithms(t *testing.T) {
+	_, pk, err := GenerateKey(DHKEM_X25519_HKDF_SHA256, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := SetupSender(rand.Reader, 0x0010, KDF_HKDF_SHA256, AEAD_AES_128_GCM, pk, nil); err == nil {
+		t.Error("SetupSender with P-256 KEM succeeded")
+	}
+	if _, _, err := SetupSender(rand.Reader, DHKEM_X25519_HKDF_SHA256, 0x0002, AEAD_AES_128_GCM, pk, nil); err == nil {
+		t.Error("SetupSender with HKDF-SHA384 succeeded")
+	}
+	if _, _, err := SetupSender(rand.Reader, DHKEM_X25519_HKDF_SHA256, KDF_HKDF_SHA256, 0xffff, pk, nil); err == nil {
+		t.Error("SetupSender with export-only AEAD succeeded")
+	}
+	if _, _, err := SetupSender(rand.Reader, DHKEM_X25519_HKDF_SHA256, KDF_HKDF_SHA256, AEAD_AES_128_GCM, make([]byte, 32), nil); err == nil {
+		t.Error("SetupSender with low-order public key succeeded")
+	}
+}
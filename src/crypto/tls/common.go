@@ -100,6 +100,9 @@ const (
 	extensionCertificateAuthorities  uint16 = 47
 	extensionSignatureAlgorithmsCert uint16 = 50
 	extensionKeyShare                uint16 = 51
+	extensionQUICTransportParameters uint16 = 57
+	extensionECHOuterExtensions      uint16 = 0xfd00
+	extensionEncryptedClientHello    uint16 = 0xfe0d
 	extensionRenegotiationInfo       uint16 = 0xff01
 )
 
@@ -111,8 +114,8 @@ const (
 // CurveID is the type of a TLS identifier for an elliptic curve. See
 // https://www.iana.org/assignments/tls-parameters/tls-parameters.xml#tls-parameters-8.
 //
-// In TLS 1.3, this type is called NamedGroup, but at this time this library
-// only supports Elliptic Curve based groups. See RFC 8446, Section 4.2.7.
+// In TLS 1.3, this type is called NamedGroup, and it also identifies the
+// X25519MLKEM768 hybrid key exchange. See RFC 8446, Section 4.2.7.
 type CurveID uint16
 
 const (
@@ -120,6 +123,11 @@ const (
 	CurveP384 CurveID = 24
 	CurveP521 CurveID = 25
 	X25519    CurveID = 29
+
+	// X25519MLKEM768 is the post-quantum hybrid key exchange combining
+	// ML-KEM-768 and X25519, as specified in draft-kwiatkowski-tls-ecdhe-mlkem.
+	// It is only supported in TLS 1.3.
+	X25519MLKEM768 CurveID = 4588
 )
 
 // TLS 1.3 Key Share. See RFC 8446, Section 4.2.8.
@@ -275,8 +283,18 @@ type ConnectionState struct {
 	// RFC 7627, and https://mitls.org/pages/attacks/3SHAKE#channelbindings.
 	TLSUnique []byte
 
+	// ECHAccepted is true if Encrypted Client Hello was offered by the client
+	// and accepted by the server. ServerName is then the server name that
+	// was sent encrypted.
+	ECHAccepted bool
+
 	// ekm is a closure exposed via ExportKeyingMaterial.
 	ekm func(label string, context []byte, length int) ([]byte, error)
+
+	// testingOnlyCurveID is the TLS 1.3 key exchange group, and
+	// testingOnlyDidHRR is true if a HelloRetryRequest was sent or received.
+	testingOnlyCurveID CurveID
+	testingOnlyDidHRR  bool
 }
 
 // ExportKeyingMaterial returns length bytes of exported key material in a new
@@ -646,6 +664,17 @@ type Config struct {
 	// testing or in combination with VerifyConnection or VerifyPeerCertificate.
 	InsecureSkipVerify bool
 
+	// VerifyOCSPStaple controls whether a client verifies the OCSP response
+	// stapled by the server. If true, a stapled response must be a valid and
+	// current response from the issuer of the server's certificate reporting
+	// it as good, and a certificate carrying the TLS Feature extension with
+	// status_request (OCSP Must-Staple, RFC 7633) is rejected if the server
+	// doesn't staple such a response. On resumption, the response stapled
+	// in the original connection is checked again, and the session is not
+	// resumed if it's no longer valid. It has no effect if InsecureSkipVerify
+	// is true.
+	VerifyOCSPStaple bool
+
 	// CipherSuites is a list of enabled TLS 1.0–1.2 cipher suites. The order of
 	// the list is ignored. Note that TLS 1.3 ciphersuites are not configurable.
 	//
@@ -704,6 +733,12 @@ type Config struct {
 	// an ECDHE handshake, in preference order. If empty, the default will
 	// be used. The client will use the first preference as the type for
 	// its key share in TLS 1.3. This may change in the future.
+	//
+	// X25519MLKEM768 is not part of the default, and must be added to
+	// CurvePreferences to be negotiated. It is ignored in TLS 1.2 and
+	// earlier. If it's the first preference of a client, the client also
+	// sends an X25519 key share, if X25519 is supported, for servers that
+	// don't implement the hybrid key exchange.
 	CurvePreferences []CurveID
 
 	// DynamicRecordSizingDisabled disables adaptive sizing of TLS records.
@@ -724,6 +759,35 @@ type Config struct {
 	// used for debugging.
 	KeyLogWriter io.Writer
 
+	// EncryptedClientHelloConfigList is a serialized ECHConfigList. If
+	// non-nil, clients will attempt Encrypted Client Hello (ECH) using one
+	// of the supported configurations in the list, and the connection will
+	// be limited to TLS 1.3. The real ServerName and the rest of the
+	// ClientHello are encrypted, and only the public name of the chosen
+	// configuration is sent in the clear.
+	//
+	// If the server rejects ECH, the handshake authenticates the server
+	// against the public name and then fails with an *ECHRejectionError,
+	// which carries the retry configurations provided by the server, if any.
+	//
+	// If the list contains no supported configurations, the handshake fails.
+	// ECH is only supported by the client if this field is set.
+	EncryptedClientHelloConfigList []byte
+
+	// EncryptedClientHelloRejectionVerify, if not nil, is called by clients
+	// instead of verifying the server certificate against the public name
+	// when the server rejects ECH. The ConnectionState has PeerCertificates
+	// set, and ServerName is the public name. If it returns a non-nil error,
+	// the handshake is aborted with that error.
+	EncryptedClientHelloRejectionVerify func(ConnectionState) error
+
+	// EncryptedClientHelloKeys are the ECH keys a server uses to decrypt the
+	// ClientHelloInner sent by clients. If ECH is offered with a
+	// configuration not matching any of these keys, the handshake proceeds
+	// with the ClientHelloOuter, and the configurations of the keys with
+	// SendAsRetry set are sent to the client for retrying.
+	EncryptedClientHelloKeys []EncryptedClientHelloKey
+
 	// mutex protects sessionTicketKeys and autoSessionTicketKeys.
 	mutex sync.RWMutex
 	// sessionTicketKeys contains zero or more ticket keys. If set, it means the
@@ -787,34 +851,38 @@ func (c *Config) Clone() *Config {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 	return &Config{
-		Rand:                        c.Rand,
-		Time:                        c.Time,
-		Certificates:                c.Certificates,
-		NameToCertificate:           c.NameToCertificate,
-		GetCertificate:              c.GetCertificate,
-		GetClientCertificate:        c.GetClientCertificate,
-		GetConfigForClient:          c.GetConfigForClient,
-		VerifyPeerCertificate:       c.VerifyPeerCertificate,
-		VerifyConnection:            c.VerifyConnection,
-		RootCAs:                     c.RootCAs,
-		NextProtos:                  c.NextProtos,
-		ServerName:                  c.ServerName,
-		ClientAuth:                  c.ClientAuth,
-		ClientCAs:                   c.ClientCAs,
-		InsecureSkipVerify:          c.InsecureSkipVerify,
-		CipherSuites:                c.CipherSuites,
-		PreferServerCipherSuites:    c.PreferServerCipherSuites,
-		SessionTicketsDisabled:      c.SessionTicketsDisabled,
-		SessionTicketKey:            c.SessionTicketKey,
-		ClientSessionCache:          c.ClientSessionCache,
-		MinVersion:                  c.MinVersion,
-		MaxVersion:                  c.MaxVersion,
-		CurvePreferences:            c.CurvePreferences,
-		DynamicRecordSizingDisabled: c.DynamicRecordSizingDisabled,
-		Renegotiation:               c.Renegotiation,
-		KeyLogWriter:                c.KeyLogWriter,
-		sessionTicketKeys:           c.sessionTicketKeys,
-		autoSessionTicketKeys:       c.autoSessionTicketKeys,
+		Rand:                                c.Rand,
+		Time:                                c.Time,
+		Certificates:                        c.Certificates,
+		NameToCertificate:                   c.NameToCertificate,
+		GetCertificate:                      c.GetCertificate,
+		GetClientCertificate:                c.GetClientCertificate,
+		GetConfigForClient:                  c.GetConfigForClient,
+		VerifyPeerCertificate:               c.VerifyPeerCertificate,
+		VerifyConnection:                    c.VerifyConnection,
+		RootCAs:                             c.RootCAs,
+		NextProtos:                          c.NextProtos,
+		ServerName:                          c.ServerName,
+		ClientAuth:                          c.ClientAuth,
+		ClientCAs:                           c.ClientCAs,
+		InsecureSkipVerify:                  c.InsecureSkipVerify,
+		VerifyOCSPStaple:                    c.VerifyOCSPStaple,
+		CipherSuites:                        c.CipherSuites,
+		PreferServerCipherSuites:            c.PreferServerCipherSuites,
+		SessionTicketsDisabled:              c.SessionTicketsDisabled,
+		SessionTicketKey:                    c.SessionTicketKey,
+		ClientSessionCache:                  c.ClientSessionCache,
+		MinVersion:                          c.MinVersion,
+		MaxVersion:                          c.MaxVersion,
+		CurvePreferences:                    c.CurvePreferences,
+		DynamicRecordSizingDisabled:         c.DynamicRecordSizingDisabled,
+		Renegotiation:                       c.Renegotiation,
+		KeyLogWriter:                        c.KeyLogWriter,
+		EncryptedClientHelloConfigList:      c.EncryptedClientHelloConfigList,
+		EncryptedClientHelloRejectionVerify: c.EncryptedClientHelloRejectionVerify,
+		EncryptedClientHelloKeys:            c.EncryptedClientHelloKeys,
+		sessionTicketKeys:                   c.sessionTicketKeys,
+		autoSessionTicketKeys:               c.autoSessionTicketKeys,
 	}
 }
 
@@ -1024,15 +1092,25 @@ func supportedVersionsFromMax(maxVersion uint16) []uint16 {
 
 var defaultCurvePreferences = []CurveID{X25519, CurveP256, CurveP384, CurveP521}
 
-func (c *Config) curvePreferences() []CurveID {
+func (c *Config) curvePreferences(version uint16) []CurveID {
 	if c == nil || len(c.CurvePreferences) == 0 {
 		return defaultCurvePreferences
 	}
-	return c.CurvePreferences
+	if version >= VersionTLS13 {
+		return c.CurvePreferences
+	}
+	// X25519MLKEM768 is only supported in TLS 1.3.
+	curvePreferences := make([]CurveID, 0, len(c.CurvePreferences))
+	for _, curve := range c.CurvePreferences {
+		if curve != X25519MLKEM768 {
+			curvePreferences = append(curvePreferences, curve)
+		}
+	}
+	return curvePreferences
 }
 
-func (c *Config) supportsCurve(curve CurveID) bool {
-	for _, cc := range c.curvePreferences() {
+func (c *Config) supportsCurve(version uint16, curve CurveID) bool {
+	for _, cc := range c.curvePreferences(version) {
 		if cc == curve {
 			return true
 		}
@@ -1191,7 +1269,7 @@ func (chi *ClientHelloInfo) SupportsCertificate(c *Certificate) error {
 	}
 
 	// The only signed key exchange we support is ECDHE.
-	if !supportsECDHE(config, chi.SupportedCurves, chi.SupportedPoints) {
+	if !supportsECDHE(config, vers, chi.SupportedCurves, chi.SupportedPoints) {
 		return supportsRSAFallback(errors.New("client doesn't support ECDHE, can only use legacy RSA key exchange"))
 	}
 
@@ -1212,7 +1290,7 @@ func (chi *ClientHelloInfo) SupportsCertificate(c *Certificate) error {
 			}
 			var curveOk bool
 			for _, c := range chi.SupportedCurves {
-				if c == curve && config.supportsCurve(c) {
+				if c == curve && config.supportsCurve(vers, c) {
 					curveOk = true
 					break
 				}
@@ -0,0 +1,689 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"crypto/internal/hpke"
+	"errors"
+	"hash"
+	"io"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// This file implements TLS Encrypted Client Hello (ECH), as specified in
+// draft-ietf-tls-esni-18. The client sends a ClientHelloOuter carrying only
+// the public name of the client-facing server, and the real ClientHelloInner
+// encrypted with HPKE in the encrypted_client_hello extension.
+
+const (
+	// echConfigVersion is the version of the ECHConfig structure, which
+	// matches the extension codepoint.
+	echConfigVersion = extensionEncryptedClientHello
+
+	echClientHelloOuterType uint8 = 0
+	echClientHelloInnerType uint8 = 1
+
+	// echConfirmationLength is the size of the acceptance signal in the
+	// ServerHello random and in the HelloRetryRequest extension.
+	echConfirmationLength = 8
+
+	echAcceptConfirmationLabel    = "ech accept confirmation"
+	echHRRAcceptConfirmationLabel = "hrr ech accept confirmation"
+)
+
+// ECHRejectionError is the error returned by the client handshake when the
+// server rejects Encrypted Client Hello. RetryConfigList holds the
+// ECHConfigList the server provided for retrying the connection, if any. It
+// was authenticated by a certificate valid for the public name of the
+// configuration that was used.
+type ECHRejectionError struct {
+	RetryConfigList []byte
+}
+
+func (e *ECHRejectionError) Error() string {
+	return "tls: server rejected ECH"
+}
+
+// EncryptedClientHelloKey is a private key a server uses to decrypt the
+// Encrypted Client Hello extension, together with the ECHConfig clients were
+// given for it.
+type EncryptedClientHelloKey struct {
+	// Config is the marshaled ECHConfig corresponding to PrivateKey, as
+	// provided to clients. Only the DHKEM(X25519, HKDF-SHA256) KEM and the
+	// HKDF-SHA256 KDF are supported, with the AES-128-GCM, AES-256-GCM and
+	// ChaCha20Poly1305 AEADs.
+	Config []byte
+
+	// PrivateKey is the 32-byte X25519 private key for the public key in
+	// Config.
+	PrivateKey []byte
+
+	// SendAsRetry indicates whether Config should be sent to clients as a
+	// retry configuration when they offer ECH with a configuration the server
+	// can't decrypt.
+	SendAsRetry bool
+}
+
+type echCipherSuite struct {
+	kdfID, aeadID uint16
+}
+
+// echConfig is a parsed ECHConfig.
+type echConfig struct {
+	raw []byte // the full ECHConfig, used as part of the HPKE info
+
+	configID      uint8
+	kemID         uint16
+	publicKey     []byte
+	cipherSuites  []echCipherSuite
+	maxNameLength uint8
+	publicName    string
+
+	// hasMandatoryExtension is true if the config carries an extension that
+	// the client must understand to use it. No extensions are supported.
+	hasMandatoryExtension bool
+}
+
+var errMalformedECHConfig = errors.New("tls: malformed ECHConfig")
+
+// parseECHConfigList parses an ECHConfigList, skipping configurations with
+// unsupported versions.
+func parseECHConfigList(data []byte) ([]*echConfig, error) {
+	s := cryptobyte.String(data)
+	var list cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&list) || !s.Empty() || list.Empty() {
+		return nil, errors.New("tls: malformed ECHConfigList")
+	}
+	var configs []*echConfig
+	for !list.Empty() {
+		raw := list
+		var version uint16
+		var contents cryptobyte.String
+		if !list.ReadUint16(&version) || !list.ReadUint16LengthPrefixed(&contents) {
+			return nil, errors.New("tls: malformed ECHConfigList")
+		}
+		if version != echConfigVersion {
+			continue
+		}
+		config, err := parseECHConfigContents(contents)
+		if err != nil {
+			return nil, err
+		}
+		config.raw = raw[:len(raw)-len(list)]
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+// parseECHConfig parses a single ECHConfig, as used by servers.
+func parseECHConfig(data []byte) (*echConfig, error) {
+	s := cryptobyte.String(data)
+	var version uint16
+	var contents cryptobyte.String
+	if !s.ReadUint16(&version) || !s.ReadUint16LengthPrefixed(&contents) || !s.Empty() {
+		return nil, errMalformedECHConfig
+	}
+	if version != echConfigVersion {
+		return nil, errors.New("tls: unsupported ECHConfig version")
+	}
+	config, err := parseECHConfigContents(contents)
+	if err != nil {
+		return nil, err
+	}
+	config.raw = data
+	return config, nil
+}
+
+func parseECHConfigContents(s cryptobyte.String) (*echConfig, error) {
+	config := &echConfig{}
+	var suites, publicName, extensions cryptobyte.String
+	if !s.ReadUint8(&config.configID) ||
+		!s.ReadUint16(&config.kemID) ||
+		!readUint16LengthPrefixed(&s, &config.publicKey) ||
+		len(config.publicKey) == 0 ||
+		!s.ReadUint16LengthPrefixed(&suites) || suites.Empty() {
+		return nil, errMalformedECHConfig
+	}
+	for !suites.Empty() {
+		var suite echCipherSuite
+		if !suites.ReadUint16(&suite.kdfID) || !suites.ReadUint16(&suite.aeadID) {
+			return nil, errMalformedECHConfig
+		}
+		config.cipherSuites = append(config.cipherSuites, suite)
+	}
+	if !s.ReadUint8(&config.maxNameLength) ||
+		!s.ReadUint8LengthPrefixed(&publicName) || publicName.Empty() ||
+		!s.ReadUint16LengthPrefixed(&extensions) || !s.Empty() {
+		return nil, errMalformedECHConfig
+	}
+	config.publicName = string(publicName)
+	for !extensions.Empty() {
+		var extType uint16
+		var extData cryptobyte.String
+		if !extensions.ReadUint16(&extType) || !extensions.ReadUint16LengthPrefixed(&extData) {
+			return nil, errMalformedECHConfig
+		}
+		if extType&0x8000 != 0 {
+			config.hasMandatoryExtension = true
+		}
+	}
+	return config, nil
+}
+
+// supportsCipherSuite reports whether suite is one of the config's cipher
+// suites and is implemented.
+func (config *echConfig) supportsCipherSuite(suite echCipherSuite) bool {
+	if !hpke.SupportedKDF(suite.kdfID) || !hpke.SupportedAEAD(suite.aeadID) {
+		return false
+	}
+	for _, s := range config.cipherSuites {
+		if s == suite {
+			return true
+		}
+	}
+	return false
+}
+
+// hpkeInfo returns the HPKE info parameter for the config. See Section 6.1.
+func (config *echConfig) hpkeInfo() []byte {
+	info := make([]byte, 0, len("tls ech")+1+len(config.raw))
+	info = append(info, "tls ech"...)
+	info = append(info, 0)
+	return append(info, config.raw...)
+}
+
+// pickECHConfig returns the first config in the list that can be used, along
+// with the first of its cipher suites that is supported.
+func pickECHConfig(configs []*echConfig) (*echConfig, echCipherSuite, bool) {
+	for _, config := range configs {
+		if !hpke.SupportedKEM(config.kemID) || config.hasMandatoryExtension {
+			continue
+		}
+		// The public name must be a DNS name in the form sent in SNI, not an
+		// IP address. See Section 4.
+		if hostnameInSNI(config.publicName) != config.publicName {
+			continue
+		}
+		for _, suite := range config.cipherSuites {
+			if config.supportsCipherSuite(suite) {
+				return config, suite, true
+			}
+		}
+	}
+	return nil, echCipherSuite{}, false
+}
+
+// echExtension is the payload of an outer encrypted_client_hello extension.
+type echExtension struct {
+	suite    echCipherSuite
+	configID uint8
+	enc      []byte
+	payload  []byte
+}
+
+func (ext *echExtension) marshal() []byte {
+	var b cryptobyte.Builder
+	b.AddUint8(echClientHelloOuterType)
+	b.AddUint16(ext.suite.kdfID)
+	b.AddUint16(ext.suite.aeadID)
+	b.AddUint8(ext.configID)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(ext.enc)
+	})
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(ext.payload)
+	})
+	return b.BytesOrPanic()
+}
+
+// parseECHExtension parses the encrypted_client_hello extension of a
+// ClientHello. If it is of the inner type, ext is nil.
+func parseECHExtension(data []byte) (echType uint8, ext *echExtension, ok bool) {
+	s := cryptobyte.String(data)
+	if !s.ReadUint8(&echType) {
+		return 0, nil, false
+	}
+	switch echType {
+	case echClientHelloInnerType:
+		return echType, nil, s.Empty()
+	case echClientHelloOuterType:
+		ext = &echExtension{}
+		if !s.ReadUint16(&ext.suite.kdfID) ||
+			!s.ReadUint16(&ext.suite.aeadID) ||
+			!s.ReadUint8(&ext.configID) ||
+			!readUint16LengthPrefixed(&s, &ext.enc) ||
+			!readUint16LengthPrefixed(&s, &ext.payload) ||
+			len(ext.payload) == 0 || !s.Empty() {
+			return 0, nil, false
+		}
+		return echType, ext, true
+	}
+	return 0, nil, false
+}
+
+// helloExtensions returns the extensions block of a marshaled ClientHello or
+// ServerHello message, including the handshake message header.
+func helloExtensions(raw []byte, isClientHello bool) (cryptobyte.String, bool) {
+	s := cryptobyte.String(raw)
+	var ignored cryptobyte.String
+	if !s.Skip(4+2+32) || // header, legacy_version and random
+		!s.ReadUint8LengthPrefixed(&ignored) {
+		return nil, false
+	}
+	if isClientHello {
+		if !s.ReadUint16LengthPrefixed(&ignored) || !s.ReadUint8LengthPrefixed(&ignored) {
+			return nil, false
+		}
+	} else if !s.Skip(3) { // cipher_suite and legacy_compression_method
+		return nil, false
+	}
+	var extensions cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&extensions) || !s.Empty() {
+		return nil, false
+	}
+	return extensions, true
+}
+
+// zeroExtensionData returns a copy of the marshaled hello message raw where
+// the last n bytes of the data of the extension of type extType are replaced
+// with zeroes. This is how the ClientHelloOuterAAD and the HelloRetryRequest
+// acceptance confirmation input are computed without re-marshaling a message
+// that might have been produced by a different implementation.
+func zeroExtensionData(raw []byte, isClientHello bool, extType uint16, n int) ([]byte, bool) {
+	extensions, ok := helloExtensions(raw, isClientHello)
+	if !ok {
+		return nil, false
+	}
+	for !extensions.Empty() {
+		var typ uint16
+		var data cryptobyte.String
+		if !extensions.ReadUint16(&typ) || !extensions.ReadUint16LengthPrefixed(&data) {
+			return nil, false
+		}
+		if typ != extType {
+			continue
+		}
+		if len(data) < n {
+			return nil, false
+		}
+		out := append([]byte(nil), raw...)
+		end := len(raw) - len(extensions)
+		for i := end - n; i < end; i++ {
+			out[i] = 0
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// echAcceptConfirmation computes the acceptance signal of Section 7.2 over
+// the transcript followed by msg, which must have the signal zeroed out.
+func echAcceptConfirmation(suite *cipherSuiteTLS13, innerRandom []byte, label string, transcript hash.Hash, msg []byte) []byte {
+	transcript = cloneHash(transcript, suite.hash)
+	if transcript == nil {
+		return nil
+	}
+	transcript.Write(msg)
+	secret := suite.extract(innerRandom, nil)
+	return suite.expandLabel(secret, label, transcript.Sum(nil), echConfirmationLength)
+}
+
+// echServerHelloConfirmationInput returns a copy of the marshaled ServerHello
+// raw with the acceptance signal in the last bytes of the random zeroed.
+func echServerHelloConfirmationInput(raw []byte) []byte {
+	out := append([]byte(nil), raw...)
+	const randomEnd = 4 + 2 + 32 // header, legacy_version and random
+	for i := randomEnd - echConfirmationLength; i < randomEnd; i++ {
+		out[i] = 0
+	}
+	return out
+}
+
+// echClientContext is the client state for an ECH handshake.
+type echClientContext struct {
+	config          *echConfig
+	suite           echCipherSuite
+	encapsulatedKey []byte
+	hpkeContext     *hpke.Sender
+	outerRandom     []byte
+
+	innerHello      *clientHelloMsg
+	innerTranscript hash.Hash
+	retryConfigs    []byte
+}
+
+// newECHClientContext parses Config.EncryptedClientHelloConfigList and sets
+// up the HPKE context for the first usable configuration.
+func (c *Conn) newECHClientContext() (*echClientContext, error) {
+	configs, err := parseECHConfigList(c.config.EncryptedClientHelloConfigList)
+	if err != nil {
+		return nil, err
+	}
+	config, suite, ok := pickECHConfig(configs)
+	if !ok {
+		return nil, errors.New("tls: EncryptedClientHelloConfigList contains no supported configurations")
+	}
+	enc, hpkeContext, err := hpke.SetupSender(c.config.rand(), config.kemID,
+		suite.kdfID, suite.aeadID, config.publicKey, config.hpkeInfo())
+	if err != nil {
+		return nil, err
+	}
+	outerRandom := make([]byte, 32)
+	if _, err := io.ReadFull(c.config.rand(), outerRandom); err != nil {
+		return nil, errors.New("tls: short read from Rand: " + err.Error())
+	}
+	return &echClientContext{
+		config:          config,
+		suite:           suite,
+		encapsulatedKey: enc,
+		hpkeContext:     hpkeContext,
+		outerRandom:     outerRandom,
+	}, nil
+}
+
+// encodeInnerClientHello returns the EncodedClientHelloInner for inner,
+// padded as recommended in Section 6.1.3 to hide the length of the server
+// name. The legacy_session_id is omitted, as the server copies it from the
+// outer ClientHello.
+func (ech *echClientContext) encodeInnerClientHello(inner *clientHelloMsg) []byte {
+	h := *inner
+	h.raw = nil
+	h.sessionId = nil
+	encoded := h.marshal()[4:] // strip the handshake message header
+
+	var padding int
+	if inner.serverName != "" {
+		padding = int(ech.config.maxNameLength) - len(inner.serverName)
+		if padding < 0 {
+			padding = 0
+		}
+	} else {
+		padding = int(ech.config.maxNameLength) + 9
+	}
+	padding += 31 - (len(encoded)+padding-1)%32
+	return append(encoded, make([]byte, padding)...)
+}
+
+// outerClientHello returns a ClientHelloOuter carrying the encrypted inner
+// ClientHello. The encapsulated key is only sent in the first ClientHello,
+// as a second one after a HelloRetryRequest reuses the HPKE context.
+func (ech *echClientContext) outerClientHello(inner *clientHelloMsg, first bool) (*clientHelloMsg, error) {
+	outer := *inner
+	outer.raw = nil
+	outer.random = ech.outerRandom
+	outer.serverName = ech.config.publicName
+	outer.ticketSupported = false
+	outer.sessionTicket = nil
+	outer.earlyData = false
+	outer.pskModes = nil
+	outer.pskIdentities = nil
+	outer.pskBinders = nil
+
+	encoded := ech.encodeInnerClientHello(inner)
+	ext := &echExtension{
+		suite:    ech.suite,
+		configID: ech.config.configID,
+		payload:  make([]byte, len(encoded)+ech.hpkeContext.Overhead()),
+	}
+	if first {
+		ext.enc = ech.encapsulatedKey
+	}
+	// The ClientHelloOuterAAD is the outer ClientHello with a zeroed payload.
+	// See Section 5.2.
+	outer.encryptedClientHello = ext.marshal()
+	aad := outer.marshal()[4:]
+	payload, err := ech.hpkeContext.Seal(aad, encoded)
+	if err != nil {
+		return nil, err
+	}
+	copy(ext.payload, payload)
+	outer.raw = nil
+	outer.encryptedClientHello = ext.marshal()
+	return &outer, nil
+}
+
+// echServerContext is the server state for a handshake where the client
+// offered ECH.
+type echServerContext struct {
+	// accepted is true if the ClientHelloInner was successfully decrypted.
+	// The following fields are only set if it was.
+	accepted    bool
+	hpkeContext *hpke.Recipient
+	configID    uint8
+	suite       echCipherSuite
+
+	// retryConfigs is the ECHConfigList sent to the client if ECH was
+	// rejected, computed with the Config passed to Server.
+	retryConfigs []byte
+}
+
+// processECHClientHello decrypts the ClientHelloInner carried by outer, if
+// any, using Config.EncryptedClientHelloKeys. It returns the ClientHello that
+// the handshake should proceed with, and the ECH state if ECH was offered.
+func (c *Conn) processECHClientHello(outer *clientHelloMsg) (*clientHelloMsg, *echServerContext, error) {
+	if outer.encryptedClientHello == nil {
+		return outer, nil, nil
+	}
+	echType, ext, ok := parseECHExtension(outer.encryptedClientHello)
+	if !ok {
+		c.sendAlert(alertDecodeError)
+		return nil, nil, errors.New("tls: malformed encrypted_client_hello extension")
+	}
+	if echType == echClientHelloInnerType {
+		c.sendAlert(alertIllegalParameter)
+		return nil, nil, errors.New("tls: client sent an inner encrypted_client_hello extension in the outer ClientHello")
+	}
+
+	ech := &echServerContext{}
+	for _, key := range c.config.EncryptedClientHelloKeys {
+		config, err := parseECHConfig(key.Config)
+		if err != nil || config.configID != ext.configID || !config.supportsCipherSuite(ext.suite) {
+			continue
+		}
+		hpkeContext, err := hpke.SetupRecipient(config.kemID, ext.suite.kdfID, ext.suite.aeadID,
+			key.PrivateKey, config.hpkeInfo(), ext.enc)
+		if err != nil {
+			continue
+		}
+		encoded, err := openECHPayload(hpkeContext, outer, ext)
+		if err != nil {
+			continue
+		}
+		inner, err := decodeInnerClientHello(outer, encoded)
+		if err != nil {
+			c.sendAlert(alertIllegalParameter)
+			return nil, nil, err
+		}
+		ech.accepted = true
+		ech.hpkeContext = hpkeContext
+		ech.configID = ext.configID
+		ech.suite = ext.suite
+		c.echAccepted = true
+		return inner, ech, nil
+	}
+
+	// ECH is rejected, and the handshake proceeds with the outer ClientHello.
+	// The client will be offered the retry configurations, if any.
+	ech.retryConfigs = c.echRetryConfigList()
+	return outer, ech, nil
+}
+
+// processSecondClientHello decrypts the ClientHelloInner carried by the
+// ClientHelloOuter sent in response to a HelloRetryRequest, if ECH was
+// accepted for the first ClientHello. See Section 7.1.1.
+func (ech *echServerContext) processSecondClientHello(c *Conn, outer *clientHelloMsg) (*clientHelloMsg, error) {
+	if !ech.accepted {
+		return outer, nil
+	}
+	if outer.encryptedClientHello == nil {
+		c.sendAlert(alertMissingExtension)
+		return nil, errors.New("tls: client did not send encrypted_client_hello in second ClientHello")
+	}
+	echType, ext, ok := parseECHExtension(outer.encryptedClientHello)
+	if !ok {
+		c.sendAlert(alertDecodeError)
+		return nil, errors.New("tls: malformed encrypted_client_hello extension")
+	}
+	if echType != echClientHelloOuterType || ext.configID != ech.configID ||
+		ext.suite != ech.suite || len(ext.enc) != 0 {
+		c.sendAlert(alertIllegalParameter)
+		return nil, errors.New("tls: client sent invalid encrypted_client_hello extension in second ClientHello")
+	}
+	encoded, err := openECHPayload(ech.hpkeContext, outer, ext)
+	if err != nil {
+		c.sendAlert(alertDecryptError)
+		return nil, errors.New("tls: failed to decrypt second ClientHelloInner")
+	}
+	inner, err := decodeInnerClientHello(outer, encoded)
+	if err != nil {
+		c.sendAlert(alertIllegalParameter)
+		return nil, err
+	}
+	return inner, nil
+}
+
+func openECHPayload(hpkeContext *hpke.Recipient, outer *clientHelloMsg, ext *echExtension) ([]byte, error) {
+	aad, ok := zeroExtensionData(outer.marshal(), true, extensionEncryptedClientHello, len(ext.payload))
+	if !ok {
+		return nil, errors.New("tls: malformed ClientHelloOuter")
+	}
+	return hpkeContext.Open(aad[4:], ext.payload)
+}
+
+// decodeInnerClientHello reconstructs the ClientHelloInner from the decrypted
+// EncodedClientHelloInner, copying the legacy_session_id and any extensions
+// referenced by ech_outer_extensions from the outer ClientHello. See
+// Section 5.1.
+func decodeInnerClientHello(outer *clientHelloMsg, encoded []byte) (*clientHelloMsg, error) {
+	errMalformed := errors.New("tls: malformed EncodedClientHelloInner")
+
+	s := cryptobyte.String(encoded)
+	var vers uint16
+	var random []byte
+	var sessionID, cipherSuites, compressionMethods, extensions cryptobyte.String
+	if !s.ReadUint16(&vers) || !s.ReadBytes(&random, 32) ||
+		!s.ReadUint8LengthPrefixed(&sessionID) || !sessionID.Empty() ||
+		!s.ReadUint16LengthPrefixed(&cipherSuites) ||
+		!s.ReadUint8LengthPrefixed(&compressionMethods) ||
+		!s.ReadUint16LengthPrefixed(&extensions) {
+		return nil, errMalformed
+	}
+	// The rest is padding, which must be all zeroes.
+	for _, b := range s {
+		if b != 0 {
+			return nil, errMalformed
+		}
+	}
+
+	outerExtensions, ok := helloExtensions(outer.marshal(), true)
+	if !ok {
+		return nil, errMalformed
+	}
+
+	var b cryptobyte.Builder
+	b.AddUint8(typeClientHello)
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint16(vers)
+		b.AddBytes(random)
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(outer.sessionId)
+		})
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(cipherSuites)
+		})
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(compressionMethods)
+		})
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			for !extensions.Empty() {
+				var extType uint16
+				var extData cryptobyte.String
+				if !extensions.ReadUint16(&extType) || !extensions.ReadUint16LengthPrefixed(&extData) {
+					b.SetError(errMalformed)
+					return
+				}
+				if extType != extensionECHOuterExtensions {
+					b.AddUint16(extType)
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						b.AddBytes(extData)
+					})
+					continue
+				}
+				var refs cryptobyte.String
+				if !extData.ReadUint8LengthPrefixed(&refs) || refs.Empty() || !extData.Empty() {
+					b.SetError(errMalformed)
+					return
+				}
+				// The referenced extensions must appear in the outer
+				// ClientHello in the same order.
+				for !refs.Empty() {
+					var ref uint16
+					if !refs.ReadUint16(&ref) || ref == extensionEncryptedClientHello {
+						b.SetError(errMalformed)
+						return
+					}
+					found := false
+					for !outerExtensions.Empty() {
+						var outerType uint16
+						var outerData cryptobyte.String
+						if !outerExtensions.ReadUint16(&outerType) ||
+							!outerExtensions.ReadUint16LengthPrefixed(&outerData) {
+							break
+						}
+						if outerType == ref {
+							b.AddUint16(outerType)
+							b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+								b.AddBytes(outerData)
+							})
+							found = true
+							break
+						}
+					}
+					if !found {
+						b.SetError(errMalformed)
+						return
+					}
+				}
+			}
+		})
+	})
+	raw, err := b.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	inner := new(clientHelloMsg)
+	if !inner.unmarshal(raw) {
+		return nil, errMalformed
+	}
+	if echType, _, ok := parseECHExtension(inner.encryptedClientHello); !ok || echType != echClientHelloInnerType {
+		return nil, errors.New("tls: ClientHelloInner does not have an inner encrypted_client_hello extension")
+	}
+	return inner, nil
+}
+
+// echRetryConfigList returns the ECHConfigList of the keys marked with
+// SendAsRetry, or nil if there are none.
+func (c *Conn) echRetryConfigList() []byte {
+	var b cryptobyte.Builder
+	var hasRetry bool
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, key := range c.config.EncryptedClientHelloKeys {
+			if key.SendAsRetry {
+				b.AddBytes(key.Config)
+				hasRetry = true
+			}
+		}
+	})
+	if !hasRetry {
+		return nil
+	}
+	list, err := b.Bytes()
+	if err != nil {
+		return nil
+	}
+	return list
+}
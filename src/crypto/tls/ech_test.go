@@ -0,0 +1,308 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"crypto/internal/hpke"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// marshalECHConfig returns an ECHConfig for publicKey, with the
+// HKDF-SHA256/AES-128-GCM cipher suite.
+func marshalECHConfig(id uint8, publicKey []byte, publicName string, maxNameLength uint8) []byte {
+	var b cryptobyte.Builder
+	b.AddUint16(echConfigVersion)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint8(id)
+		b.AddUint16(hpke.DHKEM_X25519_HKDF_SHA256)
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(publicKey)
+		})
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint16(hpke.KDF_HKDF_SHA256)
+			b.AddUint16(hpke.AEAD_AES_128_GCM)
+		})
+		b.AddUint8(maxNameLength)
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes([]byte(publicName))
+		})
+		b.AddUint16(0) // extensions
+	})
+	return b.BytesOrPanic()
+}
+
+func marshalECHConfigList(configs ...[]byte) []byte {
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, config := range configs {
+			b.AddBytes(config)
+		}
+	})
+	return b.BytesOrPanic()
+}
+
+func newTestECHKey(t *testing.T, id uint8, publicName string) EncryptedClientHelloKey {
+	priv, pub, err := hpke.GenerateKey(hpke.DHKEM_X25519_HKDF_SHA256, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return EncryptedClientHelloKey{
+		Config:     marshalECHConfig(id, pub, publicName, 32),
+		PrivateKey: priv,
+	}
+}
+
+func TestParseECHConfigList(t *testing.T) {
+	config := marshalECHConfig(7, bytes.Repeat([]byte{1}, 32), "public.example", 32)
+
+	unknown := []byte{0xfe, 0x0a, 0x00, 0x02, 0xaa, 0xbb}
+	configs, err := parseECHConfigList(marshalECHConfigList(unknown, config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 1 {
+		t.Fatalf("got %d configs, want 1", len(configs))
+	}
+	c := configs[0]
+	if !bytes.Equal(c.raw, config) {
+		t.Errorf("raw config mismatch: got %x, want %x", c.raw, config)
+	}
+	if c.configID != 7 || c.kemID != hpke.DHKEM_X25519_HKDF_SHA256 ||
+		c.publicName != "public.example" || c.maxNameLength != 32 {
+		t.Errorf("unexpected parsed config: %+v", c)
+	}
+	if _, _, ok := pickECHConfig(configs); !ok {
+		t.Errorf("pickECHConfig found no usable config")
+	}
+
+	for _, bad := range [][]byte{
+		nil,
+		{0x00, 0x00},
+		marshalECHConfigList(config[:len(config)-1]),
+		append(marshalECHConfigList(config), 0),
+	} {
+		if _, err := parseECHConfigList(bad); err == nil {
+			t.Errorf("parseECHConfigList(%x) succeeded, want error", bad)
+		}
+	}
+
+	ip := marshalECHConfig(1, bytes.Repeat([]byte{1}, 32), "192.0.2.1", 32)
+	configs, err = parseECHConfigList(marshalECHConfigList(ip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := pickECHConfig(configs); ok {
+		t.Errorf("pickECHConfig picked a config with an IP address public name")
+	}
+}
+
+func testECHConfigs(t *testing.T, publicName string) (clientConfig, serverConfig *Config) {
+	key := newTestECHKey(t, 1, publicName)
+
+	serverConfig = testConfig.Clone()
+	serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{key}
+
+	clientConfig = testConfig.Clone()
+	clientConfig.ServerName = "example.golang"
+	clientConfig.EncryptedClientHelloConfigList = marshalECHConfigList(key.Config)
+	return clientConfig, serverConfig
+}
+
+func TestECHAccepted(t *testing.T) {
+	for _, name := range []string{"Basic", "HelloRetryRequest"} {
+		t.Run(name, func(t *testing.T) {
+			clientConfig, serverConfig := testECHConfigs(t, "public.example")
+			if name == "HelloRetryRequest" {
+				clientConfig.CurvePreferences = []CurveID{X25519, CurveP256}
+				serverConfig.CurvePreferences = []CurveID{CurveP256}
+			}
+
+			serverState, clientState, err := testHandshake(t, clientConfig, serverConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !clientState.ECHAccepted || !serverState.ECHAccepted {
+				t.Errorf("ECH not accepted: client %v, server %v",
+					clientState.ECHAccepted, serverState.ECHAccepted)
+			}
+			if serverState.ServerName != "example.golang" {
+				t.Errorf("server observed ServerName %q, want %q", serverState.ServerName, "example.golang")
+			}
+			if clientState.Version != VersionTLS13 {
+				t.Errorf("negotiated version %x, want TLS 1.3", clientState.Version)
+			}
+		})
+	}
+}
+
+func TestECHResumption(t *testing.T) {
+	clientConfig, serverConfig := testECHConfigs(t, "public.example")
+	clientConfig.ClientSessionCache = NewLRUClientSessionCache(1)
+
+	for i := 0; i < 2; i++ {
+		_, clientState, err := testHandshake(t, clientConfig, serverConfig)
+		if err != nil {
+			t.Fatalf("handshake %d: %v", i, err)
+		}
+		if !clientState.ECHAccepted {
+			t.Errorf("handshake %d: ECH not accepted", i)
+		}
+		if clientState.DidResume != (i == 1) {
+			t.Errorf("handshake %d: DidResume is %v", i, clientState.DidResume)
+		}
+	}
+}
+
+func TestECHOuterServerName(t *testing.T) {
+	clientConfig, serverConfig := testECHConfigs(t, "public.example")
+	var outerName string
+	serverConfig.EncryptedClientHelloKeys = nil
+	serverConfig.GetConfigForClient = func(chi *ClientHelloInfo) (*Config, error) {
+		outerName = chi.ServerName
+		return nil, nil
+	}
+	testECHClientHandshake(t, clientConfig, serverConfig)
+	if outerName != "public.example" {
+		t.Errorf("server without ECH keys observed ServerName %q, want the public name", outerName)
+	}
+}
+
+// testECHClientHandshake runs a handshake and returns the client's error,
+// which testHandshake doesn't preserve.
+func testECHClientHandshake(t *testing.T, clientConfig, serverConfig *Config) (ConnectionState, error) {
+	c, s := localPipe(t)
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		server := Server(s, serverConfig)
+		server.Handshake()
+		server.Close()
+	}()
+	defer func() { <-done }()
+	client := Client(c, clientConfig)
+	defer client.Close()
+	err := client.Handshake()
+	return client.ConnectionState(), err
+}
+
+func TestECHRejected(t *testing.T) {
+	issuer, err := x509.ParseCertificate(testRSACertificateIssuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(issuer)
+
+	// The server's certificate is valid for example.golang, which is used as
+	// the public name, while the inner name is a different one.
+	setup := func(t *testing.T) (clientConfig, serverConfig *Config, retryKey EncryptedClientHelloKey) {
+		clientConfig, serverConfig = testECHConfigs(t, "example.golang")
+		clientConfig.ServerName = "secret.example"
+		clientConfig.InsecureSkipVerify = false
+		clientConfig.RootCAs = rootCAs
+		clientConfig.Time = func() time.Time { return time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC) }
+		clientConfig.VerifyConnection = func(ConnectionState) error {
+			return errors.New("VerifyConnection called on rejection")
+		}
+		retryKey = newTestECHKey(t, 2, "example.golang")
+		retryKey.SendAsRetry = true
+		serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{retryKey}
+		return
+	}
+
+	for _, name := range []string{"Basic", "HelloRetryRequest"} {
+		t.Run(name, func(t *testing.T) {
+			clientConfig, serverConfig, retryKey := setup(t)
+			if name == "HelloRetryRequest" {
+				clientConfig.CurvePreferences = []CurveID{X25519, CurveP256}
+				serverConfig.CurvePreferences = []CurveID{CurveP256}
+			}
+			_, err := testECHClientHandshake(t, clientConfig, serverConfig)
+			var echErr *ECHRejectionError
+			if !errors.As(err, &echErr) {
+				t.Fatalf("got error %v, want ECHRejectionError", err)
+			}
+			if want := marshalECHConfigList(retryKey.Config); !bytes.Equal(echErr.RetryConfigList, want) {
+				t.Errorf("got retry configs %x, want %x", echErr.RetryConfigList, want)
+			}
+
+			// Retrying with the provided configuration succeeds.
+			clientConfig.EncryptedClientHelloConfigList = echErr.RetryConfigList
+			clientConfig.VerifyConnection = nil
+			clientConfig.InsecureSkipVerify = true
+			clientState, err := testECHClientHandshake(t, clientConfig, serverConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !clientState.ECHAccepted {
+				t.Errorf("ECH not accepted with retry configs")
+			}
+		})
+	}
+
+	t.Run("NoRetryConfigs", func(t *testing.T) {
+		clientConfig, serverConfig, _ := setup(t)
+		serverConfig.EncryptedClientHelloKeys = nil
+		_, err := testECHClientHandshake(t, clientConfig, serverConfig)
+		var echErr *ECHRejectionError
+		if !errors.As(err, &echErr) {
+			t.Fatalf("got error %v, want ECHRejectionError", err)
+		}
+		if echErr.RetryConfigList != nil {
+			t.Errorf("got retry configs %x, want none", echErr.RetryConfigList)
+		}
+	})
+
+	t.Run("InvalidPublicNameCertificate", func(t *testing.T) {
+		clientConfig, serverConfig, _ := setup(t)
+		key := newTestECHKey(t, 1, "public.example")
+		clientConfig.EncryptedClientHelloConfigList = marshalECHConfigList(key.Config)
+		_, err := testECHClientHandshake(t, clientConfig, serverConfig)
+		if err == nil || !strings.Contains(err.Error(), "public.example") {
+			t.Errorf("got error %v, want certificate verification error for the public name", err)
+		}
+	})
+
+	t.Run("RejectionVerify", func(t *testing.T) {
+		clientConfig, serverConfig, _ := setup(t)
+		verifyErr := errors.New("rejection verify error")
+		clientConfig.EncryptedClientHelloRejectionVerify = func(cs ConnectionState) error {
+			if cs.ServerName != "example.golang" {
+				t.Errorf("EncryptedClientHelloRejectionVerify got ServerName %q", cs.ServerName)
+			}
+			if len(cs.PeerCertificates) == 0 {
+				t.Errorf("EncryptedClientHelloRejectionVerify got no PeerCertificates")
+			}
+			return verifyErr
+		}
+		_, err := testECHClientHandshake(t, clientConfig, serverConfig)
+		if err != verifyErr {
+			t.Errorf("got error %v, want %v", err, verifyErr)
+		}
+	})
+}
+
+func TestECHClientConfigErrors(t *testing.T) {
+	clientConfig, serverConfig := testECHConfigs(t, "public.example")
+	clientConfig.MaxVersion = VersionTLS12
+	if _, err := testECHClientHandshake(t, clientConfig, serverConfig); err == nil {
+		t.Errorf("handshake with MaxVersion TLS 1.2 and ECH succeeded")
+	}
+
+	clientConfig, serverConfig = testECHConfigs(t, "public.example")
+	clientConfig.EncryptedClientHelloConfigList = marshalECHConfigList(
+		[]byte{0xfe, 0x0a, 0x00, 0x02, 0xaa, 0xbb})
+	if _, err := testECHClientHandshake(t, clientConfig, serverConfig); err == nil {
+		t.Errorf("handshake with no supported ECH configs succeeded")
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"crypto/rsa"
 	"crypto/subtle"
 	"crypto/x509"
+	"encoding/asn1"
 	"errors"
 	"fmt"
 	"hash"
@@ -21,6 +22,9 @@ import (
 	"strings"
 	"sync/atomic"
 	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
 )
 
 type clientHandshakeState struct {
@@ -34,7 +38,7 @@ type clientHandshakeState struct {
 	session      *ClientSessionState
 }
 
-func (c *Conn) makeClientHello() (*clientHelloMsg, ecdheParameters, error) {
+func (c *Conn) makeClientHello() (*clientHelloMsg, *keySharePrivateKeys, error) {
 	config := c.config
 	if len(config.ServerName) == 0 && !config.InsecureSkipVerify {
 		return nil, nil, errors.New("tls: either ServerName or InsecureSkipVerify must be specified in the tls.Config")
@@ -56,6 +60,14 @@ func (c *Conn) makeClientHello() (*clientHelloMsg, ecdheParameters, error) {
 	if len(supportedVersions) == 0 {
 		return nil, nil, errors.New("tls: no supported versions satisfy MinVersion and MaxVersion")
 	}
+	if config.EncryptedClientHelloConfigList != nil {
+		// ECH is only specified for TLS 1.3, and offering earlier versions
+		// would let an attacker downgrade the connection to disable it.
+		if config.MaxVersion != 0 && config.MaxVersion < VersionTLS13 {
+			return nil, nil, errors.New("tls: MaxVersion must allow TLS 1.3 when EncryptedClientHelloConfigList is set")
+		}
+		supportedVersions = []uint16{VersionTLS13}
+	}
 
 	clientHelloVersion := config.maxSupportedVersion(roleClient)
 	// The version at the beginning of the ClientHello was capped at TLS 1.2
@@ -73,7 +85,7 @@ func (c *Conn) makeClientHello() (*clientHelloMsg, ecdheParameters, error) {
 		ocspStapling:                 true,
 		scts:                         true,
 		serverName:                   hostnameInSNI(config.ServerName),
-		supportedCurves:              config.curvePreferences(),
+		supportedCurves:              config.curvePreferences(supportedVersions[0]),
 		supportedPoints:              []uint8{pointFormatUncompressed},
 		secureRenegotiationSupported: true,
 		alpnProtocols:                config.NextProtos,
@@ -112,15 +124,21 @@ func (c *Conn) makeClientHello() (*clientHelloMsg, ecdheParameters, error) {
 	// A random session ID is used to detect when the server accepted a ticket
 	// and is resuming a session (see RFC 5077). In TLS 1.3, it's always set as
 	// a compatibility measure (see RFC 8446, Section 4.1.2).
-	if _, err := io.ReadFull(config.rand(), hello.sessionId); err != nil {
-		return nil, nil, errors.New("tls: short read from Rand: " + err.Error())
+	//
+	// The session ID is not set for QUIC connections (see RFC 9001, Section 8.4).
+	if c.quic == nil {
+		if _, err := io.ReadFull(config.rand(), hello.sessionId); err != nil {
+			return nil, nil, errors.New("tls: short read from Rand: " + err.Error())
+		}
+	} else {
+		hello.sessionId = nil
 	}
 
 	if hello.vers >= VersionTLS12 {
 		hello.supportedSignatureAlgorithms = supportedSignatureAlgorithms
 	}
 
-	var params ecdheParameters
+	var keyShareKeys *keySharePrivateKeys
 	if hello.supportedVersions[0] == VersionTLS13 {
 		if hasAESGCMHardwareSupport {
 			hello.cipherSuites = append(hello.cipherSuites, defaultCipherSuitesTLS13...)
@@ -128,18 +146,23 @@ func (c *Conn) makeClientHello() (*clientHelloMsg, ecdheParameters, error) {
 			hello.cipherSuites = append(hello.cipherSuites, defaultCipherSuitesTLS13NoAES...)
 		}
 
-		curveID := config.curvePreferences()[0]
-		if _, ok := curveForCurveID(curveID); curveID != X25519 && !ok {
-			return nil, nil, errors.New("tls: CurvePreferences includes unsupported curve")
-		}
-		params, err = generateECDHEParameters(config.rand(), curveID)
+		curveID := config.curvePreferences(VersionTLS13)[0]
+		var ks keyShare
+		keyShareKeys, ks, err = generateKeyShare(config.rand(), curveID)
 		if err != nil {
 			return nil, nil, err
 		}
-		hello.keyShares = []keyShare{{group: curveID, data: params.PublicKey()}}
+		hello.keyShares = []keyShare{ks}
+		// Servers that don't support the hybrid key exchange would need a
+		// HelloRetryRequest, so send an X25519 key share too, reusing the
+		// X25519 part of the hybrid key.
+		if curveID == X25519MLKEM768 && config.supportsCurve(VersionTLS13, X25519) {
+			hello.keyShares = append(hello.keyShares, keyShare{
+				group: X25519, data: keyShareKeys.ecdhe.PublicKey().Bytes()})
+		}
 	}
 
-	return hello, params, nil
+	return hello, keyShareKeys, nil
 }
 
 func (c *Conn) clientHandshake(ctx context.Context) (err error) {
@@ -151,12 +174,32 @@ func (c *Conn) clientHandshake(ctx context.Context) (err error) {
 	// need to be reset.
 	c.didResume = false
 
-	hello, ecdheParams, err := c.makeClientHello()
+	hello, keyShareKeys, err := c.makeClientHello()
 	if err != nil {
 		return err
 	}
 	c.serverName = hello.serverName
 
+	var echContext *echClientContext
+	if c.config.EncryptedClientHelloConfigList != nil {
+		echContext, err = c.newECHClientContext()
+		if err != nil {
+			return err
+		}
+		hello.encryptedClientHello = []byte{echClientHelloInnerType}
+	}
+
+	if c.quic != nil {
+		p, err := c.quicGetTransportParameters()
+		if err != nil {
+			return err
+		}
+		if p == nil {
+			p = []byte{}
+		}
+		hello.quicTransportParameters = p
+	}
+
 	cacheKey, session, earlySecret, binderKey := c.loadSession(hello)
 	if cacheKey != "" && session != nil {
 		defer func() {
@@ -172,7 +215,19 @@ func (c *Conn) clientHandshake(ctx context.Context) (err error) {
 		}()
 	}
 
-	if _, err := c.writeRecord(recordTypeHandshake, hello.marshal()); err != nil {
+	// With ECH, hello is the ClientHelloInner, and the ClientHelloOuter
+	// carrying it is sent instead. The TLS 1.3 handshake switches back to
+	// the former if the server accepts ECH.
+	outerHello := hello
+	if echContext != nil {
+		echContext.innerHello = hello
+		outerHello, err = echContext.outerClientHello(hello, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := c.writeRecord(recordTypeHandshake, outerHello.marshal()); err != nil {
 		return err
 	}
 
@@ -205,14 +260,15 @@ func (c *Conn) clientHandshake(ctx context.Context) (err error) {
 
 	if c.vers == VersionTLS13 {
 		hs := &clientHandshakeStateTLS13{
-			c:           c,
-			ctx:         ctx,
-			serverHello: serverHello,
-			hello:       hello,
-			ecdheParams: ecdheParams,
-			session:     session,
-			earlySecret: earlySecret,
-			binderKey:   binderKey,
+			c:            c,
+			ctx:          ctx,
+			serverHello:  serverHello,
+			hello:        outerHello,
+			keyShareKeys: keyShareKeys,
+			session:      session,
+			earlySecret:  earlySecret,
+			binderKey:    binderKey,
+			echContext:   echContext,
 		}
 
 		// In TLS 1.3, session tickets are delivered after the handshake.
@@ -262,7 +318,10 @@ func (c *Conn) loadSession(hello *clientHelloMsg) (cacheKey string,
 	}
 
 	// Try to resume a previously negotiated TLS session, if available.
-	cacheKey = clientSessionCacheKey(c.conn.RemoteAddr(), c.config)
+	cacheKey = c.clientSessionCacheKey()
+	if cacheKey == "" {
+		return "", nil, nil, nil
+	}
 	session, ok := c.config.ClientSessionCache.Get(cacheKey)
 	if !ok || session == nil {
 		return cacheKey, nil, nil, nil
@@ -297,6 +356,14 @@ func (c *Conn) loadSession(hello *clientHelloMsg) (cacheKey string,
 		if err := serverCert.VerifyHostname(c.config.ServerName); err != nil {
 			return cacheKey, nil, nil, nil
 		}
+		// The stapled OCSP response is carried over on resumption, so it
+		// must still be valid, or a new one is needed.
+		if c.config.VerifyOCSPStaple {
+			if err := c.verifyOCSPStaple(session.verifiedChains, session.ocspResponse); err != nil {
+				c.config.ClientSessionCache.Put(cacheKey, nil)
+				return cacheKey, nil, nil, nil
+			}
+		}
 	}
 
 	if session.vers != VersionTLS13 {
@@ -711,7 +778,7 @@ func (hs *clientHandshakeState) processServerHello() (bool, error) {
 		}
 	}
 
-	if err := checkALPN(hs.hello.alpnProtocols, hs.serverHello.alpnProtocol); err != nil {
+	if err := checkALPN(hs.hello.alpnProtocols, hs.serverHello.alpnProtocol, false); err != nil {
 		c.sendAlert(alertUnsupportedExtension)
 		return false, err
 	}
@@ -749,8 +816,12 @@ func (hs *clientHandshakeState) processServerHello() (bool, error) {
 
 // checkALPN ensure that the server's choice of ALPN protocol is compatible with
 // the protocols that we advertised in the Client Hello.
-func checkALPN(clientProtos []string, serverProto string) error {
+func checkALPN(clientProtos []string, serverProto string, quic bool) error {
 	if serverProto == "" {
+		if quic && len(clientProtos) > 0 {
+			// RFC 9001, Section 8.1
+			return errors.New("tls: server did not select an ALPN protocol")
+		}
 		return nil
 	}
 	if len(clientProtos) == 0 {
@@ -854,13 +925,27 @@ func (c *Conn) verifyServerCertificate(certificates [][]byte) error {
 		certs[i] = cert
 	}
 
-	if !c.config.InsecureSkipVerify {
+	// If the server rejected ECH, the certificate must be valid for the
+	// public name, which c.serverName was set to, and the connection will be
+	// aborted once the handshake completes. See draft-ietf-tls-esni-18,
+	// Section 6.1.6. This check can't be disabled by InsecureSkipVerify.
+	echRejected := c.config.EncryptedClientHelloConfigList != nil && !c.echAccepted
+	if echRejected && c.config.EncryptedClientHelloRejectionVerify != nil {
+		c.peerCertificates = certs
+		if err := c.config.EncryptedClientHelloRejectionVerify(c.connectionStateLocked()); err != nil {
+			c.sendAlert(alertBadCertificate)
+			return err
+		}
+	} else if echRejected || !c.config.InsecureSkipVerify {
 		opts := x509.VerifyOptions{
 			Roots:         c.config.RootCAs,
 			CurrentTime:   c.config.time(),
 			DNSName:       c.config.ServerName,
 			Intermediates: x509.NewCertPool(),
 		}
+		if echRejected {
+			opts.DNSName = c.serverName
+		}
 		for _, cert := range certs[1:] {
 			opts.Intermediates.AddCert(cert)
 		}
@@ -870,6 +955,12 @@ func (c *Conn) verifyServerCertificate(certificates [][]byte) error {
 			c.sendAlert(alertBadCertificate)
 			return err
 		}
+		if c.config.VerifyOCSPStaple {
+			if err := c.verifyOCSPStaple(c.verifiedChains, c.ocspResponse); err != nil {
+				c.sendAlert(alertBadCertificateStatusResponse)
+				return err
+			}
+		}
 	}
 
 	switch certs[0].PublicKey.(type) {
@@ -882,6 +973,12 @@ func (c *Conn) verifyServerCertificate(certificates [][]byte) error {
 
 	c.peerCertificates = certs
 
+	// The application callbacks are meant to authenticate the intended
+	// server, not the client-facing one, so they are skipped on rejection.
+	if echRejected {
+		return nil
+	}
+
 	if c.config.VerifyPeerCertificate != nil {
 		if err := c.config.VerifyPeerCertificate(certificates, c.verifiedChains); err != nil {
 			c.sendAlert(alertBadCertificate)
@@ -899,6 +996,65 @@ func (c *Conn) verifyServerCertificate(certificates [][]byte) error {
 	return nil
 }
 
+// oidExtensionTLSFeature is the TLS Feature extension, defined in RFC 7633.
+var oidExtensionTLSFeature = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+
+// requiresOCSPStaple reports whether cert carries a TLS Feature extension
+// that includes status_request, also known as OCSP Must-Staple. A malformed
+// extension is treated as requiring a staple.
+func requiresOCSPStaple(cert *x509.Certificate) bool {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidExtensionTLSFeature) {
+			continue
+		}
+		features := cryptobyte.String(ext.Value)
+		if !features.ReadASN1(&features, cryptobyte_asn1.SEQUENCE) {
+			return true
+		}
+		for !features.Empty() {
+			var feature uint16
+			if !features.ReadASN1Integer(&feature) || feature == extensionStatusRequest {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// verifyOCSPStaple checks ocspResponse, stapled by the server, against
+// verifiedChains, as configured by Config.VerifyOCSPStaple. It is also used
+// to check the response kept in a session before resuming it.
+func (c *Conn) verifyOCSPStaple(verifiedChains [][]*x509.Certificate, ocspResponse []byte) error {
+	leaf := verifiedChains[0][0]
+	if len(ocspResponse) == 0 {
+		if requiresOCSPStaple(leaf) {
+			return errors.New("tls: server certificate requires a stapled OCSP response, but none was provided")
+		}
+		return nil
+	}
+
+	resp, err := x509.ParseOCSPResponse(ocspResponse)
+	if err != nil {
+		return errors.New("tls: failed to parse stapled OCSP response: " + err.Error())
+	}
+	// The response must be valid for the issuer of at least one of the
+	// verified chains. A leaf that is itself trusted has no issuer in its
+	// chain, so it's only checked against itself if it's self-issued.
+	err = errors.New("no issuer to check it against")
+	for _, chain := range verifiedChains {
+		issuer := leaf
+		if len(chain) > 1 {
+			issuer = chain[1]
+		} else if !bytes.Equal(leaf.RawIssuer, leaf.RawSubject) {
+			continue
+		}
+		if err = resp.Verify(leaf, issuer, c.config.time()); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("tls: invalid stapled OCSP response: %w", err)
+}
+
 // certificateRequestInfoFromMsg generates a CertificateRequestInfo from a TLS
 // <= 1.2 CertificateRequest, making an effort to fill in missing information.
 func certificateRequestInfoFromMsg(ctx context.Context, vers uint16, certReq *certificateRequestMsg) *CertificateRequestInfo {
@@ -983,11 +1139,14 @@ func (c *Conn) getClientCertificate(cri *CertificateRequestInfo) (*Certificate,
 
 // clientSessionCacheKey returns a key used to cache sessionTickets that could
 // be used to resume previously negotiated TLS sessions with a server.
-func clientSessionCacheKey(serverAddr net.Addr, config *Config) string {
-	if len(config.ServerName) > 0 {
-		return config.ServerName
+func (c *Conn) clientSessionCacheKey() string {
+	if len(c.config.ServerName) > 0 {
+		return c.config.ServerName
+	}
+	if c.conn != nil {
+		return c.conn.RemoteAddr().String()
 	}
-	return serverAddr.String()
+	return ""
 }
 
 // hostnameInSNI converts name into an appropriate hostname for SNI.
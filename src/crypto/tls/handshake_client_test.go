@@ -7,8 +7,12 @@ package tls
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/pem"
@@ -2595,3 +2599,252 @@ func TestClientHandshakeContextCancellation(t *testing.T) {
 		t.Error("Client connection was not closed when the context was canceled")
 	}
 }
+
+func TestVerifyOCSPStaple(t *testing.T) {
+	now := time.Now()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "OCSP Test CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createLeaf := func(serial int64, mustStaple bool) *x509.Certificate {
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "example.golang"},
+			DNSNames:     []string{"example.golang"},
+			NotBefore:    now.Add(-time.Hour),
+			NotAfter:     now.Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+		if mustStaple {
+			// SEQUENCE { INTEGER 5 }, requiring status_request.
+			template.ExtraExtensions = []pkix.Extension{{Id: oidExtensionTLSFeature, Value: []byte{0x30, 0x03, 0x02, 0x01, 0x05}}}
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, leafKey.Public(), caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf
+	}
+	leaf := createLeaf(2, false)
+	mustStapleLeaf := createLeaf(3, true)
+	if requiresOCSPStaple(leaf) || !requiresOCSPStaple(mustStapleLeaf) {
+		t.Fatal("requiresOCSPStaple doesn't detect the TLS Feature extension")
+	}
+
+	createStaple := func(cert *x509.Certificate, status x509.OCSPStatus, thisUpdate, nextUpdate time.Time) []byte {
+		der, err := x509.CreateOCSPResponse(rand.Reader, &x509.OCSPResponse{
+			SerialNumber: cert.SerialNumber,
+			Status:       status,
+			RevokedAt:    now.Add(-time.Minute),
+			ThisUpdate:   thisUpdate,
+			NextUpdate:   nextUpdate,
+		}, ca, ca, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+
+	tests := []struct {
+		name      string
+		cert      *x509.Certificate
+		staple    []byte
+		noVerify  bool
+		trustLeaf bool
+		expectErr string
+	}{
+		{
+			name: "NoStaple",
+			cert: leaf,
+		},
+		{
+			name:   "GoodStaple",
+			cert:   leaf,
+			staple: createStaple(leaf, x509.OCSPGood, now.Add(-time.Hour), now.Add(time.Hour)),
+		},
+		{
+			name:   "MustStapleGoodStaple",
+			cert:   mustStapleLeaf,
+			staple: createStaple(mustStapleLeaf, x509.OCSPGood, now.Add(-time.Hour), now.Add(time.Hour)),
+		},
+		{
+			name:      "MustStapleNoStaple",
+			cert:      mustStapleLeaf,
+			expectErr: "requires a stapled OCSP response",
+		},
+		{
+			name:     "MustStapleNoStapleNotVerified",
+			cert:     mustStapleLeaf,
+			noVerify: true,
+		},
+		{
+			name:      "RevokedStaple",
+			cert:      leaf,
+			staple:    createStaple(leaf, x509.OCSPRevoked, now.Add(-time.Hour), now.Add(time.Hour)),
+			expectErr: "revoked",
+		},
+		{
+			name:     "RevokedStapleNotVerified",
+			cert:     leaf,
+			staple:   createStaple(leaf, x509.OCSPRevoked, now.Add(-time.Hour), now.Add(time.Hour)),
+			noVerify: true,
+		},
+		{
+			name:      "ExpiredStaple",
+			cert:      leaf,
+			staple:    createStaple(leaf, x509.OCSPGood, now.Add(-2*time.Hour), now.Add(-time.Hour)),
+			expectErr: "has expired",
+		},
+		{
+			name:      "StapleForOtherCertificate",
+			cert:      mustStapleLeaf,
+			staple:    createStaple(leaf, x509.OCSPGood, now.Add(-time.Hour), now.Add(time.Hour)),
+			expectErr: "different certificate",
+		},
+		{
+			name:      "MalformedStaple",
+			cert:      leaf,
+			staple:    []byte("dummy ocsp"),
+			expectErr: "failed to parse stapled OCSP response",
+		},
+		{
+			// A trusted leaf has no issuer in its verified chain to check
+			// the staple against, which must not make it acceptable.
+			name:      "TrustedLeafRevokedStaple",
+			cert:      leaf,
+			staple:    createStaple(leaf, x509.OCSPRevoked, now.Add(-time.Hour), now.Add(time.Hour)),
+			trustLeaf: true,
+			expectErr: "invalid stapled OCSP response",
+		},
+	}
+
+	for _, v := range []uint16{VersionTLS12, VersionTLS13} {
+		for _, test := range tests {
+			t.Run(fmt.Sprintf("%s-%x", test.name, v), func(t *testing.T) {
+				serverConfig := testConfig.Clone()
+				serverConfig.MaxVersion = v
+				serverConfig.Certificates = []Certificate{{
+					Certificate: [][]byte{test.cert.Raw},
+					PrivateKey:  leafKey,
+					OCSPStaple:  test.staple,
+				}}
+				clientConfig := testConfig.Clone()
+				clientConfig.MaxVersion = v
+				clientConfig.Time = time.Now
+				clientConfig.InsecureSkipVerify = false
+				clientConfig.RootCAs = roots
+				if test.trustLeaf {
+					clientConfig.RootCAs = x509.NewCertPool()
+					clientConfig.RootCAs.AddCert(test.cert)
+				}
+				clientConfig.ServerName = "example.golang"
+				clientConfig.VerifyOCSPStaple = !test.noVerify
+
+				c, s := localPipe(t)
+				done := make(chan bool)
+				go func() {
+					Server(s, serverConfig).Handshake()
+					s.Close()
+					close(done)
+				}()
+				err := Client(c, clientConfig).Handshake()
+				c.Close()
+				<-done
+				if test.expectErr == "" {
+					if err != nil {
+						t.Fatalf("handshake failed: %v", err)
+					}
+				} else if err == nil || !strings.Contains(err.Error(), test.expectErr) {
+					t.Fatalf("got error %v, expected it to contain %q", err, test.expectErr)
+				}
+			})
+		}
+	}
+
+	// The stapled response is kept in the session, so it must be checked
+	// again on resumption. Once it expired, the client must do a full
+	// handshake to get a new one.
+	for _, v := range []uint16{VersionTLS12, VersionTLS13} {
+		for _, verify := range []bool{false, true} {
+			t.Run(fmt.Sprintf("Resumption-%t-%x", verify, v), func(t *testing.T) {
+				serverConfig := testConfig.Clone()
+				serverConfig.MaxVersion = v
+				serverConfig.Time = func() time.Time { return now }
+				serverConfig.Certificates = []Certificate{{
+					Certificate: [][]byte{leaf.Raw},
+					PrivateKey:  leafKey,
+					OCSPStaple:  createStaple(leaf, x509.OCSPGood, now.Add(-time.Hour), now.Add(10*time.Minute)),
+				}}
+				clientConfig := testConfig.Clone()
+				clientConfig.MaxVersion = v
+				clientConfig.Time = func() time.Time { return now }
+				clientConfig.InsecureSkipVerify = false
+				clientConfig.RootCAs = roots
+				clientConfig.ServerName = "example.golang"
+				clientConfig.VerifyOCSPStaple = verify
+				clientConfig.ClientSessionCache = NewLRUClientSessionCache(1)
+
+				handshake := func() ConnectionState {
+					c, s := localPipe(t)
+					done := make(chan bool)
+					go func() {
+						Server(s, serverConfig).Handshake()
+						s.Close()
+						close(done)
+					}()
+					client := Client(c, clientConfig)
+					err := client.Handshake()
+					if err == nil {
+						// Read the TLS 1.3 session ticket.
+						client.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+						client.Read(make([]byte, 1))
+					}
+					c.Close()
+					<-done
+					if err != nil {
+						t.Fatalf("handshake failed: %v", err)
+					}
+					return client.ConnectionState()
+				}
+				handshake()
+
+				later := now.Add(30 * time.Minute)
+				clientConfig.Time = func() time.Time { return later }
+				serverConfig.Time = func() time.Time { return later }
+				serverConfig.Certificates[0].OCSPStaple = createStaple(leaf, x509.OCSPGood, later.Add(-time.Minute), later.Add(time.Hour))
+				if cs := handshake(); cs.DidResume == verify {
+					t.Errorf("DidResume = %t, expected %t", cs.DidResume, !verify)
+				}
+			})
+		}
+	}
+}
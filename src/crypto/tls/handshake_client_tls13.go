@@ -9,6 +9,7 @@ import (
 	"context"
 	"crypto"
 	"crypto/hmac"
+	"crypto/internal/mlkem768"
 	"crypto/rsa"
 	"errors"
 	"hash"
@@ -17,16 +18,20 @@ import (
 )
 
 type clientHandshakeStateTLS13 struct {
-	c           *Conn
-	ctx         context.Context
-	serverHello *serverHelloMsg
-	hello       *clientHelloMsg
-	ecdheParams ecdheParameters
+	c            *Conn
+	ctx          context.Context
+	serverHello  *serverHelloMsg
+	hello        *clientHelloMsg
+	keyShareKeys *keySharePrivateKeys
 
 	session     *ClientSessionState
 	earlySecret []byte
 	binderKey   []byte
 
+	// echContext is set if ECH was offered, in which case hs.hello is the
+	// ClientHelloOuter until the server accepts ECH.
+	echContext *echClientContext
+
 	certReq       *certificateRequestMsgTLS13
 	usingPSK      bool
 	sentDummyCCS  bool
@@ -36,7 +41,7 @@ type clientHandshakeStateTLS13 struct {
 	trafficSecret []byte // client_application_traffic_secret_0
 }
 
-// handshake requires hs.c, hs.hello, hs.serverHello, hs.ecdheParams, and,
+// handshake requires hs.c, hs.hello, hs.serverHello, hs.keyShareKeys, and,
 // optionally, hs.session, hs.earlySecret and hs.binderKey to be set.
 func (hs *clientHandshakeStateTLS13) handshake() error {
 	c := hs.c
@@ -49,7 +54,7 @@ func (hs *clientHandshakeStateTLS13) handshake() error {
 	}
 
 	// Consistency check on the presence of a keyShare and its parameters.
-	if hs.ecdheParams == nil || len(hs.hello.keyShares) != 1 {
+	if hs.keyShareKeys == nil || hs.keyShareKeys.ecdhe == nil || len(hs.hello.keyShares) == 0 {
 		return c.sendAlert(alertInternalError)
 	}
 
@@ -60,6 +65,11 @@ func (hs *clientHandshakeStateTLS13) handshake() error {
 	hs.transcript = hs.suite.hash.New()
 	hs.transcript.Write(hs.hello.marshal())
 
+	if hs.echContext != nil {
+		hs.echContext.innerTranscript = hs.suite.hash.New()
+		hs.echContext.innerTranscript.Write(hs.echContext.innerHello.marshal())
+	}
+
 	if bytes.Equal(hs.serverHello.random, helloRetryRequestRandom) {
 		if err := hs.sendDummyChangeCipherSpec(); err != nil {
 			return err
@@ -69,6 +79,12 @@ func (hs *clientHandshakeStateTLS13) handshake() error {
 		}
 	}
 
+	if hs.echContext != nil {
+		if err := hs.checkECHServerHello(); err != nil {
+			return err
+		}
+	}
+
 	hs.transcript.Write(hs.serverHello.marshal())
 
 	c.buffering = true
@@ -100,6 +116,13 @@ func (hs *clientHandshakeStateTLS13) handshake() error {
 		return err
 	}
 
+	// The server authenticated as the client-facing server, and the
+	// connection must not be used. See draft-ietf-tls-esni-18, Section 6.1.6.
+	if hs.echContext != nil && !c.echAccepted {
+		c.sendAlert(alertECHRequired)
+		return &ECHRejectionError{RetryConfigList: hs.echContext.retryConfigs}
+	}
+
 	atomic.StoreUint32(&c.handshakeStatus, 1)
 
 	return nil
@@ -163,6 +186,9 @@ func (hs *clientHandshakeStateTLS13) checkServerHelloOrHRR() error {
 // sendDummyChangeCipherSpec sends a ChangeCipherSpec record for compatibility
 // with middleboxes that didn't implement TLS correctly. See RFC 8446, Appendix D.4.
 func (hs *clientHandshakeStateTLS13) sendDummyChangeCipherSpec() error {
+	if hs.c.quic != nil {
+		return nil
+	}
 	if hs.sentDummyCCS {
 		return nil
 	}
@@ -176,6 +202,7 @@ func (hs *clientHandshakeStateTLS13) sendDummyChangeCipherSpec() error {
 // resends hs.hello, and reads the new ServerHello into hs.serverHello.
 func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 	c := hs.c
+	c.didHRR = true
 
 	// The first ClientHello gets double-hashed into the transcript upon a
 	// HelloRetryRequest. (The idea is that the server might offload transcript
@@ -184,6 +211,17 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 	hs.transcript.Reset()
 	hs.transcript.Write([]byte{typeMessageHash, 0, 0, uint8(len(chHash))})
 	hs.transcript.Write(chHash)
+
+	if hs.serverHello.encryptedClientHello != nil && hs.echContext == nil {
+		c.sendAlert(alertUnsupportedExtension)
+		return errors.New("tls: server sent an unexpected encrypted_client_hello extension")
+	}
+	if hs.echContext != nil {
+		if err := hs.checkECHHelloRetryRequest(); err != nil {
+			return err
+		}
+	}
+
 	hs.transcript.Write(hs.serverHello.marshal())
 
 	// The only HelloRetryRequest extensions we support are key_share and
@@ -218,21 +256,19 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 			c.sendAlert(alertIllegalParameter)
 			return errors.New("tls: server selected unsupported group")
 		}
-		if hs.ecdheParams.CurveID() == curveID {
-			c.sendAlert(alertIllegalParameter)
-			return errors.New("tls: server sent an unnecessary HelloRetryRequest key_share")
-		}
-		if _, ok := curveForCurveID(curveID); curveID != X25519 && !ok {
-			c.sendAlert(alertInternalError)
-			return errors.New("tls: CurvePreferences includes unsupported curve")
+		for _, ks := range hs.hello.keyShares {
+			if ks.group == curveID {
+				c.sendAlert(alertIllegalParameter)
+				return errors.New("tls: server sent an unnecessary HelloRetryRequest key_share")
+			}
 		}
-		params, err := generateECDHEParameters(c.config.rand(), curveID)
+		keyShareKeys, ks, err := generateKeyShare(c.config.rand(), curveID)
 		if err != nil {
 			c.sendAlert(alertInternalError)
 			return err
 		}
-		hs.ecdheParams = params
-		hs.hello.keyShares = []keyShare{{group: curveID, data: params.PublicKey()}}
+		hs.keyShareKeys = keyShareKeys
+		hs.hello.keyShares = []keyShare{ks}
 	}
 
 	hs.hello.raw = nil
@@ -246,10 +282,10 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 			ticketAge := uint32(c.config.time().Sub(hs.session.receivedAt) / time.Millisecond)
 			hs.hello.pskIdentities[0].obfuscatedTicketAge = ticketAge + hs.session.ageAdd
 
-			transcript := hs.suite.hash.New()
-			transcript.Write([]byte{typeMessageHash, 0, 0, uint8(len(chHash))})
-			transcript.Write(chHash)
-			transcript.Write(hs.serverHello.marshal())
+			transcript := cloneHash(hs.transcript, hs.suite.hash)
+			if transcript == nil {
+				return c.sendAlert(alertInternalError)
+			}
 			transcript.Write(hs.hello.marshalWithoutBinders())
 			pskBinders := [][]byte{hs.suite.finishedHash(hs.binderKey, transcript)}
 			hs.hello.updateBinders(pskBinders)
@@ -261,7 +297,18 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 	}
 
 	hs.transcript.Write(hs.hello.marshal())
-	if _, err := c.writeRecord(recordTypeHandshake, hs.hello.marshal()); err != nil {
+	outerHello := hs.hello
+	if c.echAccepted {
+		// The second ClientHelloOuter carries the updated ClientHelloInner,
+		// encrypted with the same HPKE context. See Section 6.1.5.
+		var err error
+		outerHello, err = hs.echContext.outerClientHello(hs.hello, false)
+		if err != nil {
+			c.sendAlert(alertInternalError)
+			return err
+		}
+	}
+	if _, err := c.writeRecord(recordTypeHandshake, outerHello.marshal()); err != nil {
 		return err
 	}
 
@@ -284,6 +331,84 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 	return nil
 }
 
+// checkECHHelloRetryRequest checks whether the HelloRetryRequest in
+// hs.serverHello accepts ECH, in which case it switches hs.hello and
+// hs.transcript to the ClientHelloInner. It must be called with the
+// message_hash already in hs.transcript. See draft-ietf-tls-esni-18,
+// Section 7.2.1.
+func (hs *clientHandshakeStateTLS13) checkECHHelloRetryRequest() error {
+	c := hs.c
+	ech := hs.echContext
+
+	chHash := ech.innerTranscript.Sum(nil)
+	ech.innerTranscript.Reset()
+	ech.innerTranscript.Write([]byte{typeMessageHash, 0, 0, uint8(len(chHash))})
+	ech.innerTranscript.Write(chHash)
+
+	if hs.serverHello.encryptedClientHello == nil {
+		// ECH was rejected, and the handshake continues with the
+		// ClientHelloOuter. The inner transcript can't be used anymore.
+		ech.innerTranscript = nil
+		return nil
+	}
+	msg, ok := zeroExtensionData(hs.serverHello.marshal(), false,
+		extensionEncryptedClientHello, echConfirmationLength)
+	if !ok {
+		c.sendAlert(alertInternalError)
+		return errors.New("tls: failed to locate the encrypted_client_hello extension")
+	}
+	confirmation := echAcceptConfirmation(hs.suite, ech.innerHello.random,
+		echHRRAcceptConfirmationLabel, ech.innerTranscript, msg)
+	if confirmation == nil {
+		return c.sendAlert(alertInternalError)
+	}
+	if !hmac.Equal(confirmation, hs.serverHello.encryptedClientHello) {
+		ech.innerTranscript = nil
+		return nil
+	}
+
+	c.echAccepted = true
+	hs.hello = ech.innerHello
+	hs.transcript = ech.innerTranscript
+	return nil
+}
+
+// checkECHServerHello checks whether the ServerHello in hs.serverHello
+// accepts ECH, in which case it switches hs.hello and hs.transcript to the
+// ClientHelloInner. See draft-ietf-tls-esni-18, Section 6.1.4.
+func (hs *clientHandshakeStateTLS13) checkECHServerHello() error {
+	c := hs.c
+	ech := hs.echContext
+
+	accepted := false
+	if ech.innerTranscript != nil {
+		confirmation := echAcceptConfirmation(hs.suite, ech.innerHello.random,
+			echAcceptConfirmationLabel, ech.innerTranscript,
+			echServerHelloConfirmationInput(hs.serverHello.marshal()))
+		if confirmation == nil {
+			return c.sendAlert(alertInternalError)
+		}
+		accepted = hmac.Equal(confirmation, hs.serverHello.random[32-echConfirmationLength:])
+	}
+
+	// The server must make the same decision for both ClientHellos.
+	if c.echAccepted && !accepted {
+		c.sendAlert(alertIllegalParameter)
+		return errors.New("tls: server accepted ECH in the HelloRetryRequest but not in the ServerHello")
+	}
+
+	if !accepted {
+		// The certificate will be checked against the public name.
+		c.serverName = ech.config.publicName
+		return nil
+	}
+
+	c.echAccepted = true
+	hs.hello = ech.innerHello
+	hs.transcript = ech.innerTranscript
+	return nil
+}
+
 func (hs *clientHandshakeStateTLS13) processServerHello() error {
 	c := hs.c
 
@@ -297,6 +422,11 @@ func (hs *clientHandshakeStateTLS13) processServerHello() error {
 		return errors.New("tls: server sent a cookie in a normal ServerHello")
 	}
 
+	if hs.serverHello.encryptedClientHello != nil {
+		c.sendAlert(alertUnsupportedExtension)
+		return errors.New("tls: server sent an encrypted_client_hello extension in a normal ServerHello")
+	}
+
 	if hs.serverHello.selectedGroup != 0 {
 		c.sendAlert(alertDecodeError)
 		return errors.New("tls: malformed key_share extension")
@@ -306,7 +436,14 @@ func (hs *clientHandshakeStateTLS13) processServerHello() error {
 		c.sendAlert(alertIllegalParameter)
 		return errors.New("tls: server did not send a key share")
 	}
-	if hs.serverHello.serverShare.group != hs.ecdheParams.CurveID() {
+	sentKeyShare := false
+	for _, ks := range hs.hello.keyShares {
+		if ks.group == hs.serverHello.serverShare.group {
+			sentKeyShare = true
+			break
+		}
+	}
+	if !sentKeyShare {
 		c.sendAlert(alertIllegalParameter)
 		return errors.New("tls: server selected unsupported group")
 	}
@@ -344,11 +481,38 @@ func (hs *clientHandshakeStateTLS13) processServerHello() error {
 func (hs *clientHandshakeStateTLS13) establishHandshakeKeys() error {
 	c := hs.c
 
-	sharedKey := hs.ecdheParams.SharedKey(hs.serverHello.serverShare.data)
-	if sharedKey == nil {
+	ecdhePeerData := hs.serverHello.serverShare.data
+	if hs.serverHello.serverShare.group == X25519MLKEM768 {
+		if len(ecdhePeerData) != mlkem768.CiphertextSize+x25519PublicKeySize {
+			c.sendAlert(alertIllegalParameter)
+			return errors.New("tls: invalid server X25519MLKEM768 key share")
+		}
+		ecdhePeerData = hs.serverHello.serverShare.data[mlkem768.CiphertextSize:]
+	}
+	peerKey, err := hs.keyShareKeys.ecdhe.Curve().NewPublicKey(ecdhePeerData)
+	if err != nil {
+		c.sendAlert(alertIllegalParameter)
+		return errors.New("tls: invalid server key share")
+	}
+	sharedKey, err := hs.keyShareKeys.ecdhe.ECDH(peerKey)
+	if err != nil {
 		c.sendAlert(alertIllegalParameter)
 		return errors.New("tls: invalid server key share")
 	}
+	if hs.serverHello.serverShare.group == X25519MLKEM768 {
+		if hs.keyShareKeys.mlkem == nil {
+			return c.sendAlert(alertInternalError)
+		}
+		ciphertext := hs.serverHello.serverShare.data[:mlkem768.CiphertextSize]
+		mlkemShared, err := mlkem768.Decapsulate(hs.keyShareKeys.mlkem, ciphertext)
+		if err != nil {
+			c.sendAlert(alertIllegalParameter)
+			return errors.New("tls: invalid X25519MLKEM768 server key share")
+		}
+		// The ML-KEM shared secret comes first in the hybrid shared secret.
+		sharedKey = append(mlkemShared, sharedKey...)
+	}
+	c.curveID = hs.serverHello.serverShare.group
 
 	earlySecret := hs.earlySecret
 	if !hs.usingPSK {
@@ -359,12 +523,20 @@ func (hs *clientHandshakeStateTLS13) establishHandshakeKeys() error {
 
 	clientSecret := hs.suite.deriveSecret(handshakeSecret,
 		clientHandshakeTrafficLabel, hs.transcript)
-	c.out.setTrafficSecret(hs.suite, clientSecret)
+	c.out.setTrafficSecret(hs.suite, QUICEncryptionLevelHandshake, clientSecret)
 	serverSecret := hs.suite.deriveSecret(handshakeSecret,
 		serverHandshakeTrafficLabel, hs.transcript)
-	c.in.setTrafficSecret(hs.suite, serverSecret)
+	c.in.setTrafficSecret(hs.suite, QUICEncryptionLevelHandshake, serverSecret)
+
+	if c.quic != nil {
+		if c.hand.Len() != 0 {
+			c.sendAlert(alertUnexpectedMessage)
+		}
+		c.quicSetWriteSecret(QUICEncryptionLevelHandshake, hs.suite.id, clientSecret)
+		c.quicSetReadSecret(QUICEncryptionLevelHandshake, hs.suite.id, serverSecret)
+	}
 
-	err := c.config.writeKeyLog(keyLogLabelClientHandshake, hs.hello.random, clientSecret)
+	err = c.config.writeKeyLog(keyLogLabelClientHandshake, hs.hello.random, clientSecret)
 	if err != nil {
 		c.sendAlert(alertInternalError)
 		return err
@@ -396,12 +568,41 @@ func (hs *clientHandshakeStateTLS13) readServerParameters() error {
 	}
 	hs.transcript.Write(encryptedExtensions.marshal())
 
-	if err := checkALPN(hs.hello.alpnProtocols, encryptedExtensions.alpnProtocol); err != nil {
-		c.sendAlert(alertUnsupportedExtension)
+	if err := checkALPN(hs.hello.alpnProtocols, encryptedExtensions.alpnProtocol, c.quic != nil); err != nil {
+		if c.quic != nil {
+			// RFC 9001, Section 8.1
+			c.sendAlert(alertNoApplicationProtocol)
+		} else {
+			c.sendAlert(alertUnsupportedExtension)
+		}
 		return err
 	}
 	c.clientProtocol = encryptedExtensions.alpnProtocol
 
+	if c.quic != nil {
+		if encryptedExtensions.quicTransportParameters == nil {
+			// RFC 9001, Section 8.2
+			c.sendAlert(alertMissingExtension)
+			return errors.New("tls: server did not send a quic_transport_parameters extension")
+		}
+		c.quicSetTransportParameters(encryptedExtensions.quicTransportParameters)
+	} else if encryptedExtensions.quicTransportParameters != nil {
+		c.sendAlert(alertUnsupportedExtension)
+		return errors.New("tls: server sent an unexpected quic_transport_parameters extension")
+	}
+
+	if encryptedExtensions.echRetryConfigs != nil {
+		if hs.echContext == nil || c.echAccepted {
+			c.sendAlert(alertUnsupportedExtension)
+			return errors.New("tls: server sent an unexpected encrypted_client_hello extension")
+		}
+		if _, err := parseECHConfigList(encryptedExtensions.echRetryConfigs); err != nil {
+			c.sendAlert(alertDecodeError)
+			return err
+		}
+		hs.echContext.retryConfigs = encryptedExtensions.echRetryConfigs
+	}
+
 	return nil
 }
 
@@ -522,7 +723,7 @@ func (hs *clientHandshakeStateTLS13) readServerFinished() error {
 		clientApplicationTrafficLabel, hs.transcript)
 	serverSecret := hs.suite.deriveSecret(hs.masterSecret,
 		serverApplicationTrafficLabel, hs.transcript)
-	c.in.setTrafficSecret(hs.suite, serverSecret)
+	c.in.setTrafficSecret(hs.suite, QUICEncryptionLevelApplication, serverSecret)
 
 	err = c.config.writeKeyLog(keyLogLabelClientTraffic, hs.hello.random, hs.trafficSecret)
 	if err != nil {
@@ -547,14 +748,20 @@ func (hs *clientHandshakeStateTLS13) sendClientCertificate() error {
 		return nil
 	}
 
-	cert, err := c.getClientCertificate(&CertificateRequestInfo{
-		AcceptableCAs:    hs.certReq.certificateAuthorities,
-		SignatureSchemes: hs.certReq.supportedSignatureAlgorithms,
-		Version:          c.vers,
-		ctx:              hs.ctx,
-	})
-	if err != nil {
-		return err
+	// If ECH was rejected, the client certificate is not sent to the
+	// client-facing server. See draft-ietf-tls-esni-18, Section 6.1.6.
+	cert := new(Certificate)
+	if hs.echContext == nil || c.echAccepted {
+		var err error
+		cert, err = c.getClientCertificate(&CertificateRequestInfo{
+			AcceptableCAs:    hs.certReq.certificateAuthorities,
+			SignatureSchemes: hs.certReq.supportedSignatureAlgorithms,
+			Version:          c.vers,
+			ctx:              hs.ctx,
+		})
+		if err != nil {
+			return err
+		}
 	}
 
 	certMsg := new(certificateMsgTLS13)
@@ -576,6 +783,7 @@ func (hs *clientHandshakeStateTLS13) sendClientCertificate() error {
 	certVerifyMsg := new(certificateVerifyMsg)
 	certVerifyMsg.hasSignatureAlgorithm = true
 
+	var err error
 	certVerifyMsg.signatureAlgorithm, err = selectSignatureScheme(c.vers, cert, hs.certReq.supportedSignatureAlgorithms)
 	if err != nil {
 		// getClientCertificate returned a certificate incompatible with the
@@ -621,7 +829,10 @@ func (hs *clientHandshakeStateTLS13) sendClientFinished() error {
 		return err
 	}
 
-	c.out.setTrafficSecret(hs.suite, hs.trafficSecret)
+	c.out.setTrafficSecret(hs.suite, QUICEncryptionLevelApplication, hs.trafficSecret)
+	if c.quic != nil {
+		c.quicSetWriteSecret(QUICEncryptionLevelApplication, hs.suite.id, hs.trafficSecret)
+	}
 
 	if !c.config.SessionTicketsDisabled && c.config.ClientSessionCache != nil {
 		c.resumptionSecret = hs.suite.deriveSecret(hs.masterSecret,
@@ -675,8 +886,9 @@ func (c *Conn) handleNewSessionTicket(msg *newSessionTicketMsgTLS13) error {
 		scts:               c.scts,
 	}
 
-	cacheKey := clientSessionCacheKey(c.conn.RemoteAddr(), c.config)
-	c.config.ClientSessionCache.Put(cacheKey, session)
+	if cacheKey := c.clientSessionCacheKey(); cacheKey != "" {
+		c.config.ClientSessionCache.Put(cacheKey, session)
+	}
 
 	return nil
 }
@@ -92,6 +92,8 @@ type clientHelloMsg struct {
 	pskModes                         []uint8
 	pskIdentities                    []pskIdentity
 	pskBinders                       [][]byte
+	quicTransportParameters          []byte
+	encryptedClientHello             []byte
 }
 
 func (m *clientHelloMsg) marshal() []byte {
@@ -266,6 +268,20 @@ func (m *clientHelloMsg) marshal() []byte {
 					})
 				})
 			}
+			if m.quicTransportParameters != nil { // marshal zero-length parameters when present
+				// RFC 9001, Section 8.2
+				b.AddUint16(extensionQUICTransportParameters)
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddBytes(m.quicTransportParameters)
+				})
+			}
+			if len(m.encryptedClientHello) > 0 {
+				// draft-ietf-tls-esni-18, Section 5
+				b.AddUint16(extensionEncryptedClientHello)
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddBytes(m.encryptedClientHello)
+				})
+			}
 			if len(m.pskIdentities) > 0 { // pre_shared_key must be the last extension
 				// RFC 8446, Section 4.2.11
 				b.AddUint16(extensionPreSharedKey)
@@ -545,6 +561,18 @@ func (m *clientHelloMsg) unmarshal(data []byte) bool {
 			if !readUint8LengthPrefixed(&extData, &m.pskModes) {
 				return false
 			}
+		case extensionQUICTransportParameters:
+			// RFC 9001, Section 8.2
+			m.quicTransportParameters = make([]byte, len(extData))
+			if !extData.CopyBytes(m.quicTransportParameters) {
+				return false
+			}
+		case extensionEncryptedClientHello:
+			// draft-ietf-tls-esni-18, Section 5
+			if !extData.ReadBytes(&m.encryptedClientHello, len(extData)) ||
+				len(m.encryptedClientHello) == 0 {
+				return false
+			}
 		case extensionPreSharedKey:
 			// RFC 8446, Section 4.2.11
 			if !extensions.Empty() {
@@ -608,8 +636,9 @@ type serverHelloMsg struct {
 	supportedPoints              []uint8
 
 	// HelloRetryRequest extensions
-	cookie        []byte
-	selectedGroup CurveID
+	cookie               []byte
+	selectedGroup        CurveID
+	encryptedClientHello []byte
 }
 
 func (m *serverHelloMsg) marshal() []byte {
@@ -707,6 +736,12 @@ func (m *serverHelloMsg) marshal() []byte {
 					b.AddUint16(uint16(m.selectedGroup))
 				})
 			}
+			if len(m.encryptedClientHello) > 0 {
+				b.AddUint16(extensionEncryptedClientHello)
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddBytes(m.encryptedClientHello)
+				})
+			}
 			if len(m.supportedPoints) > 0 {
 				b.AddUint16(extensionSupportedPoints)
 				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
@@ -819,6 +854,12 @@ func (m *serverHelloMsg) unmarshal(data []byte) bool {
 			if !extData.ReadUint16(&m.selectedIdentity) {
 				return false
 			}
+		case extensionEncryptedClientHello:
+			// Only sent in a HelloRetryRequest, see draft-ietf-tls-esni-18,
+			// Section 7.2.1.
+			if !extData.ReadBytes(&m.encryptedClientHello, echConfirmationLength) {
+				return false
+			}
 		case extensionSupportedPoints:
 			// RFC 4492, Section 5.1.2
 			if !readUint8LengthPrefixed(&extData, &m.supportedPoints) ||
@@ -839,8 +880,10 @@ func (m *serverHelloMsg) unmarshal(data []byte) bool {
 }
 
 type encryptedExtensionsMsg struct {
-	raw          []byte
-	alpnProtocol string
+	raw                     []byte
+	alpnProtocol            string
+	quicTransportParameters []byte
+	echRetryConfigs         []byte
 }
 
 func (m *encryptedExtensionsMsg) marshal() []byte {
@@ -862,6 +905,20 @@ func (m *encryptedExtensionsMsg) marshal() []byte {
 					})
 				})
 			}
+			if m.quicTransportParameters != nil { // marshal zero-length parameters when present
+				// RFC 9001, Section 8.2
+				b.AddUint16(extensionQUICTransportParameters)
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddBytes(m.quicTransportParameters)
+				})
+			}
+			if len(m.echRetryConfigs) > 0 {
+				// draft-ietf-tls-esni-18, Section 7.1
+				b.AddUint16(extensionEncryptedClientHello)
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddBytes(m.echRetryConfigs)
+				})
+			}
 		})
 	})
 
@@ -899,6 +956,18 @@ func (m *encryptedExtensionsMsg) unmarshal(data []byte) bool {
 				return false
 			}
 			m.alpnProtocol = string(proto)
+		case extensionQUICTransportParameters:
+			// RFC 9001, Section 8.2
+			m.quicTransportParameters = make([]byte, len(extData))
+			if !extData.CopyBytes(m.quicTransportParameters) {
+				return false
+			}
+		case extensionEncryptedClientHello:
+			// draft-ietf-tls-esni-18, Section 7.1
+			if !extData.ReadBytes(&m.echRetryConfigs, len(extData)) ||
+				len(m.echRetryConfigs) == 0 {
+				return false
+			}
 		default:
 			// Ignore unknown extensions.
 			continue
@@ -9,6 +9,7 @@ import (
 	"context"
 	"crypto"
 	"crypto/elliptic"
+	"crypto/internal/mlkem768"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
@@ -42,7 +43,7 @@ func testClientHelloFailure(t *testing.T, serverConfig *Config, m handshakeMessa
 	}()
 	ctx := context.Background()
 	conn := Server(s, serverConfig)
-	ch, err := conn.readClientHello(ctx)
+	ch, _, err := conn.readClientHello(ctx)
 	hs := serverHandshakeState{
 		c:           conn,
 		ctx:         ctx,
@@ -1448,7 +1449,7 @@ func TestSNIGivenOnFailure(t *testing.T) {
 	}()
 	conn := Server(s, serverConfig)
 	ctx := context.Background()
-	ch, err := conn.readClientHello(ctx)
+	ch, _, err := conn.readClientHello(ctx)
 	hs := serverHandshakeState{
 		c:           conn,
 		ctx:         ctx,
@@ -2044,3 +2045,47 @@ func TestHandshakeContextHierarchy(t *testing.T) {
 		t.Errorf("Unexpected client error: %v", err)
 	}
 }
+
+func TestServerRejectsInvalidMLKEMKeyShare(t *testing.T) {
+	serverConfig := testConfig.Clone()
+	serverConfig.CurvePreferences = []CurveID{X25519MLKEM768}
+
+	validEK := make([]byte, mlkem768.EncapsulationKeySize)
+	invalidEK := bytes.Repeat([]byte{0xff}, mlkem768.EncapsulationKeySize)
+	tests := []struct {
+		name      string
+		data      []byte
+		expectErr string
+	}{
+		{"Short", curve25519.Basepoint, "invalid X25519MLKEM768 client key share"},
+		{"Long", append(append(validEK, curve25519.Basepoint...), 0), "invalid X25519MLKEM768 client key share"},
+		{"UnreducedEncapsulationKey", append(invalidEK, curve25519.Basepoint...), "invalid X25519MLKEM768 client key share"},
+		{"LowOrderX25519", append(validEK, make([]byte, x25519PublicKeySize)...), "invalid client key share"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clientHello := &clientHelloMsg{
+				vers:                         VersionTLS12,
+				random:                       make([]byte, 32),
+				cipherSuites:                 []uint16{TLS_AES_128_GCM_SHA256},
+				compressionMethods:           []uint8{compressionNone},
+				supportedCurves:              []CurveID{X25519MLKEM768},
+				supportedSignatureAlgorithms: supportedSignatureAlgorithms,
+				supportedVersions:            []uint16{VersionTLS13},
+				keyShares:                    []keyShare{{group: X25519MLKEM768, data: test.data}},
+			}
+			c, s := localPipe(t)
+			go func() {
+				cli := Client(c, testConfig)
+				cli.vers = clientHello.vers
+				cli.writeRecord(recordTypeHandshake, clientHello.marshal())
+				c.Close()
+			}()
+			err := Server(s, serverConfig).Handshake()
+			s.Close()
+			if err == nil || !strings.Contains(err.Error(), test.expectErr) {
+				t.Errorf("Got error: %v; expected to match substring '%s'", err, test.expectErr)
+			}
+		})
+	}
+}
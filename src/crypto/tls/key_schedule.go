@@ -5,15 +5,14 @@
 package tls
 
 import (
-	"crypto/elliptic"
+	"crypto/ecdh"
 	"crypto/hmac"
+	"crypto/internal/mlkem768"
 	"errors"
 	"hash"
 	"io"
-	"math/big"
 
 	"golang.org/x/crypto/cryptobyte"
-	"golang.org/x/crypto/curve25519"
 	"golang.org/x/crypto/hkdf"
 )
 
@@ -101,99 +100,73 @@ func (c *cipherSuiteTLS13) exportKeyingMaterial(masterSecret []byte, transcript
 	}
 }
 
-// ecdheParameters implements Diffie-Hellman with either NIST curves or X25519,
-// according to RFC 8446, Section 4.2.8.2.
-type ecdheParameters interface {
-	CurveID() CurveID
-	PublicKey() []byte
-	SharedKey(peerPublicKey []byte) []byte
+const x25519PublicKeySize = 32
+
+// keySharePrivateKeys holds the private keys for a TLS 1.3 key share. For
+// X25519MLKEM768, both ecdhe (an X25519 key) and mlkem are set, and the X25519
+// key can also be used for a standalone X25519 key share.
+type keySharePrivateKeys struct {
+	curveID CurveID
+	ecdhe   *ecdh.PrivateKey
+	mlkem   *mlkem768.DecapsulationKey
 }
 
-func generateECDHEParameters(rand io.Reader, curveID CurveID) (ecdheParameters, error) {
-	if curveID == X25519 {
-		privateKey := make([]byte, curve25519.ScalarSize)
-		if _, err := io.ReadFull(rand, privateKey); err != nil {
-			return nil, err
+// generateKeyShare returns the private keys and the key share for curveID,
+// according to RFC 8446, Section 4.2.8 and draft-kwiatkowski-tls-ecdhe-mlkem.
+func generateKeyShare(rand io.Reader, curveID CurveID) (*keySharePrivateKeys, keyShare, error) {
+	keys := &keySharePrivateKeys{curveID: curveID}
+	if curveID != X25519MLKEM768 {
+		if _, ok := curveForCurveID(curveID); !ok {
+			return nil, keyShare{}, errors.New("tls: CurvePreferences includes unsupported curve")
 		}
-		publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+		key, err := generateECDHEKey(rand, curveID)
 		if err != nil {
-			return nil, err
+			return nil, keyShare{}, err
 		}
-		return &x25519Parameters{privateKey: privateKey, publicKey: publicKey}, nil
+		keys.ecdhe = key
+		return keys, keyShare{group: curveID, data: key.PublicKey().Bytes()}, nil
+	}
+
+	key, err := generateECDHEKey(rand, X25519)
+	if err != nil {
+		return nil, keyShare{}, err
+	}
+	keys.ecdhe = key
+	seed := make([]byte, mlkem768.SeedSize)
+	if _, err := io.ReadFull(rand, seed); err != nil {
+		return nil, keyShare{}, err
+	}
+	keys.mlkem, err = mlkem768.NewKeyFromSeed(seed)
+	if err != nil {
+		return nil, keyShare{}, err
 	}
+	// The ML-KEM encapsulation key comes first, followed by the X25519 share.
+	data := append(keys.mlkem.EncapsulationKey(), key.PublicKey().Bytes()...)
+	return keys, keyShare{group: X25519MLKEM768, data: data}, nil
+}
 
+// generateECDHEKey returns a PrivateKey that implements Diffie-Hellman
+// according to RFC 8446, Section 4.2.8.2.
+func generateECDHEKey(rand io.Reader, curveID CurveID) (*ecdh.PrivateKey, error) {
 	curve, ok := curveForCurveID(curveID)
 	if !ok {
 		return nil, errors.New("tls: internal error: unsupported curve")
 	}
 
-	p := &nistParameters{curveID: curveID}
-	var err error
-	p.privateKey, p.x, p.y, err = elliptic.GenerateKey(curve, rand)
-	if err != nil {
-		return nil, err
-	}
-	return p, nil
+	return curve.GenerateKey(rand)
 }
 
-func curveForCurveID(id CurveID) (elliptic.Curve, bool) {
+func curveForCurveID(id CurveID) (ecdh.Curve, bool) {
 	switch id {
+	case X25519:
+		return ecdh.X25519(), true
 	case CurveP256:
-		return elliptic.P256(), true
+		return ecdh.P256(), true
 	case CurveP384:
-		return elliptic.P384(), true
+		return ecdh.P384(), true
 	case CurveP521:
-		return elliptic.P521(), true
+		return ecdh.P521(), true
 	default:
 		return nil, false
 	}
 }
-
-type nistParameters struct {
-	privateKey []byte
-	x, y       *big.Int // public key
-	curveID    CurveID
-}
-
-func (p *nistParameters) CurveID() CurveID {
-	return p.curveID
-}
-
-func (p *nistParameters) PublicKey() []byte {
-	curve, _ := curveForCurveID(p.curveID)
-	return elliptic.Marshal(curve, p.x, p.y)
-}
-
-func (p *nistParameters) SharedKey(peerPublicKey []byte) []byte {
-	curve, _ := curveForCurveID(p.curveID)
-	// Unmarshal also checks whether the given point is on the curve.
-	x, y := elliptic.Unmarshal(curve, peerPublicKey)
-	if x == nil {
-		return nil
-	}
-
-	xShared, _ := curve.ScalarMult(x, y, p.privateKey)
-	sharedKey := make([]byte, (curve.Params().BitSize+7)/8)
-	return xShared.FillBytes(sharedKey)
-}
-
-type x25519Parameters struct {
-	privateKey []byte
-	publicKey  []byte
-}
-
-func (p *x25519Parameters) CurveID() CurveID {
-	return X25519
-}
-
-func (p *x25519Parameters) PublicKey() []byte {
-	return p.publicKey[:]
-}
-
-func (p *x25519Parameters) SharedKey(peerPublicKey []byte) []byte {
-	sharedKey, err := curve25519.X25519(p.privateKey, peerPublicKey)
-	if err != nil {
-		return nil
-	}
-	return sharedKey
-}
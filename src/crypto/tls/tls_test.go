@@ -734,7 +734,7 @@ func TestWarningAlertFlood(t *testing.T) {
 }
 
 func TestCloneFuncFields(t *testing.T) {
-	const expectedCount = 6
+	const expectedCount = 7
 	called := 0
 
 	c1 := Config{
@@ -762,6 +762,10 @@ func TestCloneFuncFields(t *testing.T) {
 			called |= 1 << 5
 			return nil
 		},
+		EncryptedClientHelloRejectionVerify: func(ConnectionState) error {
+			called |= 1 << 6
+			return nil
+		},
 	}
 
 	c2 := c1.Clone()
@@ -772,6 +776,7 @@ func TestCloneFuncFields(t *testing.T) {
 	c2.GetConfigForClient(nil)
 	c2.VerifyPeerCertificate(nil, nil)
 	c2.VerifyConnection(ConnectionState{})
+	c2.EncryptedClientHelloRejectionVerify(ConnectionState{})
 
 	if called != (1<<expectedCount)-1 {
 		t.Fatalf("expected %d calls but saw calls %b", expectedCount, called)
@@ -790,7 +795,7 @@ func TestCloneNonFuncFields(t *testing.T) {
 		switch fn := typ.Field(i).Name; fn {
 		case "Rand":
 			f.Set(reflect.ValueOf(io.Reader(os.Stdin)))
-		case "Time", "GetCertificate", "GetConfigForClient", "VerifyPeerCertificate", "VerifyConnection", "GetClientCertificate":
+		case "Time", "GetCertificate", "GetConfigForClient", "VerifyPeerCertificate", "VerifyConnection", "GetClientCertificate", "EncryptedClientHelloRejectionVerify":
 			// DeepEqual can't compare functions. If you add a
 			// function field to this list, you must also change
 			// TestCloneFuncFields to ensure that the func field is
@@ -813,7 +818,7 @@ func TestCloneNonFuncFields(t *testing.T) {
 			f.Set(reflect.ValueOf("b"))
 		case "ClientAuth":
 			f.Set(reflect.ValueOf(VerifyClientCertIfGiven))
-		case "InsecureSkipVerify", "SessionTicketsDisabled", "DynamicRecordSizingDisabled", "PreferServerCipherSuites":
+		case "InsecureSkipVerify", "VerifyOCSPStaple", "SessionTicketsDisabled", "DynamicRecordSizingDisabled", "PreferServerCipherSuites":
 			f.Set(reflect.ValueOf(true))
 		case "MinVersion", "MaxVersion":
 			f.Set(reflect.ValueOf(uint16(VersionTLS12)))
@@ -825,6 +830,12 @@ func TestCloneNonFuncFields(t *testing.T) {
 			f.Set(reflect.ValueOf([]CurveID{CurveP256}))
 		case "Renegotiation":
 			f.Set(reflect.ValueOf(RenegotiateOnceAsClient))
+		case "EncryptedClientHelloConfigList":
+			f.Set(reflect.ValueOf([]byte{'x'}))
+		case "EncryptedClientHelloKeys":
+			f.Set(reflect.ValueOf([]EncryptedClientHelloKey{
+				{Config: []byte{1}, PrivateKey: []byte{2}, SendAsRetry: true},
+			}))
 		case "mutex", "autoSessionTicketKeys", "sessionTicketKeys":
 			continue // these are unexported fields that are handled separately
 		default:
@@ -1609,3 +1620,131 @@ func TestPKCS1OnlyCert(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestHandshakeMLKEM(t *testing.T) {
+	var tests = []struct {
+		name              string
+		clientConfig      func(*Config)
+		serverConfig      func(*Config)
+		expectClientHello func(*testing.T, *ClientHelloInfo)
+		expectCurve       CurveID
+		expectHRR         bool
+		expectErr         string
+	}{
+		{
+			name:        "Default",
+			expectCurve: X25519,
+		},
+		{
+			name: "ClientAndServer",
+			clientConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519MLKEM768, X25519}
+			},
+			serverConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519MLKEM768, X25519}
+			},
+			expectCurve: X25519MLKEM768,
+		},
+		{
+			name: "ClientOnly",
+			clientConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519MLKEM768, X25519}
+			},
+			expectCurve: X25519,
+		},
+		{
+			name: "ServerOnly",
+			serverConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519MLKEM768, X25519}
+			},
+			expectCurve: X25519,
+		},
+		{
+			name: "ClientOnlyNoFallback",
+			clientConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519MLKEM768}
+			},
+			expectErr: "no ECDHE curve supported by both client and server",
+		},
+		{
+			name: "HelloRetryRequestToMLKEM",
+			clientConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519, X25519MLKEM768}
+			},
+			serverConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519MLKEM768, CurveP256}
+			},
+			expectCurve: X25519MLKEM768,
+			expectHRR:   true,
+		},
+		{
+			name: "HelloRetryRequestFromMLKEM",
+			clientConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519MLKEM768, CurveP256}
+			},
+			serverConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{CurveP256}
+			},
+			expectCurve: CurveP256,
+			expectHRR:   true,
+		},
+		{
+			name: "TLSv12",
+			clientConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519MLKEM768, X25519}
+				config.MaxVersion = VersionTLS12
+			},
+			serverConfig: func(config *Config) {
+				config.CurvePreferences = []CurveID{X25519MLKEM768, X25519}
+			},
+			expectClientHello: func(t *testing.T, chi *ClientHelloInfo) {
+				for _, c := range chi.SupportedCurves {
+					if c == X25519MLKEM768 {
+						t.Error("X25519MLKEM768 advertised in a TLS 1.2 ClientHello")
+					}
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clientConfig := testConfig.Clone()
+			if test.clientConfig != nil {
+				test.clientConfig(clientConfig)
+			}
+			serverConfig := testConfig.Clone()
+			if test.serverConfig != nil {
+				test.serverConfig(serverConfig)
+			}
+			serverConfig.GetConfigForClient = func(chi *ClientHelloInfo) (*Config, error) {
+				if test.expectClientHello != nil {
+					test.expectClientHello(t, chi)
+				}
+				return nil, nil
+			}
+
+			serverState, clientState, err := testHandshake(t, clientConfig, serverConfig)
+			if test.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectErr) {
+					t.Fatalf("expected error containing %q, got %v", test.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.expectCurve == 0 {
+				return
+			}
+			for _, state := range []ConnectionState{serverState, clientState} {
+				if state.testingOnlyCurveID != test.expectCurve {
+					t.Errorf("got curve %v, expected %v", state.testingOnlyCurveID, test.expectCurve)
+				}
+				if state.testingOnlyDidHRR != test.expectHRR {
+					t.Errorf("got HelloRetryRequest %v, expected %v", state.testingOnlyDidHRR, test.expectHRR)
+				}
+			}
+		})
+	}
+}
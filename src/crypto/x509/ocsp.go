@@ -0,0 +1,688 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// OCSPStatus is the status of a certificate in an OCSP response, as defined
+// in RFC 6960, Section 4.2.1.
+type OCSPStatus int
+
+const (
+	// OCSPGood indicates that the certificate is not revoked.
+	OCSPGood OCSPStatus = iota
+	// OCSPRevoked indicates that the certificate has been revoked.
+	OCSPRevoked
+	// OCSPUnknown indicates that the responder doesn't know about the
+	// certificate.
+	OCSPUnknown
+)
+
+// OCSPResponseError is returned by ParseOCSPResponse when the responder
+// didn't return a successful response. Status is the OCSPResponseStatus
+// value defined in RFC 6960, Section 4.2.1.
+type OCSPResponseError struct {
+	Status int
+}
+
+func (e OCSPResponseError) Error() string {
+	switch e.Status {
+	case 1:
+		return "x509: OCSP responder reported a malformed request"
+	case 2:
+		return "x509: OCSP responder reported an internal error"
+	case 3:
+		return "x509: OCSP responder asked to try later"
+	case 5:
+		return "x509: OCSP responder requires a signed request"
+	case 6:
+		return "x509: OCSP request is unauthorized"
+	}
+	return fmt.Sprintf("x509: OCSP responder returned unknown status %d", e.Status)
+}
+
+// OCSPResponse represents a basic OCSP response for a single certificate, as
+// specified by RFC 6960. It is used both to create a response with
+// CreateOCSPResponse and to hold the result of ParseOCSPResponse.
+type OCSPResponse struct {
+	// Raw contains the complete ASN.1 DER content of the OCSPResponse.
+	Raw []byte
+	// RawResponseData contains just the tbsResponseData portion of the
+	// ASN.1 DER, over which the signature is computed.
+	RawResponseData []byte
+
+	// RawResponderName and ResponderKeyHash identify the responder. Only one
+	// of them is set when parsing a response. They are ignored when creating
+	// a response, which identifies the responder by key hash.
+	RawResponderName []byte
+	ResponderKeyHash []byte
+
+	// ProducedAt is the time at which the response was signed. If zero when
+	// creating a response, the current time is used.
+	ProducedAt time.Time
+
+	// IssuerHash is the hash algorithm used for IssuerNameHash and
+	// IssuerKeyHash. If zero when creating a response, SHA-1 is used.
+	IssuerHash crypto.Hash
+	// IssuerNameHash and IssuerKeyHash identify the issuer of the
+	// certificate. They are populated when parsing a response and ignored
+	// when creating one.
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	// SerialNumber is the serial number of the certificate the response is
+	// about. It must not be nil when creating a response.
+	SerialNumber *big.Int
+
+	// Status is the status of the certificate.
+	Status OCSPStatus
+	// RevokedAt and RevocationReason are only meaningful if Status is
+	// OCSPRevoked. RevocationReason uses the integer enum values specified in
+	// RFC 5280, Section 5.3.1.
+	RevokedAt        time.Time
+	RevocationReason int
+	// ThisUpdate is the time at which the status was known to be correct.
+	ThisUpdate time.Time
+	// NextUpdate is the time at or before which newer information will be
+	// available. It may be zero, in which case it is omitted.
+	NextUpdate time.Time
+
+	Signature []byte
+	// SignatureAlgorithm is used to determine the signature algorithm to be
+	// used when signing the response. If 0 the default algorithm for the
+	// signing key will be used.
+	SignatureAlgorithm SignatureAlgorithm
+
+	// Certificates contains the certificates included in the response,
+	// typically a delegated responder certificate. It is populated when
+	// parsing a response and ignored when creating one.
+	Certificates []*Certificate
+
+	// Extensions contains the raw singleExtensions of the response. When
+	// creating a response, the Extensions field is ignored, see
+	// ExtraExtensions.
+	Extensions []pkix.Extension
+	// ResponseExtensions contains the raw responseExtensions of the
+	// response, such as a nonce. It is only populated when parsing.
+	ResponseExtensions []pkix.Extension
+	// ExtraExtensions contains extensions to be copied, raw, into the
+	// singleExtensions of a created response.
+	ExtraExtensions []pkix.Extension
+}
+
+var oidOCSPBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+var ocspHashOIDs = map[crypto.Hash]asn1.ObjectIdentifier{
+	crypto.SHA1:   {1, 3, 14, 3, 2, 26},
+	crypto.SHA256: oidSHA256,
+	crypto.SHA384: oidSHA384,
+	crypto.SHA512: oidSHA512,
+}
+
+// ParseOCSPResponse parses an OCSP response from the given ASN.1 DER data.
+// The response must be a successful basic OCSP response for a single
+// certificate, as is the case for responses stapled in TLS handshakes. If
+// the responder reported an error, an OCSPResponseError is returned.
+//
+// The signature and contents of the response are not verified, see
+// OCSPResponse.Verify.
+func ParseOCSPResponse(der []byte) (*OCSPResponse, error) {
+	resp := &OCSPResponse{}
+
+	input := cryptobyte.String(der)
+	if !input.ReadASN1Element(&input, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed OCSP response")
+	}
+	resp.Raw = input
+	if len(der) != len(resp.Raw) {
+		return nil, errors.New("x509: trailing data")
+	}
+	if !input.ReadASN1(&input, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed OCSP response")
+	}
+
+	var status int
+	if !input.ReadASN1Enum(&status) {
+		return nil, errors.New("x509: malformed OCSP response status")
+	}
+	if status != 0 {
+		return nil, OCSPResponseError{Status: status}
+	}
+
+	var responseBytes cryptobyte.String
+	var responseType asn1.ObjectIdentifier
+	if !input.ReadASN1(&responseBytes, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) ||
+		!responseBytes.ReadASN1(&responseBytes, cryptobyte_asn1.SEQUENCE) ||
+		!responseBytes.ReadASN1ObjectIdentifier(&responseType) {
+		return nil, errors.New("x509: malformed OCSP response bytes")
+	}
+	if !responseType.Equal(oidOCSPBasicResponse) {
+		return nil, fmt.Errorf("x509: unsupported OCSP response type %v", responseType)
+	}
+
+	var basic cryptobyte.String
+	if !responseBytes.ReadASN1(&basic, cryptobyte_asn1.OCTET_STRING) ||
+		!basic.ReadASN1(&basic, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed basic OCSP response")
+	}
+
+	var tbs cryptobyte.String
+	if !basic.ReadASN1Element(&tbs, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed OCSP response data")
+	}
+	resp.RawResponseData = tbs
+	if !tbs.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed OCSP response data")
+	}
+
+	var sigAISeq cryptobyte.String
+	if !basic.ReadASN1(&sigAISeq, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed signature algorithm identifier")
+	}
+	sigAI, err := parseAI(sigAISeq)
+	if err != nil {
+		return nil, err
+	}
+	resp.SignatureAlgorithm = getSignatureAlgorithmFromAI(sigAI)
+
+	var signature asn1.BitString
+	if !basic.ReadASN1BitString(&signature) {
+		return nil, errors.New("x509: malformed signature")
+	}
+	resp.Signature = signature.RightAlign()
+
+	var certs cryptobyte.String
+	var hasCerts bool
+	if !basic.ReadOptionalASN1(&certs, &hasCerts, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+		return nil, errors.New("x509: malformed OCSP response certificates")
+	}
+	if hasCerts {
+		if !certs.ReadASN1(&certs, cryptobyte_asn1.SEQUENCE) {
+			return nil, errors.New("x509: malformed OCSP response certificates")
+		}
+		resp.Certificates, err = ParseCertificates(certs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var version int
+	if !tbs.ReadOptionalASN1Integer(&version, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), 0) {
+		return nil, errors.New("x509: malformed OCSP response version")
+	}
+	if version != 0 {
+		return nil, fmt.Errorf("x509: unsupported OCSP response version: %d", version)
+	}
+
+	switch {
+	case tbs.PeekASN1Tag(cryptobyte_asn1.Tag(1).Constructed().ContextSpecific()):
+		var name cryptobyte.String
+		if !tbs.ReadASN1(&name, cryptobyte_asn1.Tag(1).Constructed().ContextSpecific()) ||
+			!name.ReadASN1Element(&name, cryptobyte_asn1.SEQUENCE) {
+			return nil, errors.New("x509: malformed OCSP responder name")
+		}
+		resp.RawResponderName = name
+	case tbs.PeekASN1Tag(cryptobyte_asn1.Tag(2).Constructed().ContextSpecific()):
+		var keyHash cryptobyte.String
+		if !tbs.ReadASN1(&keyHash, cryptobyte_asn1.Tag(2).Constructed().ContextSpecific()) ||
+			!keyHash.ReadASN1(&keyHash, cryptobyte_asn1.OCTET_STRING) {
+			return nil, errors.New("x509: malformed OCSP responder key hash")
+		}
+		resp.ResponderKeyHash = keyHash
+	default:
+		return nil, errors.New("x509: malformed OCSP responder ID")
+	}
+
+	if !tbs.ReadASN1GeneralizedTime(&resp.ProducedAt) {
+		return nil, errors.New("x509: malformed OCSP producedAt")
+	}
+
+	var responses cryptobyte.String
+	if !tbs.ReadASN1(&responses, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed OCSP responses")
+	}
+	var single cryptobyte.String
+	if !responses.ReadASN1(&single, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed OCSP single response")
+	}
+	if !responses.Empty() {
+		return nil, errors.New("x509: OCSP response contains more than one certificate status")
+	}
+	if err := parseOCSPSingleResponse(single, resp); err != nil {
+		return nil, err
+	}
+
+	resp.ResponseExtensions, err = parseOCSPExtensions(&tbs, 1)
+	if err != nil {
+		return nil, err
+	}
+	if !tbs.Empty() {
+		return nil, errors.New("x509: malformed OCSP response data")
+	}
+
+	return resp, nil
+}
+
+// parseOCSPSingleResponse parses a SingleResponse, as defined in RFC 6960,
+// Section 4.2.1, into resp.
+func parseOCSPSingleResponse(der cryptobyte.String, resp *OCSPResponse) error {
+	var certID, hashAISeq cryptobyte.String
+	if !der.ReadASN1(&certID, cryptobyte_asn1.SEQUENCE) ||
+		!certID.ReadASN1(&hashAISeq, cryptobyte_asn1.SEQUENCE) {
+		return errors.New("x509: malformed OCSP certificate ID")
+	}
+	hashAI, err := parseAI(hashAISeq)
+	if err != nil {
+		return err
+	}
+	for h, oid := range ocspHashOIDs {
+		if hashAI.Algorithm.Equal(oid) {
+			resp.IssuerHash = h
+		}
+	}
+	resp.SerialNumber = new(big.Int)
+	if !certID.ReadASN1Bytes(&resp.IssuerNameHash, cryptobyte_asn1.OCTET_STRING) ||
+		!certID.ReadASN1Bytes(&resp.IssuerKeyHash, cryptobyte_asn1.OCTET_STRING) ||
+		!certID.ReadASN1Integer(resp.SerialNumber) {
+		return errors.New("x509: malformed OCSP certificate ID")
+	}
+
+	var certStatus cryptobyte.String
+	var tag cryptobyte_asn1.Tag
+	if !der.ReadAnyASN1(&certStatus, &tag) {
+		return errors.New("x509: malformed OCSP certificate status")
+	}
+	switch tag {
+	case cryptobyte_asn1.Tag(0).ContextSpecific():
+		resp.Status = OCSPGood
+	case cryptobyte_asn1.Tag(1).Constructed().ContextSpecific():
+		resp.Status = OCSPRevoked
+		if !certStatus.ReadASN1GeneralizedTime(&resp.RevokedAt) {
+			return errors.New("x509: malformed OCSP revocation time")
+		}
+		var reason cryptobyte.String
+		var hasReason bool
+		if !certStatus.ReadOptionalASN1(&reason, &hasReason, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+			return errors.New("x509: malformed OCSP revocation reason")
+		}
+		if hasReason && !reason.ReadASN1Enum(&resp.RevocationReason) {
+			return errors.New("x509: malformed OCSP revocation reason")
+		}
+	case cryptobyte_asn1.Tag(2).ContextSpecific():
+		resp.Status = OCSPUnknown
+	default:
+		return errors.New("x509: malformed OCSP certificate status")
+	}
+
+	if !der.ReadASN1GeneralizedTime(&resp.ThisUpdate) {
+		return errors.New("x509: malformed OCSP thisUpdate")
+	}
+	var nextUpdate cryptobyte.String
+	var hasNextUpdate bool
+	if !der.ReadOptionalASN1(&nextUpdate, &hasNextUpdate, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+		return errors.New("x509: malformed OCSP nextUpdate")
+	}
+	if hasNextUpdate && !nextUpdate.ReadASN1GeneralizedTime(&resp.NextUpdate) {
+		return errors.New("x509: malformed OCSP nextUpdate")
+	}
+
+	resp.Extensions, err = parseOCSPExtensions(&der, 1)
+	if err != nil {
+		return err
+	}
+	if !der.Empty() {
+		return errors.New("x509: malformed OCSP single response")
+	}
+	return nil
+}
+
+// parseOCSPExtensions parses an optional, explicitly tagged, Extensions
+// field.
+func parseOCSPExtensions(der *cryptobyte.String, tag uint8) ([]pkix.Extension, error) {
+	var extensions cryptobyte.String
+	var present bool
+	if !der.ReadOptionalASN1(&extensions, &present, cryptobyte_asn1.Tag(tag).Constructed().ContextSpecific()) {
+		return nil, errors.New("x509: malformed extensions")
+	}
+	if !present {
+		return nil, nil
+	}
+	if !extensions.ReadASN1(&extensions, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed extensions")
+	}
+	var exts []pkix.Extension
+	for !extensions.Empty() {
+		var extension cryptobyte.String
+		if !extensions.ReadASN1(&extension, cryptobyte_asn1.SEQUENCE) {
+			return nil, errors.New("x509: malformed extension")
+		}
+		ext, err := parseExtension(extension)
+		if err != nil {
+			return nil, err
+		}
+		exts = append(exts, ext)
+	}
+	return exts, nil
+}
+
+// publicKeyBytes returns the contents of the subjectPublicKey BIT STRING of
+// c, over which OCSP key hashes are computed.
+func publicKeyBytes(c *Certificate) ([]byte, error) {
+	spki := cryptobyte.String(c.RawSubjectPublicKeyInfo)
+	var key []byte
+	if !spki.ReadASN1(&spki, cryptobyte_asn1.SEQUENCE) ||
+		!spki.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+		!spki.ReadASN1BitStringAsBytes(&key) {
+		return nil, errors.New("x509: malformed subject public key info")
+	}
+	return key, nil
+}
+
+// ocspIssuerHashes returns the hashes of the name and public key of issuer
+// that identify it in the CertID of an OCSP response.
+func ocspIssuerHashes(hash crypto.Hash, issuer *Certificate) (nameHash, keyHash []byte, err error) {
+	issuerKey, err := publicKeyBytes(issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+	h := hash.New()
+	h.Write(issuer.RawSubject)
+	nameHash = h.Sum(nil)
+	h.Reset()
+	h.Write(issuerKey)
+	keyHash = h.Sum(nil)
+	return nameHash, keyHash, nil
+}
+
+// CheckSignatureFrom verifies that the signature on resp is a valid signature
+// from issuer, or from a responder certificate included in resp that was
+// issued by issuer and is authorized to sign OCSP responses.
+func (resp *OCSPResponse) CheckSignatureFrom(issuer *Certificate) error {
+	_, err := resp.checkSignatureFrom(issuer)
+	return err
+}
+
+// checkSignatureFrom is like CheckSignatureFrom, but also returns the
+// certificate whose key signed resp.
+func (resp *OCSPResponse) checkSignatureFrom(issuer *Certificate) (*Certificate, error) {
+	err := issuer.CheckSignature(resp.SignatureAlgorithm, resp.RawResponseData, resp.Signature)
+	if err == nil {
+		return issuer, nil
+	}
+
+	// RFC 6960, Section 4.2.2.2: a delegated responder must be issued
+	// directly by the CA and include id-kp-OCSPSigning.
+	for _, responder := range resp.Certificates {
+		if !hasExtKeyUsage(responder, ExtKeyUsageOCSPSigning) ||
+			!bytes.Equal(responder.RawIssuer, issuer.RawSubject) {
+			continue
+		}
+		if responder.CheckSignatureFrom(issuer) != nil {
+			continue
+		}
+		if responder.CheckSignature(resp.SignatureAlgorithm, resp.RawResponseData, resp.Signature) == nil {
+			return responder, nil
+		}
+	}
+	return nil, errors.New("x509: OCSP response is not signed by the issuer or an authorized responder: " + err.Error())
+}
+
+func hasExtKeyUsage(c *Certificate, usage ExtKeyUsage) bool {
+	for _, u := range c.ExtKeyUsage {
+		if u == usage {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify checks that resp is a valid, current OCSP response reporting cert,
+// issued by issuer, as good. It checks the signature of the response, that it
+// is about cert, and that currentTime is between ThisUpdate and NextUpdate. If
+// currentTime is zero, the current time is used.
+//
+// If the response is valid but reports cert as revoked, the returned error is
+// a CertificateInvalidError with Reason Revoked.
+func (resp *OCSPResponse) Verify(cert, issuer *Certificate, currentTime time.Time) error {
+	if currentTime.IsZero() {
+		currentTime = time.Now()
+	}
+
+	signer, err := resp.checkSignatureFrom(issuer)
+	if err != nil {
+		return err
+	}
+	if signer != issuer && (currentTime.Before(signer.NotBefore) || currentTime.After(signer.NotAfter)) {
+		return errors.New("x509: OCSP responder certificate has expired or is not yet valid")
+	}
+
+	if resp.SerialNumber.Cmp(cert.SerialNumber) != 0 || !bytes.Equal(cert.RawIssuer, issuer.RawSubject) {
+		return errors.New("x509: OCSP response is for a different certificate")
+	}
+	if resp.IssuerHash == 0 || !resp.IssuerHash.Available() {
+		return errors.New("x509: OCSP response uses an unsupported issuer hash algorithm")
+	}
+	nameHash, keyHash, err := ocspIssuerHashes(resp.IssuerHash, issuer)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(resp.IssuerNameHash, nameHash) || !bytes.Equal(resp.IssuerKeyHash, keyHash) {
+		return errors.New("x509: OCSP response is for a certificate from a different issuer")
+	}
+
+	if currentTime.Before(resp.ThisUpdate) ||
+		!resp.NextUpdate.IsZero() && currentTime.After(resp.NextUpdate) {
+		return fmt.Errorf("x509: OCSP response is not yet valid or has expired: current time %s is outside of %s - %s",
+			currentTime.Format(time.RFC3339), resp.ThisUpdate.Format(time.RFC3339), resp.NextUpdate.Format(time.RFC3339))
+	}
+
+	switch resp.Status {
+	case OCSPGood:
+		return nil
+	case OCSPRevoked:
+		return CertificateInvalidError{
+			Cert:   cert,
+			Reason: Revoked,
+			Detail: fmt.Sprintf("OCSP response reports serial number %x as revoked at %s", cert.SerialNumber, resp.RevokedAt.Format(time.RFC3339)),
+		}
+	}
+	return errors.New("x509: OCSP responder doesn't know the status of the certificate")
+}
+
+type ocspResponseASN1 struct {
+	Status   asn1.Enumerated
+	Response ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type basicOCSPResponse struct {
+	TBSResponseData    asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseData struct {
+	ResponderID asn1.RawValue
+	ProducedAt  time.Time `asn1:"generalized"`
+	Responses   []ocspSingleResponse
+}
+
+type ocspCertID struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+type ocspSingleResponse struct {
+	CertID           ocspCertID
+	Good             asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked          ocspRevokedInfo  `asn1:"tag:1,optional"`
+	Unknown          asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate       time.Time        `asn1:"generalized"`
+	NextUpdate       time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	SingleExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspRevokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+// CreateOCSPResponse creates a new basic OCSP response, according to RFC
+// 6960, based on template.
+//
+// The response is about a certificate with template.SerialNumber issued by
+// issuer. It is signed by priv, which should be the private key associated
+// with the public key in responder. responder must be either issuer, or a
+// certificate issued by issuer with the OCSP signing extended key usage, in
+// which case it is included in the response.
+func CreateOCSPResponse(rand io.Reader, template *OCSPResponse, issuer, responder *Certificate, priv crypto.Signer) ([]byte, error) {
+	if template == nil {
+		return nil, errors.New("x509: template can not be nil")
+	}
+	if issuer == nil || responder == nil {
+		return nil, errors.New("x509: issuer and responder can not be nil")
+	}
+	if template.SerialNumber == nil {
+		return nil, errors.New("x509: template contains nil SerialNumber field")
+	}
+	if !template.NextUpdate.IsZero() && template.NextUpdate.Before(template.ThisUpdate) {
+		return nil, errors.New("x509: template.ThisUpdate is after template.NextUpdate")
+	}
+
+	issuerHash := template.IssuerHash
+	if issuerHash == 0 {
+		issuerHash = crypto.SHA1
+	}
+	hashOID, ok := ocspHashOIDs[issuerHash]
+	if !ok || !issuerHash.Available() {
+		return nil, errors.New("x509: unsupported issuer hash algorithm")
+	}
+	nameHash, keyHash, err := ocspIssuerHashes(issuerHash, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	single := ocspSingleResponse{
+		CertID: ocspCertID{
+			HashAlgorithm: pkix.AlgorithmIdentifier{
+				Algorithm:  hashOID,
+				Parameters: asn1.NullRawValue,
+			},
+			IssuerNameHash: nameHash,
+			IssuerKeyHash:  keyHash,
+			SerialNumber:   template.SerialNumber,
+		},
+		ThisUpdate:       template.ThisUpdate.UTC(),
+		SingleExtensions: template.ExtraExtensions,
+	}
+	if !template.NextUpdate.IsZero() {
+		single.NextUpdate = template.NextUpdate.UTC()
+	}
+	switch template.Status {
+	case OCSPGood:
+		single.Good = true
+	case OCSPRevoked:
+		single.Revoked = ocspRevokedInfo{
+			RevocationTime: template.RevokedAt.UTC(),
+			Reason:         asn1.Enumerated(template.RevocationReason),
+		}
+	case OCSPUnknown:
+		single.Unknown = true
+	default:
+		return nil, errors.New("x509: template contains invalid Status field")
+	}
+
+	responderKey, err := publicKeyBytes(responder)
+	if err != nil {
+		return nil, err
+	}
+	responderKeyHash := sha1.Sum(responderKey)
+	responderID, err := asn1.Marshal(responderKeyHash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	producedAt := template.ProducedAt
+	if producedAt.IsZero() {
+		producedAt = time.Now()
+	}
+	tbsResponseData, err := asn1.Marshal(ocspResponseData{
+		ResponderID: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        2,
+			IsCompound: true,
+			Bytes:      responderID,
+		},
+		ProducedAt: producedAt.UTC(),
+		Responses:  []ocspSingleResponse{single},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hashFunc, signatureAlgorithm, err := signingParamsForPublicKey(priv.Public(), template.SignatureAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	input := tbsResponseData
+	if hashFunc != 0 {
+		h := hashFunc.New()
+		h.Write(tbsResponseData)
+		input = h.Sum(nil)
+	}
+	var signerOpts crypto.SignerOpts = hashFunc
+	if template.SignatureAlgorithm.isRSAPSS() {
+		signerOpts = &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthEqualsHash,
+			Hash:       hashFunc,
+		}
+	}
+	signature, err := priv.Sign(rand, input, signerOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	basic := basicOCSPResponse{
+		TBSResponseData:    asn1.RawValue{FullBytes: tbsResponseData},
+		SignatureAlgorithm: signatureAlgorithm,
+		Signature:          asn1.BitString{Bytes: signature, BitLength: len(signature) * 8},
+	}
+	if responder != issuer && !bytes.Equal(responder.Raw, issuer.Raw) {
+		basic.Certificates = []asn1.RawValue{{FullBytes: responder.Raw}}
+	}
+	basicDER, err := asn1.Marshal(basic)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(ocspResponseASN1{
+		Status: 0, // successful
+		Response: ocspResponseBytes{
+			ResponseType: oidOCSPBasicResponse,
+			Response:     basicDER,
+		},
+	})
+}
@@ -0,0 +1,280 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func generateOCSPResponder(t *testing.T, issuer *Certificate, issuerKey crypto.PrivateKey, usages []ExtKeyUsage, notAfter time.Time) (*Certificate, crypto.Signer) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "OCSP Responder"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     KeyUsageDigitalSignature,
+		ExtKeyUsage:  usages,
+	}
+	der, err := CreateCertificate(rand.Reader, template, issuer, priv.Public(), issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, priv
+}
+
+func TestOCSPResponseRoundTrip(t *testing.T) {
+	issuer, issuerKey, err := generateCert("Issuer", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, issuer, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	extraExtension := pkix.Extension{Id: []int{1, 2, 3, 4}, Value: []byte{5, 0}}
+	for _, hash := range []crypto.Hash{0, crypto.SHA1, crypto.SHA256, crypto.SHA384, crypto.SHA512} {
+		for _, status := range []OCSPStatus{OCSPGood, OCSPRevoked, OCSPUnknown} {
+			template := &OCSPResponse{
+				IssuerHash:       hash,
+				SerialNumber:     leaf.SerialNumber,
+				Status:           status,
+				RevokedAt:        now.Add(-time.Minute),
+				RevocationReason: 1, // keyCompromise
+				ThisUpdate:       now.Add(-time.Hour),
+				NextUpdate:       now.Add(time.Hour),
+				ExtraExtensions:  []pkix.Extension{extraExtension},
+			}
+			der, err := CreateOCSPResponse(rand.Reader, template, issuer, issuer, issuerKey.(crypto.Signer))
+			if err != nil {
+				t.Fatalf("CreateOCSPResponse failed: %s", err)
+			}
+			resp, err := ParseOCSPResponse(der)
+			if err != nil {
+				t.Fatalf("ParseOCSPResponse failed: %s", err)
+			}
+
+			if resp.Status != status {
+				t.Errorf("Status mismatch: got %v, want %v", resp.Status, status)
+			}
+			wantHash := hash
+			if wantHash == 0 {
+				wantHash = crypto.SHA1
+			}
+			if resp.IssuerHash != wantHash {
+				t.Errorf("IssuerHash mismatch: got %v, want %v", resp.IssuerHash, wantHash)
+			}
+			if resp.SerialNumber.Cmp(leaf.SerialNumber) != 0 {
+				t.Errorf("SerialNumber mismatch: got %v, want %v", resp.SerialNumber, leaf.SerialNumber)
+			}
+			if !resp.ThisUpdate.Equal(template.ThisUpdate.Truncate(time.Second)) || !resp.NextUpdate.Equal(template.NextUpdate.Truncate(time.Second)) {
+				t.Errorf("update times mismatch: got %v and %v", resp.ThisUpdate, resp.NextUpdate)
+			}
+			if status == OCSPRevoked {
+				if !resp.RevokedAt.Equal(template.RevokedAt.Truncate(time.Second)) || resp.RevocationReason != 1 {
+					t.Errorf("revocation info mismatch: got %v, reason %d", resp.RevokedAt, resp.RevocationReason)
+				}
+			}
+			if len(resp.ResponderKeyHash) == 0 || resp.RawResponderName != nil {
+				t.Errorf("unexpected responder ID: name %x, key hash %x", resp.RawResponderName, resp.ResponderKeyHash)
+			}
+			if len(resp.Certificates) != 0 {
+				t.Errorf("unexpected certificates in the response: %d", len(resp.Certificates))
+			}
+			if len(resp.Extensions) != 1 || !resp.Extensions[0].Id.Equal(extraExtension.Id) {
+				t.Errorf("unexpected extensions: %v", resp.Extensions)
+			}
+			if err := resp.CheckSignatureFrom(issuer); err != nil {
+				t.Errorf("CheckSignatureFrom failed: %s", err)
+			}
+
+			err = resp.Verify(leaf, issuer, now)
+			switch status {
+			case OCSPGood:
+				if err != nil {
+					t.Errorf("Verify failed: %s", err)
+				}
+			case OCSPRevoked:
+				var invalidErr CertificateInvalidError
+				if !errors.As(err, &invalidErr) || invalidErr.Reason != Revoked || invalidErr.Cert != leaf {
+					t.Errorf("expected a Revoked CertificateInvalidError, got %v", err)
+				}
+			case OCSPUnknown:
+				if err == nil {
+					t.Error("Verify succeeded for an unknown certificate")
+				}
+			}
+		}
+	}
+}
+
+func TestOCSPResponseVerify(t *testing.T) {
+	issuer, issuerKey, err := generateCert("Issuer", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherIssuer, otherIssuerKey, err := generateCert("Other Issuer", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, issuer, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherLeaf, _, err := generateCert("Other Leaf", false, issuer, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	delegate, delegateKey := generateOCSPResponder(t, issuer, issuerKey, []ExtKeyUsage{ExtKeyUsageOCSPSigning}, now.Add(time.Hour))
+	notDelegate, notDelegateKey := generateOCSPResponder(t, issuer, issuerKey, []ExtKeyUsage{ExtKeyUsageServerAuth}, now.Add(time.Hour))
+	expiredDelegate, expiredDelegateKey := generateOCSPResponder(t, issuer, issuerKey, []ExtKeyUsage{ExtKeyUsageOCSPSigning}, now.Add(-time.Minute))
+	foreignDelegate, foreignDelegateKey := generateOCSPResponder(t, otherIssuer, otherIssuerKey, []ExtKeyUsage{ExtKeyUsageOCSPSigning}, now.Add(time.Hour))
+
+	tests := []struct {
+		name      string
+		template  OCSPResponse
+		responder *Certificate
+		key       crypto.Signer
+		cert      *Certificate
+		issuer    *Certificate
+		expectErr string
+	}{
+		{
+			name:      "SignedByIssuer",
+			responder: issuer,
+			key:       issuerKey.(crypto.Signer),
+		},
+		{
+			name:      "SignedByDelegate",
+			responder: delegate,
+			key:       delegateKey,
+		},
+		{
+			name:      "NoNextUpdate",
+			template:  OCSPResponse{ThisUpdate: now.Add(-48 * time.Hour)},
+			responder: issuer,
+			key:       issuerKey.(crypto.Signer),
+		},
+		{
+			name:      "DelegateWithoutOCSPSigning",
+			responder: notDelegate,
+			key:       notDelegateKey,
+			expectErr: "not signed by the issuer or an authorized responder",
+		},
+		{
+			name:      "DelegateFromOtherIssuer",
+			responder: foreignDelegate,
+			key:       foreignDelegateKey,
+			expectErr: "not signed by the issuer or an authorized responder",
+		},
+		{
+			name:      "ExpiredDelegate",
+			responder: expiredDelegate,
+			key:       expiredDelegateKey,
+			expectErr: "responder certificate has expired",
+		},
+		{
+			name:      "OtherIssuer",
+			responder: otherIssuer,
+			key:       otherIssuerKey.(crypto.Signer),
+			expectErr: "not signed by the issuer or an authorized responder",
+		},
+		{
+			name:      "OtherCertificate",
+			responder: issuer,
+			key:       issuerKey.(crypto.Signer),
+			cert:      otherLeaf,
+			expectErr: "for a different certificate",
+		},
+		{
+			name:      "Expired",
+			template:  OCSPResponse{ThisUpdate: now.Add(-2 * time.Hour), NextUpdate: now.Add(-time.Hour)},
+			responder: issuer,
+			key:       issuerKey.(crypto.Signer),
+			expectErr: "not yet valid or has expired",
+		},
+		{
+			name:      "NotYetValid",
+			template:  OCSPResponse{ThisUpdate: now.Add(time.Hour), NextUpdate: now.Add(2 * time.Hour)},
+			responder: issuer,
+			key:       issuerKey.(crypto.Signer),
+			expectErr: "not yet valid or has expired",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			template := test.template
+			template.SerialNumber = leaf.SerialNumber
+			if template.ThisUpdate.IsZero() {
+				template.ThisUpdate = now.Add(-time.Hour)
+				template.NextUpdate = now.Add(time.Hour)
+			}
+			der, err := CreateOCSPResponse(rand.Reader, &template, issuer, test.responder, test.key)
+			if err != nil {
+				t.Fatalf("CreateOCSPResponse failed: %s", err)
+			}
+			resp, err := ParseOCSPResponse(der)
+			if err != nil {
+				t.Fatalf("ParseOCSPResponse failed: %s", err)
+			}
+			cert := leaf
+			if test.cert != nil {
+				cert = test.cert
+			}
+			err = resp.Verify(cert, issuer, now)
+			if test.expectErr == "" {
+				if err != nil {
+					t.Fatalf("Verify failed: %s", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), test.expectErr) {
+				t.Fatalf("Verify: got error %v, expected it to contain %q", err, test.expectErr)
+			}
+		})
+	}
+}
+
+func TestParseOCSPResponseErrors(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		der       []byte
+		expectErr error
+	}{
+		{"TryLater", []byte{0x30, 0x03, 0x0a, 0x01, 0x03}, OCSPResponseError{Status: 3}},
+		{"Unauthorized", []byte{0x30, 0x03, 0x0a, 0x01, 0x06}, OCSPResponseError{Status: 6}},
+		{"MissingResponseBytes", []byte{0x30, 0x03, 0x0a, 0x01, 0x00}, nil},
+		{"TrailingData", []byte{0x30, 0x03, 0x0a, 0x01, 0x03, 0x00}, nil},
+		{"Empty", []byte{}, nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseOCSPResponse(test.der)
+			if err == nil {
+				t.Fatal("ParseOCSPResponse succeeded unexpectedly")
+			}
+			if test.expectErr != nil && err != test.expectErr {
+				t.Fatalf("got error %v, expected %v", err, test.expectErr)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ package x509
 import (
 	"bytes"
 	"crypto/dsa"
+	"crypto/ecdh"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
@@ -164,53 +165,53 @@ func parseAI(der cryptobyte.String) (pkix.AlgorithmIdentifier, error) {
 	return ai, nil
 }
 
-func parseValidity(der cryptobyte.String) (time.Time, time.Time, error) {
-	extract := func() (time.Time, error) {
-		var t time.Time
-		switch {
-		case der.PeekASN1Tag(cryptobyte_asn1.UTCTime):
-			// TODO(rolandshoemaker): once #45411 is fixed, the following code
-			// should be replaced with a call to der.ReadASN1UTCTime.
-			var utc cryptobyte.String
-			if !der.ReadASN1(&utc, cryptobyte_asn1.UTCTime) {
-				return t, errors.New("x509: malformed UTCTime")
-			}
-			s := string(utc)
+func parseTime(der *cryptobyte.String) (time.Time, error) {
+	var t time.Time
+	switch {
+	case der.PeekASN1Tag(cryptobyte_asn1.UTCTime):
+		// TODO(rolandshoemaker): once #45411 is fixed, the following code
+		// should be replaced with a call to der.ReadASN1UTCTime.
+		var utc cryptobyte.String
+		if !der.ReadASN1(&utc, cryptobyte_asn1.UTCTime) {
+			return t, errors.New("x509: malformed UTCTime")
+		}
+		s := string(utc)
 
-			formatStr := "0601021504Z0700"
-			var err error
+		formatStr := "0601021504Z0700"
+		var err error
+		t, err = time.Parse(formatStr, s)
+		if err != nil {
+			formatStr = "060102150405Z0700"
 			t, err = time.Parse(formatStr, s)
-			if err != nil {
-				formatStr = "060102150405Z0700"
-				t, err = time.Parse(formatStr, s)
-			}
-			if err != nil {
-				return t, err
-			}
+		}
+		if err != nil {
+			return t, err
+		}
 
-			if serialized := t.Format(formatStr); serialized != s {
-				return t, errors.New("x509: malformed UTCTime")
-			}
+		if serialized := t.Format(formatStr); serialized != s {
+			return t, errors.New("x509: malformed UTCTime")
+		}
 
-			if t.Year() >= 2050 {
-				// UTCTime only encodes times prior to 2050. See https://tools.ietf.org/html/rfc5280#section-4.1.2.5.1
-				t = t.AddDate(-100, 0, 0)
-			}
-		case der.PeekASN1Tag(cryptobyte_asn1.GeneralizedTime):
-			if !der.ReadASN1GeneralizedTime(&t) {
-				return t, errors.New("x509: malformed GeneralizedTime")
-			}
-		default:
-			return t, errors.New("x509: unsupported time format")
+		if t.Year() >= 2050 {
+			// UTCTime only encodes times prior to 2050. See https://tools.ietf.org/html/rfc5280#section-4.1.2.5.1
+			t = t.AddDate(-100, 0, 0)
 		}
-		return t, nil
+	case der.PeekASN1Tag(cryptobyte_asn1.GeneralizedTime):
+		if !der.ReadASN1GeneralizedTime(&t) {
+			return t, errors.New("x509: malformed GeneralizedTime")
+		}
+	default:
+		return t, errors.New("x509: unsupported time format")
 	}
+	return t, nil
+}
 
-	notBefore, err := extract()
+func parseValidity(der cryptobyte.String) (time.Time, time.Time, error) {
+	notBefore, err := parseTime(&der)
 	if err != nil {
 		return time.Time{}, time.Time{}, err
 	}
-	notAfter, err := extract()
+	notAfter, err := parseTime(&der)
 	if err != nil {
 		return time.Time{}, time.Time{}, err
 	}
@@ -236,7 +237,21 @@ func parseExtension(der cryptobyte.String) (pkix.Extension, error) {
 	return ext, nil
 }
 
+// parseX25519PublicKey parses an X25519 public key, which has no
+// corresponding PublicKeyAlgorithm as it can't be used for signatures.
+func parseX25519PublicKey(keyData *publicKeyInfo) (any, error) {
+	// RFC 8410, Section 3
+	// > For all of the OIDs, the parameters MUST be absent.
+	if len(keyData.Algorithm.Parameters.FullBytes) != 0 {
+		return nil, errors.New("x509: X25519 key encoded with illegal parameters")
+	}
+	return ecdh.X25519().NewPublicKey(keyData.PublicKey.RightAlign())
+}
+
 func parsePublicKey(algo PublicKeyAlgorithm, keyData *publicKeyInfo) (any, error) {
+	if keyData.Algorithm.Algorithm.Equal(oidPublicKeyX25519) {
+		return parseX25519PublicKey(keyData)
+	}
 	der := cryptobyte.String(keyData.PublicKey.RightAlign())
 	switch algo {
 	case RSA:
@@ -468,6 +483,86 @@ func parseCertificatePoliciesExtension(der cryptobyte.String) ([]asn1.ObjectIden
 	return oids, nil
 }
 
+func parsePolicyMappingsExtension(der cryptobyte.String) ([]PolicyMapping, error) {
+	// RFC 5280, 4.2.1.5
+	//
+	// PolicyMappings ::= SEQUENCE SIZE (1..MAX) OF SEQUENCE {
+	//     issuerDomainPolicy      CertPolicyId,
+	//     subjectDomainPolicy     CertPolicyId }
+	var mappings []PolicyMapping
+	if !der.ReadASN1(&der, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: invalid policy mappings")
+	}
+	for !der.Empty() {
+		var pm cryptobyte.String
+		var mapping PolicyMapping
+		if !der.ReadASN1(&pm, cryptobyte_asn1.SEQUENCE) ||
+			!pm.ReadASN1ObjectIdentifier(&mapping.IssuerDomainPolicy) ||
+			!pm.ReadASN1ObjectIdentifier(&mapping.SubjectDomainPolicy) {
+			return nil, errors.New("x509: invalid policy mappings")
+		}
+		mappings = append(mappings, mapping)
+	}
+
+	return mappings, nil
+}
+
+func parsePolicyConstraintsExtension(out *Certificate, der cryptobyte.String) error {
+	// RFC 5280, 4.2.1.11
+	//
+	// PolicyConstraints ::= SEQUENCE {
+	//     requireExplicitPolicy   [0] SkipCerts OPTIONAL,
+	//     inhibitPolicyMapping    [1] SkipCerts OPTIONAL }
+	//
+	// SkipCerts ::= INTEGER (0..MAX)
+	if !der.ReadASN1(&der, cryptobyte_asn1.SEQUENCE) {
+		return errors.New("x509: invalid policy constraints")
+	}
+	readSkipCerts := func(tag cryptobyte_asn1.Tag) (skip int, present bool, err error) {
+		if !der.PeekASN1Tag(tag) {
+			return 0, false, nil
+		}
+		var v int64
+		if !der.ReadASN1Int64WithTag(&v, tag) || v < 0 || int64(int(v)) != v {
+			return 0, false, errors.New("x509: invalid policy constraints")
+		}
+		return int(v), true, nil
+	}
+	var present bool
+	var err error
+	out.RequireExplicitPolicy, present, err = readSkipCerts(cryptobyte_asn1.Tag(0).ContextSpecific())
+	if err != nil {
+		return err
+	}
+	out.RequireExplicitPolicyZero = present && out.RequireExplicitPolicy == 0
+	out.InhibitPolicyMapping, present, err = readSkipCerts(cryptobyte_asn1.Tag(1).ContextSpecific())
+	if err != nil {
+		return err
+	}
+	out.InhibitPolicyMappingZero = present && out.InhibitPolicyMapping == 0
+	if !der.Empty() {
+		return errors.New("x509: invalid policy constraints")
+	}
+	return nil
+}
+
+// parseAuthorityKeyIdentifier parses the keyIdentifier field of the
+// authority key identifier extension, as defined in RFC 5280, 4.2.1.1.
+// It returns nil if the extension doesn't contain a key identifier.
+func parseAuthorityKeyIdentifier(der cryptobyte.String) ([]byte, error) {
+	var akid cryptobyte.String
+	if !der.ReadASN1(&akid, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: invalid authority key identifier")
+	}
+	if akid.PeekASN1Tag(cryptobyte_asn1.Tag(0).ContextSpecific()) {
+		if !akid.ReadASN1(&akid, cryptobyte_asn1.Tag(0).ContextSpecific()) {
+			return nil, errors.New("x509: invalid authority key identifier")
+		}
+		return akid, nil
+	}
+	return nil, nil
+}
+
 // isValidIPMask reports whether mask consists of zero or more 1 bits, followed by zero bits.
 func isValidIPMask(mask []byte) bool {
 	seenZero := false
@@ -736,16 +831,9 @@ func processExtensions(out *Certificate) error {
 
 			case 35:
 				// RFC 5280, 4.2.1.1
-				val := cryptobyte.String(e.Value)
-				var akid cryptobyte.String
-				if !val.ReadASN1(&akid, cryptobyte_asn1.SEQUENCE) {
-					return errors.New("x509: invalid authority key identifier")
-				}
-				if akid.PeekASN1Tag(cryptobyte_asn1.Tag(0).ContextSpecific()) {
-					if !akid.ReadASN1(&akid, cryptobyte_asn1.Tag(0).ContextSpecific()) {
-						return errors.New("x509: invalid authority key identifier")
-					}
-					out.AuthorityKeyId = akid
+				out.AuthorityKeyId, err = parseAuthorityKeyIdentifier(e.Value)
+				if err != nil {
+					return err
 				}
 			case 37:
 				out.ExtKeyUsage, out.UnknownExtKeyUsage, err = parseExtKeyUsageExtension(e.Value)
@@ -765,6 +853,22 @@ func processExtensions(out *Certificate) error {
 				if err != nil {
 					return err
 				}
+			case 33:
+				out.PolicyMappings, err = parsePolicyMappingsExtension(e.Value)
+				if err != nil {
+					return err
+				}
+			case 36:
+				if err := parsePolicyConstraintsExtension(out, e.Value); err != nil {
+					return err
+				}
+			case 54:
+				// RFC 5280, 4.2.1.14
+				val := cryptobyte.String(e.Value)
+				if !val.ReadASN1Integer(&out.InhibitAnyPolicy) || out.InhibitAnyPolicy < 0 {
+					return errors.New("x509: invalid inhibit anyPolicy")
+				}
+				out.InhibitAnyPolicyZero = out.InhibitAnyPolicy == 0
 			default:
 				// Unknown extensions are recorded if critical.
 				unhandled = true
@@ -1011,3 +1115,207 @@ func ParseCertificates(der []byte) ([]*Certificate, error) {
 	}
 	return certs, nil
 }
+
+// ParseRevocationList parses a X509 v2 Certificate Revocation List from the given
+// ASN.1 DER data.
+func ParseRevocationList(der []byte) (*RevocationList, error) {
+	rl := &RevocationList{}
+
+	input := cryptobyte.String(der)
+	// we read the SEQUENCE including length and tag bytes so that
+	// we can populate RevocationList.Raw, before unwrapping the
+	// SEQUENCE so it can be operated on
+	if !input.ReadASN1Element(&input, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed crl")
+	}
+	rl.Raw = input
+	if len(der) != len(rl.Raw) {
+		return nil, errors.New("x509: trailing data")
+	}
+	if !input.ReadASN1(&input, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed crl")
+	}
+
+	var tbs cryptobyte.String
+	// do the same trick again as above to extract the raw
+	// bytes for RevocationList.RawTBSRevocationList
+	if !input.ReadASN1Element(&tbs, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed tbs crl")
+	}
+	rl.RawTBSRevocationList = tbs
+	if !tbs.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed tbs crl")
+	}
+
+	// the version is optional, and only present (as v2) when the
+	// CRL contains extensions
+	if tbs.PeekASN1Tag(cryptobyte_asn1.INTEGER) {
+		var version int
+		if !tbs.ReadASN1Integer(&version) {
+			return nil, errors.New("x509: malformed crl version")
+		}
+		if version != 1 {
+			return nil, fmt.Errorf("x509: unsupported crl version: %d", version)
+		}
+	}
+
+	var sigAISeq cryptobyte.String
+	if !tbs.ReadASN1(&sigAISeq, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed signature algorithm identifier")
+	}
+	// Before parsing the inner algorithm identifier, extract
+	// the outer algorithm identifier and make sure that they
+	// match.
+	var outerSigAISeq cryptobyte.String
+	if !input.ReadASN1(&outerSigAISeq, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed algorithm identifier")
+	}
+	if !bytes.Equal(outerSigAISeq, sigAISeq) {
+		return nil, errors.New("x509: inner and outer signature algorithm identifiers don't match")
+	}
+	sigAI, err := parseAI(sigAISeq)
+	if err != nil {
+		return nil, err
+	}
+	rl.SignatureAlgorithm = getSignatureAlgorithmFromAI(sigAI)
+
+	var signature asn1.BitString
+	if !input.ReadASN1BitString(&signature) {
+		return nil, errors.New("x509: malformed signature")
+	}
+	rl.Signature = signature.RightAlign()
+
+	var issuerSeq cryptobyte.String
+	if !tbs.ReadASN1Element(&issuerSeq, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed issuer")
+	}
+	rl.RawIssuer = issuerSeq
+	issuerRDNs, err := parseName(issuerSeq)
+	if err != nil {
+		return nil, err
+	}
+	rl.Issuer.FillFromRDNSequence(issuerRDNs)
+
+	rl.ThisUpdate, err = parseTime(&tbs)
+	if err != nil {
+		return nil, err
+	}
+	if tbs.PeekASN1Tag(cryptobyte_asn1.GeneralizedTime) || tbs.PeekASN1Tag(cryptobyte_asn1.UTCTime) {
+		rl.NextUpdate, err = parseTime(&tbs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if tbs.PeekASN1Tag(cryptobyte_asn1.SEQUENCE) {
+		var revokedSeq cryptobyte.String
+		if !tbs.ReadASN1(&revokedSeq, cryptobyte_asn1.SEQUENCE) {
+			return nil, errors.New("x509: malformed crl")
+		}
+		for !revokedSeq.Empty() {
+			rce, err := parseRevocationListEntry(&revokedSeq)
+			if err != nil {
+				return nil, err
+			}
+			rl.RevokedCertificateEntries = append(rl.RevokedCertificateEntries, rce)
+			rl.RevokedCertificates = append(rl.RevokedCertificates, pkix.RevokedCertificate{
+				SerialNumber:   rce.SerialNumber,
+				RevocationTime: rce.RevocationTime,
+				Extensions:     rce.Extensions,
+			})
+		}
+	}
+
+	var extensions cryptobyte.String
+	var present bool
+	if !tbs.ReadOptionalASN1(&extensions, &present, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+		return nil, errors.New("x509: malformed extensions")
+	}
+	if present {
+		if !extensions.ReadASN1(&extensions, cryptobyte_asn1.SEQUENCE) {
+			return nil, errors.New("x509: malformed extensions")
+		}
+		for !extensions.Empty() {
+			var extension cryptobyte.String
+			if !extensions.ReadASN1(&extension, cryptobyte_asn1.SEQUENCE) {
+				return nil, errors.New("x509: malformed extension")
+			}
+			ext, err := parseExtension(extension)
+			if err != nil {
+				return nil, err
+			}
+			if ext.Id.Equal(oidExtensionAuthorityKeyId) {
+				rl.AuthorityKeyId, err = parseAuthorityKeyIdentifier(ext.Value)
+				if err != nil {
+					return nil, err
+				}
+			} else if ext.Id.Equal(oidExtensionCRLNumber) {
+				// RFC 5280, 5.2.3
+				value := cryptobyte.String(ext.Value)
+				rl.Number = new(big.Int)
+				if !value.ReadASN1Integer(rl.Number) {
+					return nil, errors.New("x509: malformed crl number")
+				}
+			}
+			rl.Extensions = append(rl.Extensions, ext)
+		}
+	}
+	if !tbs.Empty() {
+		return nil, errors.New("x509: malformed tbs crl")
+	}
+
+	return rl, nil
+}
+
+// parseRevocationListEntry parses a single entry of the revokedCertificates
+// sequence of a CRL, as defined in RFC 5280, 5.1.2.6.
+func parseRevocationListEntry(der *cryptobyte.String) (RevocationListEntry, error) {
+	var rce RevocationListEntry
+
+	var certSeq cryptobyte.String
+	if !der.ReadASN1Element(&certSeq, cryptobyte_asn1.SEQUENCE) {
+		return rce, errors.New("x509: malformed crl entry")
+	}
+	rce.Raw = certSeq
+	if !certSeq.ReadASN1(&certSeq, cryptobyte_asn1.SEQUENCE) {
+		return rce, errors.New("x509: malformed crl entry")
+	}
+
+	rce.SerialNumber = new(big.Int)
+	if !certSeq.ReadASN1Integer(rce.SerialNumber) {
+		return rce, errors.New("x509: malformed serial number")
+	}
+	var err error
+	rce.RevocationTime, err = parseTime(&certSeq)
+	if err != nil {
+		return rce, err
+	}
+
+	var extensions cryptobyte.String
+	var present bool
+	if !certSeq.ReadOptionalASN1(&extensions, &present, cryptobyte_asn1.SEQUENCE) {
+		return rce, errors.New("x509: malformed extensions")
+	}
+	if present {
+		for !extensions.Empty() {
+			var extension cryptobyte.String
+			if !extensions.ReadASN1(&extension, cryptobyte_asn1.SEQUENCE) {
+				return rce, errors.New("x509: malformed extension")
+			}
+			ext, err := parseExtension(extension)
+			if err != nil {
+				return rce, err
+			}
+			if ext.Id.Equal(oidExtensionReasonCode) {
+				// RFC 5280, 5.3.1
+				value := cryptobyte.String(ext.Value)
+				if !value.ReadASN1Enum(&rce.ReasonCode) {
+					return rce, errors.New("x509: malformed reasonCode extension")
+				}
+			}
+			rce.Extensions = append(rce.Extensions, ext)
+		}
+	}
+
+	return rce, nil
+}
@@ -6,6 +6,7 @@ package x509
 
 import (
 	"bytes"
+	"encoding/asn1"
 	"errors"
 	"fmt"
 	"net"
@@ -54,6 +55,12 @@ const (
 	// CANotAuthorizedForExtKeyUsage results when an intermediate or root
 	// certificate does not permit a requested extended key usage.
 	CANotAuthorizedForExtKeyUsage
+	// Revoked results when a certificate is listed as revoked in one of the
+	// revocation lists given in the VerifyOptions.
+	Revoked
+	// NoValidPolicy results when there is no certificate policy that is
+	// both valid for the chain and acceptable to the VerifyOptions.
+	NoValidPolicy
 )
 
 // CertificateInvalidError results when an odd error occurs. Users of this
@@ -84,6 +91,10 @@ func (e CertificateInvalidError) Error() string {
 		return "x509: issuer has name constraints but leaf doesn't have a SAN extension"
 	case UnconstrainedName:
 		return "x509: issuer has name constraints but leaf contains unknown or unconstrained name: " + e.Detail
+	case Revoked:
+		return "x509: certificate has been revoked: " + e.Detail
+	case NoValidPolicy:
+		return "x509: no valid certificate policy for the chain: " + e.Detail
 	}
 	return "x509: unknown error"
 }
@@ -199,6 +210,48 @@ type VerifyOptions struct {
 	// certificates from consuming excessive amounts of CPU time when
 	// validating. It does not apply to the platform verifier.
 	MaxConstraintComparisions int
+
+	// RevocationLists is an optional set of CRLs used to check the
+	// revocation status of the certificates in each chain. A CRL is used
+	// for a certificate only if it was issued, and signed, by the
+	// certificate's parent in the chain and it contains no critical
+	// extensions that this package doesn't handle. Chains containing a
+	// revoked certificate are rejected. Certificates for which no suitable
+	// CRL is provided are not considered revoked.
+	//
+	// Since revocation is permanent, CRLs are used even after their
+	// NextUpdate time, except that certificateHold entries of such stale
+	// CRLs are ignored, as the hold may have been lifted since.
+	RevocationLists []*RevocationList
+
+	// CertificatePolicies is the initial policy set of the RFC 5280 path
+	// validation algorithm. If not empty, a chain is accepted only if it is
+	// valid for at least one of the listed policy OIDs, taking into account
+	// the policy mappings of its intermediates, as if RequireExplicitPolicy
+	// was also set. An empty list means any policy.
+	CertificatePolicies []asn1.ObjectIdentifier
+
+	// RequireExplicitPolicy, InhibitPolicyMapping and InhibitAnyPolicy
+	// correspond to the initial-explicit-policy, initial-policy-mapping-inhibit
+	// and initial-any-policy-inhibit inputs of the RFC 5280 path validation
+	// algorithm. When RequireExplicitPolicy is set, every certificate in a
+	// chain must assert an acceptable policy. When InhibitPolicyMapping is
+	// set, policy mappings in intermediates are not applied. When
+	// InhibitAnyPolicy is set, the anyPolicy OID asserted by a certificate
+	// is not considered to match other policies.
+	RequireExplicitPolicy bool
+	InhibitPolicyMapping  bool
+	InhibitAnyPolicy      bool
+
+	// VerifyChain, if not nil, is called for every candidate chain that
+	// passed all the other checks performed by Verify, including the
+	// platform verifier if one is used. It can implement additional
+	// constraints, for example on names or extensions this package doesn't
+	// process. Chains for which it returns a non-nil error are discarded.
+	// If every chain is discarded, Verify returns the error of the last call.
+	//
+	// The chain must not be modified.
+	VerifyChain func(chain []*Certificate) error
 }
 
 const (
@@ -724,7 +777,16 @@ func (c *Certificate) isValid(certType int, currentChain []*Certificate, opts *V
 // list. (While this is not specified, it is common practice in order to limit
 // the types of certificates a CA can issue.)
 //
-// WARNING: this function doesn't do any revocation checking.
+// Certificate policies are processed as specified in RFC 5280, Section 6.1,
+// as updated by RFC 9618, with opts.CertificatePolicies as the initial policy
+// set. Policy qualifiers are ignored.
+//
+// Revocation checking is only performed against the CRLs provided in
+// opts.RevocationLists. WARNING: this function doesn't fetch CRLs or perform
+// any other form of revocation checking.
+//
+// Finally, if opts.VerifyChain is set, it is called for each remaining chain
+// and can reject it.
 func (c *Certificate) Verify(opts VerifyOptions) (chains [][]*Certificate, err error) {
 	// Platform-specific verification needs the ASN.1 contents so
 	// this makes the behavior consistent across platforms.
@@ -744,15 +806,22 @@ func (c *Certificate) Verify(opts VerifyOptions) (chains [][]*Certificate, err e
 	// Use platform verifiers, where available, if Roots is from SystemCertPool.
 	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
 		if opts.Roots == nil {
-			return c.systemVerify(&opts)
+			platformChains, err := c.systemVerify(&opts)
+			if err != nil {
+				return nil, err
+			}
+			return filterChains(platformChains, &opts)
 		}
 		if opts.Roots != nil && opts.Roots.systemPool {
 			platformChains, err := c.systemVerify(&opts)
 			// If the platform verifier succeeded, or there are no additional
 			// roots, return the platform verifier result. Otherwise, continue
 			// with the Go verifier.
-			if err == nil || opts.Roots.len() == 0 {
-				return platformChains, err
+			if err == nil {
+				return filterChains(platformChains, &opts)
+			}
+			if opts.Roots.len() == 0 {
+				return nil, err
 			}
 		}
 	}
@@ -790,10 +859,11 @@ func (c *Certificate) Verify(opts VerifyOptions) (chains [][]*Certificate, err e
 		keyUsages = []ExtKeyUsage{ExtKeyUsageServerAuth}
 	}
 
-	// If any key usage is acceptable then we're done.
+	// If any key usage is acceptable then only the checks in filterChains
+	// remain to be performed.
 	for _, usage := range keyUsages {
 		if usage == ExtKeyUsageAny {
-			return candidateChains, nil
+			return filterChains(candidateChains, &opts)
 		}
 	}
 
@@ -807,7 +877,95 @@ func (c *Certificate) Verify(opts VerifyOptions) (chains [][]*Certificate, err e
 		return nil, CertificateInvalidError{c, IncompatibleUsage, ""}
 	}
 
-	return chains, nil
+	return filterChains(chains, &opts)
+}
+
+// filterChains performs the checks that apply to the chains built by both
+// the Go and the platform verifiers: certificate policies, revocation, and
+// opts.VerifyChain. It returns the chains that pass all of them or, if there
+// are none, the error of the last chain to fail.
+func filterChains(chains [][]*Certificate, opts *VerifyOptions) ([][]*Certificate, error) {
+	currentTime := opts.CurrentTime
+	if currentTime.IsZero() {
+		currentTime = time.Now()
+	}
+
+	var valid [][]*Certificate
+	var err error
+	for _, chain := range chains {
+		if err = checkChainForPolicies(chain, opts); err != nil {
+			continue
+		}
+		if len(opts.RevocationLists) > 0 {
+			if err = checkChainForRevocation(chain, opts.RevocationLists, currentTime); err != nil {
+				continue
+			}
+		}
+		if opts.VerifyChain != nil {
+			if err = opts.VerifyChain(chain); err != nil {
+				continue
+			}
+		}
+		valid = append(valid, chain)
+	}
+	if len(valid) == 0 {
+		return nil, err
+	}
+	return valid, nil
+}
+
+// reasonCertificateHold is the CRLReason of entries for certificates that
+// are temporarily suspended rather than revoked, see RFC 5280, Section 5.3.1.
+const reasonCertificateHold = 6
+
+// checkChainForRevocation checks every certificate in chain, except for the
+// root, against the CRLs in crls that were issued by its parent.
+func checkChainForRevocation(chain []*Certificate, crls []*RevocationList, currentTime time.Time) error {
+	for i := 0; i < len(chain)-1; i++ {
+		cert, parent := chain[i], chain[i+1]
+		for _, crl := range crls {
+			if !bytes.Equal(crl.RawIssuer, parent.RawSubject) || crl.hasUnhandledCriticalExtension() {
+				continue
+			}
+			if crl.CheckSignatureFrom(parent) != nil {
+				continue
+			}
+			for _, entry := range crl.RevokedCertificateEntries {
+				if entry.SerialNumber.Cmp(cert.SerialNumber) != 0 || entry.RevocationTime.After(currentTime) {
+					continue
+				}
+				if entry.ReasonCode == reasonCertificateHold && !crl.NextUpdate.IsZero() && currentTime.After(crl.NextUpdate) {
+					continue
+				}
+				return CertificateInvalidError{
+					Cert:   cert,
+					Reason: Revoked,
+					Detail: fmt.Sprintf("serial number %x was revoked at %s", cert.SerialNumber, entry.RevocationTime.Format(time.RFC3339)),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hasUnhandledCriticalExtension reports whether rl, or any of its entries,
+// contains a critical extension that is not understood by this package. Per
+// RFC 5280, Section 5.2, such a CRL must not be used to determine the status
+// of certificates.
+func (rl *RevocationList) hasUnhandledCriticalExtension() bool {
+	for _, ext := range rl.Extensions {
+		if ext.Critical && !ext.Id.Equal(oidExtensionAuthorityKeyId) && !ext.Id.Equal(oidExtensionCRLNumber) {
+			return true
+		}
+	}
+	for _, entry := range rl.RevokedCertificateEntries {
+		for _, ext := range entry.Extensions {
+			if ext.Critical && !ext.Id.Equal(oidExtensionReasonCode) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func appendToFreshChain(chain []*Certificate, cert *Certificate) []*Certificate {
@@ -1109,3 +1267,310 @@ NextCert:
 
 	return true
 }
+
+// anyPolicyOID is the special policy OID that matches any other policy, as
+// defined in RFC 5280, Section 4.2.1.4.
+var anyPolicyOID = asn1.ObjectIdentifier{2, 5, 29, 32, 0}
+
+// policyNode is a node of the valid_policy_graph of RFC 9618, which replaces
+// the valid_policy_tree of RFC 5280 to avoid its exponential growth. Each
+// depth of the graph holds at most one node per valid policy.
+type policyNode struct {
+	validPolicy       asn1.ObjectIdentifier
+	expectedPolicySet []asn1.ObjectIdentifier
+	// Policy qualifiers are not processed, so qualifier_set is not tracked.
+
+	parents  map[*policyNode]bool
+	children map[*policyNode]bool
+}
+
+// policyGraph is a valid_policy_graph, stored as a list of levels indexed by
+// depth. Each level maps the string form of a valid policy to its node.
+type policyGraph struct {
+	levels []map[string]*policyNode
+}
+
+func newPolicyGraph() *policyGraph {
+	root := &policyNode{
+		validPolicy:       anyPolicyOID,
+		expectedPolicySet: []asn1.ObjectIdentifier{anyPolicyOID},
+		parents:           map[*policyNode]bool{},
+		children:          map[*policyNode]bool{},
+	}
+	return &policyGraph{levels: []map[string]*policyNode{{anyPolicyOID.String(): root}}}
+}
+
+// leaves returns the nodes at the deepest level of the graph.
+func (g *policyGraph) leaves() map[string]*policyNode {
+	return g.levels[len(g.levels)-1]
+}
+
+// parents returns the nodes at the level above the leaves.
+func (g *policyGraph) parents() map[string]*policyNode {
+	return g.levels[len(g.levels)-2]
+}
+
+// addLeaf adds a node for policy, with the given expected policies, at the
+// deepest level of the graph and links it to parents.
+func (g *policyGraph) addLeaf(policy asn1.ObjectIdentifier, expected []asn1.ObjectIdentifier, parents []*policyNode) {
+	n := &policyNode{
+		validPolicy:       policy,
+		expectedPolicySet: expected,
+		parents:           map[*policyNode]bool{},
+		children:          map[*policyNode]bool{},
+	}
+	for _, p := range parents {
+		p.children[n] = true
+		n.parents[p] = true
+	}
+	g.leaves()[policy.String()] = n
+}
+
+// deleteLeaf removes the node for policy from the deepest level, if present.
+func (g *policyGraph) deleteLeaf(policy asn1.ObjectIdentifier) {
+	leaves := g.leaves()
+	n := leaves[policy.String()]
+	if n == nil {
+		return
+	}
+	for p := range n.parents {
+		delete(p.children, n)
+	}
+	delete(leaves, policy.String())
+}
+
+// prune removes the nodes above the leaves that no longer have children.
+// The root is never removed. If the graph is left without any leaf, prune
+// reports false, and the graph must be considered NULL.
+func (g *policyGraph) prune() bool {
+	for depth := len(g.levels) - 2; depth > 0; depth-- {
+		for key, n := range g.levels[depth] {
+			if len(n.children) == 0 {
+				for p := range n.parents {
+					delete(p.children, n)
+				}
+				delete(g.levels[depth], key)
+			}
+		}
+	}
+	return len(g.leaves()) > 0
+}
+
+// authorityConstrainedPolicies returns the policies of the nodes whose only
+// parent is an anyPolicy node, and anyPolicy itself if it is a leaf, which
+// is the authorities-constrained-policy-set of RFC 9618, Section 4.4.
+func (g *policyGraph) authorityConstrainedPolicies() map[string]bool {
+	policies := map[string]bool{}
+	for depth := len(g.levels) - 1; depth > 0; depth-- {
+		for key, n := range g.levels[depth] {
+			if n.validPolicy.Equal(anyPolicyOID) {
+				continue
+			}
+			for p := range n.parents {
+				if len(n.parents) == 1 && p.validPolicy.Equal(anyPolicyOID) {
+					policies[key] = true
+				}
+			}
+		}
+	}
+	if g.leaves()[anyPolicyOID.String()] != nil {
+		policies[anyPolicyOID.String()] = true
+	}
+	return policies
+}
+
+// checkChainForPolicies runs the certificate policy processing of RFC 5280,
+// Section 6.1, as updated by RFC 9618, over chain, which is ordered from the
+// leaf to the trust anchor. The trust anchor itself is not processed.
+func checkChainForPolicies(chain []*Certificate, opts *VerifyOptions) error {
+	// n is the length of the certification path, excluding the trust anchor.
+	n := len(chain) - 1
+	if n == 0 {
+		return nil
+	}
+
+	// 6.1.2 (d), (e) and (f)
+	explicitPolicy, policyMapping, inhibitAnyPolicy := n+1, n+1, n+1
+	if opts.RequireExplicitPolicy || len(opts.CertificatePolicies) > 0 {
+		explicitPolicy = 0
+	}
+	if opts.InhibitPolicyMapping {
+		policyMapping = 0
+	}
+	if opts.InhibitAnyPolicy {
+		inhibitAnyPolicy = 0
+	}
+
+	invalid := func(detail string) error {
+		return CertificateInvalidError{Cert: chain[0], Reason: NoValidPolicy, Detail: detail}
+	}
+
+	// A nil graph is the NULL valid_policy_graph of the specification.
+	graph := newPolicyGraph()
+	for i := n - 1; i >= 0; i-- {
+		cert := chain[i]
+		// Our chains go from the leaf to the trust anchor, so the certificate
+		// at index i is certificate n-i in the terms of the specification.
+		last := i == 0
+		selfIssued := bytes.Equal(cert.RawIssuer, cert.RawSubject)
+
+		// 6.1.3 (d)
+		if graph != nil && len(cert.PolicyIdentifiers) > 0 {
+			graph.levels = append(graph.levels, map[string]*policyNode{})
+
+			// 6.1.3 (d) (1)
+			parentsByExpected := map[string][]*policyNode{}
+			for _, p := range graph.parents() {
+				for _, expected := range p.expectedPolicySet {
+					parentsByExpected[expected.String()] = append(parentsByExpected[expected.String()], p)
+				}
+			}
+			anyParent := graph.parents()[anyPolicyOID.String()]
+			assertsAnyPolicy := false
+			for _, policy := range cert.PolicyIdentifiers {
+				if policy.Equal(anyPolicyOID) {
+					assertsAnyPolicy = true
+					continue
+				}
+				if parents := parentsByExpected[policy.String()]; len(parents) > 0 {
+					graph.addLeaf(policy, []asn1.ObjectIdentifier{policy}, parents)
+				} else if anyParent != nil {
+					graph.addLeaf(policy, []asn1.ObjectIdentifier{policy}, []*policyNode{anyParent})
+				}
+			}
+
+			// 6.1.3 (d) (2)
+			if assertsAnyPolicy && (inhibitAnyPolicy > 0 || (!last && selfIssued)) {
+				missing := map[string][]*policyNode{}
+				var missingOIDs []asn1.ObjectIdentifier
+				for _, p := range graph.parents() {
+					for _, expected := range p.expectedPolicySet {
+						key := expected.String()
+						if graph.leaves()[key] != nil {
+							continue
+						}
+						if missing[key] == nil {
+							missingOIDs = append(missingOIDs, expected)
+						}
+						missing[key] = append(missing[key], p)
+					}
+				}
+				for _, policy := range missingOIDs {
+					graph.addLeaf(policy, []asn1.ObjectIdentifier{policy}, missing[policy.String()])
+				}
+			}
+
+			// 6.1.3 (d) (3)
+			if !graph.prune() {
+				graph = nil
+			}
+		} else {
+			// 6.1.3 (e)
+			graph = nil
+		}
+
+		// 6.1.3 (f)
+		if explicitPolicy == 0 && graph == nil {
+			return invalid("certificate policies are required but missing or invalid")
+		}
+
+		if last {
+			break
+		}
+
+		// 6.1.4 (a) and (b)
+		if len(cert.PolicyMappings) > 0 {
+			var issuerPolicies []asn1.ObjectIdentifier
+			subjectPolicies := map[string][]asn1.ObjectIdentifier{}
+			for _, mapping := range cert.PolicyMappings {
+				if mapping.IssuerDomainPolicy.Equal(anyPolicyOID) || mapping.SubjectDomainPolicy.Equal(anyPolicyOID) {
+					return invalid("anyPolicy in policy mappings")
+				}
+				key := mapping.IssuerDomainPolicy.String()
+				if subjectPolicies[key] == nil {
+					issuerPolicies = append(issuerPolicies, mapping.IssuerDomainPolicy)
+				}
+				subjectPolicies[key] = append(subjectPolicies[key], mapping.SubjectDomainPolicy)
+			}
+
+			if graph != nil {
+				if policyMapping > 0 {
+					// 6.1.4 (b) (1) and (2)
+					anyLeaf := graph.leaves()[anyPolicyOID.String()]
+					for _, policy := range issuerPolicies {
+						if leaf := graph.leaves()[policy.String()]; leaf != nil {
+							leaf.expectedPolicySet = subjectPolicies[policy.String()]
+						} else if anyLeaf != nil {
+							anyParent := graph.parents()[anyPolicyOID.String()]
+							graph.addLeaf(policy, subjectPolicies[policy.String()], []*policyNode{anyParent})
+						}
+					}
+				} else {
+					// 6.1.4 (b) (3)
+					for _, policy := range issuerPolicies {
+						graph.deleteLeaf(policy)
+					}
+					if !graph.prune() {
+						graph = nil
+					}
+				}
+			}
+		}
+
+		// 6.1.4 (h)
+		if !selfIssued {
+			if explicitPolicy > 0 {
+				explicitPolicy--
+			}
+			if policyMapping > 0 {
+				policyMapping--
+			}
+			if inhibitAnyPolicy > 0 {
+				inhibitAnyPolicy--
+			}
+		}
+
+		// 6.1.4 (i)
+		if (cert.RequireExplicitPolicy > 0 || cert.RequireExplicitPolicyZero) && cert.RequireExplicitPolicy < explicitPolicy {
+			explicitPolicy = cert.RequireExplicitPolicy
+		}
+		if (cert.InhibitPolicyMapping > 0 || cert.InhibitPolicyMappingZero) && cert.InhibitPolicyMapping < policyMapping {
+			policyMapping = cert.InhibitPolicyMapping
+		}
+
+		// 6.1.4 (j)
+		if (cert.InhibitAnyPolicy > 0 || cert.InhibitAnyPolicyZero) && cert.InhibitAnyPolicy < inhibitAnyPolicy {
+			inhibitAnyPolicy = cert.InhibitAnyPolicy
+		}
+	}
+
+	// 6.1.5 (a) and (b)
+	if explicitPolicy > 0 {
+		explicitPolicy--
+	}
+	if chain[0].RequireExplicitPolicyZero {
+		explicitPolicy = 0
+	}
+
+	// 6.1.5 (g), as updated by RFC 9618, Section 4.4. The check can only
+	// fail if an explicit policy is required.
+	if explicitPolicy > 0 {
+		return nil
+	}
+	if graph == nil {
+		return invalid("certificate policies are required but missing or invalid")
+	}
+	authorityPolicies := graph.authorityConstrainedPolicies()
+	if len(authorityPolicies) > 0 {
+		if len(opts.CertificatePolicies) == 0 || authorityPolicies[anyPolicyOID.String()] ||
+			(len(opts.CertificatePolicies) == 1 && opts.CertificatePolicies[0].Equal(anyPolicyOID)) {
+			return nil
+		}
+		for _, policy := range opts.CertificatePolicies {
+			if authorityPolicies[policy.String()] {
+				return nil
+			}
+		}
+	}
+	return invalid("no acceptable certificate policy")
+}
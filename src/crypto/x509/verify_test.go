@@ -10,6 +10,7 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/pem"
 	"errors"
 	"fmt"
@@ -1839,6 +1840,456 @@ func TestLongChain(t *testing.T) {
 	t.Logf("verification took %v", time.Since(start))
 }
 
+func TestVerifyRevocationLists(t *testing.T) {
+	// generateCRLIssuer returns a CA certificate that, unlike the ones
+	// returned by generateCert, is allowed to sign CRLs.
+	generateCRLIssuer := func(cn string, issuer *Certificate, issuerKey crypto.PrivateKey) (*Certificate, crypto.PrivateKey) {
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &Certificate{
+			SerialNumber:          serialNumber,
+			Subject:               pkix.Name{CommonName: cn},
+			NotBefore:             time.Now().Add(-1 * time.Hour),
+			NotAfter:              time.Now().Add(24 * time.Hour),
+			KeyUsage:              KeyUsageDigitalSignature | KeyUsageCertSign | KeyUsageCRLSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+		if issuer == nil {
+			issuer = template
+			issuerKey = priv
+		}
+		der, err := CreateCertificate(rand.Reader, template, issuer, priv.Public(), issuerKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, priv
+	}
+
+	root, rootKey := generateCRLIssuer("Root CA", nil, nil)
+	intermediate, intermediateKey := generateCRLIssuer("Intermediate CA", root, rootKey)
+	leaf, _, err := generateCert("Leaf", false, intermediate, intermediateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// impostor has the same subject as the intermediate, but a different key.
+	impostor, impostorKey := generateCRLIssuer("Intermediate CA", root, rootKey)
+
+	now := time.Now()
+	// createCRL returns a CRL revoking revoked, if not nil, for keyCompromise.
+	// modify, if not nil, can change the template before it is signed.
+	createCRL := func(issuer *Certificate, issuerKey crypto.PrivateKey, revoked *Certificate, revokedAt time.Time, modify func(*RevocationList)) *RevocationList {
+		template := &RevocationList{
+			Number:     big.NewInt(1),
+			ThisUpdate: now.Add(-time.Hour),
+			NextUpdate: now.Add(time.Hour),
+		}
+		if revoked != nil {
+			template.RevokedCertificateEntries = []RevocationListEntry{{
+				SerialNumber:   revoked.SerialNumber,
+				RevocationTime: revokedAt,
+				ReasonCode:     1, // keyCompromise
+			}}
+		}
+		if modify != nil {
+			modify(template)
+		}
+		der, err := CreateRevocationList(rand.Reader, template, issuer, issuerKey.(crypto.Signer))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rl, err := ParseRevocationList(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rl
+	}
+	stale := func(rl *RevocationList) {
+		rl.ThisUpdate = now.Add(-3 * time.Hour)
+		rl.NextUpdate = now.Add(-2 * time.Hour)
+	}
+	hold := func(rl *RevocationList) {
+		rl.RevokedCertificateEntries[0].ReasonCode = reasonCertificateHold
+	}
+
+	tests := []struct {
+		name    string
+		crls    []*RevocationList
+		revoked *Certificate
+	}{
+		{
+			name: "NoCRLs",
+		},
+		{
+			name: "EmptyCRLs",
+			crls: []*RevocationList{
+				createCRL(root, rootKey, nil, time.Time{}, nil),
+				createCRL(intermediate, intermediateKey, nil, time.Time{}, nil),
+			},
+		},
+		{
+			name:    "RevokedLeaf",
+			crls:    []*RevocationList{createCRL(intermediate, intermediateKey, leaf, now.Add(-time.Minute), nil)},
+			revoked: leaf,
+		},
+		{
+			name:    "RevokedIntermediate",
+			crls:    []*RevocationList{createCRL(root, rootKey, intermediate, now.Add(-time.Minute), nil)},
+			revoked: intermediate,
+		},
+		{
+			name: "RevokedInTheFuture",
+			crls: []*RevocationList{createCRL(intermediate, intermediateKey, leaf, now.Add(time.Minute), nil)},
+		},
+		{
+			name:    "StaleCRL",
+			crls:    []*RevocationList{createCRL(intermediate, intermediateKey, leaf, now.Add(-4*time.Hour), stale)},
+			revoked: leaf,
+		},
+		{
+			name:    "OnHold",
+			crls:    []*RevocationList{createCRL(intermediate, intermediateKey, leaf, now.Add(-time.Minute), hold)},
+			revoked: leaf,
+		},
+		{
+			name: "OnHoldInStaleCRL",
+			crls: []*RevocationList{createCRL(intermediate, intermediateKey, leaf, now.Add(-4*time.Hour), func(rl *RevocationList) {
+				stale(rl)
+				hold(rl)
+			})},
+		},
+		{
+			name: "WrongIssuer",
+			crls: []*RevocationList{createCRL(root, rootKey, leaf, now.Add(-time.Minute), nil)},
+		},
+		{
+			name: "WrongSignature",
+			crls: []*RevocationList{createCRL(impostor, impostorKey, leaf, now.Add(-time.Minute), nil)},
+		},
+		{
+			name: "UnhandledCriticalExtension",
+			crls: []*RevocationList{createCRL(intermediate, intermediateKey, leaf, now.Add(-time.Minute), func(rl *RevocationList) {
+				rl.ExtraExtensions = []pkix.Extension{
+					{Id: []int{2, 5, 29, 28}, Critical: true, Value: []byte{0x30, 0x00}},
+				}
+			})},
+		},
+	}
+
+	roots := NewCertPool()
+	roots.AddCert(root)
+	intermediates := NewCertPool()
+	intermediates.AddCert(intermediate)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := leaf.Verify(VerifyOptions{
+				Roots:           roots,
+				Intermediates:   intermediates,
+				RevocationLists: test.crls,
+			})
+			if test.revoked == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var invalidErr CertificateInvalidError
+			if !errors.As(err, &invalidErr) || invalidErr.Reason != Revoked {
+				t.Fatalf("expected a Revoked CertificateInvalidError, got %v", err)
+			}
+			if invalidErr.Cert != test.revoked {
+				t.Errorf("error reports certificate %q, expected %q", invalidErr.Cert.Subject.CommonName, test.revoked.Subject.CommonName)
+			}
+		})
+	}
+}
+
+// generatePolicyCert is like generateCert, but lets the caller set the
+// certificate policy fields of the template.
+func generatePolicyCert(t *testing.T, cn string, isCA bool, issuer *Certificate, issuerKey crypto.PrivateKey, setPolicies func(*Certificate)) (*Certificate, crypto.PrivateKey) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-1 * time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              KeyUsageDigitalSignature | KeyUsageCertSign,
+		ExtKeyUsage:           []ExtKeyUsage{ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if setPolicies != nil {
+		setPolicies(template)
+	}
+	if issuer == nil {
+		issuer = template
+		issuerKey = priv
+	}
+	der, err := CreateCertificate(rand.Reader, template, issuer, priv.Public(), issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, priv
+}
+
+func TestVerifyPolicies(t *testing.T) {
+	policy1 := asn1.ObjectIdentifier{1, 2, 3, 1}
+	policy2 := asn1.ObjectIdentifier{1, 2, 3, 2}
+	policies := func(oids ...asn1.ObjectIdentifier) func(*Certificate) {
+		return func(c *Certificate) { c.PolicyIdentifiers = oids }
+	}
+
+	tests := []struct {
+		name         string
+		intermediate func(*Certificate)
+		leaf         func(*Certificate)
+		opts         VerifyOptions
+		expectErr    bool
+	}{
+		{
+			name: "NoPolicies",
+		},
+		{
+			name:      "NoPoliciesExplicitPolicy",
+			opts:      VerifyOptions{RequireExplicitPolicy: true},
+			expectErr: true,
+		},
+		{
+			name:      "NoPoliciesInitialPolicySet",
+			opts:      VerifyOptions{CertificatePolicies: []asn1.ObjectIdentifier{policy1}},
+			expectErr: true,
+		},
+		{
+			name:         "MatchingPolicy",
+			intermediate: policies(policy1),
+			leaf:         policies(policy1),
+			opts:         VerifyOptions{CertificatePolicies: []asn1.ObjectIdentifier{policy1}},
+		},
+		{
+			name:         "AnyInitialPolicy",
+			intermediate: policies(policy1),
+			leaf:         policies(policy1),
+			opts:         VerifyOptions{CertificatePolicies: []asn1.ObjectIdentifier{anyPolicyOID}},
+		},
+		{
+			name:         "UnacceptablePolicy",
+			intermediate: policies(policy1),
+			leaf:         policies(policy1),
+			opts:         VerifyOptions{CertificatePolicies: []asn1.ObjectIdentifier{policy2}},
+			expectErr:    true,
+		},
+		{
+			name:         "LeafPolicyNotAllowedByIntermediate",
+			intermediate: policies(policy1),
+			leaf:         policies(policy2),
+			opts:         VerifyOptions{RequireExplicitPolicy: true},
+			expectErr:    true,
+		},
+		{
+			name:         "LeafPolicyNotAllowedWithoutExplicitPolicy",
+			intermediate: policies(policy1),
+			leaf:         policies(policy2),
+		},
+		{
+			name:         "IntermediateAnyPolicy",
+			intermediate: policies(anyPolicyOID),
+			leaf:         policies(policy2),
+			opts:         VerifyOptions{CertificatePolicies: []asn1.ObjectIdentifier{policy2}},
+		},
+		{
+			name:         "IntermediateAnyPolicyInhibited",
+			intermediate: policies(anyPolicyOID),
+			leaf:         policies(policy2),
+			opts:         VerifyOptions{CertificatePolicies: []asn1.ObjectIdentifier{policy2}, InhibitAnyPolicy: true},
+			expectErr:    true,
+		},
+		{
+			name:         "LeafAnyPolicy",
+			intermediate: policies(anyPolicyOID),
+			leaf:         policies(anyPolicyOID),
+			opts:         VerifyOptions{RequireExplicitPolicy: true},
+		},
+		{
+			name: "LeafAnyPolicyInhibitedByIntermediate",
+			intermediate: func(c *Certificate) {
+				c.PolicyIdentifiers = []asn1.ObjectIdentifier{anyPolicyOID}
+				c.InhibitAnyPolicyZero = true
+			},
+			leaf:      policies(anyPolicyOID),
+			opts:      VerifyOptions{RequireExplicitPolicy: true},
+			expectErr: true,
+		},
+		{
+			name: "ExplicitPolicyRequiredByIntermediate",
+			intermediate: func(c *Certificate) {
+				c.RequireExplicitPolicyZero = true
+			},
+			expectErr: true,
+		},
+		{
+			name: "ExplicitPolicyRequiredByIntermediateAfterLeaf",
+			intermediate: func(c *Certificate) {
+				c.RequireExplicitPolicy = 2
+			},
+		},
+		{
+			name: "PolicyMapping",
+			intermediate: func(c *Certificate) {
+				c.PolicyIdentifiers = []asn1.ObjectIdentifier{policy1}
+				c.PolicyMappings = []PolicyMapping{{IssuerDomainPolicy: policy1, SubjectDomainPolicy: policy2}}
+			},
+			leaf: policies(policy2),
+			opts: VerifyOptions{CertificatePolicies: []asn1.ObjectIdentifier{policy1}},
+		},
+		{
+			name: "PolicyMappingFromAnyPolicy",
+			intermediate: func(c *Certificate) {
+				c.PolicyIdentifiers = []asn1.ObjectIdentifier{anyPolicyOID}
+				c.PolicyMappings = []PolicyMapping{{IssuerDomainPolicy: policy1, SubjectDomainPolicy: policy2}}
+			},
+			leaf: policies(policy2),
+			opts: VerifyOptions{CertificatePolicies: []asn1.ObjectIdentifier{policy1}},
+		},
+		{
+			name: "PolicyMappingSubjectDomain",
+			intermediate: func(c *Certificate) {
+				c.PolicyIdentifiers = []asn1.ObjectIdentifier{policy1}
+				c.PolicyMappings = []PolicyMapping{{IssuerDomainPolicy: policy1, SubjectDomainPolicy: policy2}}
+			},
+			leaf:      policies(policy2),
+			opts:      VerifyOptions{CertificatePolicies: []asn1.ObjectIdentifier{policy2}},
+			expectErr: true,
+		},
+		{
+			name: "PolicyMappingInhibited",
+			intermediate: func(c *Certificate) {
+				c.PolicyIdentifiers = []asn1.ObjectIdentifier{policy1}
+				c.PolicyMappings = []PolicyMapping{{IssuerDomainPolicy: policy1, SubjectDomainPolicy: policy2}}
+			},
+			leaf:      policies(policy2),
+			opts:      VerifyOptions{CertificatePolicies: []asn1.ObjectIdentifier{policy1}, InhibitPolicyMapping: true},
+			expectErr: true,
+		},
+		{
+			name: "PolicyMappingToAnyPolicy",
+			intermediate: func(c *Certificate) {
+				c.PolicyIdentifiers = []asn1.ObjectIdentifier{policy1}
+				c.PolicyMappings = []PolicyMapping{{IssuerDomainPolicy: policy1, SubjectDomainPolicy: anyPolicyOID}}
+			},
+			leaf:      policies(policy1),
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, rootKey := generatePolicyCert(t, "Root CA", true, nil, nil, nil)
+			intermediate, intermediateKey := generatePolicyCert(t, "Intermediate CA", true, root, rootKey, test.intermediate)
+			leaf, _ := generatePolicyCert(t, "Leaf", false, intermediate, intermediateKey, test.leaf)
+
+			opts := test.opts
+			opts.Roots = NewCertPool()
+			opts.Roots.AddCert(root)
+			opts.Intermediates = NewCertPool()
+			opts.Intermediates.AddCert(intermediate)
+			_, err := leaf.Verify(opts)
+			if !test.expectErr {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var invalidErr CertificateInvalidError
+			if !errors.As(err, &invalidErr) || invalidErr.Reason != NoValidPolicy {
+				t.Fatalf("expected a NoValidPolicy CertificateInvalidError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyChainCallback(t *testing.T) {
+	root1, root1Key, err := generateCert("Root CA 1", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root2, root2Key, err := generateCert("Root CA 2", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate, intermediateKey, err := generateCert("Intermediate CA", true, root1, root1Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// crossSigned has the same subject and key as intermediate, but is
+	// issued by root2, so that there are two candidate chains.
+	crossSignedDER, err := CreateCertificate(rand.Reader, intermediate, root2, intermediate.PublicKey, root2Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crossSigned, err := ParseCertificate(crossSignedDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, intermediate, intermediateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := VerifyOptions{
+		Roots:         NewCertPool(),
+		Intermediates: NewCertPool(),
+	}
+	opts.Roots.AddCert(root1)
+	opts.Roots.AddCert(root2)
+	opts.Intermediates.AddCert(intermediate)
+	opts.Intermediates.AddCert(crossSigned)
+
+	var seen []*Certificate
+	errRoot1 := errors.New("root 1 is not allowed")
+	opts.VerifyChain = func(chain []*Certificate) error {
+		root := chain[len(chain)-1]
+		seen = append(seen, root)
+		if root == root1 {
+			return errRoot1
+		}
+		return nil
+	}
+	chains, err := leaf.Verify(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(seen) != 2 {
+		t.Errorf("VerifyChain was called for %d chains, expected 2", len(seen))
+	}
+	if len(chains) != 1 || chains[0][len(chains[0])-1] != root2 {
+		t.Errorf("expected a single chain to Root CA 2, got %d chains", len(chains))
+	}
+
+	opts.VerifyChain = func(chain []*Certificate) error {
+		return errRoot1
+	}
+	if _, err := leaf.Verify(opts); err != errRoot1 {
+		t.Errorf("expected the VerifyChain error when all chains are rejected, got %v", err)
+	}
+}
+
 func TestSystemRootsError(t *testing.T) {
 	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
 		t.Skip("Windows and darwin do not use (or support) systemRoots")
@@ -8,6 +8,7 @@ package x509
 import (
 	"bytes"
 	"crypto"
+	"crypto/ecdh"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
@@ -48,8 +49,9 @@ type pkixPublicKey struct {
 // The encoded public key is a SubjectPublicKeyInfo structure
 // (see RFC 5280, Section 4.1).
 //
-// It returns a *rsa.PublicKey, *dsa.PublicKey, *ecdsa.PublicKey, or
-// ed25519.PublicKey. More types might be supported in the future.
+// It returns a *rsa.PublicKey, *dsa.PublicKey, *ecdsa.PublicKey,
+// ed25519.PublicKey (not a pointer), or *ecdh.PublicKey (for X25519).
+// More types might be supported in the future.
 //
 // This kind of key is commonly encoded in PEM blocks of type "PUBLIC KEY".
 func ParsePKIXPublicKey(derBytes []byte) (pub any, err error) {
@@ -63,7 +65,7 @@ func ParsePKIXPublicKey(derBytes []byte) (pub any, err error) {
 		return nil, errors.New("x509: trailing data after ASN.1 of public-key")
 	}
 	algo := getPublicKeyAlgorithmFromOID(pki.Algorithm.Algorithm)
-	if algo == UnknownPublicKeyAlgorithm {
+	if algo == UnknownPublicKeyAlgorithm && !pki.Algorithm.Algorithm.Equal(oidPublicKeyX25519) {
 		return nil, errors.New("x509: unknown public key algorithm")
 	}
 	return parsePublicKey(algo, &pki)
@@ -99,6 +101,23 @@ func marshalPublicKey(pub any) (publicKeyBytes []byte, publicKeyAlgorithm pkix.A
 	case ed25519.PublicKey:
 		publicKeyBytes = pub
 		publicKeyAlgorithm.Algorithm = oidPublicKeyEd25519
+	case *ecdh.PublicKey:
+		publicKeyBytes = pub.Bytes()
+		if pub.Curve() == ecdh.X25519() {
+			publicKeyAlgorithm.Algorithm = oidPublicKeyX25519
+		} else {
+			oid, ok := oidFromECDHCurve(pub.Curve())
+			if !ok {
+				return nil, pkix.AlgorithmIdentifier{}, errors.New("x509: unsupported elliptic curve")
+			}
+			publicKeyAlgorithm.Algorithm = oidPublicKeyECDSA
+			var paramBytes []byte
+			paramBytes, err = asn1.Marshal(oid)
+			if err != nil {
+				return
+			}
+			publicKeyAlgorithm.Parameters.FullBytes = paramBytes
+		}
 	default:
 		return nil, pkix.AlgorithmIdentifier{}, fmt.Errorf("x509: unsupported public key type: %T", pub)
 	}
@@ -110,8 +129,9 @@ func marshalPublicKey(pub any) (publicKeyBytes []byte, publicKeyAlgorithm pkix.A
 // The encoded public key is a SubjectPublicKeyInfo structure
 // (see RFC 5280, Section 4.1).
 //
-// The following key types are currently supported: *rsa.PublicKey, *ecdsa.PublicKey
-// and ed25519.PublicKey. Unsupported key types result in an error.
+// The following key types are currently supported: *rsa.PublicKey,
+// *ecdsa.PublicKey, ed25519.PublicKey (not a pointer), and *ecdh.PublicKey.
+// Unsupported key types result in an error.
 //
 // This kind of key is commonly encoded in PEM blocks of type "PUBLIC KEY".
 func MarshalPKIXPublicKey(pub any) ([]byte, error) {
@@ -446,10 +466,15 @@ func getSignatureAlgorithmFromAI(ai pkix.AlgorithmIdentifier) SignatureAlgorithm
 //
 // id-ecPublicKey OBJECT IDENTIFIER ::= {
 //       iso(1) member-body(2) us(840) ansi-X9-62(10045) keyType(2) 1 }
+//
+// RFC 8410, Section 3
+//
+// id-X25519    OBJECT IDENTIFIER ::= { 1 3 101 110 }
 var (
 	oidPublicKeyRSA     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
 	oidPublicKeyDSA     = asn1.ObjectIdentifier{1, 2, 840, 10040, 4, 1}
 	oidPublicKeyECDSA   = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidPublicKeyX25519  = asn1.ObjectIdentifier{1, 3, 101, 110}
 	oidPublicKeyEd25519 = oidSignatureEd25519
 )
 
@@ -519,6 +544,21 @@ func oidFromNamedCurve(curve elliptic.Curve) (asn1.ObjectIdentifier, bool) {
 	return nil, false
 }
 
+func oidFromECDHCurve(curve ecdh.Curve) (asn1.ObjectIdentifier, bool) {
+	switch curve {
+	case ecdh.X25519():
+		return oidPublicKeyX25519, true
+	case ecdh.P256():
+		return oidNamedCurveP256, true
+	case ecdh.P384():
+		return oidNamedCurveP384, true
+	case ecdh.P521():
+		return oidNamedCurveP521, true
+	}
+
+	return nil, false
+}
+
 // KeyUsage represents the set of actions that are valid for a given key. It's
 // a bitmap of the KeyUsage* constants.
 type KeyUsage int
@@ -724,6 +764,40 @@ type Certificate struct {
 	CRLDistributionPoints []string
 
 	PolicyIdentifiers []asn1.ObjectIdentifier
+
+	// PolicyMappings contains the policy mappings of the certificate, as
+	// defined in RFC 5280, Section 4.2.1.5.
+	PolicyMappings []PolicyMapping
+
+	// RequireExplicitPolicy and RequireExplicitPolicyZero indicate the
+	// presence and value of the requireExplicitPolicy field of the policy
+	// constraints extension (RFC 5280, Section 4.2.1.11), the number of
+	// additional certificates that may appear in the path before an
+	// explicit policy is required.
+	//
+	// InhibitPolicyMapping and InhibitPolicyMappingZero similarly describe
+	// the inhibitPolicyMapping field of the policy constraints extension,
+	// and InhibitAnyPolicy and InhibitAnyPolicyZero the inhibit anyPolicy
+	// extension (RFC 5280, Section 4.2.1.14).
+	//
+	// As with MaxPathLen, a positive non-zero value means that the field
+	// was specified and a zero value with the corresponding Zero field set
+	// means that it was explicitly set to zero. A zero value with the Zero
+	// field false, or a negative value, means that the field is unset.
+	RequireExplicitPolicy     int
+	RequireExplicitPolicyZero bool
+	InhibitPolicyMapping      int
+	InhibitPolicyMappingZero  bool
+	InhibitAnyPolicy          int
+	InhibitAnyPolicyZero      bool
+}
+
+// PolicyMapping represents an entry of the policy mappings extension, which
+// declares that IssuerDomainPolicy in the issuing CA's domain is considered
+// equivalent to SubjectDomainPolicy in the subject CA's domain.
+type PolicyMapping struct {
+	IssuerDomainPolicy  asn1.ObjectIdentifier
+	SubjectDomainPolicy asn1.ObjectIdentifier
 }
 
 // ErrUnsupportedAlgorithm results from attempting to perform an operation that
@@ -883,6 +957,8 @@ func checkSignature(algo SignatureAlgorithm, signed, signature []byte, publicKey
 }
 
 // CheckCRLSignature checks that the signature in crl is from c.
+//
+// Deprecated: Use RevocationList.CheckSignatureFrom instead.
 func (c *Certificate) CheckCRLSignature(crl *pkix.CertificateList) error {
 	algo := getSignatureAlgorithmFromAI(crl.SignatureAlgorithm)
 	return c.CheckSignature(algo, crl.TBSCertList.Raw, crl.SignatureValue.RightAlign())
@@ -967,8 +1043,12 @@ var (
 	oidExtensionCertificatePolicies   = []int{2, 5, 29, 32}
 	oidExtensionNameConstraints       = []int{2, 5, 29, 30}
 	oidExtensionCRLDistributionPoints = []int{2, 5, 29, 31}
+	oidExtensionPolicyMappings        = []int{2, 5, 29, 33}
+	oidExtensionPolicyConstraints     = []int{2, 5, 29, 36}
+	oidExtensionInhibitAnyPolicy      = []int{2, 5, 29, 54}
 	oidExtensionAuthorityInfoAccess   = []int{1, 3, 6, 1, 5, 5, 7, 1, 1}
 	oidExtensionCRLNumber             = []int{2, 5, 29, 20}
+	oidExtensionReasonCode            = []int{2, 5, 29, 21}
 )
 
 var (
@@ -1033,7 +1113,7 @@ func isIA5String(s string) error {
 }
 
 func buildCertExtensions(template *Certificate, subjectIsEmpty bool, authorityKeyId []byte, subjectKeyId []byte) (ret []pkix.Extension, err error) {
-	ret = make([]pkix.Extension, 10 /* maximum number of elements. */)
+	ret = make([]pkix.Extension, 13 /* maximum number of elements. */)
 	n := 0
 
 	if template.KeyUsage != 0 &&
@@ -1247,6 +1327,35 @@ func buildCertExtensions(template *Certificate, subjectIsEmpty bool, authorityKe
 		n++
 	}
 
+	if len(template.PolicyMappings) > 0 &&
+		!oidInExtensions(oidExtensionPolicyMappings, template.ExtraExtensions) {
+		ret[n], err = marshalPolicyMappings(template.PolicyMappings)
+		if err != nil {
+			return nil, err
+		}
+		n++
+	}
+
+	if (template.RequireExplicitPolicy > 0 || template.RequireExplicitPolicyZero ||
+		template.InhibitPolicyMapping > 0 || template.InhibitPolicyMappingZero) &&
+		!oidInExtensions(oidExtensionPolicyConstraints, template.ExtraExtensions) {
+		ret[n] = marshalPolicyConstraints(template)
+		n++
+	}
+
+	if (template.InhibitAnyPolicy > 0 || template.InhibitAnyPolicyZero) &&
+		!oidInExtensions(oidExtensionInhibitAnyPolicy, template.ExtraExtensions) {
+		ret[n].Id = oidExtensionInhibitAnyPolicy
+		// RFC 5280, Section 4.2.1.14: “Conforming CAs MUST mark this
+		// extension as critical.”
+		ret[n].Critical = true
+		ret[n].Value, err = asn1.Marshal(template.InhibitAnyPolicy)
+		if err != nil {
+			return
+		}
+		n++
+	}
+
 	// Adding another extension here? Remember to update the maximum number
 	// of elements in the make() at the top of the function and the list of
 	// template fields used in CreateCertificate documentation.
@@ -1327,6 +1436,39 @@ func marshalCertificatePolicies(policyIdentifiers []asn1.ObjectIdentifier) (pkix
 	return ext, nil
 }
 
+func marshalPolicyMappings(mappings []PolicyMapping) (pkix.Extension, error) {
+	ext := pkix.Extension{Id: oidExtensionPolicyMappings, Critical: true}
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		for _, mapping := range mappings {
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1ObjectIdentifier(mapping.IssuerDomainPolicy)
+				b.AddASN1ObjectIdentifier(mapping.SubjectDomainPolicy)
+			})
+		}
+	})
+	var err error
+	ext.Value, err = b.Bytes()
+	return ext, err
+}
+
+func marshalPolicyConstraints(template *Certificate) pkix.Extension {
+	// RFC 5280, Section 4.2.1.11: “Conforming CAs MUST mark this extension
+	// as critical.”
+	ext := pkix.Extension{Id: oidExtensionPolicyConstraints, Critical: true}
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		if template.RequireExplicitPolicy > 0 || template.RequireExplicitPolicyZero {
+			b.AddASN1Int64WithTag(int64(template.RequireExplicitPolicy), cryptobyte_asn1.Tag(0).ContextSpecific())
+		}
+		if template.InhibitPolicyMapping > 0 || template.InhibitPolicyMappingZero {
+			b.AddASN1Int64WithTag(int64(template.InhibitPolicyMapping), cryptobyte_asn1.Tag(1).ContextSpecific())
+		}
+	})
+	ext.Value = b.BytesOrPanic()
+	return ext
+}
+
 func buildCSRExtensions(template *CertificateRequest) ([]pkix.Extension, error) {
 	var ret []pkix.Extension
 
@@ -1446,6 +1588,10 @@ var emptyASN1Subject = []byte{0x30, 0}
 //  - ExtKeyUsage
 //  - ExtraExtensions
 //  - IPAddresses
+//  - InhibitAnyPolicy
+//  - InhibitAnyPolicyZero
+//  - InhibitPolicyMapping
+//  - InhibitPolicyMappingZero
 //  - IsCA
 //  - IssuingCertificateURL
 //  - KeyUsage
@@ -1460,6 +1606,9 @@ var emptyASN1Subject = []byte{0x30, 0}
 //  - PermittedIPRanges
 //  - PermittedURIDomains
 //  - PolicyIdentifiers
+//  - PolicyMappings
+//  - RequireExplicitPolicy
+//  - RequireExplicitPolicyZero
 //  - SerialNumber
 //  - SignatureAlgorithm
 //  - Subject
@@ -1622,6 +1771,8 @@ var pemType = "X509 CRL"
 // encoded CRLs will appear where they should be DER encoded, so this function
 // will transparently handle PEM encoding as long as there isn't any leading
 // garbage.
+//
+// Deprecated: Use ParseRevocationList instead.
 func ParseCRL(crlBytes []byte) (*pkix.CertificateList, error) {
 	if bytes.HasPrefix(crlBytes, pemCRLPrefix) {
 		block, _ := pem.Decode(crlBytes)
@@ -1633,6 +1784,8 @@ func ParseCRL(crlBytes []byte) (*pkix.CertificateList, error) {
 }
 
 // ParseDERCRL parses a DER encoded CRL from the given bytes.
+//
+// Deprecated: Use ParseRevocationList instead.
 func ParseDERCRL(derBytes []byte) (*pkix.CertificateList, error) {
 	certList := new(pkix.CertificateList)
 	if rest, err := asn1.Unmarshal(derBytes, certList); err != nil {
@@ -2085,23 +2238,91 @@ func (c *CertificateRequest) CheckSignature() error {
 	return checkSignature(c.SignatureAlgorithm, c.RawTBSCertificateRequest, c.Signature, c.PublicKey)
 }
 
-// RevocationList contains the fields used to create an X.509 v2 Certificate
-// Revocation list with CreateRevocationList.
+// RevocationListEntry represents an entry in the revokedCertificates
+// sequence of a CRL.
+type RevocationListEntry struct {
+	// Raw contains the raw bytes of the revokedCertificates entry. It is set
+	// when parsing a CRL; it is ignored when generating a CRL.
+	Raw []byte
+
+	// SerialNumber is the serial number of the revoked certificate. It is
+	// both used when creating a CRL and populated when parsing a CRL. It must
+	// not be nil.
+	SerialNumber *big.Int
+	// RevocationTime is the time at which the certificate was revoked. It is
+	// both used when creating a CRL and populated when parsing a CRL. It must
+	// not be the zero time.
+	RevocationTime time.Time
+	// ReasonCode is the reason for revocation, using the integer enum values
+	// specified in RFC 5280, Section 5.3.1. When creating a CRL, the zero
+	// value results in the reasonCode extension being omitted. When parsing
+	// a CRL, the zero value means either that the extension was absent,
+	// which implies the unspecified (0) reason, or that it explicitly
+	// contained the unspecified reason.
+	ReasonCode int
+
+	// Extensions contains raw X.509 extensions. When parsing CRL entries,
+	// this can be used to extract non-critical extensions that are not
+	// parsed by this package. When creating a CRL, the Extensions field is
+	// ignored, see ExtraExtensions.
+	Extensions []pkix.Extension
+	// ExtraExtensions contains extensions to be copied, raw, into the
+	// entry when creating a CRL. It must not contain a reasonCode
+	// extension, use ReasonCode instead. The ExtraExtensions field is not
+	// populated when parsing a CRL, see Extensions.
+	ExtraExtensions []pkix.Extension
+}
+
+// RevocationList represents a X.509 v2 Certificate Revocation List, as
+// specified by RFC 5280. It is used both to create a CRL with
+// CreateRevocationList and to hold the result of ParseRevocationList.
 type RevocationList struct {
+	// Raw contains the complete ASN.1 DER content of the CRL (tbsCertList,
+	// signatureAlgorithm, and signatureValue.)
+	Raw []byte
+	// RawTBSRevocationList contains just the tbsCertList portion of the ASN.1
+	// DER.
+	RawTBSRevocationList []byte
+	// RawIssuer contains the DER encoded Issuer.
+	RawIssuer []byte
+
+	// Issuer contains the DN of the issuing certificate. It is populated
+	// when parsing a CRL; it is ignored when creating a CRL, which uses the
+	// subject of the issuer certificate instead.
+	Issuer pkix.Name
+	// AuthorityKeyId is used to identify the public key associated with the
+	// issuing certificate. It is populated from the authorityKeyIdentifier
+	// extension when parsing a CRL. It is ignored when creating a CRL; the
+	// extension is populated from the issuing certificate itself.
+	AuthorityKeyId []byte
+
+	Signature []byte
 	// SignatureAlgorithm is used to determine the signature algorithm to be
 	// used when signing the CRL. If 0 the default algorithm for the signing
 	// key will be used.
 	SignatureAlgorithm SignatureAlgorithm
 
+	// RevokedCertificateEntries represents the revokedCertificates sequence
+	// in the CRL. It is used when creating a CRL and also populated when
+	// parsing a CRL. When creating a CRL, it may be empty or nil, in which
+	// case the revokedCertificates ASN.1 sequence will be omitted from the
+	// CRL entirely.
+	RevokedCertificateEntries []RevocationListEntry
+
 	// RevokedCertificates is used to populate the revokedCertificates
-	// sequence in the CRL, it may be empty. RevokedCertificates may be nil,
-	// in which case an empty CRL will be created.
+	// sequence in the CRL if RevokedCertificateEntries is empty. It may be
+	// empty or nil, in which case an empty CRL will be created. It is also
+	// populated when parsing a CRL, without reason codes.
+	//
+	// Deprecated: Use RevokedCertificateEntries instead.
 	RevokedCertificates []pkix.RevokedCertificate
 
 	// Number is used to populate the X.509 v2 cRLNumber extension in the CRL,
 	// which should be a monotonically increasing sequence number for a given
-	// CRL scope and CRL issuer.
+	// CRL scope and CRL issuer. It is also populated from the cRLNumber
+	// extension when parsing a CRL.
 	Number *big.Int
+
 	// ThisUpdate is used to populate the thisUpdate field in the CRL, which
 	// indicates the issuance date of the CRL.
 	ThisUpdate time.Time
@@ -2109,6 +2330,11 @@ type RevocationList struct {
 	// indicates the date by which the next CRL will be issued. NextUpdate
 	// must be greater than ThisUpdate.
 	NextUpdate time.Time
+
+	// Extensions contains raw X.509 extensions. When creating a CRL,
+	// the Extensions field is ignored, see ExtraExtensions.
+	Extensions []pkix.Extension
+
 	// ExtraExtensions contains any additional extensions to add directly to
 	// the CRL.
 	ExtraExtensions []pkix.Extension
@@ -2151,11 +2377,48 @@ func CreateRevocationList(rand io.Reader, template *RevocationList, issuer *Cert
 		return nil, err
 	}
 
-	// Force revocation times to UTC per RFC 5280.
-	revokedCertsUTC := make([]pkix.RevokedCertificate, len(template.RevokedCertificates))
-	for i, rc := range template.RevokedCertificates {
-		rc.RevocationTime = rc.RevocationTime.UTC()
-		revokedCertsUTC[i] = rc
+	var revokedCertsUTC []pkix.RevokedCertificate
+	if len(template.RevokedCertificateEntries) > 0 {
+		revokedCertsUTC = make([]pkix.RevokedCertificate, len(template.RevokedCertificateEntries))
+		for i, rce := range template.RevokedCertificateEntries {
+			if rce.SerialNumber == nil {
+				return nil, errors.New("x509: template contains entry with nil SerialNumber field")
+			}
+			if rce.RevocationTime.IsZero() {
+				return nil, errors.New("x509: template contains entry with zero RevocationTime field")
+			}
+			// Force revocation times to UTC per RFC 5280.
+			rc := pkix.RevokedCertificate{
+				SerialNumber:   rce.SerialNumber,
+				RevocationTime: rce.RevocationTime.UTC(),
+			}
+			for _, ext := range rce.ExtraExtensions {
+				if ext.Id.Equal(oidExtensionReasonCode) {
+					return nil, errors.New("x509: template contains entry with reasonCode extension, use ReasonCode instead")
+				}
+				rc.Extensions = append(rc.Extensions, ext)
+			}
+			// The reasonCode extension is omitted for the unspecified
+			// reason, per RFC 5280, Section 5.3.1.
+			if rce.ReasonCode != 0 {
+				reason, err := asn1.Marshal(asn1.Enumerated(rce.ReasonCode))
+				if err != nil {
+					return nil, err
+				}
+				rc.Extensions = append(rc.Extensions, pkix.Extension{
+					Id:    oidExtensionReasonCode,
+					Value: reason,
+				})
+			}
+			revokedCertsUTC[i] = rc
+		}
+	} else {
+		// Force revocation times to UTC per RFC 5280.
+		revokedCertsUTC = make([]pkix.RevokedCertificate, len(template.RevokedCertificates))
+		for i, rc := range template.RevokedCertificates {
+			rc.RevocationTime = rc.RevocationTime.UTC()
+			revokedCertsUTC[i] = rc
+		}
 	}
 
 	aki, err := asn1.Marshal(authKeyId{Id: issuer.SubjectKeyId})
@@ -2222,3 +2485,24 @@ func CreateRevocationList(rand io.Reader, template *RevocationList, issuer *Cert
 		SignatureValue:     asn1.BitString{Bytes: signature, BitLength: len(signature) * 8},
 	})
 }
+
+// CheckSignatureFrom verifies that the signature on rl is a valid signature
+// from parent.
+func (rl *RevocationList) CheckSignatureFrom(parent *Certificate) error {
+	if parent.Version == 3 && !parent.BasicConstraintsValid ||
+		parent.BasicConstraintsValid && !parent.IsCA {
+		return ConstraintViolationError{}
+	}
+
+	// RFC 5280, 4.2.1.3: the cRLSign bit must be asserted for the key
+	// to be used to verify signatures on CRLs.
+	if parent.KeyUsage != 0 && parent.KeyUsage&KeyUsageCRLSign == 0 {
+		return ConstraintViolationError{}
+	}
+
+	if parent.PublicKeyAlgorithm == UnknownPublicKeyAlgorithm {
+		return ErrUnsupportedAlgorithm
+	}
+
+	return parent.CheckSignature(rl.SignatureAlgorithm, rl.RawTBSRevocationList, rl.Signature)
+}
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/dsa"
+	"crypto/ecdh"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
@@ -101,6 +102,13 @@ func TestParsePKIXPublicKey(t *testing.T) {
 			t.Errorf("Value returned from ParsePKIXPublicKey was not an Ed25519 public key")
 		}
 	})
+	t.Run("X25519", func(t *testing.T) {
+		pub := testParsePKIXPublicKey(t, pemX25519Key)
+		k, ok := pub.(*ecdh.PublicKey)
+		if !ok || k.Curve() != ecdh.X25519() {
+			t.Errorf("Value returned from ParsePKIXPublicKey was not an X25519 public key")
+		}
+	})
 }
 
 var pemPublicKey = `-----BEGIN PUBLIC KEY-----
@@ -139,6 +147,80 @@ MCowBQYDK2VwAyEAGb9ECWmEzf6FQbrBZ9w7lshQhqowtrbLDFw4rXAxZuE=
 -----END PUBLIC KEY-----
 `
 
+// pemX25519Key is the public key of RFC 7748, Section 6.1, encoded according
+// to RFC 8410, Section 4.
+var pemX25519Key = `
+-----BEGIN PUBLIC KEY-----
+MCowBQYDK2VuAyEAhSDwCYkwp1R0i33ctD73Wg2/Og0mOBr066SpjqqbTmo=
+-----END PUBLIC KEY-----
+`
+
+func TestMarshalECDHPublicKey(t *testing.T) {
+	for _, curve := range []ecdh.Curve{ecdh.P256(), ecdh.P384(), ecdh.P521(), ecdh.X25519()} {
+		key, err := curve.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := MarshalPKIXPublicKey(key.PublicKey())
+		if err != nil {
+			t.Errorf("%s: failed to marshal public key: %s", curve, err)
+			continue
+		}
+		parsed, err := ParsePKIXPublicKey(der)
+		if err != nil {
+			t.Errorf("%s: failed to parse public key: %s", curve, err)
+			continue
+		}
+		// NIST curve keys are parsed as ECDSA keys.
+		if ecKey, ok := parsed.(*ecdsa.PublicKey); ok {
+			if parsed, err = ecKey.ECDH(); err != nil {
+				t.Errorf("%s: failed to convert ECDSA key: %s", curve, err)
+				continue
+			}
+		}
+		if !key.PublicKey().Equal(parsed) {
+			t.Errorf("%s: parsed public key doesn't match original: %T", curve, parsed)
+		}
+	}
+}
+
+func TestCreateCertificateX25519(t *testing.T) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "X25519"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     KeyUsageKeyAgreement,
+	}
+	issuer := &Certificate{
+		Subject:            pkix.Name{CommonName: "Issuer"},
+		PublicKey:          &testPrivateKey.PublicKey,
+		PublicKeyAlgorithm: RSA,
+	}
+	der, err := CreateCertificate(rand.Reader, template, issuer, key.PublicKey(), testPrivateKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %s", err)
+	}
+	cert, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %s", err)
+	}
+	if cert.PublicKeyAlgorithm != UnknownPublicKeyAlgorithm {
+		t.Errorf("unexpected public key algorithm: %v", cert.PublicKeyAlgorithm)
+	}
+	pub, ok := cert.PublicKey.(*ecdh.PublicKey)
+	if !ok {
+		t.Fatalf("certificate public key is %T, expected *ecdh.PublicKey", cert.PublicKey)
+	}
+	if !pub.Equal(key.PublicKey()) {
+		t.Error("parsed public key doesn't match original")
+	}
+}
+
 func TestPKIXMismatchPublicKeyFormat(t *testing.T) {
 
 	const pkcs1PublicKey = "308201080282010100817cfed98bcaa2e2a57087451c7674e0c675686dc33ff1268b0c2a6ee0202dec710858ee1c31bdf5e7783582e8ca800be45f3275c6576adc35d98e26e95bb88ca5beb186f853b8745d88bc9102c5f38753bcda519fb05948d5c77ac429255ff8aaf27d9f45d1586e95e2e9ba8a7cb771b8a09dd8c8fed3f933fd9b439bc9f30c475953418ef25f71a2b6496f53d94d39ce850aa0cc75d445b5f5b4f4ee4db78ab197a9a8d8a852f44529a007ac0ac23d895928d60ba538b16b0b087a7f903ed29770e215019b77eaecc360f35f7ab11b6d735978795b2c4a74e5bdea4dc6594cd67ed752a108e666729a753ab36d6c4f606f8760f507e1765be8cd744007e629020103"
@@ -2687,6 +2769,200 @@ func TestCreateRevocationList(t *testing.T) {
 	}
 }
 
+func TestParseRevocationList(t *testing.T) {
+	derBytes := fromBase64(derCRLBase64)
+	rl, err := ParseRevocationList(derBytes)
+	if err != nil {
+		t.Fatalf("error parsing: %s", err)
+	}
+	numCerts := len(rl.RevokedCertificateEntries)
+	expected := 88
+	if numCerts != expected {
+		t.Errorf("bad number of revoked certificates. got: %d want: %d", numCerts, expected)
+	}
+	if len(rl.RevokedCertificates) != numCerts {
+		t.Errorf("bad number of deprecated revoked certificates. got: %d want: %d", len(rl.RevokedCertificates), numCerts)
+	}
+	if rl.NextUpdate.IsZero() {
+		t.Errorf("NextUpdate is the zero value")
+	}
+
+	certList, err := ParseDERCRL(derBytes)
+	if err != nil {
+		t.Fatalf("error parsing: %s", err)
+	}
+	if !rl.ThisUpdate.Equal(certList.TBSCertList.ThisUpdate) {
+		t.Errorf("ThisUpdate mismatch: got %v, want %v", rl.ThisUpdate, certList.TBSCertList.ThisUpdate)
+	}
+	if !bytes.Equal(rl.RawTBSRevocationList, certList.TBSCertList.Raw) {
+		t.Errorf("RawTBSRevocationList mismatch")
+	}
+	for i, rc := range certList.TBSCertList.RevokedCertificates {
+		rce := rl.RevokedCertificateEntries[i]
+		if rce.SerialNumber.Cmp(rc.SerialNumber) != 0 || !rce.RevocationTime.Equal(rc.RevocationTime) {
+			t.Errorf("entry %d mismatch: got %v at %v, want %v at %v", i, rce.SerialNumber, rce.RevocationTime, rc.SerialNumber, rc.RevocationTime)
+		}
+	}
+
+	if _, err := ParseRevocationList(append(derBytes, 0)); err == nil {
+		t.Error("ParseRevocationList didn't fail with trailing data")
+	}
+}
+
+func TestParseRevocationListWithoutExpiry(t *testing.T) {
+	derBytes := fromBase64("MIHYMIGZMAkGByqGSM44BAMwEjEQMA4GA1UEAxMHQ2FybERTUxcNOTkwODI3MDcwMDAwWjBpMBMCAgDIFw05OTA4MjIwNzAwMDBaMBMCAgDJFw05OTA4MjIwNzAwMDBaMBMCAgDTFw05OTA4MjIwNzAwMDBaMBMCAgDSFw05OTA4MjIwNzAwMDBaMBMCAgDUFw05OTA4MjQwNzAwMDBaMAkGByqGSM44BAMDLwAwLAIUfmVSdjP+NHMX0feW+aDU2G1cfT0CFAJ6W7fVWxjBz4fvftok8yqDnDWh")
+	rl, err := ParseRevocationList(derBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rl.NextUpdate.IsZero() {
+		t.Errorf("NextUpdate is not the zero value")
+	}
+	if rl.Issuer.CommonName != "CarlDSS" {
+		t.Errorf("unexpected issuer: %v", rl.Issuer)
+	}
+	if len(rl.RevokedCertificateEntries) != 5 {
+		t.Errorf("bad number of revoked certificates. got: %d want: 5", len(rl.RevokedCertificateEntries))
+	}
+}
+
+func TestRevocationListRoundTrip(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "CRL issuer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              KeyUsageCertSign | KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		SubjectKeyId:          []byte{1, 2, 3},
+	}
+	caDER, err := CreateCertificate(rand.Reader, caTemplate, caTemplate, priv.Public(), priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extraExtension := pkix.Extension{Id: []int{2, 5, 29, 24}, Value: []byte{0x18, 0x0f, '2', '0', '2', '2', '0', '1', '0', '1', '0', '0', '0', '0', '0', '0', 'Z'}}
+	template := &RevocationList{
+		RevokedCertificateEntries: []RevocationListEntry{
+			{
+				SerialNumber:   big.NewInt(2),
+				RevocationTime: time.Date(2022, time.January, 2, 0, 0, 0, 0, time.UTC),
+			},
+			{
+				SerialNumber:    big.NewInt(3),
+				RevocationTime:  time.Date(2022, time.January, 3, 0, 0, 0, 0, time.UTC),
+				ReasonCode:      1, // keyCompromise
+				ExtraExtensions: []pkix.Extension{extraExtension},
+			},
+		},
+		Number:     big.NewInt(42),
+		ThisUpdate: time.Date(2022, time.January, 4, 0, 0, 0, 0, time.UTC),
+		NextUpdate: time.Date(2022, time.January, 5, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := CreateRevocationList(rand.Reader, template, ca, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl, err := ParseRevocationList(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(rl.Raw, der) {
+		t.Error("Raw doesn't match the generated CRL")
+	}
+	if !bytes.Equal(rl.RawIssuer, ca.RawSubject) {
+		t.Error("RawIssuer doesn't match the issuer subject")
+	}
+	if rl.Issuer.CommonName != "CRL issuer" {
+		t.Errorf("unexpected Issuer: %v", rl.Issuer)
+	}
+	if !bytes.Equal(rl.AuthorityKeyId, ca.SubjectKeyId) {
+		t.Errorf("AuthorityKeyId mismatch: got %x, want %x", rl.AuthorityKeyId, ca.SubjectKeyId)
+	}
+	if rl.SignatureAlgorithm != ECDSAWithSHA256 {
+		t.Errorf("unexpected SignatureAlgorithm: %v", rl.SignatureAlgorithm)
+	}
+	if rl.Number.Cmp(template.Number) != 0 {
+		t.Errorf("Number mismatch: got %v, want %v", rl.Number, template.Number)
+	}
+	if !rl.ThisUpdate.Equal(template.ThisUpdate) || !rl.NextUpdate.Equal(template.NextUpdate) {
+		t.Errorf("update times mismatch: got %v and %v", rl.ThisUpdate, rl.NextUpdate)
+	}
+	if len(rl.Extensions) != 2 {
+		t.Errorf("unexpected number of extensions: %d", len(rl.Extensions))
+	}
+	if len(rl.RevokedCertificateEntries) != len(template.RevokedCertificateEntries) {
+		t.Fatalf("unexpected number of entries: %d", len(rl.RevokedCertificateEntries))
+	}
+	for i, want := range template.RevokedCertificateEntries {
+		got := rl.RevokedCertificateEntries[i]
+		if got.SerialNumber.Cmp(want.SerialNumber) != 0 {
+			t.Errorf("entry %d: SerialNumber mismatch: got %v, want %v", i, got.SerialNumber, want.SerialNumber)
+		}
+		if !got.RevocationTime.Equal(want.RevocationTime) {
+			t.Errorf("entry %d: RevocationTime mismatch: got %v, want %v", i, got.RevocationTime, want.RevocationTime)
+		}
+		if got.ReasonCode != want.ReasonCode {
+			t.Errorf("entry %d: ReasonCode mismatch: got %d, want %d", i, got.ReasonCode, want.ReasonCode)
+		}
+		if len(got.Raw) == 0 {
+			t.Errorf("entry %d: Raw is empty", i)
+		}
+	}
+	if exts := rl.RevokedCertificateEntries[0].Extensions; len(exts) != 0 {
+		t.Errorf("unexpected extensions on entry without reason code: %v", exts)
+	}
+	if exts := rl.RevokedCertificateEntries[1].Extensions; len(exts) != 2 || !reflect.DeepEqual(exts[0], extraExtension) || !exts[1].Id.Equal(oidExtensionReasonCode) {
+		t.Errorf("unexpected entry extensions: %v", exts)
+	}
+
+	if err := rl.CheckSignatureFrom(ca); err != nil {
+		t.Errorf("CheckSignatureFrom failed: %s", err)
+	}
+
+	otherPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherDER, err := CreateCertificate(rand.Reader, caTemplate, caTemplate, otherPriv.Public(), otherPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ParseCertificate(otherDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rl.CheckSignatureFrom(other); err == nil {
+		t.Error("CheckSignatureFrom succeeded with the wrong issuer")
+	}
+
+	noCRLSign := *ca
+	noCRLSign.KeyUsage = KeyUsageCertSign
+	if err := rl.CheckSignatureFrom(&noCRLSign); err != (ConstraintViolationError{}) {
+		t.Errorf("CheckSignatureFrom with no crlSign key usage: got %v, want ConstraintViolationError", err)
+	}
+	notCA := *ca
+	notCA.IsCA = false
+	if err := rl.CheckSignatureFrom(&notCA); err != (ConstraintViolationError{}) {
+		t.Errorf("CheckSignatureFrom with non-CA issuer: got %v, want ConstraintViolationError", err)
+	}
+
+	template.RevokedCertificateEntries[0].ExtraExtensions = []pkix.Extension{{Id: oidExtensionReasonCode, Value: []byte{0x0a, 0x01, 0x01}}}
+	if _, err := CreateRevocationList(rand.Reader, template, ca, priv); err == nil {
+		t.Error("CreateRevocationList didn't fail with a reasonCode extra extension")
+	}
+}
+
 func TestRSAPSAParameters(t *testing.T) {
 	generateParams := func(hashFunc crypto.Hash) []byte {
 		var hashOID asn1.ObjectIdentifier
@@ -3348,3 +3624,57 @@ func TestLargeOID(t *testing.T) {
 		t.Fatalf("ParseCertificate to failed to parse certificate with large OID: %s", err)
 	}
 }
+
+func TestPolicyExtensionsRoundTrip(t *testing.T) {
+	mappings := []PolicyMapping{
+		{IssuerDomainPolicy: asn1.ObjectIdentifier{1, 2, 3}, SubjectDomainPolicy: asn1.ObjectIdentifier{1, 2, 4}},
+		{IssuerDomainPolicy: asn1.ObjectIdentifier{1, 2, 3}, SubjectDomainPolicy: asn1.ObjectIdentifier{1, 2, 5}},
+	}
+	for _, template := range []*Certificate{
+		{
+			PolicyMappings:        mappings,
+			RequireExplicitPolicy: 2,
+			InhibitPolicyMapping:  3,
+			InhibitAnyPolicy:      1,
+		},
+		{
+			RequireExplicitPolicyZero: true,
+			InhibitAnyPolicyZero:      true,
+		},
+		{
+			InhibitPolicyMappingZero: true,
+		},
+		{},
+	} {
+		template.SerialNumber = big.NewInt(1)
+		template.Subject = pkix.Name{CommonName: "Policy CA"}
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().Add(time.Hour)
+		template.BasicConstraintsValid = true
+		template.IsCA = true
+
+		der, err := CreateCertificate(rand.Reader, template, template, &testPrivateKey.PublicKey, testPrivateKey)
+		if err != nil {
+			t.Fatalf("CreateCertificate failed: %s", err)
+		}
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatalf("ParseCertificate failed: %s", err)
+		}
+		if len(cert.UnhandledCriticalExtensions) != 0 {
+			t.Errorf("unexpected unhandled critical extensions: %v", cert.UnhandledCriticalExtensions)
+		}
+		if !reflect.DeepEqual(cert.PolicyMappings, template.PolicyMappings) {
+			t.Errorf("PolicyMappings mismatch: got %v, want %v", cert.PolicyMappings, template.PolicyMappings)
+		}
+		if cert.RequireExplicitPolicy != template.RequireExplicitPolicy || cert.RequireExplicitPolicyZero != template.RequireExplicitPolicyZero {
+			t.Errorf("RequireExplicitPolicy mismatch: got %d/%t, want %d/%t", cert.RequireExplicitPolicy, cert.RequireExplicitPolicyZero, template.RequireExplicitPolicy, template.RequireExplicitPolicyZero)
+		}
+		if cert.InhibitPolicyMapping != template.InhibitPolicyMapping || cert.InhibitPolicyMappingZero != template.InhibitPolicyMappingZero {
+			t.Errorf("InhibitPolicyMapping mismatch: got %d/%t, want %d/%t", cert.InhibitPolicyMapping, cert.InhibitPolicyMappingZero, template.InhibitPolicyMapping, template.InhibitPolicyMappingZero)
+		}
+		if cert.InhibitAnyPolicy != template.InhibitAnyPolicy || cert.InhibitAnyPolicyZero != template.InhibitAnyPolicyZero {
+			t.Errorf("InhibitAnyPolicy mismatch: got %d/%t, want %d/%t", cert.InhibitAnyPolicy, cert.InhibitAnyPolicyZero, template.InhibitAnyPolicy, template.InhibitAnyPolicyZero)
+		}
+	}
+}
@@ -11,19 +11,32 @@
 //
 //	Unwrap() error
 //
-// If e.Unwrap() returns a non-nil error w, then we say that e wraps w.
+// or
 //
-// Unwrap unpacks wrapped errors. If its argument's type has an
-// Unwrap method, it calls the method once. Otherwise, it returns nil.
+//	Unwrap() []error
+//
+// If e.Unwrap() returns a non-nil error w or a slice containing w,
+// then we say that e wraps w. A nil error returned from e.Unwrap()
+// indicates that e does not wrap any error. It is invalid for an
+// Unwrap method to return an []error containing a nil error value.
+//
+// An easy way to create wrapped errors is to call fmt.Errorf and apply
+// the %w verb to the error argument:
 //
-// A simple way to create wrapped errors is to call fmt.Errorf and apply the %w verb
-// to the error argument:
+//	wrapsErr := fmt.Errorf("... %w ...", ..., err, ...)
 //
-//	errors.Unwrap(fmt.Errorf("... %w ...", ..., err, ...))
+// Successive unwrapping of an error creates a tree. The Is and As
+// functions inspect an error's tree by examining first the error
+// itself followed by the tree of each of its children in turn
+// (pre-order, depth-first traversal).
+//
+// Unwrap unpacks wrapped errors. If its argument's type has an
+// Unwrap method returning error, it calls the method once.
+// Otherwise, it returns nil.
 //
-// returns err.
+// Join combines several errors into one that wraps each of them.
 //
-// Is unwraps its first argument sequentially looking for an error that matches the
+// Is examines the tree of its first argument looking for an error that matches the
 // second. It reports whether it finds a match. It should be used in preference to
 // simple equality checks:
 //
@@ -35,7 +48,7 @@
 //
 // because the former will succeed if err wraps fs.ErrExist.
 //
-// As unwraps its first argument sequentially looking for an error that can be
+// As examines the tree of its first argument looking for an error that can be
 // assigned to its second argument, which must be a pointer. If it succeeds, it
 // performs the assignment and returns true. Otherwise, it returns false. The form
 //
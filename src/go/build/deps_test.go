@@ -70,11 +70,11 @@ import (
 var depsRules = `
 	# No dependencies allowed for any of these packages.
 	NONE
-	< constraints, container/list, container/ring,
+	< cmp, constraints, container/list, container/ring,
 	  internal/cfg, internal/cpu, internal/goarch,
 	  internal/goexperiment, internal/goos,
 	  internal/goversion, internal/nettrace,
-	  unicode/utf8, unicode/utf16, unicode,
+	  log/internal, maps, unicode/utf8, unicode/utf16, unicode,
 	  unsafe;
 
 	# These packages depend only on internal/goarch and unsafe.
@@ -104,6 +104,9 @@ var depsRules = `
 	< sort
 	< container/heap;
 
+	RUNTIME, cmp
+	< slices;
+
 	RUNTIME
 	< io;
 
@@ -217,10 +220,15 @@ var depsRules = `
 	  mime/quotedprintable,
 	  net/internal/socktest,
 	  net/url,
-	  runtime/trace,
 	  text/scanner,
 	  text/tabwriter;
 
+	io
+	< internal/flightrecorder;
+
+	FMT, internal/flightrecorder
+	< runtime/trace;
+
 	# encodings
 	# core ones do not use fmt.
 	io, strconv
@@ -245,9 +253,14 @@ var depsRules = `
 	FMT, encoding/binary, math/rand
 	< math/big;
 
+	# coverage data files
+	FMT, encoding/binary, hash/fnv
+	< internal/coverage
+	< internal/coverage/rtcov;
+
 	# compression
 	FMT, encoding/binary, hash/adler32, hash/crc32
-	< compress/bzip2, compress/flate, compress/lzw
+	< compress/bzip2, compress/flate, compress/lzw, compress/zstd
 	< archive/zip, compress/gzip, compress/zlib;
 
 	# templates
@@ -390,9 +403,19 @@ var depsRules = `
 	< NET;
 
 	# logging - most packages should not import; http and up is allowed
-	FMT
+	FMT, log/internal
 	< log;
 
+	log, log/slog !< time/tzdata;
+
+	FMT
+	< log/slog/internal/buffer;
+
+	log,
+	encoding/json,
+	log/slog/internal/buffer
+	< log/slog;
+
 	log !< crypto/tls, database/sql, go/importer, testing;
 
 	FMT, log, net
@@ -414,6 +437,7 @@ var depsRules = `
 	< crypto/cipher
 	< crypto/aes, crypto/des, crypto/hmac, crypto/md5, crypto/rc4,
 	  crypto/sha1, crypto/sha256, crypto/sha512
+	< golang.org/x/crypto/sha3
 	< CRYPTO;
 
 	CGO, fmt, net !< CRYPTO;
@@ -422,12 +446,14 @@ var depsRules = `
 	CRYPTO, FMT, math/big, embed
 	< crypto/rand
 	< crypto/internal/randutil
+	< crypto/internal/mlkem768
 	< crypto/ed25519
 	< encoding/asn1
 	< golang.org/x/crypto/cryptobyte/asn1
 	< golang.org/x/crypto/cryptobyte
 	< golang.org/x/crypto/curve25519
 	< crypto/dsa, crypto/elliptic, crypto/rsa
+	< crypto/ecdh
 	< crypto/ecdsa
 	< CRYPTO-MATH;
 
@@ -440,6 +466,7 @@ var depsRules = `
 	< golang.org/x/crypto/internal/poly1305
 	< golang.org/x/crypto/chacha20poly1305
 	< golang.org/x/crypto/hkdf
+	< crypto/internal/hpke
 	< crypto/x509/internal/macos
 	< crypto/x509/pkix
 	< crypto/x509
@@ -453,6 +480,9 @@ var depsRules = `
 	crypto/tls
 	< net/smtp;
 
+	crypto/tls
+	< internal/quic;
+
 	# HTTP, King of Dependencies.
 
 	FMT
@@ -474,6 +504,7 @@ var depsRules = `
 	golang.org/x/net/http/httpguts,
 	golang.org/x/net/http/httpproxy,
 	golang.org/x/net/http2/hpack,
+	internal/quic,
 	net/http/internal,
 	net/http/internal/ascii,
 	net/http/internal/testcert,
@@ -504,7 +535,7 @@ var depsRules = `
 	OS, compress/gzip, regexp
 	< internal/profile;
 
-	html, internal/profile, net/http, runtime/pprof, runtime/trace
+	html, internal/flightrecorder, internal/profile, net/http, runtime/pprof, runtime/trace
 	< net/http/pprof;
 
 	# RPC
@@ -549,7 +580,7 @@ var depsRules = `
 	syscall
 	< os/exec/internal/fdtest;
 
-	FMT, container/heap, math/rand
+	FMT, container/heap, math/rand, runtime/trace
 	< internal/trace;
 `
 
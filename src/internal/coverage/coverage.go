@@ -0,0 +1,316 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package coverage defines the format of the coverage data files
+// written by programs built with "go build -cover" and read by
+// "go tool covdata".
+//
+// A program writes two kinds of files into the GOCOVERDIR directory.
+// A meta-data file, named covmeta.<hash>, describes the instrumented
+// packages: their files and the position and statement count of each
+// basic block. Its hash identifies the program's coverage layout, so
+// that repeated runs of the same binary share a single meta-data file.
+// A counter data file, named covcounters.<hash>.<pid>.<nanotime>, holds
+// the block execution counts of one run and refers to the meta-data
+// file by hash.
+//
+// Both files start with a magic string followed by a sequence of
+// unsigned varints; strings are encoded as a varint length followed
+// by the bytes.
+package coverage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// Prefixes of the names of the files in a coverage data directory.
+const (
+	MetaFilePrefix    = "covmeta."
+	CounterFilePrefix = "covcounters."
+)
+
+const (
+	metaMagic    = "\x00gocovmeta1\n"
+	counterMagic = "\x00gocovcounters1\n"
+)
+
+// maxBlocks limits the number of blocks in a file.
+const maxBlocks = 1 << 24
+
+var errCorrupt = errors.New("coverage: corrupt data file")
+
+// A Block describes a basic block of a source file.
+type Block struct {
+	StartLine, StartCol uint32
+	EndLine, EndCol     uint32
+	NumStmt             uint32
+}
+
+// A File describes the blocks of one source file. Its name is the
+// name recorded in text coverage profiles: the import path of the
+// package followed by the base name of the file.
+type File struct {
+	Name   string
+	Blocks []Block
+}
+
+// A Package describes the instrumented files of one package.
+type Package struct {
+	Path  string
+	Files []File
+}
+
+// Meta describes the coverage layout of a program.
+type Meta struct {
+	Mode     string // "set", "count" or "atomic"
+	Packages []Package
+}
+
+// NumFiles returns the total number of files in m.
+func (m *Meta) NumFiles() int {
+	n := 0
+	for _, p := range m.Packages {
+		n += len(p.Files)
+	}
+	return n
+}
+
+// Encode returns the encoding of m.
+func (m *Meta) Encode() []byte {
+	var e encoder
+	e.b = append(e.b, metaMagic...)
+	e.string(m.Mode)
+	e.uvarint(uint64(len(m.Packages)))
+	for _, p := range m.Packages {
+		e.string(p.Path)
+		e.uvarint(uint64(len(p.Files)))
+		for _, f := range p.Files {
+			e.string(f.Name)
+			e.uvarint(uint64(len(f.Blocks)))
+			for _, b := range f.Blocks {
+				e.uvarint(uint64(b.StartLine))
+				e.uvarint(uint64(b.StartCol))
+				e.uvarint(uint64(b.EndLine))
+				e.uvarint(uint64(b.EndCol))
+				e.uvarint(uint64(b.NumStmt))
+			}
+		}
+	}
+	return e.b
+}
+
+// ParseMeta parses an encoded Meta.
+func ParseMeta(data []byte) (*Meta, error) {
+	if !strings.HasPrefix(string(data), metaMagic) {
+		return nil, errors.New("coverage: not a meta-data file")
+	}
+	d := decoder{b: data[len(metaMagic):]}
+	m := &Meta{Mode: d.string()}
+	m.Packages = make([]Package, d.count())
+	for i := range m.Packages {
+		p := &m.Packages[i]
+		p.Path = d.string()
+		p.Files = make([]File, d.count())
+		for j := range p.Files {
+			f := &p.Files[j]
+			f.Name = d.string()
+			f.Blocks = make([]Block, d.count())
+			for k := range f.Blocks {
+				f.Blocks[k] = Block{
+					StartLine: d.uint32(),
+					StartCol:  d.uint32(),
+					EndLine:   d.uint32(),
+					EndCol:    d.uint32(),
+					NumStmt:   d.uint32(),
+				}
+			}
+		}
+	}
+	if d.err != nil || len(d.b) != 0 {
+		return nil, errCorrupt
+	}
+	return m, nil
+}
+
+// Hash returns the hash identifying an encoded Meta, as used in file
+// names.
+func Hash(meta []byte) string {
+	h := fnv.New128a()
+	h.Write(meta)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// Counters holds the block execution counts of one run of a program.
+type Counters struct {
+	MetaHash string
+
+	// Counts holds the counts of each file, in the order the files
+	// appear in the Meta, with one count per block.
+	Counts [][]uint32
+}
+
+// Encode returns the encoding of c. Only non-zero counts are stored.
+func (c *Counters) Encode() []byte {
+	var e encoder
+	e.b = append(e.b, counterMagic...)
+	e.string(c.MetaHash)
+	e.uvarint(uint64(len(c.Counts)))
+	for _, counts := range c.Counts {
+		nonzero := 0
+		for _, n := range counts {
+			if n != 0 {
+				nonzero++
+			}
+		}
+		e.uvarint(uint64(len(counts)))
+		e.uvarint(uint64(nonzero))
+		last := 0
+		for i, n := range counts {
+			if n != 0 {
+				e.uvarint(uint64(i - last))
+				e.uvarint(uint64(n))
+				last = i
+			}
+		}
+	}
+	return e.b
+}
+
+// ParseCounters parses an encoded Counters.
+func ParseCounters(data []byte) (*Counters, error) {
+	if !strings.HasPrefix(string(data), counterMagic) {
+		return nil, errors.New("coverage: not a counter data file")
+	}
+	d := decoder{b: data[len(counterMagic):]}
+	c := &Counters{MetaHash: d.string()}
+	c.Counts = make([][]uint32, d.count())
+	for i := range c.Counts {
+		// Zero counts are not stored, so the number of blocks is
+		// not bounded by the size of the input.
+		nblocks := d.uvarint()
+		if nblocks > maxBlocks {
+			return nil, errCorrupt
+		}
+		counts := make([]uint32, nblocks)
+		nonzero := d.count()
+		last := 0
+		for j := 0; j < nonzero && d.err == nil; j++ {
+			delta := d.uvarint()
+			if delta >= uint64(len(counts)-last) {
+				return nil, errCorrupt
+			}
+			last += int(delta)
+			counts[last] = d.uint32()
+		}
+		c.Counts[i] = counts
+	}
+	if d.err != nil || len(d.b) != 0 {
+		return nil, errCorrupt
+	}
+	return c, nil
+}
+
+// Check reports an error if c does not match the layout described by m.
+func (c *Counters) Check(m *Meta) error {
+	if len(c.Counts) != m.NumFiles() {
+		return errors.New("coverage: counter data does not match meta-data")
+	}
+	i := 0
+	for _, p := range m.Packages {
+		for _, f := range p.Files {
+			if len(c.Counts[i]) != len(f.Blocks) {
+				return errors.New("coverage: counter data does not match meta-data")
+			}
+			i++
+		}
+	}
+	return nil
+}
+
+// CounterFileName returns the name of the counter data file written
+// by process pid at time nanotime for the meta-data with the given hash.
+func CounterFileName(hash string, pid int, nanotime int64) string {
+	return CounterFilePrefix + hash + "." + strconv.Itoa(pid) + "." + strconv.FormatInt(nanotime, 10)
+}
+
+// CounterFileHash returns the meta-data hash from the name of a
+// counter data file.
+func CounterFileHash(name string) (hash string, ok bool) {
+	if !strings.HasPrefix(name, CounterFilePrefix) {
+		return "", false
+	}
+	name = name[len(CounterFilePrefix):]
+	i := strings.Index(name, ".")
+	if i < 0 {
+		return "", false
+	}
+	return name[:i], true
+}
+
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) uvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	e.b = append(e.b, buf[:n]...)
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.b = append(e.b, s...)
+}
+
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = errCorrupt
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) uint32() uint32 {
+	v := d.uvarint()
+	if v > 1<<32-1 {
+		d.err = errCorrupt
+	}
+	return uint32(v)
+}
+
+// count decodes a length, which cannot exceed the remaining input
+// since every element takes at least one byte.
+func (d *decoder) count() int {
+	v := d.uvarint()
+	if v > uint64(len(d.b)) {
+		d.err = errCorrupt
+		return 0
+	}
+	return int(v)
+}
+
+func (d *decoder) string() string {
+	n := d.count()
+	if d.err != nil {
+		return ""
+	}
+	s := string(d.b[:n])
+	d.b = d.b[n:]
+	return s
+}
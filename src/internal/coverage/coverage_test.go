@@ -0,0 +1,102 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coverage
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testMeta = &Meta{
+	Mode: "count",
+	Packages: []Package{
+		{
+			Path: "example.com/a",
+			Files: []File{
+				{Name: "example.com/a/a.go", Blocks: []Block{
+					{StartLine: 3, StartCol: 14, EndLine: 5, EndCol: 2, NumStmt: 2},
+					{StartLine: 5, StartCol: 2, EndLine: 7, EndCol: 3, NumStmt: 1},
+				}},
+				{Name: "example.com/a/empty.go", Blocks: []Block{}},
+			},
+		},
+		{
+			Path: "example.com/b",
+			Files: []File{
+				{Name: "example.com/b/b.go", Blocks: []Block{
+					{StartLine: 10, StartCol: 1, EndLine: 1000, EndCol: 70000, NumStmt: 300},
+				}},
+			},
+		},
+	},
+}
+
+func TestMeta(t *testing.T) {
+	data := testMeta.Encode()
+	m, err := ParseMeta(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, testMeta) {
+		t.Errorf("ParseMeta(Encode(m)) = %+v, want %+v", m, testMeta)
+	}
+	if n := m.NumFiles(); n != 3 {
+		t.Errorf("NumFiles() = %d, want 3", n)
+	}
+
+	if h := Hash(data); len(h) != 32 || h == Hash(data[:len(data)-1]) {
+		t.Errorf("Hash(data) = %q, want 32 hex digits differing from other data", h)
+	}
+
+	for i := range data {
+		if _, err := ParseMeta(data[:i]); err == nil {
+			t.Errorf("ParseMeta succeeded on data truncated to %d bytes", i)
+		}
+	}
+}
+
+func TestCounters(t *testing.T) {
+	c := &Counters{
+		MetaHash: Hash(testMeta.Encode()),
+		Counts:   [][]uint32{{7, 0}, {}, {1<<32 - 1}},
+	}
+	if err := c.Check(testMeta); err != nil {
+		t.Fatal(err)
+	}
+	data := c.Encode()
+	c1, err := ParseCounters(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c1, c) {
+		t.Errorf("ParseCounters(Encode(c)) = %+v, want %+v", c1, c)
+	}
+	for i := range data {
+		if _, err := ParseCounters(data[:i]); err == nil {
+			t.Errorf("ParseCounters succeeded on data truncated to %d bytes", i)
+		}
+	}
+
+	bad := &Counters{MetaHash: c.MetaHash, Counts: [][]uint32{{7}, {}, {1}}}
+	if err := bad.Check(testMeta); err == nil {
+		t.Errorf("Check succeeded on mismatched counters")
+	}
+	if _, err := ParseCounters(testMeta.Encode()); err == nil {
+		t.Errorf("ParseCounters succeeded on meta-data")
+	}
+}
+
+func TestCounterFileName(t *testing.T) {
+	name := CounterFileName("0123abcd", 42, 1234567890)
+	if want := "covcounters.0123abcd.42.1234567890"; name != want {
+		t.Errorf("CounterFileName() = %q, want %q", name, want)
+	}
+	if hash, ok := CounterFileHash(name); !ok || hash != "0123abcd" {
+		t.Errorf("CounterFileHash(%q) = %q, %v, want %q, true", name, hash, ok, "0123abcd")
+	}
+	if _, ok := CounterFileHash(MetaFilePrefix + "0123abcd"); ok {
+		t.Errorf("CounterFileHash succeeded on meta-data file name")
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package rtcov collects the coverage counters of a program built with
+// "go build -cover" and writes them to the GOCOVERDIR directory when
+// the program exits.
+//
+// The go command generates a call to Register in the initialization
+// of each instrumented package.
+package rtcov
+
+import (
+	"internal/coverage"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+	_ "unsafe" // for go:linkname
+)
+
+// A File describes the coverage variables that cmd/cover generates
+// for one source file.
+type File struct {
+	Name    string   // file name as recorded in profiles
+	Counts  []uint32 // execution count of each block
+	Pos     []uint32 // start line, end line and packed columns of each block
+	NumStmt []uint16 // number of statements in each block
+}
+
+type pkg struct {
+	path  string
+	files []File
+}
+
+var state struct {
+	mu   sync.Mutex
+	mode string
+	pkgs []pkg
+}
+
+// Register records the coverage variables of an instrumented package.
+func Register(pkgPath, mode string, files []File) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.pkgs == nil {
+		runtime_addExitHook(emit, true)
+	}
+	state.mode = mode
+	state.pkgs = append(state.pkgs, pkg{pkgPath, files})
+}
+
+// emit writes the coverage data files.
+func emit() {
+	dir := os.Getenv("GOCOVERDIR")
+	if dir == "" {
+		os.Stderr.WriteString("warning: GOCOVERDIR not set, no coverage data emitted\n")
+		return
+	}
+	if err := writeFiles(dir); err != nil {
+		os.Stderr.WriteString("error: coverage data emit failed: " + err.Error() + "\n")
+	}
+}
+
+func writeFiles(dir string) error {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	m := &coverage.Meta{Mode: state.mode}
+	var counts [][]uint32
+	for _, p := range state.pkgs {
+		mp := coverage.Package{Path: p.path}
+		for _, f := range p.files {
+			mf := coverage.File{Name: f.Name, Blocks: make([]coverage.Block, len(f.Counts))}
+			c := make([]uint32, len(f.Counts))
+			for i := range f.Counts {
+				mf.Blocks[i] = coverage.Block{
+					StartLine: f.Pos[3*i],
+					StartCol:  f.Pos[3*i+2] & 0xFFFF,
+					EndLine:   f.Pos[3*i+1],
+					EndCol:    f.Pos[3*i+2] >> 16,
+					NumStmt:   uint32(f.NumStmt[i]),
+				}
+				c[i] = atomic.LoadUint32(&f.Counts[i])
+			}
+			mp.Files = append(mp.Files, mf)
+			counts = append(counts, c)
+		}
+		m.Packages = append(m.Packages, mp)
+	}
+
+	meta := m.Encode()
+	hash := coverage.Hash(meta)
+	metaFile := filepath.Join(dir, coverage.MetaFilePrefix+hash)
+	if _, err := os.Stat(metaFile); err != nil {
+		if err := writeFile(metaFile, meta); err != nil {
+			return err
+		}
+	}
+	c := &coverage.Counters{MetaHash: hash, Counts: counts}
+	name := coverage.CounterFileName(hash, os.Getpid(), time.Now().UnixNano())
+	return writeFile(filepath.Join(dir, name), c.Encode())
+}
+
+// writeFile writes a file by renaming a temporary file into place, so
+// that readers never see a partially written file.
+func writeFile(name string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), "tmp."+filepath.Base(name))
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func runtime_addExitHook(f func(), runOnNonZeroExit bool) // implemented in runtime
@@ -0,0 +1,33 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package flightrecorder provides a back-channel communication path
+// between package runtime/trace and package net/http/pprof, so that
+// the latter can write out the trace kept by a running flight recorder.
+package flightrecorder
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// active holds the running flight recorder, if any.
+var active atomic.Value
+
+// holder makes the stored values have the same concrete type,
+// as atomic.Value requires, even when the recorder is nil.
+type holder struct {
+	r io.WriterTo
+}
+
+// SetActive sets the running flight recorder, or clears it if r is nil.
+func SetActive(r io.WriterTo) {
+	active.Store(holder{r})
+}
+
+// Active returns the running flight recorder, or nil if there is none.
+func Active() io.WriterTo {
+	h, _ := active.Load().(holder)
+	return h.r
+}
@@ -11,7 +11,11 @@ import (
 	"go/parser"
 	"go/token"
 	"math"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -25,80 +29,183 @@ func marshalCorpusFile(vals ...any) []byte {
 		panic("must have at least one value to marshal")
 	}
 	b := bytes.NewBuffer([]byte(encVersion1 + "\n"))
+	for _, val := range vals {
+		marshalValue(b, val)
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// marshalValue writes the encoding of val to b. Values of primitive types
+// are encoded as conversions, such as int(1). Values of composite types are
+// encoded as composite literals, such as
+//
+//	p.Point{X: int(1), Y: int(2)}
+//
+// in which the types of nested composite literals are elided.
+func marshalValue(b *bytes.Buffer, val any) {
 	// TODO(katiehockman): keep uint8 and int32 encoding where applicable,
 	// instead of changing to byte and rune respectively.
-	for _, val := range vals {
-		switch t := val.(type) {
-		case int, int8, int16, int64, uint, uint16, uint32, uint64, bool:
-			fmt.Fprintf(b, "%T(%v)\n", t, t)
-		case float32:
-			if math.IsNaN(float64(t)) && math.Float32bits(t) != math.Float32bits(float32(math.NaN())) {
-				// We encode unusual NaNs as hex values, because that is how users are
-				// likely to encounter them in literature about floating-point encoding.
-				// This allows us to reproduce fuzz failures that depend on the specific
-				// NaN representation (for float32 there are about 2^24 possibilities!),
-				// not just the fact that the value is *a* NaN.
-				//
-				// Note that the specific value of float32(math.NaN()) can vary based on
-				// whether the architecture represents signaling NaNs using a low bit
-				// (as is common) or a high bit (as commonly implemented on MIPS
-				// hardware before around 2012). We believe that the increase in clarity
-				// from identifying "NaN" with math.NaN() is worth the slight ambiguity
-				// from a platform-dependent value.
-				fmt.Fprintf(b, "math.Float32frombits(0x%x)\n", math.Float32bits(t))
-			} else {
-				// We encode all other values — including the NaN value that is
-				// bitwise-identical to float32(math.Nan()) — using the default
-				// formatting, which is equivalent to strconv.FormatFloat with format
-				// 'g' and can be parsed by strconv.ParseFloat.
-				//
-				// For an ordinary floating-point number this format includes
-				// sufficiently many digits to reconstruct the exact value. For positive
-				// or negative infinity it is the string "+Inf" or "-Inf". For positive
-				// or negative zero it is "0" or "-0". For NaN, it is the string "NaN".
-				fmt.Fprintf(b, "%T(%v)\n", t, t)
-			}
-		case float64:
-			if math.IsNaN(t) && math.Float64bits(t) != math.Float64bits(math.NaN()) {
-				fmt.Fprintf(b, "math.Float64frombits(0x%x)\n", math.Float64bits(t))
-			} else {
-				fmt.Fprintf(b, "%T(%v)\n", t, t)
-			}
-		case string:
-			fmt.Fprintf(b, "string(%q)\n", t)
-		case rune: // int32
-			// Although rune and int32 are represented by the same type, only a subset
-			// of valid int32 values can be expressed as rune literals. Notably,
-			// negative numbers, surrogate halves, and values above unicode.MaxRune
-			// have no quoted representation.
+	switch t := val.(type) {
+	case int, int8, int16, int64, uint, uint16, uint32, uint64, bool:
+		fmt.Fprintf(b, "%T(%v)", t, t)
+	case float32:
+		if math.IsNaN(float64(t)) && math.Float32bits(t) != math.Float32bits(float32(math.NaN())) {
+			// We encode unusual NaNs as hex values, because that is how users are
+			// likely to encounter them in literature about floating-point encoding.
+			// This allows us to reproduce fuzz failures that depend on the specific
+			// NaN representation (for float32 there are about 2^24 possibilities!),
+			// not just the fact that the value is *a* NaN.
 			//
-			// fmt with "%q" (and the corresponding functions in the strconv package)
-			// would quote out-of-range values to the Unicode replacement character
-			// instead of the original value (see https://go.dev/issue/51526), so
-			// they must be treated as int32 instead.
+			// Note that the specific value of float32(math.NaN()) can vary based on
+			// whether the architecture represents signaling NaNs using a low bit
+			// (as is common) or a high bit (as commonly implemented on MIPS
+			// hardware before around 2012). We believe that the increase in clarity
+			// from identifying "NaN" with math.NaN() is worth the slight ambiguity
+			// from a platform-dependent value.
+			fmt.Fprintf(b, "math.Float32frombits(0x%x)", math.Float32bits(t))
+		} else {
+			// We encode all other values — including the NaN value that is
+			// bitwise-identical to float32(math.Nan()) — using the default
+			// formatting, which is equivalent to strconv.FormatFloat with format
+			// 'g' and can be parsed by strconv.ParseFloat.
 			//
-			// We arbitrarily draw the line at UTF-8 validity, which biases toward the
-			// "rune" interpretation. (However, we accept either format as input.)
-			if utf8.ValidRune(t) {
-				fmt.Fprintf(b, "rune(%q)\n", t)
-			} else {
-				fmt.Fprintf(b, "int32(%v)\n", t)
+			// For an ordinary floating-point number this format includes
+			// sufficiently many digits to reconstruct the exact value. For positive
+			// or negative infinity it is the string "+Inf" or "-Inf". For positive
+			// or negative zero it is "0" or "-0". For NaN, it is the string "NaN".
+			fmt.Fprintf(b, "%T(%v)", t, t)
+		}
+	case float64:
+		if math.IsNaN(t) && math.Float64bits(t) != math.Float64bits(math.NaN()) {
+			fmt.Fprintf(b, "math.Float64frombits(0x%x)", math.Float64bits(t))
+		} else {
+			fmt.Fprintf(b, "%T(%v)", t, t)
+		}
+	case string:
+		fmt.Fprintf(b, "string(%q)", t)
+	case rune: // int32
+		// Although rune and int32 are represented by the same type, only a subset
+		// of valid int32 values can be expressed as rune literals. Notably,
+		// negative numbers, surrogate halves, and values above unicode.MaxRune
+		// have no quoted representation.
+		//
+		// fmt with "%q" (and the corresponding functions in the strconv package)
+		// would quote out-of-range values to the Unicode replacement character
+		// instead of the original value (see https://go.dev/issue/51526), so
+		// they must be treated as int32 instead.
+		//
+		// We arbitrarily draw the line at UTF-8 validity, which biases toward the
+		// "rune" interpretation. (However, we accept either format as input.)
+		if utf8.ValidRune(t) {
+			fmt.Fprintf(b, "rune(%q)", t)
+		} else {
+			fmt.Fprintf(b, "int32(%v)", t)
+		}
+	case byte: // uint8
+		// For bytes, we arbitrarily prefer the character interpretation.
+		// (Every byte has a valid character encoding.)
+		fmt.Fprintf(b, "byte(%q)", t)
+	case []byte: // []uint8
+		fmt.Fprintf(b, "[]byte(%q)", t)
+	default:
+		marshalComposite(b, reflect.ValueOf(val), true)
+	}
+}
+
+// marshalComposite writes the encoding of v, a value of a supported
+// composite type, to b as a composite literal. The literal starts with
+// the type of v if withType is set.
+func marshalComposite(b *bytes.Buffer, v reflect.Value, withType bool) {
+	if !isSupportedType(v.Type()) {
+		panic(fmt.Sprintf("unsupported type: %v", v.Type()))
+	}
+	if withType {
+		b.WriteString(v.Type().String())
+	}
+	elem := func(v reflect.Value) { marshalElem(b, v) }
+	b.WriteByte('{')
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if i > 0 {
+				b.WriteString(", ")
 			}
-		case byte: // uint8
-			// For bytes, we arbitrarily prefer the character interpretation.
-			// (Every byte has a valid character encoding.)
-			fmt.Fprintf(b, "byte(%q)\n", t)
-		case []byte: // []uint8
-			fmt.Fprintf(b, "[]byte(%q)\n", t)
-		default:
-			panic(fmt.Sprintf("unsupported type: %T", t))
+			b.WriteString(v.Type().Field(i).Name)
+			b.WriteString(": ")
+			elem(v.Field(i))
+		}
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			elem(v.Index(i))
+		}
+	case reflect.Map:
+		// Write the entries in the order of their encoded keys, so that
+		// equal maps have equal encodings.
+		keys, elems := sortedMapEntries(v)
+		for i := range keys {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			elem(keys[i])
+			b.WriteString(": ")
+			elem(elems[i])
 		}
 	}
-	return b.Bytes()
+	b.WriteByte('}')
+}
+
+// marshalElem writes the encoding of v, an element of a composite value,
+// to b.
+func marshalElem(b *bytes.Buffer, v reflect.Value) {
+	if isPrimitiveType(v.Type()) {
+		marshalValue(b, v.Interface())
+	} else {
+		marshalComposite(b, v, false)
+	}
+}
+
+// sortedMapEntries returns the keys and elements of the map v, sorted by
+// the encodings of the keys and then of the elements. Iterating over the
+// entries, rather than indexing v by its keys, finds entries with NaN keys.
+func sortedMapEntries(v reflect.Value) (keys, elems []reflect.Value) {
+	type entry struct {
+		kenc, eenc string
+		k, e       reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	var b bytes.Buffer
+	iter := v.MapRange()
+	for iter.Next() {
+		k, e := iter.Key(), iter.Value()
+		b.Reset()
+		marshalElem(&b, k)
+		kenc := b.String()
+		b.Reset()
+		marshalElem(&b, e)
+		entries = append(entries, entry{kenc, b.String(), k, e})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].kenc != entries[j].kenc {
+			return entries[i].kenc < entries[j].kenc
+		}
+		return entries[i].eenc < entries[j].eenc
+	})
+	keys = make([]reflect.Value, len(entries))
+	elems = make([]reflect.Value, len(entries))
+	for i, e := range entries {
+		keys[i], elems[i] = e.k, e.e
+	}
+	return keys, elems
 }
 
 // unmarshalCorpusFile decodes corpus bytes into their respective values.
-func unmarshalCorpusFile(b []byte) ([]any, error) {
+// Values encoded as composite literals are decoded as values of the
+// corresponding types in types, which may be nil if there are none.
+func unmarshalCorpusFile(b []byte, types []reflect.Type) ([]any, error) {
 	if len(b) == 0 {
 		return nil, fmt.Errorf("cannot unmarshal empty string")
 	}
@@ -115,7 +222,11 @@ func unmarshalCorpusFile(b []byte) ([]any, error) {
 		if len(line) == 0 {
 			continue
 		}
-		v, err := parseCorpusValue(line)
+		var t reflect.Type
+		if len(vals) < len(types) {
+			t = types[len(vals)]
+		}
+		v, err := parseCorpusValue(line, t)
 		if err != nil {
 			return nil, fmt.Errorf("malformed line %q: %v", line, err)
 		}
@@ -124,12 +235,32 @@ func unmarshalCorpusFile(b []byte) ([]any, error) {
 	return vals, nil
 }
 
-func parseCorpusValue(line []byte) (any, error) {
+// parseCorpusValue decodes a value from line. If the value is encoded as
+// a composite literal, t is its expected type.
+func parseCorpusValue(line []byte, t reflect.Type) (any, error) {
 	fs := token.NewFileSet()
 	expr, err := parser.ParseExprFrom(fs, "(test)", line, 0)
 	if err != nil {
 		return nil, err
 	}
+	if lit, ok := expr.(*ast.CompositeLit); ok {
+		if t == nil || !isSupportedType(t) || isPrimitiveType(t) {
+			return nil, fmt.Errorf("unexpected composite literal")
+		}
+		if lit.Type == nil {
+			return nil, fmt.Errorf("missing type in composite literal")
+		}
+		v, err := parseComposite(fs, line, lit, t)
+		if err != nil {
+			return nil, err
+		}
+		return v.Interface(), nil
+	}
+	return parsePrimitive(expr)
+}
+
+// parsePrimitive decodes a value of a primitive type from expr.
+func parsePrimitive(expr ast.Expr) (any, error) {
 	call, ok := expr.(*ast.CallExpr)
 	if !ok {
 		return nil, fmt.Errorf("expected call expression")
@@ -309,6 +440,110 @@ func parseCorpusValue(line []byte) (any, error) {
 	}
 }
 
+// parseComposite decodes a value of the composite type t from lit,
+// a composite literal in line. The type of lit, if present, must be
+// spelled as t is by its String method, except for white space.
+func parseComposite(fs *token.FileSet, line []byte, lit *ast.CompositeLit, t reflect.Type) (reflect.Value, error) {
+	if lit.Type != nil {
+		start, end := fs.Position(lit.Type.Pos()).Offset, fs.Position(lit.Type.End()).Offset
+		if got := string(line[start:end]); removeSpace(got) != removeSpace(t.String()) {
+			return reflect.Value{}, fmt.Errorf("composite literal of type %s, want %v", got, t)
+		}
+	}
+	elem := func(expr ast.Expr, t reflect.Type) (reflect.Value, error) {
+		if lit, ok := expr.(*ast.CompositeLit); ok && !isPrimitiveType(t) {
+			return parseComposite(fs, line, lit, t)
+		}
+		if !isPrimitiveType(t) {
+			return reflect.Value{}, fmt.Errorf("composite literal required for type %v", t)
+		}
+		x, err := parsePrimitive(expr)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if v := reflect.ValueOf(x); v.Type() == t {
+			return v, nil
+		}
+		return reflect.Value{}, fmt.Errorf("value of type %T, want %v", x, t)
+	}
+
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Struct:
+		seen := make(map[string]bool)
+		for _, e := range lit.Elts {
+			kv, ok := e.(*ast.KeyValueExpr)
+			if !ok {
+				return reflect.Value{}, fmt.Errorf("field name required in struct literal")
+			}
+			id, ok := kv.Key.(*ast.Ident)
+			if !ok {
+				return reflect.Value{}, fmt.Errorf("invalid field name in struct literal")
+			}
+			f, ok := t.FieldByName(id.Name)
+			if !ok || len(f.Index) != 1 {
+				return reflect.Value{}, fmt.Errorf("unknown field %s in struct literal of type %v", id.Name, t)
+			}
+			if seen[id.Name] {
+				return reflect.Value{}, fmt.Errorf("duplicate field %s in struct literal", id.Name)
+			}
+			seen[id.Name] = true
+			fv, err := elem(kv.Value, f.Type)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.Field(f.Index[0]).Set(fv)
+		}
+	case reflect.Array, reflect.Slice:
+		if t.Kind() == reflect.Array && len(lit.Elts) > t.Len() {
+			return reflect.Value{}, fmt.Errorf("too many elements in literal of type %v", t)
+		}
+		if t.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(t, len(lit.Elts), len(lit.Elts)))
+		}
+		for i, e := range lit.Elts {
+			if _, ok := e.(*ast.KeyValueExpr); ok {
+				return reflect.Value{}, fmt.Errorf("unexpected index in literal of type %v", t)
+			}
+			ev, err := elem(e, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.Index(i).Set(ev)
+		}
+	case reflect.Map:
+		v.Set(reflect.MakeMapWithSize(t, len(lit.Elts)))
+		for _, e := range lit.Elts {
+			kv, ok := e.(*ast.KeyValueExpr)
+			if !ok {
+				return reflect.Value{}, fmt.Errorf("missing key in map literal")
+			}
+			k, err := elem(kv.Key, t.Key())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			ev, err := elem(kv.Value, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.SetMapIndex(k, ev)
+		}
+	default:
+		return reflect.Value{}, fmt.Errorf("unexpected composite literal of type %v", t)
+	}
+	return v, nil
+}
+
+// removeSpace returns s without its white space.
+func removeSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
 // parseInt returns an integer of value val and type typ.
 func parseInt(val, typ string) (any, error) {
 	switch typ {
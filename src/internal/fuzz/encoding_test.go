@@ -5,7 +5,9 @@
 package fuzz
 
 import (
+	"bytes"
 	"math"
+	"reflect"
 	"strconv"
 	"testing"
 	"unicode"
@@ -217,7 +219,7 @@ uint(18446744073709551615)`
 	}
 	for _, test := range tests {
 		t.Run(test.desc, func(t *testing.T) {
-			vals, err := unmarshalCorpusFile([]byte(test.in))
+			vals, err := unmarshalCorpusFile([]byte(test.in), nil)
 			if test.reject {
 				if err == nil {
 					t.Fatalf("unmarshal unexpected success")
@@ -248,6 +250,190 @@ uint(18446744073709551615)`
 	}
 }
 
+type testPoint struct {
+	X, Y int
+}
+
+type testShape struct {
+	Name   string
+	Points []testPoint
+	Tags   map[string]bool
+	Data   []byte
+	Pad    [2]uint16
+}
+
+func TestUnmarshalMarshalComposite(t *testing.T) {
+	var tests = []struct {
+		desc   string
+		types  []reflect.Type
+		in     string
+		reject bool
+		want   string // if different from in
+	}{
+		{
+			desc:  "struct",
+			types: []reflect.Type{reflect.TypeOf(testPoint{})},
+			in: `go test fuzz v1
+fuzz.testPoint{X: int(1), Y: int(-2)}`,
+		},
+		{
+			desc:  "missing fields",
+			types: []reflect.Type{reflect.TypeOf(testPoint{})},
+			in: `go test fuzz v1
+fuzz.testPoint{Y: int(2)}`,
+			want: `go test fuzz v1
+fuzz.testPoint{X: int(0), Y: int(2)}`,
+		},
+		{
+			desc:  "nested",
+			types: []reflect.Type{reflect.TypeOf(testShape{}), reflect.TypeOf(0)},
+			in: `go test fuzz v1
+fuzz.testShape{Name: string("a"), Points: {{X: int(1), Y: int(2)}, {X: int(3), Y: int(4)}}, Tags: {string("x"): bool(true), string("y"): bool(false)}, Data: []byte("\x00"), Pad: {uint16(1), uint16(2)}}
+int(5)`,
+		},
+		{
+			desc:  "map order",
+			types: []reflect.Type{reflect.TypeOf(map[int]string{})},
+			in: `go test fuzz v1
+map[int]string{int(2): string("b"), int(1): string("a")}`,
+			want: `go test fuzz v1
+map[int]string{int(1): string("a"), int(2): string("b")}`,
+		},
+		{
+			desc:  "anonymous struct and white space",
+			types: []reflect.Type{reflect.TypeOf([]struct{ A [1]int8 }{})},
+			in: `go test fuzz v1
+[]struct{A [1]int8}{{A: {int8(1)}}}`,
+			want: `go test fuzz v1
+[]struct { A [1]int8 }{{A: {int8(1)}}}`,
+		},
+		{
+			desc:  "empty slice",
+			types: []reflect.Type{reflect.TypeOf([]float64{})},
+			in: `go test fuzz v1
+[]float64{}`,
+		},
+		{
+			desc:  "missing types",
+			types: nil,
+			in: `go test fuzz v1
+fuzz.testPoint{X: int(1), Y: int(2)}`,
+			reject: true,
+		},
+		{
+			desc:  "wrong type",
+			types: []reflect.Type{reflect.TypeOf(testPoint{})},
+			in: `go test fuzz v1
+fuzz.testShape{Name: string("a")}`,
+			reject: true,
+		},
+		{
+			desc:  "missing type",
+			types: []reflect.Type{reflect.TypeOf(testPoint{})},
+			in: `go test fuzz v1
+{X: int(1)}`,
+			reject: true,
+		},
+		{
+			desc:  "unknown field",
+			types: []reflect.Type{reflect.TypeOf(testPoint{})},
+			in: `go test fuzz v1
+fuzz.testPoint{Z: int(1)}`,
+			reject: true,
+		},
+		{
+			desc:  "duplicate field",
+			types: []reflect.Type{reflect.TypeOf(testPoint{})},
+			in: `go test fuzz v1
+fuzz.testPoint{X: int(1), X: int(2)}`,
+			reject: true,
+		},
+		{
+			desc:  "wrong element type",
+			types: []reflect.Type{reflect.TypeOf(testPoint{})},
+			in: `go test fuzz v1
+fuzz.testPoint{X: int8(1)}`,
+			reject: true,
+		},
+		{
+			desc:  "untyped element",
+			types: []reflect.Type{reflect.TypeOf([]int{})},
+			in: `go test fuzz v1
+[]int{1}`,
+			reject: true,
+		},
+		{
+			desc:  "too many array elements",
+			types: []reflect.Type{reflect.TypeOf([1]int{})},
+			in: `go test fuzz v1
+[1]int{int(1), int(2)}`,
+			reject: true,
+		},
+		{
+			desc:  "missing map key",
+			types: []reflect.Type{reflect.TypeOf(map[int]int{})},
+			in: `go test fuzz v1
+map[int]int{int(1)}`,
+			reject: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			vals, err := unmarshalCorpusFile([]byte(test.in), test.types)
+			if test.reject {
+				if err == nil {
+					t.Fatalf("unmarshal unexpected success")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unmarshal unexpected error: %v", err)
+			}
+			if err := CheckCorpus(vals, test.types); err != nil {
+				t.Fatal(err)
+			}
+			want := test.want
+			if want == "" {
+				want = test.in
+			}
+			want += "\n"
+			if got := string(marshalCorpusFile(vals...)); got != want {
+				t.Errorf("unexpected marshaled value\ngot:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestCompositeRoundTrip(t *testing.T) {
+	vals := []any{
+		testShape{
+			Name:   "\u2318",
+			Points: []testPoint{{1, 2}, {}},
+			Tags:   map[string]bool{"a": true, "": false},
+			Data:   []byte{0, 1, 255},
+			Pad:    [2]uint16{7, 65535},
+		},
+		map[[2]bool][]float32{{true, false}: {float32(math.Inf(-1)), 0.5}},
+		[][]byte{{}, []byte("x")},
+		map[float64]int{math.NaN(): 1, 0: 2},
+	}
+	var types []reflect.Type
+	for _, v := range vals {
+		types = append(types, reflect.TypeOf(v))
+	}
+	b := marshalCorpusFile(vals...)
+	got, err := unmarshalCorpusFile(b, types)
+	if err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, b)
+	}
+	if b2 := marshalCorpusFile(got...); !bytes.Equal(b, b2) {
+		t.Fatalf("values changed after round trip:\n%s\n%s", b, b2)
+	}
+	if !reflect.DeepEqual(got[:3], vals[:3]) {
+		t.Errorf("unmarshaled %#v, want %#v", got[:3], vals[:3])
+	}
+}
+
 // BenchmarkMarshalCorpusFile measures the time it takes to serialize byte
 // slices of various sizes to a corpus file. The slice contains a repeating
 // sequence of bytes 0-255 to mix escaped and non-escaped characters.
@@ -283,7 +469,7 @@ func BenchmarkUnmarshalCorpusFile(b *testing.B) {
 		b.Run(strconv.Itoa(sz), func(b *testing.B) {
 			for i := 0; i < b.N; i++ {
 				b.SetBytes(int64(sz))
-				unmarshalCorpusFile(data)
+				unmarshalCorpusFile(data, nil)
 			}
 		})
 	}
@@ -293,7 +479,7 @@ func TestByteRoundTrip(t *testing.T) {
 	for x := 0; x < 256; x++ {
 		b1 := byte(x)
 		buf := marshalCorpusFile(b1)
-		vs, err := unmarshalCorpusFile(buf)
+		vs, err := unmarshalCorpusFile(buf, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -308,7 +494,7 @@ func TestInt8RoundTrip(t *testing.T) {
 	for x := -128; x < 128; x++ {
 		i1 := int8(x)
 		buf := marshalCorpusFile(i1)
-		vs, err := unmarshalCorpusFile(buf)
+		vs, err := unmarshalCorpusFile(buf, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -335,7 +521,7 @@ func FuzzFloat64RoundTrip(f *testing.F) {
 		b := marshalCorpusFile(x1)
 		t.Logf("marshaled math.Float64frombits(0x%x):\n%s", u1, b)
 
-		xs, err := unmarshalCorpusFile(b)
+		xs, err := unmarshalCorpusFile(b, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -366,7 +552,7 @@ func FuzzRuneRoundTrip(f *testing.F) {
 		b := marshalCorpusFile(r1)
 		t.Logf("marshaled rune(0x%x):\n%s", r1, b)
 
-		rs, err := unmarshalCorpusFile(b)
+		rs, err := unmarshalCorpusFile(b, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -389,7 +575,7 @@ func FuzzStringRoundTrip(f *testing.F) {
 		b := marshalCorpusFile(s1)
 		t.Logf("marshaled %q:\n%s", s1, b)
 
-		rs, err := unmarshalCorpusFile(b)
+		rs, err := unmarshalCorpusFile(b, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
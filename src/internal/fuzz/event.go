@@ -0,0 +1,46 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+)
+
+// An event describes a fuzzing event for cmd/test2json, which converts it
+// to a JSON test event with the "fuzz" action. See the cmd/test2json
+// documentation for the meaning of the fields.
+type event struct {
+	Kind    string  // "baseline", "new", "minimize", "crash", or "stats"
+	Elapsed float64 // seconds since fuzzing started
+	Execs   int64   `json:",omitempty"`
+	New     int     `json:",omitempty"`
+	Corpus  int     `json:",omitempty"`
+	Workers int     `json:",omitempty"`
+	Input   string  `json:",omitempty"`
+	Size    int     `json:",omitempty"`
+}
+
+// logEvent logs e, if requested by c.opts.JSON, as a line of the form
+//
+//	=== FUZZ  FuzzTarget {"Kind":"new",...}
+//
+// which cmd/test2json recognizes. The elapsed time and the execution,
+// new input, and corpus counts are filled in from the state of c.
+func (c *coordinator) logEvent(e event) {
+	if !c.opts.JSON {
+		return
+	}
+	e.Elapsed = c.elapsed().Seconds()
+	e.Execs = c.count
+	e.New = c.interestingCount
+	e.Corpus = c.warmupInputCount + c.interestingCount
+	data, err := json.Marshal(e)
+	if err != nil {
+		panic(err) // unreachable: events are always valid
+	}
+	fmt.Fprintf(c.opts.Log, "=== FUZZ  %s %s\n", filepath.Base(c.opts.CorpusDir), data)
+}
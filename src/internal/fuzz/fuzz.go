@@ -72,6 +72,10 @@ type CoordinateFuzzingOpts struct {
 	// CacheDir is a directory containing additional "interesting" values.
 	// The fuzzer may derive new values from these, and may write new values here.
 	CacheDir string
+
+	// JSON, if true, causes machine-readable events to be logged along with
+	// the progress messages, in a form recognized by cmd/test2json.
+	JSON bool
 }
 
 // CoordinateFuzzing creates several worker processes and communicates with
@@ -147,6 +151,7 @@ func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err err
 			err = fmt.Errorf("%w\n%v", err, werr)
 			return
 		}
+		c.logEvent(event{Kind: "crash", Input: c.crashMinimizing.entry.Path, Size: len(c.crashMinimizing.entry.Data)})
 		if err == nil {
 			err = &crashError{
 				path: c.crashMinimizing.entry.Path,
@@ -233,6 +238,7 @@ func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err err
 				if c.warmupRun() && result.entry.IsSeed {
 					target := filepath.Base(c.opts.CorpusDir)
 					fmt.Fprintf(c.opts.Log, "failure while testing seed corpus entry: %s/%s\n", target, testName(result.entry.Parent))
+					c.logEvent(event{Kind: "crash", Input: target + "/" + testName(result.entry.Parent)})
 					stop(errors.New(result.crasherMsg))
 					break
 				}
@@ -247,6 +253,7 @@ func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err err
 					// other workers don't continue fuzzing.
 					c.crashMinimizing = &result
 					fmt.Fprintf(c.opts.Log, "fuzz: minimizing %d-byte failing input file\n", len(result.entry.Data))
+					c.logEvent(event{Kind: "minimize", Size: len(result.entry.Data)})
 					c.queueForMinimization(result, nil)
 				} else if !crashWritten {
 					// Found a crasher that's either minimized or not minimizable.
@@ -254,6 +261,7 @@ func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err err
 					err := writeToCorpus(&result.entry, opts.CorpusDir)
 					if err == nil {
 						crashWritten = true
+						c.logEvent(event{Kind: "crash", Input: result.entry.Path, Size: len(result.entry.Data)})
 						err = &crashError{
 							path: result.entry.Path,
 							err:  errors.New(result.crasherMsg),
@@ -290,6 +298,7 @@ func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err err
 					c.warmupInputLeft--
 					if c.warmupInputLeft == 0 {
 						fmt.Fprintf(c.opts.Log, "fuzz: elapsed: %s, gathering baseline coverage: %d/%d completed, now fuzzing with %d workers\n", c.elapsed(), c.warmupInputCount, c.warmupInputCount, c.opts.Parallel)
+						c.logEvent(event{Kind: "baseline", Workers: c.opts.Parallel})
 						if shouldPrintDebugInfo() {
 							fmt.Fprintf(
 								c.opts.Log,
@@ -327,6 +336,7 @@ func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err err
 						c.updateCoverage(keepCoverage)
 						c.inputQueue.enqueue(result.entry)
 						c.interestingCount++
+						c.logEvent(event{Kind: "new", Input: c.corpus.entries[len(c.corpus.entries)-1].Path, Size: inputSize})
 						if shouldPrintDebugInfo() {
 							fmt.Fprintf(
 								c.opts.Log,
@@ -360,6 +370,7 @@ func CoordinateFuzzing(ctx context.Context, opts CoordinateFuzzingOpts) (err err
 				c.warmupInputLeft--
 				if c.warmupInputLeft == 0 {
 					fmt.Fprintf(c.opts.Log, "fuzz: elapsed: %s, testing seed corpus: %d/%d completed, now fuzzing with %d workers\n", c.elapsed(), c.warmupInputCount, c.warmupInputCount, c.opts.Parallel)
+					c.logEvent(event{Kind: "baseline", Workers: c.opts.Parallel})
 					if shouldPrintDebugInfo() {
 						fmt.Fprintf(
 							c.opts.Log,
@@ -737,6 +748,7 @@ func (c *coordinator) logStats() {
 			fmt.Fprintf(c.opts.Log, "fuzz: elapsed: %s, execs: %d (%.0f/sec)\n", c.elapsed(), c.count, rate)
 		}
 	}
+	c.logEvent(event{Kind: "stats"})
 	c.countLastLog = c.count
 	c.timeLastLog = now
 }
@@ -1000,7 +1012,7 @@ func ReadCorpus(dir string, types []reflect.Type) ([]CorpusEntry, error) {
 }
 
 func readCorpusData(data []byte, types []reflect.Type) ([]any, error) {
-	vals, err := unmarshalCorpusFile(data)
+	vals, err := unmarshalCorpusFile(data, types)
 	if err != nil {
 		return nil, fmt.Errorf("unmarshal: %v", err)
 	}
@@ -1055,6 +1067,9 @@ func zeroValue(t reflect.Type) any {
 			return v
 		}
 	}
+	if isSupportedType(t) {
+		return reflect.Zero(t).Interface()
+	}
 	panic(fmt.Sprintf("unsupported type: %v", t))
 }
 
@@ -5,6 +5,7 @@
 package fuzz
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"math"
@@ -66,35 +67,60 @@ func (m *mutator) mutate(vals []any, maxBytes int) {
 	// Pick a random value to mutate.
 	// TODO: consider mutating more than one value at a time.
 	i := m.rand(len(vals))
-	switch v := vals[i].(type) {
+	if isPrimitiveType(reflect.TypeOf(vals[i])) {
+		vals[i] = m.mutatePrimitive(vals[i], maxPerVal)
+		return
+	}
+
+	// Mutate a copy of a composite value, which shares no memory with the
+	// original, so that the original can be restored. If the mutation makes
+	// the encoded value too large, try again, or else leave it unchanged.
+	for try := 0; try < 10; try++ {
+		v := deepCopy(reflect.ValueOf(vals[i]))
+		m.mutateValue(v, maxPerVal)
+		var b bytes.Buffer
+		marshalComposite(&b, v, true)
+		if b.Len() <= maxPerVal {
+			vals[i] = v.Interface()
+			return
+		}
+	}
+}
+
+// mutatePrimitive returns the result of mutating v, a value of a primitive
+// type. maxPerVal is the maximum length of the result, for []byte and string
+// values. The result may share memory with m.scratch.
+func (m *mutator) mutatePrimitive(v any, maxPerVal int) any {
+	switch v := v.(type) {
 	case int:
-		vals[i] = int(m.mutateInt(int64(v), maxInt))
+		return int(m.mutateInt(int64(v), maxInt))
 	case int8:
-		vals[i] = int8(m.mutateInt(int64(v), math.MaxInt8))
+		return int8(m.mutateInt(int64(v), math.MaxInt8))
 	case int16:
-		vals[i] = int16(m.mutateInt(int64(v), math.MaxInt16))
+		return int16(m.mutateInt(int64(v), math.MaxInt16))
 	case int64:
-		vals[i] = m.mutateInt(v, maxInt)
+		return m.mutateInt(v, maxInt)
 	case uint:
-		vals[i] = uint(m.mutateUInt(uint64(v), maxUint))
+		return uint(m.mutateUInt(uint64(v), maxUint))
 	case uint16:
-		vals[i] = uint16(m.mutateUInt(uint64(v), math.MaxUint16))
+		return uint16(m.mutateUInt(uint64(v), math.MaxUint16))
 	case uint32:
-		vals[i] = uint32(m.mutateUInt(uint64(v), math.MaxUint32))
+		return uint32(m.mutateUInt(uint64(v), math.MaxUint32))
 	case uint64:
-		vals[i] = m.mutateUInt(uint64(v), maxUint)
+		return m.mutateUInt(uint64(v), maxUint)
 	case float32:
-		vals[i] = float32(m.mutateFloat(float64(v), math.MaxFloat32))
+		return float32(m.mutateFloat(float64(v), math.MaxFloat32))
 	case float64:
-		vals[i] = m.mutateFloat(v, math.MaxFloat64)
+		return m.mutateFloat(v, math.MaxFloat64)
 	case bool:
 		if m.rand(2) == 1 {
-			vals[i] = !v // 50% chance of flipping the bool
+			return !v // 50% chance of flipping the bool
 		}
+		return v
 	case rune: // int32
-		vals[i] = rune(m.mutateInt(int64(v), math.MaxInt32))
+		return rune(m.mutateInt(int64(v), math.MaxInt32))
 	case byte: // uint8
-		vals[i] = byte(m.mutateUInt(uint64(v), math.MaxUint8))
+		return byte(m.mutateUInt(uint64(v), math.MaxUint8))
 	case string:
 		if len(v) > maxPerVal {
 			panic(fmt.Sprintf("cannot mutate bytes of length %d", len(v)))
@@ -106,7 +132,7 @@ func (m *mutator) mutate(vals []any, maxBytes int) {
 			copy(m.scratch, v)
 		}
 		m.mutateBytes(&m.scratch)
-		vals[i] = string(m.scratch)
+		return string(m.scratch)
 	case []byte:
 		if len(v) > maxPerVal {
 			panic(fmt.Sprintf("cannot mutate bytes of length %d", len(v)))
@@ -118,9 +144,91 @@ func (m *mutator) mutate(vals []any, maxBytes int) {
 			copy(m.scratch, v)
 		}
 		m.mutateBytes(&m.scratch)
-		vals[i] = m.scratch
+		return m.scratch
+	default:
+		panic(fmt.Sprintf("type not supported for mutating: %T", v))
+	}
+}
+
+// mutateValue mutates v, a settable value of a supported type, in place.
+// It mutates one of the elements of a composite value or, for slices and
+// maps, adds or removes one. maxPerVal is the maximum length of []byte and
+// string elements.
+func (m *mutator) mutateValue(v reflect.Value, maxPerVal int) {
+	t := v.Type()
+	if isPrimitiveType(t) {
+		x := m.mutatePrimitive(v.Interface(), maxPerVal)
+		if b, ok := x.([]byte); ok {
+			x = append([]byte(nil), b...) // don't keep m.scratch
+		}
+		v.Set(reflect.ValueOf(x))
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if t.NumField() > 0 {
+			m.mutateValue(v.Field(m.rand(t.NumField())), maxPerVal)
+		}
+	case reflect.Array:
+		if v.Len() > 0 {
+			m.mutateValue(v.Index(m.rand(v.Len())), maxPerVal)
+		}
+	case reflect.Slice:
+		n := v.Len()
+		switch op := m.rand(4); {
+		case op == 0 || n == 0:
+			// Insert a zero element or a copy of another element.
+			e := reflect.New(t.Elem()).Elem()
+			if n > 0 && m.rand(2) == 0 {
+				e.Set(deepCopy(v.Index(m.rand(n))))
+			}
+			i := m.rand(n + 1)
+			v.Set(reflect.Append(v, e))
+			reflect.Copy(v.Slice(i+1, n+1), v.Slice(i, n))
+			v.Index(i).Set(e)
+		case op == 1:
+			// Remove an element.
+			i := m.rand(n)
+			reflect.Copy(v.Slice(i, n-1), v.Slice(i+1, n))
+			v.Set(v.Slice(0, n-1))
+		default:
+			m.mutateValue(v.Index(m.rand(n)), maxPerVal)
+		}
+	case reflect.Map:
+		// Choose entries in a deterministic order, so that the coordinator
+		// can repeat the mutations made by a worker.
+		keys, elems := sortedMapEntries(v)
+		n := len(keys)
+		switch op := m.rand(4); {
+		case op == 0 || n == 0:
+			// Add an entry, with a zero element and a new key.
+			k := reflect.New(t.Key()).Elem()
+			if n > 0 {
+				k.Set(deepCopy(keys[m.rand(n)]))
+			}
+			m.mutateValue(k, maxPerVal)
+			if v.IsNil() {
+				v.Set(reflect.MakeMap(t))
+			}
+			v.SetMapIndex(k, reflect.New(t.Elem()).Elem())
+		case op == 1:
+			// Remove an entry.
+			v.SetMapIndex(keys[m.rand(n)], reflect.Value{})
+		case op == 2:
+			// Change the key of an entry.
+			i := m.rand(n)
+			k := deepCopy(keys[i])
+			m.mutateValue(k, maxPerVal)
+			v.SetMapIndex(keys[i], reflect.Value{})
+			v.SetMapIndex(k, elems[i])
+		default:
+			i := m.rand(n)
+			e := deepCopy(elems[i])
+			m.mutateValue(e, maxPerVal)
+			v.SetMapIndex(keys[i], e)
+		}
 	default:
-		panic(fmt.Sprintf("type not supported for mutating: %T", vals[i]))
+		panic(fmt.Sprintf("type not supported for mutating: %v", t))
 	}
 }
 
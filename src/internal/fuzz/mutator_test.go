@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"reflect"
 	"strconv"
 	"testing"
 )
@@ -115,3 +116,55 @@ func TestStringImmutability(t *testing.T) {
 		t.Fatalf("string was mutated: got %x, want %x", []byte(original), originalCopy)
 	}
 }
+
+func TestMutateComposite(t *testing.T) {
+	orig := []any{
+		testShape{
+			Name:   "shape",
+			Points: []testPoint{{1, 2}, {3, 4}},
+			Tags:   map[string]bool{"a": true},
+			Data:   []byte("data"),
+		},
+		map[testPoint][]string{{1, 1}: {"x"}},
+	}
+	origData := marshalCorpusFile(orig...)
+	types := []reflect.Type{reflect.TypeOf(orig[0]), reflect.TypeOf(orig[1])}
+
+	// Mutators with the same random state make the same mutations, which the
+	// coordinator relies on to repeat the mutations made by a worker.
+	m1, m2 := newMutator(), newMutator()
+	var randState, randInc uint64
+	m1.r.save(&randState, &randInc)
+	m2.r.restore(randState, randInc)
+	v1 := append([]any(nil), orig...)
+	v2 := append([]any(nil), orig...)
+	changed := 0
+	for i := 0; i < 1000; i++ {
+		prev := marshalCorpusFile(v1...)
+		m1.mutate(v1, 4096)
+		m2.mutate(v2, 4096)
+		b1, b2 := marshalCorpusFile(v1...), marshalCorpusFile(v2...)
+		if !bytes.Equal(b1, b2) {
+			t.Fatalf("mutation %d differs:\n%s\n%s", i, b1, b2)
+		}
+		if !bytes.Equal(b1, prev) {
+			changed++
+		}
+		if len(b1) > 4096 {
+			t.Fatalf("mutation %d too large: %d bytes", i, len(b1))
+		}
+		vals, err := unmarshalCorpusFile(b1, types)
+		if err != nil {
+			t.Fatalf("mutation %d: unmarshal: %v\n%s", i, err, b1)
+		}
+		if err := CheckCorpus(vals, types); err != nil {
+			t.Fatalf("mutation %d: %v", i, err)
+		}
+	}
+	if changed < 500 {
+		t.Errorf("only %d of 1000 mutations changed the values", changed)
+	}
+	if b := marshalCorpusFile(orig...); !bytes.Equal(b, origData) {
+		t.Fatalf("original values were mutated:\n%s\nwant:\n%s", b, origData)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"reflect"
+)
+
+// Besides the primitive types listed in zeroVals, the fuzzing engine
+// supports composite types built from them: structs whose fields are all
+// exported and of supported types, arrays and slices of supported types,
+// and maps whose key and element types are supported. Values of composite
+// types are encoded as composite literals (see marshalCorpusFile) and
+// mutated element by element (see mutator.mutateValue).
+
+// isPrimitiveType reports whether t is one of the primitive types
+// supported by the fuzzing engine, including []byte.
+func isPrimitiveType(t reflect.Type) bool {
+	return primitiveTypes[t]
+}
+
+var primitiveTypes = make(map[reflect.Type]bool)
+
+func init() {
+	for _, v := range zeroVals {
+		primitiveTypes[reflect.TypeOf(v)] = true
+	}
+}
+
+// isSupportedType reports whether values of type t can be fuzzed.
+func isSupportedType(t reflect.Type) bool {
+	return isSupportedTypeRec(t, make(map[reflect.Type]bool))
+}
+
+// isSupportedTypeRec implements isSupportedType. seen holds the struct
+// types being checked, which are assumed to be supported if they occur
+// within themselves.
+func isSupportedTypeRec(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if isPrimitiveType(t) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Array, reflect.Slice:
+		return isSupportedTypeRec(t.Elem(), seen)
+	case reflect.Map:
+		return t.Key().Comparable() && isSupportedTypeRec(t.Key(), seen) && isSupportedTypeRec(t.Elem(), seen)
+	case reflect.Struct:
+		if seen[t] {
+			return true
+		}
+		seen[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" || !isSupportedTypeRec(f.Type, seen) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// deepCopy returns a settable copy of v that shares no memory with v.
+// v must be of a supported type.
+func deepCopy(v reflect.Value) reflect.Value {
+	c := reflect.New(v.Type()).Elem()
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			break
+		}
+		s := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			reflect.Copy(s, v)
+		} else {
+			for i := 0; i < v.Len(); i++ {
+				s.Index(i).Set(deepCopy(v.Index(i)))
+			}
+		}
+		c.Set(s)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			c.Field(i).Set(deepCopy(v.Field(i)))
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			break
+		}
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(deepCopy(iter.Key()), deepCopy(iter.Value()))
+		}
+		c.Set(m)
+	default:
+		c.Set(v)
+	}
+	return c
+}
@@ -372,7 +372,7 @@ func (w *worker) start() (err error) {
 	w.termC = make(chan struct{})
 	comm := workerComm{fuzzIn: fuzzInW, fuzzOut: fuzzOutR, memMu: w.memMu}
 	m := newMutator()
-	w.client = newWorkerClient(comm, m)
+	w.client = newWorkerClient(comm, m, w.coordinator.opts.Types)
 
 	go func() {
 		w.waitErr = w.cmd.Wait()
@@ -468,11 +468,12 @@ func (w *worker) stop() error {
 //
 // fn is a wrapper on the fuzz function. It may return an error to indicate
 // a given input "crashed". The coordinator will also record a crasher if
-// the function times out or terminates the process.
+// the function times out or terminates the process. types is the list of
+// types of the fuzz function's arguments.
 //
 // RunFuzzWorker returns an error if it could not communicate with the
 // coordinator process.
-func RunFuzzWorker(ctx context.Context, fn func(CorpusEntry) error) error {
+func RunFuzzWorker(ctx context.Context, fn func(CorpusEntry) error, types []reflect.Type) error {
 	comm, err := getWorkerComm()
 	if err != nil {
 		return err
@@ -488,7 +489,8 @@ func RunFuzzWorker(ctx context.Context, fn func(CorpusEntry) error) error {
 			err := fn(e)
 			return time.Since(start), err
 		},
-		m: newMutator(),
+		m:     newMutator(),
+		types: types,
 	}
 	return srv.serve(ctx)
 }
@@ -618,6 +620,10 @@ type workerServer struct {
 	workerComm
 	m *mutator
 
+	// types is the list of types of the fuzz function's arguments, used to
+	// decode values of composite types.
+	types []reflect.Type
+
 	// coverageMask is the local coverage data for the worker. It is
 	// periodically updated to reflect the data in the coordinator when new
 	// coverage is found.
@@ -721,7 +727,7 @@ func (ws *workerServer) fuzz(ctx context.Context, args fuzzArgs) (resp fuzzRespo
 		return resp
 	}
 
-	originalVals, err := unmarshalCorpusFile(mem.valueCopy())
+	originalVals, err := unmarshalCorpusFile(mem.valueCopy(), ws.types)
 	if err != nil {
 		resp.InternalErr = err.Error()
 		return resp
@@ -796,7 +802,7 @@ func (ws *workerServer) minimize(ctx context.Context, args minimizeArgs) (resp m
 	defer func() { resp.Duration = time.Now().Sub(start) }()
 	mem := <-ws.memMu
 	defer func() { ws.memMu <- mem }()
-	vals, err := unmarshalCorpusFile(mem.valueCopy())
+	vals, err := unmarshalCorpusFile(mem.valueCopy(), ws.types)
 	if err != nil {
 		panic(err)
 	}
@@ -930,6 +936,10 @@ type workerClient struct {
 	workerComm
 	m *mutator
 
+	// types is the list of types of the fuzz function's arguments, used to
+	// decode values of composite types.
+	types []reflect.Type
+
 	// mu is the mutex protecting the workerComm.fuzzIn pipe. This must be
 	// locked before making calls to the workerServer. It prevents
 	// workerClient.Close from closing fuzzIn while workerClient methods are
@@ -938,8 +948,8 @@ type workerClient struct {
 	mu sync.Mutex
 }
 
-func newWorkerClient(comm workerComm, m *mutator) *workerClient {
-	return &workerClient{workerComm: comm, m: m}
+func newWorkerClient(comm workerComm, m *mutator, types []reflect.Type) *workerClient {
+	return &workerClient{workerComm: comm, m: m, types: types}
 }
 
 // Close shuts down the connection to the RPC server (the worker process) by
@@ -992,7 +1002,7 @@ func (wc *workerClient) minimize(ctx context.Context, entryIn CorpusEntry, args
 	mem.setValue(inp)
 	defer func() { wc.memMu <- mem }()
 	entryOut = entryIn
-	entryOut.Values, err = unmarshalCorpusFile(inp)
+	entryOut.Values, err = unmarshalCorpusFile(inp, wc.types)
 	if err != nil {
 		return CorpusEntry{}, minimizeResponse{}, fmt.Errorf("workerClient.minimize unmarshaling provided value: %v", err)
 	}
@@ -1035,7 +1045,7 @@ func (wc *workerClient) minimize(ctx context.Context, entryIn CorpusEntry, args
 		if resp.WroteToMem {
 			// Minimization succeeded, and mem holds the marshaled data.
 			entryOut.Data = mem.valueCopy()
-			entryOut.Values, err = unmarshalCorpusFile(entryOut.Data)
+			entryOut.Values, err = unmarshalCorpusFile(entryOut.Data, wc.types)
 			if err != nil {
 				return CorpusEntry{}, minimizeResponse{}, fmt.Errorf("workerClient.minimize unmarshaling minimized value: %v", err)
 			}
@@ -1096,7 +1106,7 @@ func (wc *workerClient) fuzz(ctx context.Context, entryIn CorpusEntry, args fuzz
 	needEntryOut := callErr != nil || resp.Err != "" ||
 		(!args.Warmup && resp.CoverageData != nil)
 	if needEntryOut {
-		valuesOut, err := unmarshalCorpusFile(inp)
+		valuesOut, err := unmarshalCorpusFile(inp, wc.types)
 		if err != nil {
 			return CorpusEntry{}, fuzzResponse{}, true, fmt.Errorf("unmarshaling fuzz input value after call: %v", err)
 		}
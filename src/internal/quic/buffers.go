@@ -0,0 +1,171 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+// A sendBuffer holds data written to a stream (or a CRYPTO stream)
+// until it is acknowledged by the peer.
+type sendBuffer struct {
+	buf    []byte   // data from offset base to end
+	base   int64    // offset of buf[0]; everything before is acknowledged
+	unsent rangeSet // data not yet sent, or sent and lost
+	acked  rangeSet // acknowledged data at or after base
+}
+
+// end returns the offset of the end of the written data.
+func (b *sendBuffer) end() int64 {
+	return b.base + int64(len(b.buf))
+}
+
+// buffered returns the amount of data written but not yet acknowledged.
+func (b *sendBuffer) buffered() int64 {
+	return int64(len(b.buf))
+}
+
+// write appends data to the buffer.
+func (b *sendBuffer) write(p []byte) {
+	end := b.end()
+	b.buf = append(b.buf, p...)
+	b.unsent.add(end, end+int64(len(p)))
+}
+
+// hasUnsent reports whether there is data to send before offset limit.
+func (b *sendBuffer) hasUnsent(limit int64) bool {
+	return len(b.unsent) > 0 && b.unsent[0].start < limit
+}
+
+// next returns up to maxLen bytes of data to send, starting from the
+// lowest unsent offset and ending before limit, and marks it as sent.
+func (b *sendBuffer) next(maxLen int, limit int64) (off int64, data []byte) {
+	if maxLen <= 0 || !b.hasUnsent(limit) {
+		return 0, nil
+	}
+	r := b.unsent[0]
+	off = r.start
+	end := r.end
+	if end > limit {
+		end = limit
+	}
+	if end-off > int64(maxLen) {
+		end = off + int64(maxLen)
+	}
+	b.unsent.sub(off, end)
+	return off, b.buf[off-b.base : end-b.base]
+}
+
+// lost marks previously sent data as needing to be sent again.
+func (b *sendBuffer) lost(off, n int64) {
+	start, end := off, off+n
+	if start < b.base {
+		start = b.base
+	}
+	if start >= end {
+		return
+	}
+	b.unsent.add(start, end)
+	for _, r := range b.acked {
+		b.unsent.sub(r.start, r.end)
+	}
+}
+
+// ack marks data as acknowledged, releasing it from the buffer
+// once all preceding data is acknowledged.
+func (b *sendBuffer) ack(off, n int64) {
+	b.acked.add(off, off+n)
+	b.unsent.sub(off, off+n)
+	if len(b.acked) > 0 && b.acked[0].start <= b.base && b.acked[0].end > b.base {
+		end := b.acked[0].end
+		b.buf = b.buf[end-b.base:]
+		b.base = end
+		b.acked.removeBefore(end)
+		if len(b.buf) == 0 {
+			b.buf = nil
+		}
+	}
+	b.acked.removeBefore(b.base)
+}
+
+// discard drops all buffered data.
+func (b *sendBuffer) discard() {
+	b.base = b.end()
+	b.buf = nil
+	b.unsent = nil
+	b.acked = nil
+}
+
+// A recvBuffer reassembles data received out of order.
+type recvBuffer struct {
+	buf   []byte   // data from offset off
+	off   int64    // offset of buf[0]; everything before has been read
+	recvd rangeSet // received data, including data before off
+}
+
+// write adds data received at offset off.
+func (b *recvBuffer) write(off int64, p []byte) {
+	end := off + int64(len(p))
+	if end <= b.off {
+		return
+	}
+	if off < b.off {
+		p = p[b.off-off:]
+		off = b.off
+	}
+	need := int(end - b.off)
+	if need > len(b.buf) {
+		if need <= cap(b.buf) {
+			b.buf = b.buf[:need]
+		} else {
+			c := 2 * cap(b.buf)
+			if c < need {
+				c = need
+			}
+			nb := make([]byte, need, c)
+			copy(nb, b.buf)
+			b.buf = nb
+		}
+	}
+	copy(b.buf[off-b.off:], p)
+	b.recvd.add(off, end)
+}
+
+// readable returns the amount of contiguous data available to read.
+func (b *recvBuffer) readable() int {
+	if len(b.recvd) == 0 || b.recvd[0].start > b.off {
+		return 0
+	}
+	return int(b.recvd[0].end - b.off)
+}
+
+// read reads up to len(p) contiguous bytes.
+func (b *recvBuffer) read(p []byte) int {
+	n := b.readable()
+	if n > len(p) {
+		n = len(p)
+	}
+	copy(p, b.buf[:n])
+	b.consume(n)
+	return n
+}
+
+// peekAll returns all contiguous readable data, without consuming it.
+func (b *recvBuffer) peekAll() []byte {
+	return b.buf[:b.readable()]
+}
+
+// consume discards n bytes of readable data.
+func (b *recvBuffer) consume(n int) {
+	b.buf = b.buf[n:]
+	b.off += int64(n)
+	if len(b.buf) == 0 {
+		b.buf = nil
+	}
+}
+
+// end returns the highest offset received.
+func (b *recvBuffer) end() int64 {
+	if len(b.recvd) == 0 {
+		return 0
+	}
+	return b.recvd[len(b.recvd)-1].end
+}
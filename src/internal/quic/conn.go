@@ -0,0 +1,679 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+type connSide int8
+
+const (
+	clientSide = connSide(iota)
+	serverSide
+)
+
+func (s connSide) String() string {
+	if s == clientSide {
+		return "client"
+	}
+	return "server"
+}
+
+type connState int8
+
+const (
+	connActive   = connState(iota)
+	connClosing  // sent CONNECTION_CLOSE, waiting for the closing period to end
+	connDraining // received CONNECTION_CLOSE, waiting for the draining period to end
+	connClosed   // done
+)
+
+var errConnClosed = errors.New("quic: connection closed")
+
+// A Conn is a QUIC connection.
+//
+// Multiple goroutines may invoke methods on a Conn simultaneously.
+type Conn struct {
+	side     connSide
+	ep       *Endpoint
+	config   *Config
+	peerAddr net.Addr
+	tls      *tls.QUICConn
+
+	tlsCancel context.CancelFunc // cancels the TLS handshake
+
+	recvc  chan []byte   // datagrams received from the endpoint
+	wakec  chan struct{} // wakes the connection loop
+	readyc chan struct{} // closed when the handshake completes or fails
+	donec  chan struct{} // closed when the connection loop exits
+
+	mu sync.Mutex // guards everything below
+
+	state      connState
+	err        error         // terminal error; set when state != connActive
+	errc       chan struct{} // closed when err is set
+	closeFrame []byte        // CONNECTION_CLOSE frame, when closing
+	closeApp   bool          // closeFrame is an application close
+	closeSend  bool          // closeFrame should be sent
+	closeTime  time.Time     // end of the closing or draining period
+	exitNow    bool          // skip the closing period
+
+	origDstConnID []byte // destination connection ID of the client's first Initial
+	localConnID   []byte
+	remoteConnID  []byte
+	gotRemoteID   bool // client has seen the server's connection ID
+
+	spaces [numberSpaceCount]pnSpace
+
+	// 1-RTT key update state. See RFC 9001, Section 6.
+	appSuite       uint16
+	appReadSecret  []byte
+	appWriteSecret []byte
+	keyPhase       bool
+
+	handshakeComplete    bool // TLS handshake complete
+	handshakeConfirmed   bool // RFC 9001, Section 4.1.2
+	handshakeDonePending bool // server must send HANDSHAKE_DONE
+	tlsState             tls.ConnectionState
+
+	// Anti-amplification limit for servers. See RFC 9000, Section 8.1.
+	addrValidated bool
+	bytesRecv     int64
+	bytesSent     int64
+
+	peerParams  transportParameters
+	gotParams   bool
+	idleTimeout time.Duration
+	idleTime    time.Time // time of the last activity resetting the idle timer
+	sentSince   bool      // an ack-eliciting packet was sent since idleTime was reset by a receive
+	pingPending bool
+
+	loss lossState
+
+	// Connection-level flow control. See RFC 9000, Section 4.
+	peerMaxData    int64 // limit on data we may send
+	sentData       int64 // data sent, summed over streams' highest offsets
+	maxData        int64 // limit on data the peer may send
+	recvData       int64 // data received, summed over streams' highest offsets
+	readData       int64 // data consumed by reads
+	maxDataPending bool
+
+	streams map[int64]*Stream
+	// Stream counts and limits, indexed by streamType.
+	localOpened       [2]int64 // locally-initiated streams opened
+	peerMaxStreams    [2]int64 // limit on locally-initiated streams
+	remoteOpened      [2]int64 // peer-initiated streams opened
+	maxStreams        [2]int64 // limit on peer-initiated streams
+	maxStreamsPending [2]bool
+	acceptq           [2][]*Stream
+	streamsChanged    broadcaster // streams accepted or limits raised
+
+	pendingPathResponse []byte // PATH_RESPONSE data to send
+
+	sendBuf []byte
+}
+
+// A broadcaster wakes goroutines waiting for a state change.
+// It is guarded by the Conn's mutex.
+type broadcaster struct {
+	c chan struct{}
+}
+
+// wait returns a channel which is closed at the next signal.
+func (b *broadcaster) wait() <-chan struct{} {
+	if b.c == nil {
+		b.c = make(chan struct{})
+	}
+	return b.c
+}
+
+func (b *broadcaster) signal() {
+	if b.c != nil {
+		close(b.c)
+		b.c = nil
+	}
+}
+
+// A pnSpace is the state of a packet number space.
+type pnSpace struct {
+	rkeys     *packetKeys
+	wkeys     *packetKeys
+	discarded bool
+
+	nextNum int64
+
+	// Received packets.
+	recvd            rangeSet
+	largestRecvTime  time.Time
+	ackNeeded        bool // packets received since the last ACK sent
+	ackPending       bool // ack-eliciting packets received since the last ACK sent
+	unackedEliciting int
+	ackDeadline      time.Time
+
+	cryptoSend sendBuffer
+	cryptoRecv recvBuffer
+
+	// Sent ack-eliciting packets, in order, not yet acknowledged or lost.
+	sent                 []*sentPacket
+	largestAcked         int64
+	lossTime             time.Time
+	lastAckElicitingTime time.Time
+	probe                int // ack-eliciting packets to send regardless of congestion control
+}
+
+// newConn creates a new connection. For servers, origDstConnID and
+// srcConnID are the connection IDs in the client's first Initial packet.
+func newConn(side connSide, ep *Endpoint, peerAddr net.Addr, origDstConnID, srcConnID []byte, config *Config) (*Conn, error) {
+	c := &Conn{
+		side:     side,
+		ep:       ep,
+		config:   config,
+		peerAddr: peerAddr,
+		recvc:    make(chan []byte, 256),
+		wakec:    make(chan struct{}, 1),
+		readyc:   make(chan struct{}),
+		donec:    make(chan struct{}),
+		errc:     make(chan struct{}),
+		streams:  make(map[int64]*Stream),
+	}
+	c.localConnID = newConnID()
+	if side == clientSide {
+		c.origDstConnID = newConnID()
+		c.remoteConnID = c.origDstConnID
+	} else {
+		c.origDstConnID = append([]byte(nil), origDstConnID...)
+		c.remoteConnID = append([]byte(nil), srcConnID...)
+	}
+	for i := range c.spaces {
+		c.spaces[i].largestAcked = -1
+	}
+	clientKeys, serverKeys := initialKeys(c.origDstConnID)
+	if side == clientSide {
+		c.spaces[initialSpace].wkeys, c.spaces[initialSpace].rkeys = clientKeys, serverKeys
+	} else {
+		c.spaces[initialSpace].wkeys, c.spaces[initialSpace].rkeys = serverKeys, clientKeys
+	}
+	c.loss.init()
+	c.peerParams = defaultTransportParameters()
+	c.idleTimeout = config.maxIdleTimeout()
+	c.idleTime = time.Now()
+
+	streamWindow := config.maxStreamReadBufferSize()
+	c.maxData = config.maxConnReadBufferSize()
+	c.maxStreams[bidiStream] = config.maxBidiRemoteStreams()
+	c.maxStreams[uniStream] = config.maxUniRemoteStreams()
+	params := transportParameters{
+		maxIdleTimeout:                 c.idleTimeout,
+		initialMaxData:                 c.maxData,
+		initialMaxStreamDataBidiLocal:  streamWindow,
+		initialMaxStreamDataBidiRemote: streamWindow,
+		initialMaxStreamDataUni:        streamWindow,
+		initialMaxStreamsBidi:          c.maxStreams[bidiStream],
+		initialMaxStreamsUni:           c.maxStreams[uniStream],
+		disableActiveMigration:         true,
+		initialSrcConnID:               c.localConnID,
+		hasInitialSrcConnID:            true,
+	}
+	if side == serverSide {
+		params.originalDstConnID = c.origDstConnID
+		params.hasOriginalDstConnID = true
+	}
+
+	tlsConfig := config.TLSConfig.Clone()
+	tlsConfig.MinVersion = tls.VersionTLS13
+	qconfig := &tls.QUICConfig{TLSConfig: tlsConfig}
+	if side == clientSide {
+		c.tls = tls.QUICClient(qconfig)
+	} else {
+		c.tls = tls.QUICServer(qconfig)
+	}
+	c.tls.SetTransportParameters(params.marshal())
+	ctx, cancel := context.WithCancel(context.Background())
+	c.tlsCancel = cancel
+	if err := c.tls.Start(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+	c.mu.Lock()
+	err := c.handleTLSEvents(time.Now())
+	c.mu.Unlock()
+	if err != nil {
+		c.closeTLS()
+		return nil, err
+	}
+	return c, nil
+}
+
+func newConnID() []byte {
+	id := make([]byte, connIDLen)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// wake wakes up the connection loop.
+func (c *Conn) wake() {
+	select {
+	case c.wakec <- struct{}{}:
+	default:
+	}
+}
+
+// deliver passes a datagram to the connection loop.
+func (c *Conn) deliver(b []byte) {
+	select {
+	case c.recvc <- b:
+	default:
+		// Drop the datagram; the peer will retransmit.
+	}
+}
+
+// loop is the connection's main loop, which processes received
+// datagrams, sends packets and handles timers.
+func (c *Conn) loop() {
+	defer c.finish()
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		c.mu.Lock()
+		now := time.Now()
+		c.handleTimers(now)
+		if c.state != connClosed {
+			c.sendPackets(now)
+		}
+		if c.state == connClosed {
+			c.mu.Unlock()
+			return
+		}
+		next := c.nextTimeout()
+		c.mu.Unlock()
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if !next.IsZero() {
+			timer.Reset(time.Until(next))
+		}
+		select {
+		case b := <-c.recvc:
+			c.mu.Lock()
+			c.handleDatagram(b, time.Now())
+			// Process any other datagrams already queued before sending.
+			for i := 0; i < 16; i++ {
+				select {
+				case b := <-c.recvc:
+					c.handleDatagram(b, time.Now())
+					continue
+				default:
+				}
+				break
+			}
+			c.mu.Unlock()
+		case <-c.wakec:
+		case <-timer.C:
+		}
+	}
+}
+
+// finish cleans up after the connection loop exits.
+func (c *Conn) finish() {
+	c.mu.Lock()
+	c.setErr(errConnClosed)
+	c.state = connClosed
+	c.mu.Unlock()
+	c.closeTLS()
+	select {
+	case <-c.readyc:
+	default:
+		close(c.readyc)
+	}
+	close(c.donec)
+	c.ep.removeConn(c)
+}
+
+// handleTimers processes expired timers.
+func (c *Conn) handleTimers(now time.Time) {
+	switch c.state {
+	case connClosing, connDraining:
+		if c.exitNow && !c.closeSend || !now.Before(c.closeTime) {
+			c.state = connClosed
+		}
+		return
+	}
+	if deadline := c.idleDeadline(); !now.Before(deadline) {
+		c.setErr(idleTimeoutError{})
+		c.state = connClosed
+		return
+	}
+	if p := c.config.KeepAlivePeriod; p > 0 && c.handshakeComplete && !now.Before(c.idleTime.Add(p)) && !c.sentSince {
+		c.pingPending = true
+	}
+	if t, _ := c.lossTimer(now); !t.IsZero() && !now.Before(t) {
+		c.onLossTimer(now)
+	}
+}
+
+func (c *Conn) idleDeadline() time.Time {
+	d := c.idleTimeout
+	if pto := 3 * c.ptoPeriod(appDataSpace); d < pto {
+		d = pto
+	}
+	return c.idleTime.Add(d)
+}
+
+// nextTimeout returns the time of the next timer event.
+func (c *Conn) nextTimeout() time.Time {
+	var next time.Time
+	min := func(t time.Time) {
+		if !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	if c.state != connActive {
+		min(c.closeTime)
+		return next
+	}
+	min(c.idleDeadline())
+	if p := c.config.KeepAlivePeriod; p > 0 && c.handshakeComplete && !c.sentSince {
+		min(c.idleTime.Add(p))
+	}
+	t, _ := c.lossTimer(time.Now())
+	min(t)
+	if s := &c.spaces[appDataSpace]; s.ackPending {
+		min(s.ackDeadline)
+	}
+	return next
+}
+
+// setErr records the terminal error of the connection.
+func (c *Conn) setErr(err error) {
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.errc)
+	c.streamsChanged.signal()
+	for _, s := range c.streams {
+		s.readable.signal()
+		s.writable.signal()
+	}
+	select {
+	case <-c.readyc:
+	default:
+		close(c.readyc)
+	}
+}
+
+// abort closes the connection after a local error or call to Close.
+// err is sent to the peer if it is a *TransportError or *ApplicationError;
+// otherwise, the connection is closed with an INTERNAL_ERROR.
+func (c *Conn) abort(now time.Time, err error) {
+	if c.state != connActive {
+		return
+	}
+	var frame []byte
+	app := false
+	switch e := err.(type) {
+	case *ApplicationError:
+		app = true
+		frame = appendConnectionCloseFrame(nil, true, e.Code, e.Reason)
+		if e.Code == 0 && e.Reason == "" {
+			err = errConnClosed
+		}
+	case *TransportError:
+		frame = appendConnectionCloseFrame(nil, false, e.Code, e.Reason)
+	default:
+		frame = appendConnectionCloseFrame(nil, false, uint64(errInternal), "")
+	}
+	c.setErr(err)
+	c.state = connClosing
+	c.closeTLS()
+	c.closeFrame = frame
+	c.closeApp = app
+	c.closeSend = true
+	c.closeTime = now.Add(3 * c.ptoPeriod(appDataSpace))
+	c.wake()
+}
+
+// enterDraining handles receipt of a CONNECTION_CLOSE frame.
+func (c *Conn) enterDraining(now time.Time, err error) {
+	if c.state == connDraining || c.state == connClosed {
+		return
+	}
+	c.setErr(err)
+	if c.state == connActive {
+		c.closeTime = now.Add(3 * c.ptoPeriod(appDataSpace))
+	}
+	c.state = connDraining
+	c.closeSend = false
+	c.closeTLS()
+}
+
+// closeTLS stops the TLS handshake, if it is still in progress, and
+// releases the TLS connection. No handshake data is processed once the
+// connection is no longer active, so this is done as soon as it is
+// closed rather than at the end of the closing or draining period.
+// Calling closeTLS more than once is harmless.
+func (c *Conn) closeTLS() {
+	c.tlsCancel()
+	c.tls.Close()
+}
+
+// handleTLSEvents processes events from the TLS handshake.
+func (c *Conn) handleTLSEvents(now time.Time) error {
+	for {
+		e := c.tls.NextEvent()
+		switch e.Kind {
+		case tls.QUICNoEvent:
+			return nil
+		case tls.QUICSetReadSecret:
+			space := levelSpace(e.Level)
+			c.spaces[space].rkeys = newPacketKeys(e.Suite, e.Data)
+			if space == appDataSpace {
+				c.appSuite = e.Suite
+				c.appReadSecret = append([]byte(nil), e.Data...)
+			}
+		case tls.QUICSetWriteSecret:
+			space := levelSpace(e.Level)
+			c.spaces[space].wkeys = newPacketKeys(e.Suite, e.Data)
+			if space == appDataSpace {
+				c.appSuite = e.Suite
+				c.appWriteSecret = append([]byte(nil), e.Data...)
+			}
+		case tls.QUICWriteData:
+			space := levelSpace(e.Level)
+			c.spaces[space].cryptoSend.write(e.Data)
+		case tls.QUICTransportParameters:
+			if err := c.receiveTransportParameters(e.Data); err != nil {
+				return err
+			}
+		case tls.QUICHandshakeDone:
+			c.handshakeComplete = true
+			c.tlsState = c.tls.ConnectionState()
+			if c.side == serverSide {
+				// The server's handshake is confirmed when it completes.
+				// See RFC 9001, Section 4.1.2.
+				c.handshakeConfirmed = true
+				c.handshakeDonePending = true
+				c.discardKeys(handshakeSpace)
+			}
+			select {
+			case <-c.readyc:
+			default:
+				close(c.readyc)
+			}
+			if c.side == serverSide {
+				c.ep.queueAccept(c)
+			}
+		}
+	}
+}
+
+func levelSpace(level tls.QUICEncryptionLevel) numberSpace {
+	switch level {
+	case tls.QUICEncryptionLevelInitial:
+		return initialSpace
+	case tls.QUICEncryptionLevelHandshake:
+		return handshakeSpace
+	}
+	return appDataSpace
+}
+
+func spaceLevel(space numberSpace) tls.QUICEncryptionLevel {
+	switch space {
+	case initialSpace:
+		return tls.QUICEncryptionLevelInitial
+	case handshakeSpace:
+		return tls.QUICEncryptionLevelHandshake
+	}
+	return tls.QUICEncryptionLevelApplication
+}
+
+func (c *Conn) receiveTransportParameters(b []byte) error {
+	p, err := unmarshalTransportParameters(b, c.side == clientSide)
+	if err != nil {
+		return err
+	}
+	if !p.hasInitialSrcConnID || string(p.initialSrcConnID) != string(c.remoteConnID) {
+		return newTransportError(errTransportParameter, "initial_source_connection_id does not match")
+	}
+	if c.side == clientSide {
+		if !p.hasOriginalDstConnID || string(p.originalDstConnID) != string(c.origDstConnID) {
+			return newTransportError(errTransportParameter, "original_destination_connection_id does not match")
+		}
+		if p.retrySrcConnID != nil {
+			return newTransportError(errTransportParameter, "unexpected retry_source_connection_id")
+		}
+	}
+	c.peerParams = p
+	c.gotParams = true
+	c.peerMaxData = p.initialMaxData
+	c.peerMaxStreams[bidiStream] = p.initialMaxStreamsBidi
+	c.peerMaxStreams[uniStream] = p.initialMaxStreamsUni
+	if p.maxIdleTimeout > 0 && p.maxIdleTimeout < c.idleTimeout {
+		c.idleTimeout = p.maxIdleTimeout
+	}
+	c.loss.maxAckDelay = p.maxAckDelay
+	c.streamsChanged.signal()
+	return nil
+}
+
+// discardKeys discards the keys and state of an Initial or Handshake
+// packet number space. See RFC 9001, Section 4.9.
+func (c *Conn) discardKeys(space numberSpace) {
+	s := &c.spaces[space]
+	if s.discarded {
+		return
+	}
+	s.discarded = true
+	s.rkeys = nil
+	s.wkeys = nil
+	for _, p := range s.sent {
+		if p.inFlight {
+			c.loss.bytesInFlight -= p.size
+		}
+	}
+	s.sent = nil
+	s.lossTime = time.Time{}
+	s.ackNeeded = false
+	s.ackPending = false
+	s.probe = 0
+	s.cryptoSend.discard()
+	c.loss.ptoCount = 0
+}
+
+// waitReady waits for the handshake to complete.
+func (c *Conn) waitReady(ctx context.Context) error {
+	select {
+	case <-c.readyc:
+	case <-ctx.Done():
+		c.Abort(nil)
+		return ctx.Err()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.handshakeComplete || c.state != connActive {
+		if c.err != nil {
+			return c.err
+		}
+		return errConnClosed
+	}
+	return nil
+}
+
+// ConnectionState returns basic TLS details about the connection.
+func (c *Conn) ConnectionState() tls.ConnectionState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tlsState
+}
+
+// LocalAddr returns the local network address.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.ep.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.peerAddr
+}
+
+// Close closes the connection with no error, as if by Abort(nil).
+func (c *Conn) Close() error {
+	c.Abort(nil)
+	return nil
+}
+
+// Abort closes the connection and returns immediately.
+//
+// If err is an *ApplicationError, its code and reason are sent to the
+// peer. If err is nil, the connection is closed with application error
+// code 0. Any other error is sent as an INTERNAL_ERROR.
+//
+// Pending operations on the connection's streams return an error.
+func (c *Conn) Abort(err error) {
+	if err == nil {
+		err = &ApplicationError{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.abort(time.Now(), err)
+}
+
+// Done returns a channel which is closed when the connection is closed,
+// whether by Close, by the peer, or by an error.
+func (c *Conn) Done() <-chan struct{} {
+	return c.errc
+}
+
+// Err returns the error which closed the connection,
+// or nil if the connection is open.
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Wait waits for the connection loop to exit, after the closing or
+// draining period ends.
+func (c *Conn) Wait(ctx context.Context) error {
+	select {
+	case <-c.donec:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,383 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"time"
+)
+
+// Loss detection and congestion control, as described in RFC 9002.
+
+const (
+	initialRTT          = 333 * time.Millisecond
+	timerGranularity    = 1 * time.Millisecond
+	packetThreshold     = 3
+	minCongestionWindow = 2 * maxDatagramSize
+	initialWindow       = 10 * maxDatagramSize
+	maxPTOBackoff       = 10
+)
+
+// A sentPacket records a sent ack-eliciting packet.
+type sentPacket struct {
+	num      int64
+	time     time.Time
+	size     int
+	inFlight bool
+	frames   []sentFrame
+}
+
+// A sentFrame records a retransmittable frame in a sent packet.
+// What to do when the packet is acknowledged or lost depends on the
+// frame type.
+type sentFrame struct {
+	typ byte
+	id  int64 // stream ID, or stream type for MAX_STREAMS
+	off int64
+	n   int64
+	fin bool
+}
+
+type lossState struct {
+	hasRTT      bool
+	latestRTT   time.Duration
+	minRTT      time.Duration
+	smoothedRTT time.Duration
+	rttvar      time.Duration
+	maxAckDelay time.Duration // peer's max_ack_delay
+	ptoCount    int
+
+	// NewReno congestion control. See RFC 9002, Section 7.
+	cwnd          int
+	ssthresh      int
+	bytesInFlight int
+	recoveryStart time.Time
+
+	lastAckElicitingTime time.Time // in any space
+}
+
+func (l *lossState) init() {
+	l.smoothedRTT = initialRTT
+	l.rttvar = initialRTT / 2
+	l.maxAckDelay = 25 * time.Millisecond
+	l.cwnd = initialWindow
+	l.ssthresh = 1<<31 - 1
+}
+
+// updateRTT updates the RTT estimate. See RFC 9002, Section 5.
+func (l *lossState) updateRTT(latest, ackDelay time.Duration, confirmed bool) {
+	l.latestRTT = latest
+	if !l.hasRTT {
+		l.hasRTT = true
+		l.minRTT = latest
+		l.smoothedRTT = latest
+		l.rttvar = latest / 2
+		return
+	}
+	if latest < l.minRTT {
+		l.minRTT = latest
+	}
+	if confirmed && ackDelay > l.maxAckDelay {
+		ackDelay = l.maxAckDelay
+	}
+	adjusted := latest
+	if latest >= l.minRTT+ackDelay {
+		adjusted = latest - ackDelay
+	}
+	diff := l.smoothedRTT - adjusted
+	if diff < 0 {
+		diff = -diff
+	}
+	l.rttvar = (3*l.rttvar + diff) / 4
+	l.smoothedRTT = (7*l.smoothedRTT + adjusted) / 8
+}
+
+// ptoPeriod returns the probe timeout period for a space, without backoff.
+func (c *Conn) ptoPeriod(space numberSpace) time.Duration {
+	l := &c.loss
+	v := 4 * l.rttvar
+	if v < timerGranularity {
+		v = timerGranularity
+	}
+	pto := l.smoothedRTT + v
+	if space == appDataSpace {
+		pto += l.maxAckDelay
+	}
+	return pto
+}
+
+// congestionLimited reports whether the congestion window is too full
+// to send another ack-eliciting datagram.
+func (c *Conn) congestionLimited() bool {
+	return c.loss.bytesInFlight+maxDatagramSize > c.loss.cwnd
+}
+
+// onPacketSent records a sent ack-eliciting packet.
+func (c *Conn) onPacketSent(now time.Time, space numberSpace, p *sentPacket) {
+	s := &c.spaces[space]
+	p.time = now
+	p.inFlight = true
+	s.sent = append(s.sent, p)
+	s.lastAckElicitingTime = now
+	c.loss.lastAckElicitingTime = now
+	c.loss.bytesInFlight += p.size
+}
+
+// handleAckFrame processes an ACK frame.
+func (c *Conn) handleAckFrame(now time.Time, space numberSpace, frame []byte) error {
+	s := &c.spaces[space]
+	var ranges rangeSet
+	largest, delay, _ := consumeAckFrame(frame, func(start, end int64) {
+		ranges.add(start, end)
+	})
+	if largest >= s.nextNum {
+		return newTransportError(errProtocolViolation, "acknowledgement of unsent packet")
+	}
+	var newlyAcked []*sentPacket
+	kept := s.sent[:0]
+	for _, p := range s.sent {
+		if ranges.contains(p.num) {
+			newlyAcked = append(newlyAcked, p)
+		} else {
+			kept = append(kept, p)
+		}
+	}
+	for i := len(kept); i < len(s.sent); i++ {
+		s.sent[i] = nil
+	}
+	s.sent = kept
+	if largest > s.largestAcked {
+		s.largestAcked = largest
+	}
+	if len(newlyAcked) == 0 {
+		return nil
+	}
+	if last := newlyAcked[len(newlyAcked)-1]; last.num == largest {
+		var ackDelay time.Duration
+		if space == appDataSpace {
+			ackDelay = time.Duration(delay<<c.peerParams.ackDelayExponent) * time.Microsecond
+		}
+		c.loss.updateRTT(now.Sub(last.time), ackDelay, c.handshakeConfirmed)
+	}
+	for _, p := range newlyAcked {
+		c.onPacketAcked(space, p)
+	}
+	c.detectLostPackets(now, space)
+	c.loss.ptoCount = 0
+	return nil
+}
+
+func (c *Conn) onPacketAcked(space numberSpace, p *sentPacket) {
+	l := &c.loss
+	if p.inFlight {
+		l.bytesInFlight -= p.size
+		// Increase the congestion window unless in recovery,
+		// or the window is not being fully used.
+		if p.time.After(l.recoveryStart) && l.bytesInFlight+p.size >= l.cwnd/2 {
+			if l.cwnd < l.ssthresh {
+				l.cwnd += p.size
+			} else {
+				l.cwnd += maxDatagramSize * p.size / l.cwnd
+			}
+		}
+	}
+	for _, f := range p.frames {
+		c.onFrameAcked(space, f)
+	}
+}
+
+func (c *Conn) onFrameAcked(space numberSpace, f sentFrame) {
+	switch f.typ {
+	case frameTypeCrypto:
+		c.spaces[space].cryptoSend.ack(f.off, f.n)
+	case frameTypeStreamBase:
+		if s := c.streams[f.id]; s != nil {
+			s.send.ack(f.off, f.n)
+			if f.fin {
+				s.finAcked = true
+			}
+			s.writable.signal()
+			c.maybeRemoveStream(s)
+		}
+	case frameTypeResetStream:
+		if s := c.streams[f.id]; s != nil {
+			s.resetAcked = true
+			c.maybeRemoveStream(s)
+		}
+	}
+}
+
+// detectLostPackets declares packets lost. See RFC 9002, Section 6.1.
+func (c *Conn) detectLostPackets(now time.Time, space numberSpace) {
+	s := &c.spaces[space]
+	l := &c.loss
+	rtt := l.smoothedRTT
+	if l.latestRTT > rtt {
+		rtt = l.latestRTT
+	}
+	lossDelay := rtt * 9 / 8
+	if lossDelay < timerGranularity {
+		lossDelay = timerGranularity
+	}
+	lostSendTime := now.Add(-lossDelay)
+	s.lossTime = time.Time{}
+	var lost []*sentPacket
+	kept := s.sent[:0]
+	for _, p := range s.sent {
+		if p.num > s.largestAcked {
+			kept = append(kept, p)
+			continue
+		}
+		if !p.time.After(lostSendTime) || s.largestAcked >= p.num+packetThreshold {
+			lost = append(lost, p)
+			continue
+		}
+		kept = append(kept, p)
+		if t := p.time.Add(lossDelay); s.lossTime.IsZero() || t.Before(s.lossTime) {
+			s.lossTime = t
+		}
+	}
+	for i := len(kept); i < len(s.sent); i++ {
+		s.sent[i] = nil
+	}
+	s.sent = kept
+	if len(lost) == 0 {
+		return
+	}
+	for _, p := range lost {
+		if p.inFlight {
+			l.bytesInFlight -= p.size
+		}
+		for _, f := range p.frames {
+			c.onFrameLost(space, f)
+		}
+	}
+	// Enter recovery once per round trip. See RFC 9002, Section 7.3.2.
+	if last := lost[len(lost)-1]; last.time.After(l.recoveryStart) {
+		l.recoveryStart = now
+		l.ssthresh = l.cwnd / 2
+		if l.ssthresh < minCongestionWindow {
+			l.ssthresh = minCongestionWindow
+		}
+		l.cwnd = l.ssthresh
+	}
+}
+
+// onFrameLost schedules the retransmission of a frame's information.
+// See RFC 9000, Section 13.3.
+func (c *Conn) onFrameLost(space numberSpace, f sentFrame) {
+	switch f.typ {
+	case frameTypeCrypto:
+		c.spaces[space].cryptoSend.lost(f.off, f.n)
+	case frameTypeStreamBase:
+		if s := c.streams[f.id]; s != nil && !s.reset {
+			s.send.lost(f.off, f.n)
+			if f.fin && !s.finAcked {
+				s.finPending = true
+			}
+		}
+	case frameTypeResetStream:
+		if s := c.streams[f.id]; s != nil && !s.resetAcked {
+			s.resetPending = true
+		}
+	case frameTypeStopSending:
+		if s := c.streams[f.id]; s != nil && s.recvErr == nil && s.finalSize < 0 {
+			s.stopSendingPending = true
+		}
+	case frameTypeMaxStreamData:
+		if s := c.streams[f.id]; s != nil && s.finalSize < 0 {
+			s.maxStreamDataPending = true
+		}
+	case frameTypeMaxData:
+		c.maxDataPending = true
+	case frameTypeMaxStreamsBidi:
+		c.maxStreamsPending[bidiStream] = true
+	case frameTypeMaxStreamsUni:
+		c.maxStreamsPending[uniStream] = true
+	case frameTypeHandshakeDone:
+		c.handshakeDonePending = true
+	}
+}
+
+// lossTimer returns the time of the loss detection timer, and the
+// space it applies to. See RFC 9002, Section 6.2 and Appendix A.8.
+func (c *Conn) lossTimer(now time.Time) (time.Time, numberSpace) {
+	var t time.Time
+	var space numberSpace
+	for i := range c.spaces {
+		s := &c.spaces[i]
+		if !s.lossTime.IsZero() && (t.IsZero() || s.lossTime.Before(t)) {
+			t, space = s.lossTime, numberSpace(i)
+		}
+	}
+	if !t.IsZero() {
+		return t, space
+	}
+	if c.side == serverSide && !c.addrValidated && c.bytesSent >= 3*c.bytesRecv {
+		// Blocked by the anti-amplification limit.
+		return time.Time{}, 0
+	}
+	backoff := c.loss.ptoCount
+	if backoff > maxPTOBackoff {
+		backoff = maxPTOBackoff
+	}
+	inFlight := false
+	for i := range c.spaces {
+		s := &c.spaces[i]
+		if len(s.sent) == 0 {
+			continue
+		}
+		inFlight = true
+		if numberSpace(i) == appDataSpace && !c.handshakeConfirmed {
+			continue
+		}
+		pt := s.lastAckElicitingTime.Add(c.ptoPeriod(numberSpace(i)) << backoff)
+		if t.IsZero() || pt.Before(t) {
+			t, space = pt, numberSpace(i)
+		}
+	}
+	if !inFlight && c.side == clientSide && !c.handshakeConfirmed {
+		// The client must probe to prevent a deadlock when the
+		// server's packets are lost. See RFC 9002, Section 6.2.2.1.
+		space = initialSpace
+		if c.spaces[handshakeSpace].wkeys != nil {
+			space = handshakeSpace
+		}
+		start := c.loss.lastAckElicitingTime
+		if start.IsZero() {
+			start = now
+		}
+		t = start.Add(c.ptoPeriod(space) << backoff)
+	}
+	return t, space
+}
+
+// onLossTimer handles expiry of the loss detection timer.
+func (c *Conn) onLossTimer(now time.Time) {
+	_, space := c.lossTimer(now)
+	if !c.spaces[space].lossTime.IsZero() {
+		c.detectLostPackets(now, space)
+		return
+	}
+	// Probe timeout: send one or two ack-eliciting packets, repeating
+	// unacknowledged data. See RFC 9002, Section 6.2.4.
+	c.loss.ptoCount++
+	s := &c.spaces[space]
+	if space == initialSpace && c.side == clientSide && len(s.sent) == 0 && s.wkeys == nil {
+		return
+	}
+	s.probe = 2
+	for _, p := range s.sent {
+		for _, f := range p.frames {
+			c.onFrameLost(space, f)
+		}
+	}
+	if len(s.sent) == 0 {
+		// Anti-deadlock probe; the space has nothing in flight.
+		s.probe = 1
+	}
+	// Keep the timer from firing again immediately.
+	s.lastAckElicitingTime = now
+	c.loss.lastAckElicitingTime = now
+}
@@ -0,0 +1,375 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"crypto/tls"
+	"errors"
+	"time"
+)
+
+// handleDatagram processes a datagram received from the peer.
+func (c *Conn) handleDatagram(b []byte, now time.Time) {
+	if c.state == connClosed || c.state == connDraining {
+		return
+	}
+	c.bytesRecv += int64(len(b))
+	for len(b) > 0 {
+		n := c.handlePacket(b, now)
+		if n <= 0 {
+			return
+		}
+		b = b[n:]
+	}
+}
+
+// handlePacket processes the packet at the start of b, and returns its
+// length, or -1 if the rest of the datagram should be discarded.
+func (c *Conn) handlePacket(b []byte, now time.Time) int {
+	var (
+		space  numberSpace
+		pnOff  int
+		end    int
+		srcID  []byte
+		isLong = b[0]&headerFormLong != 0
+	)
+	if b[0]&fixedBit == 0 {
+		return -1
+	}
+	if isLong {
+		h, ok := parseLongHeader(b)
+		if !ok || h.version != quicVersion1 {
+			return -1
+		}
+		switch h.typ {
+		case longTypeInitial:
+			space = initialSpace
+		case longTypeHandshake:
+			space = handshakeSpace
+		case longType0RTT:
+			return h.end // 0-RTT is not supported
+		default:
+			return -1 // Retry is not supported
+		}
+		if string(h.dstConnID) != string(c.localConnID) && !(c.side == serverSide && string(h.dstConnID) == string(c.origDstConnID)) {
+			return h.end
+		}
+		if c.side == serverSide && string(h.srcConnID) != string(c.remoteConnID) {
+			return h.end
+		}
+		pnOff, end, srcID = h.pnOff, h.end, h.srcConnID
+	} else {
+		space = appDataSpace
+		if len(b) < 1+connIDLen || string(b[1:1+connIDLen]) != string(c.localConnID) {
+			return -1
+		}
+		pnOff, end = 1+connIDLen, len(b)
+	}
+	s := &c.spaces[space]
+	if s.rkeys == nil {
+		return end
+	}
+	pkt := b[:end]
+	truncated, pnLen, ok := s.rkeys.removeHeaderProtection(pkt, pnOff)
+	if !ok {
+		return end
+	}
+	pnum := decodePacketNumber(s.recvd.max(), truncated, pnLen)
+	var payload []byte
+	var err error
+	keyUpdate := false
+	if space == appDataSpace && (pkt[0]&keyPhaseBit != 0) != c.keyPhase {
+		// The peer initiated a key update. See RFC 9001, Section 6.2.
+		// Packets from the previous key phase are not decryptable once
+		// the update completes, and are treated as lost by the peer.
+		nextSecret := nextTrafficSecret(c.appSuite, c.appReadSecret)
+		keys := newPacketKeys(c.appSuite, nextSecret)
+		payload, err = keys.open(pkt, pnOff+pnLen, pnum)
+		if err == nil {
+			keyUpdate = true
+			c.appReadSecret = nextSecret
+			s.rkeys = keys
+		}
+	} else {
+		payload, err = s.rkeys.open(pkt, pnOff+pnLen, pnum)
+	}
+	if err != nil {
+		return end
+	}
+	if isLong && pkt[0]&longReserved != 0 || !isLong && pkt[0]&shortReserved != 0 {
+		c.abort(now, newTransportError(errProtocolViolation, "reserved header bits set"))
+		return -1
+	}
+	if keyUpdate {
+		c.keyPhase = !c.keyPhase
+		c.appWriteSecret = nextTrafficSecret(c.appSuite, c.appWriteSecret)
+		c.spaces[appDataSpace].wkeys = newPacketKeys(c.appSuite, c.appWriteSecret)
+	}
+	if len(s.recvd) > 0 && (pnum < s.recvd.min() || s.recvd.contains(pnum)) {
+		return end // duplicate
+	}
+	if c.state == connClosing {
+		// Look only for CONNECTION_CLOSE, and otherwise repeat ours.
+		// See RFC 9000, Section 10.2.1.
+		if closeErr := findConnectionClose(payload); closeErr != nil {
+			c.enterDraining(now, closeErr)
+		} else {
+			c.closeSend = true
+		}
+		return end
+	}
+
+	if c.side == clientSide && isLong && !c.gotRemoteID {
+		// The client uses the connection ID chosen by the server.
+		// See RFC 9000, Section 7.2.
+		c.remoteConnID = append([]byte(nil), srcID...)
+		c.gotRemoteID = true
+	}
+	if c.side == serverSide && space == handshakeSpace {
+		// Receiving a Handshake packet validates the client's address,
+		// and the server discards Initial keys. See RFC 9001, Section 4.9.1.
+		c.addrValidated = true
+		c.discardKeys(initialSpace)
+	}
+
+	ackEliciting, err := c.handleFrames(now, space, payload)
+	if err != nil {
+		c.abort(now, err)
+		return -1
+	}
+	if c.state != connActive {
+		return -1
+	}
+	if s.discarded {
+		return end
+	}
+
+	s.recvd.add(pnum, pnum+1)
+	if len(s.recvd) > 64 {
+		s.recvd.removeBefore(s.recvd[len(s.recvd)-64].start)
+	}
+	if pnum == s.recvd.max() {
+		s.largestRecvTime = now
+	}
+	s.ackNeeded = true
+	if ackEliciting {
+		if !s.ackPending {
+			s.ackDeadline = now.Add(maxAckDelay)
+		}
+		s.ackPending = true
+		s.unackedEliciting++
+		if pnum != s.recvd.max() {
+			// Acknowledge out-of-order packets immediately.
+			// See RFC 9000, Section 13.2.1.
+			s.ackDeadline = now
+		}
+	}
+	c.idleTime = now
+	c.sentSince = false
+	return end
+}
+
+// maxAckDelay is the maximum time we delay sending acknowledgements.
+const maxAckDelay = 25 * time.Millisecond
+
+// findConnectionClose returns the error from a CONNECTION_CLOSE frame in
+// payload, or nil if there is none.
+func findConnectionClose(payload []byte) error {
+	for len(payload) > 0 {
+		n := frameLength(payload)
+		if n < 0 {
+			return nil
+		}
+		switch payload[0] {
+		case frameTypeConnectionCloseTransport, frameTypeConnectionCloseApplication:
+			return connectionCloseError(payload)
+		}
+		payload = payload[n:]
+	}
+	return nil
+}
+
+func connectionCloseError(b []byte) error {
+	code, reason, n := consumeConnectionCloseFrame(b)
+	if n < 0 {
+		return nil
+	}
+	if b[0] == frameTypeConnectionCloseApplication {
+		return &ApplicationError{Code: code, Reason: reason}
+	}
+	return &TransportError{Code: code, Reason: reason, Remote: true}
+}
+
+// frameLength returns the length of the frame at the start of b,
+// or -1 if it is malformed.
+func frameLength(b []byte) int {
+	var a, bb, cc uint64
+	switch typ := b[0]; {
+	case typ == frameTypePadding, typ == frameTypePing, typ == frameTypeHandshakeDone:
+		return 1
+	case typ == frameTypeAck, typ == frameTypeAckECN:
+		_, _, n := consumeAckFrame(b, func(start, end int64) {})
+		return n
+	case typ == frameTypeResetStream:
+		return consumeVarintFields(b, &a, &bb, &cc)
+	case typ == frameTypeStopSending, typ == frameTypeMaxStreamData, typ == frameTypeStreamDataBlocked:
+		return consumeVarintFields(b, &a, &bb)
+	case typ == frameTypeCrypto:
+		_, _, n := consumeCryptoFrame(b)
+		return n
+	case typ == frameTypeNewToken:
+		_, n := consumeVarintBytes(b[1:])
+		if n < 0 {
+			return -1
+		}
+		return 1 + n
+	case typ >= frameTypeStreamBase && typ < frameTypeStreamBase+8:
+		_, _, _, _, n := consumeStreamFrame(b)
+		return n
+	case typ >= frameTypeMaxData && typ <= frameTypeStreamsBlockedUni,
+		typ == frameTypeRetireConnectionID:
+		return consumeVarintFields(b, &a)
+	case typ == frameTypeNewConnectionID:
+		return consumeNewConnectionIDFrame(b)
+	case typ == frameTypePathChallenge, typ == frameTypePathResponse:
+		if len(b) < 9 {
+			return -1
+		}
+		return 9
+	case typ == frameTypeConnectionCloseTransport, typ == frameTypeConnectionCloseApplication:
+		_, _, n := consumeConnectionCloseFrame(b)
+		return n
+	}
+	return -1
+}
+
+// handleFrames processes the frames in a packet payload.
+// It reports whether the packet was ack-eliciting.
+func (c *Conn) handleFrames(now time.Time, space numberSpace, payload []byte) (ackEliciting bool, err error) {
+	if len(payload) == 0 {
+		return false, newTransportError(errProtocolViolation, "packet with no frames")
+	}
+	for len(payload) > 0 {
+		typ := payload[0]
+		n := frameLength(payload)
+		if n < 0 {
+			return false, newTransportError(errFrameEncoding, "malformed frame of type %#x", typ)
+		}
+		frame := payload[:n]
+		payload = payload[n:]
+		if space != appDataSpace {
+			// See RFC 9000, Section 12.4, Table 3.
+			switch typ {
+			case frameTypePadding, frameTypePing, frameTypeAck, frameTypeAckECN,
+				frameTypeCrypto, frameTypeConnectionCloseTransport:
+			default:
+				return false, newTransportError(errProtocolViolation, "frame type %#x not allowed in %v packet", typ, space)
+			}
+		}
+		switch typ {
+		case frameTypePadding, frameTypeAck, frameTypeAckECN,
+			frameTypeConnectionCloseTransport, frameTypeConnectionCloseApplication:
+		default:
+			ackEliciting = true
+		}
+		switch {
+		case typ == frameTypePadding, typ == frameTypePing:
+		case typ == frameTypeAck, typ == frameTypeAckECN:
+			err = c.handleAckFrame(now, space, frame)
+		case typ == frameTypeCrypto:
+			err = c.handleCryptoFrame(now, space, frame)
+		case typ == frameTypeConnectionCloseTransport, typ == frameTypeConnectionCloseApplication:
+			c.enterDraining(now, connectionCloseError(frame))
+			return ackEliciting, nil
+		case typ == frameTypeHandshakeDone:
+			if c.side == serverSide {
+				return false, newTransportError(errProtocolViolation, "client sent HANDSHAKE_DONE")
+			}
+			if !c.handshakeConfirmed {
+				c.handshakeConfirmed = true
+				c.discardKeys(handshakeSpace)
+			}
+		case typ == frameTypeNewToken:
+			if c.side == serverSide {
+				return false, newTransportError(errProtocolViolation, "client sent NEW_TOKEN")
+			}
+		case typ >= frameTypeStreamBase && typ < frameTypeStreamBase+8:
+			id, off, fin, data, _ := consumeStreamFrame(frame)
+			err = c.handleStreamFrame(id, off, data, fin)
+		case typ == frameTypeResetStream:
+			var id, code, size uint64
+			consumeVarintFields(frame, &id, &code, &size)
+			err = c.handleResetStreamFrame(int64(id), code, int64(size))
+		case typ == frameTypeStopSending:
+			var id, code uint64
+			consumeVarintFields(frame, &id, &code)
+			err = c.handleStopSendingFrame(int64(id), code)
+		case typ == frameTypeMaxData:
+			var v uint64
+			consumeVarintFields(frame, &v)
+			if int64(v) > c.peerMaxData {
+				c.peerMaxData = int64(v)
+			}
+		case typ == frameTypeMaxStreamData:
+			var id, v uint64
+			consumeVarintFields(frame, &id, &v)
+			err = c.handleMaxStreamDataFrame(int64(id), int64(v))
+		case typ == frameTypeMaxStreamsBidi, typ == frameTypeMaxStreamsUni:
+			var v uint64
+			consumeVarintFields(frame, &v)
+			if v > 1<<60 {
+				return false, newTransportError(errFrameEncoding, "MAX_STREAMS too large")
+			}
+			styp := bidiStream
+			if typ == frameTypeMaxStreamsUni {
+				styp = uniStream
+			}
+			if int64(v) > c.peerMaxStreams[styp] {
+				c.peerMaxStreams[styp] = int64(v)
+				c.streamsChanged.signal()
+			}
+		case typ == frameTypeDataBlocked, typ == frameTypeStreamDataBlocked,
+			typ == frameTypeStreamsBlockedBidi, typ == frameTypeStreamsBlockedUni:
+			// Informational only.
+		case typ == frameTypeNewConnectionID, typ == frameTypeRetireConnectionID:
+			// Alternate connection IDs are not used, since migration
+			// is not supported.
+		case typ == frameTypePathChallenge:
+			// Respond on the same path; since migration is not supported,
+			// there is only one path. The response is not retransmitted.
+			c.pendingPathResponse = append(c.pendingPathResponse[:0], frame[1:9]...)
+		case typ == frameTypePathResponse:
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return ackEliciting, nil
+}
+
+// handleCryptoFrame processes a CRYPTO frame.
+func (c *Conn) handleCryptoFrame(now time.Time, space numberSpace, frame []byte) error {
+	off, data, _ := consumeCryptoFrame(frame)
+	s := &c.spaces[space]
+	const maxCryptoBuffer = 64 << 10
+	if off+int64(len(data))-s.cryptoRecv.off > maxCryptoBuffer {
+		return newTransportError(errCryptoBufferExceeded, "too much buffered crypto data")
+	}
+	s.cryptoRecv.write(off, data)
+	b := s.cryptoRecv.peekAll()
+	if len(b) == 0 {
+		return nil
+	}
+	err := c.tls.HandleData(spaceLevel(space), b)
+	s.cryptoRecv.consume(len(b))
+	if err != nil {
+		var alert tls.AlertError
+		if errors.As(err, &alert) {
+			return &TransportError{Code: uint64(errTLSBase) + uint64(alert), Reason: err.Error()}
+		}
+		return newTransportError(errInternal, "%v", err)
+	}
+	return c.handleTLSEvents(now)
+}
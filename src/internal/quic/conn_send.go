@@ -0,0 +1,250 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"time"
+)
+
+// An outPacket is a packet being assembled into a datagram.
+type outPacket struct {
+	space   numberSpace
+	payload []byte
+	sp      *sentPacket // nil if the packet is not ack-eliciting
+}
+
+// sendPackets sends datagrams until there is nothing more to send, or
+// congestion control or the anti-amplification limit prevents it.
+func (c *Conn) sendPackets(now time.Time) {
+	for c.state == connActive || c.state == connClosing {
+		d := c.appendDatagram(now)
+		if d == nil {
+			return
+		}
+		c.bytesSent += int64(len(d))
+		c.ep.writeTo(d, c.peerAddr)
+	}
+}
+
+// appendDatagram assembles the next datagram to send, coalescing packets
+// from each number space. It returns nil if there is nothing to send.
+func (c *Conn) appendDatagram(now time.Time) []byte {
+	limit := maxDatagramSize
+	if c.side == serverSide && !c.addrValidated {
+		// See RFC 9000, Section 8.1.
+		if allowed := 3*c.bytesRecv - c.bytesSent; allowed < int64(limit) {
+			limit = int(allowed)
+		}
+	}
+	var pkts []outPacket
+	remaining := limit
+	for space := initialSpace; space < numberSpaceCount; space++ {
+		s := &c.spaces[space]
+		if s.wkeys == nil || s.discarded {
+			continue
+		}
+		avail := remaining - c.headerSize(space) - aeadOverhead
+		if avail < 32 {
+			break
+		}
+		var payload []byte
+		var sp *sentPacket
+		if c.state == connClosing {
+			if !c.closeSend {
+				return nil
+			}
+			payload = c.closeFrame
+			if c.closeApp && space != appDataSpace {
+				// Application closes are only sent in 1-RTT packets.
+				// See RFC 9000, Section 10.2.3.
+				payload = appendConnectionCloseFrame(nil, false, uint64(errApplicationError), "")
+			}
+			if len(payload) > avail {
+				continue
+			}
+		} else {
+			payload, sp = c.appendFrames(now, space, avail)
+			if len(payload) == 0 {
+				continue
+			}
+		}
+		pkts = append(pkts, outPacket{space: space, payload: payload, sp: sp})
+		remaining -= c.headerSize(space) + len(payload) + aeadOverhead
+	}
+	if c.state == connClosing {
+		c.closeSend = false
+	}
+	if len(pkts) == 0 {
+		return nil
+	}
+
+	// Datagrams containing Initial packets sent by clients, or
+	// ack-eliciting Initial packets sent by servers, must be at least
+	// 1200 bytes. See RFC 9000, Section 14.1.
+	if pkts[0].space == initialSpace && (c.side == clientSide || pkts[0].sp != nil) {
+		if size := limit - remaining; size < limit {
+			last := &pkts[len(pkts)-1]
+			last.payload = append(last.payload, make([]byte, limit-size)...)
+		}
+	}
+
+	if cap(c.sendBuf) < maxDatagramSize+aeadOverhead {
+		c.sendBuf = make([]byte, 0, maxDatagramSize+aeadOverhead)
+	}
+	b := c.sendBuf[:0]
+	sentHandshake := false
+	eliciting := false
+	for _, p := range pkts {
+		s := &c.spaces[p.space]
+		pnum := s.nextNum
+		s.nextNum++
+		start := len(b)
+		var pnOff int
+		switch p.space {
+		case appDataSpace:
+			b = appendShortHeader(b, c.remoteConnID, pnum, c.keyPhase)
+			pnOff = len(b) - packetNumberLen
+		default:
+			typ := byte(longTypeInitial)
+			if p.space == handshakeSpace {
+				typ = longTypeHandshake
+				sentHandshake = true
+			}
+			var lenOff int
+			b, lenOff = appendLongHeader(b, typ, c.remoteConnID, c.localConnID, pnum)
+			length := packetNumberLen + len(p.payload) + aeadOverhead
+			b[lenOff] = 0x40 | byte(length>>8)
+			b[lenOff+1] = byte(length)
+			pnOff = lenOff + 2
+		}
+		b = append(b, p.payload...)
+		pkt := s.wkeys.protect(b[start:], pnOff-start, pnum)
+		b = append(b[:start], pkt...)
+		if p.sp != nil {
+			p.sp.num = pnum
+			p.sp.size = len(pkt)
+			c.onPacketSent(now, p.space, p.sp)
+			eliciting = true
+		}
+	}
+	c.sendBuf = b[:0]
+	if sentHandshake && c.side == clientSide {
+		// See RFC 9001, Section 4.9.1.
+		c.discardKeys(initialSpace)
+	}
+	if eliciting && !c.sentSince {
+		// See RFC 9000, Section 10.1.
+		c.idleTime = now
+		c.sentSince = true
+	}
+	return b
+}
+
+func (c *Conn) headerSize(space numberSpace) int {
+	switch space {
+	case initialSpace:
+		return longHeaderSize(longTypeInitial, c.remoteConnID, c.localConnID)
+	case handshakeSpace:
+		return longHeaderSize(longTypeHandshake, c.remoteConnID, c.localConnID)
+	}
+	return shortHeaderSize(c.remoteConnID)
+}
+
+// appendFrames returns the payload of the next packet to send in a
+// number space, up to max bytes long. It returns a nil payload if there
+// is nothing to send, and a nil *sentPacket if the packet is not
+// ack-eliciting.
+func (c *Conn) appendFrames(now time.Time, space numberSpace, max int) ([]byte, *sentPacket) {
+	s := &c.spaces[space]
+	ackDue := s.ackPending && (space != appDataSpace || s.unackedEliciting >= 2 || !now.Before(s.ackDeadline))
+	canSend := s.probe > 0 || !c.congestionLimited()
+	if !ackDue && !canSend {
+		return nil, nil
+	}
+	b := make([]byte, 0, max)
+	ackAdded := false
+	if s.ackNeeded {
+		delay := now.Sub(s.largestRecvTime).Microseconds()
+		if delay < 0 {
+			delay = 0
+		}
+		b, ackAdded = appendAckFrame(b, s.recvd, uint64(delay), max)
+	}
+	start := len(b)
+	sp := &sentPacket{}
+	if canSend {
+		b = c.appendElicitingFrames(space, b, max, sp)
+		if len(b) == start && s.probe > 0 {
+			b = append(b, frameTypePing)
+		}
+	}
+	eliciting := len(b) > start
+	if !eliciting && !ackDue {
+		return nil, nil
+	}
+	if ackAdded {
+		s.ackNeeded = false
+		s.ackPending = false
+		s.unackedEliciting = 0
+	}
+	if !eliciting {
+		return b, nil
+	}
+	if s.probe > 0 {
+		s.probe--
+	}
+	return b, sp
+}
+
+// appendElicitingFrames appends ack-eliciting frames to b.
+func (c *Conn) appendElicitingFrames(space numberSpace, b []byte, max int, sp *sentPacket) []byte {
+	s := &c.spaces[space]
+	if space == appDataSpace {
+		if c.handshakeDonePending && len(b)+1 <= max {
+			b = append(b, frameTypeHandshakeDone)
+			c.handshakeDonePending = false
+			sp.frames = append(sp.frames, sentFrame{typ: frameTypeHandshakeDone})
+		}
+		if c.pendingPathResponse != nil && len(b)+9 <= max {
+			b = append(b, frameTypePathResponse)
+			b = append(b, c.pendingPathResponse...)
+			c.pendingPathResponse = nil
+		}
+		if c.maxDataPending && len(b)+sizeVarintFrame(uint64(c.maxData)) <= max {
+			b = appendVarintFrame(b, frameTypeMaxData, uint64(c.maxData))
+			c.maxDataPending = false
+			sp.frames = append(sp.frames, sentFrame{typ: frameTypeMaxData})
+		}
+		for typ, frameType := range [2]byte{frameTypeMaxStreamsBidi, frameTypeMaxStreamsUni} {
+			if c.maxStreamsPending[typ] && len(b)+sizeVarintFrame(uint64(c.maxStreams[typ])) <= max {
+				b = appendVarintFrame(b, frameType, uint64(c.maxStreams[typ]))
+				c.maxStreamsPending[typ] = false
+				sp.frames = append(sp.frames, sentFrame{typ: frameType})
+			}
+		}
+	}
+	for s.cryptoSend.hasUnsent(maxVarint) {
+		avail := max - len(b) - cryptoFrameOverhead(s.cryptoSend.unsent[0].start)
+		if avail <= 0 {
+			break
+		}
+		off, data := s.cryptoSend.next(avail, maxVarint)
+		b = appendCryptoFrame(b, off, data)
+		sp.frames = append(sp.frames, sentFrame{typ: frameTypeCrypto, off: off, n: int64(len(data))})
+	}
+	if space == appDataSpace {
+		for _, st := range c.streams {
+			if len(b) >= max {
+				break
+			}
+			b = st.appendFrames(b, max, sp)
+		}
+		if c.pingPending && len(b) < max {
+			b = append(b, frameTypePing)
+			c.pingPending = false
+		}
+	}
+	return b
+}
@@ -0,0 +1,271 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+var errEndpointClosed = errors.New("quic: endpoint closed")
+
+// An Endpoint handles QUIC traffic on a network address.
+// It can accept inbound connections or create outbound ones.
+//
+// Multiple goroutines may invoke methods on an Endpoint simultaneously.
+type Endpoint struct {
+	pc     net.PacketConn
+	config *Config // for accepted connections; nil if not accepting
+	donec  chan struct{}
+
+	mu            sync.Mutex
+	conns         map[string]*Conn // by local connection ID
+	acceptq       []*Conn
+	acceptChanged broadcaster
+	closed        bool
+	pcClosed      bool
+	closeWhenIdle bool // close after the last connection closes
+}
+
+// Listen listens on a local network address.
+// The configuration config is used to accept inbound connections;
+// it may be nil if the endpoint is only used to dial.
+func Listen(network, address string, config *Config) (*Endpoint, error) {
+	pc, err := net.ListenPacket(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewEndpoint(pc, config), nil
+}
+
+// NewEndpoint returns an endpoint sending and receiving on pc,
+// which should not be used by the caller afterwards. Closing the
+// endpoint closes pc.
+// The configuration config is used to accept inbound connections;
+// it may be nil if the endpoint is only used to dial.
+func NewEndpoint(pc net.PacketConn, config *Config) *Endpoint {
+	e := &Endpoint{
+		pc:     pc,
+		config: config,
+		donec:  make(chan struct{}),
+		conns:  make(map[string]*Conn),
+	}
+	go e.readLoop()
+	return e
+}
+
+// Dial creates a connection to address, using a new endpoint listening
+// on an unspecified local address. The endpoint is closed when the
+// connection is closed.
+func Dial(ctx context.Context, network, address string, config *Config) (*Conn, error) {
+	addr, err := net.ResolveUDPAddr(network, address)
+	if err != nil {
+		return nil, err
+	}
+	pc, err := net.ListenPacket(network, ":0")
+	if err != nil {
+		return nil, err
+	}
+	e := NewEndpoint(pc, nil)
+	e.closeWhenIdle = true
+	c, err := e.Dial(ctx, addr, config)
+	if err != nil {
+		e.mu.Lock()
+		idle := len(e.conns) == 0
+		e.mu.Unlock()
+		if idle {
+			e.Close()
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// LocalAddr returns the local network address.
+func (e *Endpoint) LocalAddr() net.Addr {
+	return e.pc.LocalAddr()
+}
+
+// Dial creates a connection to addr and waits for the handshake
+// to complete.
+func (e *Endpoint) Dial(ctx context.Context, addr net.Addr, config *Config) (*Conn, error) {
+	c, err := newConn(clientSide, e, addr, nil, nil, config)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		c.closeTLS()
+		return nil, errEndpointClosed
+	}
+	e.conns[string(c.localConnID)] = c
+	e.mu.Unlock()
+	go c.loop()
+	if err := c.waitReady(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Accept waits for and returns the next connection to the endpoint,
+// after its handshake completes.
+func (e *Endpoint) Accept(ctx context.Context) (*Conn, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for {
+		if len(e.acceptq) > 0 {
+			c := e.acceptq[0]
+			e.acceptq[0] = nil
+			e.acceptq = e.acceptq[1:]
+			return c, nil
+		}
+		if e.closed {
+			return nil, errEndpointClosed
+		}
+		ch := e.acceptChanged.wait()
+		e.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			e.mu.Lock()
+			return nil, ctx.Err()
+		}
+		e.mu.Lock()
+	}
+}
+
+// Close closes all of the endpoint's connections, sending a
+// CONNECTION_CLOSE to each peer, and closes the underlying PacketConn.
+func (e *Endpoint) Close() error {
+	e.mu.Lock()
+	e.closed = true
+	e.acceptChanged.signal()
+	conns := make(map[*Conn]bool)
+	for _, c := range e.conns {
+		conns[c] = true
+	}
+	e.mu.Unlock()
+	now := time.Now()
+	for c := range conns {
+		c.mu.Lock()
+		c.exitNow = true
+		c.abort(now, &ApplicationError{})
+		c.mu.Unlock()
+		c.wake()
+	}
+	for c := range conns {
+		<-c.donec
+	}
+	err := e.closePacketConn()
+	<-e.donec
+	return err
+}
+
+func (e *Endpoint) closePacketConn() error {
+	e.mu.Lock()
+	if e.pcClosed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.pcClosed = true
+	e.mu.Unlock()
+	return e.pc.Close()
+}
+
+func (e *Endpoint) readLoop() {
+	defer close(e.donec)
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := e.pc.ReadFrom(buf)
+		if err != nil {
+			e.mu.Lock()
+			closed := e.pcClosed
+			e.mu.Unlock()
+			if closed || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// Errors such as ICMP port unreachable notifications
+			// are not fatal to the endpoint.
+			continue
+		}
+		if n == 0 {
+			continue
+		}
+		e.handleDatagram(append([]byte(nil), buf[:n]...), addr)
+	}
+}
+
+func (e *Endpoint) handleDatagram(b []byte, addr net.Addr) {
+	id, ok := dstConnIDForDatagram(b)
+	if !ok {
+		return
+	}
+	e.mu.Lock()
+	c := e.conns[string(id)]
+	accepting := e.config != nil && !e.closed
+	e.mu.Unlock()
+	if c != nil {
+		c.deliver(b)
+		return
+	}
+	if !accepting || len(b) < maxDatagramSize {
+		// Clients must pad Initial packets. See RFC 9000, Section 14.1.
+		return
+	}
+	h, ok := parseLongHeader(b)
+	if !ok || h.version != quicVersion1 || h.typ != longTypeInitial || len(h.dstConnID) < 8 {
+		return
+	}
+	c, err := newConn(serverSide, e, addr, h.dstConnID, h.srcConnID, e.config)
+	if err != nil {
+		return
+	}
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		c.closeTLS()
+		return
+	}
+	e.conns[string(c.localConnID)] = c
+	e.conns[string(c.origDstConnID)] = c
+	e.mu.Unlock()
+	go c.loop()
+	c.deliver(b)
+}
+
+// queueAccept makes a connection available to Accept.
+func (e *Endpoint) queueAccept(c *Conn) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.acceptq = append(e.acceptq, c)
+	e.acceptChanged.signal()
+}
+
+// removeConn removes a connection after it is closed.
+func (e *Endpoint) removeConn(c *Conn) {
+	e.mu.Lock()
+	for _, id := range [][]byte{c.localConnID, c.origDstConnID} {
+		if e.conns[string(id)] == c {
+			delete(e.conns, string(id))
+		}
+	}
+	idle := e.closeWhenIdle && len(e.conns) == 0
+	if idle {
+		e.closed = true
+	}
+	e.mu.Unlock()
+	if idle {
+		e.closePacketConn()
+	}
+}
+
+func (e *Endpoint) writeTo(b []byte, addr net.Addr) {
+	// Write errors are treated as packet loss.
+	e.pc.WriteTo(b, addr)
+}
@@ -0,0 +1,312 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+// Frame types. See RFC 9000, Section 19.
+const (
+	frameTypePadding                    = 0x00
+	frameTypePing                       = 0x01
+	frameTypeAck                        = 0x02
+	frameTypeAckECN                     = 0x03
+	frameTypeResetStream                = 0x04
+	frameTypeStopSending                = 0x05
+	frameTypeCrypto                     = 0x06
+	frameTypeNewToken                   = 0x07
+	frameTypeStreamBase                 = 0x08 // low three bits carry flags
+	frameTypeMaxData                    = 0x10
+	frameTypeMaxStreamData              = 0x11
+	frameTypeMaxStreamsBidi             = 0x12
+	frameTypeMaxStreamsUni              = 0x13
+	frameTypeDataBlocked                = 0x14
+	frameTypeStreamDataBlocked          = 0x15
+	frameTypeStreamsBlockedBidi         = 0x16
+	frameTypeStreamsBlockedUni          = 0x17
+	frameTypeNewConnectionID            = 0x18
+	frameTypeRetireConnectionID         = 0x19
+	frameTypePathChallenge              = 0x1a
+	frameTypePathResponse               = 0x1b
+	frameTypeConnectionCloseTransport   = 0x1c
+	frameTypeConnectionCloseApplication = 0x1d
+	frameTypeHandshakeDone              = 0x1e
+)
+
+// STREAM frame flags.
+const (
+	streamFinBit = 0x01
+	streamLenBit = 0x02
+	streamOffBit = 0x04
+)
+
+// ackDelayExponent is the exponent used to encode ACK delays.
+// It is the default value, and is not sent in transport parameters.
+const ackDelayExponent = 3
+
+// appendAckFrame appends an ACK frame acknowledging the packet numbers
+// in seen. ackDelay is in microseconds. Only as many of the most recent
+// ranges as fit in maxLen bytes are included; the frame is not appended
+// if even the largest range does not fit.
+func appendAckFrame(b []byte, seen rangeSet, ackDelay uint64, maxLen int) ([]byte, bool) {
+	if len(seen) == 0 {
+		return b, false
+	}
+	last := seen[len(seen)-1]
+	largest := uint64(last.end - 1)
+	delay := ackDelay >> ackDelayExponent
+	// Count how many ranges fit.
+	size := 1 + sizeVarint(largest) + sizeVarint(delay) + 1 + sizeVarint(uint64(last.size()-1))
+	if size > maxLen {
+		return b, false
+	}
+	count := 0
+	for i := len(seen) - 2; i >= 0 && count < 62; i-- {
+		gap := uint64(seen[i+1].start - seen[i].end - 1)
+		rlen := uint64(seen[i].size() - 1)
+		n := sizeVarint(gap) + sizeVarint(rlen)
+		if size+n > maxLen {
+			break
+		}
+		size += n
+		count++
+	}
+	b = append(b, frameTypeAck)
+	b = appendVarint(b, largest)
+	b = appendVarint(b, delay)
+	b = appendVarint(b, uint64(count))
+	b = appendVarint(b, uint64(last.size()-1))
+	for i := len(seen) - 2; i >= len(seen)-1-count; i-- {
+		b = appendVarint(b, uint64(seen[i+1].start-seen[i].end-1))
+		b = appendVarint(b, uint64(seen[i].size()-1))
+	}
+	return b, true
+}
+
+// consumeAckFrame parses an ACK frame (including the type byte),
+// calling f for each acknowledged range from largest to smallest.
+// It returns the largest acknowledged packet number, the encoded
+// ACK delay, and the number of bytes consumed, or n < 0 on error.
+func consumeAckFrame(b []byte, f func(start, end int64)) (largest int64, ackDelay uint64, n int) {
+	typ := b[0]
+	n = 1
+	v, m := consumeVarint(b[n:])
+	if m < 0 {
+		return 0, 0, -1
+	}
+	n += m
+	largest = int64(v)
+	ackDelay, m = consumeVarint(b[n:])
+	if m < 0 {
+		return 0, 0, -1
+	}
+	n += m
+	count, m := consumeVarint(b[n:])
+	if m < 0 {
+		return 0, 0, -1
+	}
+	n += m
+	first, m := consumeVarint(b[n:])
+	if m < 0 || first > uint64(largest) {
+		return 0, 0, -1
+	}
+	n += m
+	smallest := largest - int64(first)
+	f(smallest, largest+1)
+	for i := uint64(0); i < count; i++ {
+		gap, m := consumeVarint(b[n:])
+		if m < 0 {
+			return 0, 0, -1
+		}
+		n += m
+		rlen, m := consumeVarint(b[n:])
+		if m < 0 {
+			return 0, 0, -1
+		}
+		n += m
+		hi := smallest - int64(gap) - 2
+		if hi < 0 || uint64(hi) < rlen {
+			return 0, 0, -1
+		}
+		smallest = hi - int64(rlen)
+		f(smallest, hi+1)
+	}
+	if typ == frameTypeAckECN {
+		for i := 0; i < 3; i++ {
+			_, m := consumeVarint(b[n:])
+			if m < 0 {
+				return 0, 0, -1
+			}
+			n += m
+		}
+	}
+	return largest, ackDelay, n
+}
+
+// consumeVarintFields parses count varints following a frame type byte.
+func consumeVarintFields(b []byte, fields ...*uint64) (n int) {
+	n = 1
+	for _, f := range fields {
+		v, m := consumeVarint(b[n:])
+		if m < 0 {
+			return -1
+		}
+		*f = v
+		n += m
+	}
+	return n
+}
+
+// consumeCryptoFrame parses a CRYPTO frame.
+func consumeCryptoFrame(b []byte) (off int64, data []byte, n int) {
+	n = 1
+	v, m := consumeVarint(b[n:])
+	if m < 0 {
+		return 0, nil, -1
+	}
+	n += m
+	data, m = consumeVarintBytes(b[n:])
+	if m < 0 || v+uint64(len(data)) > maxVarint {
+		return 0, nil, -1
+	}
+	return int64(v), data, n + m
+}
+
+// consumeStreamFrame parses a STREAM frame.
+func consumeStreamFrame(b []byte) (id, off int64, fin bool, data []byte, n int) {
+	typ := b[0]
+	n = 1
+	v, m := consumeVarint(b[n:])
+	if m < 0 {
+		return 0, 0, false, nil, -1
+	}
+	n += m
+	id = int64(v)
+	if typ&streamOffBit != 0 {
+		v, m := consumeVarint(b[n:])
+		if m < 0 {
+			return 0, 0, false, nil, -1
+		}
+		n += m
+		off = int64(v)
+	}
+	if typ&streamLenBit != 0 {
+		data, m = consumeVarintBytes(b[n:])
+		if m < 0 {
+			return 0, 0, false, nil, -1
+		}
+		n += m
+	} else {
+		data = b[n:]
+		n = len(b)
+	}
+	if uint64(off)+uint64(len(data)) > maxVarint {
+		return 0, 0, false, nil, -1
+	}
+	return id, off, typ&streamFinBit != 0, data, n
+}
+
+// consumeConnectionCloseFrame parses a CONNECTION_CLOSE frame.
+func consumeConnectionCloseFrame(b []byte) (code uint64, reason string, n int) {
+	typ := b[0]
+	n = 1
+	code, m := consumeVarint(b[n:])
+	if m < 0 {
+		return 0, "", -1
+	}
+	n += m
+	if typ == frameTypeConnectionCloseTransport {
+		_, m := consumeVarint(b[n:]) // frame type
+		if m < 0 {
+			return 0, "", -1
+		}
+		n += m
+	}
+	r, m := consumeVarintBytes(b[n:])
+	if m < 0 {
+		return 0, "", -1
+	}
+	return code, string(r), n + m
+}
+
+// consumeNewConnectionIDFrame parses a NEW_CONNECTION_ID frame.
+// Only the frame's length is needed, since alternate connection IDs
+// are not used.
+func consumeNewConnectionIDFrame(b []byte) (n int) {
+	var seq, retire uint64
+	n = consumeVarintFields(b, &seq, &retire)
+	if n < 0 || len(b) < n+1 {
+		return -1
+	}
+	idLen := int(b[n])
+	n++
+	if idLen < 1 || idLen > maxConnIDLen || len(b) < n+idLen+16 || retire > seq {
+		return -1
+	}
+	return n + idLen + 16
+}
+
+func appendCryptoFrame(b []byte, off int64, data []byte) []byte {
+	b = append(b, frameTypeCrypto)
+	b = appendVarint(b, uint64(off))
+	return appendVarintBytes(b, data)
+}
+
+// cryptoFrameOverhead is the maximum size of a CRYPTO frame header.
+func cryptoFrameOverhead(off int64) int {
+	return 1 + sizeVarint(uint64(off)) + 2
+}
+
+// appendStreamFrame appends a STREAM frame. The length is always encoded.
+func appendStreamFrame(b []byte, id, off int64, data []byte, fin bool) []byte {
+	typ := byte(frameTypeStreamBase | streamLenBit)
+	if off != 0 {
+		typ |= streamOffBit
+	}
+	if fin {
+		typ |= streamFinBit
+	}
+	b = append(b, typ)
+	b = appendVarint(b, uint64(id))
+	if off != 0 {
+		b = appendVarint(b, uint64(off))
+	}
+	return appendVarintBytes(b, data)
+}
+
+// streamFrameOverhead is the maximum size of a STREAM frame header,
+// assuming a two-byte length.
+func streamFrameOverhead(id, off int64) int {
+	return 1 + sizeVarint(uint64(id)) + sizeVarint(uint64(off)) + 2
+}
+
+func appendConnectionCloseFrame(b []byte, app bool, code uint64, reason string) []byte {
+	if len(reason) > 256 {
+		reason = reason[:256]
+	}
+	if app {
+		b = append(b, frameTypeConnectionCloseApplication)
+		b = appendVarint(b, code)
+	} else {
+		b = append(b, frameTypeConnectionCloseTransport)
+		b = appendVarint(b, code)
+		b = appendVarint(b, 0) // frame type
+	}
+	b = appendVarint(b, uint64(len(reason)))
+	return append(b, reason...)
+}
+
+func appendVarintFrame(b []byte, typ byte, fields ...uint64) []byte {
+	b = append(b, typ)
+	for _, f := range fields {
+		b = appendVarint(b, f)
+	}
+	return b
+}
+
+func sizeVarintFrame(fields ...uint64) int {
+	n := 1
+	for _, f := range fields {
+		n += sizeVarint(f)
+	}
+	return n
+}
@@ -0,0 +1,296 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import "encoding/binary"
+
+// quicVersion1 is the only version of QUIC supported.
+const quicVersion1 = 1
+
+const (
+	// maxDatagramSize is the size of datagrams sent.
+	// It is the minimum size every QUIC path is required to support,
+	// which avoids the need for path MTU discovery.
+	maxDatagramSize = 1200
+
+	// connIDLen is the length of locally chosen connection IDs.
+	connIDLen = 8
+
+	// maxConnIDLen is the maximum length of a connection ID in QUIC v1.
+	maxConnIDLen = 20
+
+	// packetNumberLen is the length of encoded packet numbers.
+	// Packets are always sent with four-byte packet numbers, which
+	// guarantees enough ciphertext for the header protection sample.
+	packetNumberLen = 4
+
+	// aeadOverhead is the size of the AEAD tag for all QUIC v1 ciphers.
+	aeadOverhead = 16
+)
+
+// Long header packet types. See RFC 9000, Section 17.2.
+const (
+	longTypeInitial   = 0x0
+	longType0RTT      = 0x1
+	longTypeHandshake = 0x2
+	longTypeRetry     = 0x3
+)
+
+const (
+	headerFormLong  = 0x80
+	fixedBit        = 0x40
+	keyPhaseBit     = 0x04
+	longReserved    = 0x0c
+	shortReserved   = 0x18
+	longPNLenMask   = 0x03
+	shortPNLenMask  = 0x03
+	longHeaderMask  = 0x0f
+	shortHeaderMask = 0x1f
+)
+
+// A numberSpace is a packet number space. See RFC 9000, Section 12.3.
+type numberSpace int
+
+const (
+	initialSpace = numberSpace(iota)
+	handshakeSpace
+	appDataSpace
+	numberSpaceCount
+)
+
+func (s numberSpace) String() string {
+	switch s {
+	case initialSpace:
+		return "Initial"
+	case handshakeSpace:
+		return "Handshake"
+	case appDataSpace:
+		return "AppData"
+	}
+	return "unknown"
+}
+
+// Variable-length integers. See RFC 9000, Section 16.
+
+const maxVarint = 1<<62 - 1
+
+// sizeVarint returns the encoded size of v.
+func sizeVarint(v uint64) int {
+	switch {
+	case v < 1<<6:
+		return 1
+	case v < 1<<14:
+		return 2
+	case v < 1<<30:
+		return 4
+	case v < 1<<62:
+		return 8
+	}
+	panic("quic: varint too large")
+}
+
+// appendVarint appends the encoding of v to b.
+func appendVarint(b []byte, v uint64) []byte {
+	switch {
+	case v < 1<<6:
+		return append(b, byte(v))
+	case v < 1<<14:
+		return append(b, 0x40|byte(v>>8), byte(v))
+	case v < 1<<30:
+		return append(b, 0x80|byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	case v < 1<<62:
+		return append(b, 0xc0|byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32),
+			byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+	panic("quic: varint too large")
+}
+
+// consumeVarint parses a varint from the start of b.
+// It returns the value and the number of bytes consumed,
+// or n < 0 if b does not contain a complete varint.
+func consumeVarint(b []byte) (v uint64, n int) {
+	if len(b) < 1 {
+		return 0, -1
+	}
+	n = 1 << (b[0] >> 6)
+	if len(b) < n {
+		return 0, -1
+	}
+	v = uint64(b[0] & 0x3f)
+	for i := 1; i < n; i++ {
+		v = v<<8 | uint64(b[i])
+	}
+	return v, n
+}
+
+// consumeVarintInt64 is consumeVarint returning an int64.
+func consumeVarintInt64(b []byte) (v int64, n int) {
+	u, n := consumeVarint(b)
+	return int64(u), n
+}
+
+// consumeVarintBytes parses a varint length followed by that many bytes.
+func consumeVarintBytes(b []byte) (v []byte, n int) {
+	size, n := consumeVarint(b)
+	if n < 0 || uint64(len(b)-n) < size {
+		return nil, -1
+	}
+	return b[n:][:size], n + int(size)
+}
+
+// appendVarintBytes appends a varint length followed by v.
+func appendVarintBytes(b, v []byte) []byte {
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// decodePacketNumber reconstructs a full packet number from a truncated
+// one, given the largest packet number received so far in the space.
+// See RFC 9000, Appendix A.3.
+func decodePacketNumber(largest, truncated int64, pnLen int) int64 {
+	expected := largest + 1
+	win := int64(1) << (8 * pnLen)
+	hwin := win / 2
+	mask := win - 1
+	candidate := (expected &^ mask) | truncated
+	switch {
+	case candidate <= expected-hwin && candidate < (1<<62)-win:
+		return candidate + win
+	case candidate > expected+hwin && candidate >= win:
+		return candidate - win
+	}
+	return candidate
+}
+
+// longHeader is a parsed long header.
+type longHeader struct {
+	typ       byte
+	version   uint32
+	dstConnID []byte
+	srcConnID []byte
+	pnOff     int // offset of the packet number
+	end       int // offset of the end of the packet
+}
+
+// parseLongHeader parses the unprotected parts of a long header packet
+// at the start of b. It returns ok == false if the packet is malformed.
+func parseLongHeader(b []byte) (h longHeader, ok bool) {
+	if len(b) < 7 || b[0]&headerFormLong == 0 {
+		return h, false
+	}
+	h.typ = (b[0] >> 4) & 0x3
+	h.version = binary.BigEndian.Uint32(b[1:5])
+	off := 5
+	dlen := int(b[off])
+	off++
+	if dlen > maxConnIDLen || len(b) < off+dlen+1 {
+		return h, false
+	}
+	h.dstConnID = b[off : off+dlen]
+	off += dlen
+	slen := int(b[off])
+	off++
+	if slen > maxConnIDLen || len(b) < off+slen {
+		return h, false
+	}
+	h.srcConnID = b[off : off+slen]
+	off += slen
+	if h.version != quicVersion1 {
+		return h, true
+	}
+	if h.typ == longTypeRetry {
+		h.end = len(b)
+		return h, true
+	}
+	if h.typ == longTypeInitial {
+		_, n := consumeVarintBytes(b[off:]) // token
+		if n < 0 {
+			return h, false
+		}
+		off += n
+	}
+	length, n := consumeVarint(b[off:])
+	if n < 0 {
+		return h, false
+	}
+	off += n
+	if uint64(len(b)-off) < length {
+		return h, false
+	}
+	h.pnOff = off
+	h.end = off + int(length)
+	return h, true
+}
+
+// dstConnIDForDatagram returns the destination connection ID of the
+// first packet in a datagram, assuming short header packets carry
+// connection IDs of length connIDLen.
+func dstConnIDForDatagram(b []byte) (id []byte, ok bool) {
+	if len(b) < 1 {
+		return nil, false
+	}
+	if b[0]&headerFormLong != 0 {
+		if len(b) < 6 {
+			return nil, false
+		}
+		dlen := int(b[5])
+		if dlen > maxConnIDLen || len(b) < 6+dlen {
+			return nil, false
+		}
+		return b[6 : 6+dlen], true
+	}
+	if len(b) < 1+connIDLen {
+		return nil, false
+	}
+	return b[1 : 1+connIDLen], true
+}
+
+// appendLongHeader appends a long header with a placeholder two-byte
+// length field and four-byte packet number. It returns the extended
+// buffer and the offset of the length field.
+func appendLongHeader(b []byte, typ byte, dstConnID, srcConnID []byte, pnum int64) ([]byte, int) {
+	b = append(b, headerFormLong|fixedBit|typ<<4|(packetNumberLen-1))
+	b = appendUint32(b, quicVersion1)
+	b = append(b, byte(len(dstConnID)))
+	b = append(b, dstConnID...)
+	b = append(b, byte(len(srcConnID)))
+	b = append(b, srcConnID...)
+	if typ == longTypeInitial {
+		b = appendVarint(b, 0) // token length
+	}
+	lenOff := len(b)
+	b = append(b, 0, 0)
+	b = appendUint32(b, uint32(pnum))
+	return b, lenOff
+}
+
+// appendShortHeader appends a 1-RTT header with a four-byte packet number.
+func appendShortHeader(b []byte, dstConnID []byte, pnum int64, keyPhase bool) []byte {
+	first := byte(fixedBit | (packetNumberLen - 1))
+	if keyPhase {
+		first |= keyPhaseBit
+	}
+	b = append(b, first)
+	b = append(b, dstConnID...)
+	return appendUint32(b, uint32(pnum))
+}
+
+// longHeaderSize returns the size of a long header as written by appendLongHeader.
+func longHeaderSize(typ byte, dstConnID, srcConnID []byte) int {
+	n := 1 + 4 + 1 + len(dstConnID) + 1 + len(srcConnID) + 2 + packetNumberLen
+	if typ == longTypeInitial {
+		n++
+	}
+	return n
+}
+
+// shortHeaderSize returns the size of a short header as written by appendShortHeader.
+func shortHeaderSize(dstConnID []byte) int {
+	return 1 + len(dstConnID) + packetNumberLen
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
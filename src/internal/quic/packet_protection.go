@@ -0,0 +1,224 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/cryptobyte"
+	"golang.org/x/crypto/hkdf"
+)
+
+// initialSalt is the salt used to derive Initial secrets.
+// See RFC 9001, Section 5.2.
+var initialSalt = []byte{
+	0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17,
+	0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a,
+}
+
+var errDecrypt = errors.New("quic: packet decryption failed")
+
+// packetKeys are the keys protecting packets in one direction.
+// See RFC 9001, Section 5.
+type packetKeys struct {
+	aead cipher.AEAD
+	iv   []byte
+	hp   headerProtection
+}
+
+// headerProtection computes a header protection mask from a ciphertext sample.
+type headerProtection interface {
+	mask(sample []byte) [5]byte
+}
+
+type aesHeaderProtection struct {
+	block cipher.Block
+}
+
+func (hp aesHeaderProtection) mask(sample []byte) (m [5]byte) {
+	var out [aes.BlockSize]byte
+	hp.block.Encrypt(out[:], sample)
+	copy(m[:], out[:])
+	return m
+}
+
+type chachaHeaderProtection struct {
+	key []byte
+}
+
+func (hp chachaHeaderProtection) mask(sample []byte) (m [5]byte) {
+	c, err := chacha20.NewUnauthenticatedCipher(hp.key, sample[4:16])
+	if err != nil {
+		panic(err)
+	}
+	c.SetCounter(binary.LittleEndian.Uint32(sample[:4]))
+	c.XORKeyStream(m[:], m[:])
+	return m
+}
+
+// suiteHash returns the hash function of a TLS 1.3 cipher suite.
+func suiteHash(suite uint16) crypto.Hash {
+	if suite == tls.TLS_AES_256_GCM_SHA384 {
+		return crypto.SHA384
+	}
+	return crypto.SHA256
+}
+
+// newPacketKeys derives packet protection keys from a traffic secret.
+func newPacketKeys(suite uint16, secret []byte) *packetKeys {
+	h := suiteHash(suite)
+	var keyLen int
+	switch suite {
+	case tls.TLS_AES_128_GCM_SHA256:
+		keyLen = 16
+	case tls.TLS_AES_256_GCM_SHA384, tls.TLS_CHACHA20_POLY1305_SHA256:
+		keyLen = 32
+	default:
+		panic("quic: unknown cipher suite")
+	}
+	key := hkdfExpandLabel(h, secret, "quic key", keyLen)
+	iv := hkdfExpandLabel(h, secret, "quic iv", 12)
+	hpKey := hkdfExpandLabel(h, secret, "quic hp", keyLen)
+	k := &packetKeys{iv: iv}
+	if suite == tls.TLS_CHACHA20_POLY1305_SHA256 {
+		aead, err := chacha20poly1305.New(key)
+		if err != nil {
+			panic(err)
+		}
+		k.aead = aead
+		k.hp = chachaHeaderProtection{hpKey}
+		return k
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	k.aead = aead
+	hpBlock, err := aes.NewCipher(hpKey)
+	if err != nil {
+		panic(err)
+	}
+	k.hp = aesHeaderProtection{hpBlock}
+	return k
+}
+
+// nextTrafficSecret derives the traffic secret for the next key phase.
+// See RFC 9001, Section 6.1.
+func nextTrafficSecret(suite uint16, secret []byte) []byte {
+	h := suiteHash(suite)
+	return hkdfExpandLabel(h, secret, "quic ku", h.Size())
+}
+
+// initialKeys returns the Initial packet protection keys for a connection
+// whose client chose the original destination connection ID cid.
+// See RFC 9001, Section 5.2.
+func initialKeys(cid []byte) (client, server *packetKeys) {
+	clientSecret, serverSecret := initialSecrets(cid)
+	return newPacketKeys(tls.TLS_AES_128_GCM_SHA256, clientSecret),
+		newPacketKeys(tls.TLS_AES_128_GCM_SHA256, serverSecret)
+}
+
+func initialSecrets(cid []byte) (client, server []byte) {
+	initialSecret := hkdf.Extract(sha256.New, cid, initialSalt)
+	client = hkdfExpandLabel(crypto.SHA256, initialSecret, "client in", sha256.Size)
+	server = hkdfExpandLabel(crypto.SHA256, initialSecret, "server in", sha256.Size)
+	return client, server
+}
+
+// hkdfExpandLabel implements HKDF-Expand-Label from RFC 8446, Section 7.1,
+// with an empty context.
+func hkdfExpandLabel(h crypto.Hash, secret []byte, label string, length int) []byte {
+	var b cryptobyte.Builder
+	b.AddUint16(uint16(length))
+	b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes([]byte("tls13 "))
+		b.AddBytes([]byte(label))
+	})
+	b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {})
+	out := make([]byte, length)
+	n, err := hkdf.Expand(h.New, secret, b.BytesOrPanic()).Read(out)
+	if err != nil || n != length {
+		panic("quic: HKDF-Expand-Label invocation failed unexpectedly")
+	}
+	return out
+}
+
+func (k *packetKeys) nonce(pnum int64) []byte {
+	nonce := make([]byte, len(k.iv))
+	copy(nonce, k.iv)
+	for i := 0; i < 8; i++ {
+		nonce[len(nonce)-1-i] ^= byte(pnum >> (8 * i))
+	}
+	return nonce
+}
+
+// protect encrypts the payload of the packet pkt in place and applies
+// header protection. pkt holds the header (with the packet number at
+// pnOff) followed by the plaintext payload, and must have capacity for
+// the AEAD tag. It returns the protected packet.
+func (k *packetKeys) protect(pkt []byte, pnOff int, pnum int64) []byte {
+	hdr := pkt[:pnOff+packetNumberLen]
+	payload := pkt[pnOff+packetNumberLen:]
+	pkt = k.aead.Seal(hdr, k.nonce(pnum), payload, hdr)
+	k.applyHeaderProtection(pkt, pnOff)
+	return pkt
+}
+
+func (k *packetKeys) applyHeaderProtection(pkt []byte, pnOff int) {
+	sample := pkt[pnOff+4:][:16]
+	mask := k.hp.mask(sample)
+	if pkt[0]&headerFormLong != 0 {
+		pkt[0] ^= mask[0] & longHeaderMask
+	} else {
+		pkt[0] ^= mask[0] & shortHeaderMask
+	}
+	for i := 0; i < packetNumberLen; i++ {
+		pkt[pnOff+i] ^= mask[1+i]
+	}
+}
+
+// removeHeaderProtection removes header protection from pkt in place.
+// It returns the truncated packet number and its length.
+func (k *packetKeys) removeHeaderProtection(pkt []byte, pnOff int) (pnum int64, pnLen int, ok bool) {
+	if len(pkt) < pnOff+4+16 {
+		return 0, 0, false
+	}
+	mask := k.hp.mask(pkt[pnOff+4:][:16])
+	if pkt[0]&headerFormLong != 0 {
+		pkt[0] ^= mask[0] & longHeaderMask
+	} else {
+		pkt[0] ^= mask[0] & shortHeaderMask
+	}
+	pnLen = int(pkt[0]&0x3) + 1
+	for i := 0; i < pnLen; i++ {
+		pkt[pnOff+i] ^= mask[1+i]
+		pnum = pnum<<8 | int64(pkt[pnOff+i])
+	}
+	return pnum, pnLen, true
+}
+
+// open decrypts the payload of a packet whose header protection has been
+// removed. hdrLen is the length of the header including the packet number.
+// The plaintext overwrites the ciphertext.
+func (k *packetKeys) open(pkt []byte, hdrLen int, pnum int64) ([]byte, error) {
+	hdr := pkt[:hdrLen]
+	payload, err := k.aead.Open(pkt[hdrLen:hdrLen], k.nonce(pnum), pkt[hdrLen:], hdr)
+	if err != nil {
+		return nil, errDecrypt
+	}
+	return payload, nil
+}
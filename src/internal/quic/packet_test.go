@@ -0,0 +1,277 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Test vectors from RFC 9001, Appendix A.1.
+func TestInitialSecrets(t *testing.T) {
+	cid := unhex("8394c8f03e515708")
+	client, server := initialSecrets(cid)
+	if want := unhex("c00cf151ca5be075ed0ebfb5c80323c42d6b7db67881289af4008f1f6c357aea"); !bytes.Equal(client, want) {
+		t.Errorf("client initial secret = %x, want %x", client, want)
+	}
+	if want := unhex("3c199828fd139efd216c155ad844cc81fb82fa8d7446fa7d78be803acdda951b"); !bytes.Equal(server, want) {
+		t.Errorf("server initial secret = %x, want %x", server, want)
+	}
+	for _, test := range []struct {
+		secret  []byte
+		key, iv string
+	}{{
+		secret: client,
+		key:    "1f369613dd76d5467730efcbe3b1a22d",
+		iv:     "fa044b2f42a3fd3b46fb255c",
+	}, {
+		secret: server,
+		key:    "cf3a5331653c364c88f0f379b6067e37",
+		iv:     "0ac1493ca1905853b0bba03e",
+	}} {
+		if got := hkdfExpandLabel(suiteHash(0x1301), test.secret, "quic key", 16); !bytes.Equal(got, unhex(test.key)) {
+			t.Errorf("key = %x, want %v", got, test.key)
+		}
+		if got := hkdfExpandLabel(suiteHash(0x1301), test.secret, "quic iv", 12); !bytes.Equal(got, unhex(test.iv)) {
+			t.Errorf("iv = %x, want %v", got, test.iv)
+		}
+	}
+}
+
+func TestPacketProtectionRoundTrip(t *testing.T) {
+	for _, suite := range []uint16{0x1301, 0x1302, 0x1303} {
+		secret := bytes.Repeat([]byte{0x42}, suiteHash(suite).Size())
+		w := newPacketKeys(suite, secret)
+		r := newPacketKeys(suite, secret)
+		payload := []byte("hello, world")
+		for _, long := range []bool{true, false} {
+			var b []byte
+			var pnOff int
+			const pnum = 0x1234567
+			if long {
+				var lenOff int
+				b, lenOff = appendLongHeader(nil, longTypeHandshake, []byte("dst"), []byte("src"), pnum)
+				pnOff = lenOff + 2
+			} else {
+				b = appendShortHeader(nil, []byte("12345678"), pnum, true)
+				pnOff = 1 + 8
+			}
+			hdr := append([]byte(nil), b...)
+			b = append(b, payload...)
+			pkt := w.protect(b, pnOff, pnum)
+			truncated, pnLen, ok := r.removeHeaderProtection(pkt, pnOff)
+			if !ok {
+				t.Fatalf("suite %x: removeHeaderProtection failed", suite)
+			}
+			got := decodePacketNumber(pnum-1, truncated, pnLen)
+			if got != pnum {
+				t.Errorf("suite %x: packet number = %x, want %x", suite, got, pnum)
+			}
+			if !bytes.Equal(pkt[:len(hdr)], hdr) {
+				t.Errorf("suite %x: header = %x, want %x", suite, pkt[:len(hdr)], hdr)
+			}
+			plain, err := r.open(pkt, pnOff+pnLen, got)
+			if err != nil {
+				t.Fatalf("suite %x: open: %v", suite, err)
+			}
+			if !bytes.Equal(plain, payload) {
+				t.Errorf("suite %x: payload = %q, want %q", suite, plain, payload)
+			}
+		}
+	}
+}
+
+func TestVarint(t *testing.T) {
+	for _, v := range []uint64{0, 1, 63, 64, 16383, 16384, 1<<30 - 1, 1 << 30, maxVarint} {
+		b := appendVarint(nil, v)
+		if len(b) != sizeVarint(v) {
+			t.Errorf("appendVarint(%v) has length %v, want %v", v, len(b), sizeVarint(v))
+		}
+		got, n := consumeVarint(b)
+		if got != v || n != len(b) {
+			t.Errorf("consumeVarint(%x) = %v, %v; want %v, %v", b, got, n, v, len(b))
+		}
+		if _, n := consumeVarint(b[:len(b)-1]); n >= 0 {
+			t.Errorf("consumeVarint(%x) succeeded on truncated input", b[:len(b)-1])
+		}
+	}
+	// Examples from RFC 9000, Appendix A.1.
+	for _, test := range []struct {
+		b string
+		v uint64
+	}{
+		{"c2197c5eff14e88c", 151288809941952652},
+		{"9d7f3e7d", 494878333},
+		{"7bbd", 15293},
+		{"25", 37},
+		{"4025", 37},
+	} {
+		if got, _ := consumeVarint(unhex(test.b)); got != test.v {
+			t.Errorf("consumeVarint(%v) = %v, want %v", test.b, got, test.v)
+		}
+	}
+}
+
+func TestDecodePacketNumber(t *testing.T) {
+	// Example from RFC 9000, Appendix A.3.
+	if got := decodePacketNumber(0xa82f30ea, 0x9b32, 2); got != 0xa82f9b32 {
+		t.Errorf("decodePacketNumber = %x, want a82f9b32", got)
+	}
+}
+
+func TestRangeSet(t *testing.T) {
+	var s rangeSet
+	s.add(10, 20)
+	s.add(30, 40)
+	s.add(0, 5)
+	s.add(20, 25) // adjacent
+	want := rangeSet{{0, 5}, {10, 25}, {30, 40}}
+	if !reflect.DeepEqual(s, want) {
+		t.Fatalf("after add: %v, want %v", s, want)
+	}
+	s.add(3, 32)
+	if want := (rangeSet{{0, 40}}); !reflect.DeepEqual(s, want) {
+		t.Fatalf("after merging add: %v, want %v", s, want)
+	}
+	s.sub(10, 20)
+	s.sub(35, 50)
+	if want := (rangeSet{{0, 10}, {20, 35}}); !reflect.DeepEqual(s, want) {
+		t.Fatalf("after sub: %v, want %v", s, want)
+	}
+	if !s.contains(9) || s.contains(10) || !s.contains(20) || s.contains(35) {
+		t.Errorf("contains returned wrong results for %v", s)
+	}
+	s.removeBefore(5)
+	if want := (rangeSet{{5, 10}, {20, 35}}); !reflect.DeepEqual(s, want) {
+		t.Fatalf("after removeBefore: %v, want %v", s, want)
+	}
+	if s.min() != 5 || s.max() != 34 {
+		t.Errorf("min, max = %v, %v; want 5, 34", s.min(), s.max())
+	}
+}
+
+func TestAckFrameRoundTrip(t *testing.T) {
+	var seen rangeSet
+	seen.add(0, 3)
+	seen.add(5, 6)
+	seen.add(10, 100)
+	b, ok := appendAckFrame(nil, seen, 8000, 1000)
+	if !ok {
+		t.Fatal("appendAckFrame failed")
+	}
+	var got rangeSet
+	largest, delay, n := consumeAckFrame(b, func(start, end int64) {
+		got.add(start, end)
+	})
+	if n != len(b) {
+		t.Fatalf("consumeAckFrame consumed %v bytes, want %v", n, len(b))
+	}
+	if largest != 99 || delay != 8000>>ackDelayExponent {
+		t.Errorf("largest, delay = %v, %v; want 99, %v", largest, delay, 8000>>ackDelayExponent)
+	}
+	if !reflect.DeepEqual(got, seen) {
+		t.Errorf("ranges = %v, want %v", got, seen)
+	}
+
+	// Only the most recent ranges are sent if space is short.
+	b, ok = appendAckFrame(nil, seen, 0, 8)
+	if !ok {
+		t.Fatal("appendAckFrame failed")
+	}
+	got = nil
+	consumeAckFrame(b, func(start, end int64) {
+		got.add(start, end)
+	})
+	if want := (rangeSet{{10, 100}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("truncated ranges = %v, want %v", got, want)
+	}
+}
+
+func TestTransportParametersRoundTrip(t *testing.T) {
+	p := transportParameters{
+		originalDstConnID:              []byte("orig"),
+		hasOriginalDstConnID:           true,
+		maxIdleTimeout:                 10 * time.Second,
+		initialMaxData:                 1 << 20,
+		initialMaxStreamDataBidiLocal:  1000,
+		initialMaxStreamDataBidiRemote: 2000,
+		initialMaxStreamDataUni:        3000,
+		initialMaxStreamsBidi:          100,
+		initialMaxStreamsUni:           3,
+		disableActiveMigration:         true,
+		initialSrcConnID:               []byte("src"),
+		hasInitialSrcConnID:            true,
+	}
+	got, err := unmarshalTransportParameters(p.marshal(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := p
+	want.maxUDPPayloadSize = 65527
+	want.ackDelayExponent = 3
+	want.maxAckDelay = 25 * time.Millisecond
+	want.activeConnIDLimit = 2
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unmarshal(marshal(p)) =\n%+v\nwant\n%+v", got, want)
+	}
+	if _, err := unmarshalTransportParameters(p.marshal(), false); err == nil {
+		t.Errorf("server-only parameter from client: got no error")
+	}
+}
+
+func TestSendBuffer(t *testing.T) {
+	var b sendBuffer
+	b.write([]byte("0123456789"))
+	off, data := b.next(4, 100)
+	if off != 0 || string(data) != "0123" {
+		t.Fatalf("next = %v, %q; want 0, %q", off, data, "0123")
+	}
+	off, data = b.next(100, 8)
+	if off != 4 || string(data) != "4567" {
+		t.Fatalf("next = %v, %q; want 4, %q", off, data, "4567")
+	}
+	b.ack(4, 4)
+	b.lost(0, 4)
+	if b.base != 0 {
+		t.Errorf("base = %v after out of order ack, want 0", b.base)
+	}
+	off, data = b.next(100, 100)
+	if off != 0 || string(data) != "0123" {
+		t.Fatalf("next after loss = %v, %q; want 0, %q", off, data, "0123")
+	}
+	b.ack(0, 4)
+	if b.base != 8 || string(b.buf) != "89" {
+		t.Errorf("after acks, base = %v, buf = %q; want 8, %q", b.base, b.buf, "89")
+	}
+}
+
+func TestRecvBuffer(t *testing.T) {
+	var b recvBuffer
+	b.write(5, []byte("56789"))
+	if n := b.readable(); n != 0 {
+		t.Errorf("readable = %v, want 0", n)
+	}
+	b.write(0, []byte("01234"))
+	b.write(3, []byte("34567")) // duplicate data
+	p := make([]byte, 20)
+	n := b.read(p)
+	if got := string(p[:n]); got != "0123456789" {
+		t.Errorf("read %q, want %q", got, "0123456789")
+	}
+	if b.end() != 10 {
+		t.Errorf("end = %v, want 10", b.end())
+	}
+}
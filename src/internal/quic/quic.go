@@ -0,0 +1,222 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package quic implements the QUIC transport protocol as described in
+// RFC 9000, RFC 9001 and RFC 9002, on top of a net.PacketConn.
+//
+// The implementation covers what an application protocol such as HTTP/3
+// needs: the handshake, bidirectional and unidirectional streams, flow
+// control, loss recovery and congestion control. It does not support
+// 0-RTT data, Retry packets, version negotiation, connection migration,
+// or initiating key updates (updates initiated by the peer are honored).
+package quic
+
+import (
+	"crypto/tls"
+	"fmt"
+	"time"
+)
+
+// A Config configures a QUIC connection or endpoint.
+type Config struct {
+	// TLSConfig is the TLS configuration to use for connections.
+	// It must be non-nil. TLS 1.3 is always used, regardless of the
+	// configuration's MinVersion.
+	TLSConfig *tls.Config
+
+	// MaxIdleTimeout is the time after which a connection with no
+	// network activity is closed. If zero, 30 seconds is used.
+	MaxIdleTimeout time.Duration
+
+	// KeepAlivePeriod is the time after which a PING is sent on a
+	// connection with no network activity, to keep it from timing out.
+	// If zero, no keep-alive packets are sent.
+	KeepAlivePeriod time.Duration
+
+	// MaxBidiRemoteStreams and MaxUniRemoteStreams are the maximum
+	// number of bidirectional and unidirectional streams the peer may
+	// have open at the same time. If zero, 100 is used.
+	MaxBidiRemoteStreams int64
+	MaxUniRemoteStreams  int64
+
+	// MaxStreamReadBufferSize is the amount of data the peer may send on
+	// a stream before it is read. If zero, 1 MiB is used.
+	MaxStreamReadBufferSize int64
+
+	// MaxStreamWriteBufferSize is the amount of data buffered for
+	// sending on a stream before Write blocks. If zero, 1 MiB is used.
+	MaxStreamWriteBufferSize int64
+
+	// MaxConnReadBufferSize is the amount of data the peer may send on
+	// all streams before it is read. If zero, 16 MiB is used.
+	MaxConnReadBufferSize int64
+}
+
+func (c *Config) maxIdleTimeout() time.Duration {
+	if c.MaxIdleTimeout > 0 {
+		return c.MaxIdleTimeout
+	}
+	return 30 * time.Second
+}
+
+func (c *Config) maxBidiRemoteStreams() int64 {
+	if c.MaxBidiRemoteStreams > 0 {
+		return c.MaxBidiRemoteStreams
+	}
+	return 100
+}
+
+func (c *Config) maxUniRemoteStreams() int64 {
+	if c.MaxUniRemoteStreams > 0 {
+		return c.MaxUniRemoteStreams
+	}
+	return 100
+}
+
+func (c *Config) maxStreamReadBufferSize() int64 {
+	if c.MaxStreamReadBufferSize > 0 {
+		return c.MaxStreamReadBufferSize
+	}
+	return 1 << 20
+}
+
+func (c *Config) maxStreamWriteBufferSize() int64 {
+	if c.MaxStreamWriteBufferSize > 0 {
+		return c.MaxStreamWriteBufferSize
+	}
+	return 1 << 20
+}
+
+func (c *Config) maxConnReadBufferSize() int64 {
+	if c.MaxConnReadBufferSize > 0 {
+		return c.MaxConnReadBufferSize
+	}
+	return 16 << 20
+}
+
+// A transportError is a QUIC transport error code.
+// See RFC 9000, Section 20.1.
+type transportError uint64
+
+const (
+	errNo                   = transportError(0x00)
+	errInternal             = transportError(0x01)
+	errConnectionRefused    = transportError(0x02)
+	errFlowControl          = transportError(0x03)
+	errStreamLimit          = transportError(0x04)
+	errStreamState          = transportError(0x05)
+	errFinalSize            = transportError(0x06)
+	errFrameEncoding        = transportError(0x07)
+	errTransportParameter   = transportError(0x08)
+	errConnectionIDLimit    = transportError(0x09)
+	errProtocolViolation    = transportError(0x0a)
+	errInvalidToken         = transportError(0x0b)
+	errApplicationError     = transportError(0x0c)
+	errCryptoBufferExceeded = transportError(0x0d)
+	errKeyUpdateError       = transportError(0x0e)
+	errAEADLimitReached     = transportError(0x0f)
+	errNoViablePath         = transportError(0x10)
+	errTLSBase              = transportError(0x0100) // 0x0100-0x01ff; base + TLS alert code
+)
+
+func (e transportError) String() string {
+	switch e {
+	case errNo:
+		return "NO_ERROR"
+	case errInternal:
+		return "INTERNAL_ERROR"
+	case errConnectionRefused:
+		return "CONNECTION_REFUSED"
+	case errFlowControl:
+		return "FLOW_CONTROL_ERROR"
+	case errStreamLimit:
+		return "STREAM_LIMIT_ERROR"
+	case errStreamState:
+		return "STREAM_STATE_ERROR"
+	case errFinalSize:
+		return "FINAL_SIZE_ERROR"
+	case errFrameEncoding:
+		return "FRAME_ENCODING_ERROR"
+	case errTransportParameter:
+		return "TRANSPORT_PARAMETER_ERROR"
+	case errConnectionIDLimit:
+		return "CONNECTION_ID_LIMIT_ERROR"
+	case errProtocolViolation:
+		return "PROTOCOL_VIOLATION"
+	case errInvalidToken:
+		return "INVALID_TOKEN"
+	case errApplicationError:
+		return "APPLICATION_ERROR"
+	case errCryptoBufferExceeded:
+		return "CRYPTO_BUFFER_EXCEEDED"
+	case errKeyUpdateError:
+		return "KEY_UPDATE_ERROR"
+	case errAEADLimitReached:
+		return "AEAD_LIMIT_REACHED"
+	case errNoViablePath:
+		return "NO_VIABLE_PATH"
+	}
+	if e >= 0x0100 && e <= 0x01ff {
+		return fmt.Sprintf("CRYPTO_ERROR(%v)", uint64(e)&0xff)
+	}
+	return fmt.Sprintf("ERROR %d", uint64(e))
+}
+
+// A TransportError is a connection error caused by a violation of the
+// QUIC protocol, detected either locally or by the peer.
+type TransportError struct {
+	Code   uint64
+	Reason string
+	Remote bool // the error was sent by the peer
+}
+
+func (e *TransportError) Error() string {
+	s := "quic: " + transportError(e.Code).String()
+	if e.Remote {
+		s = "quic: peer closed connection: " + transportError(e.Code).String()
+	}
+	if e.Reason != "" {
+		s += ": " + e.Reason
+	}
+	return s
+}
+
+func newTransportError(code transportError, format string, args ...any) *TransportError {
+	return &TransportError{Code: uint64(code), Reason: fmt.Sprintf(format, args...)}
+}
+
+// An ApplicationError is a connection error carrying an application
+// protocol error code. It is sent by Conn.Abort, and returned by Conn
+// methods after the peer closes the connection with an application
+// error code.
+type ApplicationError struct {
+	Code   uint64
+	Reason string
+}
+
+func (e *ApplicationError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("quic: application error %#x", e.Code)
+	}
+	return fmt.Sprintf("quic: application error %#x: %v", e.Code, e.Reason)
+}
+
+// A StreamError is returned by Stream methods when the peer resets the
+// stream or asks for it to stop sending. Code is the application protocol
+// error code sent by the peer.
+type StreamError struct {
+	Code uint64
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("quic: stream reset by peer with code %#x", e.Code)
+}
+
+// idleTimeoutError is returned after a connection is closed because it
+// was idle for longer than the idle timeout.
+type idleTimeoutError struct{}
+
+func (idleTimeoutError) Error() string   { return "quic: connection timed out" }
+func (idleTimeoutError) Timeout() bool   { return true }
+func (idleTimeoutError) Temporary() bool { return true }
@@ -0,0 +1,510 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testCert struct {
+	once sync.Once
+	cert tls.Certificate
+	pool *x509.CertPool
+}
+
+func testConfigs(t *testing.T) (server, client *Config) {
+	testCert.once.Do(func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			panic(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			DNSNames:              []string{"example.com"},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+			ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		if err != nil {
+			panic(err)
+		}
+		leaf, err := x509.ParseCertificate(der)
+		if err != nil {
+			panic(err)
+		}
+		testCert.cert = tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+		testCert.pool = x509.NewCertPool()
+		testCert.pool.AddCert(leaf)
+	})
+	server = &Config{
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{testCert.cert},
+			NextProtos:   []string{"quic-test"},
+		},
+	}
+	client = &Config{
+		TLSConfig: &tls.Config{
+			RootCAs:    testCert.pool,
+			ServerName: "example.com",
+			NextProtos: []string{"quic-test"},
+		},
+	}
+	return server, client
+}
+
+// lossyPacketConn drops some outgoing datagrams.
+type lossyPacketConn struct {
+	net.PacketConn
+	n    int64
+	drop func(n int64) bool
+}
+
+func (c *lossyPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if c.drop(atomic.AddInt64(&c.n, 1)) {
+		return len(b), nil
+	}
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+type testPair struct {
+	client, server         *Conn
+	clientEP, serverEP     *Endpoint
+	serverConf, clientConf *Config
+}
+
+func listenUDP(t *testing.T) net.PacketConn {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on UDP loopback: %v", err)
+	}
+	return pc
+}
+
+// newTestPair returns a connected client and server. If drop is non-nil,
+// both endpoints drop the outgoing datagrams for which it returns true.
+func newTestPair(t *testing.T, drop func(int64) bool, configure func(server, client *Config)) *testPair {
+	t.Helper()
+	p := &testPair{}
+	p.serverConf, p.clientConf = testConfigs(t)
+	if configure != nil {
+		configure(p.serverConf, p.clientConf)
+	}
+	var spc, cpc net.PacketConn = listenUDP(t), listenUDP(t)
+	if drop != nil {
+		spc = &lossyPacketConn{PacketConn: spc, drop: drop}
+		cpc = &lossyPacketConn{PacketConn: cpc, drop: drop}
+	}
+	p.serverEP = NewEndpoint(spc, p.serverConf)
+	p.clientEP = NewEndpoint(cpc, nil)
+	t.Cleanup(func() {
+		p.clientEP.Close()
+		p.serverEP.Close()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var err error
+	p.client, err = p.clientEP.Dial(ctx, p.serverEP.LocalAddr(), p.clientConf)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	p.server, err = p.serverEP.Accept(ctx)
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	return p
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestConnHandshake(t *testing.T) {
+	p := newTestPair(t, nil, nil)
+	for _, c := range []*Conn{p.client, p.server} {
+		cs := c.ConnectionState()
+		if !cs.HandshakeComplete {
+			t.Errorf("%v: HandshakeComplete = false", c.side)
+		}
+		if cs.Version != tls.VersionTLS13 {
+			t.Errorf("%v: Version = %x, want TLS 1.3", c.side, cs.Version)
+		}
+		if cs.NegotiatedProtocol != "quic-test" {
+			t.Errorf("%v: NegotiatedProtocol = %q, want %q", c.side, cs.NegotiatedProtocol, "quic-test")
+		}
+	}
+	if got, want := p.client.RemoteAddr().String(), p.serverEP.LocalAddr().String(); got != want {
+		t.Errorf("client RemoteAddr = %v, want %v", got, want)
+	}
+}
+
+func TestConnHandshakeFailure(t *testing.T) {
+	serverConf, clientConf := testConfigs(t)
+	clientConf.TLSConfig.RootCAs = x509.NewCertPool()
+	serverEP := NewEndpoint(listenUDP(t), serverConf)
+	defer serverEP.Close()
+	ctx := testContext(t)
+	_, err := Dial(ctx, "udp", serverEP.LocalAddr().String(), clientConf)
+	var terr *TransportError
+	if !errors.As(err, &terr) || terr.Code != uint64(errTLSBase)+42 { // bad_certificate
+		t.Fatalf("Dial with untrusted certificate: %v, want bad_certificate TransportError", err)
+	}
+}
+
+func TestConnNoApplicationProtocol(t *testing.T) {
+	serverConf, clientConf := testConfigs(t)
+	clientConf.TLSConfig.NextProtos = []string{"other"}
+	serverEP := NewEndpoint(listenUDP(t), serverConf)
+	defer serverEP.Close()
+	_, err := Dial(testContext(t), "udp", serverEP.LocalAddr().String(), clientConf)
+	var terr *TransportError
+	if !errors.As(err, &terr) || terr.Code != uint64(errTLSBase)+120 { // no_application_protocol
+		t.Fatalf("Dial with mismatched ALPN: %v, want no_application_protocol TransportError", err)
+	}
+}
+
+func TestStreamEcho(t *testing.T) {
+	p := newTestPair(t, nil, nil)
+	ctx := testContext(t)
+	go func() {
+		s, err := p.server.AcceptStream(ctx)
+		if err != nil {
+			return
+		}
+		io.Copy(s, s)
+		s.Close()
+	}()
+	s, err := p.client.OpenStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("hello, world")
+	if _, err := s.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	got, err := io.ReadAll(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, msg) {
+		t.Errorf("echo = %q, want %q", got, msg)
+	}
+}
+
+func TestStreamCloseWait(t *testing.T) {
+	p := newTestPair(t, nil, nil)
+	ctx := testContext(t)
+	s, err := p.client.OpenStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("hello, world")
+	if _, err := s.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CloseWait(ctx); err != nil {
+		t.Fatalf("CloseWait: %v", err)
+	}
+	// Everything is delivered, so closing the connection
+	// does not lose data.
+	p.client.Close()
+	ss, err := p.server.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, msg) {
+		t.Errorf("received %q, want %q", got, msg)
+	}
+}
+
+func testLargeTransfer(t *testing.T, drop func(int64) bool, size int) {
+	p := newTestPair(t, drop, func(server, client *Config) {
+		// Small windows exercise flow control.
+		server.MaxStreamReadBufferSize = 64 << 10
+		server.MaxConnReadBufferSize = 128 << 10
+	})
+	ctx := testContext(t)
+	data := make([]byte, size)
+	rand.Read(data)
+	errc := make(chan error, 1)
+	go func() {
+		s, err := p.client.OpenStream(ctx)
+		if err != nil {
+			errc <- err
+			return
+		}
+		if _, err := s.Write(data); err != nil {
+			errc <- err
+			return
+		}
+		errc <- s.Close()
+	}()
+	s, err := p.server.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("received %v bytes which differ from the %v sent", len(got), len(data))
+	}
+}
+
+func TestStreamLargeTransfer(t *testing.T) {
+	testLargeTransfer(t, nil, 4<<20)
+}
+
+func TestStreamLossyTransfer(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping slow lossy transfer in short mode")
+	}
+	// Drop every seventh datagram in each direction, including some
+	// handshake packets.
+	testLargeTransfer(t, func(n int64) bool { return n%7 == 3 }, 1<<20)
+}
+
+func TestManyStreams(t *testing.T) {
+	const streams = 250 // more than the default limit of 100
+	p := newTestPair(t, nil, nil)
+	ctx := testContext(t)
+	go func() {
+		for {
+			s, err := p.server.AcceptStream(ctx)
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(s, s)
+				s.Close()
+			}()
+		}
+	}()
+	var wg sync.WaitGroup
+	errc := make(chan error, streams)
+	for i := 0; i < streams; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s, err := p.client.OpenStream(ctx)
+			if err != nil {
+				errc <- err
+				return
+			}
+			msg := fmt.Sprintf("stream %v", i)
+			s.Write([]byte(msg))
+			s.Close()
+			got, err := io.ReadAll(s)
+			if err != nil {
+				errc <- err
+				return
+			}
+			if string(got) != msg {
+				errc <- fmt.Errorf("stream %v: got %q, want %q", i, got, msg)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errc)
+	for err := range errc {
+		t.Error(err)
+	}
+}
+
+func TestUniStream(t *testing.T) {
+	p := newTestPair(t, nil, nil)
+	ctx := testContext(t)
+	s, err := p.server.OpenUniStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write([]byte("data"))
+	s.Close()
+	if _, err := s.Read(make([]byte, 1)); err == nil {
+		t.Errorf("Read on send-only stream succeeded")
+	}
+	rs, err := p.client.AcceptUniStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.ID() != s.ID() || rs.ID() != 3 {
+		t.Errorf("stream IDs = %v, %v; want 3", rs.ID(), s.ID())
+	}
+	got, err := io.ReadAll(rs)
+	if err != nil || string(got) != "data" {
+		t.Errorf("ReadAll = %q, %v; want %q, nil", got, err, "data")
+	}
+	if _, err := rs.Write([]byte("x")); err == nil {
+		t.Errorf("Write on receive-only stream succeeded")
+	}
+}
+
+func TestStreamReset(t *testing.T) {
+	p := newTestPair(t, nil, nil)
+	ctx := testContext(t)
+	s, err := p.client.OpenStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write([]byte("partial"))
+	s.Reset(42)
+	rs, err := p.server.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.ReadAll(rs)
+	var serr *StreamError
+	if !errors.As(err, &serr) || serr.Code != 42 {
+		t.Errorf("Read after reset: %v, want StreamError with code 42", err)
+	}
+}
+
+func TestStreamStopSending(t *testing.T) {
+	p := newTestPair(t, nil, nil)
+	ctx := testContext(t)
+	s, err := p.client.OpenStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write([]byte("hello"))
+	rs, err := p.server.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.CloseRead(7)
+	// Writes fail once the STOP_SENDING arrives.
+	var serr *StreamError
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); {
+		_, err = s.Write([]byte("more"))
+		if err != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !errors.As(err, &serr) || serr.Code != 7 {
+		t.Errorf("Write after STOP_SENDING: %v, want StreamError with code 7", err)
+	}
+}
+
+func TestConnClose(t *testing.T) {
+	p := newTestPair(t, nil, nil)
+	ctx := testContext(t)
+	p.client.Abort(&ApplicationError{Code: 0x101, Reason: "bye"})
+	select {
+	case <-p.server.Done():
+	case <-ctx.Done():
+		t.Fatal("server connection not closed")
+	}
+	var aerr *ApplicationError
+	if err := p.server.Err(); !errors.As(err, &aerr) || aerr.Code != 0x101 || aerr.Reason != "bye" {
+		t.Errorf("server Err = %v, want application error 0x101", err)
+	}
+	if _, err := p.server.AcceptStream(ctx); err == nil {
+		t.Errorf("AcceptStream on closed connection succeeded")
+	}
+	if _, err := p.client.OpenStream(ctx); err == nil {
+		t.Errorf("OpenStream on closed connection succeeded")
+	}
+}
+
+func TestDialTimeoutStopsHandshake(t *testing.T) {
+	// The peer never answers, so the handshake cannot complete.
+	pc := listenUDP(t)
+	defer pc.Close()
+	_, client := testConfigs(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := Dial(ctx, "udp", pc.LocalAddr().String(), client); err == nil {
+		t.Fatal("Dial succeeded without a peer")
+	}
+	// The TLS handshake goroutine exits when the dial gives up,
+	// rather than at the end of the closing period.
+	for deadline := time.Now().Add(time.Second); ; {
+		buf := make([]byte, 1<<20)
+		stacks := string(buf[:runtime.Stack(buf, true)])
+		if !strings.Contains(stacks, "quicWaitForSignal") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("TLS handshake still running after Dial failed:\n%s", stacks)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestConnIdleTimeout(t *testing.T) {
+	p := newTestPair(t, nil, func(server, client *Config) {
+		client.MaxIdleTimeout = 100 * time.Millisecond
+	})
+	ctx := testContext(t)
+	for _, c := range []*Conn{p.client, p.server} {
+		select {
+		case <-c.Done():
+		case <-ctx.Done():
+			t.Fatalf("%v: connection not closed after idle timeout", c.side)
+		}
+		var nerr net.Error
+		if err := c.Err(); !errors.As(err, &nerr) || !nerr.Timeout() {
+			t.Errorf("%v: Err = %v, want timeout", c.side, err)
+		}
+	}
+}
+
+func TestConnKeepAlive(t *testing.T) {
+	p := newTestPair(t, nil, func(server, client *Config) {
+		client.MaxIdleTimeout = 500 * time.Millisecond
+		client.KeepAlivePeriod = 50 * time.Millisecond
+	})
+	select {
+	case <-p.client.Done():
+		t.Fatalf("connection closed despite keep-alives: %v", p.client.Err())
+	case <-time.After(1500 * time.Millisecond):
+	}
+}
+
+func TestEndpointClose(t *testing.T) {
+	p := newTestPair(t, nil, nil)
+	ctx := testContext(t)
+	p.serverEP.Close()
+	select {
+	case <-p.client.Done():
+	case <-ctx.Done():
+		t.Fatal("client connection not closed")
+	}
+	if _, err := p.serverEP.Accept(ctx); err == nil {
+		t.Errorf("Accept on closed endpoint succeeded")
+	}
+}
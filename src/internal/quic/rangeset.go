@@ -0,0 +1,130 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+// A rangeSet is a set of int64s, stored as an ordered list of
+// non-overlapping, non-adjacent half-open ranges.
+type rangeSet []interval
+
+// An interval is the half-open range [start, end).
+type interval struct {
+	start, end int64
+}
+
+func (i interval) size() int64 { return i.end - i.start }
+
+// add adds [start, end) to the set.
+func (s *rangeSet) add(start, end int64) {
+	if start >= end {
+		return
+	}
+	rs := *s
+	// Fast path: appending after the last range.
+	if n := len(rs); n == 0 || rs[n-1].end < start {
+		*s = append(rs, interval{start, end})
+		return
+	}
+	// Find the first range that ends at or after start.
+	i := 0
+	for i < len(rs) && rs[i].end < start {
+		i++
+	}
+	// Find the first range that starts after end.
+	j := i
+	for j < len(rs) && rs[j].start <= end {
+		j++
+	}
+	if i == j {
+		// No overlap; insert.
+		rs = append(rs, interval{})
+		copy(rs[i+1:], rs[i:])
+		rs[i] = interval{start, end}
+		*s = rs
+		return
+	}
+	if rs[i].start < start {
+		start = rs[i].start
+	}
+	if rs[j-1].end > end {
+		end = rs[j-1].end
+	}
+	rs[i] = interval{start, end}
+	*s = append(rs[:i+1], rs[j:]...)
+}
+
+// sub removes [start, end) from the set.
+func (s *rangeSet) sub(start, end int64) {
+	if start >= end {
+		return
+	}
+	rs := *s
+	i := 0
+	for i < len(rs) && rs[i].end <= start {
+		i++
+	}
+	if i == len(rs) || rs[i].start >= end {
+		return
+	}
+	out := make(rangeSet, 0, len(rs)+1)
+	out = append(out, rs[:i]...)
+	for ; i < len(rs) && rs[i].start < end; i++ {
+		r := rs[i]
+		if r.start < start {
+			out = append(out, interval{r.start, start})
+		}
+		if r.end > end {
+			out = append(out, interval{end, r.end})
+		}
+	}
+	*s = append(out, rs[i:]...)
+}
+
+// contains reports whether v is in the set.
+func (s rangeSet) contains(v int64) bool {
+	for _, r := range s {
+		if v < r.start {
+			return false
+		}
+		if v < r.end {
+			return true
+		}
+	}
+	return false
+}
+
+// min returns the smallest value in the set, or 0 if the set is empty.
+func (s rangeSet) min() int64 {
+	if len(s) == 0 {
+		return 0
+	}
+	return s[0].start
+}
+
+// max returns the largest value in the set, or -1 if the set is empty.
+func (s rangeSet) max() int64 {
+	if len(s) == 0 {
+		return -1
+	}
+	return s[len(s)-1].end - 1
+}
+
+// removeBefore removes all values less than v from the set.
+func (s *rangeSet) removeBefore(v int64) {
+	rs := *s
+	i := 0
+	for i < len(rs) && rs[i].end <= v {
+		i++
+	}
+	rs = rs[i:]
+	if len(rs) > 0 && rs[0].start < v {
+		rs[0].start = v
+	}
+	*s = rs
+}
+
+// isEmpty reports whether the set is empty.
+func (s rangeSet) isEmpty() bool {
+	return len(s) == 0
+}
@@ -0,0 +1,644 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+type streamType int
+
+const (
+	bidiStream = streamType(0)
+	uniStream  = streamType(1)
+)
+
+var (
+	errStreamClosed = errors.New("quic: stream closed")
+	errSendOnly     = errors.New("quic: read from send-only stream")
+	errRecvOnly     = errors.New("quic: write to receive-only stream")
+)
+
+// streamID returns the ID of a stream. See RFC 9000, Section 2.1.
+func streamID(index int64, initiator connSide, typ streamType) int64 {
+	id := index << 2
+	if initiator == serverSide {
+		id |= 0x1
+	}
+	if typ == uniStream {
+		id |= 0x2
+	}
+	return id
+}
+
+func streamIDSide(id int64) connSide {
+	if id&0x1 != 0 {
+		return serverSide
+	}
+	return clientSide
+}
+
+func streamIDType(id int64) streamType {
+	if id&0x2 != 0 {
+		return uniStream
+	}
+	return bidiStream
+}
+
+func streamIDIndex(id int64) int64 {
+	return id >> 2
+}
+
+// A Stream is an ordered byte stream in a QUIC connection.
+//
+// Unidirectional streams support only reading or writing, depending on
+// which side of the connection opened them.
+//
+// Multiple goroutines may invoke methods on a Stream simultaneously,
+// although concurrent reads or concurrent writes are not ordered.
+type Stream struct {
+	c  *Conn
+	id int64
+
+	// All fields below are guarded by c.mu.
+
+	// Send side.
+	hasSend      bool
+	send         sendBuffer
+	maxSendData  int64 // limit set by the peer
+	sentMax      int64 // highest offset sent
+	finSet       bool  // Close called
+	finPending   bool  // FIN needs to be sent
+	finAcked     bool
+	reset        bool // stream reset locally, or in response to STOP_SENDING
+	resetPending bool // RESET_STREAM needs to be sent
+	resetAcked   bool
+	resetCode    uint64
+	writeErr     error
+	writable     broadcaster
+
+	// Receive side.
+	hasRecv              bool
+	recv                 recvBuffer
+	recvMax              int64 // limit sent to the peer
+	recvWindow           int64
+	recvHigh             int64 // highest offset received
+	finalSize            int64 // -1 until known
+	recvErr              error // set when reset by the peer
+	readClosed           bool  // CloseRead called
+	stopSendingPending   bool  // STOP_SENDING needs to be sent
+	stopCode             uint64
+	maxStreamDataPending bool
+	readable             broadcaster
+
+	removed bool // removed from the connection's stream map
+}
+
+// newStream creates a stream with the given ID. c.mu must be held.
+func (c *Conn) newStream(id int64) *Stream {
+	s := &Stream{
+		c:         c,
+		id:        id,
+		finalSize: -1,
+	}
+	local := streamIDSide(id) == c.side
+	typ := streamIDType(id)
+	s.hasSend = typ == bidiStream || local
+	s.hasRecv = typ == bidiStream || !local
+	if s.hasSend {
+		switch {
+		case typ == uniStream:
+			s.maxSendData = c.peerParams.initialMaxStreamDataUni
+		case local:
+			s.maxSendData = c.peerParams.initialMaxStreamDataBidiRemote
+		default:
+			s.maxSendData = c.peerParams.initialMaxStreamDataBidiLocal
+		}
+	}
+	if s.hasRecv {
+		s.recvWindow = c.config.maxStreamReadBufferSize()
+		s.recvMax = s.recvWindow
+	}
+	c.streams[id] = s
+	return s
+}
+
+// ID returns the QUIC stream ID.
+func (s *Stream) ID() int64 {
+	return s.id
+}
+
+// Read reads data from the stream.
+// It returns io.EOF after the peer closes the stream and all data has
+// been read, and a *StreamError if the peer resets the stream.
+func (s *Stream) Read(b []byte) (int, error) {
+	c := s.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !s.hasRecv {
+		return 0, errSendOnly
+	}
+	for {
+		switch {
+		case s.readClosed:
+			return 0, errStreamClosed
+		case s.recvErr != nil:
+			return 0, s.recvErr
+		case s.recv.readable() > 0:
+			n := s.recv.read(b)
+			s.onRead(n)
+			return n, nil
+		case s.finalSize >= 0 && s.recv.off == s.finalSize:
+			c.maybeRemoveStream(s)
+			return 0, io.EOF
+		case c.err != nil:
+			return 0, c.err
+		case len(b) == 0:
+			return 0, nil
+		}
+		ch := s.readable.wait()
+		c.mu.Unlock()
+		<-ch
+		c.mu.Lock()
+	}
+}
+
+// onRead updates flow control after n bytes are read.
+func (s *Stream) onRead(n int) {
+	c := s.c
+	c.consumeData(int64(n))
+	if s.finalSize < 0 && s.recvMax-s.recv.off < s.recvWindow/2 {
+		s.recvMax = s.recv.off + s.recvWindow
+		s.maxStreamDataPending = true
+		c.wake()
+	}
+}
+
+// consumeData updates connection-level flow control after n bytes
+// are read or discarded.
+func (c *Conn) consumeData(n int64) {
+	c.readData += n
+	window := c.config.maxConnReadBufferSize()
+	if c.maxData-c.readData < window/2 {
+		c.maxData = c.readData + window
+		c.maxDataPending = true
+		c.wake()
+	}
+}
+
+// Write writes data to the stream.
+// It blocks while the stream's send buffer is full.
+func (s *Stream) Write(b []byte) (n int, err error) {
+	c := s.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !s.hasSend {
+		return 0, errRecvOnly
+	}
+	for {
+		switch {
+		case s.writeErr != nil:
+			return n, s.writeErr
+		case s.finSet:
+			return n, errStreamClosed
+		case c.err != nil:
+			return n, c.err
+		case len(b) == 0:
+			return n, nil
+		}
+		if space := c.config.maxStreamWriteBufferSize() - s.send.buffered(); space > 0 {
+			m := len(b)
+			if int64(m) > space {
+				m = int(space)
+			}
+			s.send.write(b[:m])
+			n += m
+			b = b[m:]
+			c.wake()
+			continue
+		}
+		ch := s.writable.wait()
+		c.mu.Unlock()
+		<-ch
+		c.mu.Lock()
+	}
+}
+
+// Close closes the send direction of the stream, after sending any
+// buffered data. It does not affect the receive direction; use
+// CloseRead to stop receiving.
+func (s *Stream) Close() error {
+	c := s.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !s.hasSend || s.finSet || s.reset {
+		return nil
+	}
+	s.finSet = true
+	s.finPending = true
+	c.wake()
+	return nil
+}
+
+// CloseWait closes the send direction of the stream, like Close, and
+// waits until the peer acknowledges all data sent on it. It returns an
+// error if the stream or connection is reset first, or ctx is done.
+func (s *Stream) CloseWait(ctx context.Context) error {
+	s.Close()
+	c := s.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		switch {
+		case !s.hasSend || s.finAcked && s.send.buffered() == 0:
+			return nil
+		case s.writeErr != nil:
+			return s.writeErr
+		case s.reset:
+			return errStreamClosed
+		case c.err != nil:
+			return c.err
+		}
+		ch := s.writable.wait()
+		c.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			c.mu.Lock()
+			return ctx.Err()
+		}
+		c.mu.Lock()
+	}
+}
+
+// CloseRead discards received data and asks the peer to stop sending,
+// using the application protocol error code code.
+func (s *Stream) CloseRead(code uint64) {
+	c := s.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !s.hasRecv || s.readClosed {
+		return
+	}
+	if s.recvErr == nil {
+		c.consumeData(s.recvHigh - s.recv.off)
+		if s.finalSize < 0 {
+			s.stopSendingPending = true
+			s.stopCode = code
+		}
+	}
+	s.readClosed = true
+	s.recv = recvBuffer{off: s.recvHigh}
+	s.readable.signal()
+	c.maybeRemoveStream(s)
+	c.wake()
+}
+
+// Reset aborts the send direction of the stream, discarding any
+// unsent data, using the application protocol error code code.
+func (s *Stream) Reset(code uint64) {
+	c := s.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !s.hasSend || s.reset || s.finAcked {
+		return
+	}
+	s.resetSend(code)
+	s.writeErr = errStreamClosed
+	c.wake()
+}
+
+// resetSend resets the send direction of the stream.
+func (s *Stream) resetSend(code uint64) {
+	s.reset = true
+	s.resetPending = true
+	s.resetCode = code
+	s.finPending = false
+	s.send.discard()
+	s.writable.signal()
+}
+
+// discardingRecv reports whether received data is being discarded.
+func (s *Stream) discardingRecv() bool {
+	return s.readClosed || s.recvErr != nil
+}
+
+// maybeRemoveStream removes a stream from the connection after
+// both directions are finished.
+func (c *Conn) maybeRemoveStream(s *Stream) {
+	if s.removed {
+		return
+	}
+	sendDone := !s.hasSend || s.resetAcked || s.finAcked && s.send.buffered() == 0
+	recvDone := !s.hasRecv || s.recvErr != nil ||
+		s.readClosed && !s.stopSendingPending ||
+		s.finalSize >= 0 && s.recv.off == s.finalSize
+	if !sendDone || !recvDone {
+		return
+	}
+	s.removed = true
+	delete(c.streams, s.id)
+	if streamIDSide(s.id) != c.side {
+		// Allow the peer to open another stream.
+		typ := streamIDType(s.id)
+		c.maxStreams[typ]++
+		c.maxStreamsPending[typ] = true
+		c.wake()
+	}
+}
+
+// OpenStream opens a new bidirectional stream.
+// It blocks while the peer's limit on open streams is reached.
+// The peer is not notified of the stream until data is sent on it.
+func (c *Conn) OpenStream(ctx context.Context) (*Stream, error) {
+	return c.openStream(ctx, bidiStream)
+}
+
+// OpenUniStream opens a new unidirectional stream.
+// It blocks while the peer's limit on open streams is reached.
+func (c *Conn) OpenUniStream(ctx context.Context) (*Stream, error) {
+	return c.openStream(ctx, uniStream)
+}
+
+func (c *Conn) openStream(ctx context.Context, typ streamType) (*Stream, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		if c.err != nil {
+			return nil, c.err
+		}
+		if c.localOpened[typ] < c.peerMaxStreams[typ] {
+			break
+		}
+		ch := c.streamsChanged.wait()
+		c.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			c.mu.Lock()
+			return nil, ctx.Err()
+		}
+		c.mu.Lock()
+	}
+	id := streamID(c.localOpened[typ], c.side, typ)
+	c.localOpened[typ]++
+	return c.newStream(id), nil
+}
+
+// AcceptStream waits for and returns the next bidirectional stream
+// opened by the peer.
+func (c *Conn) AcceptStream(ctx context.Context) (*Stream, error) {
+	return c.acceptStream(ctx, bidiStream)
+}
+
+// AcceptUniStream waits for and returns the next unidirectional stream
+// opened by the peer.
+func (c *Conn) AcceptUniStream(ctx context.Context) (*Stream, error) {
+	return c.acceptStream(ctx, uniStream)
+}
+
+func (c *Conn) acceptStream(ctx context.Context, typ streamType) (*Stream, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		if q := c.acceptq[typ]; len(q) > 0 {
+			s := q[0]
+			q[0] = nil
+			c.acceptq[typ] = q[1:]
+			return s, nil
+		}
+		if c.err != nil {
+			return nil, c.err
+		}
+		ch := c.streamsChanged.wait()
+		c.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			c.mu.Lock()
+			return nil, ctx.Err()
+		}
+		c.mu.Lock()
+	}
+}
+
+// streamForFrame returns the stream a received frame refers to,
+// opening peer-initiated streams as needed. It returns nil if the
+// stream has already been closed. peerSends reports whether the frame
+// concerns the peer's sending direction (STREAM, RESET_STREAM) rather
+// than ours (MAX_STREAM_DATA, STOP_SENDING).
+func (c *Conn) streamForFrame(id int64, peerSends bool) (*Stream, error) {
+	typ := streamIDType(id)
+	local := streamIDSide(id) == c.side
+	if typ == uniStream && local == peerSends {
+		return nil, newTransportError(errStreamState, "invalid frame for unidirectional stream %v", id)
+	}
+	if s := c.streams[id]; s != nil {
+		return s, nil
+	}
+	index := streamIDIndex(id)
+	if local {
+		if index >= c.localOpened[typ] {
+			return nil, newTransportError(errStreamState, "frame for unopened stream %v", id)
+		}
+		return nil, nil
+	}
+	if index < c.remoteOpened[typ] {
+		return nil, nil
+	}
+	if index >= c.maxStreams[typ] {
+		return nil, newTransportError(errStreamLimit, "stream %v exceeds limit", id)
+	}
+	peer := serverSide
+	if c.side == serverSide {
+		peer = clientSide
+	}
+	// Opening a stream implicitly opens all lower-numbered streams of the
+	// same type. See RFC 9000, Section 3.2.
+	for c.remoteOpened[typ] <= index {
+		s := c.newStream(streamID(c.remoteOpened[typ], peer, typ))
+		c.remoteOpened[typ]++
+		c.acceptq[typ] = append(c.acceptq[typ], s)
+	}
+	c.streamsChanged.signal()
+	return c.streams[id], nil
+}
+
+func (c *Conn) handleStreamFrame(id, off int64, data []byte, fin bool) error {
+	s, err := c.streamForFrame(id, true)
+	if s == nil {
+		return err
+	}
+	end := off + int64(len(data))
+	if s.finalSize >= 0 && (end > s.finalSize || fin && end != s.finalSize) || fin && end < s.recvHigh {
+		return newTransportError(errFinalSize, "inconsistent final size for stream %v", id)
+	}
+	if end > s.recvMax {
+		return newTransportError(errFlowControl, "stream %v exceeds flow control limit", id)
+	}
+	if err := c.recvStreamData(s, end); err != nil {
+		return err
+	}
+	if fin {
+		s.finalSize = end
+	}
+	if s.discardingRecv() {
+		return nil
+	}
+	s.recv.write(off, data)
+	s.readable.signal()
+	return nil
+}
+
+// recvStreamData updates flow control for data received up to offset end.
+func (c *Conn) recvStreamData(s *Stream, end int64) error {
+	if end <= s.recvHigh {
+		return nil
+	}
+	delta := end - s.recvHigh
+	s.recvHigh = end
+	c.recvData += delta
+	if c.recvData > c.maxData {
+		return newTransportError(errFlowControl, "connection exceeds flow control limit")
+	}
+	if s.discardingRecv() {
+		c.consumeData(delta)
+	}
+	return nil
+}
+
+func (c *Conn) handleResetStreamFrame(id int64, code uint64, size int64) error {
+	s, err := c.streamForFrame(id, true)
+	if s == nil {
+		return err
+	}
+	if s.finalSize >= 0 && size != s.finalSize || size < s.recvHigh {
+		return newTransportError(errFinalSize, "inconsistent final size for stream %v", id)
+	}
+	if size > s.recvMax {
+		return newTransportError(errFlowControl, "stream %v exceeds flow control limit", id)
+	}
+	if err := c.recvStreamData(s, size); err != nil {
+		return err
+	}
+	s.finalSize = size
+	if s.recvErr != nil {
+		return nil
+	}
+	if !s.readClosed {
+		c.consumeData(s.recvHigh - s.recv.off)
+	}
+	s.recvErr = &StreamError{Code: code}
+	s.recv = recvBuffer{off: s.recvHigh}
+	s.stopSendingPending = false
+	s.maxStreamDataPending = false
+	s.readable.signal()
+	c.maybeRemoveStream(s)
+	return nil
+}
+
+func (c *Conn) handleStopSendingFrame(id int64, code uint64) error {
+	s, err := c.streamForFrame(id, false)
+	if s == nil {
+		return err
+	}
+	if !s.reset && !s.finAcked {
+		// Respond with RESET_STREAM. See RFC 9000, Section 3.5.
+		s.resetSend(code)
+		s.writeErr = &StreamError{Code: code}
+	}
+	return nil
+}
+
+func (c *Conn) handleMaxStreamDataFrame(id, max int64) error {
+	s, err := c.streamForFrame(id, false)
+	if s == nil {
+		return err
+	}
+	if max > s.maxSendData {
+		s.maxSendData = max
+	}
+	return nil
+}
+
+// appendFrames appends frames for the stream to the packet payload b,
+// up to a total payload length of max.
+func (s *Stream) appendFrames(b []byte, max int, sp *sentPacket) []byte {
+	c := s.c
+	if s.resetPending {
+		if len(b)+sizeVarintFrame(uint64(s.id), s.resetCode, uint64(s.sentMax)) > max {
+			return b
+		}
+		b = appendVarintFrame(b, frameTypeResetStream, uint64(s.id), s.resetCode, uint64(s.sentMax))
+		s.resetPending = false
+		sp.frames = append(sp.frames, sentFrame{typ: frameTypeResetStream, id: s.id})
+	}
+	if s.stopSendingPending {
+		if len(b)+sizeVarintFrame(uint64(s.id), s.stopCode) > max {
+			return b
+		}
+		b = appendVarintFrame(b, frameTypeStopSending, uint64(s.id), s.stopCode)
+		s.stopSendingPending = false
+		sp.frames = append(sp.frames, sentFrame{typ: frameTypeStopSending, id: s.id})
+		c.maybeRemoveStream(s)
+	}
+	if s.maxStreamDataPending {
+		if len(b)+sizeVarintFrame(uint64(s.id), uint64(s.recvMax)) > max {
+			return b
+		}
+		b = appendVarintFrame(b, frameTypeMaxStreamData, uint64(s.id), uint64(s.recvMax))
+		s.maxStreamDataPending = false
+		sp.frames = append(sp.frames, sentFrame{typ: frameTypeMaxStreamData, id: s.id})
+	}
+	if !s.hasSend || s.reset {
+		return b
+	}
+	for {
+		limit := s.maxSendData
+		if connLimit := s.sentMax + c.peerMaxData - c.sentData; connLimit < limit {
+			limit = connLimit
+		}
+		end := s.send.end()
+		hasData := s.send.hasUnsent(limit)
+		finOnly := !hasData && s.finPending && !s.send.hasUnsent(end)
+		if !hasData && !finOnly {
+			return b
+		}
+		off := end
+		if hasData {
+			off = s.send.unsent[0].start
+		}
+		avail := max - len(b) - streamFrameOverhead(s.id, off)
+		if avail < 0 || hasData && avail == 0 {
+			return b
+		}
+		var data []byte
+		if hasData {
+			off, data = s.send.next(avail, limit)
+		}
+		frameEnd := off + int64(len(data))
+		fin := s.finPending && frameEnd == end
+		if fin {
+			s.finPending = false
+		}
+		b = appendStreamFrame(b, s.id, off, data, fin)
+		if frameEnd > s.sentMax {
+			c.sentData += frameEnd - s.sentMax
+			s.sentMax = frameEnd
+		}
+		sp.frames = append(sp.frames, sentFrame{
+			typ: frameTypeStreamBase,
+			id:  s.id,
+			off: off,
+			n:   int64(len(data)),
+			fin: fin,
+		})
+		if finOnly {
+			return b
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"time"
+)
+
+// transportParameters are the QUIC transport parameters sent in the
+// TLS handshake. See RFC 9000, Section 18.
+type transportParameters struct {
+	originalDstConnID              []byte
+	maxIdleTimeout                 time.Duration
+	statelessResetToken            []byte
+	maxUDPPayloadSize              int64
+	initialMaxData                 int64
+	initialMaxStreamDataBidiLocal  int64
+	initialMaxStreamDataBidiRemote int64
+	initialMaxStreamDataUni        int64
+	initialMaxStreamsBidi          int64
+	initialMaxStreamsUni           int64
+	ackDelayExponent               int64
+	maxAckDelay                    time.Duration
+	disableActiveMigration         bool
+	activeConnIDLimit              int64
+	initialSrcConnID               []byte
+	retrySrcConnID                 []byte
+
+	hasOriginalDstConnID bool
+	hasInitialSrcConnID  bool
+}
+
+const (
+	paramOriginalDstConnID              = 0x00
+	paramMaxIdleTimeout                 = 0x01
+	paramStatelessResetToken            = 0x02
+	paramMaxUDPPayloadSize              = 0x03
+	paramInitialMaxData                 = 0x04
+	paramInitialMaxStreamDataBidiLocal  = 0x05
+	paramInitialMaxStreamDataBidiRemote = 0x06
+	paramInitialMaxStreamDataUni        = 0x07
+	paramInitialMaxStreamsBidi          = 0x08
+	paramInitialMaxStreamsUni           = 0x09
+	paramAckDelayExponent               = 0x0a
+	paramMaxAckDelay                    = 0x0b
+	paramDisableActiveMigration         = 0x0c
+	paramPreferredAddress               = 0x0d
+	paramActiveConnectionIDLimit        = 0x0e
+	paramInitialSourceConnectionID      = 0x0f
+	paramRetrySourceConnectionID        = 0x10
+)
+
+// defaultTransportParameters returns the values of transport parameters
+// which are not sent.
+func defaultTransportParameters() transportParameters {
+	return transportParameters{
+		maxUDPPayloadSize: 65527,
+		ackDelayExponent:  3,
+		maxAckDelay:       25 * time.Millisecond,
+		activeConnIDLimit: 2,
+	}
+}
+
+func (p *transportParameters) marshal() []byte {
+	var b []byte
+	if p.hasOriginalDstConnID {
+		b = appendVarint(b, paramOriginalDstConnID)
+		b = appendVarintBytes(b, p.originalDstConnID)
+	}
+	appendInt := func(id uint64, v int64) {
+		b = appendVarint(b, id)
+		b = appendVarint(b, uint64(sizeVarint(uint64(v))))
+		b = appendVarint(b, uint64(v))
+	}
+	if p.maxIdleTimeout > 0 {
+		appendInt(paramMaxIdleTimeout, p.maxIdleTimeout.Milliseconds())
+	}
+	appendInt(paramInitialMaxData, p.initialMaxData)
+	appendInt(paramInitialMaxStreamDataBidiLocal, p.initialMaxStreamDataBidiLocal)
+	appendInt(paramInitialMaxStreamDataBidiRemote, p.initialMaxStreamDataBidiRemote)
+	appendInt(paramInitialMaxStreamDataUni, p.initialMaxStreamDataUni)
+	appendInt(paramInitialMaxStreamsBidi, p.initialMaxStreamsBidi)
+	appendInt(paramInitialMaxStreamsUni, p.initialMaxStreamsUni)
+	if p.disableActiveMigration {
+		b = appendVarint(b, paramDisableActiveMigration)
+		b = appendVarint(b, 0)
+	}
+	if p.hasInitialSrcConnID {
+		b = appendVarint(b, paramInitialSourceConnectionID)
+		b = appendVarintBytes(b, p.initialSrcConnID)
+	}
+	return b
+}
+
+// unmarshalTransportParameters parses the transport parameters sent by
+// the peer. isServer reports whether the parameters were sent by a server.
+func unmarshalTransportParameters(b []byte, isServer bool) (transportParameters, error) {
+	p := defaultTransportParameters()
+	seen := make(map[uint64]bool)
+	for len(b) > 0 {
+		id, n := consumeVarint(b)
+		if n < 0 {
+			return p, newTransportError(errTransportParameter, "malformed transport parameters")
+		}
+		b = b[n:]
+		val, n := consumeVarintBytes(b)
+		if n < 0 {
+			return p, newTransportError(errTransportParameter, "malformed transport parameters")
+		}
+		b = b[n:]
+		if seen[id] {
+			return p, newTransportError(errTransportParameter, "duplicate transport parameter %#x", id)
+		}
+		seen[id] = true
+		var v uint64
+		switch id {
+		case paramMaxIdleTimeout, paramMaxUDPPayloadSize, paramInitialMaxData,
+			paramInitialMaxStreamDataBidiLocal, paramInitialMaxStreamDataBidiRemote,
+			paramInitialMaxStreamDataUni, paramInitialMaxStreamsBidi, paramInitialMaxStreamsUni,
+			paramAckDelayExponent, paramMaxAckDelay, paramActiveConnectionIDLimit:
+			var n int
+			v, n = consumeVarint(val)
+			if n != len(val) {
+				return p, newTransportError(errTransportParameter, "malformed transport parameter %#x", id)
+			}
+		}
+		switch id {
+		case paramOriginalDstConnID, paramStatelessResetToken, paramPreferredAddress, paramRetrySourceConnectionID:
+			if !isServer {
+				return p, newTransportError(errTransportParameter, "client sent server-only transport parameter %#x", id)
+			}
+		}
+		switch id {
+		case paramOriginalDstConnID:
+			p.originalDstConnID = val
+			p.hasOriginalDstConnID = true
+		case paramMaxIdleTimeout:
+			p.maxIdleTimeout = time.Duration(v) * time.Millisecond
+		case paramStatelessResetToken:
+			if len(val) != 16 {
+				return p, newTransportError(errTransportParameter, "invalid stateless_reset_token")
+			}
+			p.statelessResetToken = val
+		case paramMaxUDPPayloadSize:
+			if v < 1200 {
+				return p, newTransportError(errTransportParameter, "invalid max_udp_payload_size")
+			}
+			p.maxUDPPayloadSize = int64(v)
+		case paramInitialMaxData:
+			p.initialMaxData = int64(v)
+		case paramInitialMaxStreamDataBidiLocal:
+			p.initialMaxStreamDataBidiLocal = int64(v)
+		case paramInitialMaxStreamDataBidiRemote:
+			p.initialMaxStreamDataBidiRemote = int64(v)
+		case paramInitialMaxStreamDataUni:
+			p.initialMaxStreamDataUni = int64(v)
+		case paramInitialMaxStreamsBidi:
+			if v > 1<<60 {
+				return p, newTransportError(errTransportParameter, "invalid initial_max_streams_bidi")
+			}
+			p.initialMaxStreamsBidi = int64(v)
+		case paramInitialMaxStreamsUni:
+			if v > 1<<60 {
+				return p, newTransportError(errTransportParameter, "invalid initial_max_streams_uni")
+			}
+			p.initialMaxStreamsUni = int64(v)
+		case paramAckDelayExponent:
+			if v > 20 {
+				return p, newTransportError(errTransportParameter, "invalid ack_delay_exponent")
+			}
+			p.ackDelayExponent = int64(v)
+		case paramMaxAckDelay:
+			if v >= 1<<14 {
+				return p, newTransportError(errTransportParameter, "invalid max_ack_delay")
+			}
+			p.maxAckDelay = time.Duration(v) * time.Millisecond
+		case paramDisableActiveMigration:
+			if len(val) != 0 {
+				return p, newTransportError(errTransportParameter, "invalid disable_active_migration")
+			}
+			p.disableActiveMigration = true
+		case paramActiveConnectionIDLimit:
+			if v < 2 {
+				return p, newTransportError(errTransportParameter, "invalid active_connection_id_limit")
+			}
+			p.activeConnIDLimit = int64(v)
+		case paramInitialSourceConnectionID:
+			p.initialSrcConnID = val
+			p.hasInitialSrcConnID = true
+		case paramRetrySourceConnectionID:
+			p.retrySrcConnID = val
+		}
+		// Unknown parameters are ignored.
+	}
+	return p, nil
+}
@@ -51,154 +51,189 @@ const (
 // If the UtilPerProc flag is not given, this always returns a single
 // utilization function. Otherwise, it returns one function per P.
 func MutatorUtilization(events []*Event, flags UtilFlags) [][]MutatorUtil {
-	if len(events) == 0 {
-		return nil
+	b := NewMutatorUtilizationBuilder(flags)
+	for _, ev := range events {
+		b.Event(ev)
 	}
+	return b.Finalize()
+}
+
+// A MutatorUtilizationBuilder computes the mutator utilization functions
+// returned by MutatorUtilization from the events of a trace passed to it
+// one at a time, so that the events need not be held in memory at once.
+type MutatorUtilizationBuilder struct {
+	flags UtilFlags
+	ps    []perP
+	stw   int
+	out   [][]MutatorUtil
+
+	assists map[uint64]bool
+	starts  map[uint64]*Event // start of the running goroutine
+	bgMark  map[uint64]bool
+	lastTs  int64
+	events  int
+}
+
+type perP struct {
+	// gc > 0 indicates that GC is active on this P.
+	gc int
+	// series the logical series number for this P. This
+	// is necessary because Ps may be removed and then
+	// re-added, and then the new P needs a new series.
+	series int
+}
 
-	type perP struct {
-		// gc > 0 indicates that GC is active on this P.
-		gc int
-		// series the logical series number for this P. This
-		// is necessary because Ps may be removed and then
-		// re-added, and then the new P needs a new series.
-		series int
+// NewMutatorUtilizationBuilder returns a MutatorUtilizationBuilder
+// expecting the first event of the trace.
+func NewMutatorUtilizationBuilder(flags UtilFlags) *MutatorUtilizationBuilder {
+	return &MutatorUtilizationBuilder{
+		flags:   flags,
+		out:     [][]MutatorUtil{},
+		assists: map[uint64]bool{},
+		starts:  map[uint64]*Event{},
+		bgMark:  map[uint64]bool{},
 	}
-	ps := []perP{}
-	stw := 0
-
-	out := [][]MutatorUtil{}
-	assists := map[uint64]bool{}
-	block := map[uint64]*Event{}
-	bgMark := map[uint64]bool{}
+}
 
-	for _, ev := range events {
-		switch ev.Type {
-		case EvGomaxprocs:
-			gomaxprocs := int(ev.Args[0])
-			if len(ps) > gomaxprocs {
-				if flags&UtilPerProc != 0 {
-					// End each P's series.
-					for _, p := range ps[gomaxprocs:] {
-						out[p.series] = addUtil(out[p.series], MutatorUtil{ev.Ts, 0})
-					}
-				}
-				ps = ps[:gomaxprocs]
-			}
-			for len(ps) < gomaxprocs {
-				// Start new P's series.
-				series := 0
-				if flags&UtilPerProc != 0 || len(out) == 0 {
-					series = len(out)
-					out = append(out, []MutatorUtil{{ev.Ts, 1}})
+// Event adds the next event of the trace to the utilization functions.
+func (b *MutatorUtilizationBuilder) Event(ev *Event) {
+	b.lastTs = ev.Ts
+	b.events++
+	switch ev.Type {
+	case EvGomaxprocs:
+		gomaxprocs := int(ev.Args[0])
+		if len(b.ps) > gomaxprocs {
+			if b.flags&UtilPerProc != 0 {
+				// End each P's series.
+				for _, p := range b.ps[gomaxprocs:] {
+					b.out[p.series] = addUtil(b.out[p.series], MutatorUtil{ev.Ts, 0})
 				}
-				ps = append(ps, perP{series: series})
-			}
-		case EvGCSTWStart:
-			if flags&UtilSTW != 0 {
-				stw++
-			}
-		case EvGCSTWDone:
-			if flags&UtilSTW != 0 {
-				stw--
-			}
-		case EvGCMarkAssistStart:
-			if flags&UtilAssist != 0 {
-				ps[ev.P].gc++
-				assists[ev.G] = true
 			}
-		case EvGCMarkAssistDone:
-			if flags&UtilAssist != 0 {
-				ps[ev.P].gc--
-				delete(assists, ev.G)
-			}
-		case EvGCSweepStart:
-			if flags&UtilSweep != 0 {
-				ps[ev.P].gc++
-			}
-		case EvGCSweepDone:
-			if flags&UtilSweep != 0 {
-				ps[ev.P].gc--
-			}
-		case EvGoStartLabel:
-			if flags&UtilBackground != 0 && strings.HasPrefix(ev.SArgs[0], "GC ") && ev.SArgs[0] != "GC (idle)" {
-				// Background mark worker.
-				//
-				// If we're in per-proc mode, we don't
-				// count dedicated workers because
-				// they kick all of the goroutines off
-				// that P, so don't directly
-				// contribute to goroutine latency.
-				if !(flags&UtilPerProc != 0 && ev.SArgs[0] == "GC (dedicated)") {
-					bgMark[ev.G] = true
-					ps[ev.P].gc++
-				}
-			}
-			fallthrough
-		case EvGoStart:
-			if assists[ev.G] {
-				// Unblocked during assist.
-				ps[ev.P].gc++
+			b.ps = b.ps[:gomaxprocs]
+		}
+		for len(b.ps) < gomaxprocs {
+			// Start new P's series.
+			series := 0
+			if b.flags&UtilPerProc != 0 || len(b.out) == 0 {
+				series = len(b.out)
+				b.out = append(b.out, []MutatorUtil{{ev.Ts, 1}})
 			}
-			block[ev.G] = ev.Link
-		default:
-			if ev != block[ev.G] {
-				continue
+			b.ps = append(b.ps, perP{series: series})
+		}
+	case EvGCSTWStart:
+		if b.flags&UtilSTW != 0 {
+			b.stw++
+		}
+	case EvGCSTWDone:
+		if b.flags&UtilSTW != 0 {
+			b.stw--
+		}
+	case EvGCMarkAssistStart:
+		if b.flags&UtilAssist != 0 {
+			b.ps[ev.P].gc++
+			b.assists[ev.G] = true
+		}
+	case EvGCMarkAssistDone:
+		if b.flags&UtilAssist != 0 {
+			b.ps[ev.P].gc--
+			delete(b.assists, ev.G)
+		}
+	case EvGCSweepStart:
+		if b.flags&UtilSweep != 0 {
+			b.ps[ev.P].gc++
+		}
+	case EvGCSweepDone:
+		if b.flags&UtilSweep != 0 {
+			b.ps[ev.P].gc--
+		}
+	case EvGoStartLabel:
+		if b.flags&UtilBackground != 0 && strings.HasPrefix(ev.SArgs[0], "GC ") && ev.SArgs[0] != "GC (idle)" {
+			// Background mark worker.
+			//
+			// If we're in per-proc mode, we don't
+			// count dedicated workers because
+			// they kick all of the goroutines off
+			// that P, so don't directly
+			// contribute to goroutine latency.
+			if !(b.flags&UtilPerProc != 0 && ev.SArgs[0] == "GC (dedicated)") {
+				b.bgMark[ev.G] = true
+				b.ps[ev.P].gc++
 			}
+		}
+		fallthrough
+	case EvGoStart:
+		if b.assists[ev.G] {
+			// Unblocked during assist.
+			b.ps[ev.P].gc++
+		}
+		// The event that blocks the goroutine is linked
+		// from this one once it is read.
+		b.starts[ev.G] = ev
+	default:
+		if start := b.starts[ev.G]; start == nil || start.Link != ev {
+			return
+		}
 
-			if assists[ev.G] {
-				// Blocked during assist.
-				ps[ev.P].gc--
-			}
-			if bgMark[ev.G] {
-				// Background mark worker done.
-				ps[ev.P].gc--
-				delete(bgMark, ev.G)
-			}
-			delete(block, ev.G)
+		if b.assists[ev.G] {
+			// Blocked during assist.
+			b.ps[ev.P].gc--
+		}
+		if b.bgMark[ev.G] {
+			// Background mark worker done.
+			b.ps[ev.P].gc--
+			delete(b.bgMark, ev.G)
 		}
+		delete(b.starts, ev.G)
+	}
 
-		if flags&UtilPerProc == 0 {
-			// Compute the current average utilization.
-			if len(ps) == 0 {
-				continue
-			}
-			gcPs := 0
-			if stw > 0 {
-				gcPs = len(ps)
-			} else {
-				for i := range ps {
-					if ps[i].gc > 0 {
-						gcPs++
-					}
+	if b.flags&UtilPerProc == 0 {
+		// Compute the current average utilization.
+		if len(b.ps) == 0 {
+			return
+		}
+		gcPs := 0
+		if b.stw > 0 {
+			gcPs = len(b.ps)
+		} else {
+			for i := range b.ps {
+				if b.ps[i].gc > 0 {
+					gcPs++
 				}
 			}
-			mu := MutatorUtil{ev.Ts, 1 - float64(gcPs)/float64(len(ps))}
+		}
+		mu := MutatorUtil{ev.Ts, 1 - float64(gcPs)/float64(len(b.ps))}
 
-			// Record the utilization change. (Since
-			// len(ps) == len(out), we know len(out) > 0.)
-			out[0] = addUtil(out[0], mu)
-		} else {
-			// Check for per-P utilization changes.
-			for i := range ps {
-				p := &ps[i]
-				util := 1.0
-				if stw > 0 || p.gc > 0 {
-					util = 0.0
-				}
-				out[p.series] = addUtil(out[p.series], MutatorUtil{ev.Ts, util})
+		// Record the utilization change. (Since
+		// len(ps) == len(out), we know len(out) > 0.)
+		b.out[0] = addUtil(b.out[0], mu)
+	} else {
+		// Check for per-P utilization changes.
+		for i := range b.ps {
+			p := &b.ps[i]
+			util := 1.0
+			if b.stw > 0 || p.gc > 0 {
+				util = 0.0
 			}
+			b.out[p.series] = addUtil(b.out[p.series], MutatorUtil{ev.Ts, util})
 		}
 	}
+}
 
+// Finalize returns the utilization functions once all the events of the
+// trace have been passed to Event.
+func (b *MutatorUtilizationBuilder) Finalize() [][]MutatorUtil {
+	if b.events == 0 {
+		return nil
+	}
 	// Add final 0 utilization event to any remaining series. This
 	// is important to mark the end of the trace. The exact value
 	// shouldn't matter since no window should extend beyond this,
 	// but using 0 is symmetric with the start of the trace.
-	mu := MutatorUtil{events[len(events)-1].Ts, 0}
-	for i := range ps {
-		out[ps[i].series] = addUtil(out[ps[i].series], mu)
+	mu := MutatorUtil{b.lastTs, 0}
+	for i := range b.ps {
+		b.out[b.ps[i].series] = addUtil(b.out[b.ps[i].series], mu)
 	}
-	return out
+	return b.out
 }
 
 func addUtil(util []MutatorUtil, mu MutatorUtil) []MutatorUtil {
@@ -149,152 +149,176 @@ type gdesc struct {
 
 // GoroutineStats generates statistics for all goroutines in the trace.
 func GoroutineStats(events []*Event) map[uint64]*GDesc {
-	gs := make(map[uint64]*GDesc)
-	var lastTs int64
-	var gcStartTime int64 // gcStartTime == 0 indicates gc is inactive.
+	b := NewGoroutineStatsBuilder()
 	for _, ev := range events {
-		lastTs = ev.Ts
-		switch ev.Type {
-		case EvGoCreate:
-			g := &GDesc{ID: ev.Args[0], CreationTime: ev.Ts, gdesc: new(gdesc)}
-			g.blockSchedTime = ev.Ts
-			// When a goroutine is newly created, inherit the
-			// task of the active region. For ease handling of
-			// this case, we create a fake region description with
-			// the task id.
-			if creatorG := gs[ev.G]; creatorG != nil && len(creatorG.gdesc.activeRegions) > 0 {
-				regions := creatorG.gdesc.activeRegions
-				s := regions[len(regions)-1]
-				if s.TaskID != 0 {
-					g.gdesc.activeRegions = []*UserRegionDesc{
-						{TaskID: s.TaskID, Start: ev},
-					}
+		b.Event(ev)
+	}
+	return b.Finalize()
+}
+
+// A GoroutineStatsBuilder generates the statistics returned by
+// GoroutineStats from the events of a trace passed to it one at a time,
+// so that the events need not be held in memory at once.
+type GoroutineStatsBuilder struct {
+	gs          map[uint64]*GDesc
+	lastTs      int64
+	gcStartTime int64 // gcStartTime == 0 indicates gc is inactive.
+}
+
+// NewGoroutineStatsBuilder returns a GoroutineStatsBuilder
+// expecting the first event of the trace.
+func NewGoroutineStatsBuilder() *GoroutineStatsBuilder {
+	return &GoroutineStatsBuilder{gs: make(map[uint64]*GDesc)}
+}
+
+// Event adds the next event of the trace to the statistics.
+func (b *GoroutineStatsBuilder) Event(ev *Event) {
+	gs := b.gs
+	b.lastTs = ev.Ts
+	switch ev.Type {
+	case EvGoCreate:
+		g := &GDesc{ID: ev.Args[0], CreationTime: ev.Ts, gdesc: new(gdesc)}
+		g.blockSchedTime = ev.Ts
+		// When a goroutine is newly created, inherit the
+		// task of the active region. For ease handling of
+		// this case, we create a fake region description with
+		// the task id.
+		if creatorG := gs[ev.G]; creatorG != nil && len(creatorG.gdesc.activeRegions) > 0 {
+			regions := creatorG.gdesc.activeRegions
+			s := regions[len(regions)-1]
+			if s.TaskID != 0 {
+				g.gdesc.activeRegions = []*UserRegionDesc{
+					{TaskID: s.TaskID, Start: ev},
 				}
 			}
-			gs[g.ID] = g
-		case EvGoStart, EvGoStartLabel:
-			g := gs[ev.G]
-			if g.PC == 0 {
-				g.PC = ev.Stk[0].PC
-				g.Name = ev.Stk[0].Fn
-			}
-			g.lastStartTime = ev.Ts
-			if g.StartTime == 0 {
-				g.StartTime = ev.Ts
-			}
-			if g.blockSchedTime != 0 {
-				g.SchedWaitTime += ev.Ts - g.blockSchedTime
-				g.blockSchedTime = 0
-			}
-		case EvGoEnd, EvGoStop:
-			g := gs[ev.G]
-			g.finalize(ev.Ts, gcStartTime, ev)
-		case EvGoBlockSend, EvGoBlockRecv, EvGoBlockSelect,
-			EvGoBlockSync, EvGoBlockCond:
-			g := gs[ev.G]
-			g.ExecTime += ev.Ts - g.lastStartTime
-			g.lastStartTime = 0
-			g.blockSyncTime = ev.Ts
-		case EvGoSched, EvGoPreempt:
-			g := gs[ev.G]
-			g.ExecTime += ev.Ts - g.lastStartTime
-			g.lastStartTime = 0
-			g.blockSchedTime = ev.Ts
-		case EvGoSleep, EvGoBlock:
-			g := gs[ev.G]
-			g.ExecTime += ev.Ts - g.lastStartTime
-			g.lastStartTime = 0
-		case EvGoBlockNet:
-			g := gs[ev.G]
-			g.ExecTime += ev.Ts - g.lastStartTime
-			g.lastStartTime = 0
-			g.blockNetTime = ev.Ts
-		case EvGoBlockGC:
-			g := gs[ev.G]
-			g.ExecTime += ev.Ts - g.lastStartTime
-			g.lastStartTime = 0
-			g.blockGCTime = ev.Ts
-		case EvGoUnblock:
-			g := gs[ev.Args[0]]
-			if g.blockNetTime != 0 {
-				g.IOTime += ev.Ts - g.blockNetTime
-				g.blockNetTime = 0
-			}
-			if g.blockSyncTime != 0 {
-				g.BlockTime += ev.Ts - g.blockSyncTime
-				g.blockSyncTime = 0
-			}
-			g.blockSchedTime = ev.Ts
-		case EvGoSysBlock:
-			g := gs[ev.G]
-			g.ExecTime += ev.Ts - g.lastStartTime
-			g.lastStartTime = 0
-			g.blockSyscallTime = ev.Ts
-		case EvGoSysExit:
-			g := gs[ev.G]
-			if g.blockSyscallTime != 0 {
-				g.SyscallTime += ev.Ts - g.blockSyscallTime
-				g.blockSyscallTime = 0
-			}
-			g.blockSchedTime = ev.Ts
-		case EvGCSweepStart:
-			g := gs[ev.G]
-			if g != nil {
-				// Sweep can happen during GC on system goroutine.
-				g.blockSweepTime = ev.Ts
-			}
-		case EvGCSweepDone:
-			g := gs[ev.G]
-			if g != nil && g.blockSweepTime != 0 {
-				g.SweepTime += ev.Ts - g.blockSweepTime
-				g.blockSweepTime = 0
+		}
+		gs[g.ID] = g
+	case EvGoStart, EvGoStartLabel:
+		g := gs[ev.G]
+		if g.PC == 0 {
+			g.PC = ev.Stk[0].PC
+			g.Name = ev.Stk[0].Fn
+		}
+		g.lastStartTime = ev.Ts
+		if g.StartTime == 0 {
+			g.StartTime = ev.Ts
+		}
+		if g.blockSchedTime != 0 {
+			g.SchedWaitTime += ev.Ts - g.blockSchedTime
+			g.blockSchedTime = 0
+		}
+	case EvGoEnd, EvGoStop:
+		g := gs[ev.G]
+		g.finalize(ev.Ts, b.gcStartTime, ev)
+	case EvGoBlockSend, EvGoBlockRecv, EvGoBlockSelect,
+		EvGoBlockSync, EvGoBlockCond:
+		g := gs[ev.G]
+		g.ExecTime += ev.Ts - g.lastStartTime
+		g.lastStartTime = 0
+		g.blockSyncTime = ev.Ts
+	case EvGoSched, EvGoPreempt:
+		g := gs[ev.G]
+		g.ExecTime += ev.Ts - g.lastStartTime
+		g.lastStartTime = 0
+		g.blockSchedTime = ev.Ts
+	case EvGoSleep, EvGoBlock:
+		g := gs[ev.G]
+		g.ExecTime += ev.Ts - g.lastStartTime
+		g.lastStartTime = 0
+	case EvGoBlockNet:
+		g := gs[ev.G]
+		g.ExecTime += ev.Ts - g.lastStartTime
+		g.lastStartTime = 0
+		g.blockNetTime = ev.Ts
+	case EvGoBlockGC:
+		g := gs[ev.G]
+		g.ExecTime += ev.Ts - g.lastStartTime
+		g.lastStartTime = 0
+		g.blockGCTime = ev.Ts
+	case EvGoUnblock:
+		g := gs[ev.Args[0]]
+		if g.blockNetTime != 0 {
+			g.IOTime += ev.Ts - g.blockNetTime
+			g.blockNetTime = 0
+		}
+		if g.blockSyncTime != 0 {
+			g.BlockTime += ev.Ts - g.blockSyncTime
+			g.blockSyncTime = 0
+		}
+		g.blockSchedTime = ev.Ts
+	case EvGoSysBlock:
+		g := gs[ev.G]
+		g.ExecTime += ev.Ts - g.lastStartTime
+		g.lastStartTime = 0
+		g.blockSyscallTime = ev.Ts
+	case EvGoSysExit:
+		g := gs[ev.G]
+		if g.blockSyscallTime != 0 {
+			g.SyscallTime += ev.Ts - g.blockSyscallTime
+			g.blockSyscallTime = 0
+		}
+		g.blockSchedTime = ev.Ts
+	case EvGCSweepStart:
+		g := gs[ev.G]
+		if g != nil {
+			// Sweep can happen during GC on system goroutine.
+			g.blockSweepTime = ev.Ts
+		}
+	case EvGCSweepDone:
+		g := gs[ev.G]
+		if g != nil && g.blockSweepTime != 0 {
+			g.SweepTime += ev.Ts - g.blockSweepTime
+			g.blockSweepTime = 0
+		}
+	case EvGCStart:
+		b.gcStartTime = ev.Ts
+	case EvGCDone:
+		for _, g := range gs {
+			if g.EndTime != 0 {
+				continue
 			}
-		case EvGCStart:
-			gcStartTime = ev.Ts
-		case EvGCDone:
-			for _, g := range gs {
-				if g.EndTime != 0 {
-					continue
-				}
-				if gcStartTime < g.CreationTime {
-					g.GCTime += ev.Ts - g.CreationTime
-				} else {
-					g.GCTime += ev.Ts - gcStartTime
-				}
+			if b.gcStartTime < g.CreationTime {
+				g.GCTime += ev.Ts - g.CreationTime
+			} else {
+				g.GCTime += ev.Ts - b.gcStartTime
 			}
-			gcStartTime = 0 // indicates gc is inactive.
-		case EvUserRegion:
-			g := gs[ev.G]
-			switch mode := ev.Args[1]; mode {
-			case 0: // region start
-				g.activeRegions = append(g.activeRegions, &UserRegionDesc{
-					Name:           ev.SArgs[0],
-					TaskID:         ev.Args[0],
-					Start:          ev,
-					GExecutionStat: g.snapshotStat(lastTs, gcStartTime),
-				})
-			case 1: // region end
-				var sd *UserRegionDesc
-				if regionStk := g.activeRegions; len(regionStk) > 0 {
-					n := len(regionStk)
-					sd = regionStk[n-1]
-					regionStk = regionStk[:n-1] // pop
-					g.activeRegions = regionStk
-				} else {
-					sd = &UserRegionDesc{
-						Name:   ev.SArgs[0],
-						TaskID: ev.Args[0],
-					}
+		}
+		b.gcStartTime = 0 // indicates gc is inactive.
+	case EvUserRegion:
+		g := gs[ev.G]
+		switch mode := ev.Args[1]; mode {
+		case 0: // region start
+			g.activeRegions = append(g.activeRegions, &UserRegionDesc{
+				Name:           ev.SArgs[0],
+				TaskID:         ev.Args[0],
+				Start:          ev,
+				GExecutionStat: g.snapshotStat(b.lastTs, b.gcStartTime),
+			})
+		case 1: // region end
+			var sd *UserRegionDesc
+			if regionStk := g.activeRegions; len(regionStk) > 0 {
+				n := len(regionStk)
+				sd = regionStk[n-1]
+				regionStk = regionStk[:n-1] // pop
+				g.activeRegions = regionStk
+			} else {
+				sd = &UserRegionDesc{
+					Name:   ev.SArgs[0],
+					TaskID: ev.Args[0],
 				}
-				sd.GExecutionStat = g.snapshotStat(lastTs, gcStartTime).sub(sd.GExecutionStat)
-				sd.End = ev
-				g.Regions = append(g.Regions, sd)
 			}
+			sd.GExecutionStat = g.snapshotStat(b.lastTs, b.gcStartTime).sub(sd.GExecutionStat)
+			sd.End = ev
+			g.Regions = append(g.Regions, sd)
 		}
 	}
+}
 
-	for _, g := range gs {
-		g.finalize(lastTs, gcStartTime, nil)
+// Finalize returns the statistics of the goroutines once all the events
+// of the trace have been passed to Event.
+func (b *GoroutineStatsBuilder) Finalize() map[uint64]*GDesc {
+	for _, g := range b.gs {
+		g.finalize(b.lastTs, b.gcStartTime, nil)
 
 		// sort based on region start time
 		sort.Slice(g.Regions, func(i, j int) bool {
@@ -312,7 +336,7 @@ func GoroutineStats(events []*Event) map[uint64]*GDesc {
 		g.gdesc = nil
 	}
 
-	return gs
+	return b.gs
 }
 
 // RelatedGoroutines finds a set of goroutines related to goroutine goid.
@@ -100,7 +100,15 @@ func Parse(r io.Reader, bin string) (ParseResult, error) {
 // parse parses, post-processes and verifies the trace. It returns the
 // trace version and the list of events.
 func parse(r io.Reader, bin string) (int, ParseResult, error) {
-	ver, rawEvents, strings, err := readTrace(r)
+	br := bufio.NewReader(r)
+	if ver := streamVersion(br); ver != 0 {
+		res, err := parseGenerations(ver, br)
+		if err != nil {
+			return 0, ParseResult{}, err
+		}
+		return ver, res, nil
+	}
+	ver, rawEvents, strings, err := readTrace(br)
 	if err != nil {
 		return 0, ParseResult{}, err
 	}
@@ -561,289 +569,312 @@ var ErrTimeOrder = fmt.Errorf("time stamps out of order")
 // (for example, a P does not run two Gs at the same time, or a G is indeed
 // blocked before an unblock event).
 func postProcessTrace(ver int, events []*Event) error {
-	const (
-		gDead = iota
-		gRunnable
-		gRunning
-		gWaiting
-	)
-	type gdesc struct {
-		state        int
-		ev           *Event
-		evStart      *Event
-		evCreate     *Event
-		evMarkAssist *Event
+	pp := newPostProcessor(ver)
+	for _, ev := range events {
+		if err := pp.process(ev); err != nil {
+			return err
+		}
+	}
+
+	// TODO(dvyukov): restore stacks for EvGoStart events.
+	// TODO(dvyukov): test that all EvGoStart events has non-nil Link.
+
+	return nil
+}
+
+// postProcessor holds the state of postProcessTrace,
+// which processes the events one at a time.
+type postProcessor struct {
+	ver           int
+	gs            map[uint64]ppG
+	ps            map[int]ppP
+	tasks         map[uint64]*Event   // task id to task creation events
+	activeRegions map[uint64][]*Event // goroutine id to stack of regions
+	evGC, evSTW   *Event
+}
+
+// ppG is the state of a goroutine in postProcessor.
+type ppG struct {
+	state        gStatus
+	ev           *Event
+	evStart      *Event
+	evCreate     *Event
+	evMarkAssist *Event
+}
+
+// ppP is the state of a P in postProcessor.
+type ppP struct {
+	running bool
+	g       uint64
+	evSTW   *Event
+	evSweep *Event
+}
+
+func newPostProcessor(ver int) *postProcessor {
+	pp := &postProcessor{
+		ver:           ver,
+		gs:            make(map[uint64]ppG),
+		ps:            make(map[int]ppP),
+		tasks:         make(map[uint64]*Event),
+		activeRegions: make(map[uint64][]*Event),
+	}
+	pp.gs[0] = ppG{state: gRunning}
+	return pp
+}
+
+func checkRunning(p ppP, g ppG, ev *Event, allowG0 bool) error {
+	name := EventDescriptions[ev.Type].Name
+	if g.state != gRunning {
+		return fmt.Errorf("g %v is not running while %v (offset %v, time %v)", ev.G, name, ev.Off, ev.Ts)
 	}
-	type pdesc struct {
-		running bool
-		g       uint64
-		evSTW   *Event
-		evSweep *Event
+	if p.g != ev.G {
+		return fmt.Errorf("p %v is not running g %v while %v (offset %v, time %v)", ev.P, ev.G, name, ev.Off, ev.Ts)
 	}
+	if !allowG0 && ev.G == 0 {
+		return fmt.Errorf("g 0 did %v (offset %v, time %v)", EventDescriptions[ev.Type].Name, ev.Off, ev.Ts)
+	}
+	return nil
+}
 
-	gs := make(map[uint64]gdesc)
-	ps := make(map[int]pdesc)
-	tasks := make(map[uint64]*Event)           // task id to task creation events
-	activeRegions := make(map[uint64][]*Event) // goroutine id to stack of regions
-	gs[0] = gdesc{state: gRunning}
-	var evGC, evSTW *Event
+// process verifies ev against the events processed before it
+// and links them together.
+func (pp *postProcessor) process(ev *Event) error {
+	g := pp.gs[ev.G]
+	p := pp.ps[ev.P]
 
-	checkRunning := func(p pdesc, g gdesc, ev *Event, allowG0 bool) error {
-		name := EventDescriptions[ev.Type].Name
-		if g.state != gRunning {
-			return fmt.Errorf("g %v is not running while %v (offset %v, time %v)", ev.G, name, ev.Off, ev.Ts)
+	switch ev.Type {
+	case EvProcStart:
+		if p.running {
+			return fmt.Errorf("p %v is running before start (offset %v, time %v)", ev.P, ev.Off, ev.Ts)
 		}
-		if p.g != ev.G {
-			return fmt.Errorf("p %v is not running g %v while %v (offset %v, time %v)", ev.P, ev.G, name, ev.Off, ev.Ts)
+		p.running = true
+	case EvProcStop:
+		if !p.running {
+			return fmt.Errorf("p %v is not running before stop (offset %v, time %v)", ev.P, ev.Off, ev.Ts)
 		}
-		if !allowG0 && ev.G == 0 {
-			return fmt.Errorf("g 0 did %v (offset %v, time %v)", EventDescriptions[ev.Type].Name, ev.Off, ev.Ts)
+		if p.g != 0 {
+			return fmt.Errorf("p %v is running a goroutine %v during stop (offset %v, time %v)", ev.P, p.g, ev.Off, ev.Ts)
 		}
-		return nil
-	}
-
-	for _, ev := range events {
-		g := gs[ev.G]
-		p := ps[ev.P]
-
-		switch ev.Type {
-		case EvProcStart:
-			if p.running {
-				return fmt.Errorf("p %v is running before start (offset %v, time %v)", ev.P, ev.Off, ev.Ts)
-			}
-			p.running = true
-		case EvProcStop:
-			if !p.running {
-				return fmt.Errorf("p %v is not running before stop (offset %v, time %v)", ev.P, ev.Off, ev.Ts)
-			}
-			if p.g != 0 {
-				return fmt.Errorf("p %v is running a goroutine %v during stop (offset %v, time %v)", ev.P, p.g, ev.Off, ev.Ts)
-			}
-			p.running = false
-		case EvGCStart:
-			if evGC != nil {
-				return fmt.Errorf("previous GC is not ended before a new one (offset %v, time %v)", ev.Off, ev.Ts)
-			}
-			evGC = ev
-			// Attribute this to the global GC state.
-			ev.P = GCP
-		case EvGCDone:
-			if evGC == nil {
-				return fmt.Errorf("bogus GC end (offset %v, time %v)", ev.Off, ev.Ts)
-			}
-			evGC.Link = ev
-			evGC = nil
-		case EvGCSTWStart:
-			evp := &evSTW
-			if ver < 1010 {
-				// Before 1.10, EvGCSTWStart was per-P.
-				evp = &p.evSTW
-			}
-			if *evp != nil {
-				return fmt.Errorf("previous STW is not ended before a new one (offset %v, time %v)", ev.Off, ev.Ts)
-			}
-			*evp = ev
-		case EvGCSTWDone:
-			evp := &evSTW
-			if ver < 1010 {
-				// Before 1.10, EvGCSTWDone was per-P.
-				evp = &p.evSTW
-			}
-			if *evp == nil {
-				return fmt.Errorf("bogus STW end (offset %v, time %v)", ev.Off, ev.Ts)
-			}
-			(*evp).Link = ev
-			*evp = nil
-		case EvGCSweepStart:
-			if p.evSweep != nil {
-				return fmt.Errorf("previous sweeping is not ended before a new one (offset %v, time %v)", ev.Off, ev.Ts)
-			}
-			p.evSweep = ev
-		case EvGCMarkAssistStart:
-			if g.evMarkAssist != nil {
-				return fmt.Errorf("previous mark assist is not ended before a new one (offset %v, time %v)", ev.Off, ev.Ts)
-			}
-			g.evMarkAssist = ev
-		case EvGCMarkAssistDone:
-			// Unlike most events, mark assists can be in progress when a
-			// goroutine starts tracing, so we can't report an error here.
-			if g.evMarkAssist != nil {
-				g.evMarkAssist.Link = ev
-				g.evMarkAssist = nil
-			}
-		case EvGCSweepDone:
-			if p.evSweep == nil {
-				return fmt.Errorf("bogus sweeping end (offset %v, time %v)", ev.Off, ev.Ts)
-			}
-			p.evSweep.Link = ev
-			p.evSweep = nil
-		case EvGoWaiting:
-			if g.state != gRunnable {
-				return fmt.Errorf("g %v is not runnable before EvGoWaiting (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
-			}
-			g.state = gWaiting
-			g.ev = ev
-		case EvGoInSyscall:
-			if g.state != gRunnable {
-				return fmt.Errorf("g %v is not runnable before EvGoInSyscall (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
-			}
-			g.state = gWaiting
-			g.ev = ev
-		case EvGoCreate:
-			if err := checkRunning(p, g, ev, true); err != nil {
-				return err
-			}
-			if _, ok := gs[ev.Args[0]]; ok {
-				return fmt.Errorf("g %v already exists (offset %v, time %v)", ev.Args[0], ev.Off, ev.Ts)
-			}
-			gs[ev.Args[0]] = gdesc{state: gRunnable, ev: ev, evCreate: ev}
-		case EvGoStart, EvGoStartLabel:
-			if g.state != gRunnable {
-				return fmt.Errorf("g %v is not runnable before start (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
-			}
-			if p.g != 0 {
-				return fmt.Errorf("p %v is already running g %v while start g %v (offset %v, time %v)", ev.P, p.g, ev.G, ev.Off, ev.Ts)
-			}
-			g.state = gRunning
-			g.evStart = ev
-			p.g = ev.G
-			if g.evCreate != nil {
-				if ver < 1007 {
-					// +1 because symbolizer expects return pc.
-					ev.Stk = []*Frame{{PC: g.evCreate.Args[1] + 1}}
-				} else {
-					ev.StkID = g.evCreate.Args[1]
-				}
-				g.evCreate = nil
+		p.running = false
+	case EvGCStart:
+		if pp.evGC != nil {
+			return fmt.Errorf("previous GC is not ended before a new one (offset %v, time %v)", ev.Off, ev.Ts)
+		}
+		pp.evGC = ev
+		// Attribute this to the global GC state.
+		ev.P = GCP
+	case EvGCDone:
+		if pp.evGC == nil {
+			return fmt.Errorf("bogus GC end (offset %v, time %v)", ev.Off, ev.Ts)
+		}
+		pp.evGC.Link = ev
+		pp.evGC = nil
+	case EvGCSTWStart:
+		evp := &pp.evSTW
+		if pp.ver < 1010 {
+			// Before 1.10, EvGCSTWStart was per-P.
+			evp = &p.evSTW
+		}
+		if *evp != nil {
+			return fmt.Errorf("previous STW is not ended before a new one (offset %v, time %v)", ev.Off, ev.Ts)
+		}
+		*evp = ev
+	case EvGCSTWDone:
+		evp := &pp.evSTW
+		if pp.ver < 1010 {
+			// Before 1.10, EvGCSTWDone was per-P.
+			evp = &p.evSTW
+		}
+		if *evp == nil {
+			return fmt.Errorf("bogus STW end (offset %v, time %v)", ev.Off, ev.Ts)
+		}
+		(*evp).Link = ev
+		*evp = nil
+	case EvGCSweepStart:
+		if p.evSweep != nil {
+			return fmt.Errorf("previous sweeping is not ended before a new one (offset %v, time %v)", ev.Off, ev.Ts)
+		}
+		p.evSweep = ev
+	case EvGCMarkAssistStart:
+		if g.evMarkAssist != nil {
+			return fmt.Errorf("previous mark assist is not ended before a new one (offset %v, time %v)", ev.Off, ev.Ts)
+		}
+		g.evMarkAssist = ev
+	case EvGCMarkAssistDone:
+		// Unlike most events, mark assists can be in progress when a
+		// goroutine starts tracing, so we can't report an error here.
+		if g.evMarkAssist != nil {
+			g.evMarkAssist.Link = ev
+			g.evMarkAssist = nil
+		}
+	case EvGCSweepDone:
+		if p.evSweep == nil {
+			return fmt.Errorf("bogus sweeping end (offset %v, time %v)", ev.Off, ev.Ts)
+		}
+		p.evSweep.Link = ev
+		p.evSweep = nil
+	case EvGoWaiting:
+		if g.state != gRunnable {
+			return fmt.Errorf("g %v is not runnable before EvGoWaiting (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
+		}
+		g.state = gWaiting
+		g.ev = ev
+	case EvGoInSyscall:
+		if g.state != gRunnable {
+			return fmt.Errorf("g %v is not runnable before EvGoInSyscall (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
+		}
+		g.state = gWaiting
+		g.ev = ev
+	case EvGoCreate:
+		if err := checkRunning(p, g, ev, true); err != nil {
+			return err
+		}
+		if _, ok := pp.gs[ev.Args[0]]; ok {
+			return fmt.Errorf("g %v already exists (offset %v, time %v)", ev.Args[0], ev.Off, ev.Ts)
+		}
+		pp.gs[ev.Args[0]] = ppG{state: gRunnable, ev: ev, evCreate: ev}
+	case EvGoStart, EvGoStartLabel:
+		if g.state != gRunnable {
+			return fmt.Errorf("g %v is not runnable before start (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
+		}
+		if p.g != 0 {
+			return fmt.Errorf("p %v is already running g %v while start g %v (offset %v, time %v)", ev.P, p.g, ev.G, ev.Off, ev.Ts)
+		}
+		g.state = gRunning
+		g.evStart = ev
+		p.g = ev.G
+		if g.evCreate != nil {
+			if pp.ver < 1007 {
+				// +1 because symbolizer expects return pc.
+				ev.Stk = []*Frame{{PC: g.evCreate.Args[1] + 1}}
+			} else {
+				ev.StkID = g.evCreate.Args[1]
 			}
+			g.evCreate = nil
+		}
 
-			if g.ev != nil {
-				g.ev.Link = ev
-				g.ev = nil
-			}
-		case EvGoEnd, EvGoStop:
-			if err := checkRunning(p, g, ev, false); err != nil {
-				return err
-			}
-			g.evStart.Link = ev
-			g.evStart = nil
-			g.state = gDead
-			p.g = 0
+		if g.ev != nil {
+			g.ev.Link = ev
+			g.ev = nil
+		}
+	case EvGoEnd, EvGoStop:
+		if err := checkRunning(p, g, ev, false); err != nil {
+			return err
+		}
+		g.evStart.Link = ev
+		g.evStart = nil
+		g.state = gDead
+		p.g = 0
 
-			if ev.Type == EvGoEnd { // flush all active regions
-				regions := activeRegions[ev.G]
-				for _, s := range regions {
-					s.Link = ev
-				}
-				delete(activeRegions, ev.G)
+		if ev.Type == EvGoEnd { // flush all active regions
+			regions := pp.activeRegions[ev.G]
+			for _, s := range regions {
+				s.Link = ev
 			}
+			delete(pp.activeRegions, ev.G)
+		}
 
-		case EvGoSched, EvGoPreempt:
-			if err := checkRunning(p, g, ev, false); err != nil {
-				return err
-			}
-			g.state = gRunnable
-			g.evStart.Link = ev
-			g.evStart = nil
-			p.g = 0
-			g.ev = ev
-		case EvGoUnblock:
-			if g.state != gRunning {
-				return fmt.Errorf("g %v is not running while unpark (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
-			}
-			if ev.P != TimerP && p.g != ev.G {
-				return fmt.Errorf("p %v is not running g %v while unpark (offset %v, time %v)", ev.P, ev.G, ev.Off, ev.Ts)
-			}
-			g1 := gs[ev.Args[0]]
-			if g1.state != gWaiting {
-				return fmt.Errorf("g %v is not waiting before unpark (offset %v, time %v)", ev.Args[0], ev.Off, ev.Ts)
-			}
-			if g1.ev != nil && g1.ev.Type == EvGoBlockNet && ev.P != TimerP {
-				ev.P = NetpollP
-			}
-			if g1.ev != nil {
-				g1.ev.Link = ev
-			}
-			g1.state = gRunnable
-			g1.ev = ev
-			gs[ev.Args[0]] = g1
-		case EvGoSysCall:
-			if err := checkRunning(p, g, ev, false); err != nil {
-				return err
-			}
-			g.ev = ev
-		case EvGoSysBlock:
-			if err := checkRunning(p, g, ev, false); err != nil {
-				return err
-			}
-			g.state = gWaiting
-			g.evStart.Link = ev
-			g.evStart = nil
-			p.g = 0
-		case EvGoSysExit:
-			if g.state != gWaiting {
-				return fmt.Errorf("g %v is not waiting during syscall exit (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
-			}
-			if g.ev != nil && g.ev.Type == EvGoSysCall {
-				g.ev.Link = ev
-			}
-			g.state = gRunnable
-			g.ev = ev
-		case EvGoSleep, EvGoBlock, EvGoBlockSend, EvGoBlockRecv,
-			EvGoBlockSelect, EvGoBlockSync, EvGoBlockCond, EvGoBlockNet, EvGoBlockGC:
-			if err := checkRunning(p, g, ev, false); err != nil {
-				return err
-			}
-			g.state = gWaiting
-			g.ev = ev
-			g.evStart.Link = ev
-			g.evStart = nil
-			p.g = 0
-		case EvUserTaskCreate:
-			taskid := ev.Args[0]
-			if prevEv, ok := tasks[taskid]; ok {
-				return fmt.Errorf("task id conflicts (id:%d), %q vs %q", taskid, ev, prevEv)
-			}
-			tasks[ev.Args[0]] = ev
-		case EvUserTaskEnd:
-			taskid := ev.Args[0]
-			if taskCreateEv, ok := tasks[taskid]; ok {
-				taskCreateEv.Link = ev
-				delete(tasks, taskid)
-			}
-		case EvUserRegion:
-			mode := ev.Args[1]
-			regions := activeRegions[ev.G]
-			if mode == 0 { // region start
-				activeRegions[ev.G] = append(regions, ev) // push
-			} else if mode == 1 { // region end
-				n := len(regions)
-				if n > 0 { // matching region start event is in the trace.
-					s := regions[n-1]
-					if s.Args[0] != ev.Args[0] || s.SArgs[0] != ev.SArgs[0] { // task id, region name mismatch
-						return fmt.Errorf("misuse of region in goroutine %d: span end %q when the inner-most active span start event is %q", ev.G, ev, s)
-					}
-					// Link region start event with span end event
-					s.Link = ev
+	case EvGoSched, EvGoPreempt:
+		if err := checkRunning(p, g, ev, false); err != nil {
+			return err
+		}
+		g.state = gRunnable
+		g.evStart.Link = ev
+		g.evStart = nil
+		p.g = 0
+		g.ev = ev
+	case EvGoUnblock:
+		if g.state != gRunning {
+			return fmt.Errorf("g %v is not running while unpark (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
+		}
+		if ev.P != TimerP && p.g != ev.G {
+			return fmt.Errorf("p %v is not running g %v while unpark (offset %v, time %v)", ev.P, ev.G, ev.Off, ev.Ts)
+		}
+		g1 := pp.gs[ev.Args[0]]
+		if g1.state != gWaiting {
+			return fmt.Errorf("g %v is not waiting before unpark (offset %v, time %v)", ev.Args[0], ev.Off, ev.Ts)
+		}
+		if g1.ev != nil && g1.ev.Type == EvGoBlockNet && ev.P != TimerP {
+			ev.P = NetpollP
+		}
+		if g1.ev != nil {
+			g1.ev.Link = ev
+		}
+		g1.state = gRunnable
+		g1.ev = ev
+		pp.gs[ev.Args[0]] = g1
+	case EvGoSysCall:
+		if err := checkRunning(p, g, ev, false); err != nil {
+			return err
+		}
+		g.ev = ev
+	case EvGoSysBlock:
+		if err := checkRunning(p, g, ev, false); err != nil {
+			return err
+		}
+		g.state = gWaiting
+		g.evStart.Link = ev
+		g.evStart = nil
+		p.g = 0
+	case EvGoSysExit:
+		if g.state != gWaiting {
+			return fmt.Errorf("g %v is not waiting during syscall exit (offset %v, time %v)", ev.G, ev.Off, ev.Ts)
+		}
+		if g.ev != nil && g.ev.Type == EvGoSysCall {
+			g.ev.Link = ev
+		}
+		g.state = gRunnable
+		g.ev = ev
+	case EvGoSleep, EvGoBlock, EvGoBlockSend, EvGoBlockRecv,
+		EvGoBlockSelect, EvGoBlockSync, EvGoBlockCond, EvGoBlockNet, EvGoBlockGC:
+		if err := checkRunning(p, g, ev, false); err != nil {
+			return err
+		}
+		g.state = gWaiting
+		g.ev = ev
+		g.evStart.Link = ev
+		g.evStart = nil
+		p.g = 0
+	case EvUserTaskCreate:
+		taskid := ev.Args[0]
+		if prevEv, ok := pp.tasks[taskid]; ok {
+			return fmt.Errorf("task id conflicts (id:%d), %q vs %q", taskid, ev, prevEv)
+		}
+		pp.tasks[ev.Args[0]] = ev
+	case EvUserTaskEnd:
+		taskid := ev.Args[0]
+		if taskCreateEv, ok := pp.tasks[taskid]; ok {
+			taskCreateEv.Link = ev
+			delete(pp.tasks, taskid)
+		}
+	case EvUserRegion:
+		mode := ev.Args[1]
+		regions := pp.activeRegions[ev.G]
+		if mode == 0 { // region start
+			pp.activeRegions[ev.G] = append(regions, ev) // push
+		} else if mode == 1 { // region end
+			n := len(regions)
+			if n > 0 { // matching region start event is in the trace.
+				s := regions[n-1]
+				if s.Args[0] != ev.Args[0] || s.SArgs[0] != ev.SArgs[0] { // task id, region name mismatch
+					return fmt.Errorf("misuse of region in goroutine %d: span end %q when the inner-most active span start event is %q", ev.G, ev, s)
+				}
+				// Link region start event with span end event
+				s.Link = ev
 
-					if n > 1 {
-						activeRegions[ev.G] = regions[:n-1]
-					} else {
-						delete(activeRegions, ev.G)
-					}
+				if n > 1 {
+					pp.activeRegions[ev.G] = regions[:n-1]
+				} else {
+					delete(pp.activeRegions, ev.G)
 				}
-			} else {
-				return fmt.Errorf("invalid user region mode: %q", ev)
 			}
+		} else {
+			return fmt.Errorf("invalid user region mode: %q", ev)
 		}
-
-		gs[ev.G] = g
-		ps[ev.P] = p
 	}
 
-	// TODO(dvyukov): restore stacks for EvGoStart events.
-	// TODO(dvyukov): test that all EvGoStart events has non-nil Link.
-
+	pp.gs[ev.G] = g
+	pp.ps[ev.P] = p
 	return nil
 }
 
@@ -1058,7 +1089,8 @@ const (
 	EvUserTaskEnd       = 46 // end of task [timestamp, internal task id, stack]
 	EvUserRegion        = 47 // trace.WithRegion [timestamp, internal task id, mode(0:start, 1:end), stack, name string]
 	EvUserLog           = 48 // trace.Log [timestamp, internal id, key string id, stack, value string]
-	EvCount             = 49
+	EvGeneration        = 49 // end of a generation [generation, start ticks, end ticks]
+	EvCount             = 50
 )
 
 var EventDescriptions = [EvCount]struct {
@@ -1117,4 +1149,5 @@ var EventDescriptions = [EvCount]struct {
 	EvUserTaskEnd:       {"UserTaskEnd", 1011, true, []string{"taskid"}, nil},
 	EvUserRegion:        {"UserRegion", 1011, true, []string{"taskid", "mode", "typeid"}, []string{"name"}},
 	EvUserLog:           {"UserLog", 1011, true, []string{"id", "keyid"}, []string{"category", "message"}},
+	EvGeneration:        {"Generation", 1019, false, []string{"gen", "start", "end"}, nil},
 }
@@ -0,0 +1,204 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	rtrace "runtime/trace"
+	"sort"
+)
+
+// Starting with Go 1.19, traces are partitioned into generations
+// and can be decoded incrementally by runtime/trace's Reader.
+// The functions below adapt its events to the ones of this package.
+
+// IsStreamable reports whether the trace read by r is partitioned into
+// generations, so that a Reader decodes it incrementally.
+// It does not consume any input.
+func IsStreamable(r *bufio.Reader) bool {
+	return streamVersion(r) != 0
+}
+
+// streamVersion returns the version of the trace read by r if it is
+// partitioned into generations, or 0 otherwise.
+// It does not consume any input.
+func streamVersion(r *bufio.Reader) int {
+	hdr, err := r.Peek(16)
+	if err != nil {
+		return 0
+	}
+	ver, err := parseHeader(hdr)
+	if err != nil || ver < 1019 {
+		return 0
+	}
+	return ver
+}
+
+// parseGenerations is like parse for traces of version ver,
+// which are partitioned into generations.
+func parseGenerations(ver int, r io.Reader) (ParseResult, error) {
+	tr, err := rtrace.NewReader(r)
+	if err != nil {
+		return ParseResult{}, err
+	}
+	var events []*Event
+	for {
+		ev, err := tr.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return ParseResult{}, convertError(err)
+		}
+		events = append(events, convertEvent(ev))
+	}
+	if len(events) == 0 {
+		return ParseResult{}, fmt.Errorf("trace is empty")
+	}
+	if BreakTimestampsForTesting {
+		for i := 0; i < 5; i++ {
+			events[rand.Intn(len(events))].Ts += int64(rand.Intn(2000) - 1000)
+		}
+		if !sort.IsSorted(eventList(events)) {
+			return ParseResult{}, ErrTimeOrder
+		}
+	}
+	events = removeFutile(events)
+	if err := postProcessTrace(ver, events); err != nil {
+		return ParseResult{}, err
+	}
+	// Attach stack traces.
+	stacks := make(map[uint64][]*Frame)
+	for _, ev := range events {
+		if ev.StkID != 0 {
+			ev.Stk = stackFrames(tr, stacks, ev.StkID)
+		}
+		if ev.Type == EvGoCreate {
+			stackFrames(tr, stacks, ev.Args[1])
+		}
+	}
+	return ParseResult{Events: events, Stacks: stacks}, nil
+}
+
+// convertEvent converts an event read by runtime/trace's Reader.
+func convertEvent(ev rtrace.Event) *Event {
+	e := &Event{
+		Type:  byte(ev.Type),
+		Ts:    ev.Time,
+		P:     ev.P,
+		G:     ev.G,
+		StkID: ev.StackID,
+		Args:  ev.Args,
+		SArgs: ev.Strings,
+	}
+	switch e.Type {
+	case EvGCSTWStart:
+		if e.Args[0] == 0 {
+			e.SArgs = []string{"mark termination"}
+		} else {
+			e.SArgs = []string{"sweep termination"}
+		}
+	case EvGoSysExit:
+		// Move syscalls to a separate fake P.
+		e.P = SyscallP
+	}
+	return e
+}
+
+// convertError converts an error returned by runtime/trace's Reader.
+func convertError(err error) error {
+	if err == rtrace.ErrTimeOrder {
+		return ErrTimeOrder
+	}
+	return err
+}
+
+// stackFrames returns the frames of the stack with the given id,
+// caching them in stacks.
+func stackFrames(tr *rtrace.Reader, stacks map[uint64][]*Frame, id uint64) []*Frame {
+	if stk, ok := stacks[id]; ok {
+		return stk
+	}
+	var stk []*Frame
+	for _, f := range tr.Stack(id) {
+		stk = append(stk, &Frame{PC: f.PC, Fn: f.Func, File: f.File, Line: f.Line})
+	}
+	if stk != nil {
+		stacks[id] = stk
+	}
+	return stk
+}
+
+// A Reader reads the events of a trace one at a time.
+//
+// The events are post-processed and verified like the ones returned by Parse,
+// except that futile wakeups are not removed. Traces produced by Go 1.19 and
+// later are decoded one generation at a time, so that their size is not
+// limited by the available memory. Older traces are parsed at once.
+type Reader struct {
+	tr     *rtrace.Reader
+	pp     *postProcessor
+	stacks map[uint64][]*Frame
+
+	events []*Event // the events of an older trace
+}
+
+// NewReader returns a Reader reading the trace from r.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	ver := streamVersion(br)
+	if ver == 0 {
+		res, err := Parse(br, "")
+		if err != nil {
+			return nil, err
+		}
+		return &Reader{stacks: res.Stacks, events: res.Events}, nil
+	}
+	tr, err := rtrace.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{
+		tr:     tr,
+		pp:     newPostProcessor(ver),
+		stacks: make(map[uint64][]*Frame),
+	}, nil
+}
+
+// ReadEvent returns the next event in the trace, or io.EOF at the end of it.
+// The Link of the event is set once the linked event is read.
+func (r *Reader) ReadEvent() (*Event, error) {
+	if r.tr == nil {
+		if len(r.events) == 0 {
+			return nil, io.EOF
+		}
+		ev := r.events[0]
+		r.events = r.events[1:]
+		return ev, nil
+	}
+	rev, err := r.tr.ReadEvent()
+	if err != nil {
+		return nil, convertError(err)
+	}
+	ev := convertEvent(rev)
+	if err := r.pp.process(ev); err != nil {
+		return nil, err
+	}
+	if ev.StkID != 0 {
+		ev.Stk = r.Stack(ev.StkID)
+	}
+	return ev, nil
+}
+
+// Stack returns the stack trace with the given id, if it has been read.
+func (r *Reader) Stack(id uint64) []*Frame {
+	if r.tr == nil {
+		return r.stacks[id]
+	}
+	return stackFrames(r.tr, r.stacks, id)
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slog_test
+
+import (
+	"log"
+	"log/slog"
+	"os"
+)
+
+func ExampleGroup() {
+	// Remove the time attribute to make the output deterministic.
+	removeTime := func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey && len(groups) == 0 {
+			return slog.Attr{}
+		}
+		return a
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{ReplaceAttr: removeTime}))
+	logger.Info("finished",
+		slog.Group("req",
+			slog.String("method", "GET"),
+			slog.String("url", "/index.html")),
+		slog.Int("status", 200))
+
+	// Output:
+	// level=INFO msg=finished req.method=GET req.url=/index.html status=200
+}
+
+func ExampleLogger_With() {
+	removeTime := func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey && len(groups) == 0 {
+			return slog.Attr{}
+		}
+		return a
+	}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{ReplaceAttr: removeTime}))
+	reqLogger := logger.With("id", 17).WithGroup("req")
+	reqLogger.Warn("slow request", "ms", 1500)
+
+	// Output:
+	// {"level":"WARN","msg":"slow request","id":17,"req":{"ms":1500}}
+}
+
+func ExampleNewLogLogger() {
+	removeTime := func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey && len(groups) == 0 {
+			return slog.Attr{}
+		}
+		return a
+	}
+	h := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{ReplaceAttr: removeTime})
+
+	// Code that still requires a *log.Logger can send its output
+	// to a slog.Handler.
+	var l *log.Logger = slog.NewLogLogger(h, slog.LevelWarn)
+	l.Printf("disk %d%% full", 91)
+
+	// Output:
+	// level=WARN msg="disk 91% full"
+}
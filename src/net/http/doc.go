@@ -103,5 +103,15 @@ functions. Manually configuring HTTP/2 via the golang.org/x/net/http2
 package takes precedence over the net/http package's built-in HTTP/2
 support.
 
+HTTP/3 is supported over QUIC, but is not enabled by default. Servers
+serve HTTP/3 on a UDP address with Server.ServeHTTP3 or
+Server.ListenAndServeHTTP3, and advertise it in an Alt-Svc header field
+on responses sent over TLS. Clients with Transport.EnableHTTP3 set
+switch to HTTP/3 for an origin after receiving such an advertisement:
+
+	srv := &http.Server{Addr: ":8443", Handler: handler}
+	go srv.ListenAndServeHTTP3("cert.pem", "key.pem")
+	log.Fatal(srv.ListenAndServeTLS("cert.pem", "key.pem"))
+
 */
 package http
@@ -0,0 +1,886 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTTP/3 framing and header compression, shared by the HTTP/3 client
+// and server. See RFC 9114 and RFC 9204.
+
+package http
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"internal/quic"
+	"io"
+	"net/http/internal/ascii"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http/httpguts"
+	"golang.org/x/net/http2/hpack"
+)
+
+// http3NextProto is the ALPN protocol identifier for HTTP/3.
+const http3NextProto = "h3"
+
+// HTTP/3 frame types. See RFC 9114, Section 7.2.
+const (
+	http3FrameData        = 0x00
+	http3FrameHeaders     = 0x01
+	http3FrameCancelPush  = 0x03
+	http3FrameSettings    = 0x04
+	http3FramePushPromise = 0x05
+	http3FrameGoaway      = 0x07
+	http3FrameMaxPushID   = 0x0d
+)
+
+// HTTP/3 unidirectional stream types.
+// See RFC 9114, Section 6.2, and RFC 9204, Section 4.2.
+const (
+	http3StreamControl      = 0x00
+	http3StreamPush         = 0x01
+	http3StreamQPACKEncoder = 0x02
+	http3StreamQPACKDecoder = 0x03
+)
+
+// http3SettingMaxFieldSectionSize is the SETTINGS_MAX_FIELD_SECTION_SIZE
+// setting. See RFC 9114, Section 7.2.4.1.
+const http3SettingMaxFieldSectionSize = 0x06
+
+// An http3ErrCode is an HTTP/3 error code, sent in RESET_STREAM,
+// STOP_SENDING and CONNECTION_CLOSE frames. See RFC 9114, Section 8.1.
+type http3ErrCode uint64
+
+const (
+	http3ErrNoError              = http3ErrCode(0x100)
+	http3ErrGeneralProtocolError = http3ErrCode(0x101)
+	http3ErrInternalError        = http3ErrCode(0x102)
+	http3ErrStreamCreationError  = http3ErrCode(0x103)
+	http3ErrClosedCriticalStream = http3ErrCode(0x104)
+	http3ErrFrameUnexpected      = http3ErrCode(0x105)
+	http3ErrFrameError           = http3ErrCode(0x106)
+	http3ErrExcessiveLoad        = http3ErrCode(0x107)
+	http3ErrIDError              = http3ErrCode(0x108)
+	http3ErrSettingsError        = http3ErrCode(0x109)
+	http3ErrMissingSettings      = http3ErrCode(0x10a)
+	http3ErrRequestRejected      = http3ErrCode(0x10b)
+	http3ErrRequestCancelled     = http3ErrCode(0x10c)
+	http3ErrRequestIncomplete    = http3ErrCode(0x10d)
+	http3ErrMessageError         = http3ErrCode(0x10e)
+	http3ErrQPACKDecompression   = http3ErrCode(0x200) // QPACK_DECOMPRESSION_FAILED, RFC 9204
+)
+
+// An http3ConnError is an error that terminates an HTTP/3 connection.
+type http3ConnError struct {
+	code   http3ErrCode
+	reason string
+}
+
+func (e *http3ConnError) Error() string {
+	return fmt.Sprintf("http3: connection error %#x: %v", uint64(e.code), e.reason)
+}
+
+// abort closes conn with the error e.
+func (e *http3ConnError) abort(conn *quic.Conn) {
+	conn.Abort(&quic.ApplicationError{Code: uint64(e.code), Reason: e.reason})
+}
+
+// http3CloseConn closes conn without error.
+func http3CloseConn(conn *quic.Conn) {
+	conn.Abort(&quic.ApplicationError{Code: uint64(http3ErrNoError)})
+}
+
+// An http3StreamError is an error that terminates a single request stream.
+type http3StreamError struct {
+	code   http3ErrCode
+	reason string
+}
+
+func (e *http3StreamError) Error() string {
+	return fmt.Sprintf("http3: stream error %#x: %v", uint64(e.code), e.reason)
+}
+
+var errHTTP3FrameTooLarge = &http3StreamError{http3ErrExcessiveLoad, "frame too large"}
+
+// An http3Stream reads and writes HTTP/3 frames on a QUIC stream.
+type http3Stream struct {
+	st *quic.Stream
+	br *bufio.Reader
+	bw *bufio.Writer
+}
+
+func newHTTP3Stream(st *quic.Stream) *http3Stream {
+	return &http3Stream{
+		st: st,
+		br: bufio.NewReader(st),
+		bw: bufio.NewWriter(st),
+	}
+}
+
+// abort resets both directions of the stream with the error code code.
+func (s *http3Stream) abort(code http3ErrCode) {
+	s.st.Reset(uint64(code))
+	s.st.CloseRead(uint64(code))
+}
+
+// readVarint reads a QUIC variable-length integer.
+// See RFC 9000, Section 16.
+func (s *http3Stream) readVarint() (uint64, error) {
+	b, err := s.br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	n := 1 << (b >> 6)
+	v := uint64(b & 0x3f)
+	for i := 1; i < n; i++ {
+		b, err := s.br.ReadByte()
+		if err != nil {
+			return 0, unexpectedEOF(err)
+		}
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+// readFrameHeader reads the type and length of the next frame.
+// It returns io.EOF if the stream ends before the frame starts.
+func (s *http3Stream) readFrameHeader() (typ, size uint64, err error) {
+	typ, err = s.readVarint()
+	if err != nil {
+		return 0, 0, err
+	}
+	size, err = s.readVarint()
+	if err != nil {
+		return 0, 0, unexpectedEOF(err)
+	}
+	return typ, size, nil
+}
+
+// readFramePayload reads a frame payload of size bytes,
+// which must not be larger than max.
+func (s *http3Stream) readFramePayload(size uint64, max int64) ([]byte, error) {
+	if size > uint64(max) {
+		return nil, errHTTP3FrameTooLarge
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(s.br, b); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return b, nil
+}
+
+// discard skips n bytes of frame payload.
+func (s *http3Stream) discard(n uint64) error {
+	for n > 0 {
+		m := n
+		if m > 1<<30 {
+			m = 1 << 30
+		}
+		if _, err := s.br.Discard(int(m)); err != nil {
+			return unexpectedEOF(err)
+		}
+		n -= m
+	}
+	return nil
+}
+
+// readHeadersFrame reads frames until it finds a HEADERS frame,
+// skipping unknown frame types, and returns its payload.
+// It returns io.EOF if the stream ends first.
+func (s *http3Stream) readHeadersFrame(maxSize int64) ([]byte, error) {
+	for {
+		typ, size, err := s.readFrameHeader()
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case http3FrameHeaders:
+			return s.readFramePayload(size, maxSize)
+		case http3FrameData, http3FrameCancelPush, http3FrameSettings,
+			http3FramePushPromise, http3FrameGoaway, http3FrameMaxPushID:
+			return nil, &http3ConnError{http3ErrFrameUnexpected, fmt.Sprintf("unexpected frame type %#x", typ)}
+		}
+		if err := s.discard(size); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// writeFrame buffers a frame with the given type and payload.
+func (s *http3Stream) writeFrame(typ uint64, payload []byte) error {
+	var hdr [16]byte
+	b := http3AppendVarint(hdr[:0], typ)
+	b = http3AppendVarint(b, uint64(len(payload)))
+	if _, err := s.bw.Write(b); err != nil {
+		return err
+	}
+	_, err := s.bw.Write(payload)
+	return err
+}
+
+// writeSettings writes the stream type and SETTINGS frame which
+// start a control stream.
+func (s *http3Stream) writeSettings(maxFieldSectionSize int64) error {
+	if err := s.bw.WriteByte(http3StreamControl); err != nil {
+		return err
+	}
+	var settings []byte
+	settings = http3AppendVarint(settings, http3SettingMaxFieldSectionSize)
+	settings = http3AppendVarint(settings, uint64(maxFieldSectionSize))
+	if err := s.writeFrame(http3FrameSettings, settings); err != nil {
+		return err
+	}
+	return s.bw.Flush()
+}
+
+// readSettings reads the SETTINGS frame which starts the peer's control
+// stream and returns the peer's SETTINGS_MAX_FIELD_SECTION_SIZE,
+// or -1 if unlimited.
+func (s *http3Stream) readSettings() (maxFieldSectionSize int64, err error) {
+	typ, size, err := s.readFrameHeader()
+	if err != nil {
+		return 0, &http3ConnError{http3ErrClosedCriticalStream, "control stream closed"}
+	}
+	if typ != http3FrameSettings {
+		return 0, &http3ConnError{http3ErrMissingSettings, "control stream does not start with SETTINGS"}
+	}
+	b, err := s.readFramePayload(size, 1<<16)
+	if err != nil {
+		return 0, &http3ConnError{http3ErrExcessiveLoad, "SETTINGS frame too large"}
+	}
+	maxFieldSectionSize = -1
+	for len(b) > 0 {
+		id, n := http3ConsumeVarint(b)
+		if n < 0 {
+			return 0, &http3ConnError{http3ErrFrameError, "malformed SETTINGS frame"}
+		}
+		b = b[n:]
+		v, n := http3ConsumeVarint(b)
+		if n < 0 {
+			return 0, &http3ConnError{http3ErrFrameError, "malformed SETTINGS frame"}
+		}
+		b = b[n:]
+		switch id {
+		case http3SettingMaxFieldSectionSize:
+			if v < 1<<62 {
+				maxFieldSectionSize = int64(v)
+			}
+		case 0x02, 0x03, 0x04, 0x05:
+			// Reserved HTTP/2 settings. See RFC 9114, Section 7.2.4.1.
+			return 0, &http3ConnError{http3ErrSettingsError, fmt.Sprintf("reserved setting %#x", id)}
+		}
+	}
+	return maxFieldSectionSize, nil
+}
+
+// readControlFrames reads frames from the peer's control stream after
+// its SETTINGS frame. It calls goaway for each GOAWAY frame and returns
+// a connection error when the stream ends.
+func (s *http3Stream) readControlFrames(goaway func(id uint64)) error {
+	for {
+		typ, size, err := s.readFrameHeader()
+		if err != nil {
+			return &http3ConnError{http3ErrClosedCriticalStream, "control stream closed"}
+		}
+		switch typ {
+		case http3FrameGoaway:
+			b, err := s.readFramePayload(size, 8)
+			if err != nil {
+				return &http3ConnError{http3ErrFrameError, "malformed GOAWAY frame"}
+			}
+			id, n := http3ConsumeVarint(b)
+			if n != len(b) {
+				return &http3ConnError{http3ErrFrameError, "malformed GOAWAY frame"}
+			}
+			goaway(id)
+			continue
+		case http3FrameData, http3FrameHeaders, http3FrameSettings, http3FramePushPromise:
+			return &http3ConnError{http3ErrFrameUnexpected, fmt.Sprintf("unexpected frame type %#x on control stream", typ)}
+		}
+		if err := s.discard(size); err != nil {
+			return &http3ConnError{http3ErrClosedCriticalStream, "control stream closed"}
+		}
+	}
+}
+
+// handleUniStream handles a unidirectional stream opened by the peer.
+// Control streams are passed to control, QPACK streams are drained
+// (this implementation never uses the dynamic table), and other
+// streams are refused.
+func handleHTTP3UniStream(st *quic.Stream, control func(*http3Stream)) {
+	s := newHTTP3Stream(st)
+	typ, err := s.readVarint()
+	if err != nil {
+		st.CloseRead(uint64(http3ErrStreamCreationError))
+		return
+	}
+	switch typ {
+	case http3StreamControl:
+		control(s)
+	case http3StreamQPACKEncoder, http3StreamQPACKDecoder:
+		io.Copy(io.Discard, s.br)
+	default:
+		// Push streams are never allowed, since no MAX_PUSH_ID
+		// frame is sent. Unknown stream types must be ignored.
+		st.CloseRead(uint64(http3ErrStreamCreationError))
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func http3AppendVarint(b []byte, v uint64) []byte {
+	switch {
+	case v < 1<<6:
+		return append(b, byte(v))
+	case v < 1<<14:
+		return append(b, 0x40|byte(v>>8), byte(v))
+	case v < 1<<30:
+		return append(b, 0x80|byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+	return append(b, 0xc0|byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32),
+		byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// http3ConsumeVarint parses a variable-length integer at the start
+// of b, returning its value and length. It returns n < 0 on error.
+func http3ConsumeVarint(b []byte) (v uint64, n int) {
+	if len(b) == 0 {
+		return 0, -1
+	}
+	n = 1 << (b[0] >> 6)
+	if len(b) < n {
+		return 0, -1
+	}
+	v = uint64(b[0] & 0x3f)
+	for i := 1; i < n; i++ {
+		v = v<<8 | uint64(b[i])
+	}
+	return v, n
+}
+
+// QPACK field section encoding, using only the static table.
+// See RFC 9204, Section 4.5.
+
+// http3StaticTable is the QPACK static table. See RFC 9204, Appendix A.
+var http3StaticTable = [...]struct{ name, value string }{
+	{":authority", ""},
+	{":path", "/"},
+	{"age", "0"},
+	{"content-disposition", ""},
+	{"content-length", "0"},
+	{"cookie", ""},
+	{"date", ""},
+	{"etag", ""},
+	{"if-modified-since", ""},
+	{"if-none-match", ""},
+	{"last-modified", ""},
+	{"link", ""},
+	{"location", ""},
+	{"referer", ""},
+	{"set-cookie", ""},
+	{":method", "CONNECT"},
+	{":method", "DELETE"},
+	{":method", "GET"},
+	{":method", "HEAD"},
+	{":method", "OPTIONS"},
+	{":method", "POST"},
+	{":method", "PUT"},
+	{":scheme", "http"},
+	{":scheme", "https"},
+	{":status", "103"},
+	{":status", "200"},
+	{":status", "304"},
+	{":status", "404"},
+	{":status", "503"},
+	{"accept", "*/*"},
+	{"accept", "application/dns-message"},
+	{"accept-encoding", "gzip, deflate, br"},
+	{"accept-ranges", "bytes"},
+	{"access-control-allow-headers", "cache-control"},
+	{"access-control-allow-headers", "content-type"},
+	{"access-control-allow-origin", "*"},
+	{"cache-control", "max-age=0"},
+	{"cache-control", "max-age=2592000"},
+	{"cache-control", "max-age=604800"},
+	{"cache-control", "no-cache"},
+	{"cache-control", "no-store"},
+	{"cache-control", "public, max-age=31536000"},
+	{"content-encoding", "br"},
+	{"content-encoding", "gzip"},
+	{"content-type", "application/dns-message"},
+	{"content-type", "application/javascript"},
+	{"content-type", "application/json"},
+	{"content-type", "application/x-www-form-urlencoded"},
+	{"content-type", "image/gif"},
+	{"content-type", "image/jpeg"},
+	{"content-type", "image/png"},
+	{"content-type", "text/css"},
+	{"content-type", "text/html; charset=utf-8"},
+	{"content-type", "text/plain"},
+	{"content-type", "text/plain;charset=utf-8"},
+	{"range", "bytes=0-"},
+	{"strict-transport-security", "max-age=31536000"},
+	{"strict-transport-security", "max-age=31536000; includesubdomains"},
+	{"strict-transport-security", "max-age=31536000; includesubdomains; preload"},
+	{"vary", "accept-encoding"},
+	{"vary", "origin"},
+	{"x-content-type-options", "nosniff"},
+	{"x-xss-protection", "1; mode=block"},
+	{":status", "100"},
+	{":status", "204"},
+	{":status", "206"},
+	{":status", "302"},
+	{":status", "400"},
+	{":status", "403"},
+	{":status", "421"},
+	{":status", "425"},
+	{":status", "500"},
+	{"accept-language", ""},
+	{"access-control-allow-credentials", "FALSE"},
+	{"access-control-allow-credentials", "TRUE"},
+	{"access-control-allow-headers", "*"},
+	{"access-control-allow-methods", "get"},
+	{"access-control-allow-methods", "get, post, options"},
+	{"access-control-allow-methods", "options"},
+	{"access-control-expose-headers", "content-length"},
+	{"access-control-request-headers", "content-type"},
+	{"access-control-request-method", "get"},
+	{"access-control-request-method", "post"},
+	{"alt-svc", "clear"},
+	{"authorization", ""},
+	{"content-security-policy", "script-src 'none'; object-src 'none'; base-uri 'none'"},
+	{"early-data", "1"},
+	{"expect-ct", ""},
+	{"forwarded", ""},
+	{"if-range", ""},
+	{"origin", ""},
+	{"purpose", "prefetch"},
+	{"server", ""},
+	{"timing-allow-origin", "*"},
+	{"upgrade-insecure-requests", "1"},
+	{"user-agent", ""},
+	{"x-forwarded-for", ""},
+	{"x-frame-options", "deny"},
+	{"x-frame-options", "sameorigin"},
+}
+
+var (
+	http3StaticIndexOnce sync.Once
+	http3StaticByName    map[string]int    // first index of each name
+	http3StaticByField   map[[2]string]int // index of each name and value
+)
+
+func http3StaticIndex() {
+	http3StaticByName = make(map[string]int)
+	http3StaticByField = make(map[[2]string]int)
+	for i, f := range http3StaticTable {
+		if _, ok := http3StaticByName[f.name]; !ok {
+			http3StaticByName[f.name] = i
+		}
+		http3StaticByField[[2]string{f.name, f.value}] = i
+	}
+}
+
+// An http3FieldEncoder encodes a QPACK field section.
+type http3FieldEncoder struct {
+	b []byte
+}
+
+func newHTTP3FieldEncoder() *http3FieldEncoder {
+	http3StaticIndexOnce.Do(http3StaticIndex)
+	// Required Insert Count and Delta Base are both zero,
+	// since the dynamic table is never used.
+	return &http3FieldEncoder{b: []byte{0, 0}}
+}
+
+// writeField encodes a field line. name must be lowercase.
+func (e *http3FieldEncoder) writeField(name, value string) {
+	if i, ok := http3StaticByField[[2]string{name, value}]; ok {
+		// Indexed field line, static table.
+		e.b = http3AppendPrefixedInt(e.b, 0xc0, 6, uint64(i))
+		return
+	}
+	if i, ok := http3StaticByName[name]; ok {
+		// Literal field line with static name reference.
+		e.b = http3AppendPrefixedInt(e.b, 0x50, 4, uint64(i))
+	} else {
+		// Literal field line with literal name.
+		e.b = http3AppendPrefixedString(e.b, 0x20, 3, name)
+	}
+	e.b = http3AppendPrefixedString(e.b, 0x00, 7, value)
+}
+
+// http3AppendPrefixedInt appends an integer with an N-bit prefix,
+// where the high bits of the first byte are first.
+// See RFC 7541, Section 5.1.
+func http3AppendPrefixedInt(b []byte, first byte, prefix uint, v uint64) []byte {
+	max := uint64(1)<<prefix - 1
+	if v < max {
+		return append(b, first|byte(v))
+	}
+	b = append(b, first|byte(max))
+	v -= max
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// http3AppendPrefixedString appends a string literal whose length has
+// an N-bit prefix. The bit above the prefix is the Huffman flag.
+// See RFC 9204, Section 4.1.2.
+func http3AppendPrefixedString(b []byte, first byte, prefix uint, s string) []byte {
+	if n := hpack.HuffmanEncodeLength(s); n < uint64(len(s)) {
+		b = http3AppendPrefixedInt(b, first|1<<prefix, prefix, n)
+		return hpack.AppendHuffmanString(b, s)
+	}
+	b = http3AppendPrefixedInt(b, first, prefix, uint64(len(s)))
+	return append(b, s...)
+}
+
+var errHTTP3QPACK = &http3ConnError{http3ErrQPACKDecompression, "malformed field section"}
+
+// http3ConsumePrefixedInt parses an integer with an N-bit prefix.
+// It returns n < 0 on error.
+func http3ConsumePrefixedInt(b []byte, prefix uint) (v uint64, n int) {
+	if len(b) == 0 {
+		return 0, -1
+	}
+	max := uint64(1)<<prefix - 1
+	v = uint64(b[0]) & max
+	if v < max {
+		return v, 1
+	}
+	for i, shift := 1, uint(0); i < len(b) && shift < 63; i, shift = i+1, shift+7 {
+		v += uint64(b[i]&0x7f) << shift
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, -1
+}
+
+// http3ConsumePrefixedString parses a string literal with an N-bit
+// length prefix. It returns n < 0 on error.
+func http3ConsumePrefixedString(b []byte, prefix uint) (s string, n int) {
+	if len(b) == 0 {
+		return "", -1
+	}
+	huffman := b[0]&(1<<prefix) != 0
+	size, n := http3ConsumePrefixedInt(b, prefix)
+	if n < 0 || uint64(len(b)-n) < size {
+		return "", -1
+	}
+	v := b[n : n+int(size)]
+	n += int(size)
+	if !huffman {
+		return string(v), n
+	}
+	s, err := hpack.HuffmanDecodeToString(v)
+	if err != nil {
+		return "", -1
+	}
+	return s, n
+}
+
+// http3DecodeFieldSection decodes a QPACK field section,
+// calling f for each field line.
+func http3DecodeFieldSection(b []byte, f func(name, value string) error) error {
+	http3StaticIndexOnce.Do(http3StaticIndex)
+	ric, n := http3ConsumePrefixedInt(b, 8)
+	if n < 0 || ric != 0 {
+		// The dynamic table has zero capacity, so the peer
+		// cannot refer to any entries in it.
+		return errHTTP3QPACK
+	}
+	b = b[n:]
+	if _, n = http3ConsumePrefixedInt(b, 7); n < 0 {
+		return errHTTP3QPACK
+	}
+	b = b[n:]
+	for len(b) > 0 {
+		var name, value string
+		switch {
+		case b[0]&0x80 != 0:
+			// Indexed field line.
+			if b[0]&0x40 == 0 {
+				return errHTTP3QPACK
+			}
+			i, n := http3ConsumePrefixedInt(b, 6)
+			if n < 0 || i >= uint64(len(http3StaticTable)) {
+				return errHTTP3QPACK
+			}
+			b = b[n:]
+			name, value = http3StaticTable[i].name, http3StaticTable[i].value
+		case b[0]&0xc0 == 0x40:
+			// Literal field line with name reference.
+			if b[0]&0x10 == 0 {
+				return errHTTP3QPACK
+			}
+			i, n := http3ConsumePrefixedInt(b, 4)
+			if n < 0 || i >= uint64(len(http3StaticTable)) {
+				return errHTTP3QPACK
+			}
+			b = b[n:]
+			name = http3StaticTable[i].name
+			if value, n = http3ConsumePrefixedString(b, 7); n < 0 {
+				return errHTTP3QPACK
+			}
+			b = b[n:]
+		case b[0]&0xe0 == 0x20:
+			// Literal field line with literal name.
+			if name, n = http3ConsumePrefixedString(b, 3); n < 0 {
+				return errHTTP3QPACK
+			}
+			b = b[n:]
+			if value, n = http3ConsumePrefixedString(b, 7); n < 0 {
+				return errHTTP3QPACK
+			}
+			b = b[n:]
+		default:
+			// Post-base forms refer to the dynamic table.
+			return errHTTP3QPACK
+		}
+		if err := f(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// http3ConnHeaders are connection-specific header fields,
+// which are not permitted in HTTP/3. See RFC 9114, Section 4.2.
+var http3ConnHeaders = map[string]bool{
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+// writeHeader encodes the fields in h, skipping connection-specific
+// fields and fields in skip.
+func (e *http3FieldEncoder) writeHeader(h Header, skip map[string]bool) {
+	for k, vv := range h {
+		if !httpguts.ValidHeaderFieldName(k) {
+			continue
+		}
+		name, ok := ascii.ToLower(k)
+		if !ok || http3ConnHeaders[name] || skip[name] {
+			continue
+		}
+		for _, v := range vv {
+			if !httpguts.ValidHeaderFieldValue(v) {
+				continue
+			}
+			if name == "te" && v != "trailers" {
+				continue
+			}
+			e.writeField(name, v)
+		}
+	}
+}
+
+var errHTTP3Malformed = &http3StreamError{http3ErrMessageError, "malformed message"}
+
+// http3DecodeHeader decodes a field section into its pseudo-header
+// fields and its regular fields.
+func http3DecodeHeader(b []byte) (pseudo map[string]string, h Header, err error) {
+	pseudo = make(map[string]string)
+	h = make(Header)
+	var cookies []string
+	err = http3DecodeFieldSection(b, func(name, value string) error {
+		if strings.HasPrefix(name, ":") {
+			// Pseudo-header fields must precede regular fields
+			// and may not be repeated. See RFC 9114, Section 4.3.
+			if _, dup := pseudo[name]; dup || len(h) > 0 || len(cookies) > 0 {
+				return errHTTP3Malformed
+			}
+			pseudo[name] = value
+			return nil
+		}
+		if lower, ok := ascii.ToLower(name); !ok || lower != name || !httpguts.ValidHeaderFieldName(name) ||
+			!httpguts.ValidHeaderFieldValue(value) || http3ConnHeaders[name] {
+			return errHTTP3Malformed
+		}
+		if name == "cookie" {
+			cookies = append(cookies, value)
+			return nil
+		}
+		key := textproto.CanonicalMIMEHeaderKey(name)
+		h[key] = append(h[key], value)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(cookies) > 0 {
+		// Multiple cookie fields are concatenated for HTTP/1.1
+		// compatibility. See RFC 9114, Section 4.2.1.
+		h["Cookie"] = []string{strings.Join(cookies, "; ")}
+	}
+	return pseudo, h, nil
+}
+
+// http3ContentLength parses the Content-Length field of h, returning
+// -1 if it is absent.
+func http3ContentLength(h Header) (int64, error) {
+	vv := h["Content-Length"]
+	if len(vv) == 0 {
+		return -1, nil
+	}
+	for _, v := range vv[1:] {
+		if v != vv[0] {
+			return 0, errHTTP3Malformed
+		}
+	}
+	n, err := strconv.ParseInt(textproto.TrimString(vv[0]), 10, 64)
+	if err != nil || n < 0 {
+		return 0, errHTTP3Malformed
+	}
+	return n, nil
+}
+
+// http3DeclaredTrailer returns a Header with an empty entry for each
+// trailer field announced in the Trailer field of h, or nil if none.
+func http3DeclaredTrailer(h Header) Header {
+	var trailer Header
+	for _, v := range h["Trailer"] {
+		foreachHeaderElement(v, func(key string) {
+			key = CanonicalHeaderKey(key)
+			switch key {
+			case "Transfer-Encoding", "Trailer", "Content-Length":
+				// Not permitted in trailers. Ignore.
+			default:
+				if trailer == nil {
+					trailer = make(Header)
+				}
+				trailer[key] = nil
+			}
+		})
+	}
+	return trailer
+}
+
+// An http3Body reads the DATA frames of a request or response body,
+// followed by optional trailers.
+type http3Body struct {
+	s             *http3Stream
+	maxHeaderSize int64
+	contentLength int64   // -1 if unknown
+	trailer       *Header // where to store received trailers
+	onClose       func(eof bool)
+
+	closed atomicBool // Close called
+	eof    atomicBool // body read to the end
+
+	mu        sync.Mutex // guards the fields below and reads from s
+	remaining uint64     // unread bytes in the current DATA frame
+	read      int64
+	err       error // sticky read error; io.EOF after the body ends
+}
+
+func (b *http3Body) Read(p []byte) (n int, err error) {
+	if b.closed.isSet() {
+		return 0, ErrBodyReadAfterClose
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return 0, b.err
+	}
+	for b.remaining == 0 {
+		if err := b.nextFrame(); err != nil {
+			if err == io.EOF {
+				b.eof.setTrue()
+			}
+			b.err = err
+			return 0, err
+		}
+	}
+	if uint64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err = b.s.br.Read(p)
+	b.remaining -= uint64(n)
+	b.read += int64(n)
+	if b.contentLength >= 0 && b.read > b.contentLength {
+		b.err = errors.New("http3: body larger than Content-Length")
+		return 0, b.err
+	}
+	if err != nil {
+		b.err = unexpectedEOF(err)
+		return n, nil
+	}
+	return n, nil
+}
+
+// nextFrame reads frames up to the start of the next DATA frame.
+// It returns io.EOF after the body and any trailers end.
+func (b *http3Body) nextFrame() error {
+	typ, size, err := b.s.readFrameHeader()
+	if err == io.EOF {
+		return b.end()
+	}
+	if err != nil {
+		return err
+	}
+	switch typ {
+	case http3FrameData:
+		b.remaining = size
+		return nil
+	case http3FrameHeaders:
+		payload, err := b.s.readFramePayload(size, b.maxHeaderSize)
+		if err != nil {
+			return err
+		}
+		_, h, err := http3DecodeHeader(payload)
+		if err != nil {
+			return err
+		}
+		if b.trailer != nil {
+			if *b.trailer == nil {
+				*b.trailer = make(Header)
+			}
+			for k, vv := range h {
+				(*b.trailer)[k] = vv
+			}
+		}
+		// Nothing may follow the trailers.
+		if _, _, err := b.s.readFrameHeader(); err != io.EOF {
+			return errHTTP3Malformed
+		}
+		return b.end()
+	}
+	return b.s.discard(size)
+}
+
+// end returns the error to report at the end of the stream.
+func (b *http3Body) end() error {
+	if b.contentLength >= 0 && b.read != b.contentLength {
+		return io.ErrUnexpectedEOF
+	}
+	return io.EOF
+}
+
+// Close stops reading the body. It does not wait for a concurrent
+// Read to return; onClose is expected to unblock it.
+func (b *http3Body) Close() error {
+	if b.closed.isSet() {
+		return nil
+	}
+	b.closed.setTrue()
+	if b.onClose != nil {
+		b.onClose(b.eof.isSet())
+	}
+	return nil
+}
@@ -0,0 +1,607 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTTP/3 client implementation. See RFC 9114.
+
+package http
+
+import (
+	"context"
+	"errors"
+	"internal/quic"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// errHTTP3Unavailable is returned by http3RoundTrip when a request
+// cannot be sent over HTTP/3 and should be sent over TCP instead.
+// No part of the request has been sent when it is returned.
+var errHTTP3Unavailable = errors.New("http: HTTP/3 unavailable")
+
+// An http3AltSvc is an HTTP/3 alternative service for an origin,
+// learned from an Alt-Svc response header field. See RFC 7838.
+type http3AltSvc struct {
+	addr    string // host:port of the alternative
+	expires time.Time
+}
+
+// http3ParseAltSvc returns the first HTTP/3 alternative in the Alt-Svc
+// field value v, for an origin whose host is host. It reports clear if
+// v is "clear", meaning that all alternatives are invalidated.
+func http3ParseAltSvc(v, host string, now time.Time) (alt http3AltSvc, ok, clear bool) {
+	v = textproto.TrimString(v)
+	if v == "clear" {
+		return http3AltSvc{}, false, true
+	}
+	for _, entry := range strings.Split(v, ",") {
+		params := strings.Split(entry, ";")
+		proto, authority, found := strings.Cut(textproto.TrimString(params[0]), "=")
+		if !found || proto != http3NextProto {
+			continue
+		}
+		authority, err := strconv.Unquote(authority)
+		if err != nil {
+			continue
+		}
+		altHost, port, err := net.SplitHostPort(authority)
+		if err != nil || port == "" {
+			continue
+		}
+		if altHost == "" {
+			altHost = host
+		}
+		maxAge := 24 * time.Hour
+		for _, p := range params[1:] {
+			k, v, _ := strings.Cut(textproto.TrimString(p), "=")
+			if k != "ma" {
+				continue
+			}
+			if secs, err := strconv.ParseUint(v, 10, 32); err == nil {
+				maxAge = time.Duration(secs) * time.Second
+			}
+		}
+		return http3AltSvc{
+			addr:    net.JoinHostPort(altHost, port),
+			expires: now.Add(maxAge),
+		}, true, false
+	}
+	return http3AltSvc{}, false, false
+}
+
+// http3RememberAltSvc records any HTTP/3 alternative service
+// advertised by an HTTPS response.
+func (t *Transport) http3RememberAltSvc(req *Request, resp *Response) {
+	if resp.TLS == nil || resp.ProtoMajor >= 3 {
+		return
+	}
+	v := resp.Header.Get("Alt-Svc")
+	if v == "" {
+		return
+	}
+	origin := canonicalAddr(req.URL)
+	alt, ok, clear := http3ParseAltSvc(v, req.URL.Hostname(), time.Now())
+	t.h3Mu.Lock()
+	defer t.h3Mu.Unlock()
+	switch {
+	case clear:
+		delete(t.h3AltSvc, origin)
+	case ok:
+		if t.h3AltSvc == nil {
+			t.h3AltSvc = make(map[string]http3AltSvc)
+		}
+		t.h3AltSvc[origin] = alt
+	}
+}
+
+// http3AltSvcFor returns the HTTP/3 alternative service for origin.
+func (t *Transport) http3AltSvcFor(origin string) (http3AltSvc, bool) {
+	t.h3Mu.Lock()
+	defer t.h3Mu.Unlock()
+	alt, ok := t.h3AltSvc[origin]
+	if ok && time.Now().After(alt.expires) {
+		delete(t.h3AltSvc, origin)
+		return http3AltSvc{}, false
+	}
+	return alt, ok
+}
+
+// http3ForgetAltSvc stops using the HTTP/3 alternative service for
+// origin, after failing to connect to it.
+func (t *Transport) http3ForgetAltSvc(origin string) {
+	t.h3Mu.Lock()
+	defer t.h3Mu.Unlock()
+	delete(t.h3AltSvc, origin)
+}
+
+// http3RoundTrip sends req over HTTP/3 if an alternative service is
+// known for its origin. It returns errHTTP3Unavailable if the request
+// should be sent over TCP instead.
+func (t *Transport) http3RoundTrip(req *Request) (*Response, error) {
+	if t.Proxy != nil {
+		// HTTP/3 is not sent through proxies.
+		if u, err := t.Proxy(req); err != nil || u != nil {
+			return nil, errHTTP3Unavailable
+		}
+	}
+	origin := canonicalAddr(req.URL)
+	alt, ok := t.http3AltSvcFor(origin)
+	if !ok {
+		return nil, errHTTP3Unavailable
+	}
+	ctx := req.Context()
+	serverName := req.URL.Hostname()
+	for retry := false; ; retry = true {
+		cc, err := t.http3ClientConn(ctx, alt.addr, serverName)
+		if err != nil {
+			if ctx.Err() != nil {
+				req.closeBody()
+				return nil, ctx.Err()
+			}
+			t.http3ForgetAltSvc(origin)
+			return nil, errHTTP3Unavailable
+		}
+		resp, err := cc.roundTrip(req)
+		if err == errHTTP3Unavailable && !retry {
+			// The connection closed or went away since it
+			// was last used; try a new one.
+			continue
+		}
+		return resp, err
+	}
+}
+
+// An http3DialCall is an in-flight dial of an HTTP/3 connection.
+type http3DialCall struct {
+	done    chan struct{}
+	cancel  context.CancelFunc // cancels the dial
+	waiters int                // requests waiting for the dial; guarded by Transport.h3Mu
+	cc      *http3ClientConn
+	err     error
+}
+
+// http3ClientConn returns a usable HTTP/3 connection to addr,
+// dialing a new one if necessary.
+func (t *Transport) http3ClientConn(ctx context.Context, addr, serverName string) (*http3ClientConn, error) {
+	key := addr + "|" + serverName
+	t.h3Mu.Lock()
+	if cc := t.h3Conns[key]; cc != nil && cc.canTakeNewRequest() {
+		t.h3Mu.Unlock()
+		return cc, nil
+	}
+	call := t.h3Dials[key]
+	if call == nil {
+		dialCtx, cancel := context.WithCancel(context.Background())
+		call = &http3DialCall{done: make(chan struct{}), cancel: cancel}
+		if t.h3Dials == nil {
+			t.h3Dials = make(map[string]*http3DialCall)
+		}
+		t.h3Dials[key] = call
+		go func() {
+			// The dial is shared with other requests, so it is
+			// only canceled once none of them waits for it.
+			call.cc, call.err = t.dialHTTP3(dialCtx, addr, serverName)
+			cancel()
+			t.h3Mu.Lock()
+			if t.h3Dials[key] == call {
+				delete(t.h3Dials, key)
+			}
+			if call.err == nil {
+				if t.h3Conns == nil {
+					t.h3Conns = make(map[string]*http3ClientConn)
+				}
+				t.h3Conns[key] = call.cc
+				go func(cc *http3ClientConn) {
+					<-cc.qconn.Done()
+					t.h3Mu.Lock()
+					if t.h3Conns[key] == cc {
+						delete(t.h3Conns, key)
+					}
+					t.h3Mu.Unlock()
+				}(call.cc)
+			}
+			t.h3Mu.Unlock()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	t.h3Mu.Unlock()
+	select {
+	case <-call.done:
+		return call.cc, call.err
+	case <-ctx.Done():
+		t.h3Mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// Nobody wants the connection any more. Later
+			// requests start a new dial.
+			call.cancel()
+			if t.h3Dials[key] == call {
+				delete(t.h3Dials, key)
+			}
+		}
+		t.h3Mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// http3DialTimeout bounds the QUIC handshake of a new HTTP/3 connection.
+const http3DialTimeout = 30 * time.Second
+
+func (t *Transport) dialHTTP3(ctx context.Context, addr, serverName string) (*http3ClientConn, error) {
+	config := cloneTLSConfig(t.TLSClientConfig)
+	if config.ServerName == "" {
+		config.ServerName = serverName
+	}
+	config.NextProtos = []string{http3NextProto}
+	timeout := http3DialTimeout
+	if t.TLSHandshakeTimeout > 0 {
+		timeout = t.TLSHandshakeTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	qconn, err := quic.Dial(ctx, "udp", addr, &quic.Config{
+		TLSConfig:      config,
+		MaxIdleTimeout: t.IdleConnTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	cc := &http3ClientConn{
+		t:             t,
+		qconn:         qconn,
+		peerMaxHeader: -1,
+	}
+	st, err := qconn.OpenUniStream(ctx)
+	if err == nil {
+		err = newHTTP3Stream(st).writeSettings(t.maxResponseHeaderBytes())
+	}
+	if err != nil {
+		http3CloseConn(qconn)
+		return nil, err
+	}
+	go cc.acceptUniStreams()
+	return cc, nil
+}
+
+func (t *Transport) maxResponseHeaderBytes() int64 {
+	if t.MaxResponseHeaderBytes > 0 {
+		return t.MaxResponseHeaderBytes
+	}
+	return 10 << 20 // conservative default; same as http2
+}
+
+// closeIdleHTTP3Conns closes HTTP/3 connections with no requests
+// in progress.
+func (t *Transport) closeIdleHTTP3Conns() {
+	t.h3Mu.Lock()
+	defer t.h3Mu.Unlock()
+	for key, cc := range t.h3Conns {
+		cc.mu.Lock()
+		idle := cc.active == 0
+		if idle {
+			cc.closed = true
+		}
+		cc.mu.Unlock()
+		if idle {
+			http3CloseConn(cc.qconn)
+			delete(t.h3Conns, key)
+		}
+	}
+}
+
+// An http3ClientConn is the client side of an HTTP/3 connection.
+type http3ClientConn struct {
+	t     *Transport
+	qconn *quic.Conn
+
+	mu            sync.Mutex
+	active        int   // requests in progress
+	peerMaxHeader int64 // peer's SETTINGS_MAX_FIELD_SECTION_SIZE, or -1
+	goaway        bool  // GOAWAY received
+	closed        bool
+}
+
+func (cc *http3ClientConn) canTakeNewRequest() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return !cc.goaway && !cc.closed && cc.qconn.Err() == nil
+}
+
+func (cc *http3ClientConn) acceptUniStreams() {
+	for {
+		st, err := cc.qconn.AcceptUniStream(context.Background())
+		if err != nil {
+			return
+		}
+		go handleHTTP3UniStream(st, func(s *http3Stream) {
+			max, err := s.readSettings()
+			if err != nil {
+				err.(*http3ConnError).abort(cc.qconn)
+				return
+			}
+			cc.mu.Lock()
+			cc.peerMaxHeader = max
+			cc.mu.Unlock()
+			err = s.readControlFrames(func(uint64) {
+				// Requests already sent may still complete,
+				// but new ones must use a new connection.
+				cc.mu.Lock()
+				cc.goaway = true
+				cc.mu.Unlock()
+			})
+			if cc.qconn.Err() == nil {
+				err.(*http3ConnError).abort(cc.qconn)
+			}
+		})
+	}
+}
+
+// roundTrip sends req on a new request stream. It returns
+// errHTTP3Unavailable if the connection can no longer be used.
+func (cc *http3ClientConn) roundTrip(req *Request) (*Response, error) {
+	ctx := req.Context()
+	cc.mu.Lock()
+	if cc.goaway || cc.closed {
+		cc.mu.Unlock()
+		return nil, errHTTP3Unavailable
+	}
+	cc.active++
+	peerMaxHeader := cc.peerMaxHeader
+	cc.mu.Unlock()
+
+	st, err := cc.qconn.OpenStream(ctx)
+	if err != nil {
+		cc.endRequest()
+		if ctx.Err() != nil {
+			req.closeBody()
+			return nil, ctx.Err()
+		}
+		return nil, errHTTP3Unavailable
+	}
+	s := newHTTP3Stream(st)
+
+	hdr, err := cc.encodeRequestHeader(req)
+	if err == nil && peerMaxHeader >= 0 && int64(len(hdr)) > peerMaxHeader {
+		err = errRequestHeaderListSize
+	}
+	if err != nil {
+		s.abort(http3ErrRequestCancelled)
+		cc.endRequest()
+		req.closeBody()
+		return nil, err
+	}
+
+	// Once the request starts, stop its stream if the request
+	// is canceled before the response body is closed.
+	done := make(chan struct{})
+	var doneOnce sync.Once
+	finish := func() {
+		doneOnce.Do(func() {
+			close(done)
+			cc.endRequest()
+		})
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.abort(http3ErrRequestCancelled)
+		case <-done:
+		}
+	}()
+
+	if err := s.writeFrame(http3FrameHeaders, hdr); err != nil {
+		finish()
+		req.closeBody()
+		return nil, cc.requestError(ctx, err)
+	}
+	if req.outgoingLength() == 0 {
+		req.closeBody()
+		if err := s.bw.Flush(); err != nil {
+			finish()
+			return nil, cc.requestError(ctx, err)
+		}
+		st.Close()
+	} else {
+		go cc.writeRequestBody(s, req)
+	}
+
+	resp, err := cc.readResponse(s, req)
+	if err != nil {
+		s.abort(http3ErrRequestCancelled)
+		finish()
+		return nil, cc.requestError(ctx, err)
+	}
+	body := resp.Body.(*http3Body)
+	body.onClose = func(eof bool) {
+		if !eof {
+			s.abort(http3ErrRequestCancelled)
+		}
+		finish()
+	}
+	if req.Method == "HEAD" || !bodyAllowedForStatus(resp.StatusCode) {
+		resp.Body.Close()
+		resp.Body = NoBody
+	}
+	return resp, nil
+}
+
+func (cc *http3ClientConn) endRequest() {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.active--
+}
+
+// requestError returns the error to report for a failed request.
+func (cc *http3ClientConn) requestError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if cerr, ok := err.(*http3ConnError); ok {
+		cerr.abort(cc.qconn)
+	}
+	return err
+}
+
+var errRequestHeaderListSize = errors.New("http: request header list larger than peer's advertised limit")
+
+// encodeRequestHeader encodes the HEADERS frame payload for req.
+// See RFC 9114, Section 4.3.1.
+func (cc *http3ClientConn) encodeRequestHeader(req *Request) ([]byte, error) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	host, err := httpguts.PunycodeHostPort(host)
+	if err != nil {
+		return nil, err
+	}
+	method := req.Method
+	if method == "" {
+		method = "GET"
+	}
+	if !validMethod(method) {
+		return nil, errors.New("http: invalid method " + strconv.Quote(method))
+	}
+
+	enc := newHTTP3FieldEncoder()
+	enc.writeField(":method", method)
+	if method != "CONNECT" {
+		path := req.URL.RequestURI()
+		if !validPseudoPath(path) {
+			if req.URL.Opaque == "" {
+				return nil, errors.New("http: invalid request :path " + strconv.Quote(path))
+			}
+			path = req.URL.Opaque
+		}
+		enc.writeField(":scheme", "https")
+		enc.writeField(":authority", host)
+		enc.writeField(":path", path)
+	} else {
+		enc.writeField(":authority", host)
+	}
+
+	skip := map[string]bool{"host": true, "content-length": true, "trailer": true}
+	if len(req.Trailer) > 0 {
+		var keys []string
+		for k := range req.Trailer {
+			keys = append(keys, CanonicalHeaderKey(k))
+		}
+		enc.writeField("trailer", strings.Join(keys, ","))
+	}
+	if _, ok := req.Header["User-Agent"]; !ok {
+		enc.writeField("user-agent", defaultUserAgent)
+	}
+	if n := req.outgoingLength(); n > 0 {
+		enc.writeField("content-length", strconv.FormatInt(n, 10))
+	}
+	enc.writeHeader(req.Header, skip)
+	return enc.b, nil
+}
+
+// validPseudoPath reports whether v is a valid :path pseudo-header
+// value: either an absolute path, or "*" for OPTIONS requests.
+func validPseudoPath(v string) bool {
+	return (len(v) > 0 && v[0] == '/') || v == "*"
+}
+
+// writeRequestBody sends the request body and trailers,
+// then closes the send side of the stream.
+func (cc *http3ClientConn) writeRequestBody(s *http3Stream, req *Request) {
+	err := func() error {
+		defer req.closeBody()
+		buf := make([]byte, 16<<10)
+		for {
+			n, err := req.Body.Read(buf)
+			if n > 0 {
+				if werr := s.writeFrame(http3FrameData, buf[:n]); werr != nil {
+					return werr
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if err := s.bw.Flush(); err != nil {
+				return err
+			}
+		}
+		if len(req.Trailer) > 0 {
+			enc := newHTTP3FieldEncoder()
+			enc.writeHeader(req.Trailer, nil)
+			if err := s.writeFrame(http3FrameHeaders, enc.b); err != nil {
+				return err
+			}
+		}
+		return s.bw.Flush()
+	}()
+	if err != nil {
+		s.st.Reset(uint64(http3ErrRequestCancelled))
+		return
+	}
+	s.st.Close()
+}
+
+// readResponse reads the response header, skipping interim responses.
+func (cc *http3ClientConn) readResponse(s *http3Stream, req *Request) (*Response, error) {
+	maxHeaderSize := cc.t.maxResponseHeaderBytes()
+	for {
+		payload, err := s.readHeadersFrame(maxHeaderSize)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		pseudo, header, err := http3DecodeHeader(payload)
+		if err != nil {
+			return nil, err
+		}
+		status := pseudo[":status"]
+		if len(pseudo) != 1 || len(status) != 3 {
+			return nil, errHTTP3Malformed
+		}
+		code, err := strconv.Atoi(status)
+		if err != nil || code < 100 {
+			return nil, errHTTP3Malformed
+		}
+		if code < 200 {
+			if code == StatusSwitchingProtocols {
+				return nil, errHTTP3Malformed
+			}
+			// Interim response. See RFC 9114, Section 4.1.
+			continue
+		}
+		contentLength, err := http3ContentLength(header)
+		if err != nil {
+			return nil, err
+		}
+		resp := &Response{
+			Status:        status + " " + StatusText(code),
+			StatusCode:    code,
+			Proto:         "HTTP/3.0",
+			ProtoMajor:    3,
+			Header:        header,
+			ContentLength: contentLength,
+			Trailer:       http3DeclaredTrailer(header),
+		}
+		resp.Body = &http3Body{
+			s:             s,
+			maxHeaderSize: maxHeaderSize,
+			contentLength: contentLength,
+			trailer:       &resp.Trailer,
+		}
+		if req.Method == "HEAD" {
+			resp.Body.(*http3Body).contentLength = -1
+		}
+		state := cc.qconn.ConnectionState()
+		resp.TLS = &state
+		return resp, nil
+	}
+}
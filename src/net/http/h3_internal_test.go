@@ -0,0 +1,111 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTP3FieldSectionRoundTrip(t *testing.T) {
+	fields := [][2]string{
+		{":method", "GET"},                     // static table entry
+		{":path", "/index.html"},               // static name reference
+		{":authority", "example.com"},          // static name, Huffman value
+		{"x-custom", "value"},                  // literal name
+		{"content-type", "text/plain"},         // static table entry
+		{"x-long", strings.Repeat("z", 1000)},  // multi-byte length
+		{"cookie", "a=b"},                      // static name, short value
+		{"x-binary-ish", "\t tab and spaces "}, // not shortened by Huffman
+	}
+	enc := newHTTP3FieldEncoder()
+	for _, f := range fields {
+		enc.writeField(f[0], f[1])
+	}
+	var got [][2]string
+	err := http3DecodeFieldSection(enc.b, func(name, value string) error {
+		got = append(got, [2]string{name, value})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, fields) {
+		t.Errorf("decoded fields = %q; want %q", got, fields)
+	}
+}
+
+func TestHTTP3DecodeFieldSectionErrors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		b    []byte
+	}{
+		{"empty", nil},
+		{"dynamic table insert count", []byte{0x01, 0x00}},
+		{"dynamic indexed field", []byte{0x00, 0x00, 0x80}},
+		{"static index out of range", []byte{0x00, 0x00, 0xff, 0x30}},
+		{"post-base indexed field", []byte{0x00, 0x00, 0x10}},
+		{"truncated literal", []byte{0x00, 0x00, 0x27, 'a'}},
+	} {
+		err := http3DecodeFieldSection(test.b, func(name, value string) error { return nil })
+		if err == nil {
+			t.Errorf("%v: decoding %x succeeded; want error", test.name, test.b)
+		}
+	}
+}
+
+func TestHTTP3DecodeHeaderMalformed(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		fields [][2]string
+	}{
+		{"pseudo after regular", [][2]string{{"x-a", "b"}, {":path", "/"}}},
+		{"duplicate pseudo", [][2]string{{":path", "/"}, {":path", "/"}}},
+		{"uppercase name", [][2]string{{"X-A", "b"}}},
+		{"connection-specific", [][2]string{{"connection", "close"}}},
+	} {
+		enc := newHTTP3FieldEncoder()
+		for _, f := range test.fields {
+			enc.writeField(f[0], f[1])
+		}
+		if _, _, err := http3DecodeHeader(enc.b); err == nil {
+			t.Errorf("%v: decoding succeeded; want error", test.name)
+		}
+	}
+}
+
+func TestHTTP3ParseAltSvc(t *testing.T) {
+	now := time.Unix(1e9, 0)
+	for _, test := range []struct {
+		v      string
+		addr   string
+		maxAge time.Duration
+		clear  bool
+	}{
+		{v: `h3=":443"`, addr: "example.com:443", maxAge: 24 * time.Hour},
+		{v: `h3=":8443"; ma=60`, addr: "example.com:8443", maxAge: time.Minute},
+		{v: `h2=":443", h3="alt.example.com:443"; ma=10; persist=1`, addr: "alt.example.com:443", maxAge: 10 * time.Second},
+		{v: `h3-29=":443"`},
+		{v: `h3=443`},
+		{v: `clear`, clear: true},
+	} {
+		alt, ok, clear := http3ParseAltSvc(test.v, "example.com", now)
+		if clear != test.clear {
+			t.Errorf("%q: clear = %v; want %v", test.v, clear, test.clear)
+		}
+		if ok != (test.addr != "") {
+			t.Errorf("%q: ok = %v; want %v", test.v, ok, !ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if alt.addr != test.addr || !alt.expires.Equal(now.Add(test.maxAge)) {
+			t.Errorf("%q: got %v expiring in %v; want %v expiring in %v", test.v, alt.addr, alt.expires.Sub(now), test.addr, test.maxAge)
+		}
+	}
+}
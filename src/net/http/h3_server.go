@@ -0,0 +1,686 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTTP/3 server implementation. See RFC 9114.
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"internal/quic"
+	"net"
+	"net/http/internal/ascii"
+	"net/url"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServeHTTP3 accepts incoming HTTP/3 connections on the PacketConn pc,
+// creating a new service goroutine for each request. The service
+// goroutines read requests and then call srv.Handler to reply to them.
+// ServeHTTP3 takes ownership of pc and closes it before returning.
+//
+// Certificates are configured as for ServeTLS. The TLS configuration's
+// NextProtos is replaced with "h3", and TLS 1.3 is always used.
+//
+// While ServeHTTP3 is running, responses sent by srv over TLS with
+// HTTP/1.1 or HTTP/2 include an Alt-Svc header field advertising
+// HTTP/3 on pc's port, unless the handler sets its own Alt-Svc field.
+//
+// ServeHTTP3 always returns a non-nil error. After Shutdown or Close,
+// the returned error is ErrServerClosed.
+func (srv *Server) ServeHTTP3(pc net.PacketConn, certFile, keyFile string) error {
+	// Setup HTTP/2 as ServeTLS does, to initialize srv.TLSConfig
+	// before we clone it. This also waits for a concurrent Serve or
+	// ServeTLS to finish modifying it.
+	if err := srv.setupHTTP2_ServeTLS(); err != nil {
+		pc.Close()
+		return err
+	}
+
+	config := cloneTLSConfig(srv.TLSConfig)
+	config.NextProtos = []string{http3NextProto}
+	configHasCert := len(config.Certificates) > 0 || config.GetCertificate != nil
+	if !configHasCert || certFile != "" || keyFile != "" {
+		var err error
+		config.Certificates = make([]tls.Certificate, 1)
+		config.Certificates[0], err = tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			pc.Close()
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hs := &http3Server{
+		srv: srv,
+		ep: quic.NewEndpoint(pc, &quic.Config{
+			TLSConfig:      config,
+			MaxIdleTimeout: srv.idleTimeout(),
+		}),
+		stopAccept: cancel,
+		conns:      make(map[*http3ServerConn]struct{}),
+	}
+	if !srv.trackHTTP3Server(hs, true) {
+		hs.ep.Close()
+		return ErrServerClosed
+	}
+
+	if addr, ok := pc.LocalAddr().(*net.UDPAddr); ok {
+		srv.h3AltSvc.Store(fmt.Sprintf(`%v=":%d"; ma=%d`, http3NextProto, addr.Port, int(http3AltSvcMaxAge/time.Second)))
+		defer srv.h3AltSvc.Store("")
+	}
+
+	baseCtx := context.WithValue(context.Background(), ServerContextKey, srv)
+	baseCtx = context.WithValue(baseCtx, LocalAddrContextKey, pc.LocalAddr())
+	for {
+		qconn, err := hs.ep.Accept(ctx)
+		if err != nil {
+			if srv.shuttingDown() {
+				// Close and Shutdown take care of the endpoint.
+				return ErrServerClosed
+			}
+			srv.trackHTTP3Server(hs, false)
+			hs.ep.Close()
+			return err
+		}
+		c := &http3ServerConn{
+			hs:        hs,
+			qconn:     qconn,
+			streamIDs: -1,
+		}
+		if !hs.trackConn(c, true) {
+			http3CloseConn(qconn)
+			continue
+		}
+		go c.serve(baseCtx)
+	}
+}
+
+// ListenAndServeHTTP3 listens on the UDP network address srv.Addr and
+// then calls ServeHTTP3 to handle requests on incoming HTTP/3 connections.
+//
+// Certificates are configured as for ListenAndServeTLS.
+// If srv.Addr is blank, ":https" is used.
+//
+// ListenAndServeHTTP3 always returns a non-nil error. After Shutdown or
+// Close, the returned error is ErrServerClosed.
+func (srv *Server) ListenAndServeHTTP3(certFile, keyFile string) error {
+	if srv.shuttingDown() {
+		return ErrServerClosed
+	}
+	addr := srv.Addr
+	if addr == "" {
+		addr = ":https"
+	}
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	return srv.ServeHTTP3(pc, certFile, keyFile)
+}
+
+// http3AltSvcMaxAge is the lifetime of the Alt-Svc advertisement
+// sent by servers running ServeHTTP3.
+const http3AltSvcMaxAge = 24 * time.Hour
+
+// trackHTTP3Server adds or removes an HTTP/3 server to the set of
+// tracked servers. It reports whether the server is still up.
+func (s *Server) trackHTTP3Server(hs *http3Server, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.h3Servers == nil {
+		s.h3Servers = make(map[*http3Server]struct{})
+	}
+	if add {
+		if s.shuttingDown() {
+			return false
+		}
+		s.h3Servers[hs] = struct{}{}
+	} else {
+		delete(s.h3Servers, hs)
+	}
+	return true
+}
+
+// closeHTTP3ServersLocked closes all HTTP/3 endpoints, and with them
+// all of their connections. s.mu must be held.
+func (s *Server) closeHTTP3ServersLocked() error {
+	var err error
+	for hs := range s.h3Servers {
+		hs.stopAccept()
+		if cerr := hs.ep.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(s.h3Servers, hs)
+	}
+	return err
+}
+
+// shutdownHTTP3ServersLocked stops accepting HTTP/3 connections and
+// sends a GOAWAY on each existing connection. s.mu must be held.
+func (s *Server) shutdownHTTP3ServersLocked() {
+	for hs := range s.h3Servers {
+		hs.shutdown()
+	}
+}
+
+// closeIdleHTTP3Servers closes the endpoints of HTTP/3 servers which
+// have no requests in progress, and reports whether all endpoints are
+// closed.
+func (s *Server) closeIdleHTTP3Servers() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hs := range s.h3Servers {
+		if !hs.idle() {
+			continue
+		}
+		hs.ep.Close()
+		delete(s.h3Servers, hs)
+	}
+	return len(s.h3Servers) == 0
+}
+
+// An http3Server serves HTTP/3 on a single QUIC endpoint.
+type http3Server struct {
+	srv        *Server
+	ep         *quic.Endpoint
+	stopAccept context.CancelFunc
+
+	mu           sync.Mutex
+	conns        map[*http3ServerConn]struct{}
+	shuttingDown bool
+}
+
+func (hs *http3Server) trackConn(c *http3ServerConn, add bool) bool {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if add {
+		if hs.shuttingDown {
+			return false
+		}
+		hs.conns[c] = struct{}{}
+	} else {
+		delete(hs.conns, c)
+	}
+	return true
+}
+
+func (hs *http3Server) shutdown() {
+	hs.stopAccept()
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.shuttingDown = true
+	for c := range hs.conns {
+		c.goAway()
+	}
+}
+
+// idle reports whether no requests are in progress.
+func (hs *http3Server) idle() bool {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	for c := range hs.conns {
+		c.mu.Lock()
+		active := c.active
+		c.mu.Unlock()
+		if active > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// An http3ServerConn is the server side of an HTTP/3 connection.
+type http3ServerConn struct {
+	hs    *http3Server
+	qconn *quic.Conn
+
+	mu         sync.Mutex
+	control    *http3Stream // our control stream
+	active     int          // requests in progress
+	streamIDs  int64        // highest request stream ID accepted, or -1
+	sentGoaway bool
+}
+
+func (c *http3ServerConn) serve(ctx context.Context) {
+	srv := c.hs.srv
+	defer c.hs.trackConn(c, false)
+	defer http3CloseConn(c.qconn)
+
+	st, err := c.qconn.OpenUniStream(ctx)
+	if err != nil {
+		return
+	}
+	control := newHTTP3Stream(st)
+	if err := control.writeSettings(int64(srv.maxHeaderBytes())); err != nil {
+		return
+	}
+	c.mu.Lock()
+	c.control = control
+	c.mu.Unlock()
+	if srv.shuttingDown() {
+		c.goAway()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go c.acceptUniStreams(ctx)
+	for {
+		st, err := c.qconn.AcceptStream(ctx)
+		if err != nil {
+			return
+		}
+		c.mu.Lock()
+		if c.sentGoaway {
+			// Requests on streams opened after a GOAWAY are
+			// rejected and may be retried. See RFC 9114, Section 5.2.
+			c.mu.Unlock()
+			newHTTP3Stream(st).abort(http3ErrRequestRejected)
+			continue
+		}
+		c.active++
+		if st.ID() > c.streamIDs {
+			c.streamIDs = st.ID()
+		}
+		c.mu.Unlock()
+		go c.serveRequest(ctx, newHTTP3Stream(st))
+	}
+}
+
+func (c *http3ServerConn) acceptUniStreams(ctx context.Context) {
+	for {
+		st, err := c.qconn.AcceptUniStream(ctx)
+		if err != nil {
+			return
+		}
+		go handleHTTP3UniStream(st, func(s *http3Stream) {
+			if _, err := s.readSettings(); err != nil {
+				err.(*http3ConnError).abort(c.qconn)
+				return
+			}
+			err := s.readControlFrames(func(uint64) {
+				// Clients send GOAWAY to refuse server pushes,
+				// which are never sent.
+			})
+			if ctx.Err() == nil {
+				err.(*http3ConnError).abort(c.qconn)
+			}
+		})
+	}
+}
+
+// goAway sends a GOAWAY frame allowing requests already received
+// to complete. See RFC 9114, Section 5.2.
+func (c *http3ServerConn) goAway() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sentGoaway || c.control == nil {
+		return
+	}
+	c.sentGoaway = true
+	id := c.streamIDs + 4
+	if c.streamIDs < 0 {
+		id = 0
+	}
+	c.control.writeFrame(http3FrameGoaway, http3AppendVarint(nil, uint64(id)))
+	c.control.bw.Flush()
+	if c.active == 0 {
+		http3CloseConn(c.qconn)
+	}
+}
+
+func (c *http3ServerConn) endRequest() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active--
+	if c.active == 0 && c.sentGoaway {
+		http3CloseConn(c.qconn)
+	}
+}
+
+func (c *http3ServerConn) serveRequest(ctx context.Context, s *http3Stream) {
+	defer c.endRequest()
+	srv := c.hs.srv
+	remoteAddr := c.qconn.RemoteAddr().String()
+	maxHeaderSize := int64(srv.maxHeaderBytes())
+
+	payload, err := s.readHeadersFrame(maxHeaderSize)
+	if err == errHTTP3FrameTooLarge {
+		writeHTTP3Status(s, StatusRequestHeaderFieldsTooLarge)
+		return
+	}
+	if err != nil {
+		c.streamError(s, err)
+		return
+	}
+	req, err := c.newRequest(ctx, s, payload)
+	if err != nil {
+		c.streamError(s, err)
+		return
+	}
+	reqCtx, cancelCtx := context.WithCancel(req.Context())
+	req = req.WithContext(reqCtx)
+	req.RemoteAddr = remoteAddr
+	defer cancelCtx()
+
+	w := &http3ResponseWriter{
+		srv:           srv,
+		s:             s,
+		req:           req,
+		handlerHeader: make(Header),
+		contentLength: -1,
+	}
+	body := req.Body.(*http3Body)
+	body.onClose = func(eof bool) {
+		if !eof {
+			s.st.CloseRead(uint64(http3ErrNoError))
+		}
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			if err != ErrAbortHandler {
+				const size = 64 << 10
+				buf := make([]byte, size)
+				buf = buf[:runtime.Stack(buf, false)]
+				srv.logf("http: panic serving %v: %v\n%s", remoteAddr, err, buf)
+			}
+			s.abort(http3ErrInternalError)
+			return
+		}
+		w.finish()
+		// The request body is no longer needed once the response
+		// is complete. See RFC 9114, Section 4.1.
+		body.Close()
+		// Wait for the response to be delivered, so that closing
+		// the connection after a GOAWAY does not cut it short.
+		s.st.CloseWait(ctx)
+	}()
+	serverHandler{srv}.ServeHTTP(w, req)
+}
+
+// streamError reports an error reading a request.
+func (c *http3ServerConn) streamError(s *http3Stream, err error) {
+	switch err := err.(type) {
+	case *http3ConnError:
+		err.abort(c.qconn)
+	case *http3StreamError:
+		s.abort(err.code)
+	default:
+		s.abort(http3ErrRequestIncomplete)
+	}
+}
+
+// writeHTTP3Status writes a response with status code and no body.
+func writeHTTP3Status(s *http3Stream, code int) {
+	enc := newHTTP3FieldEncoder()
+	enc.writeField(":status", strconv.Itoa(code))
+	s.writeFrame(http3FrameHeaders, enc.b)
+	s.bw.Flush()
+	s.st.Close()
+	s.st.CloseRead(uint64(http3ErrNoError))
+}
+
+// newRequest creates a Request from the HEADERS frame payload which
+// starts a request stream. See RFC 9114, Section 4.3.1.
+func (c *http3ServerConn) newRequest(ctx context.Context, s *http3Stream, payload []byte) (*Request, error) {
+	pseudo, header, err := http3DecodeHeader(payload)
+	if err != nil {
+		return nil, err
+	}
+	method := pseudo[":method"]
+	scheme := pseudo[":scheme"]
+	authority := pseudo[":authority"]
+	path := pseudo[":path"]
+	for k := range pseudo {
+		switch k {
+		case ":method", ":scheme", ":authority", ":path":
+		default:
+			return nil, errHTTP3Malformed
+		}
+	}
+	if !validMethod(method) {
+		return nil, errHTTP3Malformed
+	}
+	if authority == "" {
+		authority = header.Get("Host")
+	}
+
+	var u *url.URL
+	var requestURI string
+	if method == "CONNECT" {
+		if scheme != "" || path != "" || authority == "" {
+			return nil, errHTTP3Malformed
+		}
+		u = &url.URL{Host: authority}
+		requestURI = authority
+	} else {
+		if scheme == "" || path == "" {
+			return nil, errHTTP3Malformed
+		}
+		u, err = url.ParseRequestURI(path)
+		if err != nil {
+			return nil, errHTTP3Malformed
+		}
+		requestURI = path
+	}
+
+	contentLength, err := http3ContentLength(header)
+	if err != nil {
+		return nil, err
+	}
+	req := &Request{
+		Method:        method,
+		URL:           u,
+		Proto:         "HTTP/3.0",
+		ProtoMajor:    3,
+		ProtoMinor:    0,
+		Header:        header,
+		ContentLength: contentLength,
+		Host:          authority,
+		RequestURI:    requestURI,
+		Trailer:       http3DeclaredTrailer(header),
+	}
+	req.Body = &http3Body{
+		s:             s,
+		maxHeaderSize: int64(c.hs.srv.maxHeaderBytes()),
+		contentLength: contentLength,
+		trailer:       &req.Trailer,
+	}
+	state := c.qconn.ConnectionState()
+	req.TLS = &state
+	return req.WithContext(ctx), nil
+}
+
+// http3ResponseWriter is the ResponseWriter for HTTP/3 requests.
+//
+// Up to http3BufferedBodySize bytes of the body are buffered before
+// sending the header, so that small responses can carry a
+// Content-Length and a sniffed Content-Type.
+type http3ResponseWriter struct {
+	srv *Server
+	s   *http3Stream
+	req *Request
+
+	handlerHeader Header
+	trailers      []string // declared trailer keys
+	status        int
+	wroteHeader   bool  // WriteHeader called
+	sentHeader    bool  // HEADERS frame written
+	contentLength int64 // from the handler's Content-Length, or -1
+	written       int64
+	buf           []byte // body buffered before the header is sent
+	err           error  // sticky write error
+}
+
+const http3BufferedBodySize = 4 << 10
+
+func (w *http3ResponseWriter) Header() Header {
+	return w.handlerHeader
+}
+
+func (w *http3ResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		caller := relevantCaller()
+		w.srv.logf("http: superfluous response.WriteHeader call from %s (%s:%d)", caller.Function, path.Base(caller.File), caller.Line)
+		return
+	}
+	checkWriteHeaderCode(code)
+	w.wroteHeader = true
+	w.status = code
+	if cl := w.handlerHeader.Get("Content-Length"); cl != "" {
+		v, err := strconv.ParseInt(cl, 10, 64)
+		if err == nil && v >= 0 {
+			w.contentLength = v
+		} else {
+			w.handlerHeader.Del("Content-Length")
+		}
+	}
+}
+
+func (w *http3ResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(StatusOK)
+	}
+	if !bodyAllowedForStatus(w.status) {
+		return 0, ErrBodyNotAllowed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.contentLength >= 0 && w.written+int64(len(p)) > w.contentLength {
+		return 0, ErrContentLength
+	}
+	w.written += int64(len(p))
+	if w.req.Method == "HEAD" {
+		return len(p), nil
+	}
+	if !w.sentHeader {
+		if len(w.buf)+len(p) <= http3BufferedBodySize {
+			w.buf = append(w.buf, p...)
+			return len(p), nil
+		}
+		w.sendHeader(false)
+	}
+	if w.err == nil {
+		w.err = w.s.writeFrame(http3FrameData, p)
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	return len(p), nil
+}
+
+// Flush sends any buffered data to the client.
+func (w *http3ResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(StatusOK)
+	}
+	if !w.sentHeader {
+		w.sendHeader(false)
+	}
+	if w.err == nil {
+		w.err = w.s.bw.Flush()
+	}
+}
+
+// sendHeader writes the HEADERS frame, followed by any buffered body.
+// final reports whether the handler has returned.
+func (w *http3ResponseWriter) sendHeader(final bool) {
+	w.sentHeader = true
+	h := w.handlerHeader
+	enc := newHTTP3FieldEncoder()
+	enc.writeField(":status", strconv.Itoa(w.status))
+	skip := map[string]bool{"trailer": true}
+	for _, v := range h["Trailer"] {
+		foreachHeaderElement(v, func(key string) {
+			key = CanonicalHeaderKey(key)
+			w.trailers = append(w.trailers, key)
+			if lower, ok := ascii.ToLower(key); ok {
+				skip[lower] = true
+			}
+		})
+	}
+	if len(w.trailers) > 0 {
+		enc.writeField("trailer", strings.Join(w.trailers, ", "))
+	}
+	if _, ok := h["Date"]; !ok {
+		enc.writeField("date", time.Now().UTC().Format(TimeFormat))
+	}
+	bodyAllowed := bodyAllowedForStatus(w.status)
+	if _, ok := h["Content-Type"]; !ok && bodyAllowed && len(w.buf) > 0 {
+		if _, haveEncoding := h["Content-Encoding"]; !haveEncoding {
+			enc.writeField("content-type", DetectContentType(w.buf))
+		}
+	}
+	if _, ok := h["Content-Length"]; !ok && final && bodyAllowed && len(w.trailers) == 0 &&
+		(w.req.Method != "HEAD" || w.written > 0) {
+		enc.writeField("content-length", strconv.FormatInt(w.written, 10))
+	}
+	enc.writeHeader(h, skip)
+	w.err = w.s.writeFrame(http3FrameHeaders, enc.b)
+	if w.err == nil && len(w.buf) > 0 {
+		w.err = w.s.writeFrame(http3FrameData, w.buf)
+	}
+	w.buf = nil
+}
+
+// finish completes the response after the handler returns.
+func (w *http3ResponseWriter) finish() {
+	if !w.wroteHeader {
+		w.WriteHeader(StatusOK)
+	}
+	if !w.sentHeader {
+		w.sendHeader(true)
+	}
+	if w.err == nil && w.contentLength >= 0 && w.written != w.contentLength && w.req.Method != "HEAD" {
+		w.err = errors.New("http: handler wrote less than declared Content-Length")
+	}
+	if w.err == nil {
+		w.err = w.writeTrailers()
+	}
+	if w.err == nil {
+		w.err = w.s.bw.Flush()
+	}
+	if w.err != nil {
+		w.s.abort(http3ErrInternalError)
+		return
+	}
+	w.s.st.Close()
+}
+
+func (w *http3ResponseWriter) writeTrailers() error {
+	var enc *http3FieldEncoder
+	add := func(k string, vv []string) {
+		if enc == nil {
+			enc = newHTTP3FieldEncoder()
+		}
+		t := make(Header)
+		t[k] = vv
+		enc.writeHeader(t, nil)
+	}
+	for _, k := range w.trailers {
+		if vv, ok := w.handlerHeader[k]; ok {
+			add(k, vv)
+		}
+	}
+	for k, vv := range w.handlerHeader {
+		if strings.HasPrefix(k, TrailerPrefix) {
+			add(strings.TrimPrefix(k, TrailerPrefix), vv)
+		}
+	}
+	if enc == nil {
+		return nil
+	}
+	return w.s.writeFrame(http3FrameHeaders, enc.b)
+}
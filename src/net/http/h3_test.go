@@ -0,0 +1,313 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	. "net/http"
+	"net/http/httptest"
+	"net/http/internal/testcert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// h3Test is an httptest.Server which also serves HTTP/3 over loopback.
+type h3Test struct {
+	t     *testing.T
+	ts    *httptest.Server
+	tr    *Transport
+	c     *Client
+	errc  chan error
+	h3Url string // URL of the TCP server whose Alt-Svc points at HTTP/3
+}
+
+func newH3Test(t *testing.T, h Handler) *h3Test {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, testcert.LocalhostCert, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, testcert.LocalhostKey, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(h)
+	ts.StartTLS()
+	st := &h3Test{
+		t:     t,
+		ts:    ts,
+		errc:  make(chan error, 1),
+		h3Url: ts.URL,
+	}
+	go func() {
+		st.errc <- ts.Config.ServeHTTP3(pc, certFile, keyFile)
+	}()
+	st.c = ts.Client()
+	st.tr = st.c.Transport.(*Transport)
+	st.tr.EnableHTTP3 = true
+	return st
+}
+
+func (st *h3Test) close() {
+	st.tr.CloseIdleConnections()
+	st.ts.Config.Close()
+	st.ts.Close()
+	if err := <-st.errc; err != ErrServerClosed {
+		st.t.Errorf("ServeHTTP3 = %v; want ErrServerClosed", err)
+	}
+}
+
+// get fetches path and returns the response protocol and body.
+func (st *h3Test) get(path string) (proto, body string) {
+	st.t.Helper()
+	res, err := st.c.Get(st.h3Url + path)
+	if err != nil {
+		st.t.Fatal(err)
+	}
+	defer res.Body.Close()
+	slurp, err := io.ReadAll(res.Body)
+	if err != nil {
+		st.t.Fatal(err)
+	}
+	return res.Proto, string(slurp)
+}
+
+func TestHTTP3AltSvc(t *testing.T) {
+	defer afterTest(t)
+	st := newH3Test(t, HandlerFunc(func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "%v %v", r.Proto, r.URL.Path)
+	}))
+	defer st.close()
+
+	res, err := st.c.Get(st.h3Url + "/first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.ProtoMajor != 1 {
+		t.Fatalf("first response proto = %v; want HTTP/1.1", res.Proto)
+	}
+	altSvc := res.Header.Get("Alt-Svc")
+	if !strings.HasPrefix(altSvc, `h3=":`) {
+		t.Fatalf("Alt-Svc = %q; want h3 alternative", altSvc)
+	}
+
+	for i := 0; i < 3; i++ {
+		proto, body := st.get("/second")
+		if proto != "HTTP/3.0" || body != "HTTP/3.0 /second" {
+			t.Fatalf("response %v: proto %q, body %q; want HTTP/3.0", i, proto, body)
+		}
+	}
+}
+
+func TestHTTP3RequestBodyAndTrailers(t *testing.T) {
+	defer afterTest(t)
+	st := newH3Test(t, HandlerFunc(func(w ResponseWriter, r *Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading request body: %v", err)
+		}
+		w.Header().Set("Trailer", "X-Sum")
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "%v %q %q", r.Proto, body, r.Trailer.Get("X-Client"))
+		w.Header().Set("X-Sum", fmt.Sprint(len(body)))
+	}))
+	defer st.close()
+	st.get("/") // learn Alt-Svc
+
+	body := strings.Repeat("a", 100<<10)
+	req, _ := NewRequest("POST", st.h3Url, io.MultiReader(strings.NewReader(body)))
+	req.Trailer = Header{"X-Client": nil}
+	req.Body = io.NopCloser(&trailerSettingReader{r: strings.NewReader(body), trailer: req.Trailer})
+	res, err := st.c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	got, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("HTTP/3.0 %q %q", body, "done")
+	if string(got) != want {
+		t.Errorf("body = %.40q...; want %.40q...", got, want)
+	}
+	if got, want := res.Header.Get("Content-Type"), "text/plain"; got != want {
+		t.Errorf("Content-Type = %q; want %q", got, want)
+	}
+	if got, want := res.Trailer.Get("X-Sum"), fmt.Sprint(len(body)); got != want {
+		t.Errorf("X-Sum trailer = %q; want %q", got, want)
+	}
+}
+
+// trailerSettingReader sets the X-Client trailer when r reaches EOF.
+type trailerSettingReader struct {
+	r       io.Reader
+	trailer Header
+}
+
+func (r *trailerSettingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err == io.EOF {
+		r.trailer.Set("X-Client", "done")
+	}
+	return n, err
+}
+
+func TestHTTP3ContentLengthAndSniff(t *testing.T) {
+	defer afterTest(t)
+	st := newH3Test(t, HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, "<html><body>hello</body></html>")
+	}))
+	defer st.close()
+	st.get("/")
+
+	res, err := st.c.Get(st.h3Url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.ProtoMajor != 3 {
+		t.Fatalf("proto = %v; want HTTP/3.0", res.Proto)
+	}
+	if got, want := res.ContentLength, int64(len("<html><body>hello</body></html>")); got != want {
+		t.Errorf("ContentLength = %v; want %v", got, want)
+	}
+	if got, want := res.Header.Get("Content-Type"), "text/html; charset=utf-8"; got != want {
+		t.Errorf("Content-Type = %q; want %q", got, want)
+	}
+	if res.Header.Get("Date") == "" {
+		t.Errorf("missing Date header")
+	}
+}
+
+func TestHTTP3CancelRequest(t *testing.T) {
+	defer afterTest(t)
+	unblock := make(chan struct{})
+	st := newH3Test(t, HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path != "/block" {
+			return
+		}
+		w.WriteHeader(200)
+		w.(Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-unblock:
+		}
+	}))
+	defer st.close()
+	defer close(unblock)
+	st.get("/")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := NewRequestWithContext(ctx, "GET", st.h3Url+"/block", nil)
+	res, err := st.c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.ProtoMajor != 3 {
+		t.Fatalf("proto = %v; want HTTP/3.0", res.Proto)
+	}
+	cancel()
+	if _, err := io.ReadAll(res.Body); err == nil {
+		t.Fatal("reading body of canceled request succeeded")
+	}
+}
+
+func TestHTTP3FallbackToTCP(t *testing.T) {
+	defer afterTest(t)
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadPort := pc.LocalAddr().(*net.UDPAddr).Port
+	pc.Close()
+
+	ts := httptest.NewTLSServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.Header().Set("Alt-Svc", fmt.Sprintf(`h3=":%d"`, deadPort))
+		io.WriteString(w, r.Proto)
+	}))
+	defer ts.Close()
+	c := ts.Client()
+	tr := c.Transport.(*Transport)
+	tr.EnableHTTP3 = true
+	tr.TLSHandshakeTimeout = time.Second
+	defer tr.CloseIdleConnections()
+
+	for i := 0; i < 2; i++ {
+		res, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != "HTTP/1.1" {
+			t.Errorf("request %v sent with %q; want HTTP/1.1", i, body)
+		}
+	}
+}
+
+func TestHTTP3Shutdown(t *testing.T) {
+	defer afterTest(t)
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	st := newH3Test(t, HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/block" {
+			close(started)
+			<-unblock
+		}
+		io.WriteString(w, r.Proto)
+	}))
+	defer st.close()
+	st.get("/")
+
+	errc := make(chan error, 1)
+	go func() {
+		res, err := st.c.Get(st.h3Url + "/block")
+		if err == nil {
+			var body []byte
+			body, err = io.ReadAll(res.Body)
+			res.Body.Close()
+			if err == nil && string(body) != "HTTP/3.0" {
+				err = fmt.Errorf("body = %q; want HTTP/3.0", body)
+			}
+		}
+		errc <- err
+	}()
+	<-started
+
+	shutdownc := make(chan error, 1)
+	go func() {
+		shutdownc <- st.ts.Config.Shutdown(context.Background())
+	}()
+	select {
+	case err := <-shutdownc:
+		t.Fatalf("Shutdown returned %v before request completed", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(unblock)
+	if err := <-errc; err != nil {
+		t.Errorf("in-flight request: %v", err)
+	}
+	if err := <-shutdownc; err != nil && !errors.Is(err, context.Canceled) {
+		t.Errorf("Shutdown = %v", err)
+	}
+}
@@ -120,6 +120,7 @@ func afterTest(t testing.TB) {
 		"timeoutHandler":        "a TimeoutHandler",
 		"net.(*netFD).connect(": "a timing out dial",
 		").noteClientGone(":     "a closenotifier sender",
+		").quicWaitForSignal(":  "a QUIC handshake",
 	}
 	var stacks string
 	for i := 0; i < 10; i++ {
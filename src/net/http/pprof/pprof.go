@@ -41,12 +41,24 @@
 //
 //	go tool pprof http://localhost:6060/debug/pprof/mutex
 //
+// Or to look at the goroutines that are blocked forever, because nothing
+// can reach the channels, mutexes or other synchronization objects they
+// wait on:
+//
+//	go tool pprof http://localhost:6060/debug/pprof/goroutineleak
+//
 // The package also exports a handler that serves execution trace data
 // for the "go tool trace" command. To collect a 5-second execution trace:
 //
 //	curl -o trace.out http://localhost:6060/debug/pprof/trace?seconds=5
 //	go tool trace trace.out
 //
+// If the program is running a runtime/trace.FlightRecorder, the recent
+// history it keeps can be collected at any time with:
+//
+//	curl -o trace.out http://localhost:6060/debug/pprof/flightrecorder
+//	go tool trace trace.out
+//
 // To view all available profiles, open http://localhost:6060/debug/pprof/
 // in your browser.
 //
@@ -62,6 +74,7 @@ import (
 	"context"
 	"fmt"
 	"html"
+	"internal/flightrecorder"
 	"internal/profile"
 	"io"
 	"log"
@@ -83,6 +96,7 @@ func init() {
 	http.HandleFunc("/debug/pprof/profile", Profile)
 	http.HandleFunc("/debug/pprof/symbol", Symbol)
 	http.HandleFunc("/debug/pprof/trace", Trace)
+	http.HandleFunc("/debug/pprof/flightrecorder", FlightRecorder)
 }
 
 // Cmdline responds with the running program's
@@ -172,6 +186,29 @@ func Trace(w http.ResponseWriter, r *http.Request) {
 	trace.Stop()
 }
 
+// FlightRecorder responds with the execution trace kept by the running
+// runtime/trace.FlightRecorder, in binary form. It responds with an error
+// if no flight recorder is running.
+// The package initialization registers it as /debug/pprof/flightrecorder.
+func FlightRecorder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	fr := flightrecorder.Active()
+	if fr == nil {
+		serveError(w, http.StatusNotFound, "No flight recorder is running")
+		return
+	}
+
+	// Set Content Type assuming WriteTo will work,
+	// because if it does it starts writing.
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="trace"`)
+	if n, err := fr.WriteTo(w); err != nil && n == 0 {
+		// The recorder stopped, so no writes yet.
+		serveError(w, http.StatusInternalServerError,
+			fmt.Sprintf("Could not write flight recorder trace: %s", err))
+	}
+}
+
 // Symbol looks up the program counters listed in the request,
 // responding with a table mapping program counters to function names.
 // The package initialization registers it as /debug/pprof/symbol.
@@ -342,15 +379,17 @@ var profileSupportsDelta = map[handler]bool{
 }
 
 var profileDescriptions = map[string]string{
-	"allocs":       "A sampling of all past memory allocations",
-	"block":        "Stack traces that led to blocking on synchronization primitives",
-	"cmdline":      "The command line invocation of the current program",
-	"goroutine":    "Stack traces of all current goroutines",
-	"heap":         "A sampling of memory allocations of live objects. You can specify the gc GET parameter to run GC before taking the heap sample.",
-	"mutex":        "Stack traces of holders of contended mutexes",
-	"profile":      "CPU profile. You can specify the duration in the seconds GET parameter. After you get the profile file, use the go tool pprof command to investigate the profile.",
-	"threadcreate": "Stack traces that led to the creation of new OS threads",
-	"trace":        "A trace of execution of the current program. You can specify the duration in the seconds GET parameter. After you get the trace file, use the go tool trace command to investigate the trace.",
+	"allocs":         "A sampling of all past memory allocations",
+	"block":          "Stack traces that led to blocking on synchronization primitives",
+	"cmdline":        "The command line invocation of the current program",
+	"flightrecorder": "The recent execution trace kept by the running flight recorder, if any. After you get the trace file, use the go tool trace command to investigate the trace.",
+	"goroutine":      "Stack traces of all current goroutines",
+	"goroutineleak":  "Stack traces of leaked goroutines, which are blocked forever on unreachable channels, mutexes or other synchronization objects. Collecting the profile runs a garbage collection.",
+	"heap":           "A sampling of memory allocations of live objects. You can specify the gc GET parameter to run GC before taking the heap sample.",
+	"mutex":          "Stack traces of holders of contended mutexes",
+	"profile":        "CPU profile. You can specify the duration in the seconds GET parameter. After you get the profile file, use the go tool pprof command to investigate the profile.",
+	"threadcreate":   "Stack traces that led to the creation of new OS threads",
+	"trace":          "A trace of execution of the current program. You can specify the duration in the seconds GET parameter. After you get the trace file, use the go tool trace command to investigate the trace.",
 }
 
 type profileEntry struct {
@@ -387,7 +426,7 @@ func Index(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Adding other profiles exposed from within this package
-	for _, p := range []string{"cmdline", "profile", "trace"} {
+	for _, p := range []string{"cmdline", "flightrecorder", "profile", "trace"} {
 		profiles = append(profiles, profileEntry{
 			Name: p,
 			Href: p,
@@ -14,6 +14,7 @@ import (
 	"net/http/httptest"
 	"runtime"
 	"runtime/pprof"
+	"runtime/trace"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -48,6 +49,8 @@ func TestHandlers(t *testing.T) {
 		{"/debug/pprof/profile?seconds=1", Profile, http.StatusOK, "application/octet-stream", `attachment; filename="profile"`, nil},
 		{"/debug/pprof/symbol", Symbol, http.StatusOK, "text/plain; charset=utf-8", "", nil},
 		{"/debug/pprof/trace", Trace, http.StatusOK, "application/octet-stream", `attachment; filename="trace"`, nil},
+		{"/debug/pprof/flightrecorder", FlightRecorder, http.StatusNotFound, "text/plain; charset=utf-8", "", []byte("No flight recorder is running\n")},
+		{"/debug/pprof/goroutineleak", Index, http.StatusOK, "application/octet-stream", `attachment; filename="goroutineleak"`, nil},
 		{"/debug/pprof/mutex", Index, http.StatusOK, "application/octet-stream", `attachment; filename="mutex"`, nil},
 		{"/debug/pprof/block?seconds=1", Index, http.StatusOK, "application/octet-stream", `attachment; filename="block-delta"`, nil},
 		{"/debug/pprof/goroutine?seconds=1", Index, http.StatusOK, "application/octet-stream", `attachment; filename="goroutine-delta"`, nil},
@@ -152,6 +155,45 @@ func mutexHog(duration time.Duration, hogger func(mu1, mu2 *sync.Mutex, start ti
 	wg.Wait()
 }
 
+func TestFlightRecorder(t *testing.T) {
+	if trace.IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	fr := trace.NewFlightRecorder()
+	if err := fr.Start(); err != nil {
+		t.Fatalf("failed to start flight recorder: %v", err)
+	}
+	defer fr.Stop()
+
+	req := httptest.NewRequest("GET", "http://example.com/debug/pprof/flightrecorder", nil)
+	w := httptest.NewRecorder()
+	FlightRecorder(w, req)
+
+	resp := w.Result()
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Fatalf("status code: got %d; want %d", got, want)
+	}
+	if got, want := resp.Header.Get("Content-Disposition"), `attachment; filename="trace"`; got != want {
+		t.Errorf("Content-Disposition: got %q; want %q", got, want)
+	}
+	tr, err := trace.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read trace: %v", err)
+	}
+	for {
+		_, err := tr.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err == trace.ErrTimeOrder {
+			t.Skipf("skipping trace: %v", err)
+		}
+		if err != nil {
+			t.Fatalf("failed to read trace: %v", err)
+		}
+	}
+}
+
 func TestDeltaProfile(t *testing.T) {
 	if runtime.GOOS == "openbsd" && runtime.GOARCH == "arm" {
 		testenv.SkipFlaky(t, 50218)
@@ -0,0 +1,212 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func getTestTree() *routingNode {
+	root := &routingNode{}
+	for _, p := range []string{
+		"/a",
+		"/a/b",
+		"/a/{x}",
+		"/g/h/i",
+		"/g/{x}/j",
+		"/a/b/{x...}",
+		"/a/b/{y}",
+		"/a/b/{$}",
+		"GET /a",
+		"HEAD /foo",
+		"GET /foo/{x}",
+		"POST /{x}/{y}",
+		"POST /bar/",
+		"g.com/",
+		"g.com/a/{x}",
+		"g.com/a/b/{$}",
+		"h.com/foo/bar",
+	} {
+		pat, err := parsePattern(p)
+		if err != nil {
+			panic(err)
+		}
+		root.addPattern(pat, nil)
+	}
+	return root
+}
+
+func TestRoutingAddPattern(t *testing.T) {
+	root := getTestTree()
+
+	// Spot-check the shape of the tree.
+	if root.emptyChild == nil || root.findChild("g.com") == nil || root.findChild("h.com") == nil {
+		t.Fatal("missing host children")
+	}
+	if root.findChild("x.com") != nil {
+		t.Error("unexpected host child x.com")
+	}
+	noHost := root.emptyChild
+	for _, m := range []string{"GET", "HEAD", "POST"} {
+		if noHost.findChild(m) == nil {
+			t.Errorf("missing method child %s", m)
+		}
+	}
+	a := noHost.emptyChild.findChild("a")
+	if a == nil || a.pattern == nil || a.pattern.String() != "/a" {
+		t.Fatalf("bad node for /a: %+v", a)
+	}
+	b := a.findChild("b")
+	if b.multiChild == nil || b.multiChild.pattern.String() != "/a/b/{x...}" {
+		t.Errorf("bad multi child for /a/b: %+v", b.multiChild)
+	}
+	if d := b.findChild("/"); d == nil || d.pattern.String() != "/a/b/{$}" {
+		t.Errorf("bad {$} child for /a/b: %+v", d)
+	}
+}
+
+type testCase struct {
+	method, host, path string
+	wantPat            string // "" for nil (no match)
+	wantMatches        []string
+}
+
+func TestRoutingNodeMatch(t *testing.T) {
+
+	test := func(tree *routingNode, tests []testCase) {
+		t.Helper()
+		for _, test := range tests {
+			gotNode, gotMatches := tree.match(test.host, test.method, test.path)
+			got := ""
+			if gotNode != nil {
+				got = gotNode.pattern.String()
+			}
+			if got != test.wantPat {
+				t.Errorf("%s, %s, %s: got %q, want %q", test.host, test.method, test.path, got, test.wantPat)
+			}
+			if !reflect.DeepEqual(gotMatches, test.wantMatches) {
+				t.Errorf("%s, %s, %s: got matches %v, want %v", test.host, test.method, test.path, gotMatches, test.wantMatches)
+			}
+		}
+	}
+
+	test(getTestTree(), []testCase{
+		{"GET", "", "/a", "GET /a", nil},
+		{"POST", "", "/a", "/a", nil},
+		{"Get", "", "/b", "", nil},
+		{"Get", "", "/a/b", "/a/b", nil},
+		{"Get", "", "/a/c", "/a/{x}", []string{"c"}},
+		{"Get", "", "/a/b/", "/a/b/{$}", nil},
+		{"Get", "", "/a/b/c", "/a/b/{y}", []string{"c"}},
+		{"Get", "", "/a/b/c/d", "/a/b/{x...}", []string{"c/d"}},
+		{"Get", "", "/g/h/i", "/g/h/i", nil},
+		{"Get", "", "/g/h/j", "/g/{x}/j", []string{"h"}},
+		{"Get", "", "/g/h/k", "", nil},
+
+		{"HEAD", "", "/foo", "HEAD /foo", nil},
+		{"HEAD", "", "/foo/bar", "GET /foo/{x}", []string{"bar"}},
+		{"POST", "", "/foo/bar", "POST /{x}/{y}", []string{"foo", "bar"}},
+		{"POST", "", "/foo/bar/baz", "", nil},
+		{"POST", "", "/bar/x/y", "POST /bar/", nil},
+		{"POST", "", "/x", "", nil},
+
+		{"GET", "g.com", "/", "g.com/", nil},
+		{"GET", "g.com", "/a/b", "g.com/a/{x}", []string{"b"}},
+		{"GET", "g.com", "/a/b/", "g.com/a/b/{$}", nil},
+		{"GET", "g.com", "/a/b/c", "g.com/", nil},
+		// A host pattern that doesn't match falls through to patterns without a host.
+		{"GET", "h.com", "/a", "GET /a", nil},
+		{"GET", "h.com", "/foo/bar", "h.com/foo/bar", nil},
+		{"GET", "i.com", "/a/b", "/a/b", nil},
+	})
+
+	tree := &routingNode{}
+	for _, p := range []string{"/a/{x}/", "/a/{x}/b"} {
+		tree.addPattern(mustParsePattern(t, p), nil)
+	}
+	// A multi at a deeper level should still be found after backtracking.
+	test(tree, []testCase{
+		{"GET", "", "/a/c/d", "/a/{x}/", []string{"c"}},
+		{"GET", "", "/a/c/b", "/a/{x}/b", []string{"c"}},
+	})
+
+	tree = &routingNode{}
+	for _, p := range []string{"/{x}/a", "/a/{y}/", "/a/%2f/{z...}"} {
+		tree.addPattern(mustParsePattern(t, p), nil)
+	}
+	// Escaped slashes are part of a segment, and matches are unescaped.
+	test(tree, []testCase{
+		{"GET", "", "/b/a", "/{x}/a", []string{"b"}},
+		{"GET", "", "/a%2fb/a", "/{x}/a", []string{"a/b"}},
+		{"GET", "", "/a/%2f/c%2fd", "/a/%2f/{z...}", []string{"c/d"}},
+		{"GET", "", "/a/b/c", "/a/{y}/", []string{"b"}},
+	})
+}
+
+func TestMatchingMethods(t *testing.T) {
+	hostTree := &routingNode{}
+	for _, p := range []string{"GET a.com/", "PUT b.com/", "POST /foo/{x}"} {
+		hostTree.addPattern(mustParsePattern(t, p), nil)
+	}
+	for _, test := range []struct {
+		name       string
+		tree       *routingNode
+		host, path string
+		want       string
+	}{
+		{
+			"post",
+			getTestTree(),
+			"", "/foo/bar",
+			"GET,HEAD,POST",
+		},
+		{
+			"none",
+			getTestTree(),
+			"", "/x/y/z",
+			"",
+		},
+		{
+			"get implies head",
+			getTestTree(),
+			"", "/a",
+			"GET,HEAD",
+		},
+		{
+			"host",
+			hostTree,
+			"a.com", "/",
+			"GET,HEAD",
+		},
+		{
+			"host plus no host",
+			hostTree,
+			"b.com", "/foo/c",
+			"POST,PUT",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ms := map[string]bool{}
+			test.tree.matchingMethods(test.host, test.path, ms)
+			var keys []string
+			for k := range ms {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			got := ""
+			for i, k := range keys {
+				if i > 0 {
+					got += ","
+				}
+				got += k
+			}
+			if got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
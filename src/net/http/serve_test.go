@@ -399,6 +399,151 @@ func TestServeMuxHandleFuncWithNilHandler(t *testing.T) {
 	mux.HandleFunc("/", nil)
 }
 
+func TestServeMuxMethodNotAllowed(t *testing.T) {
+	setParallel(t)
+	mux := NewServeMux()
+	mux.Handle("GET /items/{id}", serve(200))
+	mux.Handle("PUT /items/{id}", serve(201))
+	mux.Handle("POST /items/", serve(202))
+
+	for _, tt := range []struct {
+		method    string
+		path      string
+		code      int
+		wantAllow string
+	}{
+		{"GET", "/items/1", 200, ""},
+		{"HEAD", "/items/1", 200, ""},
+		{"PUT", "/items/1", 201, ""},
+		{"POST", "/items/1", 202, ""},
+		{"DELETE", "/items/1", 405, "GET, HEAD, POST, PUT"},
+		{"DELETE", "/items/", 405, "POST"},
+		{"DELETE", "/items", 405, "POST"},
+		{"DELETE", "/other", 404, ""},
+	} {
+		r := &Request{Method: tt.method, Host: "example.com", URL: &url.URL{Path: tt.path}}
+		h, _ := mux.Handler(r)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		if rr.Code != tt.code {
+			t.Errorf("%s %s: code = %d, want %d", tt.method, tt.path, rr.Code, tt.code)
+		}
+		if got := rr.Header().Get("Allow"); got != tt.wantAllow {
+			t.Errorf("%s %s: Allow = %q, want %q", tt.method, tt.path, got, tt.wantAllow)
+		}
+	}
+}
+
+func TestServeMuxPathValue(t *testing.T) {
+	setParallel(t)
+	for _, test := range []struct {
+		pattern string
+		url     string
+		want    map[string]string
+	}{
+		{
+			"/{a}/is/{b}/{c...}",
+			"/now/is/the/time/for/all",
+			map[string]string{
+				"a": "now",
+				"b": "the",
+				"c": "time/for/all",
+				"d": "",
+			},
+		},
+		{
+			"/names/{name}/{other...}",
+			"/names/%2fjohn/address",
+			map[string]string{
+				"name":  "/john",
+				"other": "address",
+			},
+		},
+		{
+			"/names/{name}/{other...}",
+			"/names/john%2Fdoe/there/is%2F/more",
+			map[string]string{
+				"name":  "john/doe",
+				"other": "there/is//more",
+			},
+		},
+		{
+			"GET /users/{id}",
+			"/users/42",
+			map[string]string{
+				"id":   "42",
+				"user": "",
+			},
+		},
+	} {
+		mux := NewServeMux()
+		mux.HandleFunc(test.pattern, func(w ResponseWriter, r *Request) {
+			for name, want := range test.want {
+				got := r.PathValue(name)
+				if got != want {
+					t.Errorf("%q, %q: got %q, want %q", test.pattern, name, got, want)
+				}
+			}
+		})
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mux.ServeHTTP(httptest.NewRecorder(), &Request{Method: "GET", URL: u})
+	}
+}
+
+func TestSetPathValue(t *testing.T) {
+	mux := NewServeMux()
+	mux.HandleFunc("/a/{b}/c/{d...}", func(_ ResponseWriter, r *Request) {
+		kvs := map[string]string{
+			"b": "X",
+			"d": "Y",
+			"a": "Z",
+		}
+		for k, v := range kvs {
+			r.SetPathValue(k, v)
+		}
+		for k, w := range kvs {
+			if g := r.PathValue(k); g != w {
+				t.Errorf("got %q, want %q", g, w)
+			}
+		}
+	})
+	u, err := url.Parse("/a/b/c/d/e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.ServeHTTP(httptest.NewRecorder(), &Request{Method: "GET", URL: u})
+}
+
+func TestServeMuxRegisterConflictPanics(t *testing.T) {
+	setParallel(t)
+	for _, pats := range [][2]string{
+		{"/a", "/a"},
+		{"GET /{x}", "GET /{y}"},
+		{"/a/{x}", "/{x}/b"},
+		{"GET /", "/index.html"},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering %q then %q did not panic", pats[0], pats[1])
+				}
+			}()
+			mux := NewServeMux()
+			mux.Handle(pats[0], serve(200))
+			mux.Handle(pats[1], serve(200))
+		}()
+	}
+
+	// More specific patterns and patterns that differ in host do not conflict.
+	mux := NewServeMux()
+	for _, p := range []string{"/", "/{x}", "/a", "GET /a", "example.com/a", "/a/{$}", "/a/{rest...}"} {
+		mux.Handle(p, serve(200))
+	}
+}
+
 var serveMuxTests2 = []struct {
 	method  string
 	host    string
@@ -8,6 +8,8 @@ package http
 
 import (
 	"fmt"
+	"net/url"
+	"regexp"
 	"testing"
 	"time"
 )
@@ -90,9 +92,207 @@ func BenchmarkServerMatch(b *testing.B) {
 		"/products/", "/products/3/image.jpg"}
 	b.StartTimer()
 	for i := 0; i < b.N; i++ {
-		if h, p := mux.match(paths[i%len(paths)]); h != nil && p == "" {
+		if n, _ := mux.tree.match("", "GET", paths[i%len(paths)]); n != nil && n.pattern == nil {
 			b.Error("impossible")
 		}
 	}
 	b.StopTimer()
 }
+
+func TestFindHandler(t *testing.T) {
+	mux := NewServeMux()
+	for _, ph := range []struct {
+		pat string
+		h   Handler
+	}{
+		{"/", &handler{1}},
+		{"/foo/", &handler{2}},
+		{"/foo", &handler{3}},
+		{"/bar/", &handler{4}},
+		{"//foo", &handler{5}},
+	} {
+		mux.Handle(ph.pat, ph.h)
+	}
+
+	for _, test := range []struct {
+		method      string
+		path        string
+		wantHandler string
+	}{
+		{"GET", "/", "&http.handler{i:1}"},
+		{"GET", "//", `&http.redirectHandler{url:"/", code:301}`},
+		{"GET", "/foo/../bar/./..//baz", `&http.redirectHandler{url:"/baz", code:301}`},
+		{"GET", "/foo", "&http.handler{i:3}"},
+		{"GET", "/foo/x", "&http.handler{i:2}"},
+		{"GET", "/bar/x", "&http.handler{i:4}"},
+		{"GET", "/bar", `&http.redirectHandler{url:"/bar/", code:301}`},
+		{"CONNECT", "/", "&http.handler{i:1}"},
+		{"CONNECT", "//", "&http.handler{i:1}"},
+		{"CONNECT", "//foo", "&http.handler{i:5}"},
+		{"CONNECT", "/foo/../bar/./..//baz", "&http.handler{i:2}"},
+		{"CONNECT", "/foo", "&http.handler{i:3}"},
+		{"CONNECT", "/foo/x", "&http.handler{i:2}"},
+		{"CONNECT", "/bar/x", "&http.handler{i:4}"},
+		{"CONNECT", "/bar", `&http.redirectHandler{url:"/bar/", code:301}`},
+	} {
+		var r Request
+		r.Method = test.method
+		r.Host = "example.com"
+		r.URL = &url.URL{Path: test.path}
+		gotH, _, _, _ := mux.findHandler(&r)
+		got := fmt.Sprintf("%#v", gotH)
+		if got != test.wantHandler {
+			t.Errorf("%s %q: got %q, want %q", test.method, test.path, got, test.wantHandler)
+		}
+	}
+}
+
+func TestEmptyServeMux(t *testing.T) {
+	// Verify that a ServeMux with nothing registered
+	// doesn't panic.
+	mux := NewServeMux()
+	var r Request
+	r.Method = "GET"
+	r.Host = "example.com"
+	r.URL = &url.URL{Path: "/"}
+	_, p := mux.Handler(&r)
+	if p != "" {
+		t.Errorf(`got %q, want ""`, p)
+	}
+}
+
+func TestRegisterErr(t *testing.T) {
+	mux := NewServeMux()
+	h := &handler{}
+	mux.Handle("/a", h)
+
+	for _, test := range []struct {
+		pattern    string
+		handler    Handler
+		wantRegexp string
+	}{
+		{"", h, "invalid pattern"},
+		{"/", nil, "nil handler"},
+		{"/", HandlerFunc(nil), "nil handler"},
+		{"/{x", h, `parsing "/\{x": at offset 1: bad wildcard segment`},
+		{"/a", h, `conflicts with pattern.* \(registered at .*/server_test.go:\d+`},
+		{"GET /{$}/", h, `\{\$\} not at end`},
+	} {
+		t.Run(fmt.Sprintf("%s:%#v", test.pattern, test.handler), func(t *testing.T) {
+			err := mux.registerErr(test.pattern, test.handler)
+			if err == nil {
+				t.Fatal("got nil error")
+			}
+			re := regexp.MustCompile(test.wantRegexp)
+			if g := err.Error(); !re.MatchString(g) {
+				t.Errorf("\ngot %q\nwant string matching %q", g, test.wantRegexp)
+			}
+		})
+	}
+}
+
+func TestExactMatch(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"", "/a", false},
+		{"/", "/a", false},
+		{"/a", "/a", true},
+		{"/a/{x...}", "/a/b", false},
+		{"GET /a", "/a", true},
+		{"POST /a", "/a", true},
+		{"GET /b", "/a", true},
+		{"/a/{$}", "/a/", true},
+		{"/a/", "/a/", true},
+		{"/a/b/", "/a/", false},
+	} {
+		var n *routingNode
+		if test.pattern != "" {
+			pat := mustParsePattern(t, test.pattern)
+			n = &routingNode{pattern: pat}
+		}
+		got := exactMatch(n, test.path)
+		if got != test.want {
+			t.Errorf("%q, %s: got %t, want %t", test.pattern, test.path, got, test.want)
+		}
+	}
+}
+
+func TestEscapedPathsAndPatterns(t *testing.T) {
+	matches := []struct {
+		pattern string
+		paths   []string
+	}{
+		{
+			"/a", // this pattern matches a path that unescapes to "/a"
+			[]string{"/a", "/%61"},
+		},
+		{
+			"/%62", // patterns are unescaped by segment; matches paths that unescape to "/b"
+			[]string{"/b", "/%62"},
+		},
+		{
+			"/%7B/%7D", // the only way to write a pattern that matches '{' or '}'
+			[]string{"/{/}", "/%7b/}", "/{/%7d", "/%7B/%7D"},
+		},
+		{
+			"/%x", // patterns that do not unescape are left unchanged
+			[]string{"/%25x"},
+		},
+	}
+
+	mux := NewServeMux()
+	for _, m := range matches {
+		pat := m.pattern
+		mux.HandleFunc(pat, func(w ResponseWriter, r *Request) {
+			w.Write([]byte(pat))
+		})
+	}
+
+	for _, m := range matches {
+		for _, p := range m.paths {
+			u, err := url.ParseRequestURI(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := &Request{
+				Method: "GET",
+				URL:    u,
+			}
+			_, gotPattern, _, _ := mux.findHandler(req)
+			if g, w := gotPattern, m.pattern; g != w {
+				t.Errorf("%s: pattern: got %q, want %q", p, g, w)
+			}
+		}
+	}
+}
+
+type handler struct{ i int }
+
+func (handler) ServeHTTP(ResponseWriter, *Request) {}
+
+func BenchmarkServerRegisterAndMatch(b *testing.B) {
+	fn := func(w ResponseWriter, r *Request) {}
+	paths := []string{
+		"GET /",
+		"GET /users/{id}",
+		"POST /users",
+		"GET /users/{id}/posts/{post}",
+		"DELETE /users/{id}",
+		"GET /static/{path...}",
+		"example.com/",
+		"example.com/admin/{$}",
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		mux := NewServeMux()
+		for _, p := range paths {
+			mux.HandleFunc(p, fn)
+		}
+		if n, _ := mux.tree.match("", "GET", "/users/7/posts/8"); n == nil {
+			b.Fatal("no match")
+		}
+	}
+}
@@ -281,6 +281,20 @@ type Transport struct {
 	// To use a custom dialer or TLS config and still attempt HTTP/2
 	// upgrades, set this to true.
 	ForceAttemptHTTP2 bool
+
+	// EnableHTTP3 enables HTTP/3 for HTTPS requests. When it is true,
+	// the Transport remembers HTTP/3 alternative services advertised
+	// in Alt-Svc response header fields, and sends later requests to
+	// the same origin over HTTP/3 (QUIC) while the advertisement is
+	// valid. If the alternative service cannot be reached, the
+	// Transport falls back to TCP. Requests sent through a proxy do
+	// not use HTTP/3.
+	EnableHTTP3 bool
+
+	h3Mu     sync.Mutex
+	h3AltSvc map[string]http3AltSvc      // by origin host:port
+	h3Conns  map[string]*http3ClientConn // by alternative address and server name
+	h3Dials  map[string]*http3DialCall
 }
 
 // A cancelKey is the key of the reqCanceler map.
@@ -326,6 +340,7 @@ func (t *Transport) Clone() *Transport {
 		GetProxyConnectHeader:  t.GetProxyConnectHeader,
 		MaxResponseHeaderBytes: t.MaxResponseHeaderBytes,
 		ForceAttemptHTTP2:      t.ForceAttemptHTTP2,
+		EnableHTTP3:            t.EnableHTTP3,
 		WriteBufferSize:        t.WriteBufferSize,
 		ReadBufferSize:         t.ReadBufferSize,
 	}
@@ -557,6 +572,14 @@ func (t *Transport) roundTrip(req *Request) (*Response, error) {
 		req.closeBody()
 		return nil, errors.New("http: no Host in request URL")
 	}
+	if t.EnableHTTP3 && scheme == "https" {
+		if resp, err := t.http3RoundTrip(req); err != errHTTP3Unavailable {
+			if err == nil {
+				resp.Request = origReq
+			}
+			return resp, err
+		}
+	}
 
 	for {
 		select {
@@ -595,6 +618,9 @@ func (t *Transport) roundTrip(req *Request) (*Response, error) {
 		}
 		if err == nil {
 			resp.Request = origReq
+			if t.EnableHTTP3 {
+				t.http3RememberAltSvc(req, resp)
+			}
 			return resp, nil
 		}
 
@@ -776,6 +802,7 @@ func (t *Transport) CloseIdleConnections() {
 	if t2 := t.h2transport; t2 != nil {
 		t2.CloseIdleConnections()
 	}
+	t.closeIdleHTTP3Conns()
 }
 
 // CancelRequest cancels an in-flight request by closing its connection.
@@ -5902,6 +5902,7 @@ func TestTransportClone(t *testing.T) {
 		},
 		ReadBufferSize:  1,
 		WriteBufferSize: 1,
+		EnableHTTP3:     true,
 	}
 	tr2 := tr.Clone()
 	rv := reflect.ValueOf(tr2).Elem()
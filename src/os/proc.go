@@ -60,19 +60,19 @@ func Getgroups() ([]int, error) {
 //
 // For portability, the status code should be in the range [0, 125].
 func Exit(code int) {
-	if code == 0 {
-		if testlog.PanicOnExit0() {
-			// We were told to panic on calls to os.Exit(0).
-			// This is used to fail tests that make an early
-			// unexpected call to os.Exit(0).
-			panic("unexpected call to os.Exit(0) during test")
-		}
-
-		// Give race detector a chance to fail the program.
-		// Racy programs do not have the right to finish successfully.
-		runtime_beforeExit()
+	if code == 0 && testlog.PanicOnExit0() {
+		// We were told to panic on calls to os.Exit(0).
+		// This is used to fail tests that make an early
+		// unexpected call to os.Exit(0).
+		panic("unexpected call to os.Exit(0) during test")
 	}
+
+	// Inform the runtime that the program is exiting. This runs the
+	// runtime's exit hooks, such as the one writing coverage data,
+	// and gives the race detector a chance to fail the program:
+	// racy programs do not have the right to finish successfully.
+	runtime_beforeExit(code)
 	syscall.Exit(code)
 }
 
-func runtime_beforeExit() // implemented in runtime
+func runtime_beforeExit(exitCode int) // implemented in runtime
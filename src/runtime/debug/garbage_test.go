@@ -6,6 +6,7 @@ package debug_test
 
 import (
 	"internal/testenv"
+	"math"
 	"os"
 	"runtime"
 	. "runtime/debug"
@@ -236,3 +237,23 @@ func TestSetMaxThreadsOvf(t *testing.T) {
 	nt := SetMaxThreads(1 << (30 + ^uint(0)>>63))
 	SetMaxThreads(nt) // restore previous value
 }
+
+func TestSetMemoryLimit(t *testing.T) {
+	// The initial setting is math.MaxInt64 unless GOMEMLIMIT is set.
+	initial := SetMemoryLimit(-1)
+	if os.Getenv("GOMEMLIMIT") == "" && initial != math.MaxInt64 {
+		t.Errorf("SetMemoryLimit(-1) = %d, want %d", initial, int64(math.MaxInt64))
+	}
+	defer SetMemoryLimit(initial)
+
+	// Test that the limit is being set and returned correctly.
+	if old := SetMemoryLimit(256 << 20); old != initial {
+		t.Errorf("SetMemoryLimit(256 MiB) = %d, want %d", old, initial)
+	}
+	if got := SetMemoryLimit(-1); got != 256<<20 {
+		t.Errorf("SetMemoryLimit(-1) = %d, want %d", got, 256<<20)
+	}
+	if old := SetMemoryLimit(math.MaxInt64); old != 256<<20 {
+		t.Errorf("SetMemoryLimit(math.MaxInt64) = %d, want %d", old, 256<<20)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+import _ "unsafe" // for go:linkname
+
+// An exitHook is a function to be run when the program exits,
+// either by main.main returning or by a call to os.Exit.
+type exitHook struct {
+	f                func()
+	runOnNonZeroExit bool // run the hook even if the exit status is non-zero
+}
+
+var exitHooks struct {
+	hooks   []exitHook
+	running bool
+}
+
+// addExitHook registers f to be run at program exit. Hooks run in
+// the reverse order of registration. addExitHook must be called from
+// ordinary Go code, and f runs as ordinary Go code: it may allocate
+// and block, but must not call os.Exit.
+//
+//go:linkname addExitHook internal/coverage/rtcov.runtime_addExitHook
+func addExitHook(f func(), runOnNonZeroExit bool) {
+	exitHooks.hooks = append(exitHooks.hooks, exitHook{f: f, runOnNonZeroExit: runOnNonZeroExit})
+}
+
+// runExitHooks runs the registered exit hooks. It is called with the
+// program's exit status, and runs the hooks at most once.
+func runExitHooks(exitCode int) {
+	if exitHooks.running {
+		throw("internal error: exit hook invoked exit")
+	}
+	exitHooks.running = true
+	for i := len(exitHooks.hooks) - 1; i >= 0; i-- {
+		h := exitHooks.hooks[i]
+		if exitCode != 0 && !h.runOnNonZeroExit {
+			continue
+		}
+		h.f()
+	}
+	exitHooks.hooks = nil
+	exitHooks.running = false
+}
@@ -33,6 +33,7 @@ var Fastlog2 = fastlog2
 
 var Atoi = atoi
 var Atoi32 = atoi32
+var ParseByteCount = parseByteCount
 
 var Nanotime = nanotime
 var NetpollBreak = netpollBreak
@@ -409,6 +410,21 @@ func ReadMemStatsSlow() (base, slow MemStats) {
 	return
 }
 
+// ReadMappedReady returns gcController.mappedReady along with the Sys
+// and HeapReleased fields of MemStats, all read with the world stopped.
+func ReadMappedReady() (mappedReady, sys, released uint64) {
+	stopTheWorld("ReadMappedReady")
+
+	var m MemStats
+	systemstack(func() {
+		readmemstats_m(&m)
+		mappedReady = gcController.mappedReady.Load()
+	})
+
+	startTheWorld()
+	return mappedReady, m.Sys, m.HeapReleased
+}
+
 // BlockOnSystemStack switches to the system stack, prints "x\n" to
 // stderr, and blocks in a stack containing
 // "runtime.blockOnSystemStackInternal".
@@ -1022,15 +1038,19 @@ func FreePageAlloc(pp *PageAlloc) {
 	// Free all the mapped space for the summary levels.
 	if pageAlloc64Bit != 0 {
 		for l := 0; l < summaryLevels; l++ {
-			sysFree(unsafe.Pointer(&p.summary[l][0]), uintptr(cap(p.summary[l]))*pallocSumBytes, nil)
+			sysFreeOS(unsafe.Pointer(&p.summary[l][0]), uintptr(cap(p.summary[l]))*pallocSumBytes)
 		}
 	} else {
 		resSize := uintptr(0)
 		for _, s := range p.summary {
 			resSize += uintptr(cap(s)) * pallocSumBytes
 		}
-		sysFree(unsafe.Pointer(&p.summary[0][0]), alignUp(resSize, physPageSize), nil)
+		sysFreeOS(unsafe.Pointer(&p.summary[0][0]), alignUp(resSize, physPageSize))
 	}
+	// Subtract back out whatever we mapped for the summaries. Only that
+	// much of the reservation was accounted for as Ready by sysUsed.
+	gcController.mappedReady.Add(-int64(p.summaryMappedReady))
+	p.sysStat.add(-int64(p.summaryMappedReady))
 
 	// Free the mapped space for chunks.
 	for i := range p.chunks {
@@ -1257,7 +1277,7 @@ func NewGCController(gcPercent int) *GCController {
 	// space.
 	g := escape(new(GCController)).(*GCController)
 	g.gcControllerState.test = true // Mark it as a test copy.
-	g.init(int32(gcPercent))
+	g.init(int32(gcPercent), maxInt64)
 	return g
 }
 
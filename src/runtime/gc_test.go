@@ -32,6 +32,31 @@ func TestGcSys(t *testing.T) {
 	}
 }
 
+func TestGCMemoryLimit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	got := runTestProg(t, "testprog", "GCMemoryLimit", "GOGC=off", "GOMEMLIMIT=64MiB")
+	want := "OK\n"
+	if got != want {
+		t.Fatalf("expected %q, but got %q", want, got)
+	}
+}
+
+func TestMappedReadyAccounting(t *testing.T) {
+	// Allocate and free some memory to exercise the accounting.
+	for i := 0; i < 16; i++ {
+		escape(make([]byte, 1<<20))
+	}
+	runtime.GC()
+	debug.FreeOSMemory()
+
+	mappedReady, sys, released := runtime.ReadMappedReady()
+	if mappedReady != sys-released {
+		t.Errorf("mappedReady = %d, but Sys-HeapReleased = %d", mappedReady, sys-released)
+	}
+}
+
 func TestGcDeepNesting(t *testing.T) {
 	type T [2][2][2][2][2][2][2][2][2][2]*int
 	a := new(T)
@@ -1412,5 +1412,180 @@ func reflectlite_maplen(h *hmap) int {
 	return h.count
 }
 
+//go:linkname maps_clone maps.clone
+func maps_clone(m any) any {
+	e := efaceOf(&m)
+	e.data = unsafe.Pointer(mapclone2((*maptype)(unsafe.Pointer(e._type)), (*hmap)(e.data)))
+	return m
+}
+
+// moveToBmap moves the entries of bucket src into the bucket chain
+// starting at dst, beginning at slot pos. It returns the bucket and slot
+// at which the next entry should be written; dst is extended with a new
+// overflow bucket when it fills up.
+func moveToBmap(t *maptype, h *hmap, dst *bmap, pos int, src *bmap) (*bmap, int) {
+	for i := 0; i < bucketCnt; i++ {
+		if isEmpty(src.tophash[i]) {
+			continue
+		}
+
+		for ; pos < bucketCnt; pos++ {
+			if isEmpty(dst.tophash[pos]) {
+				break
+			}
+		}
+
+		if pos == bucketCnt {
+			dst = h.newoverflow(t, dst)
+			pos = 0
+		}
+
+		srcK := add(unsafe.Pointer(src), dataOffset+uintptr(i)*uintptr(t.keysize))
+		srcEle := add(unsafe.Pointer(src), dataOffset+bucketCnt*uintptr(t.keysize)+uintptr(i)*uintptr(t.elemsize))
+		dstK := add(unsafe.Pointer(dst), dataOffset+uintptr(pos)*uintptr(t.keysize))
+		dstEle := add(unsafe.Pointer(dst), dataOffset+bucketCnt*uintptr(t.keysize)+uintptr(pos)*uintptr(t.elemsize))
+
+		dst.tophash[pos] = src.tophash[i]
+		if t.indirectkey() {
+			srcK = *(*unsafe.Pointer)(srcK)
+			if t.needkeyupdate() {
+				kStore := newobject(t.key)
+				typedmemmove(t.key, kStore, srcK)
+				srcK = kStore
+			}
+			// Note: if needkeyupdate is false, then the memory
+			// used to store the key is immutable, so we can share
+			// it between the original map and its clone.
+			*(*unsafe.Pointer)(dstK) = srcK
+		} else {
+			typedmemmove(t.key, dstK, srcK)
+		}
+		if t.indirectelem() {
+			srcEle = *(*unsafe.Pointer)(srcEle)
+			eStore := newobject(t.elem)
+			typedmemmove(t.elem, eStore, srcEle)
+			*(*unsafe.Pointer)(dstEle) = eStore
+		} else {
+			typedmemmove(t.elem, dstEle, srcEle)
+		}
+		pos++
+		h.count++
+	}
+	return dst, pos
+}
+
+// mapclone2 returns a copy of src. Rather than re-inserting every entry,
+// it reuses src's hash seed and copies entries bucket by bucket, so that
+// no key needs to be rehashed except those still waiting in src's old
+// buckets mid-grow.
+func mapclone2(t *maptype, src *hmap) *hmap {
+	if raceenabled && src != nil {
+		callerpc := getcallerpc()
+		racereadpc(unsafe.Pointer(src), callerpc, abi.FuncPCABIInternal(mapclone2))
+	}
+
+	dst := makemap(t, src.count, nil)
+	dst.hash0 = src.hash0
+	dst.nevacuate = 0
+	// flags do not need to be copied here, just like a new map has no flags.
+
+	if src.count == 0 {
+		return dst
+	}
+
+	if src.flags&hashWriting != 0 {
+		throw("concurrent map clone and map write")
+	}
+
+	if src.B == 0 && src.oldbuckets == nil && (*bmap)(src.buckets).overflow(t) == nil &&
+		!(t.indirectkey() && t.needkeyupdate()) && !t.indirectelem() {
+		// Quick copy for small maps.
+		dst.buckets = newobject(t.bucket)
+		dst.count = src.count
+		typedmemmove(t.bucket, dst.buckets, src.buckets)
+		return dst
+	}
+
+	if dst.B == 0 {
+		dst.buckets = newobject(t.bucket)
+	}
+	// The hint passed to makemap above guarantees that dst.B <= src.B,
+	// so each source bucket maps to exactly one destination bucket.
+	dstArraySize := int(bucketShift(dst.B))
+	srcArraySize := int(bucketShift(src.B))
+	for i := 0; i < dstArraySize; i++ {
+		dstBmap := (*bmap)(add(dst.buckets, uintptr(i*int(t.bucketsize))))
+		pos := 0
+		for j := 0; j < srcArraySize; j += dstArraySize {
+			srcBmap := (*bmap)(add(src.buckets, uintptr((i+j)*int(t.bucketsize))))
+			for srcBmap != nil {
+				dstBmap, pos = moveToBmap(t, dst, dstBmap, pos, srcBmap)
+				srcBmap = srcBmap.overflow(t)
+			}
+		}
+	}
+
+	if src.oldbuckets == nil {
+		return dst
+	}
+
+	// src is in the middle of growing. Copy the entries of the old
+	// buckets that have not yet been evacuated.
+	oldB := src.B
+	srcOldbuckets := src.oldbuckets
+	if !src.sameSizeGrow() {
+		oldB--
+	}
+	oldSrcArraySize := int(bucketShift(oldB))
+
+	for i := 0; i < oldSrcArraySize; i++ {
+		srcBmap := (*bmap)(add(srcOldbuckets, uintptr(i*int(t.bucketsize))))
+		if evacuated(srcBmap) {
+			continue
+		}
+
+		if oldB >= dst.B { // main bucket bits in dst is less than oldB bits in src
+			dstBmap := (*bmap)(add(dst.buckets, (uintptr(i)&bucketMask(dst.B))*uintptr(t.bucketsize)))
+			for dstBmap.overflow(t) != nil {
+				dstBmap = dstBmap.overflow(t)
+			}
+			pos := 0
+			for srcBmap != nil {
+				dstBmap, pos = moveToBmap(t, dst, dstBmap, pos, srcBmap)
+				srcBmap = srcBmap.overflow(t)
+			}
+			continue
+		}
+
+		// oldB < dst.B, so a single source bucket may go to multiple
+		// destination buckets. Process entries one at a time.
+		for srcBmap != nil {
+			for i := uintptr(0); i < bucketCnt; i++ {
+				if isEmpty(srcBmap.tophash[i]) {
+					continue
+				}
+
+				if src.flags&hashWriting != 0 {
+					throw("concurrent map clone and map write")
+				}
+
+				srcK := add(unsafe.Pointer(srcBmap), dataOffset+i*uintptr(t.keysize))
+				if t.indirectkey() {
+					srcK = *((*unsafe.Pointer)(srcK))
+				}
+
+				srcEle := add(unsafe.Pointer(srcBmap), dataOffset+bucketCnt*uintptr(t.keysize)+i*uintptr(t.elemsize))
+				if t.indirectelem() {
+					srcEle = *((*unsafe.Pointer)(srcEle))
+				}
+				dstEle := mapassign(t, dst, srcK)
+				typedmemmove(t.elem, dstEle, srcEle)
+			}
+			srcBmap = srcBmap.overflow(t)
+		}
+	}
+	return dst
+}
+
 const maxZero = 1024 // must match value in reflect/value.go:maxZero cmd/compile/internal/gc/walk.go:zeroValSize
 var zeroVal [maxZero]byte
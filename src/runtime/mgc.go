@@ -158,7 +158,8 @@ func gcinit() {
 
 	// Initialize GC pacer state.
 	// Use the environment variable GOGC for the initial gcPercent value.
-	gcController.init(readGOGC())
+	// Use the environment variable GOMEMLIMIT for the initial memoryLimit value.
+	gcController.init(readGOGC(), readGOMEMLIMIT())
 
 	work.startSema = 1
 	work.markDoneSema = 1
@@ -363,6 +364,15 @@ var work struct {
 	// explicit user call.
 	userForced bool
 
+	// goroutineLeak is the state of goroutine leak detection,
+	// which a GC cycle performs on request. See mgcleak.go.
+	goroutineLeak struct {
+		pending uint32 // a cycle that detects leaks was requested; accessed atomically
+		enabled bool   // the current cycle detects leaks
+		done    bool   // the current cycle is done detecting leaks
+		cycle   uint32 // last cycle that detected leaks; accessed atomically
+	}
+
 	// totaltime is the CPU nanoseconds spent in GC since the
 	// program started if debug.gctrace > 0.
 	totaltime int64
@@ -621,6 +631,13 @@ func gcStart(trigger gcTrigger) {
 	} else if debug.gcstoptheworld == 2 {
 		mode = gcForceBlockMode
 	}
+	// Leak detection relies on user goroutines not running
+	// during the mark phase.
+	work.goroutineLeak.enabled = atomic.Cas(&work.goroutineLeak.pending, 1, 0)
+	work.goroutineLeak.done = false
+	if work.goroutineLeak.enabled {
+		mode = gcForceBlockMode
+	}
 
 	// Ok, we're doing it! Stop everybody else
 	semacquire(&gcsema)
@@ -670,6 +687,10 @@ func gcStart(trigger gcTrigger) {
 
 	work.cycles++
 
+	if work.goroutineLeak.enabled {
+		systemstack(gcPrepareLeakDetection)
+	}
+
 	// Assists and workers can start the moment we start
 	// the world.
 	gcController.startCycle(now, int(gomaxprocs))
@@ -866,6 +887,11 @@ top:
 				break
 			}
 		}
+		// Goroutine leak detection may find more goroutines
+		// to scan, which resumes concurrent mark.
+		if !restart && work.goroutineLeak.enabled && !work.goroutineLeak.done {
+			restart = gcDetectLeaks()
+		}
 	})
 	if restart {
 		getg().m.preemptoff = ""
@@ -926,6 +952,7 @@ func gcMarkTermination(nextTriggerRatio float64) {
 	// the root set down a bit (g0 stacks are not scanned, and
 	// we don't need to scan gc's internal state).  We also
 	// need to switch to g0 so we can shrink the stack.
+	var stwSwept bool
 	systemstack(func() {
 		gcMark(startTime)
 		// Must return immediately.
@@ -955,7 +982,7 @@ func gcMarkTermination(nextTriggerRatio float64) {
 
 		// marking is complete so we can turn the write barrier off
 		setGCPhase(_GCoff)
-		gcSweep(work.mode)
+		stwSwept = gcSweep(work.mode)
 	})
 
 	_g_.m.traceback = 0
@@ -978,7 +1005,7 @@ func gcMarkTermination(nextTriggerRatio float64) {
 	// Update GC trigger and pacing for the next cycle.
 	gcController.commit(nextTriggerRatio)
 	gcPaceSweeper(gcController.trigger)
-	gcPaceScavenger(gcController.heapGoal, gcController.lastHeapGoal)
+	gcPaceScavenger(gcController.memoryLimit.Load(), gcController.heapGoal, gcController.lastHeapGoal)
 
 	// Update timing memstats
 	now := nanotime()
@@ -1029,9 +1056,15 @@ func gcMarkTermination(nextTriggerRatio float64) {
 	// Those aren't tracked in any sweep lists, so we need to
 	// count them against sweep completion until we ensure all
 	// those spans have been forced out.
+	//
+	// If gcSweep fully swept the heap, as it does in
+	// gcForceBlockMode, sweeping is already done and the
+	// sweepLocker is invalid.
 	sl := sweep.active.begin()
-	if !sl.valid {
+	if !stwSwept && !sl.valid {
 		throw("failed to set sweep barrier")
+	} else if stwSwept && sl.valid {
+		throw("non-concurrent sweep failed to drain all sweep queues")
 	}
 
 	systemstack(func() { startTheWorldWithSema(true) })
@@ -1059,7 +1092,9 @@ func gcMarkTermination(nextTriggerRatio float64) {
 	})
 	// Now that we've swept stale spans in mcaches, they don't
 	// count against unswept spans.
-	sweep.active.end(sl)
+	if sl.valid {
+		sweep.active.end(sl)
+	}
 
 	// Print gctrace before dropping worldsema. As soon as we drop
 	// worldsema another cycle could start and smash the stats
@@ -1449,8 +1484,11 @@ func gcMark(startTime int64) {
 //
 // The world must be stopped.
 //
+// gcSweep reports whether it swept the heap in full, rather than
+// leaving it to the background sweeper.
+//
 //go:systemstack
-func gcSweep(mode gcMode) {
+func gcSweep(mode gcMode) bool {
 	assertWorldStopped()
 
 	if gcphase != _GCoff {
@@ -1487,7 +1525,7 @@ func gcSweep(mode gcMode) {
 		// available immediately.
 		mProf_NextCycle()
 		mProf_Flush()
-		return
+		return true
 	}
 
 	// Background sweep.
@@ -1497,6 +1535,7 @@ func gcSweep(mode gcMode) {
 		ready(sweep.g, 0, true)
 	}
 	unlock(&sweep.lock)
+	return false
 }
 
 // gcResetMarkState resets global state prior to marking (concurrent
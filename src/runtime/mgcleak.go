@@ -0,0 +1,193 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Goroutine leak detection.
+//
+// A goroutine blocked on a channel, a semaphore (as used by sync.Mutex,
+// sync.WaitGroup and friends) or a sync.Cond can only be woken up by
+// another goroutine that operates on the same object. If the object is
+// not reachable from any goroutine that may still run, the goroutine
+// is blocked forever: it is leaked.
+//
+// A GC cycle can find such goroutines by not treating the stacks of
+// the blocked goroutines as roots. At the start of the cycle, with the
+// world stopped, the goroutines blocked on these objects become leak
+// candidates. Their stacks are left out of the root set, and their g
+// and waiting sudog objects are marked without being scanned, so that
+// the runtime's own references to the objects they wait on do not keep
+// these alive. Whenever marking runs out of work, a candidate whose
+// object was marked may be woken up, so its stack is scanned and
+// marking resumes. Once no more candidates become reachable, the rest
+// of them are leaked. They are scanned too, so that the memory they
+// keep alive is retained as usual, and the cycle completes normally.
+//
+// User goroutines must not run while candidates' stacks are unscanned,
+// so a cycle that detects leaks runs in gcForceBlockMode.
+
+package runtime
+
+import (
+	"runtime/internal/atomic"
+	"unsafe"
+)
+
+// goroutineLeakGC runs a GC cycle that detects leaked goroutines and
+// waits for its mark phase to complete. Afterwards, g.leaked is set
+// for the goroutines found leaked.
+func goroutineLeakGC() {
+	for {
+		// As in GC, wait for the current cycle and start a new one,
+		// which detects leaks unless some other goroutine beat us
+		// to starting it. In that case, try again.
+		n := atomic.Load(&work.cycles)
+		gcWaitOnMark(n)
+		atomic.Store(&work.goroutineLeak.pending, 1)
+		gcStart(gcTrigger{kind: gcTriggerCycle, n: n + 1})
+		gcWaitOnMark(n + 1)
+		if int32(atomic.Load(&work.goroutineLeak.cycle)-(n+1)) >= 0 {
+			return
+		}
+	}
+}
+
+// gcPrepareLeakDetection chooses the leak candidates of a GC cycle.
+//
+// The world must be stopped, and sweeping must be done.
+func gcPrepareLeakDetection() {
+	forEachGRace(func(gp *g) {
+		gp.leaked = false
+		gp.leakCandidate = false
+		if readgstatus(gp) != _Gwaiting || isSystemGoroutine(gp, false) {
+			return
+		}
+		switch gp.waitreason {
+		case waitReasonChanReceive, waitReasonChanSend, waitReasonSelect,
+			waitReasonChanReceiveNilChan, waitReasonChanSendNilChan, waitReasonSelectNoCases:
+		case waitReasonSemacquire, waitReasonSyncCondWait:
+			if gp.waitingSync == nil {
+				return
+			}
+		default:
+			return
+		}
+		// Blocked goroutines are reachable from allgs, and their
+		// sudogs reference the channels they wait on. The semaphore
+		// table and notify lists reference the address waited on
+		// through the sudog in waitingSync.
+		s := gp.waitingSync
+		if !leakCanMarkNoScan(unsafe.Pointer(gp)) || s != nil && !leakCanMarkNoScan(unsafe.Pointer(s)) {
+			return
+		}
+		leakMarkNoScan(unsafe.Pointer(gp))
+		if s != nil {
+			leakMarkNoScan(unsafe.Pointer(s))
+		}
+		gp.leakCandidate = true
+	})
+}
+
+// gcDetectLeaks is called when marking runs out of work in a GC cycle
+// that detects leaks. It scans the candidates that became reachable,
+// or, if there are none, declares the rest of the candidates leaked
+// and scans them. It reports whether this produced more mark work.
+//
+// The world must be stopped. gcDetectLeaks must run on the system stack.
+//
+//go:systemstack
+func gcDetectLeaks() bool {
+	gcw := &getg().m.p.ptr().gcw
+	found := false
+	forEachGRace(func(gp *g) {
+		if gp.leakCandidate && leakWaitReachable(gp) {
+			scanLeakCandidate(gp, gcw)
+			found = true
+		}
+	})
+	if !found {
+		forEachGRace(func(gp *g) {
+			if gp.leakCandidate {
+				gp.leaked = true
+				scanLeakCandidate(gp, gcw)
+			}
+		})
+		work.goroutineLeak.done = true
+		atomic.Store(&work.goroutineLeak.cycle, work.cycles)
+	}
+	return !gcw.empty()
+}
+
+// leakWaitReachable reports whether the leak candidate gp may still
+// be woken up, because an object it is waiting on is marked.
+func leakWaitReachable(gp *g) bool {
+	if readgstatus(gp) != _Gwaiting {
+		// Something woke it up.
+		return true
+	}
+	switch gp.waitreason {
+	case waitReasonChanReceive, waitReasonChanSend, waitReasonSelect:
+		for sg := gp.waiting; sg != nil; sg = sg.waitlink {
+			if sg.c != nil && leakObjectMarked(unsafe.Pointer(sg.c)) {
+				return true
+			}
+		}
+		return false
+	case waitReasonChanReceiveNilChan, waitReasonChanSendNilChan, waitReasonSelectNoCases:
+		return false
+	case waitReasonSemacquire, waitReasonSyncCondWait:
+		s := gp.waitingSync
+		return s == nil || s.elem == nil || leakObjectMarked(s.elem)
+	}
+	return true
+}
+
+// scanLeakCandidate scans the stack of gp, along with the objects
+// marked by gcPrepareLeakDetection, and drops it from the candidates.
+func scanLeakCandidate(gp *g, gcw *gcWork) {
+	gp.leakCandidate = false
+	// The world is stopped, so gp cannot be running.
+	if s := readgstatus(gp); s != _Gdead {
+		if gp.gcscandone {
+			throw("g already scanned")
+		}
+		if !castogscanstatus(gp, s, s|_Gscan) {
+			throw("leak candidate changed status")
+		}
+		gcController.stackScanWork.Add(scanstack(gp, gcw))
+		casfrom_Gscanstatus(gp, s|_Gscan, s)
+	}
+	gp.gcscandone = true
+	if s := gp.waitingSync; s != nil {
+		scanobject(uintptr(unsafe.Pointer(s)), gcw)
+	}
+	scanobject(uintptr(unsafe.Pointer(gp)), gcw)
+}
+
+// leakObjectMarked reports whether the heap object containing p is
+// marked. Addresses outside of the heap are always reachable.
+func leakObjectMarked(p unsafe.Pointer) bool {
+	base, span, objIndex := findObject(uintptr(p), 0, 0)
+	if base == 0 {
+		return true
+	}
+	return span.markBitsForIndex(objIndex).isMarked()
+}
+
+// leakCanMarkNoScan reports whether p is the start of an unmarked heap
+// object that leakMarkNoScan can mark.
+func leakCanMarkNoScan(p unsafe.Pointer) bool {
+	base, span, objIndex := findObject(uintptr(p), 0, 0)
+	return base == uintptr(p) && !span.spanclass.noscan() && !span.markBitsForIndex(objIndex).isMarked()
+}
+
+// leakMarkNoScan marks the heap object at p without queueing it for
+// scanning. The caller must scan the object before the end of the
+// mark phase.
+func leakMarkNoScan(p unsafe.Pointer) {
+	_, span, objIndex := findObject(uintptr(p), 0, 0)
+	span.markBitsForIndex(objIndex).setMarked()
+	arena, pageIdx, pageMask := pageIndexOf(span.base())
+	if arena.pageMarks[pageIdx]&pageMask == 0 {
+		atomic.Or8(&arena.pageMarks[pageIdx], pageMask)
+	}
+}
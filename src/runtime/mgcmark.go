@@ -209,6 +209,12 @@ func markroot(gcw *gcWork, i uint32, flushBgCredit bool) int64 {
 			gp.waitsince = work.tstart
 		}
 
+		// The stack of a goroutine that may be leaked is scanned
+		// once leak detection decides its fate; see gcDetectLeaks.
+		if gp.leakCandidate {
+			break
+		}
+
 		// scanstack must be done on the system stack in case
 		// we're trying to scan our own stack.
 		systemstack(func() {
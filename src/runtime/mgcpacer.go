@@ -59,6 +59,13 @@ const (
 	// scannableStackSizeSlack is the bytes of stack space allocated or freed
 	// that can accumulate on a P before updating gcController.stackSize.
 	scannableStackSizeSlack = 8 << 10
+
+	// memoryLimitHeapGoalHeadroomPercent is how much headroom the pacer gives
+	// the heap goal when operating in the memory-limited regime, as a percent
+	// of the heap goal. memoryLimitMinHeapGoalHeadroom is the minimum amount
+	// of headroom, in bytes, which matters for small limits.
+	memoryLimitHeapGoalHeadroomPercent = 3
+	memoryLimitMinHeapGoalHeadroom     = 1 << 20
 )
 
 func init() {
@@ -90,6 +97,23 @@ type gcControllerState struct {
 
 	_ uint32 // padding so following 64-bit values are 8-byte aligned
 
+	// memoryLimit is the soft memory limit in bytes.
+	//
+	// Initialized from GOMEMLIMIT. GOMEMLIMIT=off is equivalent to MaxInt64
+	// which means no soft memory limit in practice.
+	//
+	// This is an int64 instead of a uint64 to more easily maintain parity with
+	// the SetMemoryLimit API, which sets a maximum at MaxInt64. This value
+	// should never be negative.
+	memoryLimit atomic.Int64
+
+	// mappedReady is the total amount of memory mapped by the runtime
+	// that is in the Ready state, i.e. memory that has not been released
+	// back to the OS. This is the quantity the memory limit applies to.
+	//
+	// It is maintained by the OS memory abstraction layer in mem.go.
+	mappedReady atomic.Uint64
+
 	// heapMinimum is the minimum heap size at which to trigger GC.
 	// For small heaps, this overrides the usual GOGC*live set rule.
 	//
@@ -336,8 +360,9 @@ type gcControllerState struct {
 	_ cpu.CacheLinePad
 }
 
-func (c *gcControllerState) init(gcPercent int32) {
+func (c *gcControllerState) init(gcPercent int32, memoryLimit int64) {
 	c.heapMinimum = defaultHeapMinimum
+	c.memoryLimit.Store(memoryLimit)
 
 	if goexperiment.PacerRedesign {
 		c.consMarkController = piController{
@@ -999,6 +1024,15 @@ func (c *gcControllerState) commit(triggerRatio float64) {
 
 	// Don't trigger below the minimum heap size.
 	minTrigger := c.heapMinimum
+
+	// The memory limit may impose a tighter goal than gcPercent.
+	if memoryLimitGoal := c.memoryLimitHeapGoal(); memoryLimitGoal < goal {
+		goal = memoryLimitGoal
+		// The minimum heap size is defined in terms of gcPercent, so
+		// it doesn't apply here. Never trigger below the live heap,
+		// though.
+		minTrigger = c.heapMarked
+	}
 	if !isSweepDone() {
 		// Concurrent sweep happens in the heap growth
 		// from gcController.heapLive to trigger, so ensure
@@ -1190,6 +1224,16 @@ func (c *gcControllerState) oldCommit(triggerRatio float64) {
 		}
 	}
 
+	// The memory limit may impose a tighter goal than gcPercent.
+	// Trigger at the same fraction of the way to it as the
+	// maximum trigger ratio would.
+	if memoryLimitGoal := c.memoryLimitHeapGoal(); memoryLimitGoal < goal {
+		goal = memoryLimitGoal
+		if limitTrigger := c.heapMarked + uint64(0.95*float64(goal-c.heapMarked)); trigger > limitTrigger {
+			trigger = limitTrigger
+		}
+	}
+
 	// Commit to the trigger and goal.
 	c.trigger = trigger
 	atomic.Store64(&c.heapGoal, goal)
@@ -1255,13 +1299,13 @@ func setGCPercent(in int32) (out int32) {
 		lock(&mheap_.lock)
 		out = gcController.setGCPercent(in)
 		gcPaceSweeper(gcController.trigger)
-		gcPaceScavenger(gcController.heapGoal, gcController.lastHeapGoal)
+		gcPaceScavenger(gcController.memoryLimit.Load(), gcController.heapGoal, gcController.lastHeapGoal)
 		unlock(&mheap_.lock)
 	})
 
 	// If we just disabled GC, wait for any concurrent GC mark to
 	// finish so we always return with no GC running.
-	if in < 0 {
+	if in < 0 && gcController.memoryLimit.Load() == maxInt64 {
 		gcWaitOnMark(atomic.Load(&work.cycles))
 	}
 
@@ -1279,6 +1323,121 @@ func readGOGC() int32 {
 	return 100
 }
 
+// memoryLimitHeapGoal returns a heap goal derived from memoryLimit,
+// or ^uint64(0) if there is no memory limit.
+//
+// The memory limit applies to all the memory mapped by the runtime that
+// hasn't been released to the OS (mappedReady), while the heap goal only
+// applies to heap objects. To convert from one to the other, subtract from
+// the limit everything that counts toward it but isn't and can't become
+// heap memory: broadly speaking, non-heap overheads such as goroutine
+// stacks and runtime metadata. If the runtime is already over the limit,
+// subtract the overage too, which pushes the goal (and with it the trigger)
+// down to help recover. Finally, leave a little headroom to account for
+// pacing inaccuracies.
+//
+// The returned goal is never below heapMarked, since a goal below the live
+// heap doesn't make sense.
+//
+// mheap_.lock must be held or the world must be stopped.
+func (c *gcControllerState) memoryLimitHeapGoal() uint64 {
+	memoryLimit := c.memoryLimit.Load()
+	if memoryLimit == maxInt64 {
+		return ^uint64(0)
+	}
+	limit := uint64(memoryLimit)
+
+	// Compute the non-heap overheads. These statistics are updated
+	// independently, so we may observe a partial update that makes the
+	// heap appear larger than all mapped memory. Such a state is
+	// transient, so just treat it as having no overheads.
+	mappedReady := c.mappedReady.Load()
+	var nonHeapMemory uint64
+	if retained := heapRetained(); retained < mappedReady {
+		nonHeapMemory = mappedReady - retained
+	}
+	var overage uint64
+	if mappedReady > limit {
+		overage = mappedReady - limit
+	}
+	if nonHeapMemory+overage >= limit {
+		// Non-heap memory exceeds the memory limit on its own. There's not
+		// much we can do here but collect continuously.
+		return c.heapMarked
+	}
+	goal := limit - (nonHeapMemory + overage)
+
+	// Apply some headroom, being careful about small limits.
+	headroom := goal / 100 * memoryLimitHeapGoalHeadroomPercent
+	if headroom < memoryLimitMinHeapGoalHeadroom {
+		headroom = memoryLimitMinHeapGoalHeadroom
+	}
+	if goal < headroom || goal-headroom < headroom {
+		goal = headroom
+	} else {
+		goal -= headroom
+	}
+	if goal < c.heapMarked {
+		goal = c.heapMarked
+	}
+	return goal
+}
+
+// setMemoryLimit updates memoryLimit and all related pacer state.
+// Returns the old value of memoryLimit. A negative in leaves the
+// limit unchanged.
+//
+// Calls gcControllerState.commit if the limit changes.
+//
+// The world must be stopped, or mheap_.lock must be held.
+func (c *gcControllerState) setMemoryLimit(in int64) int64 {
+	if !c.test {
+		assertWorldStoppedOrLockHeld(&mheap_.lock)
+	}
+
+	out := c.memoryLimit.Load()
+	if in < 0 || in == out {
+		return out
+	}
+	c.memoryLimit.Store(in)
+	// Update pacing in response to memoryLimit change.
+	c.commit(c.triggerRatio)
+	return out
+}
+
+//go:linkname setMemoryLimit runtime/debug.setMemoryLimit
+func setMemoryLimit(in int64) (out int64) {
+	// Run on the system stack since we grab the heap lock.
+	systemstack(func() {
+		lock(&mheap_.lock)
+		out = gcController.setMemoryLimit(in)
+		if in < 0 || out == in {
+			// If we're just checking the value or not changing
+			// it, there's no point in repacing the sweeper and
+			// scavenger.
+			unlock(&mheap_.lock)
+			return
+		}
+		gcPaceSweeper(gcController.trigger)
+		gcPaceScavenger(gcController.memoryLimit.Load(), gcController.heapGoal, gcController.lastHeapGoal)
+		unlock(&mheap_.lock)
+	})
+	return out
+}
+
+func readGOMEMLIMIT() int64 {
+	p := gogetenv("GOMEMLIMIT")
+	if p == "" || p == "off" {
+		return maxInt64
+	}
+	n, ok := parseByteCount(p)
+	if !ok {
+		print("GOMEMLIMIT=", p, "\n")
+		throw("malformed GOMEMLIMIT; see `go doc runtime/debug.SetMemoryLimit`")
+	}
+	return n
+}
+
 type piController struct {
 	kp float64 // Proportional constant.
 	ti float64 // Integral time constant.
@@ -41,6 +41,14 @@
 // that there's more unscavenged memory to allocate out of, since each allocation
 // out of scavenged memory incurs a potentially expensive page fault.
 //
+// If a memory limit is set, the background scavenger has a second goal: to keep
+// the total memory mapped by the runtime that hasn't been released to the OS
+// (gcController.mappedReady) below (100-reduceExtraPercent)% of the limit. The
+// background scavenger works toward whichever goal requires it to release more
+// memory. Since the heap goal is already lowered to account for the memory
+// limit, this goal mostly matters when the heap has shrunk rapidly or when
+// non-heap memory grows, leaving behind free and unscavenged pages.
+//
 // The goal is updated after each GC and the scavenger's pacing parameters
 // (which live in mheap_) are updated to match. The pacing parameters work much
 // like the background sweeping parameters. The parameters define a line whose
@@ -78,6 +86,17 @@ const (
 	// the ever-changing layout of the heap.
 	retainExtraPercent = 10
 
+	// reduceExtraPercent represents the amount of memory under the memory
+	// limit that the scavenger should target. For example, 5 means we
+	// target 95% of the limit.
+	//
+	// The purpose of shooting lower than the limit is to ensure that, once
+	// close to the limit, the scavenger is working hard to maintain it. If
+	// we have a memory limit set but are far away from it, there's no harm
+	// in leaving up to 100-retainExtraPercent live, and it's more efficient
+	// anyway, for the same reasons that retainExtraPercent exists.
+	reduceExtraPercent = 5
+
 	// maxPagesPerPhysPage is the maximum number of supported runtime pages per
 	// physical page, based on maxPhysPageSize.
 	maxPagesPerPhysPage = maxPhysPageSize / pageSize
@@ -105,11 +124,12 @@ func heapRetained() uint64 {
 }
 
 // gcPaceScavenger updates the scavenger's pacing, particularly
-// its rate and RSS goal. For this, it requires the current heapGoal,
-// and the heapGoal for the previous GC cycle.
+// its rate and RSS goals. For this, it requires the current memoryLimit,
+// the current heapGoal, and the heapGoal for the previous GC cycle.
 //
 // The RSS goal is based on the current heap goal with a small overhead
-// to accommodate non-determinism in the allocator.
+// to accommodate non-determinism in the allocator. The memory limit goal
+// is based on memoryLimit, minus reduceExtraPercent.
 //
 // The pacing is based on scavengePageRate, which applies to both regular and
 // huge pages. See that constant for more information.
@@ -117,9 +137,19 @@ func heapRetained() uint64 {
 // Must be called whenever GC pacing is updated.
 //
 // mheap_.lock must be held or the world must be stopped.
-func gcPaceScavenger(heapGoal, lastHeapGoal uint64) {
+func gcPaceScavenger(memoryLimit int64, heapGoal, lastHeapGoal uint64) {
 	assertWorldStoppedOrLockHeld(&mheap_.lock)
 
+	// Compute our scavenging goal for the memory limit. Unlike the
+	// heap-goal-based goal below, this one is in terms of all memory
+	// mapped by the runtime and not released, and it doesn't depend on
+	// any information from a previous GC cycle.
+	memoryLimitGoal := ^uint64(0)
+	if memoryLimit != maxInt64 {
+		memoryLimitGoal = uint64(float64(memoryLimit) * (100.0 - reduceExtraPercent) / 100.0)
+	}
+	atomic.Store64(&mheap_.scavengeMemoryLimitGoal, memoryLimitGoal)
+
 	// If we're called before the first GC completed, disable scavenging.
 	// We never scavenge before the 2nd GC cycle anyway (we don't have enough
 	// information about the heap yet) so this is fine, and avoids a fault
@@ -163,6 +193,15 @@ func gcPaceScavenger(heapGoal, lastHeapGoal uint64) {
 	atomic.Store64(&mheap_.scavengeGoal, retainedGoal)
 }
 
+// scavengeNeeded reports whether the background scavenger has work to do,
+// that is, whether either of its goals is currently unmet.
+func scavengeNeeded() bool {
+	if heapRetained() > atomic.Load64(&mheap_.scavengeGoal) {
+		return true
+	}
+	return gcController.mappedReady.Load() > atomic.Load64(&mheap_.scavengeMemoryLimitGoal)
+}
+
 // Sleep/wait state of the background scavenger.
 var scavenge struct {
 	lock                 mutex
@@ -314,8 +353,7 @@ func bgscavenge(c chan int) {
 		const minCritTime = 1e6
 		for crit < minCritTime {
 			// If background scavenging is disabled or if there's no work to do just park.
-			retained, goal := heapRetained(), atomic.Load64(&mheap_.scavengeGoal)
-			if retained <= goal {
+			if !scavengeNeeded() {
 				break
 			}
 
@@ -115,6 +115,14 @@ type mheap struct {
 	// Accessed atomically.
 	scavengeGoal uint64
 
+	// scavengeMemoryLimitGoal is the amount of total memory mapped by the
+	// runtime and not released (measured by gcController.mappedReady) that
+	// the runtime will try to maintain by returning memory to the OS, in
+	// order to respect the memory limit. ^uint64(0) if there is no limit.
+	//
+	// Accessed atomically.
+	scavengeMemoryLimitGoal uint64
+
 	// Page reclaimer state
 
 	// reclaimIndex is the page index in allArenas of next page to
@@ -1202,6 +1210,28 @@ func (h *mheap) allocSpan(npages uintptr, typ spanAllocType, spanclass spanClass
 
 	unlock(&h.lock)
 
+HaveSpan:
+	// Decide if we need to scavenge in response to what we just allocated.
+	// Specifically, we track the maximum amount of memory to scavenge of all
+	// the alternatives below, assuming that the maximum satisfies *all*
+	// conditions we check (e.g. if we need to scavenge X to satisfy the
+	// memory limit and Y to satisfy heap-growth scavenging, and Y > X, then
+	// it's fine to pick Y, because the memory limit is still satisfied).
+	//
+	// It's fine to do this after allocating because we expect any scavenged
+	// pages not to get touched until we return. Simultaneously, it's important
+	// to do this before calling sysUsed because that may commit address space.
+	bytesToScavenge := uintptr(0)
+	if limit := gcController.memoryLimit.Load(); limit != maxInt64 {
+		// Assist with scavenging to maintain the memory limit by the amount
+		// that we expect to page in.
+		inUse := gcController.mappedReady.Load()
+		// Be careful about overflow, especially with uintptrs. Even on 32-bit
+		// platforms someone can set a really big memory limit that isn't maxInt64.
+		if uint64(scav)+inUse > uint64(limit) {
+			bytesToScavenge = uintptr(uint64(scav) + inUse - uint64(limit))
+		}
+	}
 	if growth > 0 {
 		// We just caused a heap growth, so scavenge down what will soon be used.
 		// By scavenging inline we deal with the failure to allocate out of
@@ -1209,19 +1239,23 @@ func (h *mheap) allocSpan(npages uintptr, typ spanAllocType, spanclass spanClass
 		// likely to be re-used.
 		scavengeGoal := atomic.Load64(&h.scavengeGoal)
 		if retained := heapRetained(); retained+uint64(growth) > scavengeGoal {
-			// The scavenging algorithm requires the heap lock to be dropped so it
-			// can acquire it only sparingly. This is a potentially expensive operation
-			// so it frees up other goroutines to allocate in the meanwhile. In fact,
-			// they can make use of the growth we just created.
 			todo := growth
 			if overage := uintptr(retained + uint64(growth) - scavengeGoal); todo > overage {
 				todo = overage
 			}
-			h.pages.scavenge(todo)
+			if todo > bytesToScavenge {
+				bytesToScavenge = todo
+			}
 		}
 	}
+	if bytesToScavenge > 0 {
+		// The scavenging algorithm requires the heap lock to be dropped so it
+		// can acquire it only sparingly. This is a potentially expensive operation
+		// so it frees up other goroutines to allocate in the meanwhile. In fact,
+		// they can make use of the growth we just created.
+		h.pages.scavenge(bytesToScavenge)
+	}
 
-HaveSpan:
 	// At this point, both s != nil and base != 0, and the heap
 	// lock is no longer held. Initialize the span.
 	s.init(base, npages)
@@ -1276,7 +1310,7 @@ HaveSpan:
 	if scav != 0 {
 		// sysUsed all the pages that are actually available
 		// in the span since some of them might be scavenged.
-		sysUsed(unsafe.Pointer(base), nbytes)
+		sysUsed(unsafe.Pointer(base), nbytes, scav)
 		atomic.Xadd64(&memstats.heap_released, -int64(scav))
 	}
 	// Update stats.
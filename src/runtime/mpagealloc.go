@@ -309,6 +309,12 @@ type pageAlloc struct {
 	// memory is committed by the pageAlloc for allocation metadata.
 	sysStat *sysMemStat
 
+	// summaryMappedReady is the number of bytes mapped in the Ready state
+	// in the summary structure. Used only for testing currently.
+	//
+	// Protected by mheapLock.
+	summaryMappedReady uintptr
+
 	// Whether or not this struct is being used in tests.
 	test bool
 }
@@ -324,8 +330,10 @@ func (p *pageAlloc) init(mheapLock *mutex, sysStat *sysMemStat) {
 	}
 	p.sysStat = sysStat
 
-	// Initialize p.inUse.
+	// Initialize p.inUse and its scavenger copy, so that the latter's
+	// memory is accounted for properly too.
 	p.inUse.init(sysStat)
+	p.scav.inUse.init(sysStat)
 
 	// System-dependent initialization.
 	p.sysInit()
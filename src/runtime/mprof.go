@@ -822,6 +822,86 @@ func goroutineProfileWithLabels(p []StackRecord, labels []unsafe.Pointer) (n int
 	return n, ok
 }
 
+//go:linkname runtime_goroutineLeakGC runtime/pprof.runtime_goroutineLeakGC
+func runtime_goroutineLeakGC() {
+	goroutineLeakGC()
+}
+
+//go:linkname runtime_goroutineLeakProfileWithLabels runtime/pprof.runtime_goroutineLeakProfileWithLabels
+func runtime_goroutineLeakProfileWithLabels(p []StackRecord, labels []unsafe.Pointer) (n int, ok bool) {
+	return goroutineLeakProfileWithLabels(p, labels)
+}
+
+// goroutineLeakProfileWithLabels is like goroutineProfileWithLabels,
+// but only for the goroutines found leaked by the last goroutine leak
+// detection that are still blocked.
+// labels may be nil. If labels is non-nil, it must have the same length as p.
+func goroutineLeakProfileWithLabels(p []StackRecord, labels []unsafe.Pointer) (n int, ok bool) {
+	if labels != nil && len(labels) != len(p) {
+		labels = nil
+	}
+
+	isLeaked := func(gp1 *g) bool {
+		return gp1.leaked && readgstatus(gp1) == _Gwaiting
+	}
+
+	stopTheWorld("profile")
+
+	// World is stopped, no locking required.
+	forEachGRace(func(gp1 *g) {
+		if isLeaked(gp1) {
+			n++
+		}
+	})
+
+	if n <= len(p) {
+		ok = true
+		r, lbl := p, labels
+		forEachGRace(func(gp1 *g) {
+			if !isLeaked(gp1) || len(r) == 0 {
+				return
+			}
+			// See goroutineProfileWithLabels.
+			systemstack(func() { saveLeakedg(gp1, &r[0]) })
+			if labels != nil {
+				lbl[0] = gp1.labels
+				lbl = lbl[1:]
+			}
+			r = r[1:]
+		})
+	}
+
+	startTheWorld()
+	return n, ok
+}
+
+// saveLeakedg saves the stack of the leaked goroutine gp, followed by
+// where it was created: the stack of its creator if it was recorded
+// for GODEBUG=tracebackancestors, or else the go statement.
+func saveLeakedg(gp *g, r *StackRecord) {
+	n := gentraceback(^uintptr(0), ^uintptr(0), 0, gp, 0, &r.Stack0[0], len(r.Stack0), nil, nil, 0)
+	if gp.ancestors != nil && len(*gp.ancestors) > 0 {
+		pcs := (*gp.ancestors)[0].pcs
+		// Drop the frames of the runtime creating the goroutine.
+		for len(pcs) > 0 && hasPrefix(funcname(findfunc(pcs[0])), "runtime.") {
+			pcs = pcs[1:]
+		}
+		for _, pc := range pcs {
+			if n == len(r.Stack0) {
+				break
+			}
+			r.Stack0[n] = pc
+			n++
+		}
+	} else if n < len(r.Stack0) && gp.gopc != 0 {
+		r.Stack0[n] = gp.gopc
+		n++
+	}
+	if n < len(r.Stack0) {
+		r.Stack0[n] = 0
+	}
+}
+
 // GoroutineProfile returns n, the number of records in the active goroutine stack profile.
 // If len(p) >= n, GoroutineProfile copies the profile into p and returns n, true.
 // If len(p) < n, GoroutineProfile does not change p and returns n, false.
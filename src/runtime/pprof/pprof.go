@@ -100,12 +100,13 @@ import (
 //
 // Each Profile has a unique name. A few profiles are predefined:
 //
-//	goroutine    - stack traces of all current goroutines
-//	heap         - a sampling of memory allocations of live objects
-//	allocs       - a sampling of all past memory allocations
-//	threadcreate - stack traces that led to the creation of new OS threads
-//	block        - stack traces that led to blocking on synchronization primitives
-//	mutex        - stack traces of holders of contended mutexes
+//	goroutine     - stack traces of all current goroutines
+//	goroutineleak - stack traces of leaked goroutines
+//	heap          - a sampling of memory allocations of live objects
+//	allocs        - a sampling of all past memory allocations
+//	threadcreate  - stack traces that led to the creation of new OS threads
+//	block         - stack traces that led to blocking on synchronization primitives
+//	mutex         - stack traces of holders of contended mutexes
 //
 // These predefined profiles maintain themselves and panic on an explicit
 // Add or Remove method call.
@@ -127,6 +128,14 @@ import (
 // pprof display to -alloc_space, the total number of bytes allocated since
 // the program began (including garbage-collected bytes).
 //
+// The goroutineleak profile reports goroutines that are blocked forever,
+// because the channels, mutexes, wait groups or conditions they wait on are
+// no longer reachable from any goroutine that may run. Writing the profile
+// runs a garbage collection to find these goroutines, during which the
+// other goroutines of the program do not run. The stack of each leaked
+// goroutine is followed by where it was created: the go statement, or
+// the stack of its creator when GODEBUG=tracebackancestors is set.
+//
 // The CPU profile is not available as a Profile. It has a special API,
 // the StartCPUProfile and StopCPUProfile functions, because it streams
 // output to a writer during profiling.
@@ -151,6 +160,12 @@ var goroutineProfile = &Profile{
 	write: writeGoroutine,
 }
 
+var goroutineLeakProfile = &Profile{
+	name:  "goroutineleak",
+	count: countGoroutineLeak,
+	write: writeGoroutineLeak,
+}
+
 var threadcreateProfile = &Profile{
 	name:  "threadcreate",
 	count: countThreadCreate,
@@ -186,12 +201,13 @@ func lockProfiles() {
 	if profiles.m == nil {
 		// Initial built-in profiles.
 		profiles.m = map[string]*Profile{
-			"goroutine":    goroutineProfile,
-			"threadcreate": threadcreateProfile,
-			"heap":         heapProfile,
-			"allocs":       allocsProfile,
-			"block":        blockProfile,
-			"mutex":        mutexProfile,
+			"goroutine":     goroutineProfile,
+			"goroutineleak": goroutineLeakProfile,
+			"threadcreate":  threadcreateProfile,
+			"heap":          heapProfile,
+			"allocs":        allocsProfile,
+			"block":         blockProfile,
+			"mutex":         mutexProfile,
 		}
 	}
 }
@@ -685,6 +701,26 @@ func writeGoroutine(w io.Writer, debug int) error {
 	return writeRuntimeProfile(w, debug, "goroutine", runtime_goroutineProfileWithLabels)
 }
 
+// countGoroutineLeak returns the number of goroutines found leaked
+// by the last goroutine leak detection.
+func countGoroutineLeak() int {
+	n, _ := runtime_goroutineLeakProfileWithLabels(nil, nil)
+	return n
+}
+
+// runtime_goroutineLeakGC is defined in runtime/mprof.go
+func runtime_goroutineLeakGC()
+
+// runtime_goroutineLeakProfileWithLabels is defined in runtime/mprof.go
+func runtime_goroutineLeakProfileWithLabels(p []runtime.StackRecord, labels []unsafe.Pointer) (n int, ok bool)
+
+// writeGoroutineLeak finds the leaked goroutines and writes their
+// stacks to w.
+func writeGoroutineLeak(w io.Writer, debug int) error {
+	runtime_goroutineLeakGC()
+	return writeRuntimeProfile(w, debug, "goroutineleak", runtime_goroutineLeakProfileWithLabels)
+}
+
 func writeGoroutineStacks(w io.Writer) error {
 	// We don't know how big the buffer needs to be to collect
 	// all the goroutines. Start with 1 MB and try a few times, doubling each time.
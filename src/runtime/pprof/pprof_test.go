@@ -1319,6 +1319,123 @@ func TestGoroutineCounts(t *testing.T) {
 	time.Sleep(10 * time.Millisecond) // let goroutines exit
 }
 
+func leakedSend() {
+	c := make(chan int)
+	go func() { c <- 0 }()
+}
+
+func leakedSelect() {
+	c1, c2 := make(chan int), make(chan int)
+	go func() {
+		select {
+		case <-c1:
+		case c2 <- 0:
+		}
+	}()
+}
+
+func leakedMutex() {
+	var mu sync.Mutex
+	mu.Lock()
+	go func() { mu.Lock() }()
+}
+
+func leakedWaitGroup() {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() { wg.Wait() }()
+}
+
+func leakedCond() {
+	cond := sync.NewCond(new(sync.Mutex))
+	go func() {
+		cond.L.Lock()
+		cond.Wait()
+	}()
+}
+
+// blockedChain starts a goroutine blocked on a channel that is only
+// referenced by another blocked goroutine, which c wakes up. Neither
+// of them is leaked as long as c is reachable.
+func blockedChain(c chan int) {
+	inner := make(chan int)
+	go func() { <-inner }()
+	go func() {
+		<-c
+		inner <- 0
+	}()
+}
+
+func TestGoroutineLeakProfile(t *testing.T) {
+	for i := 0; i < 5; i++ {
+		leakedSend()
+	}
+	for i := 0; i < 4; i++ {
+		leakedSelect()
+	}
+	for i := 0; i < 3; i++ {
+		leakedMutex()
+	}
+	for i := 0; i < 2; i++ {
+		leakedWaitGroup()
+		leakedCond()
+	}
+	c := make(chan int)
+	blockedChain(c)
+	defer close(c)
+
+	// The goroutines leaked by earlier runs of the test stay leaked,
+	// so these are the minimum counts.
+	want := map[string]int64{
+		"runtime/pprof.leakedSend":      5,
+		"runtime/pprof.leakedSelect":    4,
+		"runtime/pprof.leakedMutex":     3,
+		"runtime/pprof.leakedWaitGroup": 2,
+		"runtime/pprof.leakedCond":      2,
+		"runtime/pprof.blockedChain":    0,
+	}
+	var got map[string]int64
+	ok := func() bool {
+		for fn, n := range want {
+			if got[fn] < n || n == 0 && got[fn] != 0 {
+				return false
+			}
+		}
+		return true
+	}
+	// Wait for the goroutines to block.
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		var buf bytes.Buffer
+		if err := Lookup("goroutineleak").WriteTo(&buf, 0); err != nil {
+			t.Fatalf("writing goroutineleak profile: %v", err)
+		}
+		p, err := profile.Parse(&buf)
+		if err != nil {
+			t.Fatalf("parsing goroutineleak profile: %v", err)
+		}
+		// The leaked goroutines are attributed to the functions
+		// that created them, which end their stacks.
+		got = make(map[string]int64)
+		for _, s := range p.Sample {
+			loc := s.Location[len(s.Location)-1]
+			fn := loc.Line[len(loc.Line)-1].Function.Name
+			if _, ok := want[fn]; ok {
+				got[fn] += s.Value[0]
+			}
+		}
+		if ok() || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !ok() {
+		t.Errorf("leaked goroutines by creating function: got %v, want %v", got, want)
+	}
+	if n := Lookup("goroutineleak").Count(); n < 16 {
+		t.Errorf("goroutineleak profile count is %d, want at least 16", n)
+	}
+}
+
 func containsInOrder(s string, all ...string) bool {
 	for _, t := range all {
 		var ok bool
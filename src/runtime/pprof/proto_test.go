@@ -438,3 +438,35 @@ func TestEmptyStack(t *testing.T) {
 		t.Fatalf("translating profile: %v", err)
 	}
 }
+
+// startLineFunc returns the line on which it is declared.
+//
+//go:noinline
+func startLineFunc() (line int) {
+	_, _, line, _ = runtime.Caller(0)
+	return line - 1
+}
+
+// TestFunctionStartLine checks that functions in the profile record
+// the line on which they are declared.
+func TestFunctionStartLine(t *testing.T) {
+	pc := uint64(abi.FuncPCABIInternal(startLineFunc))
+	b := []uint64{
+		3, 0, 500, // hz = 500
+		4, 0, 1, pc + 1, // 1 sample in startLineFunc
+	}
+	p, err := translateCPUProfile(b, 2)
+	if err != nil {
+		t.Fatalf("translating profile: %v", err)
+	}
+	want := int64(startLineFunc())
+	for _, f := range p.Function {
+		if strings.HasSuffix(f.Name, ".startLineFunc") {
+			if f.StartLine != want {
+				t.Errorf("%s: StartLine = %d, want %d", f.Name, f.StartLine, want)
+			}
+			return
+		}
+	}
+	t.Fatalf("startLineFunc missing from profile:\n%v", p)
+}
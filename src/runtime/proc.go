@@ -248,6 +248,7 @@ func main() {
 	}
 	fn := main_main // make an indirect call, as the linker doesn't know the address of the main package when laying down the runtime
 	fn()
+	runExitHooks(0)
 	if raceenabled {
 		racefini()
 	}
@@ -276,10 +277,11 @@ func main() {
 	}
 }
 
-// os_beforeExit is called from os.Exit(0).
+// os_beforeExit is called from os.Exit.
 //go:linkname os_beforeExit os.runtime_beforeExit
-func os_beforeExit() {
-	if raceenabled {
+func os_beforeExit(exitCode int) {
+	runExitHooks(exitCode)
+	if exitCode == 0 && raceenabled {
 		racefini()
 	}
 }
@@ -664,7 +666,6 @@ func schedinit() {
 	lockInit(&allpLock, lockRankAllp)
 	lockInit(&reflectOffs.lock, lockRankReflectOffs)
 	lockInit(&finlock, lockRankFin)
-	lockInit(&trace.bufLock, lockRankTraceBuf)
 	lockInit(&trace.stringsLock, lockRankTraceStrings)
 	lockInit(&trace.lock, lockRankTrace)
 	lockInit(&cpuprof.lock, lockRankCpuprof)
@@ -1498,8 +1499,11 @@ func mexit(osStack bool) {
 		m.gsignal = nil
 	}
 
-	// Remove m from allm.
+	// Remove m from allm. traceFlushGen walks allm without the lock, so
+	// count m as exiting first: it then either finds m in allm or waits
+	// for m to queue its trace buffers below.
 	lock(&sched.lock)
+	atomic.Xadd(&trace.exitingM, 1)
 	for pprev := &allm; *pprev != nil; pprev = &(*pprev).alllink {
 		if *pprev == m {
 			*pprev = m.alllink
@@ -1529,6 +1533,9 @@ found:
 	handoffp(releasep())
 	// After this point we must not have write barriers.
 
+	// m can no longer write trace events.
+	traceThreadDestroy(m)
+
 	// Invoke the deadlock detector. This must happen after
 	// handoffp because it may have started a new M to take our
 	// P's work.
@@ -4688,7 +4695,6 @@ func (pp *p) destroy() {
 	freemcache(pp.mcache)
 	pp.mcache = nil
 	gfpurge(pp)
-	traceProcFree(pp)
 	if raceenabled {
 		if pp.timerRaceCtx != 0 {
 			// The race detector code uses a callback to fetch
@@ -313,6 +313,7 @@ var debug struct {
 	scavtrace          int32
 	scheddetail        int32
 	schedtrace         int32
+	traceadvanceperiod int32
 	tracebackancestors int32
 	asyncpreemptoff    int32
 	harddecommit       int32
@@ -342,6 +343,7 @@ var dbgvars = []dbgVar{
 	{"scavtrace", &debug.scavtrace},
 	{"scheddetail", &debug.scheddetail},
 	{"schedtrace", &debug.schedtrace},
+	{"traceadvanceperiod", &debug.traceadvanceperiod},
 	{"tracebackancestors", &debug.tracebackancestors},
 	{"asyncpreemptoff", &debug.asyncpreemptoff},
 	{"inittrace", &debug.inittrace},
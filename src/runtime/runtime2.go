@@ -462,17 +462,20 @@ type g struct {
 	// for stack shrinking. It's a boolean value, but is updated atomically.
 	parkingOnChan uint8
 
-	raceignore     int8     // ignore race detection events
-	sysblocktraced bool     // StartTrace has emitted EvGoInSyscall about this goroutine
-	tracking       bool     // whether we're tracking this G for sched latency statistics
-	trackingSeq    uint8    // used to decide whether to track this G
-	runnableStamp  int64    // timestamp of when the G last became runnable, only used when tracking
-	runnableTime   int64    // the amount of time spent runnable, cleared when running, only used when tracking
-	sysexitticks   int64    // cputicks when syscall has returned (for tracing)
-	traceseq       uint64   // trace event sequencer
-	tracelastp     puintptr // last P emitted an event for this goroutine
+	raceignore     int8   // ignore race detection events
+	sysblocktraced bool   // StartTrace has emitted EvGoInSyscall about this goroutine
+	tracking       bool   // whether we're tracking this G for sched latency statistics
+	trackingSeq    uint8  // used to decide whether to track this G
+	leakCandidate  bool   // goroutine leak detection has yet to decide whether this G is leaked
+	leaked         bool   // the last goroutine leak detection found this G leaked
+	runnableStamp  int64  // timestamp of when the G last became runnable, only used when tracking
+	runnableTime   int64  // the amount of time spent runnable, cleared when running, only used when tracking
+	sysexitticks   int64  // cputicks when syscall has returned (for tracing)
+	traceseq       uint64 // trace event sequencer
+	tracelastm     int64  // id of the last M that emitted an event for this goroutine
 	lockedm        muintptr
 	sig            uint32
+	tracegen       uint32 // last trace generation that records the state of this goroutine, accessed atomically
 	writebuf       []byte
 	sigcode0       uintptr
 	sigcode1       uintptr
@@ -482,6 +485,7 @@ type g struct {
 	startpc        uintptr         // pc of goroutine function
 	racectx        uintptr
 	waiting        *sudog         // sudog structures this g is waiting on (that have a valid elem ptr); in lock order
+	waitingSync    *sudog         // sudog this g is waiting on in a semaphore or notify list; elem is the address waited on
 	cgoCtxt        []uintptr      // cgo traceback context
 	labels         unsafe.Pointer // profiler labels
 	timer          *timer         // cached timer for time.Sleep
@@ -560,6 +564,10 @@ type m struct {
 	waittraceev   byte
 	waittraceskip int
 	startingtrace bool
+	traceseqlock  uint32         // odd while writing trace events, accessed atomically
+	tracedepth    int32          // nesting of trace event writes
+	tracegen      uint64         // generation of the trace events being written
+	tracebuf      [2]traceBufPtr // trace buffers of the last two generations, indexed by generation%2
 	syscalltick   uint32
 	freelink      *m // on sched.freem
 
@@ -649,7 +657,9 @@ type p struct {
 		buf [128]*mspan
 	}
 
-	tracebuf traceBufPtr
+	// tracesyscallg is the last goroutine that entered a syscall on this P
+	// while tracing, to which a traceEvGoSysBlock for the P refers.
+	tracesyscallg guintptr
 
 	// traceSweep indicates the sweep events should be traced.
 	// This is used to defer the sweep start event until a span
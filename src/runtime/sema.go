@@ -141,7 +141,9 @@ func semacquire1(addr *uint32, lifo bool, profile semaProfileFlags, skipframes i
 		// Any semrelease after the cansemacquire knows we're waiting
 		// (we set nwait above), so go to sleep.
 		root.queue(addr, s, lifo)
+		gp.waitingSync = s
 		goparkunlock(&root.lock, waitReasonSemacquire, traceEvGoBlockSync, 4+skipframes)
+		gp.waitingSync = nil
 		if s.ticket != 0 || cansemacquire(addr) {
 			break
 		}
@@ -497,6 +499,7 @@ func notifyListWait(l *notifyList, t uint32) {
 	// Enqueue itself.
 	s := acquireSudog()
 	s.g = getg()
+	s.elem = unsafe.Pointer(l)
 	s.ticket = t
 	s.releasetime = 0
 	t0 := int64(0)
@@ -510,10 +513,13 @@ func notifyListWait(l *notifyList, t uint32) {
 		l.tail.next = s
 	}
 	l.tail = s
+	s.g.waitingSync = s
 	goparkunlock(&l.lock, waitReasonSyncCondWait, traceEvGoBlockCond, 3)
+	s.g.waitingSync = nil
 	if t0 != 0 {
 		blockevent(s.releasetime-t0, 2)
 	}
+	s.elem = nil
 	releaseSudog(s)
 }
 
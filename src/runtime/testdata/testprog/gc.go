@@ -21,6 +21,7 @@ func init() {
 	register("GCPhys", GCPhys)
 	register("DeferLiveness", DeferLiveness)
 	register("GCZombie", GCZombie)
+	register("GCMemoryLimit", GCMemoryLimit)
 }
 
 func GCSys() {
@@ -303,3 +304,47 @@ func GCZombie() {
 	runtime.KeepAlive(keep)
 	runtime.KeepAlive(zombies)
 }
+
+// GCMemoryLimit checks that the runtime respects the memory limit set via
+// GOMEMLIMIT, even with GOGC=off. It is expected to run with both set.
+func GCMemoryLimit() {
+	const limit = 64 << 20
+	if got := debug.SetMemoryLimit(-1); got != limit {
+		fmt.Printf("SetMemoryLimit(-1) = %d, want %d (GOMEMLIMIT not applied)\n", got, limit)
+		return
+	}
+
+	// Keep a modest live heap around and churn through several times
+	// the limit's worth of garbage. Without the limit, GOGC=off would
+	// let the heap grow without bound.
+	live := make([][]byte, 0, 16)
+	for i := 0; i < 16; i++ {
+		live = append(live, make([]byte, 1<<20))
+	}
+	var ms runtime.MemStats
+	var peak uint64
+	const chunk = 64 << 10
+	for i := 0; i < 16*limit/chunk; i++ {
+		escape(make([]byte, chunk))
+		if i%64 == 0 {
+			runtime.ReadMemStats(&ms)
+			if mapped := ms.Sys - ms.HeapReleased; mapped > peak {
+				peak = mapped
+			}
+		}
+	}
+	runtime.KeepAlive(live)
+
+	runtime.ReadMemStats(&ms)
+	if ms.NumGC == 0 {
+		fmt.Println("no GC cycles ran despite the memory limit")
+		return
+	}
+	// Allow some slack for pacing inaccuracies and memory the
+	// runtime can't release promptly.
+	if peak > limit*11/10 {
+		fmt.Printf("peak mapped memory %d MiB exceeds the limit of %d MiB\n", peak>>20, limit>>20)
+		return
+	}
+	fmt.Println("OK")
+}
@@ -22,7 +22,7 @@ import (
 // Event types in the trace, args are given in square brackets.
 const (
 	traceEvNone              = 0  // unused
-	traceEvBatch             = 1  // start of per-P batch of events [pid, timestamp]
+	traceEvBatch             = 1  // start of a batch of events written by thread mid for P pid [mid, pid, timestamp]
 	traceEvFrequency         = 2  // contains tracer timer frequency [frequency (ticks per second)]
 	traceEvStack             = 3  // stack [stack id, number of PCs, array of {PC, func string ID, file string ID, line}]
 	traceEvGomaxprocs        = 4  // current value of GOMAXPROCS [timestamp, GOMAXPROCS, stack id]
@@ -51,7 +51,7 @@ const (
 	traceEvGoBlockNet        = 27 // goroutine blocks on network [timestamp, stack]
 	traceEvGoSysCall         = 28 // syscall enter [timestamp, stack]
 	traceEvGoSysExit         = 29 // syscall exit [timestamp, goroutine id, seq, real timestamp]
-	traceEvGoSysBlock        = 30 // syscall blocks [timestamp]
+	traceEvGoSysBlock        = 30 // syscall blocks [timestamp, goroutine id]
 	traceEvGoWaiting         = 31 // denotes that goroutine is blocked when tracing starts [timestamp, goroutine id]
 	traceEvGoInSyscall       = 32 // denotes that goroutine is in syscall when tracing starts [timestamp, goroutine id]
 	traceEvHeapAlloc         = 33 // gcController.heapLive change [timestamp, heap_alloc]
@@ -59,9 +59,9 @@ const (
 	traceEvTimerGoroutine    = 35 // not currently used; previously denoted timer goroutine [timer goroutine id]
 	traceEvFutileWakeup      = 36 // denotes that the previous wakeup of this goroutine was futile [timestamp]
 	traceEvString            = 37 // string dictionary entry [ID, length, string]
-	traceEvGoStartLocal      = 38 // goroutine starts running on the same P as the last event [timestamp, goroutine id]
-	traceEvGoUnblockLocal    = 39 // goroutine is unblocked on the same P as the last event [timestamp, goroutine id, stack]
-	traceEvGoSysExitLocal    = 40 // syscall exit on the same P as the last event [timestamp, goroutine id, real timestamp]
+	traceEvGoStartLocal      = 38 // goroutine starts running on the same thread as the last event [timestamp, goroutine id]
+	traceEvGoUnblockLocal    = 39 // goroutine is unblocked on the same thread as the last event [timestamp, goroutine id, stack]
+	traceEvGoSysExitLocal    = 40 // syscall exit on the same thread as the last event [timestamp, goroutine id, real timestamp]
 	traceEvGoStartLabel      = 41 // goroutine starts running with label [timestamp, goroutine id, seq, label string id]
 	traceEvGoBlockGC         = 42 // goroutine blocks on GC assist [timestamp, stack]
 	traceEvGCMarkAssistStart = 43 // GC mark assist start [timestamp, stack]
@@ -70,7 +70,9 @@ const (
 	traceEvUserTaskEnd       = 46 // end of a task [timestamp, internal task id, stack]
 	traceEvUserRegion        = 47 // trace.WithRegion [timestamp, internal task id, mode(0:start, 1:end), stack, name string]
 	traceEvUserLog           = 48 // trace.Log [timestamp, internal task id, key string id, stack, value string]
-	traceEvCount             = 49
+	traceEvGeneration        = 49 // end of a generation [generation, start ticks, end ticks]
+	traceEvGoStatus          = 50 // goroutine state before its first event in a generation [timestamp, goroutine id, status, seq, start stack id]
+	traceEvCount             = 51
 	// Byte is used but only 6 bits are available for event type.
 	// The remaining 2 bits are used to specify the number of arguments.
 	// That means, the max event type value is 63.
@@ -95,6 +97,8 @@ const (
 	traceGlobProc = -1
 	// Maximum number of bytes to encode uint64 in base-128.
 	traceBytesPerNumber = 10
+	// Maximum size of a traceEvBatch header.
+	traceBatchSize = 1 + 3*traceBytesPerNumber
 	// Shift of the number of arguments in the first event byte.
 	traceArgCountShift = 6
 	// Flag passed to traceGoPark to denote that the previous wakeup of this
@@ -104,6 +108,17 @@ const (
 	// Such wakeups happen on buffered channels and sync.Mutex,
 	// but are generally not interesting for end user.
 	traceFutileWakeup byte = 128
+	// Default period, in milliseconds, after which the trace reader
+	// starts a new generation of the trace (see traceAdvance).
+	traceDefaultAdvancePeriod = 1000
+)
+
+// Goroutine statuses recorded by traceEvGoStatus.
+const (
+	traceGoRunnable = iota
+	traceGoRunning
+	traceGoWaiting
+	traceGoSyscall
 )
 
 // trace is global tracing context.
@@ -120,40 +135,50 @@ var trace struct {
 	ticksEnd      int64       // cputicks when tracing was stopped
 	timeStart     int64       // nanotime when tracing was started
 	timeEnd       int64       // nanotime when tracing was stopped
+	genTicksStart int64       // cputicks when the current generation was started
+	genTimeStart  int64       // nanotime when the current generation was started
+	genEnding     uint64      // generation being ended by traceAdvance, or 0
 	seqGC         uint64      // GC start/done sequencer
 	reading       traceBufPtr // buffer currently handed off to user
 	empty         traceBufPtr // stack of empty buffers
 	fullHead      traceBufPtr // queue of full buffers
 	fullTail      traceBufPtr
+	nextHead      traceBufPtr // queue of full buffers of the generation after genEnding
+	nextTail      traceBufPtr
 	reader        guintptr        // goroutine that called ReadTrace, or nil
 	stackTab      traceStackTable // maps stack traces to unique ids
 
+	gen      atomic.Uint64 // current generation of the trace
+	exitingM uint32        // number of Ms leaving allm that have not queued their buffers, accessed atomically
+
 	// Dictionary for traceEvString.
 	//
 	// TODO: central lock to access the map is not ideal.
 	//   option: pre-assign ids to all user annotation region names and tags
 	//   option: per-P cache
 	//   option: sync.Map like data structure
+	// Strings are defined anew in every generation, so there is a table
+	// for each of the last two generations, indexed by generation%2.
 	stringsLock mutex
-	strings     map[string]uint64
+	strings     [2]map[string]uint64
 	stringSeq   uint64
-
-	// markWorkerLabels maps gcMarkWorkerMode to string ID.
-	markWorkerLabels [len(gcMarkWorkerModeStrings)]uint64
-
-	bufLock mutex       // protects buf
-	buf     traceBufPtr // global trace buffer, used when running without a p
 }
 
-// traceBufHeader is per-P tracing buffer.
+// traceAdvanceSema serializes traceAdvance with StartTrace and StopTrace.
+var traceAdvanceSema uint32 = 1
+
+// traceBufHeader is per-M tracing buffer.
 type traceBufHeader struct {
 	link      traceBufPtr             // in trace.empty/full
 	lastTicks uint64                  // when we wrote the last event
 	pos       int                     // next write offset in arr
+	gen       uint64                  // generation of the events in the buffer
+	pid       int32                   // P of the current batch of events
+	genEnd    uint64                  // if non-zero, the generation ended by the buffer
 	stk       [traceStackSize]uintptr // scratch buffer for traceback
 }
 
-// traceBuf is per-P tracing buffer.
+// traceBuf is per-M tracing buffer.
 //
 //go:notinheap
 type traceBuf struct {
@@ -182,6 +207,9 @@ func traceBufPtrOf(b *traceBuf) traceBufPtr {
 // Most clients should use the runtime/trace package or the testing package's
 // -test.trace flag instead of calling StartTrace directly.
 func StartTrace() error {
+	// Wait for a concurrent traceAdvance of a previous trace to return.
+	semacquire(&traceAdvanceSema)
+
 	// Stop the world so that we can take a consistent snapshot
 	// of all goroutines at the beginning of the trace.
 	// Do not stop the world during GC so we ensure we always see
@@ -192,17 +220,10 @@ func StartTrace() error {
 	// Prevent sysmon from running any code that could generate events.
 	lock(&sched.sysmonlock)
 
-	// We are in stop-the-world, but syscalls can finish and write to trace concurrently.
-	// Exitsyscall could check trace.enabled long before and then suddenly wake up
-	// and decide to write to trace at a random point in time.
-	// However, such syscall will use the global trace.buf buffer, because we've
-	// acquired all p's by doing stop-the-world. So this protects us from such races.
-	lock(&trace.bufLock)
-
 	if trace.enabled || trace.shutdown {
-		unlock(&trace.bufLock)
 		unlock(&sched.sysmonlock)
 		startTheWorldGC()
+		semrelease(&traceAdvanceSema)
 		return errorString("tracing is already enabled")
 	}
 
@@ -216,20 +237,31 @@ func StartTrace() error {
 	_g_ := getg()
 	_g_.m.startingtrace = true
 
+	// Stacks are attributed to the generation in which they are used,
+	// starting with the ones below.
+	trace.gen.Store(1)
+
 	// Obtain current stack ID to use in all traceEvGoCreate events below.
 	mp := acquirem()
 	stkBuf := make([]uintptr, traceStackSize)
-	stackID := traceStackID(mp, stkBuf, 2)
+	stackID := traceStackID(mp, 1, stkBuf, 2)
 	releasem(mp)
 
+	// The events below record the state of every goroutine in the first
+	// generation. Mark them all before writing any event, or the first one
+	// would record the state of the current goroutine again, as a
+	// traceEvGoStatus that precedes its traceEvGoCreate.
 	// World is stopped, no need to lock.
+	forEachGRace(func(gp *g) {
+		atomic.Store(&gp.tracegen, 1)
+	})
 	forEachGRace(func(gp *g) {
 		status := readgstatus(gp)
 		if status != _Gdead {
 			gp.traceseq = 0
-			gp.tracelastp = getg().m.p
+			gp.tracelastm = getg().m.id
 			// +PCQuantum because traceFrameForPC expects return PCs and subtracts PCQuantum.
-			id := trace.stackTab.put([]uintptr{startPCforTrace(gp.startpc) + sys.PCQuantum})
+			id := trace.stackTab.put(1, []uintptr{startPCforTrace(gp.startpc) + sys.PCQuantum})
 			traceEvent(traceEvGoCreate, -1, uint64(gp.goid), uint64(id), stackID)
 		}
 		if status == _Gwaiting {
@@ -252,6 +284,8 @@ func StartTrace() error {
 	// It will lead to a false conclusion that cputicks is broken.
 	trace.ticksStart = cputicks()
 	trace.timeStart = nanotime()
+	trace.genTicksStart = trace.ticksStart
+	trace.genTimeStart = trace.timeStart
 	trace.headerWritten = false
 	trace.footerWritten = false
 
@@ -259,71 +293,46 @@ func StartTrace() error {
 	//  0 : reserved for an empty string
 	//  remaining: other strings registered by traceString
 	trace.stringSeq = 0
-	trace.strings = make(map[string]uint64)
+	trace.strings[1] = make(map[string]uint64) // of generation 1
 
 	trace.seqGC = 0
 	_g_.m.startingtrace = false
 	trace.enabled = true
 
-	// Register runtime goroutine labels.
-	_, pid, bufp := traceAcquireBuffer()
-	for i, label := range gcMarkWorkerModeStrings[:] {
-		trace.markWorkerLabels[i], bufp = traceString(bufp, pid, label)
-	}
-	traceReleaseBuffer(pid)
-
-	unlock(&trace.bufLock)
-
 	unlock(&sched.sysmonlock)
 
 	startTheWorldGC()
+	semrelease(&traceAdvanceSema)
 	return nil
 }
 
 // StopTrace stops tracing, if it was previously enabled.
 // StopTrace only returns after all the reads for the trace have completed.
 func StopTrace() {
-	// Stop the world so that we can collect the trace buffers from all p's below,
-	// and also to avoid races with traceEvent.
+	// See the comment in StartTrace.
+	semacquire(&traceAdvanceSema)
+
+	// Stop the world so that only Ms without a P, which exit syscalls,
+	// can write events concurrently, and collect the trace buffers below.
 	stopTheWorldGC("stop tracing")
 
 	// See the comment in StartTrace.
 	lock(&sched.sysmonlock)
 
-	// See the comment in StartTrace.
-	lock(&trace.bufLock)
-
 	if !trace.enabled {
-		unlock(&trace.bufLock)
 		unlock(&sched.sysmonlock)
 		startTheWorldGC()
+		semrelease(&traceAdvanceSema)
 		return
 	}
 
 	traceGoSched()
 
-	// Loop over all allocated Ps because dead Ps may still have
-	// trace buffers.
-	for _, p := range allp[:cap(allp)] {
-		buf := p.tracebuf
-		if buf != 0 {
-			traceFullQueue(buf)
-			p.tracebuf = 0
-		}
-	}
-	if trace.buf != 0 {
-		buf := trace.buf
-		trace.buf = 0
-		if buf.ptr().pos != 0 {
-			traceFullQueue(buf)
-		}
-	}
-
 	for {
 		trace.ticksEnd = cputicks()
 		trace.timeEnd = nanotime()
 		// Windows time can tick only every 15ms, wait for at least one tick.
-		if trace.timeEnd != trace.timeStart {
+		if trace.timeEnd != trace.genTimeStart {
 			break
 		}
 		osyield()
@@ -331,11 +340,15 @@ func StopTrace() {
 
 	trace.enabled = false
 	trace.shutdown = true
-	unlock(&trace.bufLock)
+
+	// Queue the buffers of the last generation, once the Ms that
+	// saw trace.enabled before it was reset are done writing.
+	traceFlushGen(trace.gen.Load())
 
 	unlock(&sched.sysmonlock)
 
 	startTheWorldGC()
+	semrelease(&traceAdvanceSema)
 
 	// The world is started but we've set trace.shutdown, so new tracing can't start.
 	// Wait for the trace reader to flush pending buffers and stop.
@@ -346,14 +359,11 @@ func StopTrace() {
 
 	// The lock protects us from races with StartTrace/StopTrace because they do stop-the-world.
 	lock(&trace.lock)
-	for _, p := range allp[:cap(allp)] {
-		if p.tracebuf != 0 {
-			throw("trace: non-empty trace buffer in proc")
+	for mp := (*m)(atomic.Loadp(unsafe.Pointer(&allm))); mp != nil; mp = mp.alllink {
+		if mp.tracebuf[0] != 0 || mp.tracebuf[1] != 0 {
+			throw("trace: non-empty trace buffer in thread")
 		}
 	}
-	if trace.buf != 0 {
-		throw("trace: non-empty global trace buffer")
-	}
 	if trace.fullHead != 0 || trace.fullTail != 0 {
 		throw("trace: non-empty full trace buffer")
 	}
@@ -365,17 +375,173 @@ func StopTrace() {
 		trace.empty = buf.ptr().link
 		sysFree(unsafe.Pointer(buf), unsafe.Sizeof(*buf.ptr()), &memstats.other_sys)
 	}
-	trace.strings = nil
+	trace.strings = [2]map[string]uint64{}
 	trace.shutdown = false
 	unlock(&trace.lock)
 }
 
+// traceAdvance ends the current generation of the trace and starts a new one.
+// All the events of a generation, followed by the stacks used in it, are
+// queued before any event of the next generation, so that a consumer can
+// parse the trace one generation at a time. traceAdvance does not stop the
+// world: it switches the Ms to the next generation, waits for the ones still
+// writing events of the current one and queues their buffers. It is called
+// by the trace reader and must not be called with trace.lock held.
+// It returns the generation it ended, or 0 if tracing is disabled.
+func traceAdvance() uint64 {
+	semacquire(&traceAdvanceSema)
+	if !trace.enabled || trace.shutdown {
+		semrelease(&traceAdvanceSema)
+		return 0
+	}
+
+	var ticks, now int64
+	for {
+		ticks = cputicks()
+		now = nanotime()
+		// Windows time can tick only every 15ms, wait for at least one tick.
+		if now != trace.genTimeStart {
+			break
+		}
+		osyield()
+	}
+	gen := trace.gen.Load()
+
+	// The strings of the next generation are defined anew. Its table
+	// was last used by the generation before the current one.
+	strings := make(map[string]uint64)
+	lock(&trace.stringsLock)
+	trace.strings[(gen+1)%2] = strings
+	unlock(&trace.stringsLock)
+
+	// Switch to the next generation. From now on, the buffers queued
+	// for it are held back until the current one is ended below.
+	lock(&trace.lock)
+	trace.genEnding = gen
+	trace.gen.Store(gen + 1)
+	unlock(&trace.lock)
+
+	traceFlushGen(gen)
+
+	bufp := traceFlush(0, gen, 0)
+	buf := bufp.ptr()
+	buf.byte(traceEvFrequency | 0<<traceArgCountShift)
+	buf.varint(traceFrequency(ticks, now))
+	traceGenEnd(bufp, gen, ticks)
+
+	trace.genTicksStart = ticks
+	trace.genTimeStart = now
+	semrelease(&traceAdvanceSema)
+	return gen
+}
+
+// traceFlushGen waits for the Ms to finish writing the events of generation
+// gen, which must no longer be the current one, and queues their buffers.
+func traceFlushGen(gen uint64) {
+	for mp := (*m)(atomic.Loadp(unsafe.Pointer(&allm))); mp != nil; mp = mp.alllink {
+		// An M that starts writing after this load sees the new generation.
+		seq := atomic.Load(&mp.traceseqlock)
+		for seq%2 == 1 && atomic.Load(&mp.traceseqlock) == seq {
+			osyield()
+		}
+		lock(&trace.lock)
+		if buf := mp.tracebuf[gen%2]; buf != 0 {
+			traceFullQueue(buf)
+			mp.tracebuf[gen%2] = 0
+		}
+		unlock(&trace.lock)
+	}
+	// The Ms that have left allm queue their buffers themselves.
+	for atomic.Load(&trace.exitingM) != 0 {
+		osyield()
+	}
+}
+
+// traceThreadDestroy queues the trace buffers of mp, which is exiting. It is
+// called once mp can no longer write events, after it has been removed from
+// allm and trace.exitingM has been incremented.
+func traceThreadDestroy(mp *m) {
+	lock(&trace.lock)
+	for i, buf := range mp.tracebuf {
+		if buf != 0 {
+			traceFullQueue(buf)
+			mp.tracebuf[i] = 0
+		}
+	}
+	unlock(&trace.lock)
+	atomic.Xadd(&trace.exitingM, -1)
+}
+
+// traceAdvanceDue reports whether the current generation of the trace
+// is old enough for the trace reader to start a new one.
+func traceAdvanceDue() bool {
+	period := int64(traceDefaultAdvancePeriod)
+	if debug.traceadvanceperiod > 0 {
+		period = int64(debug.traceadvanceperiod)
+	}
+	return nanotime()-trace.genTimeStart >= period*1e6
+}
+
+// traceFrequency returns the frequency of trace ticks over the current
+// generation, which ends at cputicks ticksEnd and nanotime timeEnd.
+func traceFrequency(ticksEnd, timeEnd int64) uint64 {
+	// Use float64 because (ticksEnd - trace.genTicksStart) * 1e9 can overflow int64.
+	freq := float64(ticksEnd-trace.genTicksStart) * 1e9 / float64(timeEnd-trace.genTimeStart) / traceTickDiv
+	if freq <= 0 {
+		throw("trace: ReadTrace got invalid frequency")
+	}
+	return uint64(freq)
+}
+
+// traceGenEnd writes the stacks used in generation gen, followed by the
+// traceEvGeneration event that ends it, to trace buffers starting with bufp,
+// and queues them for the trace reader, followed by the buffers of the next
+// generation queued meanwhile. ticksEnd is the cputicks value at which
+// the generation ended.
+func traceGenEnd(bufp traceBufPtr, gen uint64, ticksEnd int64) {
+	bufp = trace.stackTab.dump(gen, bufp)
+	if buf := bufp.ptr(); len(buf.arr)-buf.pos < 1+3*traceBytesPerNumber {
+		bufp = traceFlush(bufp, gen, 0)
+	}
+	buf := bufp.ptr()
+	buf.byte(traceEvGeneration | 2<<traceArgCountShift)
+	buf.varint(gen)
+	buf.varint(uint64(trace.genTicksStart) / traceTickDiv)
+	buf.varint(uint64(ticksEnd) / traceTickDiv)
+	buf.genEnd = gen
+
+	lock(&trace.lock)
+	traceFullQueue(bufp)
+	if trace.nextHead != 0 {
+		trace.fullTail.ptr().link = trace.nextHead
+		trace.fullTail = trace.nextTail
+		trace.nextHead = 0
+		trace.nextTail = 0
+	}
+	trace.genEnding = 0
+	unlock(&trace.lock)
+}
+
 // ReadTrace returns the next chunk of binary tracing data, blocking until data
 // is available. If tracing is turned off and all the data accumulated while it
 // was on has been returned, ReadTrace returns nil. The caller must copy the
 // returned data before calling ReadTrace again.
 // ReadTrace must be called from one goroutine at a time.
 func ReadTrace() []byte {
+	data, _ := readTrace()
+	return data
+}
+
+// readTrace is like ReadTrace, but also returns the generation ended
+// by the returned data, if any, or 0 otherwise.
+func readTrace() (data []byte, genEnd uint64) {
+	// Start a new generation if the current one is old enough.
+	// This must happen before we lock trace.lock below,
+	// because it waits for Ms that may need to lock it.
+	if trace.headerWritten && !trace.shutdown && traceAdvanceDue() {
+		traceAdvance()
+	}
+
 	// This function may need to lock trace.lock recursively
 	// (goparkunlock -> traceGoPark -> traceEvent -> traceFlush).
 	// To allow this we use trace.lockOwner.
@@ -392,7 +558,7 @@ func ReadTrace() []byte {
 		trace.lockOwner = nil
 		unlock(&trace.lock)
 		println("runtime: ReadTrace called from multiple goroutines simultaneously")
-		return nil
+		return nil, 0
 	}
 	// Recycle the old buffer.
 	if buf := trace.reading; buf != 0 {
@@ -405,13 +571,22 @@ func ReadTrace() []byte {
 		trace.headerWritten = true
 		trace.lockOwner = nil
 		unlock(&trace.lock)
-		return []byte("go 1.11 trace\x00\x00\x00")
+		return []byte("go 1.21 trace\x00\x00\x00"), 0
 	}
 	// Wait for new data.
 	if trace.fullHead == 0 && !trace.shutdown {
 		trace.reader.set(getg())
 		goparkunlock(&trace.lock, waitReasonTraceReaderBlocked, traceEvGoBlock, 2)
 		lock(&trace.lock)
+		if trace.fullHead == 0 && !trace.shutdown && traceAdvanceDue() {
+			// We were woken up to start a new generation,
+			// which queues the buffers of the current one.
+			trace.lockOwner = nil
+			unlock(&trace.lock)
+			traceAdvance()
+			lock(&trace.lock)
+			trace.lockOwner = getg()
+		}
 	}
 	// Write a buffer.
 	if trace.fullHead != 0 {
@@ -419,25 +594,22 @@ func ReadTrace() []byte {
 		trace.reading = buf
 		trace.lockOwner = nil
 		unlock(&trace.lock)
-		return buf.ptr().arr[:buf.ptr().pos]
+		return buf.ptr().arr[:buf.ptr().pos], buf.ptr().genEnd
 	}
-	// Write footer with timer frequency.
+	// Write footer of the last generation, starting with timer frequency.
 	if !trace.footerWritten {
 		trace.footerWritten = true
-		// Use float64 because (trace.ticksEnd - trace.ticksStart) * 1e9 can overflow int64.
-		freq := float64(trace.ticksEnd-trace.ticksStart) * 1e9 / float64(trace.timeEnd-trace.timeStart) / traceTickDiv
-		if freq <= 0 {
-			throw("trace: ReadTrace got invalid frequency")
-		}
+		freq := traceFrequency(trace.ticksEnd, trace.timeEnd)
 		trace.lockOwner = nil
 		unlock(&trace.lock)
-		var data []byte
 		data = append(data, traceEvFrequency|0<<traceArgCountShift)
-		data = traceAppend(data, uint64(freq))
+		data = traceAppend(data, freq)
 		// This will emit a bunch of full buffers, we will pick them up
 		// on the next iteration.
-		trace.stackTab.dump()
-		return data
+		gen := trace.gen.Load()
+		traceGenEnd(traceFlush(0, gen, 0), gen, trace.ticksEnd)
+		trace.stackTab.reset()
+		return data, 0
 	}
 	// Done.
 	if trace.shutdown {
@@ -451,22 +623,22 @@ func ReadTrace() []byte {
 		}
 		// trace.enabled is already reset, so can call traceable functions.
 		semrelease(&trace.shutdownSema)
-		return nil
+		return nil, 0
 	}
 	// Also bad, but see the comment above.
 	trace.lockOwner = nil
 	unlock(&trace.lock)
 	println("runtime: spurious wakeup of trace reader")
-	return nil
+	return nil, 0
 }
 
 // traceReader returns the trace reader that should be woken up, if any.
 func traceReader() *g {
-	if trace.reader == 0 || (trace.fullHead == 0 && !trace.shutdown) {
+	if !traceReaderAvailable() {
 		return nil
 	}
 	lock(&trace.lock)
-	if trace.reader == 0 || (trace.fullHead == 0 && !trace.shutdown) {
+	if !traceReaderAvailable() {
 		unlock(&trace.lock)
 		return nil
 	}
@@ -476,21 +648,26 @@ func traceReader() *g {
 	return gp
 }
 
-// traceProcFree frees trace buffer associated with pp.
-func traceProcFree(pp *p) {
-	buf := pp.tracebuf
-	pp.tracebuf = 0
-	if buf == 0 {
-		return
-	}
-	lock(&trace.lock)
-	traceFullQueue(buf)
-	unlock(&trace.lock)
+// traceReaderAvailable reports whether the trace reader is blocked and should
+// be woken up, because there is data to read, tracing is being stopped or
+// a new generation of the trace is due.
+func traceReaderAvailable() bool {
+	return trace.reader != 0 && (trace.fullHead != 0 || trace.shutdown || traceAdvanceDue())
 }
 
 // traceFullQueue queues buf into queue of full buffers.
 func traceFullQueue(buf traceBufPtr) {
 	buf.ptr().link = 0
+	if trace.genEnding != 0 && buf.ptr().gen > trace.genEnding {
+		// The generation of buf starts after the one being ended.
+		if trace.nextHead == 0 {
+			trace.nextHead = buf
+		} else {
+			trace.nextTail.ptr().link = buf
+		}
+		trace.nextTail = buf
+		return
+	}
 	if trace.fullHead == 0 {
 		trace.fullHead = buf
 	} else {
@@ -519,20 +696,21 @@ func traceFullDequeue() traceBufPtr {
 // If skip = 0, this event type should contain a stack, but we don't want
 // to collect and remember it for this particular call.
 func traceEvent(ev byte, skip int, args ...uint64) {
-	mp, pid, bufp := traceAcquireBuffer()
-	// Double-check trace.enabled now that we've done m.locks++ and acquired bufLock.
+	mp, gen, pid, bufp := traceAcquireBuffer()
+	// Double-check trace.enabled now that we've done m.locks++ and started writing.
 	// This protects from races between traceEvent and StartTrace/StopTrace.
 
 	// The caller checked that trace.enabled == true, but trace.enabled might have been
-	// turned off between the check and now. Check again. traceLockBuffer did mp.locks++,
+	// turned off between the check and now. Check again. traceAcquireBuffer did mp.locks++,
 	// StopTrace does stopTheWorld, and stopTheWorld waits for mp.locks to go back to zero,
 	// so if we see trace.enabled == true now, we know it's true for the rest of the function.
-	// Exitsyscall can run even during stopTheWorld. The race with StartTrace/StopTrace
-	// during tracing in exitsyscall is resolved by locking trace.bufLock in traceLockBuffer.
+	// Exitsyscall can run even during stopTheWorld. The race with StopTrace during tracing
+	// in exitsyscall is resolved by StopTrace waiting for the Ms that are writing events
+	// (see traceAcquireBuffer) after resetting trace.enabled.
 	//
 	// Note trace_userTaskCreate runs the same check.
 	if !trace.enabled && !mp.startingtrace {
-		traceReleaseBuffer(pid)
+		traceReleaseBuffer(mp)
 		return
 	}
 
@@ -541,17 +719,64 @@ func traceEvent(ev byte, skip int, args ...uint64) {
 			skip++ // +1 because stack is captured in traceEventLocked.
 		}
 	}
-	traceEventLocked(0, mp, pid, bufp, ev, skip, args...)
-	traceReleaseBuffer(pid)
+	traceEventLocked(0, mp, gen, pid, bufp, ev, skip, args...)
+	traceReleaseBuffer(mp)
 }
 
-func traceEventLocked(extraBytes int, mp *m, pid int32, bufp *traceBufPtr, ev byte, skip int, args ...uint64) {
+// traceGoEvent is like traceEvent for an event that changes the state of
+// goroutine gp, which is status before the event. The first such event of
+// gp in a generation is preceded by a traceEvGoStatus event recording it.
+func traceGoEvent(gp *g, status uint64, ev byte, skip int, args ...uint64) {
+	mp, gen, pid, bufp := traceAcquireBuffer()
+	// See the comment in traceEvent.
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(mp)
+		return
+	}
+
+	if skip > 0 {
+		if getg() == mp.curg {
+			skip++ // +1 because stack is captured in traceEventLocked.
+		}
+	}
+	traceGoStatusLocked(mp, gen, pid, bufp, gp, status)
+	traceEventLocked(0, mp, gen, pid, bufp, ev, skip, args...)
+	traceReleaseBuffer(mp)
+}
+
+// traceGoStatusLocked writes a traceEvGoStatus event recording that the state
+// of goroutine gp is status, unless its state is already recorded in
+// generation gen. It must precede the first event of gp in gen.
+func traceGoStatusLocked(mp *m, gen uint64, pid int32, bufp *traceBufPtr, gp *g, status uint64) {
+	last := atomic.Load(&gp.tracegen)
+	if last == uint32(gen) || !atomic.Cas(&gp.tracegen, last, uint32(gen)) {
+		return
+	}
+	// This may run during malloc, so the stack must not be heap allocated.
+	// +PCQuantum because traceFrameForPC expects return PCs and subtracts PCQuantum.
+	var stk [1]uintptr
+	stk[0] = startPCforTrace(gp.startpc) + sys.PCQuantum
+	id := trace.stackTab.put(gen, stk[:])
+	traceEventLocked(0, mp, gen, pid, bufp, traceEvGoStatus, -1, uint64(gp.goid), status, gp.traceseq, uint64(id))
+}
+
+func traceEventLocked(extraBytes int, mp *m, gen uint64, pid int32, bufp *traceBufPtr, ev byte, skip int, args ...uint64) {
+	// The events written for the P of the M are the ones of the goroutine
+	// running on it, whose state must be recorded before them.
+	if pp := mp.p.ptr(); pp != nil && pp.id == pid && ev != traceEvGoStatus {
+		if gp := mp.curg; gp != nil && readgstatus(gp)&^_Gscan == _Grunning {
+			traceGoStatusLocked(mp, gen, pid, bufp, gp, traceGoRunning)
+		}
+	}
+
 	buf := bufp.ptr()
 	// TODO: test on non-zero extraBytes param.
 	maxSize := 2 + 5*traceBytesPerNumber + extraBytes // event type, length, sequence, timestamp, stack id and two add params
-	if buf == nil || len(buf.arr)-buf.pos < maxSize {
-		buf = traceFlush(traceBufPtrOf(buf), pid).ptr()
+	if buf == nil || len(buf.arr)-buf.pos < maxSize+traceBatchSize {
+		buf = traceFlush(traceBufPtrOf(buf), gen, pid).ptr()
 		bufp.set(buf)
+	} else if buf.pid != pid {
+		buf.batch(pid)
 	}
 
 	// NOTE: ticks might be same after tick division, although the real cputicks is
@@ -588,7 +813,7 @@ func traceEventLocked(extraBytes int, mp *m, pid int32, bufp *traceBufPtr, ev by
 	if skip == 0 {
 		buf.varint(0)
 	} else if skip > 0 {
-		buf.varint(traceStackID(mp, buf.stk[:], skip))
+		buf.varint(traceStackID(mp, gen, buf.stk[:], skip))
 	}
 	evSize := buf.pos - startPos
 	if evSize > maxSize {
@@ -600,7 +825,7 @@ func traceEventLocked(extraBytes int, mp *m, pid int32, bufp *traceBufPtr, ev by
 	}
 }
 
-func traceStackID(mp *m, buf []uintptr, skip int) uint64 {
+func traceStackID(mp *m, gen uint64, buf []uintptr, skip int) uint64 {
 	_g_ := getg()
 	gp := mp.curg
 	var nstk int
@@ -616,30 +841,44 @@ func traceStackID(mp *m, buf []uintptr, skip int) uint64 {
 	if nstk > 0 && gp.goid == 1 {
 		nstk-- // skip runtime.main
 	}
-	id := trace.stackTab.put(buf[:nstk])
+	id := trace.stackTab.put(gen, buf[:nstk])
 	return uint64(id)
 }
 
-// traceAcquireBuffer returns trace buffer to use and, if necessary, locks it.
-func traceAcquireBuffer() (mp *m, pid int32, bufp *traceBufPtr) {
+// traceAcquireBuffer returns the trace buffer of the current M to use for
+// the current generation of the trace, which does not end before
+// traceReleaseBuffer is called, and the P to write events for.
+func traceAcquireBuffer() (mp *m, gen uint64, pid int32, bufp *traceBufPtr) {
 	mp = acquirem()
+	if mp.tracedepth == 0 {
+		// traceAdvance waits for the sequence number to be even
+		// before queueing the buffers of the ending generation.
+		atomic.Xadd(&mp.traceseqlock, 1)
+		mp.tracegen = trace.gen.Load()
+	}
+	// Events written while writing another one, for example
+	// while allocating, belong to the same generation.
+	mp.tracedepth++
+	gen = mp.tracegen
+	pid = traceGlobProc
 	if p := mp.p.ptr(); p != nil {
-		return mp, p.id, &p.tracebuf
+		pid = p.id
 	}
-	lock(&trace.bufLock)
-	return mp, traceGlobProc, &trace.buf
+	return mp, gen, pid, &mp.tracebuf[gen%2]
 }
 
 // traceReleaseBuffer releases a buffer previously acquired with traceAcquireBuffer.
-func traceReleaseBuffer(pid int32) {
-	if pid == traceGlobProc {
-		unlock(&trace.bufLock)
+func traceReleaseBuffer(mp *m) {
+	mp.tracedepth--
+	if mp.tracedepth == 0 {
+		atomic.Xadd(&mp.traceseqlock, 1)
 	}
-	releasem(getg().m)
+	releasem(mp)
 }
 
-// traceFlush puts buf onto stack of full buffers and returns an empty buffer.
-func traceFlush(buf traceBufPtr, pid int32) traceBufPtr {
+// traceFlush puts buf onto stack of full buffers and returns an empty buffer
+// for the events of generation gen, starting a batch of events for P pid.
+func traceFlush(buf traceBufPtr, gen uint64, pid int32) traceBufPtr {
 	owner := trace.lockOwner
 	dolock := owner == nil || owner != getg().m.curg
 	if dolock {
@@ -660,16 +899,11 @@ func traceFlush(buf traceBufPtr, pid int32) traceBufPtr {
 	bufp := buf.ptr()
 	bufp.link.set(nil)
 	bufp.pos = 0
+	bufp.gen = gen
+	bufp.genEnd = 0
 
 	// initialize the buffer for a new batch
-	ticks := uint64(cputicks()) / traceTickDiv
-	if ticks == bufp.lastTicks {
-		ticks = bufp.lastTicks + 1
-	}
-	bufp.lastTicks = ticks
-	bufp.byte(traceEvBatch | 1<<traceArgCountShift)
-	bufp.varint(uint64(pid))
-	bufp.varint(ticks)
+	bufp.batch(pid)
 
 	if dolock {
 		unlock(&trace.lock)
@@ -677,8 +911,22 @@ func traceFlush(buf traceBufPtr, pid int32) traceBufPtr {
 	return buf
 }
 
-// traceString adds a string to the trace.strings and returns the id.
-func traceString(bufp *traceBufPtr, pid int32, s string) (uint64, *traceBufPtr) {
+// batch starts a new batch of events in buf, written by the current M for P pid.
+func (buf *traceBuf) batch(pid int32) {
+	ticks := uint64(cputicks()) / traceTickDiv
+	if ticks == buf.lastTicks {
+		ticks = buf.lastTicks + 1
+	}
+	buf.lastTicks = ticks
+	buf.pid = pid
+	buf.byte(traceEvBatch | 2<<traceArgCountShift)
+	buf.varint(uint64(getg().m.id))
+	buf.varint(uint64(pid))
+	buf.varint(ticks)
+}
+
+// traceString adds a string to the strings of generation gen and returns the id.
+func traceString(bufp *traceBufPtr, gen uint64, pid int32, s string) (uint64, *traceBufPtr) {
 	if s == "" {
 		return 0, bufp
 	}
@@ -690,7 +938,8 @@ func traceString(bufp *traceBufPtr, pid int32, s string) (uint64, *traceBufPtr)
 		raceacquire(unsafe.Pointer(&trace.stringsLock))
 	}
 
-	if id, ok := trace.strings[s]; ok {
+	strings := trace.strings[gen%2]
+	if id, ok := strings[s]; ok {
 		if raceenabled {
 			racerelease(unsafe.Pointer(&trace.stringsLock))
 		}
@@ -701,7 +950,7 @@ func traceString(bufp *traceBufPtr, pid int32, s string) (uint64, *traceBufPtr)
 
 	trace.stringSeq++
 	id := trace.stringSeq
-	trace.strings[s] = id
+	strings[s] = id
 
 	if raceenabled {
 		racerelease(unsafe.Pointer(&trace.stringsLock))
@@ -716,7 +965,7 @@ func traceString(bufp *traceBufPtr, pid int32, s string) (uint64, *traceBufPtr)
 	buf := bufp.ptr()
 	size := 1 + 2*traceBytesPerNumber + len(s)
 	if buf == nil || len(buf.arr)-buf.pos < size {
-		buf = traceFlush(traceBufPtrOf(buf), pid).ptr()
+		buf = traceFlush(traceBufPtrOf(buf), gen, pid).ptr()
 		bufp.set(buf)
 	}
 	buf.byte(traceEvString)
@@ -777,6 +1026,7 @@ type traceStack struct {
 	link traceStackPtr
 	hash uintptr
 	id   uint32
+	gen  [2]uint32 // last two generations in which the stack was used, indexed by generation%2, accessed atomically
 	n    int
 	stk  [0]uintptr // real type [n]uintptr
 }
@@ -790,28 +1040,33 @@ func (ts *traceStack) stack() []uintptr {
 	return (*[traceStackSize]uintptr)(unsafe.Pointer(&ts.stk))[:ts.n]
 }
 
-// put returns a unique id for the stack trace pcs and caches it in the table,
-// if it sees the trace for the first time.
-func (tab *traceStackTable) put(pcs []uintptr) uint32 {
+// put returns a unique id for the stack trace pcs, used in generation gen,
+// and caches it in the table, if it sees the trace for the first time.
+func (tab *traceStackTable) put(gen uint64, pcs []uintptr) uint32 {
 	if len(pcs) == 0 {
 		return 0
 	}
-	hash := memhash(unsafe.Pointer(&pcs[0]), 0, uintptr(len(pcs))*unsafe.Sizeof(pcs[0]))
+	// pcs does not escape: the table keeps a copy of it. Its callers
+	// may pass a stack array while allocation is not allowed.
+	hash := memhash(noescape(unsafe.Pointer(&pcs[0])), 0, uintptr(len(pcs))*unsafe.Sizeof(pcs[0]))
 	// First, search the hashtable w/o the mutex.
-	if id := tab.find(pcs, hash); id != 0 {
-		return id
+	if stk := tab.find(pcs, hash); stk != nil {
+		stk.use(gen)
+		return stk.id
 	}
 	// Now, double check under the mutex.
 	lock(&tab.lock)
-	if id := tab.find(pcs, hash); id != 0 {
+	if stk := tab.find(pcs, hash); stk != nil {
 		unlock(&tab.lock)
-		return id
+		stk.use(gen)
+		return stk.id
 	}
 	// Create new record.
 	tab.seq++
 	stk := tab.newStack(len(pcs))
 	stk.hash = hash
 	stk.id = tab.seq
+	stk.gen[gen%2] = uint32(gen)
 	stk.n = len(pcs)
 	stkpc := stk.stack()
 	for i, pc := range pcs {
@@ -824,8 +1079,16 @@ func (tab *traceStackTable) put(pcs []uintptr) uint32 {
 	return stk.id
 }
 
+// use records that the stack is used in generation gen,
+// so that it is written to the trace at the end of the generation.
+func (stk *traceStack) use(gen uint64) {
+	if p := &stk.gen[gen%2]; atomic.Load(p) != uint32(gen) {
+		atomic.Store(p, uint32(gen))
+	}
+}
+
 // find checks if the stack trace pcs is already present in the table.
-func (tab *traceStackTable) find(pcs []uintptr, hash uintptr) uint32 {
+func (tab *traceStackTable) find(pcs []uintptr, hash uintptr) *traceStack {
 	part := int(hash % uintptr(len(tab.tab)))
 Search:
 	for stk := tab.tab[part].ptr(); stk != nil; stk = stk.link.ptr() {
@@ -835,10 +1098,10 @@ Search:
 					continue Search
 				}
 			}
-			return stk.id
+			return stk
 		}
 	}
-	return 0
+	return nil
 }
 
 // newStack allocates a new stack of size n.
@@ -859,21 +1122,23 @@ func allFrames(pcs []uintptr) []Frame {
 	}
 }
 
-// dump writes all previously cached stacks to trace buffers,
-// releases all memory and resets state.
-func (tab *traceStackTable) dump() {
+// dump writes the stacks used in generation gen to trace buffers,
+// starting with bufp, and returns the last buffer written to.
+func (tab *traceStackTable) dump(gen uint64, bufp traceBufPtr) traceBufPtr {
 	var tmp [(2 + 4*traceStackSize) * traceBytesPerNumber]byte
-	bufp := traceFlush(0, 0)
 	for _, stk := range tab.tab {
 		stk := stk.ptr()
 		for ; stk != nil; stk = stk.link.ptr() {
+			if atomic.Load(&stk.gen[gen%2]) != uint32(gen) {
+				continue
+			}
 			tmpbuf := tmp[:0]
 			tmpbuf = traceAppend(tmpbuf, uint64(stk.id))
 			frames := allFrames(stk.stack())
 			tmpbuf = traceAppend(tmpbuf, uint64(len(frames)))
 			for _, f := range frames {
 				var frame traceFrame
-				frame, bufp = traceFrameForPC(bufp, 0, f)
+				frame, bufp = traceFrameForPC(bufp, gen, 0, f)
 				tmpbuf = traceAppend(tmpbuf, uint64(f.PC))
 				tmpbuf = traceAppend(tmpbuf, uint64(frame.funcID))
 				tmpbuf = traceAppend(tmpbuf, uint64(frame.fileID))
@@ -882,7 +1147,7 @@ func (tab *traceStackTable) dump() {
 			// Now copy to the buffer.
 			size := 1 + traceBytesPerNumber + len(tmpbuf)
 			if buf := bufp.ptr(); len(buf.arr)-buf.pos < size {
-				bufp = traceFlush(bufp, 0)
+				bufp = traceFlush(bufp, gen, 0)
 			}
 			buf := bufp.ptr()
 			buf.byte(traceEvStack | 3<<traceArgCountShift)
@@ -890,11 +1155,11 @@ func (tab *traceStackTable) dump() {
 			buf.pos += copy(buf.arr[buf.pos:], tmpbuf)
 		}
 	}
+	return bufp
+}
 
-	lock(&trace.lock)
-	traceFullQueue(bufp)
-	unlock(&trace.lock)
-
+// reset releases all memory and resets state.
+func (tab *traceStackTable) reset() {
 	tab.mem.drop()
 	*tab = traceStackTable{}
 	lockInit(&((*tab).lock), lockRankTraceStackTab)
@@ -906,9 +1171,9 @@ type traceFrame struct {
 	line   uint64
 }
 
-// traceFrameForPC records the frame information.
+// traceFrameForPC records the frame information in generation gen.
 // It may allocate memory.
-func traceFrameForPC(buf traceBufPtr, pid int32, f Frame) (traceFrame, traceBufPtr) {
+func traceFrameForPC(buf traceBufPtr, gen uint64, pid int32, f Frame) (traceFrame, traceBufPtr) {
 	bufp := &buf
 	var frame traceFrame
 
@@ -917,13 +1182,13 @@ func traceFrameForPC(buf traceBufPtr, pid int32, f Frame) (traceFrame, traceBufP
 	if len(fn) > maxLen {
 		fn = fn[len(fn)-maxLen:]
 	}
-	frame.funcID, bufp = traceString(bufp, pid, fn)
+	frame.funcID, bufp = traceString(bufp, gen, pid, fn)
 	frame.line = uint64(f.Line)
 	file := f.File
 	if len(file) > maxLen {
 		file = file[len(file)-maxLen:]
 	}
-	frame.fileID, bufp = traceString(bufp, pid, file)
+	frame.fileID, bufp = traceString(bufp, gen, pid, file)
 	return frame, (*bufp)
 }
 
@@ -993,13 +1258,15 @@ func traceProcStart() {
 
 func traceProcStop(pp *p) {
 	// Sysmon and stopTheWorld can stop Ps blocked in syscalls,
-	// to handle this we temporary employ the P.
-	mp := acquirem()
-	oldp := mp.p
-	mp.p.set(pp)
-	traceEvent(traceEvProcStop, -1)
-	mp.p = oldp
-	releasem(mp)
+	// so the event is written for pp rather than the P of the M.
+	mp, gen, _, bufp := traceAcquireBuffer()
+	// See the comment in traceEvent.
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(mp)
+		return
+	}
+	traceEventLocked(0, mp, gen, pp.id, bufp, traceEvProcStop, -1)
+	traceReleaseBuffer(mp)
 }
 
 func traceGCStart() {
@@ -1069,62 +1336,84 @@ func traceGCMarkAssistDone() {
 
 func traceGoCreate(newg *g, pc uintptr) {
 	newg.traceseq = 0
-	newg.tracelastp = getg().m.p
+	newg.tracelastm = getg().m.id
+	mp, gen, pid, bufp := traceAcquireBuffer()
+	// See the comment in traceEvent.
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(mp)
+		return
+	}
+	// The event records the state of newg in the generation.
+	atomic.Store(&newg.tracegen, uint32(gen))
 	// +PCQuantum because traceFrameForPC expects return PCs and subtracts PCQuantum.
-	id := trace.stackTab.put([]uintptr{startPCforTrace(pc) + sys.PCQuantum})
-	traceEvent(traceEvGoCreate, 2, uint64(newg.goid), uint64(id))
+	var stk [1]uintptr
+	stk[0] = startPCforTrace(pc) + sys.PCQuantum
+	id := trace.stackTab.put(gen, stk[:])
+	traceEventLocked(0, mp, gen, pid, bufp, traceEvGoCreate, 2, uint64(newg.goid), uint64(id))
+	traceReleaseBuffer(mp)
 }
 
 func traceGoStart() {
 	_g_ := getg().m.curg
-	_p_ := _g_.m.p
-	_g_.traceseq++
-	if _p_.ptr().gcMarkWorkerMode != gcMarkWorkerNotWorker {
-		traceEvent(traceEvGoStartLabel, -1, uint64(_g_.goid), _g_.traceseq, trace.markWorkerLabels[_p_.ptr().gcMarkWorkerMode])
-	} else if _g_.tracelastp == _p_ {
-		traceEvent(traceEvGoStartLocal, -1, uint64(_g_.goid))
+	mp := _g_.m
+	_p_ := mp.p
+	if mode := _p_.ptr().gcMarkWorkerMode; mode != gcMarkWorkerNotWorker {
+		_, gen, pid, bufp := traceAcquireBuffer()
+		// See the comment in traceEvent.
+		if trace.enabled || mp.startingtrace {
+			traceGoStatusLocked(mp, gen, pid, bufp, _g_, traceGoRunnable)
+			var label uint64
+			label, bufp = traceString(bufp, gen, pid, gcMarkWorkerModeStrings[mode])
+			traceEventLocked(0, mp, gen, pid, bufp, traceEvGoStartLabel, -1, uint64(_g_.goid), _g_.traceseq+1, label)
+		}
+		traceReleaseBuffer(mp)
+	} else if _g_.tracelastm == mp.id {
+		traceGoEvent(_g_, traceGoRunnable, traceEvGoStartLocal, -1, uint64(_g_.goid))
 	} else {
-		_g_.tracelastp = _p_
-		traceEvent(traceEvGoStart, -1, uint64(_g_.goid), _g_.traceseq)
+		_g_.tracelastm = mp.id
+		traceGoEvent(_g_, traceGoRunnable, traceEvGoStart, -1, uint64(_g_.goid), _g_.traceseq+1)
 	}
+	_g_.traceseq++
 }
 
 func traceGoEnd() {
-	traceEvent(traceEvGoEnd, -1)
+	traceGoEvent(getg().m.curg, traceGoRunning, traceEvGoEnd, -1)
 }
 
 func traceGoSched() {
 	_g_ := getg()
-	_g_.tracelastp = _g_.m.p
-	traceEvent(traceEvGoSched, 1)
+	_g_.tracelastm = _g_.m.id
+	traceGoEvent(_g_.m.curg, traceGoRunning, traceEvGoSched, 1)
 }
 
 func traceGoPreempt() {
 	_g_ := getg()
-	_g_.tracelastp = _g_.m.p
-	traceEvent(traceEvGoPreempt, 1)
+	_g_.tracelastm = _g_.m.id
+	traceGoEvent(_g_.m.curg, traceGoRunning, traceEvGoPreempt, 1)
 }
 
 func traceGoPark(traceEv byte, skip int) {
 	if traceEv&traceFutileWakeup != 0 {
 		traceEvent(traceEvFutileWakeup, -1)
 	}
-	traceEvent(traceEv & ^traceFutileWakeup, skip)
+	traceGoEvent(getg().m.curg, traceGoRunning, traceEv & ^traceFutileWakeup, skip)
 }
 
 func traceGoUnpark(gp *g, skip int) {
-	_p_ := getg().m.p
-	gp.traceseq++
-	if gp.tracelastp == _p_ {
-		traceEvent(traceEvGoUnblockLocal, skip, uint64(gp.goid))
+	mp := getg().m
+	if gp.tracelastm == mp.id {
+		traceGoEvent(gp, traceGoWaiting, traceEvGoUnblockLocal, skip, uint64(gp.goid))
 	} else {
-		gp.tracelastp = _p_
-		traceEvent(traceEvGoUnblock, skip, uint64(gp.goid), gp.traceseq)
+		gp.tracelastm = mp.id
+		traceGoEvent(gp, traceGoWaiting, traceEvGoUnblock, skip, uint64(gp.goid), gp.traceseq+1)
 	}
+	gp.traceseq++
 }
 
 func traceGoSysCall() {
-	traceEvent(traceEvGoSysCall, 1)
+	mp := getg().m
+	mp.p.ptr().tracesyscallg.set(mp.curg)
+	traceGoEvent(mp.curg, traceGoRunning, traceEvGoSysCall, 1)
 }
 
 func traceGoSysExit(ts int64) {
@@ -1141,20 +1430,24 @@ func traceGoSysExit(ts int64) {
 		ts = 0
 	}
 	_g_ := getg().m.curg
+	_g_.tracelastm = _g_.m.id
+	traceGoEvent(_g_, traceGoSyscall, traceEvGoSysExit, -1, uint64(_g_.goid), _g_.traceseq+1, uint64(ts)/traceTickDiv)
 	_g_.traceseq++
-	_g_.tracelastp = _g_.m.p
-	traceEvent(traceEvGoSysExit, -1, uint64(_g_.goid), _g_.traceseq, uint64(ts)/traceTickDiv)
 }
 
 func traceGoSysBlock(pp *p) {
 	// Sysmon and stopTheWorld can declare syscalls running on remote Ps as blocked,
-	// to handle this we temporary employ the P.
-	mp := acquirem()
-	oldp := mp.p
-	mp.p.set(pp)
-	traceEvent(traceEvGoSysBlock, -1)
-	mp.p = oldp
-	releasem(mp)
+	// so the event is written for pp and names the goroutine in the syscall.
+	gp := pp.tracesyscallg.ptr()
+	mp, gen, _, bufp := traceAcquireBuffer()
+	// See the comment in traceEvent.
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(mp)
+		return
+	}
+	traceGoStatusLocked(mp, gen, pp.id, bufp, gp, traceGoRunning)
+	traceEventLocked(0, mp, gen, pp.id, bufp, traceEvGoSysBlock, -1, uint64(gp.goid))
+	traceReleaseBuffer(mp)
 }
 
 func traceHeapAlloc() {
@@ -1180,15 +1473,15 @@ func trace_userTaskCreate(id, parentID uint64, taskType string) {
 	}
 
 	// Same as in traceEvent.
-	mp, pid, bufp := traceAcquireBuffer()
+	mp, gen, pid, bufp := traceAcquireBuffer()
 	if !trace.enabled && !mp.startingtrace {
-		traceReleaseBuffer(pid)
+		traceReleaseBuffer(mp)
 		return
 	}
 
-	typeStringID, bufp := traceString(bufp, pid, taskType)
-	traceEventLocked(0, mp, pid, bufp, traceEvUserTaskCreate, 3, id, parentID, typeStringID)
-	traceReleaseBuffer(pid)
+	typeStringID, bufp := traceString(bufp, gen, pid, taskType)
+	traceEventLocked(0, mp, gen, pid, bufp, traceEvUserTaskCreate, 3, id, parentID, typeStringID)
+	traceReleaseBuffer(mp)
 }
 
 //go:linkname trace_userTaskEnd runtime/trace.userTaskEnd
@@ -1202,15 +1495,15 @@ func trace_userRegion(id, mode uint64, name string) {
 		return
 	}
 
-	mp, pid, bufp := traceAcquireBuffer()
+	mp, gen, pid, bufp := traceAcquireBuffer()
 	if !trace.enabled && !mp.startingtrace {
-		traceReleaseBuffer(pid)
+		traceReleaseBuffer(mp)
 		return
 	}
 
-	nameStringID, bufp := traceString(bufp, pid, name)
-	traceEventLocked(0, mp, pid, bufp, traceEvUserRegion, 3, id, mode, nameStringID)
-	traceReleaseBuffer(pid)
+	nameStringID, bufp := traceString(bufp, gen, pid, name)
+	traceEventLocked(0, mp, gen, pid, bufp, traceEvUserRegion, 3, id, mode, nameStringID)
+	traceReleaseBuffer(mp)
 }
 
 //go:linkname trace_userLog runtime/trace.userLog
@@ -1219,16 +1512,16 @@ func trace_userLog(id uint64, category, message string) {
 		return
 	}
 
-	mp, pid, bufp := traceAcquireBuffer()
+	mp, gen, pid, bufp := traceAcquireBuffer()
 	if !trace.enabled && !mp.startingtrace {
-		traceReleaseBuffer(pid)
+		traceReleaseBuffer(mp)
 		return
 	}
 
-	categoryID, bufp := traceString(bufp, pid, category)
+	categoryID, bufp := traceString(bufp, gen, pid, category)
 
 	extraSpace := traceBytesPerNumber + len(message) // extraSpace for the value string
-	traceEventLocked(extraSpace, mp, pid, bufp, traceEvUserLog, 3, id, categoryID)
+	traceEventLocked(extraSpace, mp, gen, pid, bufp, traceEvUserLog, 3, id, categoryID)
 	// traceEventLocked reserved extra space for val and len(val)
 	// in buf, so buf now has room for the following.
 	buf := bufp.ptr()
@@ -1242,7 +1535,17 @@ func trace_userLog(id uint64, category, message string) {
 	buf.varint(uint64(slen))
 	buf.pos += copy(buf.arr[buf.pos:], message[:slen])
 
-	traceReleaseBuffer(pid)
+	traceReleaseBuffer(mp)
+}
+
+//go:linkname trace_readTrace runtime/trace.readTrace
+func trace_readTrace() ([]byte, uint64) {
+	return readTrace()
+}
+
+//go:linkname trace_advance runtime/trace.advance
+func trace_advance() uint64 {
+	return traceAdvance()
 }
 
 // the start PC of a goroutine for tracing purposes. If pc is a wrapper,
@@ -0,0 +1,9 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+// Advance ends the current generation of the trace and returns it,
+// or 0 if tracing is disabled.
+var Advance = advance
@@ -0,0 +1,241 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	"errors"
+	"internal/flightrecorder"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A FlightRecorder records the execution trace of the program into
+// a bounded in-memory buffer, discarding the oldest part of the trace
+// as it goes. The recent history kept by the buffer can be written out
+// at any time with WriteTo, for example after the program observes an
+// unusually slow request, to find out what led to it.
+//
+// The buffer holds whole generations of the trace (see Reading traces
+// in the package documentation), so the period and size it covers are
+// approximate. The trace written by WriteTo begins with the oldest
+// generation kept and can be read with a Reader or go tool trace.
+//
+// Only one FlightRecorder may be running at a time, and not while
+// tracing with Start.
+type FlightRecorder struct {
+	mu      sync.Mutex
+	cond    sync.Cond // signaled when a generation is read or reading stops
+	period  time.Duration
+	size    int
+	running bool // whether the recorder has been started and not stopped
+	reading bool // whether the trace is being read
+	header  []byte
+	gens    []*recordedGen // complete generations, oldest first
+	bytes   int            // total size of gens
+	cur     recordedGen    // generation being read
+	lastGen uint64         // last complete generation read
+
+	writing sync.Mutex // serializes WriteTo
+}
+
+// recordedGen is a generation of the trace kept by a FlightRecorder.
+type recordedGen struct {
+	end    time.Time // when the generation was read in full
+	chunks [][]byte
+	size   int
+}
+
+// NewFlightRecorder returns a new FlightRecorder that keeps at least
+// the last 10 seconds of the trace, up to 10 MiB of it.
+func NewFlightRecorder() *FlightRecorder {
+	r := &FlightRecorder{
+		period: 10 * time.Second,
+		size:   10 << 20,
+	}
+	r.cond.L = &r.mu
+	return r
+}
+
+// SetPeriod sets the approximate duration of the most recent part of
+// the trace kept by the recorder. The size set by SetSize takes precedence.
+func (r *FlightRecorder) SetPeriod(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.period = d
+}
+
+// SetSize sets the approximate maximum size, in bytes, of the trace
+// kept by the recorder.
+func (r *FlightRecorder) SetSize(bytes int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.size = bytes
+}
+
+// Start starts recording the execution trace.
+// Start returns an error if tracing is already enabled.
+func (r *FlightRecorder) Start() error {
+	tracing.Lock()
+	defer tracing.Unlock()
+
+	if err := runtime.StartTrace(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.running = true
+	r.reading = true
+	r.header = nil
+	r.gens = nil
+	r.bytes = 0
+	r.cur = recordedGen{}
+	r.lastGen = 0
+	r.mu.Unlock()
+	go r.read()
+	atomic.StoreInt32(&tracing.enabled, 1)
+	flightrecorder.SetActive(r)
+	return nil
+}
+
+// Stop stops recording the execution trace and discards it.
+func (r *FlightRecorder) Stop() {
+	tracing.Lock()
+	defer tracing.Unlock()
+
+	r.mu.Lock()
+	running := r.running
+	r.running = false
+	r.mu.Unlock()
+	if !running {
+		return
+	}
+	flightrecorder.SetActive(nil)
+	atomic.StoreInt32(&tracing.enabled, 0)
+
+	runtime.StopTrace()
+
+	r.mu.Lock()
+	r.header = nil
+	r.gens = nil
+	r.bytes = 0
+	r.cur = recordedGen{}
+	r.mu.Unlock()
+}
+
+// Enabled reports whether the recorder is recording the execution trace.
+func (r *FlightRecorder) Enabled() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.running
+}
+
+// WriteTo writes the trace kept by the recorder to w. It first ends the
+// current generation of the trace, so that the written trace includes
+// the most recent events. WriteTo returns an error if the recorder is
+// not recording.
+func (r *FlightRecorder) WriteTo(w io.Writer) (n int64, err error) {
+	r.writing.Lock()
+	defer r.writing.Unlock()
+
+	r.mu.Lock()
+	running := r.running
+	r.mu.Unlock()
+	if !running {
+		return 0, errors.New("trace: flight recorder is not running")
+	}
+	gen := advance()
+	r.mu.Lock()
+	for r.reading && r.lastGen < gen {
+		r.cond.Wait()
+	}
+	if gen == 0 || !r.running {
+		r.mu.Unlock()
+		return 0, errors.New("trace: flight recorder is not running")
+	}
+	header := r.header
+	gens := append([]*recordedGen(nil), r.gens...)
+	r.mu.Unlock()
+
+	m, err := w.Write(header)
+	n += int64(m)
+	if err != nil {
+		return n, err
+	}
+	for _, g := range gens {
+		for _, chunk := range g.chunks {
+			m, err := w.Write(chunk)
+			n += int64(m)
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// read reads the trace into the recorder's buffer until tracing stops.
+func (r *FlightRecorder) read() {
+	for {
+		data, gen := readTrace()
+		if data == nil {
+			break
+		}
+		// The data is overwritten by the next call to readTrace.
+		chunk := append([]byte(nil), data...)
+		r.mu.Lock()
+		if r.header == nil {
+			r.header = chunk
+			r.mu.Unlock()
+			continue
+		}
+		r.cur.chunks = append(r.cur.chunks, chunk)
+		r.cur.size += len(chunk)
+		if gen != 0 {
+			g := r.cur
+			g.end = time.Now()
+			r.gens = append(r.gens, &g)
+			r.bytes += g.size
+			r.cur = recordedGen{}
+			r.lastGen = gen
+			r.trim(g.end)
+			r.cond.Broadcast()
+		}
+		r.mu.Unlock()
+	}
+	r.mu.Lock()
+	r.reading = false
+	r.cond.Broadcast()
+	r.mu.Unlock()
+}
+
+// trim discards the oldest generations that are not needed to cover
+// the recorder's period, or do not fit in its size. The most recent
+// generation is always kept.
+func (r *FlightRecorder) trim(now time.Time) {
+	for len(r.gens) > 1 {
+		// The generations after the oldest one cover the time since its end.
+		oldest := r.gens[0]
+		if now.Sub(oldest.end) < r.period && r.bytes <= r.size {
+			break
+		}
+		r.bytes -= oldest.size
+		r.gens[0] = nil
+		r.gens = r.gens[1:]
+	}
+}
+
+//
+// Function bodies are defined in runtime/trace.go
+//
+
+// readTrace is like runtime.ReadTrace, but also returns the generation
+// ended by the returned data, or 0 if it does not end one.
+func readTrace() ([]byte, uint64)
+
+// advance ends the current generation of the trace and returns it,
+// or returns 0 if tracing is disabled.
+func advance() uint64
@@ -0,0 +1,152 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace_test
+
+import (
+	"bytes"
+	"context"
+	"internal/trace"
+	"io"
+	. "runtime/trace"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFlightRecorder(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	r := NewFlightRecorder()
+	r.SetPeriod(time.Hour)
+	if err := r.Start(); err != nil {
+		t.Fatalf("failed to start flight recorder: %v", err)
+	}
+	defer r.Stop()
+	if !r.Enabled() {
+		t.Fatal("flight recorder is not enabled after Start")
+	}
+	if err := Start(io.Discard); err == nil {
+		Stop()
+		t.Fatal("Start succeeded while the flight recorder is running")
+	}
+
+	// Goroutines that exist across the generations, in various states.
+	ctx := context.Background()
+	unblock := make(chan bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-unblock
+			WithRegion(ctx, "unblocked", func() {
+				time.Sleep(time.Millisecond)
+			})
+		}()
+	}
+	Log(ctx, "phase", "early")
+	if _, err := r.WriteTo(io.Discard); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	close(unblock)
+	wg.Wait()
+	Log(ctx, "phase", "late")
+
+	buf := new(bytes.Buffer)
+	if _, err := r.WriteTo(buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	data := buf.Bytes()
+
+	_, events := readAll(t, data)
+	logs := make(map[string]bool)
+	var regions int
+	for _, ev := range events {
+		switch ev.Type {
+		case EvUserLog:
+			logs[ev.Strings[1]] = true
+		case EvUserRegion:
+			regions++
+		}
+	}
+	if !logs["early"] || !logs["late"] {
+		t.Errorf("trace contains logs %v, want both early and late", logs)
+	}
+	if regions != 8 {
+		t.Errorf("trace contains %d region events, want 8", regions)
+	}
+	if _, err := trace.Parse(bytes.NewReader(data), ""); err != nil && err != trace.ErrTimeOrder {
+		t.Fatalf("failed to parse trace: %v", err)
+	}
+
+	r.Stop()
+	if r.Enabled() {
+		t.Error("flight recorder is enabled after Stop")
+	}
+	if _, err := r.WriteTo(io.Discard); err == nil {
+		t.Error("WriteTo succeeded after Stop")
+	}
+}
+
+func TestFlightRecorderPeriod(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	r := NewFlightRecorder()
+	// Keep only the most recent generation.
+	r.SetPeriod(0)
+	if err := r.Start(); err != nil {
+		t.Fatalf("failed to start flight recorder: %v", err)
+	}
+	defer r.Stop()
+
+	ctx := context.Background()
+	Log(ctx, "phase", "discarded")
+	if _, err := r.WriteTo(io.Discard); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	if _, err := r.WriteTo(buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	_, events := readAll(t, buf.Bytes())
+	for _, ev := range events {
+		if ev.Type == EvUserLog && ev.Strings[1] == "discarded" {
+			t.Errorf("trace contains the log of a discarded generation: %v", ev)
+		}
+	}
+}
+
+func TestFlightRecorderSize(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	r := NewFlightRecorder()
+	r.SetPeriod(time.Hour)
+	r.SetSize(1)
+	if err := r.Start(); err != nil {
+		t.Fatalf("failed to start flight recorder: %v", err)
+	}
+	defer r.Stop()
+
+	var sizes []int
+	for i := 0; i < 5; i++ {
+		buf := new(bytes.Buffer)
+		WithRegion(context.Background(), "region", func() {})
+		if _, err := r.WriteTo(buf); err != nil {
+			t.Fatalf("WriteTo failed: %v", err)
+		}
+		readAll(t, buf.Bytes())
+		sizes = append(sizes, buf.Len())
+	}
+	// The size takes precedence over the period, so that only the
+	// last generation is kept and the trace does not grow.
+	for i, n := range sizes[1:] {
+		if n > 2*sizes[0]+64<<10 {
+			t.Errorf("trace %d is %d bytes, want about as large as the first one (%d bytes)", i+1, n, sizes[0])
+		}
+	}
+}
@@ -0,0 +1,668 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// EventType is the type of an event in an execution trace.
+type EventType uint8
+
+// Event types returned by Reader.ReadEvent.
+// The arguments of each event, as found in Event.Args and Event.Strings,
+// are given in square brackets.
+const (
+	EvGomaxprocs        EventType = 4  // current value of GOMAXPROCS [GOMAXPROCS]
+	EvProcStart         EventType = 5  // start of P [thread id]
+	EvProcStop          EventType = 6  // stop of P []
+	EvGCStart           EventType = 7  // GC start [seq]
+	EvGCDone            EventType = 8  // GC done []
+	EvGCSTWStart        EventType = 9  // GC STW start [kind (0: mark termination, 1: sweep termination)]
+	EvGCSTWDone         EventType = 10 // GC STW done []
+	EvGCSweepStart      EventType = 11 // GC sweep start []
+	EvGCSweepDone       EventType = 12 // GC sweep done [swept bytes, reclaimed bytes]
+	EvGoCreate          EventType = 13 // goroutine creation [new goroutine id, new stack id]
+	EvGoStart           EventType = 14 // goroutine starts running [goroutine id, seq]
+	EvGoEnd             EventType = 15 // goroutine ends []
+	EvGoStop            EventType = 16 // goroutine stops (like in select{}) []
+	EvGoSched           EventType = 17 // goroutine calls Gosched []
+	EvGoPreempt         EventType = 18 // goroutine is preempted []
+	EvGoSleep           EventType = 19 // goroutine calls Sleep []
+	EvGoBlock           EventType = 20 // goroutine blocks []
+	EvGoUnblock         EventType = 21 // goroutine is unblocked [goroutine id, seq]
+	EvGoBlockSend       EventType = 22 // goroutine blocks on chan send []
+	EvGoBlockRecv       EventType = 23 // goroutine blocks on chan recv []
+	EvGoBlockSelect     EventType = 24 // goroutine blocks on select []
+	EvGoBlockSync       EventType = 25 // goroutine blocks on Mutex/RWMutex []
+	EvGoBlockCond       EventType = 26 // goroutine blocks on Cond []
+	EvGoBlockNet        EventType = 27 // goroutine blocks on network []
+	EvGoSysCall         EventType = 28 // syscall enter []
+	EvGoSysExit         EventType = 29 // syscall exit [goroutine id, seq, real timestamp in ticks]
+	EvGoSysBlock        EventType = 30 // syscall blocks []; G is the goroutine in the syscall
+	EvGoWaiting         EventType = 31 // goroutine is blocked when tracing starts [goroutine id]
+	EvGoInSyscall       EventType = 32 // goroutine is in syscall when tracing starts [goroutine id]
+	EvHeapAlloc         EventType = 33 // heap live bytes change [heap live bytes]
+	EvHeapGoal          EventType = 34 // heap goal change [heap goal bytes]
+	EvFutileWakeup      EventType = 36 // previous wakeup of this goroutine was futile []
+	EvGoStartLabel      EventType = 41 // goroutine starts running with label [goroutine id, seq, label string id; label]
+	EvGoBlockGC         EventType = 42 // goroutine blocks on GC assist []
+	EvGCMarkAssistStart EventType = 43 // GC mark assist start []
+	EvGCMarkAssistDone  EventType = 44 // GC mark assist done []
+	EvUserTaskCreate    EventType = 45 // NewTask [task id, parent task id, name string id; name]
+	EvUserTaskEnd       EventType = 46 // end of a task [task id]
+	EvUserRegion        EventType = 47 // WithRegion [task id, mode (0: start, 1: end), name string id; name]
+	EvUserLog           EventType = 48 // Log [task id, key string id; key, value]
+)
+
+// Event types that only appear in the encoded trace.
+const (
+	evNone           = 0  // unused
+	evBatch          = 1  // start of batch of events [mid, pid, timestamp] ([pid, timestamp] before Go 1.21)
+	evFrequency      = 2  // tracer timer frequency of a generation [frequency (ticks per second)]
+	evStack          = 3  // stack [stack id, number of PCs, array of {PC, func string ID, file string ID, line}]
+	evTimerGoroutine = 35 // not currently used; previously denoted timer goroutine [timer goroutine id]
+	evString         = 37 // string dictionary entry [ID, length, string]
+	evGoStartLocal   = 38 // goroutine starts running on the same thread (P before Go 1.21) as the last event [timestamp, goroutine id]
+	evGoUnblockLocal = 39 // goroutine is unblocked on the same thread (P before Go 1.21) as the last event [timestamp, goroutine id, stack]
+	evGoSysExitLocal = 40 // syscall exit on the same thread (P before Go 1.21) as the last event [timestamp, goroutine id, real timestamp]
+	evGeneration     = 49 // end of a generation [generation, start ticks, end ticks]
+	evGoStatus       = 50 // goroutine state in a generation, before its first event in it (at its start before Go 1.21) [timestamp, goroutine id, status, seq, start stack id]
+	evCount          = 51
+)
+
+// Goroutine statuses recorded by evGoStatus.
+const (
+	goRunnable = iota
+	goRunning
+	goWaiting
+	goSyscall
+)
+
+// eventDescs describes the encoding of each event type.
+// The number of arguments does not include the timestamp and stack id.
+var eventDescs = [evCount]struct {
+	name  string
+	args  int
+	stack bool
+}{
+	evNone:              {"None", 0, false},
+	evBatch:             {"Batch", 2, false},
+	evFrequency:         {"Frequency", 1, false},
+	evStack:             {"Stack", 2, false},
+	EvGomaxprocs:        {"Gomaxprocs", 1, true},
+	EvProcStart:         {"ProcStart", 1, false},
+	EvProcStop:          {"ProcStop", 0, false},
+	EvGCStart:           {"GCStart", 1, true},
+	EvGCDone:            {"GCDone", 0, false},
+	EvGCSTWStart:        {"GCSTWStart", 1, false},
+	EvGCSTWDone:         {"GCSTWDone", 0, false},
+	EvGCSweepStart:      {"GCSweepStart", 0, true},
+	EvGCSweepDone:       {"GCSweepDone", 2, false},
+	EvGoCreate:          {"GoCreate", 2, true},
+	EvGoStart:           {"GoStart", 2, false},
+	EvGoEnd:             {"GoEnd", 0, false},
+	EvGoStop:            {"GoStop", 0, true},
+	EvGoSched:           {"GoSched", 0, true},
+	EvGoPreempt:         {"GoPreempt", 0, true},
+	EvGoSleep:           {"GoSleep", 0, true},
+	EvGoBlock:           {"GoBlock", 0, true},
+	EvGoUnblock:         {"GoUnblock", 2, true},
+	EvGoBlockSend:       {"GoBlockSend", 0, true},
+	EvGoBlockRecv:       {"GoBlockRecv", 0, true},
+	EvGoBlockSelect:     {"GoBlockSelect", 0, true},
+	EvGoBlockSync:       {"GoBlockSync", 0, true},
+	EvGoBlockCond:       {"GoBlockCond", 0, true},
+	EvGoBlockNet:        {"GoBlockNet", 0, true},
+	EvGoSysCall:         {"GoSysCall", 0, true},
+	EvGoSysExit:         {"GoSysExit", 3, false},
+	EvGoSysBlock:        {"GoSysBlock", 0, false},
+	EvGoWaiting:         {"GoWaiting", 1, false},
+	EvGoInSyscall:       {"GoInSyscall", 1, false},
+	EvHeapAlloc:         {"HeapAlloc", 1, false},
+	EvHeapGoal:          {"HeapGoal", 1, false},
+	evTimerGoroutine:    {"TimerGoroutine", 1, false},
+	EvFutileWakeup:      {"FutileWakeup", 0, false},
+	evString:            {"String", 0, false},
+	evGoStartLocal:      {"GoStartLocal", 1, false},
+	evGoUnblockLocal:    {"GoUnblockLocal", 1, true},
+	evGoSysExitLocal:    {"GoSysExitLocal", 2, false},
+	EvGoStartLabel:      {"GoStartLabel", 3, false},
+	EvGoBlockGC:         {"GoBlockGC", 0, true},
+	EvGCMarkAssistStart: {"GCMarkAssistStart", 0, true},
+	EvGCMarkAssistDone:  {"GCMarkAssistDone", 0, false},
+	EvUserTaskCreate:    {"UserTaskCreate", 3, true},
+	EvUserTaskEnd:       {"UserTaskEnd", 1, true},
+	EvUserRegion:        {"UserRegion", 3, true},
+	EvUserLog:           {"UserLog", 2, true},
+	evGeneration:        {"Generation", 3, false},
+	evGoStatus:          {"GoStatus", 3, true},
+}
+
+func (t EventType) String() string {
+	if int(t) < len(eventDescs) && eventDescs[t].name != "" {
+		return eventDescs[t].name
+	}
+	return fmt.Sprintf("EventType(%d)", t)
+}
+
+// An Event is a single event in an execution trace.
+type Event struct {
+	Type    EventType
+	Time    int64     // time of the event in nanoseconds since the start of the trace
+	P       int       // P on which the event happened, or -1 if it happened without a P
+	G       uint64    // goroutine on which the event happened, or 0 if none
+	StackID uint64    // stack of the event, if any, to be looked up with Reader.Stack
+	Args    [3]uint64 // event-type-specific arguments
+	Strings []string  // event-type-specific string arguments
+}
+
+func (ev Event) String() string {
+	return fmt.Sprintf("%v %v p=%v g=%v stk=%v args=%v strings=%q", ev.Time, ev.Type, ev.P, ev.G, ev.StackID, ev.Args, ev.Strings)
+}
+
+// A Frame is a frame of a stack in an execution trace.
+type Frame struct {
+	PC   uint64
+	Func string
+	File string
+	Line int
+}
+
+// ErrTimeOrder is returned by Reader.ReadEvent when the trace contains
+// timestamps that do not respect the actual order of the events, which
+// may happen on machines whose CPU ticks are not synchronized across cores.
+var ErrTimeOrder = errors.New("trace: time stamps out of order")
+
+// A Reader reads events from an execution trace produced by Start
+// or written by a FlightRecorder.
+//
+// The trace is partitioned into generations, each covering roughly a second
+// of the program's execution. A Reader decodes the trace one generation at a
+// time, so the memory it needs depends on the amount of activity in a single
+// generation rather than on the length of the whole trace. Each generation
+// records the state of the goroutines at its start, so a trace may begin
+// with any generation.
+//
+// Reader supports the trace formats of Go 1.19, Go 1.20 and Go 1.21. In the
+// Go 1.19 format, strings and stacks are only written in the first generation
+// that uses them and goroutine states are not recorded, so such a trace must
+// be read from its start. In the Go 1.20 format, the states of all goroutines
+// are recorded at the start of each generation. In the Go 1.21 format, the
+// state of a goroutine is recorded before its first event in a generation.
+type Reader struct {
+	r   *bufio.Reader
+	ver int // version of the trace format, such as 1020 for Go 1.20
+	off int // offset of the next byte in the trace
+
+	strings map[uint64]string
+	stacks  map[uint64][]Frame
+
+	// The generation being decoded.
+	gen      uint64
+	inGen    bool             // whether any of the generation has been read
+	freq     uint64           // ticks per second in the generation
+	batches  map[int][]*Event // events by thread (P before Go 1.21); Time is in ticks until ordered
+	statuses []*Event         // evGoStatus events of the generation
+	lastGs   map[int]uint64   // last goroutine running on thread (P before Go 1.21)
+	stream   int              // key of the current batch in batches and lastGs
+	lastP    int
+	lastG    uint64
+	lastTs   int64
+
+	// State carried across generations.
+	gs           map[uint64]gState // ordering state of goroutines
+	lastSysBlock map[uint64]int64  // time of last syscall block by goroutine, in ticks
+	procs        map[int]bool      // whether P is running
+	baseTime     int64             // time of the start of the generation
+	lastTime     int64             // time of the last ordered event
+
+	events []*Event // ordered events not yet returned
+}
+
+// NewReader returns a Reader that reads the execution trace from r.
+// It returns an error if the trace was not written by this version of Go.
+func NewReader(r io.Reader) (*Reader, error) {
+	tr := &Reader{
+		r:            bufio.NewReader(r),
+		strings:      make(map[uint64]string),
+		stacks:       make(map[uint64][]Frame),
+		batches:      make(map[int][]*Event),
+		lastGs:       make(map[int]uint64),
+		gs:           make(map[uint64]gState),
+		lastSysBlock: make(map[uint64]int64),
+		procs:        make(map[int]bool),
+	}
+	var hdr [16]byte
+	n, err := io.ReadFull(tr.r, hdr[:])
+	if err != nil {
+		return nil, fmt.Errorf("trace: failed to read header: read %v, err %v", n, err)
+	}
+	tr.off = n
+	switch string(hdr[:]) {
+	case "go 1.19 trace\x00\x00\x00":
+		tr.ver = 1019
+	case "go 1.20 trace\x00\x00\x00":
+		tr.ver = 1020
+	case "go 1.21 trace\x00\x00\x00":
+		tr.ver = 1021
+	default:
+		return nil, errors.New("trace: unsupported trace file version or not a trace file")
+	}
+	return tr, nil
+}
+
+// ReadEvent returns the next event in the trace.
+// Events are returned in time order. At the end of the trace,
+// ReadEvent returns io.EOF.
+func (r *Reader) ReadEvent() (Event, error) {
+	for len(r.events) == 0 {
+		if err := r.readGeneration(); err != nil {
+			return Event{}, err
+		}
+	}
+	ev := r.events[0]
+	r.events[0] = nil
+	r.events = r.events[1:]
+	return *ev, nil
+}
+
+// Stack returns the frames of the stack with the given id,
+// or nil if there is no such stack in the trace read so far.
+// A stack is available once the first event referring to it
+// has been returned by ReadEvent.
+func (r *Reader) Stack(id uint64) []Frame {
+	return r.stacks[id]
+}
+
+// readGeneration reads and decodes the next generation of the trace.
+func (r *Reader) readGeneration() error {
+	for {
+		off0 := r.off
+		b, err := r.r.ReadByte()
+		if err == io.EOF && !r.inGen {
+			return io.EOF
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("trace: failed to read event at offset 0x%x: %v", off0, err)
+		}
+		r.off++
+		r.inGen = true
+		typ := b << 2 >> 2
+		narg := int(b>>6) + 1
+		if typ == evNone || typ >= evCount || eventDescs[typ].name == "" || typ == evGoStatus && r.ver < 1020 {
+			return fmt.Errorf("trace: unknown event type %v at offset 0x%x", typ, off0)
+		}
+		if typ == evString {
+			// String dictionary entry [ID, length, string].
+			id, err := r.readVal()
+			if err != nil {
+				return err
+			}
+			if id == 0 {
+				return fmt.Errorf("trace: string at offset 0x%x has invalid id 0", off0)
+			}
+			if _, ok := r.strings[id]; ok {
+				return fmt.Errorf("trace: string at offset 0x%x has duplicate id %v", off0, id)
+			}
+			s, err := r.readStr()
+			if err != nil {
+				return err
+			}
+			if s == "" {
+				return fmt.Errorf("trace: string at offset 0x%x has invalid length 0", off0)
+			}
+			r.strings[id] = s
+			continue
+		}
+		var args []uint64
+		if narg < 4 {
+			for i := 0; i < narg; i++ {
+				v, err := r.readVal()
+				if err != nil {
+					return err
+				}
+				args = append(args, v)
+			}
+		} else {
+			// More than 3 args, the first value is length of the event in bytes.
+			evLen, err := r.readVal()
+			if err != nil {
+				return err
+			}
+			off1 := r.off
+			for evLen > uint64(r.off-off1) {
+				v, err := r.readVal()
+				if err != nil {
+					return err
+				}
+				args = append(args, v)
+			}
+			if evLen != uint64(r.off-off1) {
+				return fmt.Errorf("trace: event has wrong length at offset 0x%x: want %v, got %v", off0, evLen, r.off-off1)
+			}
+		}
+		desc := eventDescs[typ]
+		want := desc.args
+		switch typ {
+		case evStack:
+			want = len(args)
+		case evBatch:
+			if r.ver >= 1021 {
+				want++ // thread id
+			}
+		case evFrequency, evTimerGoroutine, evGeneration:
+		default:
+			want++ // timestamp
+			if desc.stack {
+				want++
+			}
+			if EventType(typ) == EvGoSysBlock && r.ver >= 1021 {
+				want++ // goroutine id
+			}
+		}
+		if len(args) != want {
+			return fmt.Errorf("trace: %v has wrong number of arguments at offset 0x%x: want %v, got %v", desc.name, off0, want, len(args))
+		}
+
+		switch typ {
+		case evBatch:
+			r.lastGs[r.stream] = r.lastG
+			if r.ver >= 1021 {
+				// Batches are written by threads, for any P.
+				r.stream = int(args[0])
+				args = args[1:]
+			} else {
+				r.stream = int(args[0])
+			}
+			r.lastP = int(args[0])
+			r.lastG = r.lastGs[r.stream]
+			r.lastTs = int64(args[1])
+		case evFrequency:
+			r.freq = args[0]
+			if r.freq == 0 {
+				// The most likely cause for this is tick skew on different CPUs.
+				return ErrTimeOrder
+			}
+		case evTimerGoroutine:
+		case evStack:
+			if len(args) < 2 {
+				return fmt.Errorf("trace: Stack has wrong number of arguments at offset 0x%x: want at least 2, got %v", off0, len(args))
+			}
+			size := args[1]
+			if size > 1000 {
+				return fmt.Errorf("trace: Stack has bad number of frames at offset 0x%x: %v", off0, size)
+			}
+			if uint64(len(args)) != 2+4*size {
+				return fmt.Errorf("trace: Stack has wrong number of arguments at offset 0x%x: want %v, got %v", off0, 2+4*size, len(args))
+			}
+			if id := args[0]; id != 0 && size > 0 {
+				stk := make([]Frame, size)
+				for i := range stk {
+					a := args[2+i*4:]
+					stk[i] = Frame{PC: a[0], Func: r.strings[a[1]], File: r.strings[a[2]], Line: int(a[3])}
+				}
+				r.stacks[id] = stk
+			}
+		case evGeneration:
+			return r.endGeneration(args[0], int64(args[1]), int64(args[2]))
+		default:
+			if err := r.addEvent(EventType(typ), args); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// addEvent adds an event of the given type with the given encoded arguments
+// to the current batch.
+func (r *Reader) addEvent(typ EventType, args []uint64) error {
+	e := &Event{Type: typ, P: r.lastP, G: r.lastG}
+	r.lastTs += int64(args[0])
+	e.Time = r.lastTs
+	narg := len(args)
+	for i := 1; i < narg; i++ {
+		if i == narg-1 && eventDescs[typ].stack {
+			e.StackID = args[i]
+		} else {
+			e.Args[i-1] = args[i]
+		}
+	}
+	switch typ {
+	case EvGoStart, evGoStartLocal, EvGoStartLabel:
+		r.lastG = e.Args[0]
+		e.G = r.lastG
+	case EvGCSTWStart:
+		e.G = 0
+		if kind := e.Args[0]; kind != 0 && kind != 1 {
+			return fmt.Errorf("trace: unknown STW kind %d", kind)
+		}
+	case EvGCStart, EvGCDone, EvGCSTWDone:
+		e.G = 0
+	case EvGoEnd, EvGoStop, EvGoSched, EvGoPreempt,
+		EvGoSleep, EvGoBlock, EvGoBlockSend, EvGoBlockRecv,
+		EvGoBlockSelect, EvGoBlockSync, EvGoBlockCond, EvGoBlockNet,
+		EvGoBlockGC:
+		r.lastG = 0
+	case EvGoSysBlock:
+		if r.ver >= 1021 {
+			// The syscall may be blocked by another thread,
+			// so the event names the goroutine.
+			e.G = e.Args[0]
+			e.Args[0] = 0
+			if r.lastG != e.G {
+				break
+			}
+		}
+		r.lastG = 0
+	case EvGoSysExit, EvGoWaiting, EvGoInSyscall:
+		e.G = e.Args[0]
+	case EvUserLog:
+		// EvUserLog records are followed by the value string.
+		// The key is set by resolveStrings.
+		s, err := r.readStr()
+		if err != nil {
+			return err
+		}
+		e.Strings = []string{"", s}
+	case evGoStatus:
+		e.G = e.Args[0]
+		if e.Args[1] == goRunning {
+			r.lastG = e.G
+		}
+		r.statuses = append(r.statuses, e)
+		return nil
+	}
+	r.batches[r.stream] = append(r.batches[r.stream], e)
+	return nil
+}
+
+// resolveStrings sets the string arguments of ev. A string may be defined
+// anywhere in the generation that refers to it, so this is only done once
+// the whole generation has been read.
+func (r *Reader) resolveStrings(ev *Event) {
+	switch ev.Type {
+	case EvGoStartLabel, EvUserTaskCreate, EvUserRegion:
+		ev.Strings = []string{r.strings[ev.Args[2]]}
+	case EvUserLog:
+		ev.Strings[0] = r.strings[ev.Args[1]]
+	}
+}
+
+// initState initializes the state of the goroutines from the statuses
+// recorded in the generation, for the goroutines not seen so far, which are
+// all of them if the trace does not begin with the first generation (first).
+// It returns the events with which a trace beginning at this point would
+// start.
+func (r *Reader) initState(statuses []*Event, first bool) []*Event {
+	var events, running []*Event
+	for _, st := range statuses {
+		// The recorded sequence number is the one of the last event of
+		// the goroutine; the state holds the one its next event carries.
+		g, status, seq := st.Args[0], st.Args[1], st.Args[2]+1
+		if _, ok := r.gs[g]; ok {
+			continue
+		}
+		events = append(events, &Event{Type: EvGoCreate, P: st.P, Args: [3]uint64{g, st.StackID}})
+		switch status {
+		case goRunnable:
+			r.gs[g] = gState{seq, gRunnable}
+		case goRunning:
+			r.gs[g] = gState{seq, gRunning}
+			if !r.procs[st.P] {
+				r.procs[st.P] = true
+				running = append(running, &Event{Type: EvProcStart, P: st.P})
+			}
+			running = append(running, &Event{Type: EvGoStart, P: st.P, G: g, Args: [3]uint64{g, seq}})
+		case goWaiting:
+			r.gs[g] = gState{seq, gWaiting}
+			events = append(events, &Event{Type: EvGoWaiting, P: st.P, G: g, Args: [3]uint64{g}})
+		case goSyscall:
+			r.gs[g] = gState{seq, gWaiting}
+			r.lastSysBlock[g] = 0
+			events = append(events, &Event{Type: EvGoInSyscall, P: st.P, G: g, Args: [3]uint64{g}})
+		}
+	}
+	if first {
+		// GC cycles are numbered consecutively,
+		// so the first one of the generation gives the sequence.
+		gc := gState{0, gDead}
+		found := false
+		for _, batch := range r.batches {
+			for _, ev := range batch {
+				if ev.Type == EvGCStart && (!found || ev.Args[0] < gc.seq) {
+					gc.seq = ev.Args[0]
+					found = true
+				}
+			}
+		}
+		r.gs[garbage] = gc
+	}
+	return append(events, running...)
+}
+
+// endGeneration orders the events of the generation that has just been
+// read and translates their timestamps from ticks to nanoseconds.
+func (r *Reader) endGeneration(gen uint64, startTicks, endTicks int64) error {
+	if r.gen != 0 && gen != r.gen+1 {
+		return fmt.Errorf("trace: generation %d follows generation %d", gen, r.gen)
+	}
+	if r.freq == 0 {
+		return fmt.Errorf("trace: no frequency event in generation %d", gen)
+	}
+	for _, batch := range r.batches {
+		for _, ev := range batch {
+			r.resolveStrings(ev)
+		}
+	}
+	// The statuses of the goroutines are only needed if the trace does
+	// not begin with the first generation or, since Go 1.21, for the
+	// goroutines that have no event in the generations read so far.
+	var initial []*Event
+	if r.gen == 0 && gen != 1 {
+		initial = r.initState(r.statuses, true)
+	} else if r.ver >= 1021 {
+		initial = r.initState(r.statuses, false)
+	}
+	events, err := r.order()
+	if err != nil {
+		return err
+	}
+	if len(initial) > 0 {
+		ts := r.statuses[0].Time
+		for _, st := range r.statuses {
+			if st.Time < ts {
+				ts = st.Time
+			}
+		}
+		if len(events) > 0 && events[0].Time < ts {
+			ts = events[0].Time
+		}
+		for _, ev := range initial {
+			ev.Time = ts
+		}
+		events = append(initial, events...)
+	}
+	// The first events of the trace are emitted before tracing is
+	// considered started, so the first generation starts with them.
+	if r.gen == 0 && len(events) > 0 && events[0].Time < startTicks {
+		startTicks = events[0].Time
+	}
+	// Use floating point to avoid integer overflows.
+	freq := 1e9 / float64(r.freq)
+	for _, ev := range events {
+		ev.Time = r.baseTime + int64(float64(ev.Time-startTicks)*freq)
+		// Since Go 1.21, the last events of a generation may be
+		// written after the next one has started.
+		if ev.Time < r.lastTime {
+			ev.Time = r.lastTime
+		}
+		r.lastTime = ev.Time
+		switch ev.Type {
+		case EvProcStart:
+			r.procs[ev.P] = true
+		case EvProcStop:
+			r.procs[ev.P] = false
+		}
+	}
+	r.baseTime += int64(float64(endTicks-startTicks) * freq)
+
+	r.gen = gen
+	r.inGen = false
+	r.freq = 0
+	r.batches = make(map[int][]*Event)
+	r.statuses = nil
+	if r.ver >= 1020 {
+		// Strings are defined anew in every generation.
+		r.strings = make(map[uint64]string)
+	}
+	r.events = events
+	return nil
+}
+
+// readVal reads an unsigned base-128 value.
+func (r *Reader) readVal() (uint64, error) {
+	off0 := r.off
+	var v uint64
+	for i := 0; i < 10; i++ {
+		b, err := r.r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, fmt.Errorf("trace: failed to read trace at offset 0x%x: %v", off0, err)
+		}
+		r.off++
+		v |= uint64(b&0x7f) << (uint(i) * 7)
+		if b&0x80 == 0 {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("trace: bad value at offset 0x%x", off0)
+}
+
+// readStr reads a length-prefixed string.
+func (r *Reader) readStr() (string, error) {
+	sz, err := r.readVal()
+	if err != nil || sz == 0 {
+		return "", err
+	}
+	if sz > 1e6 {
+		return "", fmt.Errorf("trace: string at offset 0x%x is too large (len=%d)", r.off, sz)
+	}
+	buf := make([]byte, sz)
+	n, err := io.ReadFull(r.r, buf)
+	r.off += n
+	if err != nil {
+		return "", fmt.Errorf("trace: failed to read string at offset 0x%x: read %v, want %v, error %v", r.off, n, sz, err)
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace_test
+
+import (
+	"bytes"
+	"context"
+	"internal/testenv"
+	"internal/trace"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	. "runtime/trace"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readAll reads all the events of the trace in data.
+func readAll(t *testing.T, data []byte) (*Reader, []Event) {
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	var events []Event
+	for {
+		ev, err := r.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err == ErrTimeOrder {
+			t.Skipf("skipping trace: %v", err)
+		}
+		if err != nil {
+			t.Fatalf("ReadEvent: %v", err)
+		}
+		events = append(events, ev)
+	}
+	return r, events
+}
+
+func TestReader(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	buf := new(bytes.Buffer)
+	if err := Start(buf); err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+	ctx, task := NewTask(context.Background(), "task")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			WithRegion(ctx, "region", func() {
+				time.Sleep(time.Millisecond)
+			})
+		}()
+	}
+	wg.Wait()
+	Log(ctx, "key", "value")
+	task.End()
+	Stop()
+
+	r, events := readAll(t, buf.Bytes())
+	var last int64
+	var sawCreate, sawLog, sawRegion bool
+	for _, ev := range events {
+		if ev.Time < last {
+			t.Fatalf("event %v at time %d follows event at time %d", ev, ev.Time, last)
+		}
+		last = ev.Time
+		switch ev.Type {
+		case EvGoCreate:
+			sawCreate = true
+		case EvUserRegion:
+			if len(ev.Strings) != 1 || ev.Strings[0] != "region" {
+				t.Errorf("region event %v, want name %q", ev, "region")
+			}
+			sawRegion = true
+		case EvUserLog:
+			if len(ev.Strings) != 2 || ev.Strings[0] != "key" || ev.Strings[1] != "value" {
+				t.Errorf("log event %v, want key %q and value %q", ev, "key", "value")
+			}
+			found := false
+			for _, f := range r.Stack(ev.StackID) {
+				if strings.HasSuffix(f.Func, "TestReader") {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("stack of log event %v does not contain TestReader: %v", ev, r.Stack(ev.StackID))
+			}
+			sawLog = true
+		}
+	}
+	if !sawCreate || !sawRegion || !sawLog {
+		t.Errorf("missing events: GoCreate %v, UserRegion %v, UserLog %v", sawCreate, sawRegion, sawLog)
+	}
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += n
+	return n, err
+}
+
+func TestReaderGenerations(t *testing.T) {
+	if file := os.Getenv("TEST_TRACE_GENERATIONS_FILE"); file != "" {
+		// Child process: write a trace spanning many generations.
+		f, err := os.Create(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Start(f); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(100 * time.Millisecond)
+		for time.Now().Before(deadline) {
+			done := make(chan bool)
+			go func() {
+				time.Sleep(100 * time.Microsecond)
+				done <- true
+			}()
+			<-done
+		}
+		Stop()
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	testenv.MustHaveExec(t)
+
+	file := filepath.Join(t.TempDir(), "trace.out")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestReaderGenerations$")
+	cmd.Env = append(os.Environ(), "GODEBUG=traceadvanceperiod=1", "TEST_TRACE_GENERATIONS_FILE="+file)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("child process failed: %v\n%s", err, out)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first event must be available long before the end of the trace.
+	cr := &countingReader{r: bytes.NewReader(data)}
+	r, err := NewReader(cr)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	if _, err := r.ReadEvent(); err != nil {
+		t.Fatalf("ReadEvent: %v", err)
+	}
+	if cr.n >= len(data)/2 {
+		t.Errorf("read %d bytes of %d to decode the first event, want the trace to be decoded incrementally", cr.n, len(data))
+	}
+
+	// The events are consistent across generations.
+	readAll(t, data)
+	if _, err := trace.Parse(bytes.NewReader(data), ""); err != nil && err != trace.ErrTimeOrder {
+		t.Fatalf("failed to parse trace: %v", err)
+	}
+}
@@ -34,6 +34,18 @@
 // See the net/http/pprof package for more details about all of the
 // debug endpoints installed by this import.
 //
+// Reading traces
+//
+// The trace is partitioned into generations, which the runtime ends
+// periodically (see the traceadvanceperiod setting of GODEBUG in the
+// runtime package). Each generation carries the strings and stacks used in
+// it, so a Reader decodes the trace one generation at a time, without
+// holding the whole trace in memory. Generations are not independent,
+// however: the Reader carries the state of goroutines and Ps and the
+// time base from one generation to the next. This allows tools to process
+// traces of long-running programs incrementally. The generations must be
+// read in order, although since Go 1.20 the trace may begin with any of them.
+//
 // User annotation
 //
 // Package trace provides user annotation APIs that can be used to
@@ -6,6 +6,7 @@ package trace_test
 
 import (
 	"bytes"
+	"context"
 	"flag"
 	"internal/race"
 	"internal/trace"
@@ -137,6 +138,73 @@ func TestTrace(t *testing.T) {
 	}
 }
 
+// TestTraceGenerations checks that a trace in which goroutines, regions
+// and a task span a generation boundary can be parsed.
+func TestTraceGenerations(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	buf := new(bytes.Buffer)
+	if err := Start(buf); err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+	ctx, task := NewTask(context.Background(), "task")
+	var started, done sync.WaitGroup
+	unblock := make(chan bool)
+	const n = 4
+	for i := 0; i < n; i++ {
+		started.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			WithRegion(ctx, "region", func() {
+				started.Done()
+				<-unblock
+			})
+		}()
+	}
+	started.Wait()
+	gen := Advance()
+	close(unblock)
+	done.Wait()
+	Log(ctx, "key", "value")
+	task.End()
+	Stop()
+	saveTrace(t, buf, "TestTraceGenerations")
+	if gen == 0 {
+		t.Fatal("failed to end a generation of the trace")
+	}
+
+	res, err := trace.Parse(bytes.NewReader(buf.Bytes()), "")
+	if err == trace.ErrTimeOrder {
+		t.Skipf("skipping trace: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("failed to parse trace: %v", err)
+	}
+	var regions, logs int
+	for _, ev := range res.Events {
+		switch ev.Type {
+		case trace.EvUserTaskCreate:
+			if ev.Link == nil {
+				t.Errorf("task %v has no end", ev)
+			}
+		case trace.EvUserRegion:
+			if ev.Args[1] == 0 {
+				regions++
+				if ev.Link == nil {
+					t.Errorf("region %v has no end", ev)
+				}
+			}
+		case trace.EvUserLog:
+			logs++
+		}
+	}
+	if regions != n || logs != 1 {
+		t.Errorf("trace contains %d regions and %d logs, want %d and 1", regions, logs, n)
+	}
+}
+
 func parseTrace(t *testing.T, r io.Reader) ([]*trace.Event, map[uint64]*trace.GDesc) {
 	res, err := trace.Parse(r, "")
 	if err == trace.ErrTimeOrder {
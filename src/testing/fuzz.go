@@ -25,6 +25,7 @@ func initFuzzFlags() {
 
 	fuzzCacheDir = flag.String("test.fuzzcachedir", "", "directory where interesting fuzzing inputs are stored (for use only by cmd/go)")
 	isFuzzWorker = flag.Bool("test.fuzzworker", false, "coordinate with the parent process to fuzz random values (for use only by cmd/go)")
+	fuzzJSON = flag.Bool("test.fuzzjson", false, "report fuzzing events for cmd/test2json (for use only by cmd/go)")
 }
 
 var (
@@ -33,6 +34,7 @@ var (
 	minimizeDuration = durationOrCountFlag{d: 60 * time.Second, allowZero: true}
 	fuzzCacheDir     *string
 	isFuzzWorker     *bool
+	fuzzJSON         *bool
 
 	// corpusDir is the parent directory of the fuzz test's seed corpus within
 	// the package.
@@ -153,7 +155,7 @@ func (f *F) Skipped() bool {
 func (f *F) Add(args ...any) {
 	var values []any
 	for i := range args {
-		if t := reflect.TypeOf(args[i]); !supportedTypes[t] {
+		if t := reflect.TypeOf(args[i]); t == nil || !isSupportedType(t) {
 			panic(fmt.Sprintf("testing: unsupported type to Add %v", t))
 		}
 		values = append(values, args[i])
@@ -161,7 +163,8 @@ func (f *F) Add(args ...any) {
 	f.corpus = append(f.corpus, corpusEntry{Values: values, IsSeed: true, Path: fmt.Sprintf("seed#%d", len(f.corpus))})
 }
 
-// supportedTypes represents all of the supported types which can be fuzzed.
+// supportedTypes represents all of the primitive types which can be fuzzed.
+// See isSupportedType for the composite types which can be fuzzed.
 var supportedTypes = map[reflect.Type]bool{
 	reflect.TypeOf(([]byte)("")):  true,
 	reflect.TypeOf((string)("")):  true,
@@ -182,6 +185,42 @@ var supportedTypes = map[reflect.Type]bool{
 	reflect.TypeOf((uint64)(0)):   true,
 }
 
+// isSupportedType reports whether values of type t can be fuzzed: t is
+// one of supportedTypes, or an array, slice, or map of supported types,
+// or a struct whose fields are all exported and of supported types.
+// Keep in sync with internal/fuzz.isSupportedType.
+func isSupportedType(t reflect.Type) bool {
+	return isSupportedTypeRec(t, make(map[reflect.Type]bool))
+}
+
+// isSupportedTypeRec implements isSupportedType. seen holds the struct
+// types being checked, which are assumed to be supported if they occur
+// within themselves.
+func isSupportedTypeRec(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if supportedTypes[t] {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Array, reflect.Slice:
+		return isSupportedTypeRec(t.Elem(), seen)
+	case reflect.Map:
+		return t.Key().Comparable() && isSupportedTypeRec(t.Key(), seen) && isSupportedTypeRec(t.Elem(), seen)
+	case reflect.Struct:
+		if seen[t] {
+			return true
+		}
+		seen[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" || !isSupportedTypeRec(f.Type, seen) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
 // Fuzz runs the fuzz function, ff, for fuzz testing. If ff fails for a set of
 // arguments, those arguments will be added to the seed corpus.
 //
@@ -193,6 +232,16 @@ var supportedTypes = map[reflect.Type]bool{
 //
 // The following types are allowed: []byte, string, bool, byte, rune, float32,
 // float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64.
+// Arrays, slices, and maps of allowed types are also allowed, as are structs
+// whose fields are all exported and of allowed types. For example:
+//
+//     type Point struct{ X, Y int }
+//
+//     f.Fuzz(func(t *testing.T, ps []Point, names map[string]Point) { ... })
+//
+// Values of these types are written to the corpus in testdata/fuzz as
+// composite literals, such as p.Point{X: int(1), Y: int(2)}. Pointers,
+// interfaces, channels, and functions are not allowed, even within structs.
 // More types may be supported in the future.
 //
 // ff must not call any *F methods, e.g. (*F).Log, (*F).Error, (*F).Skip. Use
@@ -235,7 +284,7 @@ func (f *F) Fuzz(ff any) {
 	var types []reflect.Type
 	for i := 1; i < fnType.NumIn(); i++ {
 		t := fnType.In(i)
-		if !supportedTypes[t] {
+		if !isSupportedType(t) {
 			panic(fmt.Sprintf("testing: unsupported type for fuzzing %v", t))
 		}
 		types = append(types, t)
@@ -357,7 +406,8 @@ func (f *F) Fuzz(ff any) {
 			f.corpus,
 			types,
 			corpusTargetDir,
-			cacheTargetDir)
+			cacheTargetDir,
+			*fuzzJSON)
 		if err != nil {
 			f.result = fuzzResult{Error: err}
 			f.Fail()
@@ -385,7 +435,7 @@ func (f *F) Fuzz(ff any) {
 				return errors.New(buf.String())
 			}
 			return nil
-		}); err != nil {
+		}, types); err != nil {
 			// Internal errors are marked with f.Fail; user code may call this too, before F.Fuzz.
 			// The worker will exit with fuzzWorkerExitCode, indicating this is a failure
 			// (and 'go test' should exit non-zero) but a failing input should not be recorded.